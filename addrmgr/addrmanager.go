@@ -10,6 +10,7 @@ import (
 	"encoding/base32"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
@@ -24,6 +25,7 @@ import (
 
 	"github.com/navcoin/navd/chaincfg/chainhash"
 	"github.com/navcoin/navd/wire"
+	"golang.org/x/crypto/sha3"
 )
 
 // AddrManager provides a concurrency safe address manager for caching potential
@@ -45,6 +47,7 @@ type AddrManager struct {
 	nNew           int
 	lamtx          sync.Mutex
 	localAddresses map[string]*localAddress
+	asmap          *ASMap
 }
 
 type serializedKnownAddress struct {
@@ -60,6 +63,7 @@ type serializedKnownAddress struct {
 type serializedAddrManager struct {
 	Version      int
 	Key          [32]byte
+	ASMap        string `json:",omitempty"` // checksum of the asmap used
 	Addresses    []*serializedKnownAddress
 	NewBuckets   [newBucketCount][]string // string is NetAddressKey
 	TriedBuckets [triedBucketCount][]string
@@ -154,9 +158,26 @@ const (
 	getAddrPercent = 23
 
 	// serialisationVersion is the current version of the on-disk format.
-	serialisationVersion = 1
+	// Version 2 added Tor v3 and I2P addresses which older versions are
+	// unable to parse.
+	serialisationVersion = 2
+
+	// torV3Version is the version byte encoded in Tor v3 onion addresses.
+	torV3Version = 0x03
+
+	// torV3ChecksumLen is the number of bytes of the checksum encoded in Tor
+	// v3 onion addresses.
+	torV3ChecksumLen = 2
+
+	// i2pSuffix is the suffix of I2P addresses.  The part prior to it is the
+	// base32 encoding of the address without padding.
+	i2pSuffix = ".b32.i2p"
 )
 
+// base32NoPad is the base32 encoding without padding used by Tor v3 onion and
+// I2P addresses.
+var base32NoPad = base32.StdEncoding.WithPadding(base32.NoPadding)
+
 // updateAddress is a helper function to either update an address already known
 // to the address manager, or to add the address if not already known.
 func (a *AddrManager) updateAddress(netAddr, srcAddr *wire.NetAddress) {
@@ -296,8 +317,8 @@ func (a *AddrManager) getNewBucket(netAddr, srcAddr *wire.NetAddress) int {
 
 	data1 := []byte{}
 	data1 = append(data1, a.key[:]...)
-	data1 = append(data1, []byte(GroupKey(netAddr))...)
-	data1 = append(data1, []byte(GroupKey(srcAddr))...)
+	data1 = append(data1, []byte(a.GroupKey(netAddr))...)
+	data1 = append(data1, []byte(a.GroupKey(srcAddr))...)
 	hash1 := chainhash.DoubleHashB(data1)
 	hash64 := binary.LittleEndian.Uint64(hash1)
 	hash64 %= newBucketsPerGroup
@@ -305,7 +326,7 @@ func (a *AddrManager) getNewBucket(netAddr, srcAddr *wire.NetAddress) int {
 	binary.LittleEndian.PutUint64(hashbuf[:], hash64)
 	data2 := []byte{}
 	data2 = append(data2, a.key[:]...)
-	data2 = append(data2, GroupKey(srcAddr)...)
+	data2 = append(data2, a.GroupKey(srcAddr)...)
 	data2 = append(data2, hashbuf[:]...)
 
 	hash2 := chainhash.DoubleHashB(data2)
@@ -325,7 +346,7 @@ func (a *AddrManager) getTriedBucket(netAddr *wire.NetAddress) int {
 	binary.LittleEndian.PutUint64(hashbuf[:], hash64)
 	data2 := []byte{}
 	data2 = append(data2, a.key[:]...)
-	data2 = append(data2, GroupKey(netAddr)...)
+	data2 = append(data2, a.GroupKey(netAddr)...)
 	data2 = append(data2, hashbuf[:]...)
 
 	hash2 := chainhash.DoubleHashB(data2)
@@ -363,6 +384,7 @@ func (a *AddrManager) savePeers() {
 	sam := new(serializedAddrManager)
 	sam.Version = serialisationVersion
 	copy(sam.Key[:], a.key[:])
+	sam.ASMap = a.asmapChecksum()
 
 	sam.Addresses = make([]*serializedKnownAddress, len(a.addrIndex))
 	i := 0
@@ -450,7 +472,8 @@ func (a *AddrManager) deserializePeers(filePath string) error {
 		return fmt.Errorf("error reading %s: %v", filePath, err)
 	}
 
-	if sam.Version != serialisationVersion {
+	// Version 1 files only differ in lacking the newer address types.
+	if sam.Version != 1 && sam.Version != serialisationVersion {
 		return fmt.Errorf("unknown version %v in serialized "+
 			"addrmanager", sam.Version)
 	}
@@ -503,6 +526,15 @@ func (a *AddrManager) deserializePeers(filePath string) error {
 		}
 	}
 
+	// The buckets of the addresses depend on their network groups, which
+	// depend on the asmap, so the addresses are placed into buckets anew
+	// when the asmap changed since the file was written.
+	if sam.ASMap != a.asmapChecksum() {
+		log.Infof("Asmap changed since %s was written -- rebucketing "+
+			"addresses", filePath)
+		a.rebucket()
+	}
+
 	// Sanity checking.
 	for k, v := range a.addrIndex {
 		if v.refs == 0 && !v.tried {
@@ -519,6 +551,47 @@ func (a *AddrManager) deserializePeers(filePath string) error {
 	return nil
 }
 
+// rebucket places all known addresses into the buckets they belong to anew.
+// Tried addresses remain tried unless their tried bucket is full, in which
+// case they become new addresses.  New addresses are placed into a single
+// bucket and are dropped when it is full.
+//
+// This function MUST be called with the address manager lock held.
+func (a *AddrManager) rebucket() {
+	for i := range a.addrNew {
+		a.addrNew[i] = make(map[string]*KnownAddress)
+	}
+	for i := range a.addrTried {
+		a.addrTried[i] = list.New()
+	}
+	a.nNew = 0
+	a.nTried = 0
+
+	for key, ka := range a.addrIndex {
+		wasTried := ka.tried
+		ka.tried = false
+		ka.refs = 0
+		if wasTried {
+			bucket := a.getTriedBucket(ka.na)
+			if a.addrTried[bucket].Len() < triedBucketSize {
+				ka.tried = true
+				a.nTried++
+				a.addrTried[bucket].PushBack(ka)
+				continue
+			}
+		}
+
+		bucket := a.getNewBucket(ka.na, ka.srcAddr)
+		if len(a.addrNew[bucket]) >= newBucketSize {
+			delete(a.addrIndex, key)
+			continue
+		}
+		ka.refs++
+		a.nNew++
+		a.addrNew[bucket][key] = ka
+	}
+}
+
 // DeserializeNetAddress converts a given address string to a *wire.NetAddress
 func (a *AddrManager) DeserializeNetAddress(addr string) (*wire.NetAddress, error) {
 	host, portStr, err := net.SplitHostPort(addr)
@@ -681,10 +754,74 @@ func (a *AddrManager) reset() {
 	}
 }
 
+// torV3Checksum returns the checksum encoded in the Tor v3 onion address of the
+// passed public key.
+func torV3Checksum(pubKey []byte) []byte {
+	data := make([]byte, 0, 15+len(pubKey)+1)
+	data = append(data, ".onion checksum"...)
+	data = append(data, pubKey...)
+	data = append(data, torV3Version)
+	checksum := sha3.Sum256(data)
+	return checksum[:torV3ChecksumLen]
+}
+
+// decodeTorV3 returns the public key encoded in the passed Tor v3 onion host
+// name without the ".onion" suffix.
+func decodeTorV3(name string) ([]byte, error) {
+	data, err := base32NoPad.DecodeString(strings.ToUpper(name))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != 32+torV3ChecksumLen+1 {
+		return nil, fmt.Errorf("invalid tor v3 address length %d",
+			len(data))
+	}
+	pubKey := data[:32]
+	if data[len(data)-1] != torV3Version {
+		return nil, fmt.Errorf("unsupported tor address version %d",
+			data[len(data)-1])
+	}
+	checksum := data[32 : 32+torV3ChecksumLen]
+	if string(checksum) != string(torV3Checksum(pubKey)) {
+		return nil, errors.New("invalid tor v3 address checksum")
+	}
+	return pubKey, nil
+}
+
+// encodeTorV3 returns the Tor v3 onion host name of the passed public key.
+func encodeTorV3(pubKey []byte) string {
+	data := make([]byte, 0, len(pubKey)+torV3ChecksumLen+1)
+	data = append(data, pubKey...)
+	data = append(data, torV3Checksum(pubKey)...)
+	data = append(data, torV3Version)
+	return strings.ToLower(base32NoPad.EncodeToString(data)) + ".onion"
+}
+
 // HostToNetAddress returns a netaddress given a host address.  If the address
-// is a Tor .onion address this will be taken care of.  Else if the host is
-// not an IP address it will be resolved (via Tor if required).
+// is a Tor .onion address or an I2P .b32.i2p address this will be taken care
+// of.  Else if the host is not an IP address it will be resolved (via Tor if
+// required).
 func (a *AddrManager) HostToNetAddress(host string, port uint16, services wire.ServiceFlag) (*wire.NetAddress, error) {
+	// Tor v3 address is 56 char base32 + ".onion"
+	if len(host) == 62 && host[56:] == ".onion" {
+		pubKey, err := decodeTorV3(host[:56])
+		if err != nil {
+			return nil, err
+		}
+		return wire.NewNetAddressNetwork(wire.NetTorV3, pubKey, port,
+			services)
+	}
+
+	// I2P address is 52 char base32 + ".b32.i2p"
+	if len(host) == 52+len(i2pSuffix) && strings.HasSuffix(host, i2pSuffix) {
+		data, err := base32NoPad.DecodeString(strings.ToUpper(host[:52]))
+		if err != nil {
+			return nil, err
+		}
+		return wire.NewNetAddressNetwork(wire.NetI2P, data, port,
+			services)
+	}
+
 	// Tor address is 16 char base32 + ".onion"
 	var ip net.IP
 	if len(host) == 22 && host[16:] == ".onion" {
@@ -714,8 +851,16 @@ func (a *AddrManager) HostToNetAddress(host string, port uint16, services wire.S
 
 // ipString returns a string for the ip from the provided NetAddress. If the
 // ip is in the range used for Tor addresses then it will be transformed into
-// the relevant .onion address.
+// the relevant .onion address.  Tor v3 and I2P addresses are transformed into
+// their .onion and .b32.i2p addresses.
 func ipString(na *wire.NetAddress) string {
+	if IsTorV3(na) {
+		return encodeTorV3(na.Addr)
+	}
+	if IsI2P(na) {
+		return strings.ToLower(base32NoPad.EncodeToString(na.Addr)) +
+			i2pSuffix
+	}
 	if IsOnionCatTor(na) {
 		// We know now that na.IP is long enough.
 		base32 := base32.StdEncoding.EncodeToString(na.IP[6:])
@@ -808,6 +953,19 @@ func (a *AddrManager) find(addr *wire.NetAddress) *KnownAddress {
 	return a.addrIndex[NetAddressKey(addr)]
 }
 
+// KnownServices returns the services last advertised for the given address.
+// Zero is returned when the address is not known to the address manager.
+func (a *AddrManager) KnownServices(addr *wire.NetAddress) wire.ServiceFlag {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	ka := a.find(addr)
+	if ka == nil {
+		return 0
+	}
+	return ka.na.Services
+}
+
 // Attempt increases the given address' attempt counter and updates
 // the last attempt time.
 func (a *AddrManager) Attempt(addr *wire.NetAddress) {
@@ -979,8 +1137,16 @@ func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
 		return Unreachable
 	}
 
-	if IsOnionCatTor(remoteAddr) {
-		if IsOnionCatTor(localAddr) {
+	if IsI2P(remoteAddr) {
+		if IsI2P(localAddr) {
+			return Private
+		}
+
+		return Default
+	}
+
+	if IsOnionCatTor(remoteAddr) || IsTorV3(remoteAddr) {
+		if IsOnionCatTor(localAddr) || IsTorV3(localAddr) {
 			return Private
 		}
 
@@ -1060,15 +1226,16 @@ func (a *AddrManager) GetBestLocalAddress(remoteAddr *wire.NetAddress) *wire.Net
 		}
 	}
 	if bestAddress != nil {
-		log.Debugf("Suggesting address %s:%d for %s:%d", bestAddress.IP,
-			bestAddress.Port, remoteAddr.IP, remoteAddr.Port)
+		log.Debugf("Suggesting address %s for %s",
+			NetAddressKey(bestAddress), NetAddressKey(remoteAddr))
 	} else {
-		log.Debugf("No worthy address for %s:%d", remoteAddr.IP,
-			remoteAddr.Port)
+		log.Debugf("No worthy address for %s", NetAddressKey(remoteAddr))
 
 		// Send something unroutable if nothing suitable.
 		var ip net.IP
-		if !IsIPv4(remoteAddr) && !IsOnionCatTor(remoteAddr) {
+		if !IsIPv4(remoteAddr) && !IsOnionCatTor(remoteAddr) &&
+			!IsTorV3(remoteAddr) && !IsI2P(remoteAddr) {
+
 			ip = net.IPv6zero
 		} else {
 			ip = net.IPv4zero
@@ -1080,6 +1247,43 @@ func (a *AddrManager) GetBestLocalAddress(remoteAddr *wire.NetAddress) *wire.Net
 	return bestAddress
 }
 
+// SetASMap sets the asmap used to group addresses by the autonomous system
+// which announces them.  It must be called before Start and is not safe for
+// concurrent access.
+func (a *AddrManager) SetASMap(asmap *ASMap) {
+	a.asmap = asmap
+}
+
+// asmapChecksum returns the checksum of the asmap in use, or an empty string
+// when no asmap is used.
+func (a *AddrManager) asmapChecksum() string {
+	if a.asmap == nil {
+		return ""
+	}
+	return a.asmap.Checksum()
+}
+
+// ASN returns the number of the autonomous system which announces the passed
+// address according to the asmap in use, or zero when it is unknown.
+func (a *AddrManager) ASN(na *wire.NetAddress) uint32 {
+	if a.asmap == nil {
+		return 0
+	}
+	return a.asmap.Lookup(na)
+}
+
+// GroupKey returns a string representing the network group the passed address
+// is part of.  When an asmap is in use and it maps the address, the group is
+// the autonomous system which announces the address, in the form "as" followed
+// by its number.  Otherwise, the group is the one returned by the GroupKey
+// function.
+func (a *AddrManager) GroupKey(na *wire.NetAddress) string {
+	if asn := a.ASN(na); asn != 0 {
+		return fmt.Sprintf("as%d", asn)
+	}
+	return GroupKey(na)
+}
+
 // New returns a new navcoin address manager.
 // Use Start to begin processing asynchronous address updates.
 func New(dataDir string, lookupFunc func(string) ([]net.IP, error)) *AddrManager {
@@ -7,7 +7,9 @@ package addrmgr_test
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
 	"reflect"
 	"testing"
 	"time"
@@ -230,6 +232,23 @@ func TestAttempt(t *testing.T) {
 	}
 }
 
+func TestKnownServices(t *testing.T) {
+	n := addrmgr.New("testknownservices", lookupFunc)
+
+	na := wire.NewNetAddressIPPort(net.ParseIP(someIP), 8333,
+		wire.SFNodeNetwork|wire.SFNodeP2PV2)
+	if services := n.KnownServices(na); services != 0 {
+		t.Errorf("Unknown address has services %v", services)
+	}
+
+	n.AddAddress(na, na)
+	want := wire.SFNodeNetwork | wire.SFNodeP2PV2
+	if services := n.KnownServices(na); services != want {
+		t.Errorf("Known address has services %v, want %v", services,
+			want)
+	}
+}
+
 func TestConnected(t *testing.T) {
 	n := addrmgr.New("testconnected", lookupFunc)
 
@@ -470,3 +489,113 @@ func TestNetAddressKey(t *testing.T) {
 	}
 
 }
+
+// TestHostToNetAddress ensures Tor and I2P host names are converted to the
+// expected network addresses and back.
+func TestHostToNetAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		network wire.NetworkID
+		valid   bool
+	}{
+		{
+			name:    "tor v2",
+			host:    "aaaqeayeaudaocaj.onion",
+			network: wire.NetTorV2,
+			valid:   true,
+		},
+		{
+			name:    "tor v3",
+			host:    "2gzyxa5ihm7nsggfxnu52rck2vv4rvmdlkiu3zzui5du4xyclen53wid.onion",
+			network: wire.NetTorV3,
+			valid:   true,
+		},
+		{
+			name:  "tor v3 bad checksum",
+			host:  "2gzyxa5ihm7nsggfxnu52rck2vv4rvmdlkiu3zzui5du4xyclen53wad.onion",
+			valid: false,
+		},
+		{
+			name:    "i2p",
+			host:    "aaaqeayeaudaocajbifqydiob4ibceqtcqkrmfyydenbwha5dypq.b32.i2p",
+			network: wire.NetI2P,
+			valid:   true,
+		},
+	}
+
+	amgr := addrmgr.New("testhosttonetaddress", lookupFunc)
+	for i, test := range tests {
+		na, err := amgr.HostToNetAddress(test.host, 8333,
+			wire.SFNodeNetwork)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("HostToNetAddress #%d (%s): expected "+
+					"an error and got none", i, test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("HostToNetAddress #%d (%s): unexpected "+
+				"error: %v", i, test.name, err)
+			continue
+		}
+		if na.NetworkID() != test.network {
+			t.Errorf("HostToNetAddress #%d (%s): wrong network - "+
+				"got %v, want %v", i, test.name, na.NetworkID(),
+				test.network)
+			continue
+		}
+		if !addrmgr.IsRoutable(na) {
+			t.Errorf("HostToNetAddress #%d (%s): address is not "+
+				"routable", i, test.name)
+			continue
+		}
+		want := net.JoinHostPort(test.host, "8333")
+		if key := addrmgr.NetAddressKey(na); key != want {
+			t.Errorf("HostToNetAddress #%d (%s): wrong key - got "+
+				"%s, want %s", i, test.name, key, want)
+			continue
+		}
+	}
+}
+
+// TestSavePeersAddrV2 ensures addresses which can't be represented by an IP
+// address survive saving and loading the peers file.
+func TestSavePeersAddrV2(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "testsavepeersaddrv2")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	amgr := addrmgr.New(dataDir, lookupFunc)
+	amgr.Start()
+	hosts := []string{
+		"2gzyxa5ihm7nsggfxnu52rck2vv4rvmdlkiu3zzui5du4xyclen53wid.onion",
+		"aaaqeayeaudaocajbifqydiob4ibceqtcqkrmfyydenbwha5dypq.b32.i2p",
+	}
+	for _, host := range hosts {
+		na, err := amgr.HostToNetAddress(host, 8333, wire.SFNodeNetwork)
+		if err != nil {
+			t.Fatalf("HostToNetAddress: unexpected error: %v", err)
+		}
+		amgr.AddAddress(na, na)
+	}
+	if err := amgr.Stop(); err != nil {
+		t.Fatalf("Address Manager failed to stop: %v", err)
+	}
+
+	amgr = addrmgr.New(dataDir, lookupFunc)
+	amgr.Start()
+	defer amgr.Stop()
+	if n := amgr.NumAddresses(); n != len(hosts) {
+		t.Fatalf("NumAddresses: got %d, want %d", n, len(hosts))
+	}
+	for _, na := range amgr.AddressCache() {
+		if !na.RequiresAddrV2() {
+			t.Errorf("AddressCache: loaded address %s is not an "+
+				"addrv2 address", addrmgr.NetAddressKey(na))
+		}
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/bits"
+	"net"
+
+	"github.com/navcoin/navd/chaincfg/chainhash"
+	"github.com/navcoin/navd/wire"
+)
+
+// asmapInvalid is returned when decoding a value of an asmap fails.
+const asmapInvalid = 0xffffffff
+
+// asmapInstruction is an instruction of the program encoded by an asmap.
+type asmapInstruction uint32
+
+const (
+	// asmapReturn returns the ASN which follows it.
+	asmapReturn asmapInstruction = 0
+
+	// asmapJump consumes a bit of the address and jumps ahead by the
+	// offset which follows it when the bit is set.
+	asmapJump asmapInstruction = 1
+
+	// asmapMatch consumes the bits of the address which follow it and
+	// returns the default ASN when they don't match.
+	asmapMatch asmapInstruction = 2
+
+	// asmapDefault sets the default ASN to the ASN which follows it.
+	asmapDefault asmapInstruction = 3
+)
+
+var (
+	// The following bit sizes describe the variable length encodings of
+	// the values of an asmap.
+	asmapTypeBitSizes  = []uint8{0, 0, 1}
+	asmapASNBitSizes   = []uint8{15, 16, 17, 18, 19, 20, 21, 22, 23, 24}
+	asmapMatchBitSizes = []uint8{1, 2, 3, 4, 5, 6, 7, 8}
+	asmapJumpBitSizes  = []uint8{5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16,
+		17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30}
+)
+
+// ASMap maps IP addresses to the number of the autonomous system (ASN) which
+// announces them.  It uses the compact format of the asmap files produced
+// for and used by Bitcoin Core, which encodes a program that walks the bits
+// of an IPv6 address, with IPv4 addresses mapped into IPv6.
+//
+// An ASMap is immutable and safe for concurrent access.
+type ASMap struct {
+	bits     []bool
+	checksum string
+}
+
+// asmapReader reads the values of an asmap starting at a bit position.
+type asmapReader struct {
+	bits []bool
+	pos  int
+}
+
+// decode decodes a value encoded with the provided minimum value and bit
+// sizes.  The value is encoded as a unary exponent selecting one of the bit
+// sizes followed by a mantissa of that size.
+func (r *asmapReader) decode(minVal uint32, bitSizes []uint8) uint32 {
+	val := minVal
+	for i, size := range bitSizes {
+		var bit bool
+		if i+1 != len(bitSizes) {
+			if r.pos == len(r.bits) {
+				break
+			}
+			bit = r.bits[r.pos]
+			r.pos++
+		}
+		if bit {
+			val += 1 << size
+			continue
+		}
+		for b := uint8(0); b < size; b++ {
+			// Reached the end in the mantissa.
+			if r.pos == len(r.bits) {
+				return asmapInvalid
+			}
+			if r.bits[r.pos] {
+				val += 1 << (size - 1 - b)
+			}
+			r.pos++
+		}
+		return val
+	}
+
+	// Reached the end in the exponent.
+	return asmapInvalid
+}
+
+// decodeType decodes an instruction.
+func (r *asmapReader) decodeType() asmapInstruction {
+	return asmapInstruction(r.decode(0, asmapTypeBitSizes))
+}
+
+// decodeASN decodes an ASN.
+func (r *asmapReader) decodeASN() uint32 {
+	return r.decode(1, asmapASNBitSizes)
+}
+
+// decodeMatch decodes the bits to match prefixed with a set bit.
+func (r *asmapReader) decodeMatch() uint32 {
+	return r.decode(2, asmapMatchBitSizes)
+}
+
+// decodeJump decodes a jump offset.
+func (r *asmapReader) decodeJump() uint32 {
+	return r.decode(17, asmapJumpBitSizes)
+}
+
+// checkASMap returns whether the provided asmap bits encode a program which
+// terminates with a RETURN for every input of the provided number of bits,
+// which is what makes it safe to interpret.
+func checkASMap(asmap []bool, numBits int) bool {
+	type jump struct {
+		offset  int
+		numBits int
+	}
+	r := asmapReader{bits: asmap}
+	var jumps []jump
+	prevOpcode := asmapJump
+	hadIncompleteMatch := false
+	for r.pos != len(asmap) {
+		// A jump into the middle of the previous instruction.
+		if len(jumps) > 0 && r.pos >= jumps[len(jumps)-1].offset {
+			return false
+		}
+
+		switch opcode := r.decodeType(); opcode {
+		case asmapReturn:
+			// A RETURN right after a DEFAULT could be combined into
+			// a single RETURN.
+			if prevOpcode == asmapDefault {
+				return false
+			}
+			if r.decodeASN() == asmapInvalid {
+				return false
+			}
+			if len(jumps) == 0 {
+				// Nothing is left to execute, so only up to
+				// seven unset padding bits may follow.
+				if len(asmap)-r.pos > 7 {
+					return false
+				}
+				for _, bit := range asmap[r.pos:] {
+					if bit {
+						return false
+					}
+				}
+				return true
+			}
+
+			// Continue as if the last jump was taken, so code in
+			// between is unreachable.
+			last := jumps[len(jumps)-1]
+			if r.pos != last.offset {
+				return false
+			}
+			numBits = last.numBits
+			jumps = jumps[:len(jumps)-1]
+			prevOpcode = asmapJump
+
+		case asmapJump:
+			offset := r.decodeJump()
+			if offset == asmapInvalid {
+				return false
+			}
+			if int64(offset) > int64(len(asmap)-r.pos) {
+				return false
+			}
+			if numBits == 0 {
+				return false
+			}
+			numBits--
+			target := r.pos + int(offset)
+			if len(jumps) > 0 && target >= jumps[len(jumps)-1].offset {
+				return false
+			}
+			jumps = append(jumps, jump{offset: target, numBits: numBits})
+			prevOpcode = asmapJump
+
+		case asmapMatch:
+			match := r.decodeMatch()
+			if match == asmapInvalid {
+				return false
+			}
+			matchLen := bits.Len32(match) - 1
+			if prevOpcode != asmapMatch {
+				hadIncompleteMatch = false
+			}
+
+			// Only one match of a sequence of matches may be
+			// shorter than the longest possible match.
+			if matchLen < 8 && hadIncompleteMatch {
+				return false
+			}
+			hadIncompleteMatch = matchLen < 8
+			if numBits < matchLen {
+				return false
+			}
+			numBits -= matchLen
+			prevOpcode = asmapMatch
+
+		case asmapDefault:
+			// Successive DEFAULTs could be combined into one.
+			if prevOpcode == asmapDefault {
+				return false
+			}
+			if r.decodeASN() == asmapInvalid {
+				return false
+			}
+			prevOpcode = asmapDefault
+
+		default:
+			// The instruction straddles the end.
+			return false
+		}
+	}
+
+	// Reached the end without a RETURN.
+	return false
+}
+
+// DecodeASMap decodes the provided asmap data and ensures it is well formed.
+func DecodeASMap(data []byte) (*ASMap, error) {
+	asmap := make([]bool, 0, len(data)*8)
+	for _, b := range data {
+		for bit := uint(0); bit < 8; bit++ {
+			asmap = append(asmap, (b>>bit)&1 == 1)
+		}
+	}
+	if !checkASMap(asmap, 128) {
+		return nil, errors.New("malformed asmap")
+	}
+
+	return &ASMap{
+		bits:     asmap,
+		checksum: chainhash.HashH(data).String(),
+	}, nil
+}
+
+// LoadASMap reads and decodes the asmap file at the provided path.
+func LoadASMap(filePath string) (*ASMap, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading asmap file %s: %v",
+			filePath, err)
+	}
+	m, err := DecodeASMap(data)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding asmap file %s: %v",
+			filePath, err)
+	}
+	return m, nil
+}
+
+// Checksum returns a checksum of the asmap data which identifies the asmap.
+func (m *ASMap) Checksum() string {
+	return m.checksum
+}
+
+// lookupIP returns the ASN of the provided IPv6 address, or zero when it is
+// not mapped.
+func (m *ASMap) lookupIP(ip net.IP) uint32 {
+	r := asmapReader{bits: m.bits}
+	numBits := len(ip) * 8
+	inputBit := func() bool {
+		i := len(ip)*8 - numBits
+		return ip[i/8]>>(7-uint(i%8))&1 == 1
+	}
+
+	var defaultASN uint32
+	for r.pos != len(r.bits) {
+		switch r.decodeType() {
+		case asmapReturn:
+			asn := r.decodeASN()
+			if asn == asmapInvalid {
+				return 0
+			}
+			return asn
+
+		case asmapJump:
+			offset := r.decodeJump()
+			if offset == asmapInvalid || numBits == 0 ||
+				int64(offset) >= int64(len(r.bits)-r.pos) {
+
+				return 0
+			}
+			if inputBit() {
+				r.pos += int(offset)
+			}
+			numBits--
+
+		case asmapMatch:
+			match := r.decodeMatch()
+			if match == asmapInvalid {
+				return 0
+			}
+			matchLen := bits.Len32(match) - 1
+			if numBits < matchLen {
+				return 0
+			}
+			for bit := 0; bit < matchLen; bit++ {
+				want := (match>>uint(matchLen-1-bit))&1 == 1
+				if inputBit() != want {
+					return defaultASN
+				}
+				numBits--
+			}
+
+		case asmapDefault:
+			defaultASN = r.decodeASN()
+			if defaultASN == asmapInvalid {
+				return 0
+			}
+
+		default:
+			return 0
+		}
+	}
+
+	// The asmap was checked when decoded, so this is never reached.
+	return 0
+}
+
+// Lookup returns the ASN of the provided address, or zero when it is not an
+// IPv4 or IPv6 address or not mapped.
+func (m *ASMap) Lookup(na *wire.NetAddress) uint32 {
+	if IsTorV3(na) || IsI2P(na) || IsOnionCatTor(na) {
+		return 0
+	}
+	ip := na.IP.To16()
+	if ip == nil {
+		return 0
+	}
+	return m.lookupIP(ip)
+}
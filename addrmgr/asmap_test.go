@@ -0,0 +1,217 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/navcoin/navd/addrmgr"
+	"github.com/navcoin/navd/wire"
+)
+
+// asmapWriter encodes asmap programs for the tests.
+type asmapWriter struct {
+	bits []bool
+}
+
+// write encodes the provided value with the provided minimum value and bit
+// sizes as a unary exponent followed by a mantissa.
+func (w *asmapWriter) write(val, minVal uint32, bitSizes []uint8) {
+	val -= minVal
+	for i, size := range bitSizes {
+		if i+1 != len(bitSizes) {
+			if val >= 1<<size {
+				w.bits = append(w.bits, true)
+				val -= 1 << size
+				continue
+			}
+			w.bits = append(w.bits, false)
+		}
+		for b := int(size) - 1; b >= 0; b-- {
+			w.bits = append(w.bits, (val>>uint(b))&1 == 1)
+		}
+		return
+	}
+}
+
+func (w *asmapWriter) opcode(op uint32) {
+	w.write(op, 0, []uint8{0, 0, 1})
+}
+
+func (w *asmapWriter) ret(asn uint32) {
+	w.opcode(0)
+	w.write(asn, 1, []uint8{15, 16, 17, 18, 19, 20, 21, 22, 23, 24})
+}
+
+func (w *asmapWriter) jump(offset uint32) {
+	w.opcode(1)
+	w.write(offset, 17, []uint8{5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30})
+}
+
+// match encodes a match of the provided byte.
+func (w *asmapWriter) match(b byte) {
+	w.opcode(2)
+	w.write(0x100|uint32(b), 2, []uint8{1, 2, 3, 4, 5, 6, 7, 8})
+}
+
+// bytes returns the encoded program padded to whole bytes.
+func (w *asmapWriter) bytes() []byte {
+	data := make([]byte, (len(w.bits)+7)/8)
+	for i, bit := range w.bits {
+		if bit {
+			data[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return data
+}
+
+// testASMap returns an asmap which maps the IPv4 addresses below 128.0.0.0 to
+// AS 100 and the others to AS 200, leaving IPv6 addresses unmapped.
+func testASMap() []byte {
+	var w asmapWriter
+
+	// Match the IPv4-mapped IPv6 prefix ::ffff:0:0/96.
+	for i := 0; i < 10; i++ {
+		w.match(0x00)
+	}
+	w.match(0xff)
+	w.match(0xff)
+
+	// Branch on the first bit of the IPv4 address.
+	var ret asmapWriter
+	ret.ret(100)
+	w.jump(uint32(len(ret.bits)))
+	w.ret(100)
+	w.ret(200)
+	return w.bytes()
+}
+
+// TestASMapLookup ensures addresses are mapped to the expected ASNs.
+func TestASMapLookup(t *testing.T) {
+	asmap, err := addrmgr.DecodeASMap(testASMap())
+	if err != nil {
+		t.Fatalf("DecodeASMap: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		host string
+		want uint32
+	}{
+		{host: "10.1.2.3", want: 100},
+		{host: "127.255.255.255", want: 100},
+		{host: "128.0.0.1", want: 200},
+		{host: "203.0.113.7", want: 200},
+		{host: "2001:db8::1", want: 0},
+		{host: "2gzyxa5ihm7nsggfxnu52rck2vv4rvmdlkiu3zzui5du4xyclen53wid.onion", want: 0},
+	}
+
+	amgr := addrmgr.New("", nil)
+	for _, test := range tests {
+		na, err := amgr.HostToNetAddress(test.host, 8333,
+			wire.SFNodeNetwork)
+		if err != nil {
+			t.Fatalf("HostToNetAddress %s: unexpected error: %v",
+				test.host, err)
+		}
+		if asn := asmap.Lookup(na); asn != test.want {
+			t.Errorf("Lookup %s: got AS%d, want AS%d", test.host,
+				asn, test.want)
+		}
+	}
+}
+
+// TestDecodeASMapMalformed ensures malformed asmaps are rejected.
+func TestDecodeASMapMalformed(t *testing.T) {
+	valid := testASMap()
+	padded := append([]byte(nil), valid...)
+	padded[len(padded)-1] |= 0x80
+
+	var noReturn asmapWriter
+	noReturn.match(0x00)
+
+	var unreachable asmapWriter
+	unreachable.ret(100)
+	unreachable.ret(200)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "truncated", data: valid[:len(valid)-2]},
+		{name: "nonzero padding", data: padded},
+		{name: "excessive padding", data: append(valid, 0)},
+		{name: "no return", data: noReturn.bytes()},
+		{name: "unreachable code", data: unreachable.bytes()},
+	}
+	for _, test := range tests {
+		if _, err := addrmgr.DecodeASMap(test.data); err == nil {
+			t.Errorf("%s: DecodeASMap did not return an error",
+				test.name)
+		}
+	}
+}
+
+// TestAddrManagerASMap ensures the address manager groups addresses by ASN when
+// an asmap is in use and keeps the known addresses when the asmap changes.
+func TestAddrManagerASMap(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "testaddrmanagerasmap")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	asmapFile := filepath.Join(dataDir, "ip_asn.map")
+	if err := ioutil.WriteFile(asmapFile, testASMap(), 0644); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	asmap, err := addrmgr.LoadASMap(asmapFile)
+	if err != nil {
+		t.Fatalf("LoadASMap: unexpected error: %v", err)
+	}
+
+	// Addresses from different /16 networks announced by the same AS are
+	// in the same group.
+	amgr := addrmgr.New(dataDir, lookupFunc)
+	na1 := wire.NewNetAddressIPPort(net.ParseIP("12.1.2.3"), 8333, 0)
+	na2 := wire.NewNetAddressIPPort(net.ParseIP("13.1.2.3"), 8333, 0)
+	na3 := wire.NewNetAddressIPPort(net.ParseIP("2a00:1450::1"), 8333, 0)
+	if amgr.GroupKey(na1) == amgr.GroupKey(na2) {
+		t.Fatal("GroupKey: addresses grouped without an asmap")
+	}
+	amgr.Start()
+	amgr.AddAddresses([]*wire.NetAddress{na1, na2, na3}, na1)
+	if err := amgr.Stop(); err != nil {
+		t.Fatalf("Address Manager failed to stop: %v", err)
+	}
+
+	amgr = addrmgr.New(dataDir, lookupFunc)
+	amgr.SetASMap(asmap)
+	if got := amgr.GroupKey(na1); got != "as100" {
+		t.Fatalf("GroupKey: got %s, want as100", got)
+	}
+	if amgr.GroupKey(na1) != amgr.GroupKey(na2) {
+		t.Fatal("GroupKey: addresses of the same AS not grouped")
+	}
+	if got, want := amgr.GroupKey(na3), addrmgr.GroupKey(na3); got != want {
+		t.Fatalf("GroupKey: got %s for unmapped address, want %s",
+			got, want)
+	}
+	if asn := amgr.ASN(na2); asn != 100 {
+		t.Fatalf("ASN: got %d, want 100", asn)
+	}
+
+	// The addresses saved without an asmap are kept.
+	amgr.Start()
+	defer amgr.Stop()
+	if n := amgr.NumAddresses(); n != 3 {
+		t.Fatalf("NumAddresses: got %d, want 3", n)
+	}
+}
@@ -200,3 +200,52 @@ func TestGroupKey(t *testing.T) {
 		}
 	}
 }
+
+// TestAddrV2Types ensures addresses which can't be represented by an IP address
+// are classified and grouped properly.
+func TestAddrV2Types(t *testing.T) {
+	addr := make([]byte, 32)
+	addr[0] = 0x35
+	tests := []struct {
+		name     string
+		network  wire.NetworkID
+		torV3    bool
+		i2p      bool
+		routable bool
+		groupKey string
+	}{
+		{name: "tor v3", network: wire.NetTorV3, torV3: true,
+			routable: true, groupKey: "torv3:5"},
+		{name: "i2p", network: wire.NetI2P, i2p: true, routable: true,
+			groupKey: "i2p:5"},
+		{name: "unknown", network: wire.NetworkID(0xff),
+			groupKey: "unroutable"},
+	}
+
+	for i, test := range tests {
+		na, err := wire.NewNetAddressNetwork(test.network, addr, 8333,
+			wire.SFNodeNetwork)
+		if err != nil {
+			t.Errorf("TestAddrV2Types #%d (%s): unexpected error: "+
+				"%v", i, test.name, err)
+			continue
+		}
+		if rv := addrmgr.IsTorV3(na); rv != test.torV3 {
+			t.Errorf("TestAddrV2Types #%d (%s): IsTorV3 got %v, "+
+				"want %v", i, test.name, rv, test.torV3)
+		}
+		if rv := addrmgr.IsI2P(na); rv != test.i2p {
+			t.Errorf("TestAddrV2Types #%d (%s): IsI2P got %v, "+
+				"want %v", i, test.name, rv, test.i2p)
+		}
+		if rv := addrmgr.IsRoutable(na); rv != test.routable {
+			t.Errorf("TestAddrV2Types #%d (%s): IsRoutable got "+
+				"%v, want %v", i, test.name, rv, test.routable)
+		}
+		if key := addrmgr.GroupKey(na); key != test.groupKey {
+			t.Errorf("TestAddrV2Types #%d (%s): unexpected group "+
+				"key - got '%s', want '%s'", i, test.name, key,
+				test.groupKey)
+		}
+	}
+}
@@ -63,7 +63,7 @@ func (b *BlockChain) maybeAcceptBlock(block *navutil.Block, flags BehaviorFlags)
 	// Create a new block node for the block and add it to the in-memory
 	// block chain (could be either a side chain or the main chain).
 	blockHeader := &block.MsgBlock().Header
-	newNode := newBlockNode(blockHeader, blockHeight)
+	newNode := newBlockNode(blockHeader, block.Hash(), blockHeight)
 	newNode.status = statusDataStored
 	if prevNode != nil {
 		newNode.parent = prevNode
@@ -101,16 +101,23 @@ type blockNode struct {
 	status blockStatus
 }
 
-// initBlockNode initializes a block node from the given header and height.  The
-// node is completely disconnected from the chain and the workSum value is just
-// the work for the passed block.  The work sum must be updated accordingly when
-// the node is inserted into a chain.
+// initBlockNode initializes a block node from the given header, hash and
+// height.  The node is completely disconnected from the chain and the workSum
+// value is just the work for the passed block.  The work sum must be updated
+// accordingly when the node is inserted into a chain.
+//
+// The hash is computed from the header when it is nil.  Callers which already
+// know the hash should pass it since hashing X13 headers is expensive.
 //
 // This function is NOT safe for concurrent access.  It must only be called when
 // initially creating a node.
-func initBlockNode(node *blockNode, blockHeader *wire.BlockHeader, height int32) {
+func initBlockNode(node *blockNode, blockHeader *wire.BlockHeader, blockHash *chainhash.Hash, height int32) {
+	if blockHash == nil {
+		hash := blockHeader.BlockHash()
+		blockHash = &hash
+	}
 	*node = blockNode{
-		hash:       blockHeader.BlockHash(),
+		hash:       *blockHash,
 		workSum:    CalcWork(blockHeader.Bits),
 		height:     height,
 		version:    blockHeader.Version,
@@ -121,13 +128,14 @@ func initBlockNode(node *blockNode, blockHeader *wire.BlockHeader, height int32)
 	}
 }
 
-// newBlockNode returns a new block node for the given block header.  It is
-// completely disconnected from the chain and the workSum value is just the work
-// for the passed block.  The work sum must be updated accordingly when the node
-// is inserted into a chain.
-func newBlockNode(blockHeader *wire.BlockHeader, height int32) *blockNode {
+// newBlockNode returns a new block node for the given block header and hash.  It
+// is completely disconnected from the chain and the workSum value is just the
+// work for the passed block.  The work sum must be updated accordingly when the
+// node is inserted into a chain.  The hash is computed from the header when it
+// is nil.
+func newBlockNode(blockHeader *wire.BlockHeader, blockHash *chainhash.Hash, height int32) *blockNode {
 	var node blockNode
-	initBlockNode(&node, blockHeader, height)
+	initBlockNode(&node, blockHeader, blockHash, height)
 	return &node
 }
 
@@ -1059,7 +1059,7 @@ func (b *BlockChain) createChainState() error {
 	// Create a new node from the genesis block and set it as the best node.
 	genesisBlock := navutil.NewBlock(b.chainParams.GenesisBlock)
 	header := &genesisBlock.MsgBlock().Header
-	node := newBlockNode(header, 0)
+	node := newBlockNode(header, genesisBlock.Hash(), 0)
 	node.status = statusDataStored | statusValid
 	b.bestChain.SetTip(node)
 
@@ -1151,12 +1151,20 @@ func (b *BlockChain) initChainState() error {
 		// number of nodes are already known, perform a single alloc
 		// for them versus a whole bunch of little ones to reduce
 		// pressure on the GC.
+		//
+		// The hashes stored in the height index are used rather than
+		// hashing every header again, which is expensive for the X13
+		// headers of the early chain.
 		log.Infof("Loading block index.  This might take a while...")
 		bestHeight := int32(state.height)
 		blockNodes := make([]blockNode, bestHeight+1)
 		var tip *blockNode
 		for height := int32(0); height <= bestHeight; height++ {
-			header, err := dbFetchHeaderByHeight(dbTx, height)
+			hash, err := dbFetchHashByHeight(dbTx, height)
+			if err != nil {
+				return err
+			}
+			header, err := dbFetchHeaderByHash(dbTx, hash)
 			if err != nil {
 				return err
 			}
@@ -1164,7 +1172,7 @@ func (b *BlockChain) initChainState() error {
 			// Initialize the block node for the block, connect it,
 			// and add it to the block index.
 			node := &blockNodes[height]
-			initBlockNode(node, header, height)
+			initBlockNode(node, header, hash, height)
 			node.status = statusDataStored | statusValid
 			if tip != nil {
 				node.parent = tip
@@ -1242,17 +1250,6 @@ func dbFetchHeaderByHash(dbTx database.Tx, hash *chainhash.Hash) (*wire.BlockHea
 	return &header, nil
 }
 
-// dbFetchHeaderByHeight uses an existing database transaction to retrieve the
-// block header for the provided height.
-func dbFetchHeaderByHeight(dbTx database.Tx, height int32) (*wire.BlockHeader, error) {
-	hash, err := dbFetchHashByHeight(dbTx, height)
-	if err != nil {
-		return nil, err
-	}
-
-	return dbFetchHeaderByHash(dbTx, hash)
-}
-
 // dbFetchBlockByNode uses an existing database transaction to retrieve the
 // raw block for the provided node, deserialize it, and return a navutil.Block
 // with the height set.
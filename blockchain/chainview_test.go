@@ -32,7 +32,7 @@ func chainedNodes(parent *blockNode, numNodes int) []*blockNode {
 			header.PrevBlock = tip.hash
 			height = tip.height + 1
 		}
-		node := newBlockNode(&header, height)
+		node := newBlockNode(&header, nil, height)
 		node.parent = tip
 		tip = node
 
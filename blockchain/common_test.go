@@ -261,7 +261,7 @@ func (b *BlockChain) TstSetCoinbaseMaturity(maturity uint16) {
 func newFakeChain(params *chaincfg.Params) *BlockChain {
 	// Create a genesis block node and block index index populated with it
 	// for use when creating the fake chain below.
-	node := newBlockNode(&params.GenesisBlock.Header, 0)
+	node := newBlockNode(&params.GenesisBlock.Header, nil, 0)
 	index := newBlockIndex(nil, params)
 	index.AddNode(node)
 
@@ -291,7 +291,7 @@ func newFakeNode(parent *blockNode, blockVersion int32, bits uint32, timestamp t
 		Bits:      bits,
 		Timestamp: timestamp,
 	}
-	node := newBlockNode(header, parent.height+1)
+	node := newBlockNode(header, nil, parent.height+1)
 	node.parent = parent
 	node.workSum.Add(parent.workSum, node.workSum)
 	return node
@@ -165,7 +165,8 @@ func (b *BlockChain) ProcessBlock(block *navutil.Block, flags BehaviorFlags) (bo
 	}
 
 	// Perform preliminary sanity checks on the block and its transactions.
-	err = checkBlockSanity(block, b.chainParams.PowLimit, b.timeSource, flags)
+	err = checkBlockSanity(block, b.chainParams.PowLimit,
+		b.chainParams.SignetChallenge, b.timeSource, flags)
 	if err != nil {
 		return false, false, err
 	}
@@ -0,0 +1,149 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/navcoin/navd/chaincfg/chainhash"
+	"github.com/navcoin/navd/txscript"
+	"github.com/navcoin/navd/wire"
+	"github.com/navcoin/navutil"
+)
+
+// SignetHeader is the header of the data pushed by the OP_RETURN output of a
+// coinbase transaction which holds the signet solution of the block.  The
+// solution follows the header.
+var SignetHeader = [4]byte{0xec, 0xc7, 0xda, 0xa2}
+
+// signetScriptFlags are the script flags used to check signet solutions.
+// They are fixed consensus rules rather than the standardness policy, which
+// may change between releases, so every node agrees on which solutions are
+// valid.
+const signetScriptFlags = txscript.ScriptBip16 |
+	txscript.ScriptVerifyDERSignatures |
+	txscript.ScriptStrictMultiSig |
+	txscript.ScriptVerifyWitness
+
+// signetSolutionIndex returns the index of the output of the passed coinbase
+// transaction which holds the signet solution of its block along with the
+// solution itself.  The index is -1 when there is no such output.  When there
+// are several, the last one is used.
+func signetSolutionIndex(coinbaseTx *wire.MsgTx) (int, []byte) {
+	for i := len(coinbaseTx.TxOut) - 1; i >= 0; i-- {
+		pkScript := coinbaseTx.TxOut[i].PkScript
+		if len(pkScript) == 0 || pkScript[0] != txscript.OP_RETURN {
+			continue
+		}
+		pushes, err := txscript.PushedData(pkScript)
+		if err != nil || len(pushes) != 1 ||
+			!bytes.HasPrefix(pushes[0], SignetHeader[:]) {
+
+			continue
+		}
+		return i, pushes[0][len(SignetHeader):]
+	}
+	return -1, nil
+}
+
+// RemoveSignetSolution removes the output holding the signet solution, if
+// any, from the passed coinbase transaction.
+func RemoveSignetSolution(coinbaseTx *wire.MsgTx) {
+	if idx, _ := signetSolutionIndex(coinbaseTx); idx >= 0 {
+		coinbaseTx.TxOut = append(coinbaseTx.TxOut[:idx],
+			coinbaseTx.TxOut[idx+1:]...)
+	}
+}
+
+// SignetTxns returns the virtual transactions used to check the signet
+// solution of the passed block against the passed challenge script as
+// described by BIP0325.
+//
+// The first transaction pays to the challenge and commits to the version,
+// previous block, time and transactions of the block with the output holding
+// the solution removed from the coinbase.  The second transaction spends it
+// with the solution of the block as its signature script, which is empty when
+// the block has no solution yet.  A block is signed by signing the input of
+// the second transaction.
+func SignetTxns(block *wire.MsgBlock, challenge []byte) (*wire.MsgTx, *wire.MsgTx, error) {
+	if len(block.Transactions) == 0 {
+		return nil, nil, ruleError(ErrNoTransactions, "block does not "+
+			"contain any transactions")
+	}
+
+	// Calculate the merkle root of the block without the signet solution.
+	coinbaseTx := block.Transactions[0].Copy()
+	_, solution := signetSolutionIndex(coinbaseTx)
+	RemoveSignetSolution(coinbaseTx)
+	txns := make([]*navutil.Tx, 0, len(block.Transactions))
+	txns = append(txns, navutil.NewTx(coinbaseTx))
+	for _, tx := range block.Transactions[1:] {
+		txns = append(txns, navutil.NewTx(tx))
+	}
+	merkles := BuildMerkleTreeStore(txns, false)
+	merkleRoot := merkles[len(merkles)-1]
+
+	// The committed block data is the version, previous block, merkle root
+	// and time of the block.
+	header := &block.Header
+	blockData := make([]byte, 0, 8+chainhash.HashSize*2)
+	var scratch [4]byte
+	binary.LittleEndian.PutUint32(scratch[:], uint32(header.Version))
+	blockData = append(blockData, scratch[:]...)
+	blockData = append(blockData, header.PrevBlock[:]...)
+	blockData = append(blockData, merkleRoot[:]...)
+	binary.LittleEndian.PutUint32(scratch[:], uint32(header.Timestamp.Unix()))
+	blockData = append(blockData, scratch[:]...)
+	commitment, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).
+		AddData(blockData).Script()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	toSpend := wire.NewMsgTx(0)
+	toSpend.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: commitment,
+	})
+	toSpend.AddTxOut(wire.NewTxOut(0, challenge))
+
+	toSpendHash := toSpend.TxHash()
+	toSign := wire.NewMsgTx(0)
+	toSign.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&toSpendHash, 0),
+		SignatureScript:  solution,
+	})
+	toSign.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_RETURN}))
+
+	return toSpend, toSign, nil
+}
+
+// checkSignetSolution ensures the signet solution of the passed block
+// satisfies the passed challenge script.
+func checkSignetSolution(block *wire.MsgBlock, challenge []byte) error {
+	_, toSign, err := SignetTxns(block, challenge)
+	if err != nil {
+		return err
+	}
+	if len(toSign.TxIn[0].SignatureScript) == 0 {
+		return ruleError(ErrBadSignetSolution, "block does not contain "+
+			"a signet solution")
+	}
+
+	vm, err := txscript.NewEngine(challenge, toSign, 0,
+		signetScriptFlags, nil, nil, 0)
+	if err == nil {
+		err = vm.Execute()
+	}
+	if err != nil {
+		str := fmt.Sprintf("signet solution does not satisfy the "+
+			"challenge: %v", err)
+		return ruleError(ErrBadSignetSolution, str)
+	}
+	return nil
+}
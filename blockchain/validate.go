@@ -299,13 +299,13 @@ func CheckTransactionSanity(tx *navutil.Tx) error {
 }
 
 // checkProofOfWork ensures the block header bits which indicate the target
-// difficulty is in min/max range and that the block hash is less than the
-// target difficulty as claimed.
+// difficulty is in min/max range and that the passed block hash of the header
+// is less than the target difficulty as claimed.
 //
 // The flags modify the behavior of this function as follows:
 //  - BFNoPoWCheck: The check to ensure the block hash is less than the target
 //    difficulty is not performed.
-func checkProofOfWork(header *wire.BlockHeader, powLimit *big.Int, flags BehaviorFlags) error {
+func checkProofOfWork(header *wire.BlockHeader, blockHash *chainhash.Hash, powLimit *big.Int, flags BehaviorFlags) error {
 	// The target difficulty must be larger than zero.
 	target := CompactToBig(header.Bits)
 	if target.Sign() <= 0 {
@@ -325,8 +325,7 @@ func checkProofOfWork(header *wire.BlockHeader, powLimit *big.Int, flags Behavio
 	// to avoid proof of work checks is set.
 	if flags&BFNoPoWCheck != BFNoPoWCheck {
 		// The block hash must be less than the claimed target.
-		hash := header.BlockHash()
-		hashNum := HashToBig(&hash)
+		hashNum := HashToBig(blockHash)
 		if hashNum.Cmp(target) > 0 {
 			str := fmt.Sprintf("block hash of %064x is higher than "+
 				"expected max of %064x", hashNum, target)
@@ -341,7 +340,8 @@ func checkProofOfWork(header *wire.BlockHeader, powLimit *big.Int, flags Behavio
 // difficulty is in min/max range and that the block hash is less than the
 // target difficulty as claimed.
 func CheckProofOfWork(block *navutil.Block, powLimit *big.Int) error {
-	return checkProofOfWork(&block.MsgBlock().Header, powLimit, BFNone)
+	return checkProofOfWork(&block.MsgBlock().Header, block.Hash(),
+		powLimit, BFNone)
 }
 
 // CountSigOps returns the number of signature operations for all transaction
@@ -424,17 +424,17 @@ func CountP2SHSigOps(tx *navutil.Tx, isCoinBaseTx bool, utxoView *UtxoViewpoint)
 	return totalSigOps, nil
 }
 
-// checkBlockHeaderSanity performs some preliminary checks on a block header to
-// ensure it is sane before continuing with processing.  These checks are
-// context free.
+// checkBlockHeaderSanity performs some preliminary checks on a block header
+// with the passed hash to ensure it is sane before continuing with processing.
+// These checks are context free.
 //
 // The flags do not modify the behavior of this function directly, however they
 // are needed to pass along to checkProofOfWork.
-func checkBlockHeaderSanity(header *wire.BlockHeader, powLimit *big.Int, timeSource MedianTimeSource, flags BehaviorFlags) error {
+func checkBlockHeaderSanity(header *wire.BlockHeader, blockHash *chainhash.Hash, powLimit *big.Int, timeSource MedianTimeSource, flags BehaviorFlags) error {
 	// Ensure the proof of work bits in the block header is in min/max range
 	// and the block hash is less than the target value described by the
 	// bits.
-	err := checkProofOfWork(header, powLimit, flags)
+	err := checkProofOfWork(header, blockHash, powLimit, flags)
 	if err != nil {
 		return err
 	}
@@ -464,13 +464,17 @@ func checkBlockHeaderSanity(header *wire.BlockHeader, powLimit *big.Int, timeSou
 
 // checkBlockSanity performs some preliminary checks on a block to ensure it is
 // sane before continuing with block processing.  These checks are context free.
+// When the signet challenge is not empty, the block must also carry a signet
+// solution which satisfies it.
 //
-// The flags do not modify the behavior of this function directly, however they
-// are needed to pass along to checkBlockHeaderSanity.
-func checkBlockSanity(block *navutil.Block, powLimit *big.Int, timeSource MedianTimeSource, flags BehaviorFlags) error {
+// The solution takes the place of the proof of work on signet networks, so it
+// is not checked when the BFNoPoWCheck flag is set.  The flags are also passed
+// along to checkBlockHeaderSanity.
+func checkBlockSanity(block *navutil.Block, powLimit *big.Int, signetChallenge []byte, timeSource MedianTimeSource, flags BehaviorFlags) error {
 	msgBlock := block.MsgBlock()
 	header := &msgBlock.Header
-	err := checkBlockHeaderSanity(header, powLimit, timeSource, flags)
+	err := checkBlockHeaderSanity(header, block.Hash(), powLimit,
+		timeSource, flags)
 	if err != nil {
 		return err
 	}
@@ -539,6 +543,14 @@ func checkBlockSanity(block *navutil.Block, powLimit *big.Int, timeSource Median
 		return ruleError(ErrBadMerkleRoot, str)
 	}
 
+	// Blocks on signet networks must be signed by the holders of the keys
+	// required by the challenge.
+	if len(signetChallenge) != 0 && flags&BFNoPoWCheck != BFNoPoWCheck {
+		if err := checkSignetSolution(msgBlock, signetChallenge); err != nil {
+			return err
+		}
+	}
+
 	// Check for duplicate transactions.  This check will be fairly quick
 	// since the transaction hashes are already cached due to building the
 	// merkle tree above.
@@ -575,7 +587,7 @@ func checkBlockSanity(block *navutil.Block, powLimit *big.Int, timeSource Median
 // CheckBlockSanity performs some preliminary checks on a block to ensure it is
 // sane before continuing with block processing.  These checks are context free.
 func CheckBlockSanity(block *navutil.Block, powLimit *big.Int, timeSource MedianTimeSource) error {
-	return checkBlockSanity(block, powLimit, timeSource, BFNone)
+	return checkBlockSanity(block, powLimit, nil, timeSource, BFNone)
 }
 
 // ExtractCoinbaseHeight attempts to extract the height of the block from the
@@ -637,14 +649,14 @@ func checkSerializedHeight(coinbaseTx *navutil.Tx, wantHeight int32) error {
 }
 
 // checkBlockHeaderContext performs several validation checks on the block header
-// which depend on its position within the block chain.
+// with the passed hash which depend on its position within the block chain.
 //
 // The flags modify the behavior of this function as follows:
 //  - BFFastAdd: All checks except those involving comparing the header against
 //    the checkpoints are not performed.
 //
 // This function MUST be called with the chain state lock held (for writes).
-func (b *BlockChain) checkBlockHeaderContext(header *wire.BlockHeader, prevNode *blockNode, flags BehaviorFlags) error {
+func (b *BlockChain) checkBlockHeaderContext(header *wire.BlockHeader, blockHash *chainhash.Hash, prevNode *blockNode, flags BehaviorFlags) error {
 	fastAdd := flags&BFFastAdd == BFFastAdd
 	if !fastAdd {
 		// Ensure the difficulty specified in the block header matches
@@ -677,8 +689,7 @@ func (b *BlockChain) checkBlockHeaderContext(header *wire.BlockHeader, prevNode
 	blockHeight := prevNode.height + 1
 
 	// Ensure chain matches up to predetermined checkpoints.
-	blockHash := header.BlockHash()
-	if !b.verifyCheckpoint(blockHeight, &blockHash) {
+	if !b.verifyCheckpoint(blockHeight, blockHash) {
 		str := fmt.Sprintf("block at height %d does not match "+
 			"checkpoint hash", blockHeight)
 		return ruleError(ErrBadCheckpoint, str)
@@ -729,7 +740,7 @@ func (b *BlockChain) checkBlockHeaderContext(header *wire.BlockHeader, prevNode
 func (b *BlockChain) checkBlockContext(block *navutil.Block, prevNode *blockNode, flags BehaviorFlags) error {
 	// Perform all block header related validation checks.
 	header := &block.MsgBlock().Header
-	err := b.checkBlockHeaderContext(header, prevNode, flags)
+	err := b.checkBlockHeaderContext(header, block.Hash(), prevNode, flags)
 	if err != nil {
 		return err
 	}
@@ -1260,7 +1271,8 @@ func (b *BlockChain) CheckConnectBlockTemplate(block *navutil.Block) error {
 		return ruleError(ErrPrevBlockNotBest, str)
 	}
 
-	err := checkBlockSanity(block, b.chainParams.PowLimit, b.timeSource, flags)
+	err := checkBlockSanity(block, b.chainParams.PowLimit,
+		b.chainParams.SignetChallenge, b.timeSource, flags)
 	if err != nil {
 		return err
 	}
@@ -1274,8 +1286,37 @@ func (b *BlockChain) CheckConnectBlockTemplate(block *navutil.Block) error {
 	// is not needed and thus extra work can be avoided.
 	view := NewUtxoViewpoint()
 	view.SetBestHash(&tip.hash)
-	newNode := newBlockNode(&header, tip.height+1)
+	newNode := newBlockNode(&header, block.Hash(), tip.height+1)
 	newNode.parent = tip
 	newNode.workSum = newNode.workSum.Add(tip.workSum, newNode.workSum)
 	return b.checkConnectBlock(newNode, block, view, nil)
 }
+
+// CheckBlockHeader ensures the passed block header extends a known block which
+// is not known to be invalid and that it satisfies the proof of work and
+// difficulty rules at its position in the chain.  This allows cheap rejection
+// of blocks which are announced before their contents are available.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) CheckBlockHeader(header *wire.BlockHeader) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	prevHash := &header.PrevBlock
+	prevNode := b.index.LookupNode(prevHash)
+	if prevNode == nil {
+		str := fmt.Sprintf("previous block %s is unknown", prevHash)
+		return ruleError(ErrPreviousBlockUnknown, str)
+	} else if b.index.NodeStatus(prevNode).KnownInvalid() {
+		str := fmt.Sprintf("previous block %s is known to be invalid", prevHash)
+		return ruleError(ErrInvalidAncestorBlock, str)
+	}
+
+	blockHash := header.BlockHash()
+	err := checkBlockHeaderSanity(header, &blockHash, b.chainParams.PowLimit,
+		b.timeSource, BFNone)
+	if err != nil {
+		return err
+	}
+	return b.checkBlockHeaderContext(header, &blockHash, prevNode, BFNone)
+}
@@ -0,0 +1,264 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcec
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// EllswiftEncodingSize is the size of the ElligatorSwift encoding of a public
+// key.  It consists of two 32-byte big-endian field elements u and t.
+const EllswiftEncodingSize = 64
+
+// ellswiftXDHTag is the tag of the tagged hash used to derive the shared secret
+// of an ElligatorSwift x-only ECDH exchange (BIP0324).
+const ellswiftXDHTag = "bip324_ellswift_xonly_ecdh"
+
+var (
+	// errEllswiftEncode occurs when no ElligatorSwift encoding of a public
+	// key could be found, which only happens when the source of randomness
+	// is broken.
+	errEllswiftEncode = errors.New("unable to encode public key")
+
+	// fieldMinus3Sqrt is a square root of -3 in the field of the curve.  It
+	// is the specific root used by the ElligatorSwift mapping.
+	fieldMinus3Sqrt = fromHex("0a2d2ba93507f1df233770c2a797962cc61f6d15da14ecd47d8d27ae1cd5f852")
+)
+
+// fieldOps provides modular arithmetic in the field of the secp256k1 curve
+// on big integers.  Every operation returns a new reduced value.
+type fieldOps struct {
+	p *big.Int
+}
+
+func (f fieldOps) add(a, b *big.Int) *big.Int {
+	r := new(big.Int).Add(a, b)
+	return r.Mod(r, f.p)
+}
+
+func (f fieldOps) sub(a, b *big.Int) *big.Int {
+	r := new(big.Int).Sub(a, b)
+	return r.Mod(r, f.p)
+}
+
+func (f fieldOps) mul(a, b *big.Int) *big.Int {
+	r := new(big.Int).Mul(a, b)
+	return r.Mod(r, f.p)
+}
+
+func (f fieldOps) neg(a *big.Int) *big.Int {
+	r := new(big.Int).Neg(a)
+	return r.Mod(r, f.p)
+}
+
+// div returns a/b.  The result is zero when b is zero.
+func (f fieldOps) div(a, b *big.Int) *big.Int {
+	inv := new(big.Int).ModInverse(b, f.p)
+	if inv == nil {
+		return new(big.Int)
+	}
+	return f.mul(a, inv)
+}
+
+// sqrt returns a square root of a or nil when a is not a square.
+func (f fieldOps) sqrt(a *big.Int) *big.Int {
+	r := new(big.Int).Exp(a, S256().QPlus1Div4(), f.p)
+	if f.mul(r, r).Cmp(new(big.Int).Mod(a, f.p)) != 0 {
+		return nil
+	}
+	return r
+}
+
+// curveRHS returns x^3 + 7, the right hand side of the curve equation.
+func (f fieldOps) curveRHS(x *big.Int) *big.Int {
+	return f.add(f.mul(f.mul(x, x), x), S256().B)
+}
+
+// isValidX returns whether x is the x coordinate of a point on the curve.
+func (f fieldOps) isValidX(x *big.Int) bool {
+	return f.sqrt(f.curveRHS(x)) != nil
+}
+
+// newFieldOps returns field operations for the secp256k1 curve.
+func newFieldOps() fieldOps {
+	return fieldOps{p: S256().P}
+}
+
+// xSwiftEC maps the field elements u and t to the x coordinate of a point on
+// the curve as specified by BIP0324.
+func xSwiftEC(u, t *big.Int) *big.Int {
+	f := newFieldOps()
+	u = new(big.Int).Mod(u, f.p)
+	t = new(big.Int).Mod(t, f.p)
+	if u.Sign() == 0 {
+		u.SetInt64(1)
+	}
+	if t.Sign() == 0 {
+		t.SetInt64(1)
+	}
+	if f.add(f.curveRHS(u), f.mul(t, t)).Sign() == 0 {
+		t = f.add(t, t)
+	}
+
+	// X = (u^3 + 7 - t^2) / (2t)
+	// Y = (X + t) / (sqrt(-3) * u)
+	x := f.div(f.sub(f.curveRHS(u), f.mul(t, t)), f.add(t, t))
+	y := f.div(f.add(x, t), f.mul(fieldMinus3Sqrt, u))
+
+	// Return the first valid x coordinate of u + 4Y^2, (-X/Y - u) / 2 and
+	// (X/Y - u) / 2.  At least one of them is always valid.
+	two := big.NewInt(2)
+	x3 := f.add(u, f.mul(big.NewInt(4), f.mul(y, y)))
+	if f.isValidX(x3) {
+		return x3
+	}
+	xy := f.div(x, y)
+	x2 := f.div(f.sub(f.neg(xy), u), two)
+	if f.isValidX(x2) {
+		return x2
+	}
+	return f.div(f.sub(xy, u), two)
+}
+
+// xSwiftECInv returns a field element t such that xSwiftEC(u, t) is x, or nil
+// when no such element exists for the given case.  There are up to eight
+// solutions which are selected by the case in the range [0, 7].
+func xSwiftECInv(x, u *big.Int, c int) *big.Int {
+	f := newFieldOps()
+	var s, v *big.Int
+	if c&2 == 0 {
+		if f.isValidX(f.sub(f.neg(x), u)) {
+			return nil
+		}
+		v = x
+
+		// s = -(u^3 + 7) / (u^2 + uv + v^2)
+		d := f.add(f.add(f.mul(u, u), f.mul(u, v)), f.mul(v, v))
+		s = f.div(f.neg(f.curveRHS(u)), d)
+	} else {
+		s = f.sub(x, u)
+		if s.Sign() == 0 {
+			return nil
+		}
+
+		// r = sqrt(-s * (4 * (u^3 + 7) + 3 * s * u^2))
+		a := f.mul(big.NewInt(4), f.curveRHS(u))
+		b := f.mul(f.mul(big.NewInt(3), s), f.mul(u, u))
+		r := f.sqrt(f.mul(f.neg(s), f.add(a, b)))
+		if r == nil {
+			return nil
+		}
+		if c&1 != 0 && r.Sign() == 0 {
+			return nil
+		}
+		v = f.div(f.sub(f.div(r, s), u), big.NewInt(2))
+	}
+	w := f.sqrt(s)
+	if w == nil {
+		return nil
+	}
+
+	// t = +-w * (u * (1 -+ sqrt(-3)) / 2 + v)
+	one := big.NewInt(1)
+	var m *big.Int
+	if c&1 == 0 {
+		m = f.sub(one, fieldMinus3Sqrt)
+	} else {
+		m = f.add(one, fieldMinus3Sqrt)
+	}
+	t := f.mul(w, f.add(f.div(f.mul(u, m), big.NewInt(2)), v))
+	if c&5 == 0 || c&5 == 5 {
+		t = f.neg(t)
+	}
+	return t
+}
+
+// EllswiftEncode returns a random ElligatorSwift encoding of the provided
+// public key using the randomness read from rand.  Every public key has many
+// encodings which are indistinguishable from uniformly random bytes.
+func EllswiftEncode(pubKey *PublicKey, rand io.Reader) ([EllswiftEncodingSize]byte, error) {
+	var enc [EllswiftEncodingSize]byte
+	var buf [33]byte
+	p := S256().P
+	for i := 0; i < 1000; i++ {
+		if _, err := io.ReadFull(rand, buf[:]); err != nil {
+			return enc, err
+		}
+		u := new(big.Int).SetBytes(buf[:32])
+		u.Mod(u, p)
+		if u.Sign() == 0 {
+			continue
+		}
+		t := xSwiftECInv(pubKey.X, u, int(buf[32]&7))
+		if t == nil {
+			continue
+		}
+		copy(enc[32-len(u.Bytes()):32], u.Bytes())
+		copy(enc[64-len(t.Bytes()):], t.Bytes())
+		return enc, nil
+	}
+	return enc, errEllswiftEncode
+}
+
+// EllswiftDecode returns the x coordinate of the public key encoded by the
+// provided ElligatorSwift encoding.  Every 64-byte string is a valid encoding.
+func EllswiftDecode(enc [EllswiftEncodingSize]byte) *big.Int {
+	u := new(big.Int).SetBytes(enc[:32])
+	t := new(big.Int).SetBytes(enc[32:])
+	return xSwiftEC(u, t)
+}
+
+// EllswiftCreate generates a new private key along with a random
+// ElligatorSwift encoding of its public key.
+func EllswiftCreate() (*PrivateKey, [EllswiftEncodingSize]byte, error) {
+	privKey, err := NewPrivateKey(S256())
+	if err != nil {
+		return nil, [EllswiftEncodingSize]byte{}, err
+	}
+	enc, err := EllswiftEncode(privKey.PubKey(), rand.Reader)
+	if err != nil {
+		return nil, enc, err
+	}
+	return privKey, enc, nil
+}
+
+// EllswiftXDH computes the shared secret of an x-only ECDH exchange between
+// the provided private key and the ElligatorSwift encoded public key of the
+// other party as specified by BIP0324.  Both encodings are committed to in the
+// secret, ordered by the initiator of the exchange first.
+func EllswiftXDH(privKey *PrivateKey, ourEnc, theirEnc [EllswiftEncodingSize]byte,
+	initiator bool) [32]byte {
+
+	// Any of the two points with the decoded x coordinate results in the
+	// same x coordinate of the shared point.
+	f := newFieldOps()
+	x := EllswiftDecode(theirEnc)
+	y := f.sqrt(f.curveRHS(x))
+	sx, _ := S256().ScalarMult(x, y, privKey.D.Bytes())
+
+	var xBytes [32]byte
+	copy(xBytes[32-len(sx.Bytes()):], sx.Bytes())
+
+	tag := sha256.Sum256([]byte(ellswiftXDHTag))
+	h := sha256.New()
+	h.Write(tag[:])
+	h.Write(tag[:])
+	if initiator {
+		h.Write(ourEnc[:])
+		h.Write(theirEnc[:])
+	} else {
+		h.Write(theirEnc[:])
+		h.Write(ourEnc[:])
+	}
+	h.Write(xBytes[:])
+
+	var secret [32]byte
+	copy(secret[:], h.Sum(nil))
+	return secret
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcec
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// TestEllswiftDecode ensures ElligatorSwift encodings are decoded to the
+// expected x coordinates.
+func TestEllswiftDecode(t *testing.T) {
+	tests := []struct {
+		enc string
+		x   string
+	}{
+		{
+			enc: "0000000000000000000000000000000000000000000000000000000000000000" +
+				"0000000000000000000000000000000000000000000000000000000000000000",
+			x: "edd1fd3e327ce90cc7a3542614289aee9682003e9cf7dcc9cf2ca9743be5aa0c",
+		},
+		{
+			// Field elements are reduced modulo the field prime.
+			enc: "fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f" +
+				"0000000000000000000000000000000000000000000000000000000000000000",
+			x: "edd1fd3e327ce90cc7a3542614289aee9682003e9cf7dcc9cf2ca9743be5aa0c",
+		},
+		{
+			enc: "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb" +
+				"f55ff16f66f43360266b95db6f8fec01d76031054306ae4a4b380598f6cfd114",
+			x: "6ddc81dad822d31209b1175fe02144de0cc20fbf328d617f1b7ecc80f855fb83",
+		},
+		{
+			enc: "3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d" +
+				"7dc96f776c8423e57a2785489a3f9c43fb6e756876d6ad9a9cac4aa4e72ec193",
+			x: "a5293ed748afd903ffe95a819a75e6fb4095ecf5546c122650af8fcbeb21547a",
+		},
+		{
+			enc: "2e7d2c03a9507ae265ecf5b5356885a53393a2029d241394997265a1a25aefc6" +
+				"d0f631ca1ddba8db3bcfcb9e057cdc98d0379f1bee00e75a545147a27dadd982",
+			x: "b89a091d190b8a2c5883ab81587cd1ee3d0c6459d9c5cd22fef95c552a3703a9",
+		},
+	}
+
+	for i, test := range tests {
+		var enc [EllswiftEncodingSize]byte
+		b, _ := hex.DecodeString(test.enc)
+		copy(enc[:], b)
+		x := EllswiftDecode(enc)
+		if got := fmt.Sprintf("%064x", x); got != test.x {
+			t.Errorf("EllswiftDecode #%d: got %s want %s", i, got,
+				test.x)
+		}
+	}
+}
+
+// TestEllswiftEncode ensures random ElligatorSwift encodings of public keys
+// decode back to the public keys.
+func TestEllswiftEncode(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		privKey, enc, err := EllswiftCreate()
+		if err != nil {
+			t.Fatalf("EllswiftCreate #%d: unexpected error: %v", i, err)
+		}
+		x := EllswiftDecode(enc)
+		if x.Cmp(privKey.PubKey().X) != 0 {
+			t.Errorf("EllswiftCreate #%d: decoded x %064x, want %064x",
+				i, x, privKey.PubKey().X)
+		}
+
+		// Encoding the same key again must result in another
+		// encoding.
+		enc2, err := EllswiftEncode(privKey.PubKey(), rand.Reader)
+		if err != nil {
+			t.Fatalf("EllswiftEncode #%d: unexpected error: %v", i, err)
+		}
+		if bytes.Equal(enc[:], enc2[:]) {
+			t.Errorf("EllswiftEncode #%d: same encoding twice", i)
+		}
+		if EllswiftDecode(enc2).Cmp(privKey.PubKey().X) != 0 {
+			t.Errorf("EllswiftEncode #%d: wrong decoded x", i)
+		}
+	}
+}
+
+// TestEllswiftXDH ensures both parties of an ElligatorSwift ECDH exchange
+// derive the same shared secret.
+func TestEllswiftXDH(t *testing.T) {
+	privA, encA, err := EllswiftCreate()
+	if err != nil {
+		t.Fatalf("EllswiftCreate: unexpected error: %v", err)
+	}
+	privB, encB, err := EllswiftCreate()
+	if err != nil {
+		t.Fatalf("EllswiftCreate: unexpected error: %v", err)
+	}
+
+	secretA := EllswiftXDH(privA, encA, encB, true)
+	secretB := EllswiftXDH(privB, encB, encA, false)
+	if secretA != secretB {
+		t.Fatalf("EllswiftXDH: mismatched secrets %x and %x", secretA,
+			secretB)
+	}
+
+	// The secret commits to the role of the parties.
+	if EllswiftXDH(privB, encB, encA, true) == secretB {
+		t.Fatal("EllswiftXDH: secret does not commit to the roles")
+	}
+}
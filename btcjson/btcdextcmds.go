@@ -72,6 +72,39 @@ func NewGenerateCmd(numBlocks uint32) *GenerateCmd {
 	}
 }
 
+// GenerateToAddressCmd defines the generatetoaddress JSON-RPC command.
+type GenerateToAddressCmd struct {
+	NumBlocks uint32
+	Address   string
+}
+
+// NewGenerateToAddressCmd returns a new instance which can be used to issue a
+// generatetoaddress JSON-RPC command.
+func NewGenerateToAddressCmd(numBlocks uint32, address string) *GenerateToAddressCmd {
+	return &GenerateToAddressCmd{
+		NumBlocks: numBlocks,
+		Address:   address,
+	}
+}
+
+// GenerateBlockCmd defines the generateblock JSON-RPC command.
+type GenerateBlockCmd struct {
+	Address      string
+	Transactions *[]string
+}
+
+// NewGenerateBlockCmd returns a new instance which can be used to issue a
+// generateblock JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGenerateBlockCmd(address string, transactions *[]string) *GenerateBlockCmd {
+	return &GenerateBlockCmd{
+		Address:      address,
+		Transactions: transactions,
+	}
+}
+
 // GetBestBlockCmd defines the getbestblock JSON-RPC command.
 type GetBestBlockCmd struct{}
 
@@ -90,6 +123,15 @@ func NewGetCurrentNetCmd() *GetCurrentNetCmd {
 	return &GetCurrentNetCmd{}
 }
 
+// GetMiningStatsCmd defines the getminingstats JSON-RPC command.
+type GetMiningStatsCmd struct{}
+
+// NewGetMiningStatsCmd returns a new instance which can be used to issue a
+// getminingstats JSON-RPC command.
+func NewGetMiningStatsCmd() *GetMiningStatsCmd {
+	return &GetMiningStatsCmd{}
+}
+
 // GetHeadersCmd defines the getheaders JSON-RPC command.
 //
 // NOTE: This is a btcsuite extension ported from
@@ -111,6 +153,58 @@ func NewGetHeadersCmd(blockLocators []string, hashStop string) *GetHeadersCmd {
 	}
 }
 
+// PeerPermission defines the type used in the setpeerpermission JSON-RPC
+// command for the permission field.
+type PeerPermission string
+
+const (
+	// PPWhitelist indicates the peer is never penalized for misbehaving.
+	PPWhitelist PeerPermission = "whitelist"
+
+	// PPNoBan indicates the peer is not banned once its ban score exceeds
+	// the ban threshold.
+	PPNoBan PeerPermission = "noban"
+)
+
+// SetPeerPermissionCmd defines the setpeerpermission JSON-RPC command.  This
+// command is not a standard NavCoin command.  It is an extension for navd.
+type SetPeerPermissionCmd struct {
+	NodeID     int32
+	Permission PeerPermission `jsonrpcusage:"\"whitelist|noban\""`
+	Enable     *bool          `jsonrpcdefault:"true"`
+}
+
+// NewSetPeerPermissionCmd returns a new instance which can be used to issue a
+// setpeerpermission JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewSetPeerPermissionCmd(nodeID int32, permission PeerPermission, enable *bool) *SetPeerPermissionCmd {
+	return &SetPeerPermissionCmd{
+		NodeID:     nodeID,
+		Permission: permission,
+		Enable:     enable,
+	}
+}
+
+// AddPeerBanScoreCmd defines the addpeerbanscore JSON-RPC command.  This
+// command is not a standard NavCoin command.  It is an extension for navd.
+type AddPeerBanScoreCmd struct {
+	NodeID int32
+	Score  uint32
+	Reason string
+}
+
+// NewAddPeerBanScoreCmd returns a new instance which can be used to issue an
+// addpeerbanscore JSON-RPC command.
+func NewAddPeerBanScoreCmd(nodeID int32, score uint32, reason string) *AddPeerBanScoreCmd {
+	return &AddPeerBanScoreCmd{
+		NodeID: nodeID,
+		Score:  score,
+		Reason: reason,
+	}
+}
+
 // VersionCmd defines the version JSON-RPC command.
 //
 // NOTE: This is a btcsuite extension ported from
@@ -131,8 +225,13 @@ func init() {
 	MustRegisterCmd("debuglevel", (*DebugLevelCmd)(nil), flags)
 	MustRegisterCmd("node", (*NodeCmd)(nil), flags)
 	MustRegisterCmd("generate", (*GenerateCmd)(nil), flags)
+	MustRegisterCmd("generateblock", (*GenerateBlockCmd)(nil), flags)
+	MustRegisterCmd("generatetoaddress", (*GenerateToAddressCmd)(nil), flags)
 	MustRegisterCmd("getbestblock", (*GetBestBlockCmd)(nil), flags)
 	MustRegisterCmd("getcurrentnet", (*GetCurrentNetCmd)(nil), flags)
 	MustRegisterCmd("getheaders", (*GetHeadersCmd)(nil), flags)
+	MustRegisterCmd("getminingstats", (*GetMiningStatsCmd)(nil), flags)
+	MustRegisterCmd("setpeerpermission", (*SetPeerPermissionCmd)(nil), flags)
+	MustRegisterCmd("addpeerbanscore", (*AddPeerBanScoreCmd)(nil), flags)
 	MustRegisterCmd("version", (*VersionCmd)(nil), flags)
 }
@@ -114,6 +114,49 @@ func TestBtcdExtCmds(t *testing.T) {
 				NumBlocks: 1,
 			},
 		},
+		{
+			name: "generatetoaddress",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generatetoaddress", 1, "1Address")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGenerateToAddressCmd(1, "1Address")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generatetoaddress","params":[1,"1Address"],"id":1}`,
+			unmarshalled: &btcjson.GenerateToAddressCmd{
+				NumBlocks: 1,
+				Address:   "1Address",
+			},
+		},
+		{
+			name: "generateblock",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generateblock", "1Address")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGenerateBlockCmd("1Address", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generateblock","params":["1Address"],"id":1}`,
+			unmarshalled: &btcjson.GenerateBlockCmd{
+				Address:      "1Address",
+				Transactions: nil,
+			},
+		},
+		{
+			name: "generateblock optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generateblock", "1Address", []string{"123", "456"})
+			},
+			staticCmd: func() interface{} {
+				txns := []string{"123", "456"}
+				return btcjson.NewGenerateBlockCmd("1Address", &txns)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generateblock","params":["1Address",["123","456"]],"id":1}`,
+			unmarshalled: &btcjson.GenerateBlockCmd{
+				Address:      "1Address",
+				Transactions: &[]string{"123", "456"},
+			},
+		},
 		{
 			name: "getbestblock",
 			newCmd: func() (interface{}, error) {
@@ -136,6 +179,63 @@ func TestBtcdExtCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getcurrentnet","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetCurrentNetCmd{},
 		},
+		{
+			name: "getminingstats",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getminingstats")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetMiningStatsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getminingstats","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetMiningStatsCmd{},
+		},
+		{
+			name: "setpeerpermission",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setpeerpermission", 3, "noban")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetPeerPermissionCmd(3, btcjson.PPNoBan, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setpeerpermission","params":[3,"noban"],"id":1}`,
+			unmarshalled: &btcjson.SetPeerPermissionCmd{
+				NodeID:     3,
+				Permission: btcjson.PPNoBan,
+				Enable:     btcjson.Bool(true),
+			},
+		},
+		{
+			name: "setpeerpermission optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setpeerpermission", 3, "whitelist", false)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetPeerPermissionCmd(3, btcjson.PPWhitelist,
+					btcjson.Bool(false))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setpeerpermission","params":[3,"whitelist",false],"id":1}`,
+			unmarshalled: &btcjson.SetPeerPermissionCmd{
+				NodeID:     3,
+				Permission: btcjson.PPWhitelist,
+				Enable:     btcjson.Bool(false),
+			},
+		},
+		{
+			name: "addpeerbanscore",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("addpeerbanscore", 3, 50, "spam")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAddPeerBanScoreCmd(3, 50, "spam")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"addpeerbanscore","params":[3,50,"spam"],"id":1}`,
+			unmarshalled: &btcjson.AddPeerBanScoreCmd{
+				NodeID: 3,
+				Score:  50,
+				Reason: "spam",
+			},
+		},
 		{
 			name: "getheaders",
 			newCmd: func() (interface{}, error) {
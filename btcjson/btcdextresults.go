@@ -5,6 +5,38 @@
 
 package btcjson
 
+// GenerateBlockResult models the data from the generateblock command.
+type GenerateBlockResult struct {
+	Hash string `json:"hash"`
+}
+
+// GeneratedBlockResult models the details about a generated block returned by
+// the getminingstats command.
+type GeneratedBlockResult struct {
+	Hash          string  `json:"hash"`
+	Height        int32   `json:"height"`
+	Time          int64   `json:"time"`
+	BuildTime     float64 `json:"buildtime"`
+	Fees          float64 `json:"fees"`
+	TxCount       int     `json:"txcount"`
+	Status        string  `json:"status"`
+	Confirmations int64   `json:"confirmations"`
+}
+
+// GetMiningStatsResult models the data from the getminingstats command.
+type GetMiningStatsResult struct {
+	TemplateBuilds       uint64                 `json:"templatebuilds"`
+	TemplatesReused      uint64                 `json:"templatesreused"`
+	FullRebuilds         uint64                 `json:"fullrebuilds"`
+	TemplateBuildTime    float64                `json:"templatebuildtime"`
+	AvgTemplateBuildTime float64                `json:"avgtemplatebuildtime"`
+	Accepted             int                    `json:"accepted"`
+	Orphaned             int                    `json:"orphaned"`
+	Stale                int                    `json:"stale"`
+	Rejected             int                    `json:"rejected"`
+	Blocks               []GeneratedBlockResult `json:"blocks"`
+}
+
 // VersionResult models objects included in the version response.  In the actual
 // result, these objects are keyed by the program or API name.
 //
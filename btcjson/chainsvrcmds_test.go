@@ -81,6 +81,17 @@ func TestChainSvrCmds(t *testing.T) {
 			},
 		},
 
+		{
+			name: "clearbanned",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("clearbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewClearBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"clearbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ClearBannedCmd{},
+		},
 		{
 			name: "decoderawtransaction",
 			newCmd: func() (interface{}, error) {
@@ -103,6 +114,61 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"decodescript","params":["00"],"id":1}`,
 			unmarshalled: &btcjson.DecodeScriptCmd{HexScript: "00"},
 		},
+		{
+			name: "disconnectnode",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("disconnectnode", "127.0.0.1:8333")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDisconnectNodeCmd(btcjson.String("127.0.0.1:8333"), nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"disconnectnode","params":["127.0.0.1:8333"],"id":1}`,
+			unmarshalled: &btcjson.DisconnectNodeCmd{
+				Address: btcjson.String("127.0.0.1:8333"),
+			},
+		},
+		{
+			name: "disconnectnode nodeid",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("disconnectnode", "", 7)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDisconnectNodeCmd(btcjson.String(""), btcjson.Int32(7))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"disconnectnode","params":["",7],"id":1}`,
+			unmarshalled: &btcjson.DisconnectNodeCmd{
+				Address: btcjson.String(""),
+				NodeID:  btcjson.Int32(7),
+			},
+		},
+		{
+			name: "estimatesmartfee",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("estimatesmartfee", 6)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewEstimateSmartFeeCmd(6, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"estimatesmartfee","params":[6],"id":1}`,
+			unmarshalled: &btcjson.EstimateSmartFeeCmd{
+				ConfTarget:   6,
+				EstimateMode: &btcjson.EstimateModeConservative,
+			},
+		},
+		{
+			name: "estimatesmartfee optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("estimatesmartfee", 6, btcjson.EstimateModeEconomical)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewEstimateSmartFeeCmd(6, &btcjson.EstimateModeEconomical)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"estimatesmartfee","params":[6,"ECONOMICAL"],"id":1}`,
+			unmarshalled: &btcjson.EstimateSmartFeeCmd{
+				ConfTarget:   6,
+				EstimateMode: &btcjson.EstimateModeEconomical,
+			},
+		},
 		{
 			name: "getaddednodeinfo",
 			newCmd: func() (interface{}, error) {
@@ -716,6 +782,17 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "listbanned",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("listbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"listbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ListBannedCmd{},
+		},
 		{
 			name: "ping",
 			newCmd: func() (interface{}, error) {
@@ -740,6 +817,21 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "0123",
 			},
 		},
+		{
+			name: "prioritisetransaction",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("prioritisetransaction", "123", 0.0, 10000)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewPrioritiseTransactionCmd("123", 0, 10000)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"prioritisetransaction","params":["123",0,10000],"id":1}`,
+			unmarshalled: &btcjson.PrioritiseTransactionCmd{
+				Txid:          "123",
+				PriorityDelta: 0,
+				FeeDelta:      10000,
+			},
+		},
 		{
 			name: "reconsiderblock",
 			newCmd: func() (interface{}, error) {
@@ -920,13 +1012,46 @@ func TestChainSvrCmds(t *testing.T) {
 				AllowHighFees: btcjson.Bool(false),
 			},
 		},
+		{
+			name: "setban",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setban", "10.0.0.0/8", "add")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("10.0.0.0/8", btcjson.SBAdd, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["10.0.0.0/8","add"],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				Subnet:   "10.0.0.0/8",
+				SubCmd:   btcjson.SBAdd,
+				BanTime:  btcjson.Int64(0),
+				Absolute: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "setban optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setban", "10.0.0.1", "add", 1600000000, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("10.0.0.1", btcjson.SBAdd,
+					btcjson.Int64(1600000000), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["10.0.0.1","add",1600000000,true],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				Subnet:   "10.0.0.1",
+				SubCmd:   btcjson.SBAdd,
+				BanTime:  btcjson.Int64(1600000000),
+				Absolute: btcjson.Bool(true),
+			},
+		},
 		{
 			name: "setgenerate",
 			newCmd: func() (interface{}, error) {
 				return btcjson.NewCmd("setgenerate", true)
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewSetGenerateCmd(true, nil)
+				return btcjson.NewSetGenerateCmd(true, nil, nil, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"setgenerate","params":[true],"id":1}`,
 			unmarshalled: &btcjson.SetGenerateCmd{
@@ -940,7 +1065,7 @@ func TestChainSvrCmds(t *testing.T) {
 				return btcjson.NewCmd("setgenerate", true, 6)
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewSetGenerateCmd(true, btcjson.Int(6))
+				return btcjson.NewSetGenerateCmd(true, btcjson.Int(6), nil, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"setgenerate","params":[true,6],"id":1}`,
 			unmarshalled: &btcjson.SetGenerateCmd{
@@ -948,6 +1073,25 @@ func TestChainSvrCmds(t *testing.T) {
 				GenProcLimit: btcjson.Int(6),
 			},
 		},
+		{
+			name: "setgenerate payouts",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setgenerate", true, -1,
+					`{"addr1":3,"addr2":1}`, "pool")
+			},
+			staticCmd: func() interface{} {
+				payouts := map[string]uint32{"addr1": 3, "addr2": 1}
+				return btcjson.NewSetGenerateCmd(true, btcjson.Int(-1),
+					&payouts, btcjson.String("pool"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setgenerate","params":[true,-1,{"addr1":3,"addr2":1},"pool"],"id":1}`,
+			unmarshalled: &btcjson.SetGenerateCmd{
+				Generate:     true,
+				GenProcLimit: btcjson.Int(-1),
+				Payouts:      &map[string]uint32{"addr1": 3, "addr2": 1},
+				CoinbaseTag:  btcjson.String("pool"),
+			},
+		},
 		{
 			name: "stop",
 			newCmd: func() (interface{}, error) {
@@ -992,6 +1136,35 @@ func TestChainSvrCmds(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "testmempoolaccept",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("testmempoolaccept", []string{"1122", "3344"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewTestMempoolAcceptCmd([]string{"1122", "3344"}, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"testmempoolaccept","params":[["1122","3344"]],"id":1}`,
+			unmarshalled: &btcjson.TestMempoolAcceptCmd{
+				RawTxns:    []string{"1122", "3344"},
+				MaxFeeRate: btcjson.Float64(0.1),
+			},
+		},
+		{
+			name: "testmempoolaccept optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("testmempoolaccept", []string{"1122"}, 0.5)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewTestMempoolAcceptCmd([]string{"1122"},
+					btcjson.Float64(0.5))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"testmempoolaccept","params":[["1122"],0.5],"id":1}`,
+			unmarshalled: &btcjson.TestMempoolAcceptCmd{
+				RawTxns:    []string{"1122"},
+				MaxFeeRate: btcjson.Float64(0.5),
+			},
+		},
 		{
 			name: "uptime",
 			newCmd: func() (interface{}, error) {
@@ -63,6 +63,14 @@ type DecodeScriptResult struct {
 	P2sh      string   `json:"p2sh,omitempty"`
 }
 
+// EstimateSmartFeeResult models the data returned from the estimatesmartfee
+// command.
+type EstimateSmartFeeResult struct {
+	FeeRate *float64 `json:"feerate,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+	Blocks  int64    `json:"blocks"`
+}
+
 // GetAddedNodeInfoResultAddr models the data of the addresses portion of the
 // getaddednodeinfo command.
 type GetAddedNodeInfoResultAddr struct {
@@ -236,27 +244,29 @@ type GetNetworkInfoResult struct {
 
 // GetPeerInfoResult models the data returned from the getpeerinfo command.
 type GetPeerInfoResult struct {
-	ID             int32   `json:"id"`
-	Addr           string  `json:"addr"`
-	AddrLocal      string  `json:"addrlocal,omitempty"`
-	Services       string  `json:"services"`
-	RelayTxes      bool    `json:"relaytxes"`
-	LastSend       int64   `json:"lastsend"`
-	LastRecv       int64   `json:"lastrecv"`
-	BytesSent      uint64  `json:"bytessent"`
-	BytesRecv      uint64  `json:"bytesrecv"`
-	ConnTime       int64   `json:"conntime"`
-	TimeOffset     int64   `json:"timeoffset"`
-	PingTime       float64 `json:"pingtime"`
-	PingWait       float64 `json:"pingwait,omitempty"`
-	Version        uint32  `json:"version"`
-	SubVer         string  `json:"subver"`
-	Inbound        bool    `json:"inbound"`
-	StartingHeight int32   `json:"startingheight"`
-	CurrentHeight  int32   `json:"currentheight,omitempty"`
-	BanScore       int32   `json:"banscore"`
-	FeeFilter      int64   `json:"feefilter"`
-	SyncNode       bool    `json:"syncnode"`
+	ID                int32   `json:"id"`
+	Addr              string  `json:"addr"`
+	AddrLocal         string  `json:"addrlocal,omitempty"`
+	Services          string  `json:"services"`
+	RelayTxes         bool    `json:"relaytxes"`
+	LastSend          int64   `json:"lastsend"`
+	LastRecv          int64   `json:"lastrecv"`
+	BytesSent         uint64  `json:"bytessent"`
+	BytesRecv         uint64  `json:"bytesrecv"`
+	ConnTime          int64   `json:"conntime"`
+	TimeOffset        int64   `json:"timeoffset"`
+	PingTime          float64 `json:"pingtime"`
+	PingWait          float64 `json:"pingwait,omitempty"`
+	Version           uint32  `json:"version"`
+	SubVer            string  `json:"subver"`
+	Inbound           bool    `json:"inbound"`
+	StartingHeight    int32   `json:"startingheight"`
+	CurrentHeight     int32   `json:"currentheight,omitempty"`
+	BanScore          int32   `json:"banscore"`
+	FeeFilter         int64   `json:"feefilter"`
+	SyncNode          bool    `json:"syncnode"`
+	TransportProtocol string  `json:"transportprotocol"`
+	MappedAS          uint32  `json:"mappedas,omitempty"`
 }
 
 // GetRawMempoolVerboseResult models the data returned from the getrawmempool
@@ -266,6 +276,7 @@ type GetRawMempoolVerboseResult struct {
 	Size             int32    `json:"size"`
 	Vsize            int32    `json:"vsize"`
 	Fee              float64  `json:"fee"`
+	ModifiedFee      float64  `json:"modifiedfee"`
 	Time             int64    `json:"time"`
 	Height           int64    `json:"height"`
 	StartingPriority float64  `json:"startingpriority"`
@@ -463,18 +474,20 @@ type Vout struct {
 
 // GetMiningInfoResult models the data from the getmininginfo command.
 type GetMiningInfoResult struct {
-	Blocks             int64   `json:"blocks"`
-	CurrentBlockSize   uint64  `json:"currentblocksize"`
-	CurrentBlockWeight uint64  `json:"currentblockweight"`
-	CurrentBlockTx     uint64  `json:"currentblocktx"`
-	Difficulty         float64 `json:"difficulty"`
-	Errors             string  `json:"errors"`
-	Generate           bool    `json:"generate"`
-	GenProcLimit       int32   `json:"genproclimit"`
-	HashesPerSec       int64   `json:"hashespersec"`
-	NetworkHashPS      int64   `json:"networkhashps"`
-	PooledTx           uint64  `json:"pooledtx"`
-	TestNet            bool    `json:"testnet"`
+	Blocks               int64   `json:"blocks"`
+	CurrentBlockSize     uint64  `json:"currentblocksize"`
+	CurrentBlockWeight   uint64  `json:"currentblockweight"`
+	CurrentBlockTx       uint64  `json:"currentblocktx"`
+	Difficulty           float64 `json:"difficulty"`
+	Errors               string  `json:"errors"`
+	Generate             bool    `json:"generate"`
+	GenProcLimit         int32   `json:"genproclimit"`
+	HashesPerSec         int64   `json:"hashespersec"`
+	NetworkHashPS        int64   `json:"networkhashps"`
+	PooledTx             uint64  `json:"pooledtx"`
+	TemplateBuildTime    float64 `json:"templatebuildtime"`
+	AvgTemplateBuildTime float64 `json:"avgtemplatebuildtime"`
+	TestNet              bool    `json:"testnet"`
 }
 
 // GetWorkResult models the data from the getwork command.
@@ -499,6 +512,14 @@ type InfoChainResult struct {
 	Errors          string  `json:"errors"`
 }
 
+// ListBannedResult models the data returned for each ban by the listbanned
+// command.
+type ListBannedResult struct {
+	Address     string `json:"address"`
+	BanCreated  int64  `json:"ban_created"`
+	BannedUntil int64  `json:"banned_until"`
+}
+
 // TxRawResult models the data from the getrawtransaction command.
 type TxRawResult struct {
 	Hex           string `json:"hex"`
@@ -534,6 +555,22 @@ type SearchRawTransactionsResult struct {
 	Blocktime     int64        `json:"blocktime,omitempty"`
 }
 
+// TestMempoolAcceptFees models the fees portion of the testmempoolaccept
+// command result.
+type TestMempoolAcceptFees struct {
+	Base float64 `json:"base"`
+}
+
+// TestMempoolAcceptResult models the data returned for each transaction by the
+// testmempoolaccept command.
+type TestMempoolAcceptResult struct {
+	Txid         string                 `json:"txid"`
+	Allowed      bool                   `json:"allowed"`
+	Vsize        int64                  `json:"vsize,omitempty"`
+	Fees         *TestMempoolAcceptFees `json:"fees,omitempty"`
+	RejectReason string                 `json:"reject-reason,omitempty"`
+}
+
 // TxRawDecodeResult models the data from the decoderawtransaction command.
 type TxRawDecodeResult struct {
 	Txid     string `json:"txid"`
@@ -75,6 +75,16 @@ const (
 	// from the chain server that inform a client that a transaction that
 	// matches the loaded filter was accepted by the mempool.
 	RelevantTxAcceptedNtfnMethod = "relevanttxaccepted"
+
+	// TxExpiredNtfnMethod is the method used for notifications from the
+	// chain server that a transaction has been evicted from the mempool
+	// because it remained unconfirmed for longer than the expiry.
+	TxExpiredNtfnMethod = "txexpired"
+
+	// MinedBlockOrphanedNtfnMethod is the method used for notifications
+	// from the chain server that a block it generated has been
+	// disconnected from the main chain by a reorganization.
+	MinedBlockOrphanedNtfnMethod = "minedblockorphaned"
 )
 
 // BlockConnectedNtfn defines the blockconnected JSON-RPC notification.
@@ -285,6 +295,34 @@ func NewRelevantTxAcceptedNtfn(txHex string) *RelevantTxAcceptedNtfn {
 	return &RelevantTxAcceptedNtfn{Transaction: txHex}
 }
 
+// TxExpiredNtfn defines the txexpired JSON-RPC notification.
+type TxExpiredNtfn struct {
+	TxID string
+}
+
+// NewTxExpiredNtfn returns a new instance which can be used to issue a
+// txexpired JSON-RPC notification.
+func NewTxExpiredNtfn(txHash string) *TxExpiredNtfn {
+	return &TxExpiredNtfn{
+		TxID: txHash,
+	}
+}
+
+// MinedBlockOrphanedNtfn defines the minedblockorphaned JSON-RPC notification.
+type MinedBlockOrphanedNtfn struct {
+	Hash   string
+	Height int32
+}
+
+// NewMinedBlockOrphanedNtfn returns a new instance which can be used to issue
+// a minedblockorphaned JSON-RPC notification.
+func NewMinedBlockOrphanedNtfn(hash string, height int32) *MinedBlockOrphanedNtfn {
+	return &MinedBlockOrphanedNtfn{
+		Hash:   hash,
+		Height: height,
+	}
+}
+
 func init() {
 	// The commands in this file are only usable by websockets and are
 	// notifications.
@@ -301,4 +339,6 @@ func init() {
 	MustRegisterCmd(TxAcceptedNtfnMethod, (*TxAcceptedNtfn)(nil), flags)
 	MustRegisterCmd(TxAcceptedVerboseNtfnMethod, (*TxAcceptedVerboseNtfn)(nil), flags)
 	MustRegisterCmd(RelevantTxAcceptedNtfnMethod, (*RelevantTxAcceptedNtfn)(nil), flags)
+	MustRegisterCmd(TxExpiredNtfnMethod, (*TxExpiredNtfn)(nil), flags)
+	MustRegisterCmd(MinedBlockOrphanedNtfnMethod, (*MinedBlockOrphanedNtfn)(nil), flags)
 }
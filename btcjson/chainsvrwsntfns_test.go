@@ -225,6 +225,33 @@ func TestChainSvrWsNtfns(t *testing.T) {
 				Transaction: "001122",
 			},
 		},
+		{
+			name: "txexpired",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("txexpired", "123")
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewTxExpiredNtfn("123")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"txexpired","params":["123"],"id":null}`,
+			unmarshalled: &btcjson.TxExpiredNtfn{
+				TxID: "123",
+			},
+		},
+		{
+			name: "minedblockorphaned",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("minedblockorphaned", "123", 100000)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewMinedBlockOrphanedNtfn("123", 100000)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"minedblockorphaned","params":["123",100000],"id":null}`,
+			unmarshalled: &btcjson.MinedBlockOrphanedNtfn{
+				Hash:   "123",
+				Height: 100000,
+			},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -46,6 +46,8 @@ const (
 	ErrRPCClientNotConnected      RPCErrorCode = -9
 	ErrRPCClientInInitialDownload RPCErrorCode = -10
 	ErrRPCClientNodeNotAdded      RPCErrorCode = -24
+	ErrRPCClientNodeNotConnected  RPCErrorCode = -29
+	ErrRPCClientInvalidIPOrSubnet RPCErrorCode = -30
 )
 
 // Wallet JSON errors
@@ -0,0 +1,82 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chainhash
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"hash"
+)
+
+const (
+	// HeaderSize is the size of a serialized block header.
+	HeaderSize = 80
+
+	// headerNonceOffset is the offset of the nonce in a serialized block
+	// header.
+	headerNonceOffset = HeaderSize - 4
+)
+
+// PrehashedHeader houses a serialized block header along with the hashing
+// state which does not depend on its nonce.  It allows headers which only
+// differ by their nonce, such as the ones tried while mining, to be hashed by
+// only rewriting the nonce instead of serializing the whole header again.
+//
+// Double sha256 headers reuse the midstate after the first 64 bytes, which do
+// not include the nonce.  X13 starts with a BLAKE-512 round which processes
+// the whole header in a single 128 byte block, so no X13 state can be shared
+// between nonces and only the serialization is saved.
+//
+// A PrehashedHeader is NOT safe for concurrent access.  Each goroutine hashing
+// headers must use its own.
+type PrehashedHeader struct {
+	header   [HeaderSize]byte
+	x13      bool
+	midstate []byte
+	digest   hash.Hash
+	first    [sha256.Size]byte
+}
+
+// NewPrehashedHeader returns a prehashed header for the passed serialized block
+// header.  The header is hashed with X13 when x13 is true and double sha256
+// otherwise.
+func NewPrehashedHeader(header []byte, x13 bool) (*PrehashedHeader, error) {
+	if len(header) != HeaderSize {
+		return nil, fmt.Errorf("serialized block header is %d bytes "+
+			"instead of %d", len(header), HeaderSize)
+	}
+
+	p := PrehashedHeader{x13: x13}
+	copy(p.header[:], header)
+	if !x13 {
+		p.digest = sha256.New()
+		p.digest.Write(p.header[:sha256.BlockSize])
+		midstate, err := p.digest.(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		p.midstate = midstate
+	}
+	return &p, nil
+}
+
+// Hash returns the hash of the block header with its nonce replaced by the
+// passed nonce.
+func (p *PrehashedHeader) Hash(nonce uint32) Hash {
+	binary.LittleEndian.PutUint32(p.header[headerNonceOffset:], nonce)
+	if p.x13 {
+		return X13HashH(p.header[:])
+	}
+
+	// Restore the midstate and only hash the remaining bytes of the header
+	// which include the nonce.  Restoring the state of the digest can't
+	// fail since the state was marshalled by the same digest type.
+	_ = p.digest.(encoding.BinaryUnmarshaler).UnmarshalBinary(p.midstate)
+	p.digest.Write(p.header[sha256.BlockSize:])
+	first := p.digest.Sum(p.first[:0])
+	return Hash(sha256.Sum256(first))
+}
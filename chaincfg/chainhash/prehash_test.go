@@ -0,0 +1,107 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chainhash
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// testHeader returns a serialized block header with the passed nonce.
+func testHeader(nonce uint32) []byte {
+	header := make([]byte, HeaderSize)
+	for i := range header {
+		header[i] = byte(i)
+	}
+	binary.LittleEndian.PutUint32(header[headerNonceOffset:], nonce)
+	return header
+}
+
+// TestPrehashedHeader ensures prehashed headers hash to the same value as
+// hashing the whole serialized header with the rewritten nonce.
+func TestPrehashedHeader(t *testing.T) {
+	if _, err := NewPrehashedHeader(make([]byte, HeaderSize-1), false); err == nil {
+		t.Fatal("NewPrehashedHeader: did not reject short header")
+	}
+
+	for _, x13 := range []bool{false, true} {
+		p, err := NewPrehashedHeader(testHeader(0), x13)
+		if err != nil {
+			t.Fatalf("NewPrehashedHeader: %v", err)
+		}
+
+		for _, nonce := range []uint32{0, 1, 0x01020304, 0xffffffff} {
+			var want Hash
+			if x13 {
+				want = X13HashH(testHeader(nonce))
+			} else {
+				want = DoubleHashH(testHeader(nonce))
+			}
+			if got := p.Hash(nonce); got != want {
+				t.Errorf("Hash(%d) x13 %v: got %v, want %v",
+					nonce, x13, got, want)
+			}
+		}
+	}
+}
+
+// benchmarkHeaderHash benchmarks hashing headers which only differ by their
+// nonce by serializing the whole header for every nonce like the miner did
+// before prehashed headers were available.
+func benchmarkHeaderHash(b *testing.B, hashFunc func([]byte) Hash) {
+	b.SetBytes(HeaderSize)
+	b.RunParallel(func(pb *testing.PB) {
+		var nonce uint32
+		for pb.Next() {
+			header := testHeader(nonce)
+			_ = hashFunc(header)
+			nonce++
+		}
+	})
+}
+
+// benchmarkPrehashedHeader benchmarks hashing headers which only differ by
+// their nonce using a prehashed header per goroutine.
+func benchmarkPrehashedHeader(b *testing.B, x13 bool) {
+	b.SetBytes(HeaderSize)
+	b.RunParallel(func(pb *testing.PB) {
+		p, err := NewPrehashedHeader(testHeader(0), x13)
+		if err != nil {
+			b.Fatalf("NewPrehashedHeader: %v", err)
+		}
+		var nonce uint32
+		for pb.Next() {
+			_ = p.Hash(nonce)
+			nonce++
+		}
+	})
+}
+
+// BenchmarkHeaderHashX13 benchmarks hashing X13 headers by serializing the
+// whole header for every nonce.  Run with -cpu to compare the throughput per
+// core.
+func BenchmarkHeaderHashX13(b *testing.B) {
+	benchmarkHeaderHash(b, X13HashH)
+}
+
+// BenchmarkPrehashedHeaderX13 benchmarks hashing X13 headers with prehashed
+// headers.  Run with -cpu to compare the throughput per core.
+func BenchmarkPrehashedHeaderX13(b *testing.B) {
+	benchmarkPrehashedHeader(b, true)
+}
+
+// BenchmarkHeaderHashDoubleSha256 benchmarks hashing double sha256 headers by
+// serializing the whole header for every nonce.  Run with -cpu to compare the
+// throughput per core.
+func BenchmarkHeaderHashDoubleSha256(b *testing.B) {
+	benchmarkHeaderHash(b, DoubleHashH)
+}
+
+// BenchmarkPrehashedHeaderDoubleSha256 benchmarks hashing double sha256
+// headers with prehashed headers.  Run with -cpu to compare the throughput per
+// core.
+func BenchmarkPrehashedHeaderDoubleSha256(b *testing.B) {
+	benchmarkPrehashedHeader(b, false)
+}
@@ -182,6 +182,11 @@ type Params struct {
 	// GenerateSupported specifies whether or not CPU mining is allowed.
 	GenerateSupported bool
 
+	// SignetChallenge is the script the signature carried by every block
+	// other than the genesis block must satisfy.  It is nil for networks
+	// which don't require signed blocks.  See CustomSignetParams.
+	SignetChallenge []byte
+
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints []Checkpoint
 
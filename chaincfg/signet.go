@@ -0,0 +1,109 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/encrypt-s/navd/chaincfg/chainhash"
+	"github.com/encrypt-s/navd/wire"
+)
+
+// CustomSignetParams returns the network parameters for a private signet
+// NavCoin network.  Every block of a signet network other than the genesis
+// block must carry a signature which satisfies the passed challenge script, so
+// only the holders of the keys required by the challenge are able to mine
+// blocks.  This makes it possible to run reproducible test networks without
+// having to compete with anyone mining on them.
+//
+// The genesis block of the network is the passed block, or the genesis block
+// of the regression test network when it is nil.  The magic bytes of the
+// network are derived from the challenge, so networks with different
+// challenges don't accept each other's peers.
+func CustomSignetParams(challenge []byte, genesis *wire.MsgBlock) Params {
+	if genesis == nil {
+		genesis = &regTestGenesisBlock
+	}
+	genesisHash := genesis.BlockHash()
+
+	// The magic bytes are the first four bytes of the double sha256 of the
+	// challenge.
+	challengeHash := chainhash.DoubleHashB(challenge)
+	net := wire.NavCoinNet(binary.LittleEndian.Uint32(challengeHash[:4]))
+
+	return Params{
+		Name:        "signet",
+		Net:         net,
+		DefaultPort: "38333",
+		DNSSeeds:    []DNSSeed{}, // NOTE: There must NOT be any seeds.
+
+		// Chain parameters
+		GenesisBlock:             genesis,
+		GenesisHash:              &genesisHash,
+		PowLimit:                 regressionPowLimit,
+		PowLimitBits:             0x207fffff,
+		BIP0034Height:            0, // Always active on signet
+		BIP0065Height:            0, // Always active on signet
+		BIP0066Height:            0, // Always active on signet
+		CoinbaseMaturity:         100,
+		SubsidyReductionInterval: 210000,
+		TargetTimespan:           time.Hour * 24 * 14, // 14 days
+		TargetTimePerBlock:       time.Minute * 10,    // 10 minutes
+		RetargetAdjustmentFactor: 4,                   // 25% less, 400% more
+		ReduceMinDifficulty:      false,
+		MinDiffReductionTime:     0,
+		GenerateSupported:        true,
+		SignetChallenge:          challenge,
+
+		// Checkpoints ordered from oldest to newest.
+		Checkpoints: nil,
+
+		// Consensus rule change deployments.
+		//
+		// The miner confirmation window is defined as:
+		//   target proof of work timespan / target proof of work spacing
+		RuleChangeActivationThreshold: 1815, // 90% of MinerConfirmationWindow
+		MinerConfirmationWindow:       2016,
+		Deployments: [DefinedDeployments]ConsensusDeployment{
+			DeploymentTestDummy: {
+				BitNumber:  28,
+				StartTime:  0,             // Always available for vote
+				ExpireTime: math.MaxInt64, // Never expires
+			},
+			DeploymentCSV: {
+				BitNumber:  0,
+				StartTime:  0,             // Always available for vote
+				ExpireTime: math.MaxInt64, // Never expires
+			},
+			DeploymentSegwit: {
+				BitNumber:  1,
+				StartTime:  0,             // Always available for vote
+				ExpireTime: math.MaxInt64, // Never expires.
+			},
+		},
+
+		// Mempool parameters
+		RelayNonStdTxs: true,
+
+		// Human-readable part for Bech32 encoded segwit addresses, as
+		// defined in BIP 173.
+		Bech32HRPSegwit: "tb", // always tb for test net
+
+		// Address encoding magics
+		PubKeyHashAddrID: 0x6f, // starts with m or n
+		ScriptHashAddrID: 0xc4, // starts with 2
+		PrivateKeyID:     0xef, // starts with 9 (uncompressed) or c (compressed)
+
+		// BIP32 hierarchical deterministic extended key magics
+		HDPrivateKeyID: [4]byte{0x04, 0x35, 0x83, 0x94}, // starts with tprv
+		HDPublicKeyID:  [4]byte{0x04, 0x35, 0x87, 0xcf}, // starts with tpub
+
+		// BIP44 coin type used in the hierarchical deterministic path
+		// for address generation.
+		HDCoinType: 1,
+	}
+}
@@ -6,8 +6,10 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -21,12 +23,17 @@ import (
 	"time"
 
 	"github.com/navcoin/navd/blockchain"
+	"github.com/navcoin/navd/btcec"
 	"github.com/navcoin/navd/chaincfg"
 	"github.com/navcoin/navd/chaincfg/chainhash"
 	"github.com/navcoin/navd/connmgr"
 	"github.com/navcoin/navd/database"
 	_ "github.com/navcoin/navd/database/ffldb"
 	"github.com/navcoin/navd/mempool"
+	"github.com/navcoin/navd/mining"
+	"github.com/navcoin/navd/mining/stratum"
+	"github.com/navcoin/navd/txscript"
+	"github.com/navcoin/navd/wire"
 	"github.com/navcoin/navutil"
 	"github.com/btcsuite/go-socks/socks"
 	flags "github.com/jessevdk/go-flags"
@@ -51,6 +58,7 @@ const (
 	defaultBlockMaxSize          = 750000
 	defaultBlockMinWeight        = 0
 	defaultBlockMaxWeight        = 3000000
+	defaultBlockTxSelection      = "priority"
 	blockMaxSizeMin              = 1000
 	blockMaxSizeMax              = blockchain.MaxBlockBaseSize - 1000
 	blockMaxWeightMin            = 4000
@@ -58,6 +66,7 @@ const (
 	defaultGenerate              = false
 	defaultMaxOrphanTransactions = 100
 	defaultMaxOrphanTxSize       = 100000
+	defaultMempoolExpiry         = time.Hour * 24 * 14
 	defaultSigCacheMaxSize       = 100000
 	sampleConfigFilename         = "sample-navd.conf"
 	defaultTxIndex               = false
@@ -104,6 +113,7 @@ type config struct {
 	BanDuration          time.Duration `long:"banduration" description:"How long to ban misbehaving peers.  Valid time units are {s, m, h}.  Minimum 1 second"`
 	BanThreshold         uint32        `long:"banthreshold" description:"Maximum allowed ban score before disconnecting and banning misbehaving peers."`
 	Whitelists           []string      `long:"whitelist" description:"Add an IP network or IP that will not be banned. (eg. 192.168.1.0/24 or ::1)"`
+	ASMap                string        `long:"asmap" description:"File containing a map of IP addresses to autonomous system numbers used to diversify peers by the autonomous system announcing them instead of their /16 or /32"`
 	RPCUser              string        `short:"u" long:"rpcuser" description:"Username for RPC connections"`
 	RPCPass              string        `short:"P" long:"rpcpass" default-mask:"-" description:"Password for RPC connections"`
 	RPCLimitUser         string        `long:"rpclimituser" description:"Username for limited RPC connections"`
@@ -127,9 +137,14 @@ type config struct {
 	OnionProxyPass       string        `long:"onionpass" default-mask:"-" description:"Password for onion proxy server"`
 	NoOnion              bool          `long:"noonion" description:"Disable connecting to tor hidden services"`
 	TorIsolation         bool          `long:"torisolation" description:"Enable Tor stream isolation by randomizing user credentials for each connection."`
+	TorBroadcast         bool          `long:"torbroadcast" description:"Announce transactions submitted through the RPC server only to peers reached over Tor"`
+	V2Transport          bool          `long:"v2transport" description:"Use the encrypted v2 transport protocol (BIP0324) for peer connections which support it"`
 	TestNet3             bool          `long:"testnet" description:"Use the test network"`
 	RegressionTest       bool          `long:"regtest" description:"Use the regression test network"`
 	SimNet               bool          `long:"simnet" description:"Use the simulation test network"`
+	SignetChallenge      string        `long:"signetchallenge" description:"Use a signet test network whose blocks must be signed to satisfy this hex-encoded challenge script"`
+	SignetGenesis        string        `long:"signetgenesis" description:"Hex-encoded genesis block of the signet test network -- defaults to the regression test genesis block"`
+	SignetKey            string        `long:"signetkey" default-mask:"-" description:"WIF-encoded private key used to sign generated blocks on a signet test network"`
 	AddCheckpoints       []string      `long:"addcheckpoint" description:"Add a custom checkpoint.  Format: '<height>:<hash>'"`
 	DisableCheckpoints   bool          `long:"nocheckpoints" description:"Disable built-in checkpoints.  Don't do this unless you know what you're doing."`
 	DbType               string        `long:"dbtype" description:"Database backend to use for the Block Chain"`
@@ -141,13 +156,26 @@ type config struct {
 	FreeTxRelayLimit     float64       `long:"limitfreerelay" description:"Limit relay of transactions with no transaction fee to the given amount in thousands of bytes per minute"`
 	NoRelayPriority      bool          `long:"norelaypriority" description:"Do not require free or low-fee transactions to have high priority for relaying"`
 	MaxOrphanTxs         int           `long:"maxorphantx" description:"Max number of orphan transactions to keep in memory"`
+	MempoolExpiry        time.Duration `long:"mempoolexpiry" description:"Evict transactions and their descendants from the mempool once they have been unconfirmed for this long -- 0 to disable"`
+	EventPubListeners    []string      `long:"eventpub" description:"Add an interface/port or unix:<path> socket to publish mempool and block events on -- No events are published when none are specified"`
 	Generate             bool          `long:"generate" description:"Generate (mine) navcoins using the CPU"`
 	MiningAddrs          []string      `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- At least one address is required if the generate option is set"`
+	CoinbaseSplit        []string      `long:"coinbasesplit" description:"Split the value of the coinbase of generated blocks across the specified address in proportion to its weight, given as address:weight -- May be specified up to 16 times and may be used instead of miningaddr"`
+	CoinbaseTag          string        `long:"coinbasetag" description:"Add the specified text to the coinbase of generated blocks in an OP_RETURN output"`
+	StratumListeners     []string      `long:"stratum" description:"Add an interface/port to accept Stratum mining connections on -- At least one mining address is required if this option is set"`
+	StratumDifficulty    float64       `long:"stratumdifficulty" description:"The initial share difficulty assigned to Stratum miners"`
+	StratumMinDifficulty float64       `long:"stratummindifficulty" description:"The lowest share difficulty assigned to Stratum miners, including through variable difficulty and mining.suggest_difficulty"`
+	StratumShareInterval time.Duration `long:"stratumshareinterval" description:"The time between shares Stratum miners are retargeted towards -- 0 to disable variable difficulty"`
 	BlockMinSize         uint32        `long:"blockminsize" description:"Mininum block size in bytes to be used when creating a block"`
 	BlockMaxSize         uint32        `long:"blockmaxsize" description:"Maximum block size in bytes to be used when creating a block"`
 	BlockMinWeight       uint32        `long:"blockminweight" description:"Mininum block weight to be used when creating a block"`
 	BlockMaxWeight       uint32        `long:"blockmaxweight" description:"Maximum block weight to be used when creating a block"`
 	BlockPrioritySize    uint32        `long:"blockprioritysize" description:"Size in bytes for high-priority/low-fee transactions when creating a block"`
+	BlockTxSelection     string        `long:"blocktxselection" description:"Policy used to select transactions when creating a block {priority, feerate, ancestor}"`
+	BlockAllowClasses    []string      `long:"blockallowclass" description:"Only include transactions whose outputs are of the specified script class or pay an allowed address when creating a block"`
+	BlockDenyClasses     []string      `long:"blockdenyclass" description:"Do not include transactions with an output of the specified script class when creating a block"`
+	BlockAllowAddrs      []string      `long:"blockallowaddr" description:"Only include transactions whose outputs pay the specified address or are of an allowed script class when creating a block"`
+	BlockDenyAddrs       []string      `long:"blockdenyaddr" description:"Do not include transactions with an output paying the specified address when creating a block"`
 	UserAgentComments    []string      `long:"uacomment" description:"Comment to add to the user agent -- See BIP 14 for more information."`
 	NoPeerBloomFilters   bool          `long:"nopeerbloomfilters" description:"Disable bloom filtering support"`
 	NoCFilters           bool          `long:"nocfilters" description:"Disable committed filtering (CF) support"`
@@ -165,8 +193,11 @@ type config struct {
 	dial                 func(string, string, time.Duration) (net.Conn, error)
 	addCheckpoints       []chaincfg.Checkpoint
 	miningAddrs          []navutil.Address
+	txSelector           mining.TxSelector
+	coinbaseSplit        *mining.CoinbaseSplit
 	minRelayTxFee        navutil.Amount
 	whitelists           []*net.IPNet
+	signetKey            *btcec.PrivateKey
 }
 
 // serviceOptions defines the configuration options for the daemon as a service on
@@ -284,6 +315,99 @@ func validDbType(dbType string) bool {
 	return false
 }
 
+// newTxSelector returns the transaction selector for the passed block
+// transaction selection policy name.
+func newTxSelector(name string) (mining.TxSelector, error) {
+	switch name {
+	case "priority":
+		return mining.PriorityTxSelector{}, nil
+	case "feerate":
+		return mining.FeeRateTxSelector{}, nil
+	case "ancestor":
+		return mining.AncestorTxSelector{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown transaction selection policy '%s'",
+		name)
+}
+
+// parseScriptClasses returns the script classes for the passed script class
+// names.
+func parseScriptClasses(names []string) ([]txscript.ScriptClass, error) {
+	classes := make([]txscript.ScriptClass, 0, len(names))
+	for _, name := range names {
+		found := false
+		for class := txscript.NonStandardTy; class <= txscript.NullDataTy; class++ {
+			if class.String() == name {
+				classes = append(classes, class)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown script class '%s'", name)
+		}
+	}
+
+	return classes, nil
+}
+
+// parseAddrs decodes the passed addresses and ensures they are all for the
+// passed network.
+func parseAddrs(strAddrs []string, params *chaincfg.Params) ([]navutil.Address, error) {
+	addrs := make([]navutil.Address, 0, len(strAddrs))
+	for _, strAddr := range strAddrs {
+		addr, err := navutil.DecodeAddress(strAddr, params)
+		if err != nil {
+			return nil, fmt.Errorf("address '%s' failed to "+
+				"decode: %v", strAddr, err)
+		}
+		if !addr.IsForNet(params) {
+			return nil, fmt.Errorf("address '%s' is on the wrong "+
+				"network", strAddr)
+		}
+		addrs = append(addrs, addr)
+	}
+
+	return addrs, nil
+}
+
+// parseCoinbaseSplit returns the coinbase split for the passed address:weight
+// payouts and tag.  It returns nil when there are no payouts and the tag is
+// empty.
+func parseCoinbaseSplit(payouts []string, tag string, params *chaincfg.Params) (*mining.CoinbaseSplit, error) {
+	if len(payouts) == 0 && tag == "" {
+		return nil, nil
+	}
+
+	split := &mining.CoinbaseSplit{Tag: []byte(tag)}
+	for _, payout := range payouts {
+		parts := strings.Split(payout, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("coinbase payout '%s' is not "+
+				"of the form address:weight", payout)
+		}
+		addrs, err := parseAddrs(parts[:1], params)
+		if err != nil {
+			return nil, err
+		}
+		weight, err := strconv.ParseUint(parts[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("coinbase payout '%s' has an "+
+				"invalid weight: %v", payout, err)
+		}
+		split.Payouts = append(split.Payouts, mining.CoinbasePayout{
+			Addr:   addrs[0],
+			Weight: uint32(weight),
+		})
+	}
+	if err := split.Validate(); err != nil {
+		return nil, err
+	}
+
+	return split, nil
+}
+
 // removeDuplicateAddresses returns a new slice with all duplicate entries in
 // addrs removed.
 func removeDuplicateAddresses(addrs []string) []string {
@@ -366,6 +490,28 @@ func parseCheckpoints(checkpointStrings []string) ([]chaincfg.Checkpoint, error)
 	return checkpoints, nil
 }
 
+// decodeSignetGenesis decodes the passed hex-encoded serialized block to be
+// used as the genesis block of a signet network.
+func decodeSignetGenesis(genesisHex string) (*wire.MsgBlock, error) {
+	serialized, err := hex.DecodeString(genesisHex)
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(serialized)
+	var genesis wire.MsgBlock
+	if err := genesis.Deserialize(r); err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes after the block",
+			r.Len())
+	}
+	if len(genesis.Transactions) == 0 {
+		return nil, errors.New("the block has no transactions")
+	}
+	return &genesis, nil
+}
+
 // filesExists reports whether the named file or directory exists.
 func fileExists(name string) bool {
 	if _, err := os.Stat(name); err != nil {
@@ -420,9 +566,14 @@ func loadConfig() (*config, []string, error) {
 		BlockMinWeight:       defaultBlockMinWeight,
 		BlockMaxWeight:       defaultBlockMaxWeight,
 		BlockPrioritySize:    mempool.DefaultBlockPrioritySize,
+		BlockTxSelection:     defaultBlockTxSelection,
 		MaxOrphanTxs:         defaultMaxOrphanTransactions,
+		MempoolExpiry:        defaultMempoolExpiry,
 		SigCacheMaxSize:      defaultSigCacheMaxSize,
 		Generate:             defaultGenerate,
+		StratumDifficulty:    stratum.DefaultDifficulty,
+		StratumMinDifficulty: stratum.DefaultMinDifficulty,
+		StratumShareInterval: stratum.DefaultShareInterval,
 		TxIndex:              defaultTxIndex,
 		AddrIndex:            defaultAddrIndex,
 	}
@@ -542,9 +693,52 @@ func loadConfig() (*config, []string, error) {
 		activeNetParams = &simNetParams
 		cfg.DisableDNSSeed = true
 	}
+	if cfg.SignetChallenge != "" {
+		numNets++
+		challenge, err := hex.DecodeString(cfg.SignetChallenge)
+		if err != nil || len(challenge) == 0 {
+			str := "%s: The signetchallenge option must be a " +
+				"non-empty hex-encoded script -- parsed [%v]"
+			err := fmt.Errorf(str, funcName, cfg.SignetChallenge)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+
+		// Decode the genesis block of the network when one is given.
+		var genesis *wire.MsgBlock
+		if cfg.SignetGenesis != "" {
+			genesis, err = decodeSignetGenesis(cfg.SignetGenesis)
+			if err != nil {
+				str := "%s: The signetgenesis option must be a " +
+					"hex-encoded serialized block: %v"
+				err := fmt.Errorf(str, funcName, err)
+				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(os.Stderr, usageMessage)
+				return nil, nil, err
+			}
+		}
+
+		// Register the network so addresses and keys for it can be
+		// decoded.  Also disable dns seeding since signet networks are
+		// private.
+		signetParams := chaincfg.CustomSignetParams(challenge, genesis)
+		if err := chaincfg.Register(&signetParams); err != nil {
+			str := "%s: Unable to register the signet network: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		activeNetParams = &params{
+			Params:  &signetParams,
+			rpcPort: "38332",
+		}
+		cfg.DisableDNSSeed = true
+	}
 	if numNets > 1 {
-		str := "%s: The testnet, regtest, segnet, and simnet params " +
-			"can't be used together -- choose one of the four"
+		str := "%s: The testnet, regtest, segnet, simnet, and signet " +
+			"params can't be used together -- choose one of the five"
 		err := fmt.Errorf(str, funcName)
 		fmt.Fprintln(os.Stderr, err)
 		fmt.Fprintln(os.Stderr, usageMessage)
@@ -585,6 +779,10 @@ func loadConfig() (*config, []string, error) {
 	cfg.LogDir = cleanAndExpandPath(cfg.LogDir)
 	cfg.LogDir = filepath.Join(cfg.LogDir, netName(activeNetParams))
 
+	if cfg.ASMap != "" {
+		cfg.ASMap = cleanAndExpandPath(cfg.ASMap)
+	}
+
 	// Special show command to list supported subsystems and exit.
 	if cfg.DebugLevel == "show" {
 		fmt.Println("Supported subsystems", supportedSubsystems())
@@ -794,6 +992,16 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// The mempool expiry may not be negative.
+	if cfg.MempoolExpiry < 0 {
+		str := "%s: The mempoolexpiry option may not be less than 0 " +
+			"-- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.MempoolExpiry)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Limit the block priority and minimum block sizes to max block size.
 	cfg.BlockPrioritySize = minUint32(cfg.BlockPrioritySize, cfg.BlockMaxSize)
 	cfg.BlockMinSize = minUint32(cfg.BlockMinSize, cfg.BlockMaxSize)
@@ -881,9 +1089,129 @@ func loadConfig() (*config, []string, error) {
 		cfg.miningAddrs = append(cfg.miningAddrs, addr)
 	}
 
+	// Create the transaction selector used when creating blocks and wrap it
+	// with an allow/deny list filter when any are specified.
+	cfg.txSelector, err = newTxSelector(cfg.BlockTxSelection)
+	if err != nil {
+		str := "%s: the blocktxselection option is invalid: %v"
+		err := fmt.Errorf(str, funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	var filter mining.TxFilter
+	filter.AllowClasses, err = parseScriptClasses(cfg.BlockAllowClasses)
+	if err == nil {
+		filter.DenyClasses, err = parseScriptClasses(cfg.BlockDenyClasses)
+	}
+	if err != nil {
+		str := "%s: the blockallowclass and blockdenyclass options " +
+			"are invalid: %v"
+		err := fmt.Errorf(str, funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	filter.AllowAddrs, err = parseAddrs(cfg.BlockAllowAddrs,
+		activeNetParams.Params)
+	if err == nil {
+		filter.DenyAddrs, err = parseAddrs(cfg.BlockDenyAddrs,
+			activeNetParams.Params)
+	}
+	if err != nil {
+		str := "%s: the blockallowaddr and blockdenyaddr options " +
+			"are invalid: %v"
+		err := fmt.Errorf(str, funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if len(filter.AllowClasses) > 0 || len(filter.DenyClasses) > 0 ||
+		len(filter.AllowAddrs) > 0 || len(filter.DenyAddrs) > 0 {
+
+		cfg.txSelector = mining.NewFilterTxSelector(cfg.txSelector,
+			&filter, activeNetParams.Params)
+	}
+
+	// Parse how the value of the coinbase of generated blocks is split.  The
+	// split addresses are used as the mining addresses when none are
+	// specified since the value is paid to them regardless.
+	cfg.coinbaseSplit, err = parseCoinbaseSplit(cfg.CoinbaseSplit,
+		cfg.CoinbaseTag, activeNetParams.Params)
+	if err != nil {
+		str := "%s: the coinbasesplit and coinbasetag options are " +
+			"invalid: %v"
+		err := fmt.Errorf(str, funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if len(cfg.miningAddrs) == 0 && cfg.coinbaseSplit != nil {
+		for _, payout := range cfg.coinbaseSplit.Payouts {
+			cfg.miningAddrs = append(cfg.miningAddrs, payout.Addr)
+		}
+	}
+
+	// The genesis block option only applies to signet networks.
+	if cfg.SignetGenesis != "" && len(activeNetParams.SignetChallenge) == 0 {
+		str := "%s: the signetgenesis option requires the " +
+			"signetchallenge option"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Decode the key used to sign generated blocks on signet networks.
+	if cfg.SignetKey != "" {
+		if len(activeNetParams.SignetChallenge) == 0 {
+			str := "%s: the signetkey option requires the " +
+				"signetchallenge option"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		wif, err := navutil.DecodeWIF(cfg.SignetKey)
+		if err != nil {
+			str := "%s: the signetkey option is not a valid " +
+				"WIF-encoded private key: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.signetKey = wif.PrivKey
+	}
+
+	// Blocks generated on signet networks must be signed, so the generate
+	// flag requires a signing key.
+	if cfg.Generate && len(activeNetParams.SignetChallenge) != 0 &&
+		cfg.signetKey == nil {
+
+		str := "%s: the generate flag is set on a signet network, but " +
+			"there is no signing key specified via --signetkey"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Stratum miners can't sign the blocks they solve, so Stratum
+	// listeners can't be used on signet networks.
+	if len(cfg.StratumListeners) > 0 &&
+		len(activeNetParams.SignetChallenge) != 0 {
+
+		str := "%s: the stratum option can't be used on signet networks"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Ensure there is at least one mining address when the generate flag is
 	// set.
-	if cfg.Generate && len(cfg.MiningAddrs) == 0 {
+	if cfg.Generate && len(cfg.miningAddrs) == 0 {
 		str := "%s: the generate flag is set, but there are no mining " +
 			"addresses specified "
 		err := fmt.Errorf(str, funcName)
@@ -892,6 +1220,44 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Ensure there is at least one mining address when Stratum listeners
+	// are specified.
+	if len(cfg.StratumListeners) > 0 && len(cfg.miningAddrs) == 0 {
+		str := "%s: the stratum option is set, but there are no mining " +
+			"addresses specified "
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Ensure the Stratum share difficulties are positive and the share
+	// interval is not negative.
+	if cfg.StratumDifficulty <= 0 {
+		str := "%s: The stratumdifficulty option must be greater " +
+			"than 0 -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.StratumDifficulty)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.StratumMinDifficulty <= 0 {
+		str := "%s: The stratummindifficulty option must be greater " +
+			"than 0 -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.StratumMinDifficulty)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.StratumShareInterval < 0 {
+		str := "%s: The stratumshareinterval option may not be less " +
+			"than 0 -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.StratumShareInterval)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Add default port to all listener addresses if needed and remove
 	// duplicate addresses.
 	cfg.Listeners = normalizeAddresses(cfg.Listeners,
@@ -968,6 +1334,20 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Broadcasting transactions via Tor requires a proxy to reach peers
+	// over Tor.
+	if cfg.TorBroadcast && (cfg.NoOnion || (cfg.Proxy == "" &&
+		cfg.OnionProxy == "")) {
+
+		str := "%s: broadcasting transactions via Tor requires either " +
+			"proxy or onionproxy to be set and may not be used with " +
+			"--noonion"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Setup dial and DNS resolution (lookup) functions depending on the
 	// specified options.  The default is to use the standard
 	// net.DialTimeout function as well as the system DNS resolver.  When a
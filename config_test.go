@@ -1,12 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"encoding/hex"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
 	"runtime"
 	"testing"
+
+	"github.com/navcoin/navd/chaincfg"
 )
 
 var (
@@ -70,3 +74,31 @@ func TestCreateDefaultConfigFile(t *testing.T) {
 		t.Error("Could not find rpcpass in generated default config file.")
 	}
 }
+
+// TestDecodeSignetGenesis ensures signet genesis blocks are decoded from their
+// hex-encoded serialization and malformed ones are rejected.
+func TestDecodeSignetGenesis(t *testing.T) {
+	var buf bytes.Buffer
+	genesis := chaincfg.RegressionNetParams.GenesisBlock
+	if err := genesis.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	genesisHex := hex.EncodeToString(buf.Bytes())
+
+	block, err := decodeSignetGenesis(genesisHex)
+	if err != nil {
+		t.Fatalf("decodeSignetGenesis: unexpected error: %v", err)
+	}
+	if block.BlockHash() != genesis.BlockHash() {
+		t.Fatalf("decodeSignetGenesis: got block %v, want %v",
+			block.BlockHash(), genesis.BlockHash())
+	}
+
+	invalid := []string{"zz", genesisHex[:80], genesisHex + "00"}
+	for _, test := range invalid {
+		if _, err := decodeSignetGenesis(test); err == nil {
+			t.Fatalf("decodeSignetGenesis(%.16s...): unexpected success",
+				test)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// banListVersion is the version of the serialized ban list.
+const banListVersion = 1
+
+// BanEntry describes a banned subnet along with the time the ban was created
+// and the time it expires.  Hosts which are not IP addresses, such as Tor
+// onion addresses, are banned by name, in which case Host is set and Subnet is
+// nil.
+type BanEntry struct {
+	Subnet  *net.IPNet
+	Host    string
+	Created time.Time
+	Until   time.Time
+}
+
+// String returns the banned subnet or host.
+func (e *BanEntry) String() string {
+	if e.Subnet == nil {
+		return e.Host
+	}
+	return e.Subnet.String()
+}
+
+// serializedBanEntry is the serialized form of a BanEntry.
+type serializedBanEntry struct {
+	Subnet  string `json:"subnet,omitempty"`
+	Host    string `json:"host,omitempty"`
+	Created int64  `json:"created"`
+	Until   int64  `json:"until"`
+}
+
+// serializedBanList is the serialized form of a BanList.
+type serializedBanList struct {
+	Version int                  `json:"version"`
+	Bans    []serializedBanEntry `json:"bans"`
+}
+
+// BanList houses banned IP addresses and subnets along with the time their
+// bans expire.  Every modification is persisted to the file of the ban list
+// so that bans survive restarts.
+//
+// All functions are safe for concurrent access.
+type BanList struct {
+	mtx      sync.Mutex
+	filePath string
+	bans     map[string]*BanEntry // keyed by subnet
+	hostBans map[string]*BanEntry // keyed by host
+}
+
+// NewBanList returns a new empty ban list which is persisted to the provided
+// file.  An empty path results in a ban list which is only kept in memory.
+// Use Load to read the bans from an existing file.
+func NewBanList(filePath string) *BanList {
+	return &BanList{
+		filePath: filePath,
+		bans:     make(map[string]*BanEntry),
+		hostBans: make(map[string]*BanEntry),
+	}
+}
+
+// ParseSubnet parses the provided IP address or subnet in CIDR notation.  An
+// IP address results in a subnet which only contains that address.
+func ParseSubnet(subnet string) (*net.IPNet, error) {
+	if ip := net.ParseIP(subnet); ip != nil {
+		return singleIPSubnet(ip), nil
+	}
+	_, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP address or subnet %q", subnet)
+	}
+	return ipNet, nil
+}
+
+// singleIPSubnet returns the subnet which only contains the provided address.
+func singleIPSubnet(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip.To16(), Mask: net.CIDRMask(128, 128)}
+}
+
+// Load reads the bans from the file of the ban list, replacing any bans
+// currently held.  A missing file results in an empty ban list.
+func (b *BanList) Load() error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.bans = make(map[string]*BanEntry)
+	b.hostBans = make(map[string]*BanEntry)
+	if b.filePath == "" {
+		return nil
+	}
+	r, err := os.Open(b.filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s error opening file: %v", b.filePath, err)
+	}
+	defer r.Close()
+
+	var sbl serializedBanList
+	if err := json.NewDecoder(r).Decode(&sbl); err != nil {
+		return fmt.Errorf("error reading %s: %v", b.filePath, err)
+	}
+	if sbl.Version != banListVersion {
+		return fmt.Errorf("unknown version %v in serialized ban list",
+			sbl.Version)
+	}
+
+	for _, sbe := range sbl.Bans {
+		entry := &BanEntry{
+			Host:    sbe.Host,
+			Created: time.Unix(sbe.Created, 0),
+			Until:   time.Unix(sbe.Until, 0),
+		}
+		if sbe.Host != "" {
+			b.hostBans[sbe.Host] = entry
+			continue
+		}
+		subnet, err := ParseSubnet(sbe.Subnet)
+		if err != nil {
+			return err
+		}
+		entry.Subnet = subnet
+		b.bans[subnet.String()] = entry
+	}
+	b.sweep()
+	return nil
+}
+
+// save writes the bans to the file of the ban list.  The bans are written to
+// a temporary file which then replaces the file of the ban list, so the file is
+// never left partially written.
+//
+// This function MUST be called with the ban list lock held.
+func (b *BanList) save() {
+	if b.filePath == "" {
+		return
+	}
+
+	sbl := serializedBanList{
+		Version: banListVersion,
+		Bans:    make([]serializedBanEntry, 0, len(b.bans)+len(b.hostBans)),
+	}
+	for _, entry := range b.bans {
+		sbl.Bans = append(sbl.Bans, serializedBanEntry{
+			Subnet:  entry.Subnet.String(),
+			Created: entry.Created.Unix(),
+			Until:   entry.Until.Unix(),
+		})
+	}
+	for _, entry := range b.hostBans {
+		sbl.Bans = append(sbl.Bans, serializedBanEntry{
+			Host:    entry.Host,
+			Created: entry.Created.Unix(),
+			Until:   entry.Until.Unix(),
+		})
+	}
+
+	tmpPath := b.filePath + ".tmp"
+	w, err := os.Create(tmpPath)
+	if err != nil {
+		log.Errorf("Error opening file %s: %v", tmpPath, err)
+		return
+	}
+	err = json.NewEncoder(w).Encode(&sbl)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Errorf("Failed to encode file %s: %v", tmpPath, err)
+		os.Remove(tmpPath)
+		return
+	}
+	if err := os.Rename(tmpPath, b.filePath); err != nil {
+		log.Errorf("Error replacing file %s: %v", b.filePath, err)
+		os.Remove(tmpPath)
+	}
+}
+
+// sweep removes the expired bans.  It returns whether any ban was removed.
+//
+// This function MUST be called with the ban list lock held.
+func (b *BanList) sweep() bool {
+	now := time.Now()
+	removed := false
+	for _, bans := range []map[string]*BanEntry{b.bans, b.hostBans} {
+		for key, entry := range bans {
+			if !now.Before(entry.Until) {
+				log.Infof("Ban of %v expired", entry)
+				delete(bans, key)
+				removed = true
+			}
+		}
+	}
+	return removed
+}
+
+// Ban bans the provided subnet until the provided time.  An existing ban of
+// the subnet is replaced.
+func (b *BanList) Ban(subnet *net.IPNet, until time.Time) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.bans[subnet.String()] = &BanEntry{
+		Subnet:  subnet,
+		Created: time.Now(),
+		Until:   until,
+	}
+	b.save()
+}
+
+// BanIP bans the provided IP address until the provided time.  A nil address
+// is ignored.
+func (b *BanList) BanIP(ip net.IP, until time.Time) {
+	if ip == nil {
+		return
+	}
+	b.Ban(singleIPSubnet(ip), until)
+}
+
+// BanHost bans the provided host until the provided time.  Hosts which are IP
+// addresses are banned as such while other hosts, such as Tor onion addresses,
+// are only banned by their exact name.
+func (b *BanList) BanHost(host string, until time.Time) {
+	if ip := net.ParseIP(host); ip != nil {
+		b.BanIP(ip, until)
+		return
+	}
+	if host == "" {
+		return
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.hostBans[host] = &BanEntry{
+		Host:    host,
+		Created: time.Now(),
+		Until:   until,
+	}
+	b.save()
+}
+
+// Unban removes the ban of the provided subnet.  It returns whether the subnet
+// was banned.
+func (b *BanList) Unban(subnet *net.IPNet) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	key := subnet.String()
+	if _, ok := b.bans[key]; !ok {
+		return false
+	}
+	delete(b.bans, key)
+	b.save()
+	return true
+}
+
+// Clear removes all bans.
+func (b *BanList) Clear() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.bans = make(map[string]*BanEntry)
+	b.hostBans = make(map[string]*BanEntry)
+	b.save()
+}
+
+// IsBanned returns whether the provided IP address is within any banned subnet
+// and the time the longest of these bans expires.  A nil address is never
+// banned.
+func (b *BanList) IsBanned(ip net.IP) (time.Time, bool) {
+	if ip == nil {
+		return time.Time{}, false
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.sweep() {
+		b.save()
+	}
+
+	var until time.Time
+	banned := false
+	for _, entry := range b.bans {
+		if entry.Subnet.Contains(ip) && entry.Until.After(until) {
+			until = entry.Until
+			banned = true
+		}
+	}
+	return until, banned
+}
+
+// IsHostBanned returns whether the provided host is banned and the time the
+// ban expires.  Hosts which are IP addresses are checked against the banned
+// subnets while other hosts must have been banned by name.
+func (b *BanList) IsHostBanned(host string) (time.Time, bool) {
+	if ip := net.ParseIP(host); ip != nil {
+		return b.IsBanned(ip)
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.sweep() {
+		b.save()
+	}
+
+	entry, ok := b.hostBans[host]
+	if !ok {
+		return time.Time{}, false
+	}
+	return entry.Until, true
+}
+
+// Entries returns the bans which have not expired yet ordered by subnet or
+// host.
+func (b *BanList) Entries() []BanEntry {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.sweep() {
+		b.save()
+	}
+
+	entries := make([]BanEntry, 0, len(b.bans)+len(b.hostBans))
+	for _, entry := range b.bans {
+		entries = append(entries, *entry)
+	}
+	for _, entry := range b.hostBans {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].String() < entries[j].String()
+	})
+	return entries
+}
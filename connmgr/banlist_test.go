@@ -0,0 +1,164 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestParseSubnet ensures IP addresses and subnets are parsed as expected.
+func TestParseSubnet(t *testing.T) {
+	tests := []struct {
+		in    string
+		want  string
+		valid bool
+	}{
+		{in: "10.0.0.1", want: "10.0.0.1/32", valid: true},
+		{in: "10.0.0.0/8", want: "10.0.0.0/8", valid: true},
+		{in: "10.1.2.3/16", want: "10.1.0.0/16", valid: true},
+		{in: "fe80::1", want: "fe80::1/128", valid: true},
+		{in: "2001:db8::/32", want: "2001:db8::/32", valid: true},
+		{in: "example.com"},
+		{in: "10.0.0.0/33"},
+	}
+
+	for i, test := range tests {
+		subnet, err := ParseSubnet(test.in)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("ParseSubnet #%d: expected error for %s",
+					i, test.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSubnet #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if subnet.String() != test.want {
+			t.Errorf("ParseSubnet #%d: got %s want %s", i, subnet,
+				test.want)
+		}
+	}
+}
+
+// TestBanList ensures bans are enforced for the addresses within the banned
+// subnets, expire, and are persisted across loads.
+func TestBanList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "banlist")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "banlist.json")
+
+	bl := NewBanList(filePath)
+	if err := bl.Load(); err != nil {
+		t.Fatalf("Load: unexpected error for missing file: %v", err)
+	}
+
+	subnet, _ := ParseSubnet("10.0.0.0/8")
+	until := time.Now().Add(time.Hour)
+	bl.Ban(subnet, until)
+	bl.BanIP(net.ParseIP("192.168.1.1"), time.Now().Add(-time.Second))
+
+	if _, banned := bl.IsBanned(net.ParseIP("10.1.2.3")); !banned {
+		t.Fatal("IsBanned: address within banned subnet not banned")
+	}
+	if _, banned := bl.IsBanned(net.ParseIP("11.0.0.1")); banned {
+		t.Fatal("IsBanned: address outside banned subnet banned")
+	}
+	if _, banned := bl.IsBanned(net.ParseIP("192.168.1.1")); banned {
+		t.Fatal("IsBanned: address with expired ban banned")
+	}
+
+	// The bans are restored from the file.
+	bl = NewBanList(filePath)
+	if err := bl.Load(); err != nil {
+		t.Fatalf("Load: unexpected error: %v", err)
+	}
+	entries := bl.Entries()
+	if len(entries) != 1 || entries[0].Subnet.String() != "10.0.0.0/8" {
+		t.Fatalf("Entries: unexpected entries after load %v", entries)
+	}
+	if entries[0].Until.Unix() != until.Unix() {
+		t.Fatalf("Entries: ban expires at %v, want %v",
+			entries[0].Until, until)
+	}
+
+	if bl.Unban(singleIPSubnet(net.ParseIP("10.0.0.1"))) {
+		t.Fatal("Unban: removed ban which does not exist")
+	}
+	if !bl.Unban(subnet) {
+		t.Fatal("Unban: did not remove existing ban")
+	}
+	if _, banned := bl.IsBanned(net.ParseIP("10.1.2.3")); banned {
+		t.Fatal("IsBanned: address banned after unban")
+	}
+
+	bl.BanIP(net.ParseIP("fe80::1"), until)
+	bl.Clear()
+	if err := bl.Load(); err != nil {
+		t.Fatalf("Load: unexpected error: %v", err)
+	}
+	if len(bl.Entries()) != 0 {
+		t.Fatal("Entries: bans left after clear")
+	}
+}
+
+// TestBanListHosts ensures hosts which are not IP addresses are banned by name
+// only, that nil addresses are never banned, and that host bans are persisted
+// across loads.
+func TestBanListHosts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "banlist")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "banlist.json")
+
+	const onion = "expyuzz4wqqyqhjn.onion"
+	until := time.Now().Add(time.Hour)
+	bl := NewBanList(filePath)
+	bl.BanIP(nil, until)
+	bl.BanHost(onion, until)
+	bl.BanHost("10.0.0.1", until)
+
+	if _, banned := bl.IsHostBanned(onion); !banned {
+		t.Fatal("IsHostBanned: banned host not banned")
+	}
+	if _, banned := bl.IsHostBanned("3g2upl4pq6kufc4m.onion"); banned {
+		t.Fatal("IsHostBanned: other host banned")
+	}
+	if _, banned := bl.IsHostBanned("10.0.0.1"); !banned {
+		t.Fatal("IsHostBanned: banned IP address not banned")
+	}
+	if _, banned := bl.IsBanned(nil); banned {
+		t.Fatal("IsBanned: nil address banned")
+	}
+
+	// The bans are restored from the file, which is replaced as a whole.
+	if _, err := os.Stat(filePath + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("Stat: temporary file left behind: %v", err)
+	}
+	bl = NewBanList(filePath)
+	if err := bl.Load(); err != nil {
+		t.Fatalf("Load: unexpected error: %v", err)
+	}
+	entries := bl.Entries()
+	if len(entries) != 2 || entries[0].String() != "10.0.0.1/32" ||
+		entries[1].String() != onion || entries[1].Subnet != nil {
+
+		t.Fatalf("Entries: unexpected entries after load %v", entries)
+	}
+	if _, banned := bl.IsHostBanned(onion); !banned {
+		t.Fatal("IsHostBanned: banned host not banned after load")
+	}
+}
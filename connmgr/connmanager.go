@@ -53,13 +53,15 @@ const (
 )
 
 // ConnReq is the connection request to a network address. If permanent, the
-// connection will be retried on disconnection.
+// connection will be retried on disconnection.  Block-relay-only connections
+// are only used to relay blocks, neither transactions nor addresses.
 type ConnReq struct {
 	// The following variables must only be used atomically.
 	id uint64
 
-	Addr      net.Addr
-	Permanent bool
+	Addr           net.Addr
+	Permanent      bool
+	BlockRelayOnly bool
 
 	conn       net.Conn
 	state      ConnState
@@ -124,6 +126,10 @@ type Config struct {
 	// maintain. Defaults to 8.
 	TargetOutbound uint32
 
+	// TargetBlockRelayOnly is the number of block-relay-only outbound
+	// network connections to maintain in addition to TargetOutbound.
+	TargetBlockRelayOnly uint32
+
 	// RetryDuration is the duration to wait before retrying connection
 	// requests. Defaults to 5s.
 	RetryDuration time.Duration
@@ -140,6 +146,11 @@ type Config struct {
 	// to.  If nil, no new connections will be made automatically.
 	GetNewAddress func() (net.Addr, error)
 
+	// GetBlockRelayOnlyAddress is a way to get an address to make a
+	// block-relay-only network connection to.  If nil, GetNewAddress is
+	// used instead.
+	GetBlockRelayOnlyAddress func() (net.Addr, error)
+
 	// Dial connects to the address on the named network. It cannot be nil.
 	Dial func(net.Addr) (net.Conn, error)
 }
@@ -211,10 +222,10 @@ func (cm *ConnManager) handleFailedConn(c *ConnReq) {
 				"-- retrying connection in: %v", maxFailedAttempts,
 				cm.cfg.RetryDuration)
 			time.AfterFunc(cm.cfg.RetryDuration, func() {
-				cm.NewConnReq()
+				cm.newConnReq(c.BlockRelayOnly)
 			})
 		} else {
-			go cm.NewConnReq()
+			go cm.newConnReq(c.BlockRelayOnly)
 		}
 	}
 }
@@ -322,7 +333,7 @@ out:
 				// re added to the pending map, so that
 				// subsequent processing of connections and
 				// failures do not ignore the request.
-				if uint32(len(conns)) < cm.cfg.TargetOutbound ||
+				if cm.needMoreConns(conns, connReq.BlockRelayOnly) ||
 					connReq.Permanent {
 
 					connReq.updateState(ConnPending)
@@ -356,17 +367,45 @@ out:
 	log.Trace("Connection handler done")
 }
 
+// needMoreConns returns whether fewer of the given established connections
+// are block-relay-only, or not block-relay-only, than targeted.
+//
+// This function MUST only be called from the connection handler goroutine.
+func (cm *ConnManager) needMoreConns(conns map[uint64]*ConnReq, blockRelayOnly bool) bool {
+	target := cm.cfg.TargetOutbound
+	if blockRelayOnly {
+		target = cm.cfg.TargetBlockRelayOnly
+	}
+	var count uint32
+	for _, c := range conns {
+		if c.BlockRelayOnly == blockRelayOnly {
+			count++
+		}
+	}
+	return count < target
+}
+
 // NewConnReq creates a new connection request and connects to the
 // corresponding address.
 func (cm *ConnManager) NewConnReq() {
+	cm.newConnReq(false)
+}
+
+// newConnReq creates a new connection request, which is block-relay-only as
+// requested, and connects to the corresponding address.
+func (cm *ConnManager) newConnReq(blockRelayOnly bool) {
 	if atomic.LoadInt32(&cm.stop) != 0 {
 		return
 	}
-	if cm.cfg.GetNewAddress == nil {
+	getNewAddress := cm.cfg.GetNewAddress
+	if blockRelayOnly && cm.cfg.GetBlockRelayOnlyAddress != nil {
+		getNewAddress = cm.cfg.GetBlockRelayOnlyAddress
+	}
+	if getNewAddress == nil {
 		return
 	}
 
-	c := &ConnReq{}
+	c := &ConnReq{BlockRelayOnly: blockRelayOnly}
 	atomic.StoreUint64(&c.id, atomic.AddUint64(&cm.connReqCount, 1))
 
 	// Submit a request of a pending connection attempt to the connection
@@ -388,7 +427,7 @@ func (cm *ConnManager) NewConnReq() {
 		return
 	}
 
-	addr, err := cm.cfg.GetNewAddress()
+	addr, err := getNewAddress()
 	if err != nil {
 		select {
 		case cm.requests <- handleFailed{c, err}:
@@ -521,6 +560,9 @@ func (cm *ConnManager) Start() {
 	for i := atomic.LoadUint64(&cm.connReqCount); i < uint64(cm.cfg.TargetOutbound); i++ {
 		go cm.NewConnReq()
 	}
+	for i := uint32(0); i < cm.cfg.TargetBlockRelayOnly; i++ {
+		go cm.newConnReq(true)
+	}
 }
 
 // Wait blocks until the connection manager halts gracefully.
@@ -216,6 +216,79 @@ func TestTargetOutbound(t *testing.T) {
 	cmgr.Stop()
 }
 
+// TestTargetBlockRelayOnly tests that the connection manager maintains the
+// target number of block-relay-only connections in addition to the other
+// outbound connections, using the addresses designated for them, and replaces
+// them when they are disconnected.
+func TestTargetBlockRelayOnly(t *testing.T) {
+	targetOutbound := uint32(4)
+	targetBlockRelayOnly := uint32(2)
+	connected := make(chan *ConnReq)
+	cmgr, err := New(&Config{
+		TargetOutbound:       targetOutbound,
+		TargetBlockRelayOnly: targetBlockRelayOnly,
+		Dial:                 mockDialer,
+		GetNewAddress: func() (net.Addr, error) {
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.1"),
+				Port: 18555,
+			}, nil
+		},
+		GetBlockRelayOnlyAddress: func() (net.Addr, error) {
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.2"),
+				Port: 18555,
+			}, nil
+		},
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+
+	var blockRelayOnly *ConnReq
+	var numBlockRelayOnly uint32
+	for i := uint32(0); i < targetOutbound+targetBlockRelayOnly; i++ {
+		c := <-connected
+		wantAddr := "127.0.0.1:18555"
+		if c.BlockRelayOnly {
+			blockRelayOnly = c
+			numBlockRelayOnly++
+			wantAddr = "127.0.0.2:18555"
+		}
+		if c.Addr.String() != wantAddr {
+			t.Fatalf("target block relay only: got address %v, "+
+				"want %v", c.Addr, wantAddr)
+		}
+	}
+	if numBlockRelayOnly != targetBlockRelayOnly {
+		t.Fatalf("target block relay only: got %d block-relay-only "+
+			"connections, want %d", numBlockRelayOnly,
+			targetBlockRelayOnly)
+	}
+
+	select {
+	case c := <-connected:
+		t.Fatalf("target block relay only: got unexpected connection "+
+			"- %v", c.Addr)
+	case <-time.After(time.Millisecond):
+		break
+	}
+
+	// A disconnected block-relay-only connection is replaced by another
+	// block-relay-only connection.
+	cmgr.Disconnect(blockRelayOnly.ID())
+	c := <-connected
+	if !c.BlockRelayOnly {
+		t.Fatalf("target block relay only: disconnected connection " +
+			"replaced by full relay connection")
+	}
+	cmgr.Stop()
+}
+
 // TestRetryPermanent tests that permanent connection requests are retried.
 //
 // We make a permanent connection request using Connect, disconnect it using
@@ -0,0 +1,184 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+	"time"
+)
+
+const (
+	// evictProtectNetGroups is the number of candidates protected from
+	// eviction by their keyed network group.
+	evictProtectNetGroups = 4
+
+	// evictProtectPing is the number of candidates with the lowest ping
+	// times protected from eviction.
+	evictProtectPing = 8
+
+	// evictProtectTx is the number of candidates which most recently
+	// relayed novel transactions protected from eviction.
+	evictProtectTx = 4
+
+	// evictProtectBlock is the number of candidates which most recently
+	// relayed novel blocks protected from eviction.
+	evictProtectBlock = 4
+)
+
+// EvictionCandidate describes an inbound peer which may be evicted to make
+// room for a new inbound peer once all inbound slots are in use.
+type EvictionCandidate struct {
+	// ID identifies the peer.
+	ID int32
+
+	// NetGroup is the network group of the address of the peer.
+	NetGroup string
+
+	// ConnTime is the time the peer connected.
+	ConnTime time.Time
+
+	// MinPing is the lowest ping time measured for the peer or zero when
+	// the ping time is not known yet.
+	MinPing time.Duration
+
+	// LastTxTime is the time the peer last relayed a transaction which was
+	// accepted into the memory pool.
+	LastTxTime time.Time
+
+	// LastBlockTime is the time the peer last relayed a block which was
+	// not known yet.
+	LastBlockTime time.Time
+
+	// RelayTxes is whether the peer wants transactions relayed to it.
+	RelayTxes bool
+}
+
+// keyedNetGroup returns the network group of the candidate keyed with the
+// provided seed.  Keying the groups prevents an attacker from predicting which
+// groups are protected from eviction.
+func keyedNetGroup(c *EvictionCandidate, seed uint64) uint64 {
+	var seedBytes [8]byte
+	binary.LittleEndian.PutUint64(seedBytes[:], seed)
+	h := fnv.New64a()
+	h.Write(seedBytes[:])
+	h.Write([]byte(c.NetGroup))
+	return h.Sum64()
+}
+
+// protectCandidates orders the candidates using the provided function, which
+// must return whether the first candidate is more deserving of protection
+// than the second, and removes the first n of them from eviction.
+func protectCandidates(candidates []EvictionCandidate, n int,
+	better func(a, b *EvictionCandidate) bool) []EvictionCandidate {
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return better(&candidates[i], &candidates[j])
+	})
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	return candidates[n:]
+}
+
+// SelectNodeToEvict selects the inbound peer to evict among the provided
+// candidates to make room for a new inbound peer.  The candidates passed must
+// not include peers which are exempt from eviction such as whitelisted peers.
+//
+// Several subsets of the candidates which would be hard for an attacker to
+// dominate are protected from eviction in turn: candidates from distinct
+// network groups, the ones with the lowest ping times, the ones which most
+// recently relayed novel transactions and blocks, and finally the half of the
+// remaining candidates which have been connected the longest.  The youngest
+// candidate of the network group with the most remaining candidates is then
+// selected.  It returns the ID of the selected candidate, or false when all
+// candidates are protected.
+//
+// The seed keys the network groups of the candidates and should be a random
+// value which is kept secret.
+func SelectNodeToEvict(candidates []EvictionCandidate, seed uint64) (int32, bool) {
+	remaining := make([]EvictionCandidate, len(candidates))
+	copy(remaining, candidates)
+
+	// Protect the candidates with the highest keyed network groups so an
+	// attacker can't take over every slot from a few network groups.
+	remaining = protectCandidates(remaining, evictProtectNetGroups,
+		func(a, b *EvictionCandidate) bool {
+			return keyedNetGroup(a, seed) > keyedNetGroup(b, seed)
+		})
+
+	// Protect the candidates with the lowest ping times since they are
+	// hard to emulate from far away.  Candidates without a ping time yet
+	// are the least deserving.
+	remaining = protectCandidates(remaining, evictProtectPing,
+		func(a, b *EvictionCandidate) bool {
+			if a.MinPing == 0 || b.MinPing == 0 {
+				return a.MinPing != 0 && b.MinPing == 0
+			}
+			return a.MinPing < b.MinPing
+		})
+
+	// Protect the candidates which most recently relayed novel
+	// transactions, preferring the ones which want transactions relayed
+	// among those which never did.
+	remaining = protectCandidates(remaining, evictProtectTx,
+		func(a, b *EvictionCandidate) bool {
+			if !a.LastTxTime.Equal(b.LastTxTime) {
+				return a.LastTxTime.After(b.LastTxTime)
+			}
+			return a.RelayTxes && !b.RelayTxes
+		})
+
+	// Protect the candidates which most recently relayed novel blocks.
+	remaining = protectCandidates(remaining, evictProtectBlock,
+		func(a, b *EvictionCandidate) bool {
+			return a.LastBlockTime.After(b.LastBlockTime)
+		})
+
+	// Protect the half of the remaining candidates which have been
+	// connected the longest.
+	remaining = protectCandidates(remaining, len(remaining)/2,
+		func(a, b *EvictionCandidate) bool {
+			return a.ConnTime.Before(b.ConnTime)
+		})
+
+	if len(remaining) == 0 {
+		return 0, false
+	}
+
+	// Group the remaining candidates by network group while keeping track
+	// of the youngest candidate of each group.
+	type group struct {
+		count    int
+		youngest *EvictionCandidate
+	}
+	groups := make(map[string]*group)
+	for i := range remaining {
+		c := &remaining[i]
+		g, ok := groups[c.NetGroup]
+		if !ok {
+			g = &group{youngest: c}
+			groups[c.NetGroup] = g
+		}
+		g.count++
+		if c.ConnTime.After(g.youngest.ConnTime) {
+			g.youngest = c
+		}
+	}
+
+	// Evict the youngest candidate of the largest group, breaking ties in
+	// favor of the group with the youngest candidate.
+	var largest *group
+	for _, g := range groups {
+		if largest == nil || g.count > largest.count ||
+			(g.count == largest.count &&
+				g.youngest.ConnTime.After(largest.youngest.ConnTime)) {
+
+			largest = g
+		}
+	}
+	return largest.youngest.ID, true
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// evictionCandidates returns the given number of synthetic candidates from the
+// same network group which connected one second apart in order of their IDs.
+func evictionCandidates(n int, netGroup string, firstID int32,
+	base time.Time) []EvictionCandidate {
+
+	candidates := make([]EvictionCandidate, n)
+	for i := range candidates {
+		id := firstID + int32(i)
+		candidates[i] = EvictionCandidate{
+			ID:       id,
+			NetGroup: netGroup,
+			ConnTime: base.Add(time.Duration(id) * time.Second),
+		}
+	}
+	return candidates
+}
+
+// TestSelectNodeToEvictNone ensures no candidate is selected when all of them
+// are protected.
+func TestSelectNodeToEvictNone(t *testing.T) {
+	base := time.Unix(1500000000, 0)
+	if _, ok := SelectNodeToEvict(nil, 0); ok {
+		t.Fatal("SelectNodeToEvict: selected candidate from none")
+	}
+	candidates := evictionCandidates(evictProtectNetGroups, "a", 0, base)
+	if id, ok := SelectNodeToEvict(candidates, 0); ok {
+		t.Fatalf("SelectNodeToEvict: selected protected candidate %d", id)
+	}
+}
+
+// TestSelectNodeToEvictProtected ensures the candidates which are the most
+// deserving by each protection criterion are never evicted even when they are
+// the youngest candidates.
+func TestSelectNodeToEvictProtected(t *testing.T) {
+	base := time.Unix(1500000000, 0)
+	tests := []struct {
+		name    string
+		protect int
+		modify  func(c *EvictionCandidate)
+	}{
+		{
+			name:    "lowest ping",
+			protect: evictProtectPing,
+			modify: func(c *EvictionCandidate) {
+				c.MinPing = time.Millisecond * time.Duration(c.ID)
+			},
+		},
+		{
+			name:    "recent transactions",
+			protect: evictProtectTx,
+			modify: func(c *EvictionCandidate) {
+				c.LastTxTime = base.Add(time.Hour)
+			},
+		},
+		{
+			name:    "recent blocks",
+			protect: evictProtectBlock,
+			modify: func(c *EvictionCandidate) {
+				c.LastBlockTime = base.Add(time.Hour)
+			},
+		},
+	}
+
+	const numCandidates = 40
+	for _, test := range tests {
+		candidates := evictionCandidates(numCandidates, "a", 0, base)
+		for i := numCandidates - test.protect; i < numCandidates; i++ {
+			test.modify(&candidates[i])
+		}
+
+		// The youngest candidates are protected, so the youngest of
+		// the others is evicted.
+		want := int32(numCandidates - test.protect - 1)
+		id, ok := SelectNodeToEvict(candidates, 0)
+		if !ok {
+			t.Errorf("%s: no candidate selected", test.name)
+			continue
+		}
+		if id != want {
+			t.Errorf("%s: selected candidate %d, want %d", test.name,
+				id, want)
+		}
+	}
+}
+
+// TestSelectNodeToEvictUptime ensures the candidates which have been connected
+// the longest are not evicted.
+func TestSelectNodeToEvictUptime(t *testing.T) {
+	base := time.Unix(1500000000, 0)
+
+	// Each candidate is in its own group, so only the protections decide
+	// which candidate is selected.  The other criteria protect 20 of the
+	// candidates, so half of the 20 remaining ones are protected for their
+	// uptime and at least 10 older candidates than the selected one are
+	// left.
+	const numCandidates = 40
+	candidates := make([]EvictionCandidate, 0, numCandidates)
+	for i := 0; i < numCandidates; i++ {
+		candidates = append(candidates, evictionCandidates(1,
+			fmt.Sprintf("group%d", i), int32(i), base)...)
+	}
+
+	for seed := uint64(0); seed < 20; seed++ {
+		id, ok := SelectNodeToEvict(candidates, seed)
+		if !ok {
+			t.Fatalf("seed %d: no candidate selected", seed)
+		}
+		if id < numCandidates/4 {
+			t.Fatalf("seed %d: selected candidate %d which is "+
+				"among the oldest", seed, id)
+		}
+	}
+}
+
+// TestSelectNodeToEvictNetGroup ensures a newcomer evicts the youngest peer
+// of the largest network group when an attacker fills the inbound slots from
+// a single network group.
+func TestSelectNodeToEvictNetGroup(t *testing.T) {
+	base := time.Unix(1500000000, 0)
+
+	// Honest peers from distinct groups which connected first and relay
+	// transactions and blocks.
+	const numHonest = 10
+	var candidates []EvictionCandidate
+	for i := 0; i < numHonest; i++ {
+		c := evictionCandidates(1, fmt.Sprintf("honest%d", i),
+			int32(i), base)[0]
+		c.MinPing = time.Duration(i+1) * time.Millisecond
+		c.LastTxTime = base.Add(time.Hour)
+		c.LastBlockTime = base.Add(time.Hour)
+		c.RelayTxes = true
+		candidates = append(candidates, c)
+	}
+
+	// Attacker peers which connected afterwards from a single group.
+	const numAttacker = 60
+	candidates = append(candidates, evictionCandidates(numAttacker,
+		"attacker", numHonest, base)...)
+
+	want := int32(numHonest + numAttacker - 1)
+	for seed := uint64(0); seed < 20; seed++ {
+		id, ok := SelectNodeToEvict(candidates, seed)
+		if !ok {
+			t.Fatalf("seed %d: no candidate selected", seed)
+		}
+		if id != want {
+			t.Fatalf("seed %d: selected candidate %d, want %d", seed,
+				id, want)
+		}
+	}
+
+	// The candidates passed are not modified.
+	for i, c := range candidates {
+		if c.ID != int32(i) {
+			t.Fatalf("candidate %d was reordered to %d", c.ID, i)
+		}
+	}
+}
@@ -5,9 +5,13 @@
 package connmgr
 
 import (
+	"encoding/base32"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"net"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -22,6 +26,16 @@ const (
 	torAddrNotSupported  = 0x08
 )
 
+const (
+	// torV2HostLen is the length of the base32 encoded host of a Tor v2
+	// onion address excluding the .onion suffix.
+	torV2HostLen = 16
+
+	// torV3HostLen is the length of the base32 encoded host of a Tor v3
+	// onion address excluding the .onion suffix.
+	torV3HostLen = 56
+)
+
 var (
 	// ErrTorInvalidAddressResponse indicates an invalid address was
 	// returned by the Tor DNS resolver.
@@ -31,6 +45,10 @@ var (
 	// response in an unexpected format.
 	ErrTorInvalidProxyResponse = errors.New("invalid proxy response")
 
+	// ErrTorInvalidOnionAddress indicates the provided address is not a
+	// valid Tor v2 or v3 onion address.
+	ErrTorInvalidOnionAddress = errors.New("invalid onion address")
+
 	// ErrTorUnrecognizedAuthMethod indicates the authentication method
 	// provided is not recognized.
 	ErrTorUnrecognizedAuthMethod = errors.New("invalid proxy authentication method")
@@ -127,3 +145,56 @@ func TorLookupIP(host, proxy string) ([]net.IP, error) {
 
 	return addr, nil
 }
+
+// OnionAddr implements the net.Addr interface and represents a Tor v2 or v3
+// onion address.  Onion addresses can't be resolved to an IP, so connections
+// to them are expected to be made through a Tor SOCKS proxy which resolves the
+// host itself.
+type OnionAddr struct {
+	Host string
+	Port int
+}
+
+// String returns the onion address in the form of 'host:port'.
+//
+// This is part of the net.Addr interface.
+func (oa *OnionAddr) String() string {
+	return net.JoinHostPort(oa.Host, strconv.Itoa(oa.Port))
+}
+
+// Network returns "onion".
+//
+// This is part of the net.Addr interface.
+func (oa *OnionAddr) Network() string {
+	return "onion"
+}
+
+// Ensure OnionAddr implements the net.Addr interface.
+var _ net.Addr = (*OnionAddr)(nil)
+
+// NewOnionAddr returns a new OnionAddr for the provided onion host and port.
+// ErrTorInvalidOnionAddress is returned when the host is not a Tor v2 or v3
+// onion address.
+func NewOnionAddr(host string, port int) (*OnionAddr, error) {
+	if !IsOnionHost(host) {
+		return nil, ErrTorInvalidOnionAddress
+	}
+	if port < 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid port %d", port)
+	}
+	return &OnionAddr{Host: host, Port: port}, nil
+}
+
+// IsOnionHost returns whether the provided host is a Tor v2 or v3 onion
+// address.
+func IsOnionHost(host string) bool {
+	if !strings.HasSuffix(host, ".onion") {
+		return false
+	}
+	name := strings.ToUpper(strings.TrimSuffix(host, ".onion"))
+	if len(name) != torV2HostLen && len(name) != torV3HostLen {
+		return false
+	}
+	_, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(name)
+	return err == nil
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import "testing"
+
+// TestOnionAddr ensures onion addresses are validated and formatted properly.
+func TestOnionAddr(t *testing.T) {
+	tests := []struct {
+		host  string
+		port  int
+		valid bool
+		want  string
+	}{
+		{
+			host:  "aaaaaaaaaaaaaaaa.onion",
+			port:  5556,
+			valid: true,
+			want:  "aaaaaaaaaaaaaaaa.onion:5556",
+		},
+		{
+			host:  "2gzyxa5ihm7nsggfxnu52rck2vv4rvmdlkiu3zzui5du4xyclen53wid.onion",
+			port:  5556,
+			valid: true,
+			want:  "2gzyxa5ihm7nsggfxnu52rck2vv4rvmdlkiu3zzui5du4xyclen53wid.onion:5556",
+		},
+		// Wrong length.
+		{host: "aaaaaaaaaaaaaaa.onion", port: 5556},
+		// Not base32.
+		{host: "aaaaaaaaaaaaaaa1.onion", port: 5556},
+		// Missing suffix.
+		{host: "aaaaaaaaaaaaaaaa", port: 5556},
+		// Invalid port.
+		{host: "aaaaaaaaaaaaaaaa.onion", port: 65536},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		addr, err := NewOnionAddr(test.host, test.port)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("NewOnionAddr #%d: expected error for %s",
+					i, test.host)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NewOnionAddr #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if addr.String() != test.want {
+			t.Errorf("NewOnionAddr #%d: got %s want %s", i,
+				addr.String(), test.want)
+		}
+		if addr.Network() != "onion" {
+			t.Errorf("NewOnionAddr #%d: got network %s want onion",
+				i, addr.Network())
+		}
+	}
+}
@@ -41,6 +41,10 @@ Application Options:
                             banning misbehaving peers.
       --whitelist=          Add an IP network or IP that will not be banned.
                             (eg. 192.168.1.0/24 or ::1)
+      --asmap=              File containing a map of IP addresses to autonomous
+                            system numbers used to diversify peers by the
+                            autonomous system announcing them instead of their
+                            /16 or /32
   -u, --rpcuser=            Username for RPC connections
   -P, --rpcpass=            Password for RPC connections
       --rpclimituser=       Username for limited RPC connections
@@ -73,9 +77,20 @@ Application Options:
       --noonion             Disable connecting to tor hidden services
       --torisolation        Enable Tor stream isolation by randomizing user
                             credentials for each connection.
+      --torbroadcast        Announce transactions submitted through the RPC
+                            server only to peers reached over Tor
+      --v2transport         Use the encrypted v2 transport protocol (BIP0324)
+                            for peer connections which support it
       --testnet             Use the test network
       --regtest             Use the regression test network
       --simnet              Use the simulation test network
+      --signetchallenge=    Use a signet test network whose blocks must be
+                            signed to satisfy this hex-encoded challenge script
+      --signetgenesis=      Hex-encoded genesis block of the signet test
+                            network -- defaults to the regression test
+                            genesis block
+      --signetkey=          WIF-encoded private key used to sign generated
+                            blocks on a signet test network
       --addcheckpoint=      Add a custom checkpoint.  Format: '<height>:<hash>'
       --nocheckpoints       Disable built-in checkpoints.  Don't do this unless
                             you know what you're doing.
@@ -100,17 +115,53 @@ Application Options:
                             high priority for relaying
       --maxorphantx=        Max number of orphan transactions to keep in memory
                             (100)
+      --mempoolexpiry=      Evict transactions and their descendants from the
+                            mempool once they have been unconfirmed for this
+                            long -- 0 to disable (336h0m0s)
+      --eventpub=           Add an interface/port or unix:<path> socket to
+                            publish mempool and block events on -- No events
+                            are published when none are specified
       --generate            Generate (mine) navcoins using the CPU
       --miningaddr=         Add the specified payment address to the list of
                             addresses to use for generated blocks -- At least
                             one address is required if the generate option is
                             set
+      --coinbasesplit=      Split the value of the coinbase of generated blocks
+                            across the specified address in proportion to its
+                            weight, given as address:weight -- May be specified
+                            up to 16 times and may be used instead of
+                            miningaddr
+      --coinbasetag=        Add the specified text to the coinbase of generated
+                            blocks in an OP_RETURN output
+      --stratum=            Add an interface/port to accept Stratum mining
+                            connections on -- At least one mining address is
+                            required if this option is set
+      --stratumdifficulty=  The initial share difficulty assigned to Stratum
+                            miners (1)
+      --stratummindifficulty= The lowest share difficulty assigned to Stratum
+                            miners, including through variable difficulty and
+                            mining.suggest_difficulty (1)
+      --stratumshareinterval= The time between shares Stratum miners are
+                            retargeted towards -- 0 to disable variable
+                            difficulty (15s)
       --blockminsize=       Mininum block size in bytes to be used when creating
                             a block
       --blockmaxsize=       Maximum block size in bytes to be used when creating
                             a block (750000)
       --blockprioritysize=  Size in bytes for high-priority/low-fee transactions
                             when creating a block (50000)
+      --blocktxselection=   Policy used to select transactions when creating a
+                            block {priority, feerate, ancestor} (priority)
+      --blockallowclass=    Only include transactions whose outputs are of the
+                            specified script class or pay an allowed address
+                            when creating a block
+      --blockdenyclass=     Do not include transactions with an output of the
+                            specified script class when creating a block
+      --blockallowaddr=     Only include transactions whose outputs pay the
+                            specified address or are of an allowed script class
+                            when creating a block
+      --blockdenyaddr=      Do not include transactions with an output paying
+                            the specified address when creating a block
       --nopeerbloomfilters  Disable bloom filtering support.
       --nocfilters          Disable committed filtering (CF) support.
       --sigcachemaxsize=    The maximum number of entries in the signature
@@ -0,0 +1,41 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package eventpub implements a publisher which streams transaction, block and
+mempool removal events to subscribers over TCP or Unix domain sockets.
+
+Publisher Overview
+
+External indexers often need to follow the memory pool and the main chain
+without polling the RPC server or maintaining a websocket per client.  The
+publisher accepts any number of subscribers on its listeners and writes every
+event to each of them as a length-prefixed frame.  Subscribers never send
+anything; they simply read frames until the connection is closed.
+
+Each frame is laid out as follows, with all integers in big endian:
+
+	uint32   number of bytes which follow
+	uint8    length of the topic
+	[]byte   topic
+	uint64   sequence number of the event within its topic
+	[]byte   payload
+
+The following topics are published:
+
+	rawtx      the serialized transaction accepted into the memory pool
+	hashtx     the 32-byte hash of that transaction
+	rawblock   the serialized block connected to the main chain
+	hashblock  the 32-byte hash of that block
+	removetx   the 32-byte hash of a transaction removed from the memory
+	           pool followed by the reason, one of confirmed, conflict,
+	           expiry, eviction, replaced or unknown
+
+Hashes are in the internal byte order, which is the reverse of their usual
+hex encoding.  Sequence numbers start at zero and increase by one for every
+event of a topic, whether or not any subscriber was connected at the time, so
+a subscriber can detect missed events by looking for gaps.  A subscriber that
+falls too far behind is disconnected rather than slowing down the node.
+*/
+package eventpub
@@ -0,0 +1,318 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package eventpub
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/navcoin/navutil"
+)
+
+// These constants define the topics events are published under.
+const (
+	TopicRawTx     = "rawtx"
+	TopicHashTx    = "hashtx"
+	TopicRawBlock  = "rawblock"
+	TopicHashBlock = "hashblock"
+	TopicRemoveTx  = "removetx"
+)
+
+const (
+	// DefaultClientQueueSize is the default number of frames which may be
+	// queued for a subscriber before it is disconnected as too slow.
+	DefaultClientQueueSize = 1000
+
+	// MaxFrameSize is the maximum number of bytes following the length
+	// prefix of a frame which ReadMessage will accept.
+	MaxFrameSize = 32 * 1024 * 1024
+)
+
+// Config is a descriptor containing the publisher configuration.
+type Config struct {
+	// Listeners defines a slice of listeners on which the publisher will
+	// accept subscribers.
+	Listeners []net.Listener
+
+	// ClientQueueSize is the number of frames which may be queued for a
+	// subscriber before it is disconnected.  DefaultClientQueueSize is
+	// used when it is zero.
+	ClientQueueSize int
+}
+
+// Message is a single event read from a publisher by ReadMessage.
+type Message struct {
+	Topic    string
+	Sequence uint64
+	Payload  []byte
+}
+
+// client houses the state of a single subscriber.
+type client struct {
+	conn  net.Conn
+	queue chan []byte
+	quit  chan struct{}
+	once  sync.Once
+}
+
+// disconnect closes the connection to the subscriber.  It is safe to call
+// multiple times.
+func (c *client) disconnect() {
+	c.once.Do(func() {
+		close(c.quit)
+		c.conn.Close()
+	})
+}
+
+// Publisher streams transaction, block and mempool removal events to
+// subscribers.  Publishing never blocks on subscribers.  It is safe for
+// concurrent access.
+type Publisher struct {
+	started  int32
+	shutdown int32
+
+	cfg       Config
+	mtx       sync.Mutex
+	clients   map[*client]struct{}
+	sequences map[string]uint64
+	quit      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// New returns a new publisher which accepts subscribers on the listeners in
+// the passed config once started.
+func New(cfg *Config) *Publisher {
+	p := &Publisher{
+		cfg:       *cfg,
+		clients:   make(map[*client]struct{}),
+		sequences: make(map[string]uint64),
+		quit:      make(chan struct{}),
+	}
+	if p.cfg.ClientQueueSize <= 0 {
+		p.cfg.ClientQueueSize = DefaultClientQueueSize
+	}
+	return p
+}
+
+// Start begins accepting subscribers on all listeners.
+func (p *Publisher) Start() {
+	// Already started?
+	if atomic.AddInt32(&p.started, 1) != 1 {
+		return
+	}
+
+	log.Trace("Starting event publisher")
+	for _, listener := range p.cfg.Listeners {
+		p.wg.Add(1)
+		go p.listenHandler(listener)
+	}
+}
+
+// Stop closes all listeners and disconnects all subscribers.
+func (p *Publisher) Stop() error {
+	// Make sure this only happens once.
+	if atomic.AddInt32(&p.shutdown, 1) != 1 {
+		log.Infof("Event publisher is already in the process of " +
+			"shutting down")
+		return nil
+	}
+
+	log.Infof("Event publisher shutting down")
+	close(p.quit)
+	for _, listener := range p.cfg.Listeners {
+		listener.Close()
+	}
+
+	p.mtx.Lock()
+	for c := range p.clients {
+		c.disconnect()
+	}
+	p.mtx.Unlock()
+
+	p.wg.Wait()
+	return nil
+}
+
+// NumClients returns the number of connected subscribers.
+func (p *Publisher) NumClients() int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return len(p.clients)
+}
+
+// listenHandler accepts subscribers on the passed listener until the publisher
+// is stopped.  It must be run as a goroutine.
+func (p *Publisher) listenHandler(listener net.Listener) {
+	log.Infof("Event publisher listening on %s", listener.Addr())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			// Only log the error if not forcibly shutting down.
+			if atomic.LoadInt32(&p.shutdown) == 0 {
+				log.Errorf("Can't accept subscriber: %v", err)
+			}
+			break
+		}
+
+		c := &client{
+			conn:  conn,
+			queue: make(chan []byte, p.cfg.ClientQueueSize),
+			quit:  make(chan struct{}),
+		}
+		p.mtx.Lock()
+		if atomic.LoadInt32(&p.shutdown) != 0 {
+			p.mtx.Unlock()
+			conn.Close()
+			break
+		}
+		p.clients[c] = struct{}{}
+		p.mtx.Unlock()
+
+		log.Debugf("New event subscriber %s", conn.RemoteAddr())
+		p.wg.Add(1)
+		go p.clientHandler(c)
+	}
+	p.wg.Done()
+}
+
+// clientHandler writes queued frames to a subscriber until it disconnects or
+// the publisher is stopped.  It must be run as a goroutine.
+func (p *Publisher) clientHandler(c *client) {
+out:
+	for {
+		select {
+		case frame := <-c.queue:
+			if _, err := c.conn.Write(frame); err != nil {
+				log.Debugf("Unable to write to event subscriber "+
+					"%s: %v", c.conn.RemoteAddr(), err)
+				break out
+			}
+
+		case <-c.quit:
+			break out
+		}
+	}
+
+	c.disconnect()
+	p.mtx.Lock()
+	delete(p.clients, c)
+	p.mtx.Unlock()
+	log.Debugf("Event subscriber %s disconnected", c.conn.RemoteAddr())
+	p.wg.Done()
+}
+
+// publish assigns the next sequence number of the topic to the event and
+// queues it for every subscriber.  The payload function is only invoked when
+// there are subscribers to send the event to.  Subscribers whose queue is
+// full are disconnected.
+func (p *Publisher) publish(topic string, payload func() []byte) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	seq := p.sequences[topic]
+	p.sequences[topic] = seq + 1
+	if len(p.clients) == 0 {
+		return
+	}
+
+	frame := encodeFrame(topic, seq, payload())
+	for c := range p.clients {
+		select {
+		case c.queue <- frame:
+		default:
+			log.Warnf("Disconnecting event subscriber %s which is "+
+				"too slow", c.conn.RemoteAddr())
+			c.disconnect()
+		}
+	}
+}
+
+// PublishTx publishes the rawtx and hashtx events for a transaction accepted
+// into the memory pool.
+func (p *Publisher) PublishTx(tx *navutil.Tx) {
+	p.publish(TopicRawTx, func() []byte {
+		var buf bytes.Buffer
+		buf.Grow(tx.MsgTx().SerializeSize())
+		if err := tx.MsgTx().Serialize(&buf); err != nil {
+			log.Errorf("Unable to serialize transaction %v: %v",
+				tx.Hash(), err)
+		}
+		return buf.Bytes()
+	})
+	p.publish(TopicHashTx, func() []byte {
+		return tx.Hash()[:]
+	})
+}
+
+// PublishBlock publishes the rawblock and hashblock events for a block
+// connected to the main chain.
+func (p *Publisher) PublishBlock(block *navutil.Block) {
+	p.publish(TopicRawBlock, func() []byte {
+		serialized, err := block.Bytes()
+		if err != nil {
+			log.Errorf("Unable to serialize block %v: %v",
+				block.Hash(), err)
+		}
+		return serialized
+	})
+	p.publish(TopicHashBlock, func() []byte {
+		return block.Hash()[:]
+	})
+}
+
+// PublishTxRemoval publishes the removetx event for a transaction removed
+// from the memory pool for the given reason.
+func (p *Publisher) PublishTxRemoval(tx *navutil.Tx, reason string) {
+	p.publish(TopicRemoveTx, func() []byte {
+		payload := make([]byte, 0, len(tx.Hash())+len(reason))
+		payload = append(payload, tx.Hash()[:]...)
+		return append(payload, reason...)
+	})
+}
+
+// encodeFrame returns the length-prefixed frame for an event.
+func encodeFrame(topic string, seq uint64, payload []byte) []byte {
+	size := 1 + len(topic) + 8 + len(payload)
+	frame := make([]byte, 4+size)
+	binary.BigEndian.PutUint32(frame[0:4], uint32(size))
+	frame[4] = uint8(len(topic))
+	offset := 5 + copy(frame[5:], topic)
+	binary.BigEndian.PutUint64(frame[offset:], seq)
+	copy(frame[offset+8:], payload)
+	return frame
+}
+
+// ReadMessage reads a single event frame written by a publisher from r.
+func ReadMessage(r io.Reader) (*Message, error) {
+	var size uint32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if size > MaxFrameSize {
+		return nil, fmt.Errorf("frame size %d exceeds max of %d", size,
+			MaxFrameSize)
+	}
+
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	if len(frame) < 1 || len(frame) < 1+int(frame[0])+8 {
+		return nil, errors.New("frame is too short")
+	}
+	topicEnd := 1 + int(frame[0])
+	return &Message{
+		Topic:    string(frame[1:topicEnd]),
+		Sequence: binary.BigEndian.Uint64(frame[topicEnd : topicEnd+8]),
+		Payload:  frame[topicEnd+8:],
+	}, nil
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package eventpub
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/navcoin/navd/wire"
+	"github.com/navcoin/navutil"
+)
+
+// newTestPublisher returns a started publisher listening on a random local
+// port along with a connected subscriber.
+func newTestPublisher(t *testing.T, queueSize int) (*Publisher, net.Conn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	p := New(&Config{
+		Listeners:       []net.Listener{listener},
+		ClientQueueSize: queueSize,
+	})
+	p.Start()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		p.Stop()
+		t.Fatalf("unable to connect: %v", err)
+	}
+
+	// Wait for the publisher to register the subscriber so no events are
+	// missed.
+	for i := 0; p.NumClients() == 0; i++ {
+		if i == 100 {
+			conn.Close()
+			p.Stop()
+			t.Fatal("subscriber was not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return p, conn
+}
+
+// TestPublisher ensures events are delivered to subscribers with the expected
+// topics, payloads and per-topic sequence numbers.
+func TestPublisher(t *testing.T) {
+	p, conn := newTestPublisher(t, 0)
+	defer p.Stop()
+	defer conn.Close()
+
+	msgTx := wire.NewMsgTx(wire.TxVersion)
+	msgTx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+	tx := navutil.NewTx(msgTx)
+	var rawTx bytes.Buffer
+	if err := msgTx.Serialize(&rawTx); err != nil {
+		t.Fatalf("unable to serialize tx: %v", err)
+	}
+
+	block := navutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{msgTx},
+	})
+	rawBlock, err := block.Bytes()
+	if err != nil {
+		t.Fatalf("unable to serialize block: %v", err)
+	}
+
+	p.PublishTx(tx)
+	p.PublishBlock(block)
+	p.PublishTxRemoval(tx, "confirmed")
+	p.PublishTx(tx)
+
+	tests := []Message{
+		{Topic: TopicRawTx, Sequence: 0, Payload: rawTx.Bytes()},
+		{Topic: TopicHashTx, Sequence: 0, Payload: tx.Hash()[:]},
+		{Topic: TopicRawBlock, Sequence: 0, Payload: rawBlock},
+		{Topic: TopicHashBlock, Sequence: 0, Payload: block.Hash()[:]},
+		{Topic: TopicRemoveTx, Sequence: 0,
+			Payload: append(tx.Hash().CloneBytes(), "confirmed"...)},
+		{Topic: TopicRawTx, Sequence: 1, Payload: rawTx.Bytes()},
+		{Topic: TopicHashTx, Sequence: 1, Payload: tx.Hash()[:]},
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for i, want := range tests {
+		msg, err := ReadMessage(conn)
+		if err != nil {
+			t.Fatalf("#%d: unable to read message: %v", i, err)
+		}
+		if msg.Topic != want.Topic || msg.Sequence != want.Sequence {
+			t.Fatalf("#%d: got topic %q seq %d, want topic %q seq %d",
+				i, msg.Topic, msg.Sequence, want.Topic,
+				want.Sequence)
+		}
+		if !bytes.Equal(msg.Payload, want.Payload) {
+			t.Fatalf("#%d: got payload %x, want %x", i, msg.Payload,
+				want.Payload)
+		}
+	}
+}
+
+// TestPublisherSequenceGap ensures sequence numbers advance while nobody is
+// subscribed so that subscribers can detect missed events.
+func TestPublisherSequenceGap(t *testing.T) {
+	p := New(&Config{})
+	tx := navutil.NewTx(wire.NewMsgTx(wire.TxVersion))
+	p.PublishTxRemoval(tx, "expiry")
+	p.PublishTxRemoval(tx, "expiry")
+	if seq := p.sequences[TopicRemoveTx]; seq != 2 {
+		t.Fatalf("got next sequence %d, want 2", seq)
+	}
+	if seq := p.sequences[TopicRawTx]; seq != 0 {
+		t.Fatalf("got next rawtx sequence %d, want 0", seq)
+	}
+}
+
+// TestPublisherSlowClient ensures a subscriber which does not keep up is
+// disconnected instead of blocking the publisher.
+func TestPublisherSlowClient(t *testing.T) {
+	p, conn := newTestPublisher(t, 1)
+	defer p.Stop()
+	defer conn.Close()
+
+	// Publish far more data than the subscriber queue and socket buffers
+	// can hold without reading anything.
+	msgTx := wire.NewMsgTx(wire.TxVersion)
+	msgTx.AddTxOut(wire.NewTxOut(1000, make([]byte, 64*1024)))
+	tx := navutil.NewTx(msgTx)
+	for i := 0; i < 1000 && p.NumClients() != 0; i++ {
+		p.PublishTx(tx)
+	}
+
+	for i := 0; p.NumClients() != 0; i++ {
+		if i == 100 {
+			t.Fatal("slow subscriber was not disconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -5,6 +5,8 @@
 package rpctest
 
 import (
+	"bytes"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -395,6 +397,37 @@ func (h *Harness) P2PAddress() string {
 	return h.node.config.listen
 }
 
+// GenerateToAddress generates numBlocks blocks paying to the passed address
+// using the generatetoaddress RPC of the running node and returns their hashes.
+//
+// This function is safe for concurrent access.
+func (h *Harness) GenerateToAddress(numBlocks uint32,
+	addr navutil.Address) ([]*chainhash.Hash, error) {
+
+	return h.Node.GenerateToAddress(numBlocks, addr)
+}
+
+// GenerateBlock generates a block containing exactly the passed transactions,
+// in order, with a coinbase paying to the passed address using the
+// generateblock RPC of the running node and returns its hash.  Unlike
+// GenerateAndSubmitBlock, the block is created and solved by the node itself.
+//
+// This function is safe for concurrent access.
+func (h *Harness) GenerateBlock(addr navutil.Address,
+	txns []*navutil.Tx) (*chainhash.Hash, error) {
+
+	rawTxns := make([]string, 0, len(txns))
+	for _, tx := range txns {
+		var buf bytes.Buffer
+		if err := tx.MsgTx().Serialize(&buf); err != nil {
+			return nil, err
+		}
+		rawTxns = append(rawTxns, hex.EncodeToString(buf.Bytes()))
+	}
+
+	return h.Node.GenerateBlock(addr, rawTxns)
+}
+
 // GenerateAndSubmitBlock creates a block whose contents include the passed
 // transactions and submits it to the running simnet node. For generating
 // blocks with only a coinbase tx, callers can simply pass nil instead of
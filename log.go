@@ -17,9 +17,11 @@ import (
 	"github.com/navcoin/navd/blockchain/indexers"
 	"github.com/navcoin/navd/connmgr"
 	"github.com/navcoin/navd/database"
+	"github.com/navcoin/navd/eventpub"
 	"github.com/navcoin/navd/mempool"
 	"github.com/navcoin/navd/mining"
 	"github.com/navcoin/navd/mining/cpuminer"
+	"github.com/navcoin/navd/mining/stratum"
 	"github.com/navcoin/navd/peer"
 	"github.com/navcoin/navd/txscript"
 
@@ -62,6 +64,7 @@ var (
 	navdLog = backendLog.Logger("BTCD")
 	chanLog = backendLog.Logger("CHAN")
 	discLog = backendLog.Logger("DISC")
+	evpbLog = backendLog.Logger("EVPB")
 	indxLog = backendLog.Logger("INDX")
 	minrLog = backendLog.Logger("MINR")
 	peerLog = backendLog.Logger("PEER")
@@ -76,11 +79,13 @@ var (
 func init() {
 	addrmgr.UseLogger(amgrLog)
 	connmgr.UseLogger(cmgrLog)
+	eventpub.UseLogger(evpbLog)
 	database.UseLogger(bcdbLog)
 	blockchain.UseLogger(chanLog)
 	indexers.UseLogger(indxLog)
 	mining.UseLogger(minrLog)
 	cpuminer.UseLogger(minrLog)
+	stratum.UseLogger(minrLog)
 	peer.UseLogger(peerLog)
 	txscript.UseLogger(scrpLog)
 	netsync.UseLogger(syncLog)
@@ -96,6 +101,7 @@ var subsystemLoggers = map[string]navlog.Logger{
 	"NAVD": navdLog,
 	"CHAN": chanLog,
 	"DISC": discLog,
+	"EVPB": evpbLog,
 	"INDX": indxLog,
 	"MINR": minrLog,
 	"PEER": peerLog,
@@ -35,6 +35,10 @@ func (e RuleError) Error() string {
 type TxRuleError struct {
 	RejectCode  wire.RejectCode // The code to send with reject messages
 	Description string          // Human readable description of the issue
+
+	// witnessIndependent is set when the violation does not depend on the
+	// witness data of the transaction.
+	witnessIndependent bool
 }
 
 // Error satisfies the error interface and prints human-readable errors.
@@ -50,6 +54,19 @@ func txRuleError(c wire.RejectCode, desc string) RuleError {
 	}
 }
 
+// witnessIndependentRuleError creates an underlying TxRuleError with the given
+// set of arguments for a violation which does not depend on the witness data of
+// the transaction and returns a RuleError that encapsulates it.
+func witnessIndependentRuleError(c wire.RejectCode, desc string) RuleError {
+	return RuleError{
+		Err: TxRuleError{
+			RejectCode:         c,
+			Description:        desc,
+			witnessIndependent: true,
+		},
+	}
+}
+
 // chainRuleError returns a RuleError that encapsulates the given
 // blockchain.RuleError.
 func chainRuleError(chainErr blockchain.RuleError) RuleError {
@@ -131,3 +148,30 @@ func ErrToRejectErr(err error) (wire.RejectCode, string) {
 	// text.
 	return wire.RejectInvalid, "rejected: " + err.Error()
 }
+
+// IsWitnessIndependent returns whether the given error, which caused the
+// rejection of a transaction, does not depend on the witness data of the
+// transaction.  Every transaction with the same hash is rejected for the same
+// reason in that case, regardless of its witness data.
+func IsWitnessIndependent(err error) bool {
+	// Pull the underlying error out of a RuleError.
+	if rerr, ok := err.(RuleError); ok {
+		err = rerr.Err
+	}
+
+	switch err := err.(type) {
+	case blockchain.RuleError:
+		// The outputs spent by the transaction, their values and the lock
+		// times are all committed to by the transaction hash.
+		switch err.ErrorCode {
+		case blockchain.ErrImmatureSpend, blockchain.ErrSpendTooHigh,
+			blockchain.ErrBadTxOutValue, blockchain.ErrUnfinalizedTx:
+			return true
+		}
+
+	case TxRuleError:
+		return err.witnessIndependent
+	}
+
+	return false
+}
@@ -178,6 +178,14 @@ type FeeEstimator struct {
 	// Transactions that have been removed from the bins. This allows us to
 	// revert in case of an orphaned block.
 	dropped []*registeredBlock
+
+	// The state of the bucketed estimator used by EstimateSmartFee.  The
+	// horizons hold decayed confirmation statistics per fee rate bucket
+	// and tracked houses the observed transactions which have not been
+	// mined yet.  Since the statistics are decayed averages they are not
+	// reverted by Rollback.
+	horizons [numFeeHorizons]*confirmStats
+	tracked  map[chainhash.Hash]*trackedTx
 }
 
 // NewFeeEstimator creates a FeeEstimator for which at most maxRollback blocks
@@ -192,6 +200,8 @@ func NewFeeEstimator(maxRollback, minRegisteredBlocks uint32) *FeeEstimator {
 		maxReplacements:     estimateFeeMaxReplacements,
 		observed:            make(map[chainhash.Hash]*observedTransaction),
 		dropped:             make([]*registeredBlock, 0, maxRollback),
+		horizons:            newHorizonStats(),
+		tracked:             make(map[chainhash.Hash]*trackedTx),
 	}
 }
 
@@ -217,6 +227,8 @@ func (ef *FeeEstimator) ObserveTransaction(t *TxDesc) {
 			mined:    mining.UnminedHeight,
 		}
 	}
+	ef.trackTransaction(&hash, t.Height,
+		SatoshiPerByte(float64(t.FeePerKB)/1000))
 }
 
 // RegisterBlock informs the fee estimator of a new block to take into account.
@@ -243,6 +255,9 @@ func (ef *FeeEstimator) RegisterBlock(block *navutil.Block) error {
 		transactions[t] = struct{}{}
 	}
 
+	// Update the bucketed estimator with the txs it has been tracking.
+	ef.registerBlockStats(height, block.Transactions())
+
 	// Count the number of replacements we make per bin so that we don't
 	// replace too many.
 	var replacementCounts [estimateFeeDepth]int
@@ -577,8 +592,16 @@ func (ef *FeeEstimator) EstimateFee(numBlocks uint32) (BtcPerKilobyte, error) {
 // In case the format for the serialized version of the FeeEstimator changes,
 // we use a version number. If the version number changes, it does not make
 // sense to try to upgrade a previous version to a new version. Instead, just
-// start fee estimation over.
-const estimateFeeSaveVersion = 1
+// start fee estimation over.  The only exception is version 1, which predates
+// the bucketed estimator and is upgraded by starting the bucketed estimator
+// over.
+const (
+	estimateFeeSaveVersion = 2
+
+	// estimateFeeSaveVersionNoBuckets is the version of the states saved
+	// before the bucketed estimator was added.
+	estimateFeeSaveVersionNoBuckets = 1
+)
 
 func deserializeRegisteredBlock(r io.Reader, txs map[uint32]*observedTransaction) (*registeredBlock, error) {
 	var lenTransactions uint32
@@ -671,6 +694,9 @@ func (ef *FeeEstimator) Save() FeeEstimatorState {
 		registered.serialize(w, observed)
 	}
 
+	// State of the bucketed estimator.
+	ef.serializeBucketStats(w)
+
 	// Commit the tx and return.
 	return FeeEstimatorState(w.Bytes())
 }
@@ -686,7 +712,9 @@ func RestoreFeeEstimator(data FeeEstimatorState) (*FeeEstimator, error) {
 	if err != nil {
 		return nil, err
 	}
-	if version != estimateFeeSaveVersion {
+	if version != estimateFeeSaveVersion &&
+		version != estimateFeeSaveVersionNoBuckets {
+
 		return nil, fmt.Errorf("Incorrect version: expected %d found %d", estimateFeeSaveVersion, version)
 	}
 
@@ -745,5 +773,20 @@ func RestoreFeeEstimator(data FeeEstimatorState) (*FeeEstimator, error) {
 		}
 	}
 
+	// States saved before the bucketed estimator was added don't include
+	// its state, so it starts over.
+	if version == estimateFeeSaveVersionNoBuckets {
+		log.Infof("Upgraded the saved fee estimator state from version "+
+			"%d, smart fee estimates start over", version)
+		ef.horizons = newHorizonStats()
+		ef.tracked = make(map[chainhash.Hash]*trackedTx)
+		return ef, nil
+	}
+
+	// Read the state of the bucketed estimator.
+	if err := ef.deserializeBucketStats(r); err != nil {
+		return nil, err
+	}
+
 	return ef, nil
 }
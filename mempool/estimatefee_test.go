@@ -6,6 +6,8 @@ package mempool
 
 import (
 	"bytes"
+	"encoding/binary"
+	"math"
 	"math/rand"
 	"testing"
 
@@ -26,6 +28,8 @@ func newTestFeeEstimator(binSize, maxReplacements, maxRollback uint32) *FeeEstim
 		maxReplacements:     int32(maxReplacements),
 		observed:            make(map[chainhash.Hash]*observedTransaction),
 		dropped:             make([]*registeredBlock, 0, maxRollback),
+		horizons:            newHorizonStats(),
+		tracked:             make(map[chainhash.Hash]*trackedTx),
 	}
 }
 
@@ -422,3 +426,127 @@ func TestDatabase(t *testing.T) {
 		eft.checkSaveAndRestore(estimateHistory[len(estimateHistory)-round-1])
 	}
 }
+
+// TestEstimateSmartFee tests that the bucketed estimator only recommends fee
+// rates which have reliably confirmed and that its state survives a save and
+// restore.
+func TestEstimateSmartFee(t *testing.T) {
+	ef := NewFeeEstimator(DefaultEstimateFeeMaxRollback,
+		DefaultEstimateFeeMinRegisteredBlocks)
+	eft := estimateFeeTester{ef: ef, t: t}
+	eft.newBlock(nil)
+
+	// No estimate can be given before enough blocks have been seen.
+	_, _, err := ef.EstimateSmartFee(2, EstimateModeEconomical)
+	if err == nil {
+		t.Fatal("EstimateSmartFee: expected error with no data")
+	}
+
+	// Every block, observe some high fee txs which are mined in the next
+	// block and some low fee txs which are never mined.
+	const highFeePerKB, lowFeePerKB = 50000, 2000
+	for i := 0; i < 100; i++ {
+		var mined []*wire.MsgTx
+		for j := 0; j < 5; j++ {
+			high := eft.testTx(0)
+			high.FeePerKB = highFeePerKB
+			ef.ObserveTransaction(high)
+			mined = append(mined, high.Tx.MsgTx())
+
+			low := eft.testTx(0)
+			low.FeePerKB = lowFeePerKB
+			ef.ObserveTransaction(low)
+		}
+		eft.newBlock(mined)
+	}
+
+	wantRate := SatoshiPerByte(highFeePerKB / 1000).ToBtcPerKb()
+	for _, mode := range []EstimateMode{EstimateModeConservative,
+		EstimateModeEconomical} {
+
+		rate, blocks, err := ef.EstimateSmartFee(1, mode)
+		if err != nil {
+			t.Fatalf("EstimateSmartFee(%v): unexpected error: %v",
+				mode, err)
+		}
+		if blocks != 2 {
+			t.Errorf("EstimateSmartFee(%v): got target %d, want 2",
+				mode, blocks)
+		}
+		if math.Abs(float64(rate-wantRate)) > 1e-12 {
+			t.Errorf("EstimateSmartFee(%v): got rate %v, want %v",
+				mode, rate, wantRate)
+		}
+	}
+
+	// Targets beyond the observed history are capped.
+	_, blocks, err := ef.EstimateSmartFee(1000, EstimateModeEconomical)
+	if err != nil {
+		t.Fatalf("EstimateSmartFee: unexpected error: %v", err)
+	}
+	if want := ef.numBlocksRegistered / 2; blocks != want {
+		t.Errorf("EstimateSmartFee: got target %d, want %d", blocks,
+			want)
+	}
+
+	// The estimate is unchanged after a save and restore.
+	restored, err := RestoreFeeEstimator(ef.Save())
+	if err != nil {
+		t.Fatalf("RestoreFeeEstimator: unexpected error: %v", err)
+	}
+	rate, _, err := restored.EstimateSmartFee(2, EstimateModeConservative)
+	if err != nil {
+		t.Fatalf("EstimateSmartFee: unexpected error after restore: %v",
+			err)
+	}
+	if math.Abs(float64(rate-wantRate)) > 1e-12 {
+		t.Errorf("EstimateSmartFee: got rate %v after restore, want %v",
+			rate, wantRate)
+	}
+}
+
+// TestRestoreFeeEstimatorNoBuckets tests that states saved before the bucketed
+// estimator was added are restored with an empty bucketed estimator.
+func TestRestoreFeeEstimatorNoBuckets(t *testing.T) {
+	ef := NewFeeEstimator(DefaultEstimateFeeMaxRollback,
+		DefaultEstimateFeeMinRegisteredBlocks)
+	eft := estimateFeeTester{ef: ef, t: t}
+	eft.newBlock(nil)
+	for i := 0; i < 5; i++ {
+		tx := eft.testTx(50000)
+		ef.ObserveTransaction(tx)
+		eft.newBlock([]*wire.MsgTx{tx.Tx.MsgTx()})
+	}
+
+	// Recreate the state of the previous version by removing the state
+	// of the bucketed estimator.
+	var buckets bytes.Buffer
+	ef.serializeBucketStats(&buckets)
+	state := ef.Save()
+	state = append(FeeEstimatorState(nil), state[:len(state)-buckets.Len()]...)
+	binary.BigEndian.PutUint32(state, estimateFeeSaveVersionNoBuckets)
+
+	restored, err := RestoreFeeEstimator(state)
+	if err != nil {
+		t.Fatalf("RestoreFeeEstimator: unexpected error: %v", err)
+	}
+	if restored.LastKnownHeight() != ef.LastKnownHeight() {
+		t.Fatalf("RestoreFeeEstimator: got height %d, want %d",
+			restored.LastKnownHeight(), ef.LastKnownHeight())
+	}
+	if len(restored.tracked) != 0 {
+		t.Fatalf("RestoreFeeEstimator: got %d tracked transactions, "+
+			"want none", len(restored.tracked))
+	}
+
+	// The upgraded state is saved in the current version.
+	saved := restored.Save()
+	if version := binary.BigEndian.Uint32(saved); version != estimateFeeSaveVersion {
+		t.Fatalf("Save: got version %d, want %d", version,
+			estimateFeeSaveVersion)
+	}
+	if _, err := RestoreFeeEstimator(saved); err != nil {
+		t.Fatalf("RestoreFeeEstimator: unexpected error after "+
+			"upgrade: %v", err)
+	}
+}
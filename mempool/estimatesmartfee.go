@@ -0,0 +1,625 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/encrypt-s/navd/chaincfg/chainhash"
+	"github.com/navcoin/navutil"
+)
+
+const (
+	// minBucketFeeRate is the upper bound of the lowest fee rate bucket
+	// tracked by the bucketed estimator.  Transactions paying less than
+	// this are grouped in the lowest bucket.
+	minBucketFeeRate = SatoshiPerByte(1)
+
+	// maxBucketFeeRate is the upper bound of the highest finite fee rate
+	// bucket.  Transactions paying more than this are grouped in a final
+	// unbounded bucket.
+	maxBucketFeeRate = SatoshiPerByte(10000)
+
+	// feeBucketSpacing is the ratio between the upper bounds of two
+	// adjacent fee rate buckets.
+	feeBucketSpacing = 1.1
+
+	// sufficientFeeTxs is the minimum decayed number of transactions per
+	// block that a range of buckets must hold for the medium and long
+	// horizons to produce an estimate from it.
+	sufficientFeeTxs = 0.1
+
+	// sufficientTxsShort is the equivalent of sufficientFeeTxs for the
+	// short horizon, which decays faster and so requires more recent
+	// data.
+	sufficientTxsShort = 0.5
+
+	// halfSuccessPct, successPct and doubleSuccessPct are the fractions of
+	// transactions which must have confirmed within half, exactly and
+	// double the requested target respectively for a fee rate to pass.
+	halfSuccessPct   = 0.6
+	successPct       = 0.85
+	doubleSuccessPct = 0.95
+)
+
+// EstimateMode selects how cautious EstimateSmartFee is with its answers.
+type EstimateMode int
+
+const (
+	// EstimateModeConservative favours fee rates that have succeeded over
+	// longer horizons, which makes the estimate less responsive to short
+	// term drops in demand.
+	EstimateModeConservative EstimateMode = iota
+
+	// EstimateModeEconomical favours the most recent data, which produces
+	// lower estimates when demand has recently fallen.
+	EstimateModeEconomical
+)
+
+// String returns the EstimateMode as a human-readable name.
+func (m EstimateMode) String() string {
+	switch m {
+	case EstimateModeConservative:
+		return "CONSERVATIVE"
+	case EstimateModeEconomical:
+		return "ECONOMICAL"
+	}
+	return fmt.Sprintf("Unknown EstimateMode (%d)", int(m))
+}
+
+// feeHorizon identifies one of the time horizons tracked by the bucketed
+// estimator.
+type feeHorizon int
+
+const (
+	shortHorizon feeHorizon = iota
+	mediumHorizon
+	longHorizon
+	numFeeHorizons
+)
+
+// horizonParams houses the number of tracked confirmation periods, the number
+// of blocks in each period and the per-block decay for every horizon.  The
+// maximum confirmation target of a horizon is periods * scale blocks.
+var horizonParams = [numFeeHorizons]struct {
+	periods uint32
+	scale   uint32
+	decay   float64
+}{
+	shortHorizon:  {periods: 12, scale: 1, decay: 0.962},
+	mediumHorizon: {periods: 24, scale: 2, decay: 0.9952},
+	longHorizon:   {periods: 42, scale: 24, decay: 0.99931},
+}
+
+// feeRateBuckets contains the upper bound of every fee rate bucket in
+// ascending order.  The final bucket is unbounded.
+var feeRateBuckets = func() []SatoshiPerByte {
+	var buckets []SatoshiPerByte
+	for rate := minBucketFeeRate; rate <= maxBucketFeeRate; rate *= feeBucketSpacing {
+		buckets = append(buckets, rate)
+	}
+	return append(buckets, SatoshiPerByte(math.Inf(1)))
+}()
+
+// feeRateBucket returns the index of the bucket the passed fee rate belongs
+// to.
+func feeRateBucket(rate SatoshiPerByte) int {
+	return sort.Search(len(feeRateBuckets), func(i int) bool {
+		return feeRateBuckets[i] >= rate
+	})
+}
+
+// trackedTx is a transaction observed in the mempool which the bucketed
+// estimator is waiting to see confirmed.
+type trackedTx struct {
+	// The block height when it was observed.
+	height int32
+
+	// The fee per byte of the transaction in satoshis.
+	feeRate SatoshiPerByte
+
+	// The fee rate bucket it belongs to.
+	bucket uint32
+}
+
+// confirmStats tracks exponentially decaying confirmation statistics for each
+// fee rate bucket over a single horizon.
+type confirmStats struct {
+	periods uint32
+	scale   uint32
+	decay   float64
+
+	// txCtAvg is the decayed number of confirmed transactions per bucket
+	// and feeRateSum the decayed sum of their fee rates.
+	txCtAvg    []float64
+	feeRateSum []float64
+
+	// confAvg[p][b] is the decayed number of transactions in bucket b
+	// which confirmed within (p+1)*scale blocks.
+	confAvg [][]float64
+
+	// failAvg[p][b] is the decayed number of transactions in bucket b
+	// which left the mempool unconfirmed after at least (p+1)*scale
+	// blocks.
+	failAvg [][]float64
+}
+
+// newConfirmStats returns empty confirmation statistics for the given
+// horizon.
+func newConfirmStats(h feeHorizon) *confirmStats {
+	params := horizonParams[h]
+	numBuckets := len(feeRateBuckets)
+	s := &confirmStats{
+		periods:    params.periods,
+		scale:      params.scale,
+		decay:      params.decay,
+		txCtAvg:    make([]float64, numBuckets),
+		feeRateSum: make([]float64, numBuckets),
+		confAvg:    make([][]float64, params.periods),
+		failAvg:    make([][]float64, params.periods),
+	}
+	for p := uint32(0); p < params.periods; p++ {
+		s.confAvg[p] = make([]float64, numBuckets)
+		s.failAvg[p] = make([]float64, numBuckets)
+	}
+	return s
+}
+
+// maxConfirms returns the largest confirmation target in blocks which can be
+// estimated from the statistics.
+func (s *confirmStats) maxConfirms() uint32 {
+	return s.periods * s.scale
+}
+
+// decayAll applies one block worth of decay to every statistic.
+func (s *confirmStats) decayAll() {
+	for b := range s.txCtAvg {
+		s.txCtAvg[b] *= s.decay
+		s.feeRateSum[b] *= s.decay
+		for p := uint32(0); p < s.periods; p++ {
+			s.confAvg[p][b] *= s.decay
+			s.failAvg[p][b] *= s.decay
+		}
+	}
+}
+
+// recordConfirmed records a transaction in the given bucket which confirmed
+// after the given number of blocks.
+func (s *confirmStats) recordConfirmed(blocksToConfirm uint32, bucket int, rate SatoshiPerByte) {
+	if blocksToConfirm < 1 {
+		blocksToConfirm = 1
+	}
+	periodsToConfirm := (blocksToConfirm + s.scale - 1) / s.scale
+	for p := periodsToConfirm - 1; p < s.periods; p++ {
+		s.confAvg[p][bucket]++
+	}
+	s.txCtAvg[bucket]++
+	s.feeRateSum[bucket] += float64(rate)
+}
+
+// recordFailed records a transaction in the given bucket which left the
+// mempool unconfirmed after the given number of blocks.
+func (s *confirmStats) recordFailed(blocksAgo uint32, bucket int) {
+	for p := uint32(0); p < s.periods && blocksAgo >= (p+1)*s.scale; p++ {
+		s.failAvg[p][bucket]++
+	}
+}
+
+// estimateMedianVal returns the median fee rate of the lowest range of
+// buckets in which at least successBreakPoint of the transactions confirmed
+// within confTarget blocks.  Ranges are grown from the highest fee rate down
+// until they hold enough transactions as determined by sufficientTxVal.
+// unconfirmed holds, per bucket, the number of tracked transactions which
+// have already been waiting at least confTarget blocks; they are counted as
+// failures.  It returns -1 when no range passes.
+func (s *confirmStats) estimateMedianVal(confTarget uint32, sufficientTxVal,
+	successBreakPoint float64, unconfirmed []float64) SatoshiPerByte {
+
+	if confTarget == 0 || confTarget > s.maxConfirms() {
+		return -1
+	}
+	period := (confTarget+s.scale-1)/s.scale - 1
+	sufficient := sufficientTxVal / (1 - s.decay)
+
+	var nConf, totalNum, failNum, extraNum float64
+	found := false
+	curNear, curFar := len(feeRateBuckets)-1, len(feeRateBuckets)-1
+	bestNear, bestFar := curNear, curFar
+	newRange := true
+	for b := len(feeRateBuckets) - 1; b >= 0; b-- {
+		if newRange {
+			curNear = b
+			newRange = false
+		}
+		curFar = b
+		nConf += s.confAvg[period][b]
+		totalNum += s.txCtAvg[b]
+		failNum += s.failAvg[period][b]
+		extraNum += unconfirmed[b]
+
+		// Keep growing the range until there is enough data to
+		// evaluate it.
+		if totalNum < sufficient {
+			continue
+		}
+
+		// Once a range no longer confirms at the required rate, keep
+		// accumulating lower buckets into it in case the wider range
+		// passes as a whole.
+		curPct := nConf / (totalNum + failNum + extraNum)
+		if curPct < successBreakPoint {
+			continue
+		}
+
+		found = true
+		bestNear, bestFar = curNear, curFar
+		nConf, totalNum, failNum, extraNum = 0, 0, 0, 0
+		newRange = true
+	}
+	if !found {
+		return -1
+	}
+
+	// Find the bucket holding the median transaction of the best range
+	// and return the average fee rate of that bucket.
+	var txSum float64
+	for b := bestFar; b <= bestNear; b++ {
+		txSum += s.txCtAvg[b]
+	}
+	if txSum == 0 {
+		return -1
+	}
+	var runningSum float64
+	for b := bestFar; b <= bestNear; b++ {
+		runningSum += s.txCtAvg[b]
+		if runningSum >= txSum/2 && s.txCtAvg[b] > 0 {
+			return SatoshiPerByte(s.feeRateSum[b] / s.txCtAvg[b])
+		}
+	}
+	return -1
+}
+
+// serialize writes the statistics to w.
+func (s *confirmStats) serialize(w io.Writer) {
+	binary.Write(w, binary.BigEndian, s.txCtAvg)
+	binary.Write(w, binary.BigEndian, s.feeRateSum)
+	for p := uint32(0); p < s.periods; p++ {
+		binary.Write(w, binary.BigEndian, s.confAvg[p])
+		binary.Write(w, binary.BigEndian, s.failAvg[p])
+	}
+}
+
+// deserializeConfirmStats reads the statistics of the given horizon which
+// were previously written by serialize.
+func deserializeConfirmStats(r io.Reader, h feeHorizon) (*confirmStats, error) {
+	s := newConfirmStats(h)
+	if err := binary.Read(r, binary.BigEndian, s.txCtAvg); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.BigEndian, s.feeRateSum); err != nil {
+		return nil, err
+	}
+	for p := uint32(0); p < s.periods; p++ {
+		if err := binary.Read(r, binary.BigEndian, s.confAvg[p]); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.BigEndian, s.failAvg[p]); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// newHorizonStats returns empty confirmation statistics for every horizon.
+func newHorizonStats() [numFeeHorizons]*confirmStats {
+	var stats [numFeeHorizons]*confirmStats
+	for h := feeHorizon(0); h < numFeeHorizons; h++ {
+		stats[h] = newConfirmStats(h)
+	}
+	return stats
+}
+
+// trackTransaction starts tracking a transaction observed in the mempool at
+// the given height.
+//
+// This function MUST be called with the fee estimator lock held (for writes).
+func (ef *FeeEstimator) trackTransaction(hash *chainhash.Hash, height int32, rate SatoshiPerByte) {
+	if _, ok := ef.tracked[*hash]; ok {
+		return
+	}
+	ef.tracked[*hash] = &trackedTx{
+		height:  height,
+		feeRate: rate,
+		bucket:  uint32(feeRateBucket(rate)),
+	}
+}
+
+// registerBlockStats updates the bucketed statistics with the transactions
+// mined in a block at the given height.
+//
+// This function MUST be called with the fee estimator lock held (for writes).
+func (ef *FeeEstimator) registerBlockStats(height int32, txns []*navutil.Tx) {
+	for _, stats := range ef.horizons {
+		stats.decayAll()
+	}
+
+	for _, tx := range txns {
+		t, ok := ef.tracked[*tx.Hash()]
+		if !ok {
+			continue
+		}
+		delete(ef.tracked, *tx.Hash())
+
+		if height <= t.height {
+			continue
+		}
+		blocksToConfirm := uint32(height - t.height)
+		for _, stats := range ef.horizons {
+			stats.recordConfirmed(blocksToConfirm, int(t.bucket),
+				t.feeRate)
+		}
+	}
+
+	// Transactions which have been waiting for longer than the longest
+	// horizon can no longer affect any estimate while tracked, so record
+	// them as failures and stop tracking them.
+	maxConfirms := ef.horizons[longHorizon].maxConfirms()
+	for hash, t := range ef.tracked {
+		blocksAgo := height - t.height
+		if blocksAgo > int32(maxConfirms) {
+			ef.failTracked(&hash, uint32(blocksAgo))
+		}
+	}
+}
+
+// failTracked records a tracked transaction which left the mempool without
+// being mined after the given number of blocks and stops tracking it.
+//
+// This function MUST be called with the fee estimator lock held (for writes).
+func (ef *FeeEstimator) failTracked(hash *chainhash.Hash, blocksAgo uint32) {
+	t, ok := ef.tracked[*hash]
+	if !ok {
+		return
+	}
+	delete(ef.tracked, *hash)
+	for _, stats := range ef.horizons {
+		stats.recordFailed(blocksAgo, int(t.bucket))
+	}
+}
+
+// RemoveTransaction informs the fee estimator that a transaction has left the
+// mempool without being mined, for example because it conflicted with a mined
+// transaction or expired.  Such transactions count against the success rate
+// of their fee rate bucket.
+func (ef *FeeEstimator) RemoveTransaction(hash *chainhash.Hash) {
+	ef.mtx.Lock()
+	defer ef.mtx.Unlock()
+
+	t, ok := ef.tracked[*hash]
+	if !ok {
+		return
+	}
+	var blocksAgo uint32
+	if ef.lastKnownHeight > t.height {
+		blocksAgo = uint32(ef.lastKnownHeight - t.height)
+	}
+	ef.failTracked(hash, blocksAgo)
+}
+
+// unconfirmedCounts returns, per bucket, the number of tracked transactions
+// which have been waiting at least confTarget blocks.
+//
+// This function MUST be called with the fee estimator lock held (for reads).
+func (ef *FeeEstimator) unconfirmedCounts(confTarget uint32) []float64 {
+	counts := make([]float64, len(feeRateBuckets))
+	for _, t := range ef.tracked {
+		if ef.lastKnownHeight-t.height >= int32(confTarget) {
+			counts[t.bucket]++
+		}
+	}
+	return counts
+}
+
+// horizonEstimate returns the estimate of a single horizon for the given
+// target and success threshold.
+//
+// This function MUST be called with the fee estimator lock held (for reads).
+func (ef *FeeEstimator) horizonEstimate(h feeHorizon, confTarget uint32, threshold float64) SatoshiPerByte {
+	sufficient := sufficientFeeTxs
+	if h == shortHorizon {
+		sufficient = sufficientTxsShort
+	}
+	return ef.horizons[h].estimateMedianVal(confTarget, sufficient,
+		threshold, ef.unconfirmedCounts(confTarget))
+}
+
+// estimateCombinedFee returns the estimate for the given target and success
+// threshold from the shortest horizon able to answer it.  When
+// checkShorterHorizon is set, a lower estimate for the maximum target of a
+// shorter horizon is preferred since it is based on more recent data.
+//
+// This function MUST be called with the fee estimator lock held (for reads).
+func (ef *FeeEstimator) estimateCombinedFee(confTarget uint32, threshold float64, checkShorterHorizon bool) SatoshiPerByte {
+	h := longHorizon
+	switch {
+	case confTarget <= ef.horizons[shortHorizon].maxConfirms():
+		h = shortHorizon
+	case confTarget <= ef.horizons[mediumHorizon].maxConfirms():
+		h = mediumHorizon
+	}
+	estimate := ef.horizonEstimate(h, confTarget, threshold)
+
+	if checkShorterHorizon {
+		for shorter := h - 1; shorter >= shortHorizon; shorter-- {
+			maxTarget := ef.horizons[shorter].maxConfirms()
+			shorterEst := ef.horizonEstimate(shorter, maxTarget, threshold)
+			if shorterEst > 0 && (estimate == -1 || shorterEst < estimate) {
+				estimate = shorterEst
+			}
+		}
+	}
+	return estimate
+}
+
+// estimateConservativeFee returns the highest of the medium and long horizon
+// estimates for the given target at the strictest success threshold.
+//
+// This function MUST be called with the fee estimator lock held (for reads).
+func (ef *FeeEstimator) estimateConservativeFee(doubleTarget uint32) SatoshiPerByte {
+	estimate := SatoshiPerByte(-1)
+	if doubleTarget <= ef.horizons[shortHorizon].maxConfirms() {
+		estimate = ef.horizonEstimate(mediumHorizon, doubleTarget,
+			doubleSuccessPct)
+	}
+	if doubleTarget <= ef.horizons[mediumHorizon].maxConfirms() {
+		longEst := ef.horizonEstimate(longHorizon, doubleTarget,
+			doubleSuccessPct)
+		if longEst > estimate {
+			estimate = longEst
+		}
+	}
+	return estimate
+}
+
+// maxUsableEstimate returns the largest target an estimate can currently be
+// given for, which is limited by the number of blocks registered.
+//
+// This function MUST be called with the fee estimator lock held (for reads).
+func (ef *FeeEstimator) maxUsableEstimate() uint32 {
+	maxTarget := ef.numBlocksRegistered / 2
+	if longMax := ef.horizons[longHorizon].maxConfirms(); maxTarget > longMax {
+		maxTarget = longMax
+	}
+	return maxTarget
+}
+
+// EstimateSmartFee estimates the fee rate needed for a transaction to begin
+// confirmation within confTarget blocks with high confidence, based on the
+// success rates of each fee rate bucket over the short, medium and long
+// horizons.  The target actually used, which may be lower than the one
+// requested when not enough blocks have been observed yet, is returned along
+// with the estimate.
+func (ef *FeeEstimator) EstimateSmartFee(confTarget uint32, mode EstimateMode) (BtcPerKilobyte, uint32, error) {
+	ef.mtx.Lock()
+	defer ef.mtx.Unlock()
+
+	if confTarget == 0 {
+		return -1, 0, errors.New("cannot confirm transaction in zero blocks")
+	}
+
+	// A target of one block is not meaningful since no estimate can
+	// guarantee inclusion in the very next block.
+	if confTarget == 1 {
+		confTarget = 2
+	}
+	if maxTarget := ef.maxUsableEstimate(); confTarget > maxTarget {
+		confTarget = maxTarget
+	}
+	if confTarget <= 1 {
+		return -1, 0, errors.New("not enough blocks have been observed")
+	}
+
+	conservative := mode == EstimateModeConservative
+	estimate := ef.estimateCombinedFee(confTarget/2, halfSuccessPct, true)
+	if actual := ef.estimateCombinedFee(confTarget, successPct, true); actual > estimate {
+		estimate = actual
+	}
+	if double := ef.estimateCombinedFee(2*confTarget, doubleSuccessPct,
+		!conservative); double > estimate {
+		estimate = double
+	}
+	if conservative || estimate == -1 {
+		if cons := ef.estimateConservativeFee(2 * confTarget); cons > estimate {
+			estimate = cons
+		}
+	}
+	if estimate < 0 {
+		return -1, confTarget, errors.New("insufficient data or no " +
+			"feerate found")
+	}
+
+	return estimate.ToBtcPerKb(), confTarget, nil
+}
+
+// serializeBucketStats writes the bucketed estimator state to w.
+//
+// This function MUST be called with the fee estimator lock held (for reads).
+func (ef *FeeEstimator) serializeBucketStats(w io.Writer) {
+	binary.Write(w, binary.BigEndian, uint32(len(feeRateBuckets)))
+	for _, stats := range ef.horizons {
+		stats.serialize(w)
+	}
+
+	// Write the tracked transactions sorted by hash so that a serialized
+	// state always comes out the same.
+	hashes := make([]chainhash.Hash, 0, len(ef.tracked))
+	for hash := range ef.tracked {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i][:], hashes[j][:]) < 0
+	})
+	binary.Write(w, binary.BigEndian, uint32(len(hashes)))
+	for i := range hashes {
+		t := ef.tracked[hashes[i]]
+		binary.Write(w, binary.BigEndian, hashes[i])
+		binary.Write(w, binary.BigEndian, t.height)
+		binary.Write(w, binary.BigEndian, t.feeRate)
+		binary.Write(w, binary.BigEndian, t.bucket)
+	}
+}
+
+// deserializeBucketStats restores the bucketed estimator state previously
+// written by serializeBucketStats.
+func (ef *FeeEstimator) deserializeBucketStats(r io.Reader) error {
+	var numBuckets uint32
+	if err := binary.Read(r, binary.BigEndian, &numBuckets); err != nil {
+		return err
+	}
+	if numBuckets != uint32(len(feeRateBuckets)) {
+		return fmt.Errorf("Incorrect number of fee rate buckets: "+
+			"expected %d found %d", len(feeRateBuckets), numBuckets)
+	}
+	for h := feeHorizon(0); h < numFeeHorizons; h++ {
+		stats, err := deserializeConfirmStats(r, h)
+		if err != nil {
+			return err
+		}
+		ef.horizons[h] = stats
+	}
+
+	var numTracked uint32
+	if err := binary.Read(r, binary.BigEndian, &numTracked); err != nil {
+		return err
+	}
+	ef.tracked = make(map[chainhash.Hash]*trackedTx, numTracked)
+	for i := uint32(0); i < numTracked; i++ {
+		var hash chainhash.Hash
+		t := &trackedTx{}
+		if err := binary.Read(r, binary.BigEndian, &hash); err != nil {
+			return err
+		}
+		if err := binary.Read(r, binary.BigEndian, &t.height); err != nil {
+			return err
+		}
+		if err := binary.Read(r, binary.BigEndian, &t.feeRate); err != nil {
+			return err
+		}
+		if err := binary.Read(r, binary.BigEndian, &t.bucket); err != nil {
+			return err
+		}
+		if t.bucket >= numBuckets {
+			return fmt.Errorf("Invalid fee rate bucket %d", t.bucket)
+		}
+		ef.tracked[hash] = t
+	}
+	return nil
+}
@@ -5,9 +5,13 @@
 package mempool
 
 import (
+	"bytes"
 	"container/list"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"math"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -38,6 +42,16 @@ const (
 	// orphanExpireScanInterval is the minimum amount of time in between
 	// scans of the orphan pool to evict expired transactions.
 	orphanExpireScanInterval = time.Minute * 5
+
+	// feeDeltasSaveVersion is the version of the serialized fee deltas
+	// produced by SaveFeeDeltas.
+	feeDeltasSaveVersion = 1
+)
+
+var (
+	// FeeDeltasDatabaseKey is the key that we use to store the fee deltas
+	// applied via PrioritiseTransaction in the database.
+	FeeDeltasDatabaseKey = []byte("mempoolfeedeltas")
 )
 
 // Tag represents an identifier to use for tagging orphan transactions.  The
@@ -93,6 +107,68 @@ type Config struct {
 	// FeeEstimatator provides a feeEstimator. If it is not nil, the mempool
 	// records all new transactions it observes into the feeEstimator.
 	FeeEstimator *FeeEstimator
+
+	// TxAccepted, if not nil, is invoked whenever a transaction is added
+	// to the pool.  It is called with the pool lock held, so it must not
+	// block or call back into the pool.
+	TxAccepted func(txD *TxDesc)
+
+	// TxRemoved, if not nil, is invoked whenever a transaction leaves the
+	// pool along with the reason it was removed.  It is called with the
+	// pool lock held, so it must not block or call back into the pool.
+	TxRemoved func(tx *navutil.Tx, reason RemovalReason)
+}
+
+// RemovalReason describes why a transaction was removed from the pool.
+type RemovalReason int
+
+// These constants define the reasons a transaction may be removed from the
+// pool.
+const (
+	// RemovalConfirmed indicates the transaction was included in a block
+	// connected to the main chain.
+	RemovalConfirmed RemovalReason = iota
+
+	// RemovalConflict indicates the transaction, or one of its ancestors,
+	// spent an output which was also spent by a transaction in a block,
+	// or otherwise became invalid after a change of the main chain.
+	RemovalConflict
+
+	// RemovalExpiry indicates the transaction, or one of its ancestors,
+	// stayed in the pool for longer than the TxExpiry policy allows.
+	RemovalExpiry
+
+	// RemovalEviction indicates the transaction was evicted to make room
+	// for other transactions.
+	RemovalEviction
+
+	// RemovalReplaced indicates the transaction was replaced by another
+	// transaction spending the same outputs.
+	RemovalReplaced
+
+	// RemovalUnknown indicates the transaction was removed for a reason
+	// which is none of the above, such as when a caller discards a
+	// transaction it can't make use of.
+	RemovalUnknown
+)
+
+// Map of RemovalReason values back to their constant names for pretty
+// printing.
+var removalReasonStrings = map[RemovalReason]string{
+	RemovalConfirmed: "confirmed",
+	RemovalConflict:  "conflict",
+	RemovalExpiry:    "expiry",
+	RemovalEviction:  "eviction",
+	RemovalReplaced:  "replaced",
+	RemovalUnknown:   "unknown",
+}
+
+// String returns the RemovalReason in human-readable form.
+func (r RemovalReason) String() string {
+	if s, ok := removalReasonStrings[r]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown RemovalReason (%d)", int(r))
 }
 
 // Policy houses the policy (configuration parameters) which is used to
@@ -133,6 +209,12 @@ type Policy struct {
 	// MinRelayTxFee defines the minimum transaction fee in BTC/kB to be
 	// considered a non-zero fee.
 	MinRelayTxFee navutil.Amount
+
+	// TxExpiry is the maximum amount of time a transaction is allowed to
+	// stay in the pool before it, along with any transactions which
+	// depend on it, is evicted by ExpireTransactions.  A value of zero
+	// disables expiry.
+	TxExpiry time.Duration
 }
 
 // TxDesc is a descriptor containing a transaction in the mempool along with
@@ -164,12 +246,19 @@ type TxPool struct {
 	mtx           sync.RWMutex
 	cfg           Config
 	pool          map[chainhash.Hash]*TxDesc
+	wtxids        map[chainhash.Hash]chainhash.Hash // wtxid to hash in pool
 	orphans       map[chainhash.Hash]*orphanTx
 	orphansByPrev map[wire.OutPoint]map[chainhash.Hash]*navutil.Tx
 	outpoints     map[wire.OutPoint]*navutil.Tx
 	pennyTotal    float64 // exponentially decaying total for penny spends.
 	lastPennyUnix int64   // unix time of last ``penny spend''
 
+	// feeDeltas houses the fee adjustments made via PrioritiseTransaction
+	// keyed by transaction hash.  Entries may refer to transactions which
+	// are not in the pool yet, in which case the delta is applied once the
+	// transaction is accepted.
+	feeDeltas map[chainhash.Hash]int64
+
 	// nextExpireScan is the time after which the orphan pool will be
 	// scanned in order to evict orphans.  This is NOT a hard deadline as
 	// the scan will only run when an orphan is added to the pool as opposed
@@ -401,6 +490,28 @@ func (mp *TxPool) IsTransactionInPool(hash *chainhash.Hash) bool {
 	return inPool
 }
 
+// HaveTransactionByWTxID returns whether or not the transaction with the passed
+// witness hash (wtxid) already exists in the main pool or in the orphan pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) HaveTransactionByWTxID(wtxid *chainhash.Hash) bool {
+	// Protect concurrent access.
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	if _, exists := mp.wtxids[*wtxid]; exists {
+		return true
+	}
+
+	// The orphan pool is small, so it is simply scanned.
+	for _, otx := range mp.orphans {
+		if otx.tx.WitnessHash().IsEqual(wtxid) {
+			return true
+		}
+	}
+	return false
+}
+
 // isOrphanInPool returns whether or not the passed transaction already exists
 // in the orphan pool.
 //
@@ -451,14 +562,14 @@ func (mp *TxPool) HaveTransaction(hash *chainhash.Hash) bool {
 // RemoveTransaction.  See the comment for RemoveTransaction for more details.
 //
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) removeTransaction(tx *navutil.Tx, removeRedeemers bool) {
+func (mp *TxPool) removeTransaction(tx *navutil.Tx, removeRedeemers bool, reason RemovalReason) {
 	txHash := tx.Hash()
 	if removeRedeemers {
 		// Remove any transactions which rely on this one.
 		for i := uint32(0); i < uint32(len(tx.MsgTx().TxOut)); i++ {
 			prevOut := wire.OutPoint{Hash: *txHash, Index: i}
 			if txRedeemer, exists := mp.outpoints[prevOut]; exists {
-				mp.removeTransaction(txRedeemer, true)
+				mp.removeTransaction(txRedeemer, true, reason)
 			}
 		}
 	}
@@ -476,20 +587,27 @@ func (mp *TxPool) removeTransaction(tx *navutil.Tx, removeRedeemers bool) {
 			delete(mp.outpoints, txIn.PreviousOutPoint)
 		}
 		delete(mp.pool, *txHash)
+		delete(mp.wtxids, *tx.WitnessHash())
+		delete(mp.feeDeltas, *txHash)
 		atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+
+		if mp.cfg.TxRemoved != nil {
+			mp.cfg.TxRemoved(tx, reason)
+		}
 	}
 }
 
 // RemoveTransaction removes the passed transaction from the mempool. When the
 // removeRedeemers flag is set, any transactions that redeem outputs from the
 // removed transaction will also be removed recursively from the mempool, as
-// they would otherwise become orphans.
+// they would otherwise become orphans.  The reason is reported to the
+// TxRemoved callback for every removed transaction.
 //
 // This function is safe for concurrent access.
-func (mp *TxPool) RemoveTransaction(tx *navutil.Tx, removeRedeemers bool) {
+func (mp *TxPool) RemoveTransaction(tx *navutil.Tx, removeRedeemers bool, reason RemovalReason) {
 	// Protect concurrent access.
 	mp.mtx.Lock()
-	mp.removeTransaction(tx, removeRedeemers)
+	mp.removeTransaction(tx, removeRedeemers, reason)
 	mp.mtx.Unlock()
 }
 
@@ -503,36 +621,127 @@ func (mp *TxPool) RemoveTransaction(tx *navutil.Tx, removeRedeemers bool) {
 func (mp *TxPool) RemoveDoubleSpends(tx *navutil.Tx) {
 	// Protect concurrent access.
 	mp.mtx.Lock()
+	var removed []*navutil.Tx
+	seen := make(map[chainhash.Hash]struct{})
 	for _, txIn := range tx.MsgTx().TxIn {
 		if txRedeemer, ok := mp.outpoints[txIn.PreviousOutPoint]; ok {
 			if !txRedeemer.Hash().IsEqual(tx.Hash()) {
-				mp.removeTransaction(txRedeemer, true)
+				removed = mp.appendWithDescendants(removed,
+					txRedeemer, seen)
 			}
 		}
 	}
+	for _, txRemoved := range removed {
+		mp.removeTransaction(txRemoved, false, RemovalConflict)
+	}
 	mp.mtx.Unlock()
+
+	// The removed transactions were never mined, so they count against
+	// the success rate of their fee rate in the fee estimator.
+	mp.notifyFeeEstimatorRemoved(removed)
 }
 
-// addTransaction adds the passed transaction to the memory pool.  It should
-// not be called directly as it doesn't perform any validation.  This is a
-// helper for maybeAcceptTransaction.
+// ExpireTransactions evicts all transactions which have been in the pool for
+// longer than the TxExpiry policy allows, along with any transactions which
+// redeem their outputs since those would otherwise become orphans.  All of
+// the removed transactions are returned.  Nothing is evicted when TxExpiry is
+// zero.
 //
-// This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *navutil.Tx, height int32, fee int64) *TxDesc {
-	// Add the transaction to the pool and mark the referenced outpoints
-	// as spent by the pool.
-	txD := &TxDesc{
+// This function is safe for concurrent access.
+func (mp *TxPool) ExpireTransactions() []*navutil.Tx {
+	if mp.cfg.Policy.TxExpiry <= 0 {
+		return nil
+	}
+
+	// Protect concurrent access.
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	cutoff := time.Now().Add(-mp.cfg.Policy.TxExpiry)
+	var expired []*navutil.Tx
+	seen := make(map[chainhash.Hash]struct{})
+	for _, txDesc := range mp.pool {
+		if !txDesc.Added.Before(cutoff) {
+			continue
+		}
+		expired = mp.appendWithDescendants(expired, txDesc.Tx, seen)
+	}
+	for _, tx := range expired {
+		mp.removeTransaction(tx, false, RemovalExpiry)
+	}
+	mp.notifyFeeEstimatorRemoved(expired)
+
+	if numExpired := len(expired); numExpired > 0 {
+		log.Debugf("Expired %d %s (remaining: %d)", numExpired,
+			pickNoun(numExpired, "transaction", "transactions"),
+			len(mp.pool))
+	}
+
+	return expired
+}
+
+// notifyFeeEstimatorRemoved informs the fee estimator, if any, that the passed
+// transactions left the pool without being mined.
+func (mp *TxPool) notifyFeeEstimatorRemoved(txns []*navutil.Tx) {
+	if mp.cfg.FeeEstimator == nil {
+		return
+	}
+	for _, tx := range txns {
+		mp.cfg.FeeEstimator.RemoveTransaction(tx.Hash())
+	}
+}
+
+// appendWithDescendants appends the passed transaction followed by all pool
+// transactions which redeem its outputs, recursively, to txns.  Transactions
+// already present in seen are skipped.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) appendWithDescendants(txns []*navutil.Tx, tx *navutil.Tx, seen map[chainhash.Hash]struct{}) []*navutil.Tx {
+	txHash := tx.Hash()
+	if _, ok := seen[*txHash]; ok {
+		return txns
+	}
+	seen[*txHash] = struct{}{}
+	txns = append(txns, tx)
+
+	for i := uint32(0); i < uint32(len(tx.MsgTx().TxOut)); i++ {
+		prevOut := wire.OutPoint{Hash: *txHash, Index: i}
+		if txRedeemer, exists := mp.outpoints[prevOut]; exists {
+			txns = mp.appendWithDescendants(txns, txRedeemer, seen)
+		}
+	}
+	return txns
+}
+
+// newTxDesc returns a descriptor for the passed transaction as it would be
+// added to the memory pool at the given height with the given fee.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) newTxDesc(utxoView *blockchain.UtxoViewpoint, tx *navutil.Tx, height int32, fee int64) *TxDesc {
+	return &TxDesc{
 		TxDesc: mining.TxDesc{
 			Tx:       tx,
 			Added:    time.Now(),
 			Height:   height,
 			Fee:      fee,
 			FeePerKB: fee * 1000 / GetTxVirtualSize(tx),
+			FeeDelta: mp.feeDeltas[*tx.Hash()],
 		},
 		StartingPriority: mining.CalcPriority(tx.MsgTx(), utxoView, height),
 	}
+}
 
+// addTransaction adds the passed transaction to the memory pool.  It should
+// not be called directly as it doesn't perform any validation.  This is a
+// helper for maybeAcceptTransaction.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *navutil.Tx, height int32, fee int64) *TxDesc {
+	// Add the transaction to the pool and mark the referenced outpoints
+	// as spent by the pool.
+	txD := mp.newTxDesc(utxoView, tx, height, fee)
 	mp.pool[*tx.Hash()] = txD
+	mp.wtxids[*tx.WitnessHash()] = *tx.Hash()
 	for _, txIn := range tx.MsgTx().TxIn {
 		mp.outpoints[txIn.PreviousOutPoint] = tx
 	}
@@ -549,6 +758,10 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *navutil
 		mp.cfg.FeeEstimator.ObserveTransaction(txD)
 	}
 
+	if mp.cfg.TxAccepted != nil {
+		mp.cfg.TxAccepted(txD)
+	}
+
 	return txD
 }
 
@@ -614,12 +827,39 @@ func (mp *TxPool) FetchTransaction(txHash *chainhash.Hash) (*navutil.Tx, error)
 	return nil, fmt.Errorf("transaction is not in the pool")
 }
 
+// FetchTransactionByWTxID returns the transaction with the passed witness hash
+// (wtxid) from the transaction pool.  This only fetches from the main
+// transaction pool and does not include orphans.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) FetchTransactionByWTxID(wtxid *chainhash.Hash) (*navutil.Tx, error) {
+	// Protect concurrent access.
+	mp.mtx.RLock()
+	var txDesc *TxDesc
+	txHash, exists := mp.wtxids[*wtxid]
+	if exists {
+		txDesc, exists = mp.pool[txHash]
+	}
+	mp.mtx.RUnlock()
+
+	if exists {
+		return txDesc.Tx, nil
+	}
+
+	return nil, fmt.Errorf("transaction is not in the pool")
+}
+
 // maybeAcceptTransaction is the internal function which implements the public
 // MaybeAcceptTransaction.  See the comment for MaybeAcceptTransaction for
 // more details.
 //
+// When a test package is passed, the transaction is only checked against the
+// policy rules.  It is neither inserted into the pool nor announced, and the
+// returned descriptor is instead recorded in the package so that later
+// transactions of the package may spend its outputs.
+//
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) maybeAcceptTransaction(tx *navutil.Tx, isNew, rateLimit, rejectDupOrphans bool) ([]*chainhash.Hash, *TxDesc, error) {
+func (mp *TxPool) maybeAcceptTransaction(tx *navutil.Tx, isNew, rateLimit, rejectDupOrphans bool, pkg *testPackage) ([]*chainhash.Hash, *TxDesc, error) {
 	txHash := tx.Hash()
 
 	// If a transaction has iwtness data, and segwit isn't active yet, If
@@ -643,10 +883,11 @@ func (mp *TxPool) maybeAcceptTransaction(tx *navutil.Tx, isNew, rateLimit, rejec
 	// orphans flag is set.  This check is intended to be a quick check to
 	// weed out duplicates.
 	if mp.isTransactionInPool(txHash) || (rejectDupOrphans &&
-		mp.isOrphanInPool(txHash)) {
+		mp.isOrphanInPool(txHash)) || pkg.hasTransaction(txHash) {
 
 		str := fmt.Sprintf("already have transaction %v", txHash)
-		return nil, nil, txRuleError(wire.RejectDuplicate, str)
+		return nil, nil, witnessIndependentRuleError(
+			wire.RejectDuplicate, str)
 	}
 
 	// Perform preliminary sanity checks on the transaction.  This makes
@@ -664,7 +905,8 @@ func (mp *TxPool) maybeAcceptTransaction(tx *navutil.Tx, isNew, rateLimit, rejec
 	if blockchain.IsCoinBase(tx) {
 		str := fmt.Sprintf("transaction %v is an individual coinbase",
 			txHash)
-		return nil, nil, txRuleError(wire.RejectInvalid, str)
+		return nil, nil, witnessIndependentRuleError(
+			wire.RejectInvalid, str)
 	}
 
 	// Get the current height of the main chain.  A standalone transaction
@@ -691,6 +933,10 @@ func (mp *TxPool) maybeAcceptTransaction(tx *navutil.Tx, isNew, rateLimit, rejec
 			}
 			str := fmt.Sprintf("transaction %v is not standard: %v",
 				txHash, err)
+			if IsWitnessIndependent(err) {
+				return nil, nil, witnessIndependentRuleError(
+					rejectCode, str)
+			}
 			return nil, nil, txRuleError(rejectCode, str)
 		}
 	}
@@ -707,6 +953,10 @@ func (mp *TxPool) maybeAcceptTransaction(tx *navutil.Tx, isNew, rateLimit, rejec
 	if err != nil {
 		return nil, nil, err
 	}
+	err = pkg.checkDoubleSpend(tx)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Fetch all of the unspent transaction outputs referenced by the inputs
 	// to this transaction.  This function also attempts to fetch the
@@ -719,13 +969,14 @@ func (mp *TxPool) maybeAcceptTransaction(tx *navutil.Tx, isNew, rateLimit, rejec
 		}
 		return nil, nil, err
 	}
+	pkg.addInputUtxos(utxoView)
 
 	// Don't allow the transaction if it exists in the main chain and is not
 	// not already fully spent.
 	txEntry := utxoView.LookupEntry(txHash)
 	if txEntry != nil && !txEntry.IsFullySpent() {
-		return nil, nil, txRuleError(wire.RejectDuplicate,
-			"transaction already exists")
+		return nil, nil, witnessIndependentRuleError(
+			wire.RejectDuplicate, "transaction already exists")
 	}
 	delete(utxoView.Entries(), *txHash)
 
@@ -760,7 +1011,8 @@ func (mp *TxPool) maybeAcceptTransaction(tx *navutil.Tx, isNew, rateLimit, rejec
 	}
 	if !blockchain.SequenceLockActive(sequenceLock, nextBlockHeight,
 		medianTimePast) {
-		return nil, nil, txRuleError(wire.RejectNonstandard,
+		return nil, nil, witnessIndependentRuleError(
+			wire.RejectNonstandard,
 			"transaction's sequence locks on inputs not met")
 	}
 
@@ -791,7 +1043,8 @@ func (mp *TxPool) maybeAcceptTransaction(tx *navutil.Tx, isNew, rateLimit, rejec
 			}
 			str := fmt.Sprintf("transaction %v has a non-standard "+
 				"input: %v", txHash, err)
-			return nil, nil, txRuleError(rejectCode, str)
+			return nil, nil, witnessIndependentRuleError(
+				rejectCode, str)
 		}
 	}
 
@@ -890,6 +1143,14 @@ func (mp *TxPool) maybeAcceptTransaction(tx *navutil.Tx, isNew, rateLimit, rejec
 		return nil, nil, err
 	}
 
+	// Only record the transaction in the test package when this is a dry
+	// run.
+	if pkg != nil {
+		txD := mp.newTxDesc(utxoView, tx, bestHeight, txFee)
+		pkg.addTransaction(txD)
+		return nil, txD, nil
+	}
+
 	// Add to transaction pool.
 	txD := mp.addTransaction(utxoView, tx, bestHeight, txFee)
 
@@ -913,7 +1174,8 @@ func (mp *TxPool) maybeAcceptTransaction(tx *navutil.Tx, isNew, rateLimit, rejec
 func (mp *TxPool) MaybeAcceptTransaction(tx *navutil.Tx, isNew, rateLimit bool) ([]*chainhash.Hash, *TxDesc, error) {
 	// Protect concurrent access.
 	mp.mtx.Lock()
-	hashes, txD, err := mp.maybeAcceptTransaction(tx, isNew, rateLimit, true)
+	hashes, txD, err := mp.maybeAcceptTransaction(tx, isNew, rateLimit, true,
+		nil)
 	mp.mtx.Unlock()
 
 	return hashes, txD, err
@@ -956,7 +1218,7 @@ func (mp *TxPool) processOrphans(acceptedTx *navutil.Tx) []*TxDesc {
 			// Potentially accept an orphan into the tx pool.
 			for _, tx := range orphans {
 				missing, txD, err := mp.maybeAcceptTransaction(
-					tx, true, true, false)
+					tx, true, true, false, nil)
 				if err != nil {
 					// The orphan is now invalid, so there
 					// is no way any other orphans which
@@ -1040,7 +1302,7 @@ func (mp *TxPool) ProcessTransaction(tx *navutil.Tx, allowOrphan, rateLimit bool
 
 	// Potentially accept the transaction to the memory pool.
 	missingParents, txD, err := mp.maybeAcceptTransaction(tx, true, rateLimit,
-		true)
+		true, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -1076,7 +1338,8 @@ func (mp *TxPool) ProcessTransaction(tx *navutil.Tx, allowOrphan, rateLimit bool
 		str := fmt.Sprintf("orphan transaction %v references "+
 			"outputs of unknown or fully-spent "+
 			"transaction %v", tx.Hash(), missingParents[0])
-		return nil, txRuleError(wire.RejectDuplicate, str)
+		return nil, witnessIndependentRuleError(wire.RejectDuplicate,
+			str)
 	}
 
 	// Potentially add the orphan transaction to the orphan pool.
@@ -1177,6 +1440,7 @@ func (mp *TxPool) RawMempoolVerbose() map[string]*btcjson.GetRawMempoolVerboseRe
 			Size:             int32(tx.MsgTx().SerializeSize()),
 			Vsize:            int32(GetTxVirtualSize(tx)),
 			Fee:              navutil.Amount(desc.Fee).ToNAV(),
+			ModifiedFee:      navutil.Amount(desc.Fee + desc.FeeDelta).ToNAV(),
 			Time:             desc.Added.Unix(),
 			Height:           int64(desc.Height),
 			StartingPriority: desc.StartingPriority,
@@ -1197,6 +1461,119 @@ func (mp *TxPool) RawMempoolVerbose() map[string]*btcjson.GetRawMempoolVerboseRe
 	return result
 }
 
+// PrioritiseTransaction adjusts the fee used to order the transaction with the
+// passed hash for inclusion in block templates by the given delta in Satoshi.
+// Deltas accumulate across calls and may be set for transactions which are not
+// in the pool yet, in which case they apply once the transaction is accepted.
+// The fee actually paid by the transaction is not affected.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) PrioritiseTransaction(hash *chainhash.Hash, feeDelta int64) {
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	delta := mp.feeDeltas[*hash] + feeDelta
+	if delta == 0 {
+		delete(mp.feeDeltas, *hash)
+	} else {
+		mp.feeDeltas[*hash] = delta
+	}
+
+	// Replace the descriptor of a transaction that is already in the pool
+	// rather than updating it in place since the descriptors handed out by
+	// MiningDescs are read without the pool lock held.
+	if txDesc, exists := mp.pool[*hash]; exists {
+		newTxDesc := *txDesc
+		newTxDesc.FeeDelta = delta
+		mp.pool[*hash] = &newTxDesc
+		atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+	}
+
+	log.Debugf("Fee delta for transaction %v set to %d", hash, delta)
+}
+
+// FeeDelta returns the fee delta that has been applied to the transaction with
+// the passed hash via PrioritiseTransaction.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) FeeDelta(hash *chainhash.Hash) int64 {
+	mp.mtx.RLock()
+	delta := mp.feeDeltas[*hash]
+	mp.mtx.RUnlock()
+
+	return delta
+}
+
+// SaveFeeDeltas serializes the fee deltas applied via PrioritiseTransaction so
+// they can be restored with RestoreFeeDeltas in a later session.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) SaveFeeDeltas() []byte {
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	// Sort the hashes so a serialized state always comes out the same.
+	hashes := make([]chainhash.Hash, 0, len(mp.feeDeltas))
+	for hash := range mp.feeDeltas {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i][:], hashes[j][:]) < 0
+	})
+
+	w := bytes.NewBuffer(make([]byte, 0, 8+len(hashes)*
+		(chainhash.HashSize+8)))
+	binary.Write(w, binary.BigEndian, uint32(feeDeltasSaveVersion))
+	binary.Write(w, binary.BigEndian, uint32(len(hashes)))
+	for i := range hashes {
+		w.Write(hashes[i][:])
+		binary.Write(w, binary.BigEndian, mp.feeDeltas[hashes[i]])
+	}
+
+	return w.Bytes()
+}
+
+// RestoreFeeDeltas loads fee deltas that were previously returned by
+// SaveFeeDeltas into the pool.  The restored deltas are added to any deltas
+// that are already present.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) RestoreFeeDeltas(data []byte) error {
+	r := bytes.NewReader(data)
+
+	var version uint32
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return err
+	}
+	if version != feeDeltasSaveVersion {
+		return fmt.Errorf("incorrect fee deltas version: expected %d "+
+			"found %d", feeDeltasSaveVersion, version)
+	}
+
+	var numDeltas uint32
+	if err := binary.Read(r, binary.BigEndian, &numDeltas); err != nil {
+		return err
+	}
+	deltas := make(map[chainhash.Hash]int64, numDeltas)
+	for i := uint32(0); i < numDeltas; i++ {
+		var hash chainhash.Hash
+		var delta int64
+		if _, err := io.ReadFull(r, hash[:]); err != nil {
+			return err
+		}
+		if err := binary.Read(r, binary.BigEndian, &delta); err != nil {
+			return err
+		}
+		deltas[hash] = delta
+	}
+
+	for hash, delta := range deltas {
+		mp.PrioritiseTransaction(&hash, delta)
+	}
+
+	return nil
+}
+
 // LastUpdated returns the last time a transaction was added to or removed from
 // the main pool.  It does not include the orphan pool.
 //
@@ -1211,9 +1588,11 @@ func New(cfg *Config) *TxPool {
 	return &TxPool{
 		cfg:            *cfg,
 		pool:           make(map[chainhash.Hash]*TxDesc),
+		wtxids:         make(map[chainhash.Hash]chainhash.Hash),
 		orphans:        make(map[chainhash.Hash]*orphanTx),
 		orphansByPrev:  make(map[wire.OutPoint]map[chainhash.Hash]*navutil.Tx),
 		nextExpireScan: time.Now().Add(orphanExpireScanInterval),
 		outpoints:      make(map[wire.OutPoint]*navutil.Tx),
+		feeDeltas:      make(map[chainhash.Hash]int64),
 	}
 }
@@ -794,3 +794,312 @@ func TestMultiInputOrphanDoubleSpend(t *testing.T) {
 	// was not moved to the transaction pool.
 	testPoolMembership(tc, doubleSpendTx, false, false)
 }
+
+// TestPrioritiseTransaction ensures fee deltas applied to transactions are
+// reflected in the mining descriptors, survive a save and restore cycle and are
+// cleared once the transaction leaves the pool.
+func TestPrioritiseTransaction(t *testing.T) {
+	t.Parallel()
+
+	harness, spendableOuts, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	// Accept the version of the transactions created by the harness.
+	harness.txPool.cfg.Policy.MaxTxVersion = wire.TxVersion
+	tc := &testContext{t, harness}
+
+	tx, err := harness.CreateSignedTx(spendableOuts, 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+
+	// Apply a delta before the transaction is known to the pool to ensure
+	// it is honoured once the transaction is accepted.
+	harness.txPool.PrioritiseTransaction(tx.Hash(), 5000)
+	_, err = harness.txPool.ProcessTransaction(tx, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept valid "+
+			"transaction: %v", err)
+	}
+	testPoolMembership(tc, tx, false, true)
+
+	// Deltas accumulate across calls.
+	harness.txPool.PrioritiseTransaction(tx.Hash(), 2500)
+	const wantDelta = 7500
+	descs := harness.txPool.MiningDescs()
+	if len(descs) != 1 {
+		t.Fatalf("MiningDescs: got %d descriptors, want 1", len(descs))
+	}
+	if descs[0].FeeDelta != wantDelta {
+		t.Fatalf("MiningDescs: got fee delta %d, want %d",
+			descs[0].FeeDelta, wantDelta)
+	}
+	wantFeePerKB := (descs[0].Fee + wantDelta) * 1000 /
+		GetTxVirtualSize(tx)
+	if got := descs[0].ModifiedFeePerKB(); got != wantFeePerKB {
+		t.Fatalf("ModifiedFeePerKB: got %d, want %d", got,
+			wantFeePerKB)
+	}
+	verbose := harness.txPool.RawMempoolVerbose()[tx.Hash().String()]
+	wantModifiedFee := navutil.Amount(descs[0].Fee + wantDelta).ToNAV()
+	if verbose.ModifiedFee != wantModifiedFee {
+		t.Fatalf("RawMempoolVerbose: got modified fee %v, want %v",
+			verbose.ModifiedFee, wantModifiedFee)
+	}
+
+	// Ensure the deltas are carried over to a new pool.
+	saved := harness.txPool.SaveFeeDeltas()
+	restored := New(&harness.txPool.cfg)
+	if err := restored.RestoreFeeDeltas(saved); err != nil {
+		t.Fatalf("RestoreFeeDeltas: unexpected error: %v", err)
+	}
+	if got := restored.FeeDelta(tx.Hash()); got != wantDelta {
+		t.Fatalf("RestoreFeeDeltas: got fee delta %d, want %d", got,
+			wantDelta)
+	}
+
+	// Removing the transaction from the pool clears its delta.
+	harness.txPool.RemoveTransaction(tx, false, RemovalConfirmed)
+	if got := harness.txPool.FeeDelta(tx.Hash()); got != 0 {
+		t.Fatalf("FeeDelta: got %d after removal, want 0", got)
+	}
+}
+
+// TestWTxIDLookup ensures transactions in the main pool and the orphan pool are
+// found by their witness hash and are no longer found once removed.
+func TestWTxIDLookup(t *testing.T) {
+	t.Parallel()
+
+	harness, spendableOuts, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	// Accept the version of the transactions created by the harness.
+	harness.txPool.cfg.Policy.MaxTxVersion = wire.TxVersion
+
+	chainedTxns, err := harness.CreateTxChain(spendableOuts[0], 2)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	parent, child := chainedTxns[0], chainedTxns[1]
+
+	// The child is an orphan until its parent is known.
+	_, err = harness.txPool.ProcessTransaction(child, true, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept orphan: %v", err)
+	}
+	if !harness.txPool.HaveTransactionByWTxID(child.WitnessHash()) {
+		t.Fatal("HaveTransactionByWTxID: orphan not found")
+	}
+	if _, err := harness.txPool.FetchTransactionByWTxID(child.WitnessHash()); err == nil {
+		t.Fatal("FetchTransactionByWTxID: orphan fetched")
+	}
+
+	_, err = harness.txPool.ProcessTransaction(parent, true, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept valid "+
+			"transaction: %v", err)
+	}
+	for _, tx := range chainedTxns {
+		fetched, err := harness.txPool.FetchTransactionByWTxID(tx.WitnessHash())
+		if err != nil {
+			t.Fatalf("FetchTransactionByWTxID: unexpected error: %v",
+				err)
+		}
+		if !fetched.Hash().IsEqual(tx.Hash()) {
+			t.Fatalf("FetchTransactionByWTxID: got %v, want %v",
+				fetched.Hash(), tx.Hash())
+		}
+	}
+
+	// Removing the parent removes its redeemer as well.
+	harness.txPool.RemoveTransaction(parent, true, RemovalConfirmed)
+	for _, tx := range chainedTxns {
+		if harness.txPool.HaveTransactionByWTxID(tx.WitnessHash()) {
+			t.Fatalf("HaveTransactionByWTxID: removed transaction "+
+				"%v found", tx.Hash())
+		}
+	}
+}
+
+// TestExpireTransactions ensures that transactions which have been in the pool
+// longer than the configured expiry are evicted along with their descendants
+// while unrelated and ancestor transactions are left untouched.
+func TestExpireTransactions(t *testing.T) {
+	t.Parallel()
+
+	harness, spendableOuts, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	// Accept the version of the transactions created by the harness.
+	harness.txPool.cfg.Policy.MaxTxVersion = wire.TxVersion
+	tc := &testContext{t, harness}
+
+	// Create a parent transaction with two outputs, a chain of two
+	// transactions that spends the first output, and a lone transaction
+	// that spends the second.
+	parent, err := harness.CreateSignedTx(spendableOuts, 2)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	chainedTxns, err := harness.CreateTxChain(txOutToSpendableOut(parent,
+		0), 2)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	loneTxns, err := harness.CreateTxChain(txOutToSpendableOut(parent, 1),
+		1)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	allTxns := append([]*navutil.Tx{parent}, chainedTxns...)
+	allTxns = append(allTxns, loneTxns...)
+	for _, tx := range allTxns {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: failed to accept valid "+
+				"transaction %v: %v", tx.Hash(), err)
+		}
+	}
+
+	// Backdate the first transaction of the chain so it appears stale.
+	harness.txPool.pool[*chainedTxns[0].Hash()].Added =
+		time.Now().Add(-2 * time.Hour)
+
+	// Nothing is evicted while expiry is disabled.
+	if expired := harness.txPool.ExpireTransactions(); len(expired) != 0 {
+		t.Fatalf("ExpireTransactions: expired %d transactions with "+
+			"expiry disabled", len(expired))
+	}
+
+	// Enable expiry and ensure the stale transaction and its descendant
+	// are evicted.
+	harness.txPool.cfg.Policy.TxExpiry = time.Hour
+	expired := harness.txPool.ExpireTransactions()
+	if len(expired) != len(chainedTxns) {
+		t.Fatalf("ExpireTransactions: expired %d transactions, want %d",
+			len(expired), len(chainedTxns))
+	}
+	for _, tx := range chainedTxns {
+		testPoolMembership(tc, tx, false, false)
+	}
+	testPoolMembership(tc, parent, false, true)
+	testPoolMembership(tc, loneTxns[0], false, true)
+}
+
+// TestTxEventCallbacks ensures the transaction accepted and removed callbacks
+// are invoked for every transaction along with the reason it was removed.
+func TestTxEventCallbacks(t *testing.T) {
+	t.Parallel()
+
+	harness, spendableOuts, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	// Accept the version of the transactions created by the harness.
+	harness.txPool.cfg.Policy.MaxTxVersion = wire.TxVersion
+
+	accepted := make(map[chainhash.Hash]struct{})
+	removed := make(map[chainhash.Hash]RemovalReason)
+	harness.txPool.cfg.TxAccepted = func(txD *TxDesc) {
+		accepted[*txD.Tx.Hash()] = struct{}{}
+	}
+	harness.txPool.cfg.TxRemoved = func(tx *navutil.Tx, reason RemovalReason) {
+		removed[*tx.Hash()] = reason
+	}
+
+	chainedTxns, err := harness.CreateTxChain(spendableOuts[0], 3)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	for _, tx := range chainedTxns {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: failed to accept valid "+
+				"transaction %v: %v", tx.Hash(), err)
+		}
+		if _, ok := accepted[*tx.Hash()]; !ok {
+			t.Fatalf("TxAccepted: not called for transaction %v",
+				tx.Hash())
+		}
+	}
+
+	// Removing the first transaction as confirmed must report its
+	// descendants as removed for the same reason.
+	harness.txPool.RemoveTransaction(chainedTxns[0], true, RemovalConfirmed)
+	for _, tx := range chainedTxns {
+		reason, ok := removed[*tx.Hash()]
+		if !ok {
+			t.Fatalf("TxRemoved: not called for transaction %v",
+				tx.Hash())
+		}
+		if reason != RemovalConfirmed {
+			t.Fatalf("TxRemoved: got reason %v for transaction %v, "+
+				"want %v", reason, tx.Hash(), RemovalConfirmed)
+		}
+	}
+}
+
+// TestTestAcceptTransactions ensures packages of transactions are run through
+// the acceptance checks without modifying the pool.
+func TestTestAcceptTransactions(t *testing.T) {
+	t.Parallel()
+
+	harness, spendableOuts, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	// Accept the version of the transactions created by the harness.
+	harness.txPool.cfg.Policy.MaxTxVersion = wire.TxVersion
+	tc := &testContext{t, harness}
+
+	// Create a chain of transactions along with a transaction which
+	// double spends the first one.
+	chainedTxns, err := harness.CreateTxChain(spendableOuts[0], 3)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	doubleSpend, err := harness.CreateSignedTx(spendableOuts[:1], 2)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+
+	// The whole chain is accepted as a package, but the later
+	// transactions are orphans on their own.
+	results := harness.txPool.TestAcceptTransactions(chainedTxns)
+	for i, result := range results {
+		if result.Err != nil || len(result.MissingParents) != 0 ||
+			result.TxDesc == nil {
+
+			t.Fatalf("TestAcceptTransactions #%d: transaction not "+
+				"accepted: %v", i, result.Err)
+		}
+	}
+	results = harness.txPool.TestAcceptTransactions(chainedTxns[1:2])
+	if len(results[0].MissingParents) != 1 {
+		t.Fatalf("TestAcceptTransactions: got %d missing parents, "+
+			"want 1", len(results[0].MissingParents))
+	}
+
+	// Nothing may have been added to the pool by the dry runs.
+	for _, tx := range chainedTxns {
+		testPoolMembership(tc, tx, false, false)
+	}
+
+	// Transactions of a package may not double spend each other or be
+	// duplicated.
+	pkg := []*navutil.Tx{chainedTxns[0], doubleSpend, chainedTxns[0]}
+	results = harness.txPool.TestAcceptTransactions(pkg)
+	if results[0].Err != nil {
+		t.Fatalf("TestAcceptTransactions: transaction not accepted: %v",
+			results[0].Err)
+	}
+	for i, result := range results[1:] {
+		if result.Err == nil {
+			t.Fatalf("TestAcceptTransactions #%d: conflicting "+
+				"transaction accepted", i+1)
+		}
+	}
+}
@@ -286,13 +286,13 @@ func checkTransactionStandard(tx *navutil.Tx, height int32,
 		str := fmt.Sprintf("transaction version %d is not in the "+
 			"valid range of %d-%d", msgTx.Version, 1,
 			maxTxVersion)
-		return txRuleError(wire.RejectNonstandard, str)
+		return witnessIndependentRuleError(wire.RejectNonstandard, str)
 	}
 
 	// The transaction must be finalized to be standard and therefore
 	// considered for inclusion in a block.
 	if !blockchain.IsFinalizedTransaction(tx, height, medianTimePast) {
-		return txRuleError(wire.RejectNonstandard,
+		return witnessIndependentRuleError(wire.RejectNonstandard,
 			"transaction is not finalized")
 	}
 
@@ -317,7 +317,8 @@ func checkTransactionStandard(tx *navutil.Tx, height int32,
 				"script size of %d bytes is large than max "+
 				"allowed size of %d bytes", i, sigScriptLen,
 				maxStandardSigScriptSize)
-			return txRuleError(wire.RejectNonstandard, str)
+			return witnessIndependentRuleError(
+				wire.RejectNonstandard, str)
 		}
 
 		// Each transaction input signature script must only contain
@@ -325,7 +326,8 @@ func checkTransactionStandard(tx *navutil.Tx, height int32,
 		if !txscript.IsPushOnlyScript(txIn.SignatureScript) {
 			str := fmt.Sprintf("transaction input %d: signature "+
 				"script is not push only", i)
-			return txRuleError(wire.RejectNonstandard, str)
+			return witnessIndependentRuleError(
+				wire.RejectNonstandard, str)
 		}
 	}
 
@@ -344,7 +346,7 @@ func checkTransactionStandard(tx *navutil.Tx, height int32,
 				rejectCode = rejCode
 			}
 			str := fmt.Sprintf("transaction output %d: %v", i, err)
-			return txRuleError(rejectCode, str)
+			return witnessIndependentRuleError(rejectCode, str)
 		}
 
 		// Accumulate the number of outputs which only carry data.  For
@@ -355,7 +357,7 @@ func checkTransactionStandard(tx *navutil.Tx, height int32,
 		} else if isDust(txOut, minRelayTxFee) {
 			str := fmt.Sprintf("transaction output %d: payment "+
 				"of %d is dust", i, txOut.Value)
-			return txRuleError(wire.RejectDust, str)
+			return witnessIndependentRuleError(wire.RejectDust, str)
 		}
 	}
 
@@ -363,7 +365,7 @@ func checkTransactionStandard(tx *navutil.Tx, height int32,
 	// only carries data.
 	if numNullDataOutputs > 1 {
 		str := "more than one transaction output in a nulldata script"
-		return txRuleError(wire.RejectNonstandard, str)
+		return witnessIndependentRuleError(wire.RejectNonstandard, str)
 	}
 
 	return nil
@@ -312,6 +312,10 @@ func TestCheckTransactionStandard(t *testing.T) {
 		height     int32
 		isStandard bool
 		code       wire.RejectCode
+
+		// witnessDependent is whether the rejection may depend on the
+		// witness data of the transaction.
+		witnessDependent bool
 	}{
 		{
 			name: "Typical pay-to-pubkey-hash transaction",
@@ -364,9 +368,10 @@ func TestCheckTransactionStandard(t *testing.T) {
 				}},
 				LockTime: 0,
 			},
-			height:     300000,
-			isStandard: false,
-			code:       wire.RejectNonstandard,
+			height:           300000,
+			isStandard:       false,
+			code:             wire.RejectNonstandard,
+			witnessDependent: true,
 		},
 		{
 			name: "Signature script size is too large",
@@ -508,5 +513,14 @@ func TestCheckTransactionStandard(t *testing.T) {
 				txrerr.RejectCode, test.code)
 			continue
 		}
+
+		// Ensure the rejection is only independent of the witness when
+		// it is expected to be.
+		if IsWitnessIndependent(err) == test.witnessDependent {
+			t.Errorf("checkTransactionStandard (%s): witness "+
+				"independent %v, want %v", test.name,
+				test.witnessDependent, !test.witnessDependent)
+			continue
+		}
 	}
 }
@@ -0,0 +1,140 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"fmt"
+
+	"github.com/encrypt-s/navd/blockchain"
+	"github.com/encrypt-s/navd/chaincfg/chainhash"
+	"github.com/encrypt-s/navd/mining"
+	"github.com/encrypt-s/navd/wire"
+	"github.com/navcoin/navutil"
+)
+
+// MaxTestAcceptPackageSize is the maximum number of transactions which may be
+// tested for acceptance together as a single package.
+const MaxTestAcceptPackageSize = 25
+
+// TestAcceptResult houses the outcome of testing whether a single transaction
+// would be accepted into the memory pool.
+type TestAcceptResult struct {
+	// Tx is the transaction which was tested.
+	Tx *navutil.Tx
+
+	// TxDesc is the descriptor the transaction would have been added to
+	// the memory pool with.  It is nil when the transaction would not be
+	// accepted.
+	TxDesc *TxDesc
+
+	// MissingParents houses the hashes of the transactions referenced by
+	// inputs which are neither in the main chain, the memory pool nor
+	// earlier in the package.
+	MissingParents []*chainhash.Hash
+
+	// Err is the reason the transaction would be rejected, if any.
+	Err error
+}
+
+// testPackage houses the transactions of a package which have passed the
+// acceptance checks of a dry run so that later transactions of the same
+// package may spend their outputs.  All methods are safe to call on a nil
+// package, in which case they do nothing.
+type testPackage struct {
+	pool      map[chainhash.Hash]*TxDesc
+	outpoints map[wire.OutPoint]*navutil.Tx
+}
+
+// newTestPackage returns a new empty test package.
+func newTestPackage() *testPackage {
+	return &testPackage{
+		pool:      make(map[chainhash.Hash]*TxDesc),
+		outpoints: make(map[wire.OutPoint]*navutil.Tx),
+	}
+}
+
+// hasTransaction returns whether or not the passed transaction has already
+// passed the acceptance checks as part of the package.
+func (pkg *testPackage) hasTransaction(hash *chainhash.Hash) bool {
+	if pkg == nil {
+		return false
+	}
+	_, exists := pkg.pool[*hash]
+	return exists
+}
+
+// checkDoubleSpend checks whether or not the passed transaction is attempting
+// to spend coins already spent by an earlier transaction of the package.
+func (pkg *testPackage) checkDoubleSpend(tx *navutil.Tx) error {
+	if pkg == nil {
+		return nil
+	}
+	for _, txIn := range tx.MsgTx().TxIn {
+		if txR, exists := pkg.outpoints[txIn.PreviousOutPoint]; exists {
+			str := fmt.Sprintf("output %v already spent by "+
+				"transaction %v in the package",
+				txIn.PreviousOutPoint, txR.Hash())
+			return txRuleError(wire.RejectDuplicate, str)
+		}
+	}
+	return nil
+}
+
+// addInputUtxos populates any inputs missing from the passed view with the
+// outputs of transactions of the package.
+func (pkg *testPackage) addInputUtxos(utxoView *blockchain.UtxoViewpoint) {
+	if pkg == nil {
+		return
+	}
+	for originHash, entry := range utxoView.Entries() {
+		if entry != nil && !entry.IsFullySpent() {
+			continue
+		}
+		if txD, exists := pkg.pool[originHash]; exists {
+			utxoView.AddTxOuts(txD.Tx, mining.UnminedHeight)
+		}
+	}
+}
+
+// addTransaction records the passed transaction as accepted by the package and
+// marks the outpoints it references as spent by the package.
+func (pkg *testPackage) addTransaction(txD *TxDesc) {
+	pkg.pool[*txD.Tx.Hash()] = txD
+	for _, txIn := range txD.Tx.MsgTx().TxIn {
+		pkg.outpoints[txIn.PreviousOutPoint] = txD.Tx
+	}
+}
+
+// TestAcceptTransactions runs the passed transactions through every check
+// performed when accepting transactions into the memory pool without adding
+// them to the pool, relaying them or modifying any other pool state.  The
+// transactions are tested in order as a package, so later transactions may
+// spend the outputs of earlier ones which would be accepted.  The free
+// transaction rate limiter is not applied since it only concerns transactions
+// relayed by remote peers.
+//
+// A result is returned for every passed transaction in the same order.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) TestAcceptTransactions(txns []*navutil.Tx) []*TestAcceptResult {
+	// Protect concurrent access.  The write lock is required since
+	// maybeAcceptTransaction is shared with the insertion path.
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	pkg := newTestPackage()
+	results := make([]*TestAcceptResult, 0, len(txns))
+	for _, tx := range txns {
+		missingParents, txD, err := mp.maybeAcceptTransaction(tx, true,
+			false, true, pkg)
+		results = append(results, &TestAcceptResult{
+			Tx:             tx,
+			TxDesc:         txD,
+			MissingParents: missingParents,
+			Err:            err,
+		})
+	}
+	return results
+}
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/navcoin/navd/blockchain"
+	"github.com/navcoin/navd/btcec"
 	"github.com/navcoin/navd/chaincfg"
 	"github.com/navcoin/navd/chaincfg/chainhash"
 	"github.com/navcoin/navd/mining"
@@ -61,6 +62,10 @@ type Config struct {
 	// blocks.  Each generated block will randomly choose one of them.
 	MiningAddrs []navutil.Address
 
+	// SignetKey is the private key used to sign the generated blocks when
+	// the chain parameters define a signet network.
+	SignetKey *btcec.PrivateKey
+
 	// ProcessBlock defines the function to call with any solved blocks.
 	// It typically must run the provided block through the same set of
 	// rules and handling as any other block coming from the network.
@@ -151,8 +156,9 @@ out:
 }
 
 // submitBlock submits the passed block to network after ensuring it passes all
-// of the consensus validation rules.
-func (m *CPUMiner) submitBlock(block *navutil.Block) bool {
+// of the consensus validation rules.  The outcome is recorded in the history of
+// blocks generated from the passed template.
+func (m *CPUMiner) submitBlock(template *mining.BlockTemplate, block *navutil.Block) bool {
 	m.submitBlockLock.Lock()
 	defer m.submitBlockLock.Unlock()
 
@@ -165,6 +171,7 @@ func (m *CPUMiner) submitBlock(block *navutil.Block) bool {
 	if !msgBlock.Header.PrevBlock.IsEqual(&m.g.BestSnapshot().Hash) {
 		log.Debugf("Block submitted via CPU miner with previous "+
 			"block %s is stale", msgBlock.Header.PrevBlock)
+		m.g.RecordBlock(template, block, mining.BlockStale)
 		return false
 	}
 
@@ -177,24 +184,43 @@ func (m *CPUMiner) submitBlock(block *navutil.Block) bool {
 		if _, ok := err.(blockchain.RuleError); !ok {
 			log.Errorf("Unexpected error while processing "+
 				"block submitted via CPU miner: %v", err)
+			m.g.RecordBlock(template, block, mining.BlockRejected)
 			return false
 		}
 
 		log.Debugf("Block submitted via CPU miner rejected: %v", err)
+		m.g.RecordBlock(template, block, mining.BlockRejected)
 		return false
 	}
 	if isOrphan {
 		log.Debugf("Block submitted via CPU miner is an orphan")
+		m.g.RecordBlock(template, block, mining.BlockRejected)
 		return false
 	}
 
 	// The block was accepted.
+	m.g.RecordBlock(template, block, mining.BlockAccepted)
 	coinbaseTx := block.MsgBlock().Transactions[0].TxOut[0]
 	log.Infof("Block submitted via CPU miner accepted (hash %s, "+
 		"amount %v)", block.Hash(), navutil.Amount(coinbaseTx.Value))
 	return true
 }
 
+// signBlock signs the passed block when the miner is associated with a signet
+// network.  It returns false when the block could not be signed.
+func (m *CPUMiner) signBlock(msgBlock *wire.MsgBlock) bool {
+	if len(m.cfg.ChainParams.SignetChallenge) == 0 {
+		return true
+	}
+
+	err := mining.SignBlock(msgBlock, m.cfg.ChainParams, m.cfg.SignetKey)
+	if err != nil {
+		log.Errorf("Failed to sign block: %v", err)
+		return false
+	}
+	return true
+}
+
 // solveBlock attempts to find some combination of a nonce, extra nonce, and
 // current timestamp which makes the passed block hash to a value less than the
 // target difficulty.  The timestamp is updated periodically and the passed
@@ -233,6 +259,13 @@ func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight int32,
 		// new value by regenerating the coinbase script and
 		// setting the merkle root to the new value.
 		m.g.UpdateExtraNonce(msgBlock, blockHeight, extraNonce+enOffset)
+		if !m.signBlock(msgBlock) {
+			return false
+		}
+
+		// Prehash the header so only the nonce has to be rewritten
+		// for every attempt below.
+		prehashed := header.Prehash()
 
 		// Search through the entire nonce range for a solution while
 		// periodically checking for early quit and stale block
@@ -264,6 +297,10 @@ func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight int32,
 				}
 
 				m.g.UpdateBlockTime(msgBlock)
+				if !m.signBlock(msgBlock) {
+					return false
+				}
+				prehashed = header.Prehash()
 
 			default:
 				// Non-blocking select to fall through
@@ -274,7 +311,7 @@ func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight int32,
 			// increment the number of hashes completed for each
 			// attempt accordingly.
 			header.Nonce = i
-			hash := header.BlockHash()
+			hash := prehashed.Hash(i)
 			hashesCompleted += 2
 
 			// The block is solved when the new block hash is less
@@ -356,7 +393,7 @@ out:
 		// true a solution was found, so submit the solved block.
 		if m.solveBlock(template.Block, curHeight+1, ticker, quit) {
 			block := navutil.NewBlock(template.Block)
-			m.submitBlock(block)
+			m.submitBlock(template, block)
 		}
 	}
 
@@ -545,23 +582,66 @@ func (m *CPUMiner) NumWorkers() int32 {
 // generating a new block template.  When a block is solved, it is submitted.
 // The function returns a list of the hashes of generated blocks.
 func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
+	return m.generateNBlocks(n, nil)
+}
+
+// GenerateNBlocksToAddress generates the requested number of blocks in the
+// same way as GenerateNBlocks except the coinbase of every block pays to the
+// passed address instead of one of the configured mining addresses.
+func (m *CPUMiner) GenerateNBlocksToAddress(n uint32,
+	payToAddr navutil.Address) ([]*chainhash.Hash, error) {
+
+	return m.generateNBlocks(n, payToAddr)
+}
+
+// startDiscreteMining marks the miner as discretely mining and starts the
+// speed monitor.  It returns an error if the server is already mining.
+func (m *CPUMiner) startDiscreteMining() error {
 	m.Lock()
+	defer m.Unlock()
 
 	// Respond with an error if server is already mining.
 	if m.started || m.discreteMining {
-		m.Unlock()
-		return nil, errors.New("Server is already CPU mining. Please call " +
+		return errors.New("Server is already CPU mining. Please call " +
 			"`setgenerate 0` before calling discrete `generate` commands.")
 	}
 
+	// Blocks can't be generated on signet networks without a key to sign
+	// them with.
+	if len(m.cfg.ChainParams.SignetChallenge) != 0 && m.cfg.SignetKey == nil {
+		return errors.New("No signet signing key specified via " +
+			"--signetkey")
+	}
+
 	m.started = true
 	m.discreteMining = true
 
 	m.speedMonitorQuit = make(chan struct{})
 	m.wg.Add(1)
 	go m.speedMonitor()
+	return nil
+}
 
+// stopDiscreteMining stops the speed monitor started by startDiscreteMining
+// and marks the miner as no longer mining.
+func (m *CPUMiner) stopDiscreteMining() {
+	m.Lock()
+	close(m.speedMonitorQuit)
+	m.wg.Wait()
+	m.started = false
+	m.discreteMining = false
 	m.Unlock()
+}
+
+// generateNBlocks generates the requested number of blocks paying to the
+// passed address, or to a random configured mining address for each block
+// when it is nil.
+func (m *CPUMiner) generateNBlocks(n uint32,
+	payToAddr navutil.Address) ([]*chainhash.Hash, error) {
+
+	if err := m.startDiscreteMining(); err != nil {
+		return nil, err
+	}
 
 	log.Tracef("Generating %d blocks", n)
 
@@ -588,14 +668,17 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 		m.submitBlockLock.Lock()
 		curHeight := m.g.BestSnapshot().Height
 
-		// Choose a payment address at random.
-		rand.Seed(time.Now().UnixNano())
-		payToAddr := m.cfg.MiningAddrs[rand.Intn(len(m.cfg.MiningAddrs))]
+		// Choose a payment address at random unless one was provided.
+		blockPayToAddr := payToAddr
+		if blockPayToAddr == nil {
+			rand.Seed(time.Now().UnixNano())
+			blockPayToAddr = m.cfg.MiningAddrs[rand.Intn(len(m.cfg.MiningAddrs))]
+		}
 
 		// Create a new block template using the available transactions
 		// in the memory pool as a source of transactions to potentially
 		// include in the block.
-		template, err := m.g.NewBlockTemplate(payToAddr)
+		template, err := m.g.NewBlockTemplate(blockPayToAddr)
 		m.submitBlockLock.Unlock()
 		if err != nil {
 			errStr := fmt.Sprintf("Failed to create new block "+
@@ -610,23 +693,67 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 		// true a solution was found, so submit the solved block.
 		if m.solveBlock(template.Block, curHeight+1, ticker, nil) {
 			block := navutil.NewBlock(template.Block)
-			m.submitBlock(block)
+			m.submitBlock(template, block)
 			blockHashes[i] = block.Hash()
 			i++
 			if i == n {
 				log.Tracef("Generated %d blocks", i)
-				m.Lock()
-				close(m.speedMonitorQuit)
-				m.wg.Wait()
-				m.started = false
-				m.discreteMining = false
-				m.Unlock()
+				m.stopDiscreteMining()
 				return blockHashes, nil
 			}
 		}
 	}
 }
 
+// GenerateBlock generates a single block containing exactly the passed
+// transactions, in the given order, with a coinbase paying to the passed
+// address.  The memory pool is not used to select transactions, so the passed
+// transactions do not have to be in it.  An error is returned when a block
+// with the transactions can't be created or the solved block is rejected.
+func (m *CPUMiner) GenerateBlock(payToAddr navutil.Address,
+	txns []*navutil.Tx) (*chainhash.Hash, error) {
+
+	if err := m.startDiscreteMining(); err != nil {
+		return nil, err
+	}
+	defer m.stopDiscreteMining()
+
+	// Start a ticker which is used to signal checks for stale work and
+	// updates to the speed monitor.
+	ticker := time.NewTicker(time.Second * hashUpdateSecs)
+	defer ticker.Stop()
+
+	for {
+		// Read updateNumWorkers in case someone tries a `setgenerate` while
+		// we're generating.
+		select {
+		case <-m.updateNumWorkers:
+		default:
+		}
+
+		m.submitBlockLock.Lock()
+		curHeight := m.g.BestSnapshot().Height
+		template, err := m.g.NewBlockTemplateWithTxns(payToAddr, txns)
+		m.submitBlockLock.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		// Attempt to solve the block and start over with a new template
+		// when it becomes stale.
+		if !m.solveBlock(template.Block, curHeight+1, ticker, nil) {
+			continue
+		}
+
+		block := navutil.NewBlock(template.Block)
+		if !m.submitBlock(template, block) {
+			return nil, fmt.Errorf("generated block %s was rejected",
+				block.Hash())
+		}
+		return block.Hash(), nil
+	}
+}
+
 // New returns a new instance of a CPU miner for the provided configuration.
 // Use Start to begin the mining process.  See the documentation for CPUMiner
 // type for more details.
@@ -0,0 +1,157 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/navcoin/navd/chaincfg/chainhash"
+	"github.com/navcoin/navutil"
+)
+
+const (
+	// maxBlockHistory is the maximum number of generated blocks kept in the
+	// history of a block template generator.  The oldest blocks are
+	// removed once the limit is reached.
+	maxBlockHistory = 100
+)
+
+// BlockStatus describes the outcome of a block generated from a block
+// template.
+type BlockStatus int
+
+// These constants define the possible outcomes of a generated block.
+const (
+	// BlockAccepted indicates the block was accepted and is part of the
+	// main chain.
+	BlockAccepted BlockStatus = iota
+
+	// BlockStale indicates the block was not submitted because the best
+	// chain changed while it was being solved.
+	BlockStale
+
+	// BlockRejected indicates the block was rejected when it was
+	// submitted.
+	BlockRejected
+
+	// BlockOrphaned indicates the block was accepted, but later
+	// disconnected from the main chain by a reorganization.
+	BlockOrphaned
+)
+
+// Map of block status values back to their constant names for pretty printing.
+var blockStatusStrings = map[BlockStatus]string{
+	BlockAccepted: "accepted",
+	BlockStale:    "stale",
+	BlockRejected: "rejected",
+	BlockOrphaned: "orphaned",
+}
+
+// String returns the BlockStatus in human-readable form.
+func (s BlockStatus) String() string {
+	if str, ok := blockStatusStrings[s]; ok {
+		return str
+	}
+	return fmt.Sprintf("Unknown BlockStatus (%d)", int(s))
+}
+
+// GeneratedBlock houses details about a block generated from a block template
+// along with its outcome.
+type GeneratedBlock struct {
+	// Hash is the hash of the block.
+	Hash chainhash.Hash
+
+	// Height is the height of the block.
+	Height int32
+
+	// Time is when the block was submitted.
+	Time time.Time
+
+	// BuildTime is how long the template of the block took to build.
+	BuildTime time.Duration
+
+	// Fees is the total amount of fees collected by the block.
+	Fees int64
+
+	// NumTxns is the number of transactions in the block including the
+	// coinbase.
+	NumTxns int
+
+	// Status is the outcome of the block.
+	Status BlockStatus
+}
+
+// RecordBlock adds a block solved from the passed template to the history of
+// generated blocks with the given outcome.
+//
+// This function is safe for concurrent access.
+func (g *BlkTmplGenerator) RecordBlock(template *BlockTemplate,
+	block *navutil.Block, status BlockStatus) {
+
+	var fees int64
+	if len(template.Fees) > 0 {
+		fees = -template.Fees[0]
+	}
+	generated := GeneratedBlock{
+		Hash:      *block.Hash(),
+		Height:    template.Height,
+		Time:      time.Now(),
+		BuildTime: template.BuildTime,
+		Fees:      fees,
+		NumTxns:   len(block.MsgBlock().Transactions),
+		Status:    status,
+	}
+
+	g.historyMtx.Lock()
+	if len(g.history) >= maxBlockHistory {
+		copy(g.history, g.history[1:])
+		g.history = g.history[:len(g.history)-1]
+	}
+	g.history = append(g.history, generated)
+	g.historyMtx.Unlock()
+}
+
+// UpdateBlockStatus changes the outcome of the generated block with the passed
+// hash, which allows blocks to be marked as orphaned when they are
+// disconnected from the main chain and accepted again when they are
+// reconnected.  Only blocks which were accepted at some point are updated.  It
+// returns whether or not the status of a generated block changed.
+//
+// This function is safe for concurrent access.
+func (g *BlkTmplGenerator) UpdateBlockStatus(hash *chainhash.Hash,
+	status BlockStatus) bool {
+
+	g.historyMtx.Lock()
+	defer g.historyMtx.Unlock()
+
+	for i := len(g.history) - 1; i >= 0; i-- {
+		generated := &g.history[i]
+		if generated.Hash != *hash {
+			continue
+		}
+		if generated.Status == status ||
+			(generated.Status != BlockAccepted &&
+				generated.Status != BlockOrphaned) {
+
+			return false
+		}
+		generated.Status = status
+		return true
+	}
+	return false
+}
+
+// BlockHistory returns the details about the most recently generated blocks
+// ordered from oldest to newest.
+//
+// This function is safe for concurrent access.
+func (g *BlkTmplGenerator) BlockHistory() []GeneratedBlock {
+	g.historyMtx.Lock()
+	history := make([]GeneratedBlock, len(g.history))
+	copy(history, g.history)
+	g.historyMtx.Unlock()
+	return history
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"testing"
+	"time"
+
+	"github.com/navcoin/navd/wire"
+	"github.com/navcoin/navutil"
+)
+
+// newHistoryBlock returns a template and a block with a unique hash solved
+// from it for use in the block history tests.
+func newHistoryBlock(height int32) (*BlockTemplate, *navutil.Block) {
+	msgBlock := &wire.MsgBlock{
+		Header:       wire.BlockHeader{Nonce: uint32(height)},
+		Transactions: []*wire.MsgTx{wire.NewMsgTx(wire.TxVersion)},
+	}
+	template := &BlockTemplate{
+		Block:     msgBlock,
+		Fees:      []int64{-1500, 1000, 500},
+		Height:    height,
+		BuildTime: time.Millisecond,
+	}
+	return template, navutil.NewBlock(msgBlock)
+}
+
+// TestBlockHistory ensures the history of generated blocks records their
+// details, only keeps the most recent blocks and tracks orphaned blocks.
+func TestBlockHistory(t *testing.T) {
+	var g BlkTmplGenerator
+	template, block := newHistoryBlock(1)
+	g.RecordBlock(template, block, BlockAccepted)
+
+	history := g.BlockHistory()
+	if len(history) != 1 {
+		t.Fatalf("history has %d blocks, want 1", len(history))
+	}
+	generated := history[0]
+	if generated.Hash != *block.Hash() || generated.Height != 1 ||
+		generated.Fees != 1500 || generated.NumTxns != 1 ||
+		generated.BuildTime != time.Millisecond ||
+		generated.Status != BlockAccepted {
+
+		t.Fatalf("unexpected generated block %+v", generated)
+	}
+
+	// Orphaning and reconnecting the accepted block changes its status.
+	if !g.UpdateBlockStatus(block.Hash(), BlockOrphaned) {
+		t.Fatal("accepted block was not orphaned")
+	}
+	if g.UpdateBlockStatus(block.Hash(), BlockOrphaned) {
+		t.Fatal("orphaned block was orphaned again")
+	}
+	if g.BlockHistory()[0].Status != BlockOrphaned {
+		t.Fatal("block status is not orphaned")
+	}
+	if !g.UpdateBlockStatus(block.Hash(), BlockAccepted) {
+		t.Fatal("orphaned block was not accepted again")
+	}
+
+	// Stale blocks never make it into the chain, so their status never
+	// changes.
+	template, block = newHistoryBlock(2)
+	g.RecordBlock(template, block, BlockStale)
+	if g.UpdateBlockStatus(block.Hash(), BlockOrphaned) {
+		t.Fatal("stale block was orphaned")
+	}
+
+	// Only the most recent blocks are kept.
+	for height := int32(3); height < maxBlockHistory+10; height++ {
+		template, block = newHistoryBlock(height)
+		g.RecordBlock(template, block, BlockRejected)
+	}
+	history = g.BlockHistory()
+	if len(history) != maxBlockHistory {
+		t.Fatalf("history has %d blocks, want %d", len(history),
+			maxBlockHistory)
+	}
+	if history[len(history)-1].Hash != *block.Hash() {
+		t.Fatal("newest block is not last in the history")
+	}
+	if history[0].Height != 10 {
+		t.Fatalf("oldest block has height %d, want 10",
+			history[0].Height)
+	}
+}
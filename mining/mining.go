@@ -7,7 +7,9 @@ package mining
 import (
 	"bytes"
 	"container/heap"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/navcoin/navd/blockchain"
@@ -51,6 +53,26 @@ type TxDesc struct {
 
 	// FeePerKB is the fee the transaction pays in Satoshi per 1000 bytes.
 	FeePerKB int64
+
+	// FeeDelta is the adjustment in Satoshi that has been applied to Fee
+	// via the prioritisetransaction RPC.  It only affects the order in
+	// which transactions are selected for inclusion in a block and does not
+	// change the fees that are actually paid to the coinbase.
+	FeeDelta int64
+}
+
+// ModifiedFeePerKB returns the fee per 1000 bytes of the transaction after
+// applying the fee delta, if any.  This is the value that is used when
+// ordering transactions for inclusion in a block.
+func (txD *TxDesc) ModifiedFeePerKB() int64 {
+	if txD.FeeDelta == 0 {
+		return txD.FeePerKB
+	}
+
+	txWeight := blockchain.GetTransactionWeight(txD.Tx)
+	vsize := (txWeight + blockchain.WitnessScaleFactor - 1) /
+		blockchain.WitnessScaleFactor
+	return (txD.Fee + txD.FeeDelta) * 1000 / vsize
 }
 
 // TxSource represents a source of transactions to consider for inclusion in
@@ -72,20 +94,24 @@ type TxSource interface {
 	HaveTransaction(hash *chainhash.Hash) bool
 }
 
-// txPrioItem houses a transaction along with extra information that allows the
-// transaction to be prioritized and track dependencies on other transactions
-// which have not been mined into a block yet.
+// txPrioItem houses a candidate transaction along with the priority and fee
+// per kilobyte used to order it in a transaction priority queue.  Items queued
+// by the AncestorTxSelector also house the ancestor package of the candidate,
+// in which case the fee per kilobyte is the one of the whole package.
 type txPrioItem struct {
-	tx       *navutil.Tx
-	fee      int64
-	priority float64
-	feePerKB int64
+	candidate *TxCandidate
+	pkg       *ancestorPackage
+	priority  float64
+	feePerKB  int64
+}
 
-	// dependsOn holds a map of transaction hashes which this one depends
-	// on.  It will only be set when the transaction references other
-	// transactions in the source pool and hence must come after them in
-	// a block.
-	dependsOn map[chainhash.Hash]struct{}
+// newTxPrioItem returns a priority queue item for the passed candidate.
+func newTxPrioItem(candidate *TxCandidate) *txPrioItem {
+	return &txPrioItem{
+		candidate: candidate,
+		priority:  candidate.Priority,
+		feePerKB:  candidate.FeePerKB,
+	}
 }
 
 // txPriorityQueueLessFunc describes a function that can be used as a compare
@@ -217,19 +243,23 @@ type BlockTemplate struct {
 	// witness has been activated, and the block contains a transaction
 	// which has witness data.
 	WitnessCommitment []byte
+
+	// BuildTime is how long the block template took to build.
+	BuildTime time.Duration
 }
 
 // mergeUtxoView adds all of the entries in view to viewA.  The result is that
 // viewA will contain all of its original entries plus all of the entries
 // in viewB.  It will replace any entries in viewB which also exist in viewA
-// if the entry in viewA is fully spent.
+// if the entry in viewA is fully spent.  The entries are cloned since viewB
+// may be shared by several block templates.
 func mergeUtxoView(viewA *blockchain.UtxoViewpoint, viewB *blockchain.UtxoViewpoint) {
 	viewAEntries := viewA.Entries()
 	for hash, entryB := range viewB.Entries() {
 		if entryA, exists := viewAEntries[hash]; !exists ||
 			entryA == nil || entryA.IsFullySpent() {
 
-			viewAEntries[hash] = entryB
+			viewAEntries[hash] = entryB.Clone()
 		}
 	}
 }
@@ -245,29 +275,31 @@ func standardCoinbaseScript(nextBlockHeight int32, extraNonce uint64) ([]byte, e
 }
 
 // createCoinbaseTx returns a coinbase transaction paying an appropriate subsidy
-// based on the passed block height to the provided address.  When the address
-// is nil, the coinbase transaction will instead be redeemable by anyone.
+// based on the passed block height to the provided address, split according to
+// the passed coinbase split if it is not nil.  When the address is nil, the
+// coinbase transaction will instead be redeemable by anyone.
 //
 // See the comment for NewBlockTemplate for more information about why the nil
 // address handling is useful.
-func createCoinbaseTx(params *chaincfg.Params, coinbaseScript []byte, nextBlockHeight int32, addr navutil.Address) (*navutil.Tx, error) {
-	// Create the script to pay to the provided payment address if one was
-	// specified.  Otherwise create a script that allows the coinbase to be
+func createCoinbaseTx(params *chaincfg.Params, coinbaseScript []byte, nextBlockHeight int32, addr navutil.Address, split *CoinbaseSplit) (*navutil.Tx, error) {
+	// Create the outputs to pay to the provided payment address if one was
+	// specified.  Otherwise create an output that allows the coinbase to be
 	// redeemable by anyone.
-	var pkScript []byte
+	value := blockchain.CalcBlockSubsidy(nextBlockHeight, params)
+	var outputs []*wire.TxOut
 	if addr != nil {
 		var err error
-		pkScript, err = txscript.PayToAddrScript(addr)
+		outputs, err = coinbaseOutputs(value, addr, split)
 		if err != nil {
 			return nil, err
 		}
 	} else {
-		var err error
 		scriptBuilder := txscript.NewScriptBuilder()
-		pkScript, err = scriptBuilder.AddOp(txscript.OP_TRUE).Script()
+		pkScript, err := scriptBuilder.AddOp(txscript.OP_TRUE).Script()
 		if err != nil {
 			return nil, err
 		}
+		outputs = []*wire.TxOut{wire.NewTxOut(value, pkScript)}
 	}
 
 	tx := wire.NewMsgTx(wire.TxVersion)
@@ -279,10 +311,7 @@ func createCoinbaseTx(params *chaincfg.Params, coinbaseScript []byte, nextBlockH
 		SignatureScript: coinbaseScript,
 		Sequence:        wire.MaxTxInSequenceNum,
 	})
-	tx.AddTxOut(&wire.TxOut{
-		Value:    blockchain.CalcBlockSubsidy(nextBlockHeight, params),
-		PkScript: pkScript,
-	})
+	tx.TxOut = outputs
 	return navutil.NewTx(tx), nil
 }
 
@@ -305,14 +334,10 @@ func spendTransaction(utxoView *blockchain.UtxoViewpoint, tx *navutil.Tx, height
 
 // logSkippedDeps logs any dependencies which are also skipped as a result of
 // skipping a transaction while generating a block template at the trace level.
-func logSkippedDeps(tx *navutil.Tx, deps map[chainhash.Hash]*txPrioItem) {
-	if deps == nil {
-		return
-	}
-
-	for _, item := range deps {
+func logSkippedDeps(candidate *TxCandidate) {
+	for _, child := range candidate.Children {
 		log.Tracef("Skipping tx %s since it depends on %s\n",
-			item.tx.Hash(), tx.Hash())
+			child.Tx.Hash(), candidate.Tx.Hash())
 	}
 }
 
@@ -355,6 +380,26 @@ type BlkTmplGenerator struct {
 	timeSource  blockchain.MedianTimeSource
 	sigCache    *txscript.SigCache
 	hashCache   *txscript.HashCache
+
+	// cacheMtx protects the details about the source transactions and the
+	// most recent block template which are reused by block templates built
+	// on top of the same best chain block.
+	cacheMtx sync.Mutex
+	txCache  *txCache
+
+	// splitMtx protects how the value of the coinbase is split.
+	splitMtx sync.Mutex
+	split    *CoinbaseSplit
+
+	// statsMtx protects the statistics about the built block templates.
+	statsMtx       sync.Mutex
+	stats          TemplateStats
+	totalBuildTime time.Duration
+
+	// historyMtx protects the history of the blocks generated from the
+	// block templates.
+	historyMtx sync.Mutex
+	history    []GeneratedBlock
 }
 
 // NewBlkTmplGenerator returns a new block template generator for the given
@@ -380,6 +425,166 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 	}
 }
 
+// templateBuilder houses the state of a block template while a transaction
+// selector adds transactions to it.  It implements the TemplateBuilder
+// interface and enforces the block limits and consensus rules for every
+// transaction added.
+type templateBuilder struct {
+	g               *BlkTmplGenerator
+	nextBlockHeight int32
+	coinbaseTx      *navutil.Tx
+	segwitActive    bool
+	witnessIncluded bool
+	blockUtxos      *blockchain.UtxoViewpoint
+	blockTxns       []*navutil.Tx
+	added           map[*TxCandidate]struct{}
+	blockWeight     uint32
+	blockSigOpCost  int64
+	totalFees       int64
+	txFees          []int64
+	txSigOpCosts    []int64
+}
+
+// Ensure templateBuilder implements the TemplateBuilder interface.
+var _ TemplateBuilder = (*templateBuilder)(nil)
+
+// BlockWeight returns the current weight of the block template.
+//
+// This is part of the TemplateBuilder interface.
+func (b *templateBuilder) BlockWeight() uint32 {
+	return b.blockWeight
+}
+
+// AddTransaction attempts to add the passed candidate to the block template
+// and returns whether or not it was added.
+//
+// This is part of the TemplateBuilder interface.
+func (b *templateBuilder) AddTransaction(candidate *TxCandidate) bool {
+	if err := b.addTransaction(candidate); err != nil {
+		log.Tracef("Skipping tx %s: %v", candidate.Tx.Hash(), err)
+		logSkippedDeps(candidate)
+		return false
+	}
+
+	log.Tracef("Adding tx %s (priority %.2f, feePerKB %d)",
+		candidate.Tx.Hash(), candidate.Priority, candidate.FeePerKB)
+	return true
+}
+
+// addTransaction attempts to add the passed candidate to the block template
+// and returns the reason it was not added, if any.
+func (b *templateBuilder) addTransaction(candidate *TxCandidate) error {
+	tx := candidate.Tx
+	if _, ok := b.added[candidate]; ok {
+		return errors.New("it is already in the block")
+	}
+	for _, parent := range candidate.Parents {
+		if _, ok := b.added[parent]; !ok {
+			return fmt.Errorf("it depends on %s which is not in "+
+				"the block", parent.Tx.Hash())
+		}
+	}
+
+	blockWeight := b.blockWeight
+	witnessIncluded := b.witnessIncluded
+	switch {
+	// If segregated witness has not been activated yet, then we
+	// shouldn't include any witness transactions in the block.
+	case !b.segwitActive && tx.HasWitness():
+		return errors.New("it has witness data before segwit is " +
+			"active")
+
+	// Otherwise, Keep track of if we've included a transaction
+	// with witness data or not. If so, then we'll need to include
+	// the witness commitment as the last output in the coinbase
+	// transaction.
+	case b.segwitActive && !witnessIncluded && tx.HasWitness():
+		// If we're about to include a transaction bearing
+		// witness data, then we'll also need to include a
+		// witness commitment in the coinbase transaction.
+		// Therefore, we account for the additional weight
+		// within the block with a model coinbase tx with a
+		// witness commitment.
+		coinbaseCopy := navutil.NewTx(b.coinbaseTx.MsgTx().Copy())
+		coinbaseCopy.MsgTx().TxIn[0].Witness = [][]byte{
+			bytes.Repeat([]byte("a"),
+				blockchain.CoinbaseWitnessDataLen),
+		}
+		coinbaseCopy.MsgTx().AddTxOut(&wire.TxOut{
+			PkScript: bytes.Repeat([]byte("a"),
+				blockchain.CoinbaseWitnessPkScriptLength),
+		})
+
+		// In order to accurately account for the weight
+		// addition due to this coinbase transaction, we'll add
+		// the difference of the transaction before and after
+		// the addition of the commitment to the block weight.
+		weightDiff := blockchain.GetTransactionWeight(coinbaseCopy) -
+			blockchain.GetTransactionWeight(b.coinbaseTx)
+
+		blockWeight += uint32(weightDiff)
+
+		witnessIncluded = true
+	}
+
+	// Enforce maximum block size.  Also check for overflow.
+	txWeight := uint32(candidate.Weight)
+	blockPlusTxWeight := blockWeight + txWeight
+	if blockPlusTxWeight < blockWeight ||
+		blockPlusTxWeight >= b.g.policy.BlockMaxWeight {
+
+		return errors.New("it would exceed the max block weight")
+	}
+
+	// Enforce maximum signature operation cost per block.  Also
+	// check for overflow.
+	sigOpCost, err := blockchain.GetSigOpCost(tx, false,
+		b.blockUtxos, true, b.segwitActive)
+	if err != nil {
+		return fmt.Errorf("error in GetSigOpCost: %v", err)
+	}
+	if b.blockSigOpCost+int64(sigOpCost) < b.blockSigOpCost ||
+		b.blockSigOpCost+int64(sigOpCost) > blockchain.MaxBlockSigOpsCost {
+
+		return errors.New("it would exceed the maximum sigops per " +
+			"block")
+	}
+
+	// Ensure the transaction inputs pass all of the necessary
+	// preconditions before allowing it to be added to the block.
+	fee, err := blockchain.CheckTransactionInputs(tx, b.nextBlockHeight,
+		b.blockUtxos, b.g.chainParams)
+	if err != nil {
+		return fmt.Errorf("error in CheckTransactionInputs: %v", err)
+	}
+	err = blockchain.ValidateTransactionScripts(tx, b.blockUtxos,
+		txscript.StandardVerifyFlags, b.g.sigCache,
+		b.g.hashCache)
+	if err != nil {
+		return fmt.Errorf("error in ValidateTransactionScripts: %v",
+			err)
+	}
+
+	// Spend the transaction inputs in the block utxo view and add
+	// an entry for it to ensure any transactions which reference
+	// this one have it available as an input and can ensure they
+	// aren't double spending.
+	spendTransaction(b.blockUtxos, tx, b.nextBlockHeight)
+
+	// Add the transaction to the block, increment counters, and
+	// save the fees and signature operation counts to the block
+	// template.
+	b.added[candidate] = struct{}{}
+	b.blockTxns = append(b.blockTxns, tx)
+	b.blockWeight = blockWeight + txWeight
+	b.witnessIncluded = witnessIncluded
+	b.blockSigOpCost += int64(sigOpCost)
+	b.totalFees += fee
+	b.txFees = append(b.txFees, fee)
+	b.txSigOpCosts = append(b.txSigOpCosts, int64(sigOpCost))
+	return nil
+}
+
 // NewBlockTemplate returns a new block template that is ready to be solved
 // using the transactions from the passed transaction source pool and a coinbase
 // that either pays to the passed address if it is not nil, or a coinbase that
@@ -389,13 +594,26 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 // coinbase which will replace the one generated for the block template.  Thus
 // the need to have configured address can be avoided.
 //
-// The transactions selected and included are prioritized according to several
-// factors.  First, each transaction has a priority calculated based on its
+// The transactions included are chosen by the TxSelector policy setting, while
+// the generator ensures every chosen transaction keeps the block valid.  With
+// the default PriorityTxSelector, the transactions selected and included are
+// prioritized according to several factors.  First, each transaction has a priority calculated based on its
 // value, age of inputs, and size.  Transactions which consist of larger
 // amounts, older inputs, and small sizes have the highest priority.  Second, a
 // fee per kilobyte is calculated for each transaction.  Transactions with a
-// higher fee per kilobyte are preferred.  Finally, the block generation related
-// policy settings are all taken into account.
+// higher fee per kilobyte are preferred.  The fee per kilobyte includes any fee
+// delta applied to the transaction by the operator.  Finally, the block
+// generation related policy settings are all taken into account.
+//
+// Block templates are updated incrementally.  The most recent template is
+// handed out again with an updated timestamp while neither the best chain nor
+// the source pool changed for a few seconds.  The details about the source
+// transactions which only change with the best chain, such as the outputs they
+// spend and their priority, are cached between templates, so when the source
+// pool changed only the transactions added to it since the previous template
+// are gathered and the ones removed from it are dropped before the
+// transactions are selected again.  Every transaction is gathered again when
+// the best chain changed or the cache timed out.
 //
 // Transactions which only spend outputs from other transactions already in the
 // block chain are immediately added to a priority queue which either
@@ -443,70 +661,72 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 //  |  <= policy.BlockMinSize)          |   |
 //   -----------------------------------  --
 func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress navutil.Address) (*BlockTemplate, error) {
+	buildStart := time.Now()
+
 	// Extend the most recently known best block.
 	best := g.chain.BestSnapshot()
 	nextBlockHeight := best.Height + 1
 
-	// Create a standard coinbase transaction paying to the provided
-	// address.  NOTE: The coinbase value will be updated to include the
-	// fees from the selected transactions later after they have actually
-	// been selected.  It is created here to detect any errors early
-	// before potentially doing a lot of work below.  The extra nonce helps
-	// ensure the transaction is not a duplicate transaction (paying the
-	// same value to the same public key address would otherwise be an
-	// identical transaction for block version 1).
-	extraNonce := uint64(0)
-	coinbaseScript, err := standardCoinbaseScript(nextBlockHeight, extraNonce)
+	// Hand out the most recent template again when it was built with the
+	// same parameters and the source pool has not changed since.  The time
+	// the source pool was last updated is read before its transactions, so
+	// any change made while the template is built is noticed by the next
+	// one.
+	sourceUpdated := g.txSource.LastUpdated()
+	split := g.CoinbaseSplit()
+	payAddress := encodePayAddress(payToAddress)
+	template, err := g.reuseTemplate(&best.Hash, payAddress, split,
+		sourceUpdated)
 	if err != nil {
 		return nil, err
 	}
-	coinbaseTx, err := createCoinbaseTx(g.chainParams, coinbaseScript,
-		nextBlockHeight, payToAddress)
-	if err != nil {
-		return nil, err
+	if template != nil {
+		g.recordReuse()
+		log.Debugf("Reusing block template since the source pool " +
+			"has not changed")
+		return template, nil
 	}
-	coinbaseSigOpCost := int64(blockchain.CountSigOps(coinbaseTx)) * blockchain.WitnessScaleFactor
 
-	// Get the current source transactions and create a priority queue to
-	// hold the transactions which are ready for inclusion into a block
-	// along with some priority related and fee metadata.  Reserve the same
-	// number of items that are available for the priority queue.  Also,
-	// choose the initial sort order for the priority queue based on whether
-	// or not there is an area allocated for high-priority transactions.
+	// Get the current source transactions and gather the ones which are
+	// ready for inclusion into a block along with some priority related and
+	// fee metadata as candidates for the transaction selector.  Also,
+	// create a utxo view to house all of the input transactions so multiple
+	// lookups can be avoided.
+	//
+	// The details which only change with the best chain are cached, so only
+	// the transactions added to the source pool since the previous template
+	// have to be gathered unless the best chain changed or the cache timed
+	// out.
 	sourceTxns := g.txSource.MiningDescs()
-	sortedByFee := g.policy.BlockPrioritySize == 0
-	priorityQueue := newTxPriorityQueue(len(sourceTxns), sortedByFee)
-
-	// Create a slice to hold the transactions to be included in the
-	// generated block with reserved space.  Also create a utxo view to
-	// house all of the input transactions so multiple lookups can be
-	// avoided.
-	blockTxns := make([]*navutil.Tx, 0, len(sourceTxns))
-	blockTxns = append(blockTxns, coinbaseTx)
+
+	// Create the builder which houses the block template while the
+	// transactions are selected along with the coinbase transaction.  It is
+	// created here to detect any errors early before potentially doing a
+	// lot of work below.
 	blockUtxos := blockchain.NewUtxoViewpoint()
+	builder, err := g.newTemplateBuilder(nextBlockHeight, payToAddress,
+		split, blockUtxos, len(sourceTxns))
+	if err != nil {
+		return nil, err
+	}
 
-	// dependers is used to track transactions which depend on another
-	// transaction in the source pool.  This, in conjunction with the
-	// dependsOn map kept with each dependent transaction helps quickly
-	// determine which dependent transactions are now eligible for inclusion
-	// in the block once each transaction has been included.
-	dependers := make(map[chainhash.Hash]map[chainhash.Hash]*txPrioItem)
+	candidates := make([]*TxCandidate, 0, len(sourceTxns))
+	candidateMap := make(map[chainhash.Hash]*TxCandidate, len(sourceTxns))
 
-	// Create slices to hold the fees and number of signature operations
-	// for each of the selected transactions and add an entry for the
-	// coinbase.  This allows the code below to simply append details about
-	// a transaction as it is selected for inclusion in the final block.
-	// However, since the total fees aren't known yet, use a dummy value for
-	// the coinbase fee which will be updated later.
-	txFees := make([]int64, 0, len(sourceTxns))
-	txSigOpCosts := make([]int64, 0, len(sourceTxns))
-	txFees = append(txFees, -1) // Updated once known
-	txSigOpCosts = append(txSigOpCosts, coinbaseSigOpCost)
+	// dependsOn is used to track the transactions in the source pool each
+	// candidate depends on so the candidates can be linked once they are
+	// all known.
+	dependsOn := make(map[*TxCandidate]map[chainhash.Hash]struct{})
 
 	log.Debugf("Considering %d transactions for inclusion to new block",
 		len(sourceTxns))
 
-mempoolLoop:
+	g.cacheMtx.Lock()
+	fullRebuild := g.txCache == nil || g.txCache.expired(&best.Hash)
+	if fullRebuild {
+		g.txCache = newTxCache(&best.Hash)
+	}
+	seen := make(map[chainhash.Hash]struct{}, len(sourceTxns))
 	for _, txDesc := range sourceTxns {
 		// A block can't have more than one coinbase or contain
 		// non-finalized transactions.
@@ -522,290 +742,333 @@ mempoolLoop:
 			continue
 		}
 
-		// Fetch all of the utxos referenced by the this transaction.
-		// NOTE: This intentionally does not fetch inputs from the
-		// mempool since a transaction which depends on other
-		// transactions in the mempool must come after those
-		// dependencies in the final generated block.
-		utxos, err := g.chain.FetchUtxoView(tx)
-		if err != nil {
-			log.Warnf("Unable to fetch utxo view for tx %s: %v",
-				tx.Hash(), err)
-			continue
-		}
-
-		// Setup dependencies for any transactions which reference
-		// other transactions in the mempool so they can be properly
-		// ordered below.
-		prioItem := &txPrioItem{tx: tx}
-		for _, txIn := range tx.MsgTx().TxIn {
-			originHash := &txIn.PreviousOutPoint.Hash
-			originIndex := txIn.PreviousOutPoint.Index
-			utxoEntry := utxos.LookupEntry(originHash)
-			if utxoEntry == nil || utxoEntry.IsOutputSpent(originIndex) {
-				if !g.txSource.HaveTransaction(originHash) {
-					log.Tracef("Skipping tx %s because it "+
-						"references unspent output %s "+
-						"which is not available",
-						tx.Hash(), txIn.PreviousOutPoint)
-					continue mempoolLoop
-				}
-
-				// The transaction is referencing another
-				// transaction in the source pool, so setup an
-				// ordering dependency.
-				deps, exists := dependers[*originHash]
-				if !exists {
-					deps = make(map[chainhash.Hash]*txPrioItem)
-					dependers[*originHash] = deps
-				}
-				deps[*prioItem.tx.Hash()] = prioItem
-				if prioItem.dependsOn == nil {
-					prioItem.dependsOn = make(
-						map[chainhash.Hash]struct{})
-				}
-				prioItem.dependsOn[*originHash] = struct{}{}
-
-				// Skip the check below. We already know the
-				// referenced transaction is available.
+		// Gather the details about transactions which are not cached
+		// yet.  Transactions which can't be included are not cached so
+		// they are checked again by later templates.
+		cached, ok := g.txCache.txns[*tx.Hash()]
+		if !ok {
+			cached = g.gatherTx(tx, nextBlockHeight)
+			if cached == nil {
 				continue
 			}
+			g.txCache.txns[*tx.Hash()] = cached
 		}
-
-		// Calculate the final transaction priority using the input
-		// value age sum as well as the adjusted transaction size.  The
-		// formula is: sum(inputValue * inputAge) / adjustedTxSize
-		prioItem.priority = CalcPriority(tx.MsgTx(), utxos,
-			nextBlockHeight)
-
-		// Calculate the fee in Satoshi/kB.
-		prioItem.feePerKB = txDesc.FeePerKB
-		prioItem.fee = txDesc.Fee
-
-		// Add the transaction to the priority queue to mark it ready
-		// for inclusion in the block unless it has dependencies.
-		if prioItem.dependsOn == nil {
-			heap.Push(priorityQueue, prioItem)
+		seen[*tx.Hash()] = struct{}{}
+
+		// Calculate the fee in Satoshi/kB.  Any fee delta that has been
+		// applied to the transaction is taken into account for ordering
+		// purposes only, so the actual fee is tracked separately.
+		candidate := &TxCandidate{
+			Tx:          tx,
+			Fee:         txDesc.Fee,
+			ModifiedFee: txDesc.Fee + txDesc.FeeDelta,
+			FeePerKB:    txDesc.ModifiedFeePerKB(),
+			Priority:    cached.priority,
+			Weight:      cached.weight,
+		}
+		if cached.dependsOn != nil {
+			dependsOn[candidate] = cached.dependsOn
 		}
+		candidates = append(candidates, candidate)
+		candidateMap[*tx.Hash()] = candidate
 
 		// Merge the referenced outputs from the input transactions to
 		// this transaction into the block utxo view.  This allows the
 		// code below to avoid a second lookup.
-		mergeUtxoView(blockUtxos, utxos)
+		mergeUtxoView(blockUtxos, cached.utxos)
 	}
 
-	log.Tracef("Priority queue len %d, dependers len %d",
-		priorityQueue.Len(), len(dependers))
+	// Remove the transactions which are no longer in the source pool from
+	// the cache.
+	for hash := range g.txCache.txns {
+		if _, ok := seen[hash]; !ok {
+			delete(g.txCache.txns, hash)
+		}
+	}
+	g.cacheMtx.Unlock()
+
+	// Link the candidates which depend on other candidates.  Candidates
+	// which depend on transactions in the source pool which are not
+	// candidates themselves can never be included, so they are removed.
+	// Any of their descendants are never added either since their parents
+	// are missing.
+	readyCandidates := candidates[:0]
+	for _, candidate := range candidates {
+		available := true
+		for originHash := range dependsOn[candidate] {
+			parent, ok := candidateMap[originHash]
+			if !ok {
+				available = false
+				continue
+			}
+			candidate.Parents = append(candidate.Parents, parent)
+			parent.Children = append(parent.Children, candidate)
+		}
+		if !available {
+			log.Tracef("Skipping tx %s because it depends on a "+
+				"transaction which can't be included",
+				candidate.Tx.Hash())
+			continue
+		}
+		readyCandidates = append(readyCandidates, candidate)
+	}
+	candidates = readyCandidates
 
-	// The starting block size is the size of the block header plus the max
-	// possible transaction count size, plus the size of the coinbase
-	// transaction.
-	blockWeight := uint32((blockHeaderOverhead * blockchain.WitnessScaleFactor) +
-		blockchain.GetTransactionWeight(coinbaseTx))
-	blockSigOpCost := coinbaseSigOpCost
-	totalFees := int64(0)
+	log.Tracef("Candidates len %d, dependers len %d", len(candidates),
+		len(dependsOn))
 
-	// Query the version bits state to see if segwit has been activated, if
-	// so then this means that we'll include any transactions with witness
-	// data in the mempool, and also add the witness commitment as an
-	// OP_RETURN output in the coinbase transaction.
-	segwitState, err := g.chain.ThresholdState(chaincfg.DeploymentSegwit)
+	// Choose which transactions make it into the block.
+	selector := g.policy.TxSelector
+	if selector == nil {
+		selector = PriorityTxSelector{}
+	}
+	selector.SelectTransactions(candidates, g.policy, builder)
+
+	template, err = g.finishBlockTemplate(best, builder, payToAddress,
+		split)
 	if err != nil {
 		return nil, err
 	}
-	segwitActive := segwitState == blockchain.ThresholdActive
 
-	witnessIncluded := false
+	buildTime := time.Since(buildStart)
+	g.recordBuild(buildTime, fullRebuild)
+	template.BuildTime = buildTime
+	log.Debugf("Built block template in %v", buildTime)
 
-	// Choose which transactions make it into the block.
-	for priorityQueue.Len() > 0 {
-		// Grab the highest priority (or highest fee per kilobyte
-		// depending on the sort order) transaction.
-		prioItem := heap.Pop(priorityQueue).(*txPrioItem)
-		tx := prioItem.tx
-
-		switch {
-		// If segregated witness has not been activated yet, then we
-		// shouldn't include any witness transactions in the block.
-		case !segwitActive && tx.HasWitness():
-			continue
-
-		// Otherwise, Keep track of if we've included a transaction
-		// with witness data or not. If so, then we'll need to include
-		// the witness commitment as the last output in the coinbase
-		// transaction.
-		case segwitActive && !witnessIncluded && tx.HasWitness():
-			// If we're about to include a transaction bearing
-			// witness data, then we'll also need to include a
-			// witness commitment in the coinbase transaction.
-			// Therefore, we account for the additional weight
-			// within the block with a model coinbase tx with a
-			// witness commitment.
-			coinbaseCopy := navutil.NewTx(coinbaseTx.MsgTx().Copy())
-			coinbaseCopy.MsgTx().TxIn[0].Witness = [][]byte{
-				bytes.Repeat([]byte("a"),
-					blockchain.CoinbaseWitnessDataLen),
-			}
-			coinbaseCopy.MsgTx().AddTxOut(&wire.TxOut{
-				PkScript: bytes.Repeat([]byte("a"),
-					blockchain.CoinbaseWitnessPkScriptLength),
-			})
-
-			// In order to accurately account for the weight
-			// addition due to this coinbase transaction, we'll add
-			// the difference of the transaction before and after
-			// the addition of the commitment to the block weight.
-			weightDiff := blockchain.GetTransactionWeight(coinbaseCopy) -
-				blockchain.GetTransactionWeight(coinbaseTx)
+	g.cacheTemplate(&best.Hash, payAddress, split, sourceUpdated,
+		buildStart, template)
+	return template, nil
+}
 
-			blockWeight += uint32(weightDiff)
+// NewBlockTemplateWithTxns returns a new block template that is ready to be
+// solved containing exactly the passed transactions, in the given order, and a
+// coinbase paying to the passed address in the same way as NewBlockTemplate.
+//
+// Unlike NewBlockTemplate, the transactions do not have to be in the source
+// pool and no selection policy is applied.  Every transaction must be valid
+// when added to the block in order, which means a transaction may spend the
+// outputs of a transaction before it in the list.  An error describing the
+// first transaction which can't be included is returned otherwise.
+func (g *BlkTmplGenerator) NewBlockTemplateWithTxns(payToAddress navutil.Address,
+	txns []*navutil.Tx) (*BlockTemplate, error) {
 
-			witnessIncluded = true
-		}
+	buildStart := time.Now()
 
-		// Grab any transactions which depend on this one.
-		deps := dependers[*tx.Hash()]
+	// Extend the most recently known best block.
+	best := g.chain.BestSnapshot()
+	nextBlockHeight := best.Height + 1
 
-		// Enforce maximum block size.  Also check for overflow.
-		txWeight := uint32(blockchain.GetTransactionWeight(tx))
-		blockPlusTxWeight := blockWeight + txWeight
-		if blockPlusTxWeight < blockWeight ||
-			blockPlusTxWeight >= g.policy.BlockMaxWeight {
+	split := g.CoinbaseSplit()
+	blockUtxos := blockchain.NewUtxoViewpoint()
+	builder, err := g.newTemplateBuilder(nextBlockHeight, payToAddress,
+		split, blockUtxos, len(txns))
+	if err != nil {
+		return nil, err
+	}
 
-			log.Tracef("Skipping tx %s because it would exceed "+
-				"the max block weight", tx.Hash())
-			logSkippedDeps(tx, deps)
-			continue
+	// Gather the details about every transaction and ensure the outputs
+	// they spend are either in the best chain or created by a transaction
+	// earlier in the list.
+	candidates := make([]*TxCandidate, 0, len(txns))
+	candidateMap := make(map[chainhash.Hash]*TxCandidate, len(txns))
+	for _, tx := range txns {
+		if blockchain.IsCoinBase(tx) {
+			return nil, fmt.Errorf("transaction %s is a coinbase",
+				tx.Hash())
 		}
+		if !blockchain.IsFinalizedTransaction(tx, nextBlockHeight,
+			g.timeSource.AdjustedTime()) {
 
-		// Enforce maximum signature operation cost per block.  Also
-		// check for overflow.
-		sigOpCost, err := blockchain.GetSigOpCost(tx, false,
-			blockUtxos, true, segwitActive)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"GetSigOpCost: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
+			return nil, fmt.Errorf("transaction %s is not finalized",
+				tx.Hash())
 		}
-		if blockSigOpCost+int64(sigOpCost) < blockSigOpCost ||
-			blockSigOpCost+int64(sigOpCost) > blockchain.MaxBlockSigOpsCost {
-			log.Tracef("Skipping tx %s because it would "+
-				"exceed the maximum sigops per block", tx.Hash())
-			logSkippedDeps(tx, deps)
-			continue
+		if _, ok := candidateMap[*tx.Hash()]; ok {
+			return nil, fmt.Errorf("transaction %s is included more "+
+				"than once", tx.Hash())
 		}
 
-		// Skip free transactions once the block is larger than the
-		// minimum block size.
-		if sortedByFee &&
-			prioItem.feePerKB < int64(g.policy.TxMinFreeFee) &&
-			blockPlusTxWeight >= g.policy.BlockMinWeight {
-
-			log.Tracef("Skipping tx %s with feePerKB %d "+
-				"< TxMinFreeFee %d and block weight %d >= "+
-				"minBlockWeight %d", tx.Hash(), prioItem.feePerKB,
-				g.policy.TxMinFreeFee, blockPlusTxWeight,
-				g.policy.BlockMinWeight)
-			logSkippedDeps(tx, deps)
-			continue
+		utxos, err := g.chain.FetchUtxoView(tx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch utxo view for "+
+				"transaction %s: %v", tx.Hash(), err)
 		}
 
-		// Prioritize by fee per kilobyte once the block is larger than
-		// the priority size or there are no more high-priority
-		// transactions.
-		if !sortedByFee && (blockPlusTxWeight >= g.policy.BlockPrioritySize ||
-			prioItem.priority <= MinHighPriority) {
-
-			log.Tracef("Switching to sort by fees per "+
-				"kilobyte blockSize %d >= BlockPrioritySize "+
-				"%d || priority %.2f <= minHighPriority %.2f",
-				blockPlusTxWeight, g.policy.BlockPrioritySize,
-				prioItem.priority, MinHighPriority)
-
-			sortedByFee = true
-			priorityQueue.SetLessFunc(txPQByFee)
-
-			// Put the transaction back into the priority queue and
-			// skip it so it is re-priortized by fees if it won't
-			// fit into the high-priority section or the priority
-			// is too low.  Otherwise this transaction will be the
-			// final one in the high-priority section, so just fall
-			// though to the code below so it is added now.
-			if blockPlusTxWeight > g.policy.BlockPrioritySize ||
-				prioItem.priority < MinHighPriority {
-
-				heap.Push(priorityQueue, prioItem)
+		candidate := &TxCandidate{
+			Tx:       tx,
+			Priority: CalcPriority(tx.MsgTx(), utxos, nextBlockHeight),
+			Weight:   blockchain.GetTransactionWeight(tx),
+		}
+		for _, txIn := range tx.MsgTx().TxIn {
+			originHash := &txIn.PreviousOutPoint.Hash
+			originIndex := txIn.PreviousOutPoint.Index
+			utxoEntry := utxos.LookupEntry(originHash)
+			if utxoEntry != nil && !utxoEntry.IsOutputSpent(originIndex) {
 				continue
 			}
+			parent, ok := candidateMap[*originHash]
+			if !ok {
+				return nil, fmt.Errorf("transaction %s references "+
+					"output %s which is not available",
+					tx.Hash(), txIn.PreviousOutPoint)
+			}
+			candidate.Parents = append(candidate.Parents, parent)
 		}
+		candidates = append(candidates, candidate)
+		candidateMap[*tx.Hash()] = candidate
 
-		// Ensure the transaction inputs pass all of the necessary
-		// preconditions before allowing it to be added to the block.
-		_, err = blockchain.CheckTransactionInputs(tx, nextBlockHeight,
-			blockUtxos, g.chainParams)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"CheckTransactionInputs: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
+		mergeUtxoView(blockUtxos, utxos)
+	}
+
+	for _, candidate := range candidates {
+		if err := builder.addTransaction(candidate); err != nil {
+			return nil, fmt.Errorf("unable to include transaction "+
+				"%s: %v", candidate.Tx.Hash(), err)
 		}
-		err = blockchain.ValidateTransactionScripts(tx, blockUtxos,
-			txscript.StandardVerifyFlags, g.sigCache,
-			g.hashCache)
+	}
+
+	template, err := g.finishBlockTemplate(best, builder, payToAddress,
+		split)
+	if err != nil {
+		return nil, err
+	}
+	template.BuildTime = time.Since(buildStart)
+	return template, nil
+}
+
+// newTemplateBuilder creates the coinbase transaction for a block at the
+// provided height along with a template builder seeded with it.  The passed
+// utxo view is used by the builder when checking the inputs of the
+// transactions added to it.
+func (g *BlkTmplGenerator) newTemplateBuilder(nextBlockHeight int32,
+	payToAddress navutil.Address, split *CoinbaseSplit,
+	blockUtxos *blockchain.UtxoViewpoint, numTxns int) (*templateBuilder, error) {
+
+	// Create a standard coinbase transaction paying to the provided
+	// address.  NOTE: The coinbase value will be updated to include the
+	// fees from the selected transactions later after they have actually
+	// been selected.  It is created here to detect any errors early
+	// before potentially doing a lot of work below.  The extra nonce helps
+	// ensure the transaction is not a duplicate transaction (paying the
+	// same value to the same public key address would otherwise be an
+	// identical transaction for block version 1).
+	extraNonce := uint64(0)
+	coinbaseScript, err := standardCoinbaseScript(nextBlockHeight, extraNonce)
+	if err != nil {
+		return nil, err
+	}
+	coinbaseTx, err := createCoinbaseTx(g.chainParams, coinbaseScript,
+		nextBlockHeight, payToAddress, split)
+	if err != nil {
+		return nil, err
+	}
+	coinbaseSigOpCost := int64(blockchain.CountSigOps(coinbaseTx)) * blockchain.WitnessScaleFactor
+
+	// The coinbase of a template without a payment address is rewritten to
+	// pay the coinbase split when one is set and the getblocktemplate
+	// caller asks for the coinbase, so reserve the weight and signature
+	// operation cost of a coinbase paying the split in that case.
+	coinbaseWeight := blockchain.GetTransactionWeight(coinbaseTx)
+	reservedSigOpCost := coinbaseSigOpCost
+	if payToAddress == nil && split != nil && len(split.Payouts) > 0 {
+		splitTx, err := createCoinbaseTx(g.chainParams, coinbaseScript,
+			nextBlockHeight, split.Payouts[0].Addr, split)
 		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"ValidateTransactionScripts: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
+			return nil, err
 		}
-
-		// Spend the transaction inputs in the block utxo view and add
-		// an entry for it to ensure any transactions which reference
-		// this one have it available as an input and can ensure they
-		// aren't double spending.
-		spendTransaction(blockUtxos, tx, nextBlockHeight)
-
-		// Add the transaction to the block, increment counters, and
-		// save the fees and signature operation counts to the block
-		// template.
-		blockTxns = append(blockTxns, tx)
-		blockWeight += txWeight
-		blockSigOpCost += int64(sigOpCost)
-		totalFees += prioItem.fee
-		txFees = append(txFees, prioItem.fee)
-		txSigOpCosts = append(txSigOpCosts, int64(sigOpCost))
-
-		log.Tracef("Adding tx %s (priority %.2f, feePerKB %.2f)",
-			prioItem.tx.Hash(), prioItem.priority, prioItem.feePerKB)
-
-		// Add transactions which depend on this one (and also do not
-		// have any other unsatisified dependencies) to the priority
-		// queue.
-		for _, item := range deps {
-			// Add the transaction to the priority queue if there
-			// are no more dependencies after this one.
-			delete(item.dependsOn, *tx.Hash())
-			if len(item.dependsOn) == 0 {
-				heap.Push(priorityQueue, item)
-			}
+		splitWeight := blockchain.GetTransactionWeight(splitTx)
+		if splitWeight > coinbaseWeight {
+			coinbaseWeight = splitWeight
+		}
+		splitSigOpCost := int64(blockchain.CountSigOps(splitTx)) *
+			blockchain.WitnessScaleFactor
+		if splitSigOpCost > reservedSigOpCost {
+			reservedSigOpCost = splitSigOpCost
 		}
 	}
 
+	// Query the version bits state to see if segwit has been activated, if
+	// so then this means that we'll include any transactions with witness
+	// data in the mempool, and also add the witness commitment as an
+	// OP_RETURN output in the coinbase transaction.
+	segwitState, err := g.chain.ThresholdState(chaincfg.DeploymentSegwit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create slices to hold the fees and number of signature operations
+	// for each of the selected transactions and add an entry for the
+	// coinbase.  This allows the builder to simply append details about
+	// a transaction as it is selected for inclusion in the final block.
+	// However, since the total fees aren't known yet, use a dummy value for
+	// the coinbase fee which will be updated later.
+	//
+	// The starting block size is the size of the block header plus the max
+	// possible transaction count size, plus the size of the coinbase
+	// transaction.
+	builder := &templateBuilder{
+		g:               g,
+		nextBlockHeight: nextBlockHeight,
+		coinbaseTx:      coinbaseTx,
+		segwitActive:    segwitState == blockchain.ThresholdActive,
+		blockUtxos:      blockUtxos,
+		blockTxns:       make([]*navutil.Tx, 0, numTxns+1),
+		added:           make(map[*TxCandidate]struct{}, numTxns),
+		blockWeight: uint32((blockHeaderOverhead * blockchain.WitnessScaleFactor) +
+			coinbaseWeight),
+		blockSigOpCost: reservedSigOpCost,
+		txFees:         make([]int64, 0, numTxns+1),
+		txSigOpCosts:   make([]int64, 0, numTxns+1),
+	}
+	builder.blockTxns = append(builder.blockTxns, coinbaseTx)
+	builder.txFees = append(builder.txFees, -1) // Updated once known
+	builder.txSigOpCosts = append(builder.txSigOpCosts, coinbaseSigOpCost)
+
+	return builder, nil
+}
+
+// finishBlockTemplate completes the block template housed by the passed
+// builder once all of its transactions have been selected.  The coinbase
+// value is updated with the collected fees, the witness commitment is added
+// when needed, and the resulting block is checked against the consensus
+// rules before it is returned.
+func (g *BlkTmplGenerator) finishBlockTemplate(best *blockchain.BestState,
+	builder *templateBuilder, payToAddress navutil.Address,
+	split *CoinbaseSplit) (*BlockTemplate, error) {
+
+	nextBlockHeight := builder.nextBlockHeight
+	coinbaseTx := builder.coinbaseTx
+	blockTxns := builder.blockTxns
+	blockWeight := builder.blockWeight
+	blockSigOpCost := builder.blockSigOpCost
+	totalFees := builder.totalFees
+	txFees := builder.txFees
+	txSigOpCosts := builder.txSigOpCosts
+
 	// Now that the actual transactions have been selected, update the
 	// block weight for the real transaction count and coinbase value with
-	// the total fees accordingly.
+	// the total fees accordingly.  The fees are split the same way as the
+	// subsidy.
 	blockWeight -= wire.MaxVarIntPayload -
 		(uint32(wire.VarIntSerializeSize(uint64(len(blockTxns)))) *
 			blockchain.WitnessScaleFactor)
-	coinbaseTx.MsgTx().TxOut[0].Value += totalFees
+	if payToAddress != nil {
+		value := blockchain.CalcBlockSubsidy(nextBlockHeight,
+			g.chainParams) + totalFees
+		outputs, err := coinbaseOutputs(value, payToAddress, split)
+		if err != nil {
+			return nil, err
+		}
+		coinbaseTx.MsgTx().TxOut = outputs
+	} else {
+		coinbaseTx.MsgTx().TxOut[0].Value += totalFees
+	}
 	txFees[0] = -totalFees
 
 	// If segwit is active and we included transactions with witness data,
 	// then we'll need to include a commitment to the witness data in an
 	// OP_RETURN output within the coinbase transaction.
 	var witnessCommitment []byte
-	if witnessIncluded {
+	if builder.witnessIncluded {
 		// The witness of the coinbase transaction MUST be exactly 32-bytes
 		// of all zeroes.
 		var witnessNonce [blockchain.CoinbaseWitnessDataLen]byte
@@ -884,9 +1147,10 @@ mempoolLoop:
 	}
 
 	log.Debugf("Created new block template (%d transactions, %d in "+
-		"fees, %d signature operations cost, %d weight, target difficulty "+
-		"%064x)", len(msgBlock.Transactions), totalFees, blockSigOpCost,
-		blockWeight, blockchain.CompactToBig(msgBlock.Header.Bits))
+		"fees, %d signature operations cost, %d weight, target "+
+		"difficulty %064x)", len(msgBlock.Transactions),
+		totalFees, blockSigOpCost, blockWeight,
+		blockchain.CompactToBig(msgBlock.Header.Bits))
 
 	return &BlockTemplate{
 		Block:             &msgBlock,
@@ -0,0 +1,235 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/navcoin/navd/blockchain"
+	"github.com/navcoin/navd/chaincfg/chainhash"
+	"github.com/navcoin/navd/txscript"
+	"github.com/navcoin/navd/wire"
+	"github.com/navcoin/navutil"
+)
+
+const (
+	// MaxCoinbasePayouts is the maximum number of addresses the value of
+	// the coinbase can be split across.
+	MaxCoinbasePayouts = 16
+
+	// CoinbaseReservedWeight is the weight of a block which is reserved for
+	// its coinbase.  It is the difference between the maximum weight of a
+	// block allowed by the consensus rules and the maximum block weight
+	// allowed by the policy, so a coinbase which is replaced by external
+	// mining software or rewritten to split its value doesn't cause the
+	// block to become too large.
+	CoinbaseReservedWeight = 4000
+)
+
+// CoinbasePayout is an address which is paid a share of the value of the
+// coinbase of generated blocks in proportion to its weight.
+type CoinbasePayout struct {
+	Addr   navutil.Address
+	Weight uint32
+}
+
+// CoinbaseSplit describes how the value of the coinbase of generated blocks is
+// split across several addresses.
+type CoinbaseSplit struct {
+	// Payouts are the addresses the value is split across.  The payment
+	// address passed when creating a block template is paid the whole
+	// value instead when there are none.
+	Payouts []CoinbasePayout
+
+	// Tag is data which is added to the coinbase in a provably unspendable
+	// OP_RETURN output when it is not empty.
+	Tag []byte
+}
+
+// Validate returns an error when there are too many payouts, any of the payouts
+// has no weight, the total weight overflows, the tag is too large to be carried
+// by an OP_RETURN output, or a coinbase paying the split doesn't fit the weight
+// reserved for it.
+func (split *CoinbaseSplit) Validate() error {
+	if len(split.Payouts) > MaxCoinbasePayouts {
+		return fmt.Errorf("coinbase split has %d payouts which is more "+
+			"than the max of %d", len(split.Payouts),
+			MaxCoinbasePayouts)
+	}
+
+	var totalWeight uint64
+	for _, payout := range split.Payouts {
+		if payout.Addr == nil {
+			return errors.New("coinbase payout has no address")
+		}
+		if payout.Weight == 0 {
+			return errors.New("coinbase payout weight must be " +
+				"greater than 0")
+		}
+		totalWeight += uint64(payout.Weight)
+	}
+	if totalWeight > math.MaxInt32 {
+		return errors.New("total coinbase payout weight is too large")
+	}
+	if len(split.Tag) > txscript.MaxDataCarrierSize {
+		return errors.New("coinbase tag is too large")
+	}
+	if len(split.Payouts) == 0 {
+		return nil
+	}
+
+	// Ensure the largest possible coinbase paying the split, which has a
+	// coinbase script of the maximum length and a witness commitment, fits
+	// the weight reserved for the coinbase.
+	outputs, err := coinbaseOutputs(navutil.MaxSatoshi, nil, split)
+	if err != nil {
+		return err
+	}
+	witnessScript := append(blockchain.WitnessMagicBytes,
+		make([]byte, chainhash.HashSize)...)
+	outputs = append(outputs, wire.NewTxOut(0, witnessScript))
+	coinbaseTx := wire.NewMsgTx(wire.TxVersion)
+	coinbaseTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: make([]byte, blockchain.MaxCoinbaseScriptLen),
+		Witness: wire.TxWitness{
+			make([]byte, blockchain.CoinbaseWitnessDataLen),
+		},
+		Sequence: wire.MaxTxInSequenceNum,
+	})
+	coinbaseTx.TxOut = outputs
+	weight := blockchain.GetTransactionWeight(navutil.NewTx(coinbaseTx))
+	if weight > CoinbaseReservedWeight {
+		return fmt.Errorf("coinbase paying the split has a weight of "+
+			"%d which is more than the %d reserved for it", weight,
+			CoinbaseReservedWeight)
+	}
+	return nil
+}
+
+// coinbaseOutputs returns the outputs of a coinbase paying the passed value
+// according to the passed split.  Each payout is paid its share of the value
+// rounded down, while the first payout is also paid the remainder so the full
+// value is paid.  When the split has no payouts, the whole value is paid to the
+// passed address.
+func coinbaseOutputs(value int64, payToAddress navutil.Address, split *CoinbaseSplit) ([]*wire.TxOut, error) {
+	payouts := []CoinbasePayout{{Addr: payToAddress, Weight: 1}}
+	var tag []byte
+	if split != nil {
+		if len(split.Payouts) > 0 {
+			payouts = split.Payouts
+		}
+		tag = split.Tag
+	}
+
+	var totalWeight int64
+	for _, payout := range payouts {
+		totalWeight += int64(payout.Weight)
+	}
+
+	outputs := make([]*wire.TxOut, 0, len(payouts)+1)
+	remaining := value
+	for _, payout := range payouts {
+		pkScript, err := txscript.PayToAddrScript(payout.Addr)
+		if err != nil {
+			return nil, err
+		}
+		share := value / totalWeight * int64(payout.Weight)
+		share += value % totalWeight * int64(payout.Weight) / totalWeight
+		remaining -= share
+		outputs = append(outputs, wire.NewTxOut(share, pkScript))
+	}
+	outputs[0].Value += remaining
+
+	if len(tag) > 0 {
+		pkScript, err := txscript.NullDataScript(tag)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, wire.NewTxOut(0, pkScript))
+	}
+
+	return outputs, nil
+}
+
+// SetCoinbaseSplit sets how the value of the coinbase of block templates which
+// are created with a payment address is split.  Passing nil pays the whole
+// value to the payment address.
+//
+// This function is safe for concurrent access.
+func (g *BlkTmplGenerator) SetCoinbaseSplit(split *CoinbaseSplit) {
+	g.splitMtx.Lock()
+	g.split = split
+	g.splitMtx.Unlock()
+}
+
+// CoinbaseSplit returns how the value of the coinbase of block templates which
+// are created with a payment address is split.  It returns nil when the whole
+// value is paid to the payment address.
+//
+// This function is safe for concurrent access.
+func (g *BlkTmplGenerator) CoinbaseSplit() *CoinbaseSplit {
+	g.splitMtx.Lock()
+	split := g.split
+	g.splitMtx.Unlock()
+	return split
+}
+
+// UpdateCoinbasePayout updates the coinbase of the passed block template, which
+// must have been created without a payment address, to pay its value to the
+// passed address according to the coinbase split.  Any witness commitment
+// output is kept.  It also recalculates and updates the signature operation
+// cost of the coinbase and the new merkle root that result from changing the
+// coinbase outputs.  The template is left unchanged and an error is returned
+// when the updated coinbase causes the block to exceed the maximum weight or
+// signature operation cost allowed by the consensus rules.
+func (g *BlkTmplGenerator) UpdateCoinbasePayout(template *BlockTemplate, payToAddress navutil.Address) error {
+	msgBlock := template.Block
+	coinbaseTx := msgBlock.Transactions[0]
+	outputs, err := coinbaseOutputs(coinbaseTx.TxOut[0].Value,
+		payToAddress, g.CoinbaseSplit())
+	if err != nil {
+		return err
+	}
+	newCoinbaseTx := coinbaseTx.Copy()
+	newCoinbaseTx.TxOut = append(outputs, newCoinbaseTx.TxOut[1:]...)
+
+	// Ensure the block is still valid with the updated coinbase.  The
+	// space for the coinbase paying the split is reserved when the
+	// template is built, but the split might have changed since.
+	oldWeight := blockchain.GetTransactionWeight(navutil.NewTx(coinbaseTx))
+	newCoinbase := navutil.NewTx(newCoinbaseTx)
+	newWeight := blockchain.GetTransactionWeight(newCoinbase)
+	blockWeight := blockchain.GetBlockWeight(navutil.NewBlock(msgBlock)) -
+		oldWeight + newWeight
+	if blockWeight > blockchain.MaxBlockWeight {
+		return fmt.Errorf("block with the updated coinbase has a weight "+
+			"of %d which is more than the max of %d", blockWeight,
+			blockchain.MaxBlockWeight)
+	}
+	sigOpCost := int64(blockchain.CountSigOps(newCoinbase)) *
+		blockchain.WitnessScaleFactor
+	blockSigOpCost := sigOpCost
+	for _, txSigOpCost := range template.SigOpCosts[1:] {
+		blockSigOpCost += txSigOpCost
+	}
+	if blockSigOpCost > blockchain.MaxBlockSigOpsCost {
+		return fmt.Errorf("block with the updated coinbase has a "+
+			"signature operation cost of %d which is more than the "+
+			"max of %d", blockSigOpCost,
+			blockchain.MaxBlockSigOpsCost)
+	}
+	msgBlock.Transactions[0] = newCoinbaseTx
+	template.SigOpCosts[0] = sigOpCost
+
+	// Recalculate the merkle root with the updated outputs.
+	block := navutil.NewBlock(msgBlock)
+	merkles := blockchain.BuildMerkleTreeStore(block.Transactions(), false)
+	msgBlock.Header.MerkleRoot = *merkles[len(merkles)-1]
+	return nil
+}
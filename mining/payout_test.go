@@ -0,0 +1,275 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/navcoin/navd/blockchain"
+	"github.com/navcoin/navd/btcec"
+	"github.com/navcoin/navd/chaincfg"
+	"github.com/navcoin/navd/txscript"
+	"github.com/navcoin/navutil"
+)
+
+// TestCoinbaseOutputs ensures the value of the coinbase is split across the
+// payouts in proportion to their weights without losing any of it and that the
+// tag is added in an OP_RETURN output.
+func TestCoinbaseOutputs(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	var addrs []navutil.Address
+	var pkScripts [][]byte
+	for i := byte(1); i <= 3; i++ {
+		var hash [20]byte
+		hash[0] = i
+		addr, err := navutil.NewAddressPubKeyHash(hash[:], params)
+		if err != nil {
+			t.Fatalf("NewAddressPubKeyHash: %v", err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Fatalf("PayToAddrScript: %v", err)
+		}
+		addrs = append(addrs, addr)
+		pkScripts = append(pkScripts, pkScript)
+	}
+	tagScript, err := txscript.NullDataScript([]byte("pool"))
+	if err != nil {
+		t.Fatalf("NullDataScript: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		value      int64
+		split      *CoinbaseSplit
+		wantValues []int64
+		wantScript [][]byte
+	}{
+		{
+			name:       "no split",
+			value:      1000,
+			split:      nil,
+			wantValues: []int64{1000},
+			wantScript: [][]byte{pkScripts[0]},
+		},
+		{
+			name:       "tag only",
+			value:      1000,
+			split:      &CoinbaseSplit{Tag: []byte("pool")},
+			wantValues: []int64{1000, 0},
+			wantScript: [][]byte{pkScripts[0], tagScript},
+		},
+		{
+			name:  "weighted split with remainder",
+			value: 1001,
+			split: &CoinbaseSplit{
+				Payouts: []CoinbasePayout{
+					{Addr: addrs[1], Weight: 2},
+					{Addr: addrs[2], Weight: 1},
+				},
+			},
+			wantValues: []int64{668, 333},
+			wantScript: [][]byte{pkScripts[1], pkScripts[2]},
+		},
+		{
+			name:  "split with tag",
+			value: 5000000000,
+			split: &CoinbaseSplit{
+				Payouts: []CoinbasePayout{
+					{Addr: addrs[1], Weight: 3},
+					{Addr: addrs[2], Weight: 1},
+				},
+				Tag: []byte("pool"),
+			},
+			wantValues: []int64{3750000000, 1250000000, 0},
+			wantScript: [][]byte{pkScripts[1], pkScripts[2], tagScript},
+		},
+	}
+
+	for _, test := range tests {
+		outputs, err := coinbaseOutputs(test.value, addrs[0], test.split)
+		if err != nil {
+			t.Errorf("%s: coinbaseOutputs: %v", test.name, err)
+			continue
+		}
+		if len(outputs) != len(test.wantValues) {
+			t.Errorf("%s: got %d outputs, want %d", test.name,
+				len(outputs), len(test.wantValues))
+			continue
+		}
+		for i, output := range outputs {
+			if output.Value != test.wantValues[i] {
+				t.Errorf("%s: output %d value got %d, want %d",
+					test.name, i, output.Value,
+					test.wantValues[i])
+			}
+			if !bytes.Equal(output.PkScript, test.wantScript[i]) {
+				t.Errorf("%s: output %d script got %x, want %x",
+					test.name, i, output.PkScript,
+					test.wantScript[i])
+			}
+		}
+	}
+}
+
+// TestCoinbaseSplitValidate ensures invalid coinbase splits are rejected.
+func TestCoinbaseSplitValidate(t *testing.T) {
+	var hash [20]byte
+	addr, err := navutil.NewAddressPubKeyHash(hash[:],
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash: %v", err)
+	}
+
+	// Payouts to uncompressed public keys have large outputs, so a coinbase
+	// paying many of them doesn't fit the weight reserved for it.
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	pubKeyAddr, err := navutil.NewAddressPubKey(
+		privKey.PubKey().SerializeUncompressed(), &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressPubKey: %v", err)
+	}
+
+	maxPayouts := make([]CoinbasePayout, MaxCoinbasePayouts)
+	largePayouts := make([]CoinbasePayout, MaxCoinbasePayouts)
+	for i := range maxPayouts {
+		maxPayouts[i] = CoinbasePayout{addr, 1}
+		largePayouts[i] = CoinbasePayout{pubKeyAddr, 1}
+	}
+
+	tests := []struct {
+		name  string
+		split CoinbaseSplit
+		valid bool
+	}{
+		{
+			name:  "valid",
+			split: CoinbaseSplit{Payouts: []CoinbasePayout{{addr, 1}}},
+			valid: true,
+		},
+		{
+			name:  "zero weight",
+			split: CoinbaseSplit{Payouts: []CoinbasePayout{{addr, 0}}},
+			valid: false,
+		},
+		{
+			name:  "no address",
+			split: CoinbaseSplit{Payouts: []CoinbasePayout{{nil, 1}}},
+			valid: false,
+		},
+		{
+			name: "total weight overflow",
+			split: CoinbaseSplit{Payouts: []CoinbasePayout{
+				{addr, 1 << 31}, {addr, 1 << 31},
+			}},
+			valid: false,
+		},
+		{
+			name: "tag too large",
+			split: CoinbaseSplit{
+				Tag: make([]byte, txscript.MaxDataCarrierSize+1),
+			},
+			valid: false,
+		},
+		{
+			name: "max payouts with max tag",
+			split: CoinbaseSplit{
+				Payouts: maxPayouts,
+				Tag:     make([]byte, txscript.MaxDataCarrierSize),
+			},
+			valid: true,
+		},
+		{
+			name: "too many payouts",
+			split: CoinbaseSplit{Payouts: append(maxPayouts,
+				CoinbasePayout{addr, 1})},
+			valid: false,
+		},
+		{
+			name:  "coinbase too large",
+			split: CoinbaseSplit{Payouts: largePayouts},
+			valid: false,
+		},
+	}
+
+	for _, test := range tests {
+		err := test.split.Validate()
+		if (err == nil) != test.valid {
+			t.Errorf("%s: unexpected result %v", test.name, err)
+		}
+	}
+}
+
+// TestUpdateCoinbasePayout ensures the coinbase of a block template created
+// without a payment address is rewritten to pay the coinbase split along with
+// its signature operation cost, and that the template is left unchanged when
+// the rewritten coinbase makes the block invalid.
+func TestUpdateCoinbasePayout(t *testing.T) {
+	h, teardown := newGeneratorHarness(t, 1)
+	defer teardown()
+
+	var payouts []CoinbasePayout
+	for i := byte(1); i <= 3; i++ {
+		var hash [20]byte
+		hash[0] = i
+		addr, err := navutil.NewAddressPubKeyHash(hash[:], h.params)
+		if err != nil {
+			t.Fatalf("NewAddressPubKeyHash: %v", err)
+		}
+		payouts = append(payouts, CoinbasePayout{addr, uint32(i)})
+	}
+	h.g.SetCoinbaseSplit(&CoinbaseSplit{Payouts: payouts})
+
+	// The template is rejected without any change when the rewritten
+	// coinbase exceeds the maximum signature operation cost.
+	template, err := h.g.NewBlockTemplate(nil)
+	if err != nil {
+		t.Fatalf("NewBlockTemplate: unexpected error: %v", err)
+	}
+	coinbaseHash := template.Block.Transactions[0].TxHash()
+	merkleRoot := template.Block.Header.MerkleRoot
+	fullTemplate := *template
+	fullTemplate.SigOpCosts = append(template.SigOpCosts,
+		blockchain.MaxBlockSigOpsCost)
+	err = h.g.UpdateCoinbasePayout(&fullTemplate, payouts[0].Addr)
+	if err == nil {
+		t.Fatal("UpdateCoinbasePayout: did not reject a block " +
+			"exceeding the max signature operation cost")
+	}
+	if template.Block.Transactions[0].TxHash() != coinbaseHash ||
+		template.Block.Header.MerkleRoot != merkleRoot ||
+		template.SigOpCosts[0] != 0 {
+
+		t.Fatal("UpdateCoinbasePayout: modified the rejected template")
+	}
+
+	// Each pay-to-pubkey-hash output of the rewritten coinbase has a
+	// single signature operation and the block is accepted.
+	err = h.g.UpdateCoinbasePayout(template, payouts[0].Addr)
+	if err != nil {
+		t.Fatalf("UpdateCoinbasePayout: unexpected error: %v", err)
+	}
+	wantSigOpCost := int64(len(payouts)) * blockchain.WitnessScaleFactor
+	if template.SigOpCosts[0] != wantSigOpCost {
+		t.Fatalf("got coinbase sigop cost %d, want %d",
+			template.SigOpCosts[0], wantSigOpCost)
+	}
+	coinbaseOuts := template.Block.Transactions[0].TxOut
+	if len(coinbaseOuts) < len(payouts) {
+		t.Fatalf("got %d coinbase outputs, want at least %d",
+			len(coinbaseOuts), len(payouts))
+	}
+	h.solve(template.Block)
+	isMainChain, _, err := h.chain.ProcessBlock(
+		navutil.NewBlock(template.Block), blockchain.BFNone)
+	if err != nil || !isMainChain {
+		t.Fatalf("ProcessBlock: main chain %v, error %v", isMainChain,
+			err)
+	}
+}
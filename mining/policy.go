@@ -45,6 +45,11 @@ type Policy struct {
 	// required for a transaction to be treated as free for mining purposes
 	// (block template generation).
 	TxMinFreeFee navutil.Amount
+
+	// TxSelector chooses which transactions are included in generated
+	// block templates and in which order.  PriorityTxSelector is used when
+	// it is nil.
+	TxSelector TxSelector
 }
 
 // minInt is a helper function to return the minimum of two ints.  This avoids
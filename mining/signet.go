@@ -0,0 +1,75 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"errors"
+
+	"github.com/navcoin/navd/blockchain"
+	"github.com/navcoin/navd/btcec"
+	"github.com/navcoin/navd/chaincfg"
+	"github.com/navcoin/navd/txscript"
+	"github.com/navcoin/navd/wire"
+	"github.com/navcoin/navutil"
+)
+
+// SignBlock signs the passed block for the signet network defined by the
+// passed parameters with the passed private key.  Any previous signet solution
+// in the coinbase of the block is replaced and the merkle root is updated
+// accordingly.
+//
+// The solution commits to everything in the block except its nonce and bits,
+// so the block must be signed again whenever anything else changes, such as
+// its extra nonce or timestamp.  Pay-to-pubkey-hash challenges are expected to
+// commit to the compressed public key of the passed key.
+func SignBlock(msgBlock *wire.MsgBlock, params *chaincfg.Params,
+	key *btcec.PrivateKey) error {
+
+	if len(params.SignetChallenge) == 0 {
+		return errors.New("network does not require signed blocks")
+	}
+	if key == nil {
+		return errors.New("no signet signing key")
+	}
+
+	coinbaseTx := msgBlock.Transactions[0]
+	blockchain.RemoveSignetSolution(coinbaseTx)
+	_, toSign, err := blockchain.SignetTxns(msgBlock, params.SignetChallenge)
+	if err != nil {
+		return err
+	}
+
+	getKey := txscript.KeyClosure(func(navutil.Address) (*btcec.PrivateKey,
+		bool, error) {
+
+		return key, true, nil
+	})
+	getScript := txscript.ScriptClosure(func(navutil.Address) ([]byte, error) {
+		return nil, errors.New("pay-to-script-hash signet challenges " +
+			"are not supported")
+	})
+	solution, err := txscript.SignTxOutput(params, toSign, 0,
+		params.SignetChallenge, txscript.SigHashAll, getKey, getScript,
+		nil)
+	if err != nil {
+		return err
+	}
+
+	// Add the solution to the coinbase and recalculate the merkle root.
+	data := make([]byte, 0, len(blockchain.SignetHeader)+len(solution))
+	data = append(data, blockchain.SignetHeader[:]...)
+	data = append(data, solution...)
+	pkScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+		AddData(data).Script()
+	if err != nil {
+		return err
+	}
+	coinbaseTx.AddTxOut(wire.NewTxOut(0, pkScript))
+
+	block := navutil.NewBlock(msgBlock)
+	merkles := blockchain.BuildMerkleTreeStore(block.Transactions(), false)
+	msgBlock.Header.MerkleRoot = *merkles[len(merkles)-1]
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"testing"
+	"time"
+
+	"github.com/navcoin/navd/blockchain"
+	"github.com/navcoin/navd/btcec"
+	"github.com/navcoin/navd/chaincfg"
+	"github.com/navcoin/navd/chaincfg/chainhash"
+	"github.com/navcoin/navd/txscript"
+	"github.com/navcoin/navd/wire"
+	"github.com/navcoin/navutil"
+)
+
+// verifySignetBlock returns an error when the signet solution of the passed
+// block does not satisfy the passed challenge.
+func verifySignetBlock(block *wire.MsgBlock, challenge []byte) error {
+	_, toSign, err := blockchain.SignetTxns(block, challenge)
+	if err != nil {
+		return err
+	}
+	vm, err := txscript.NewEngine(challenge, toSign, 0,
+		txscript.StandardVerifyFlags, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	return vm.Execute()
+}
+
+// TestSignBlock ensures blocks signed for a signet network satisfy its
+// challenge and that signing a block again replaces its previous solution.
+func TestSignBlock(t *testing.T) {
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+	pubKeyHash := navutil.Hash160(key.PubKey().SerializeCompressed())
+	addr, err := navutil.NewAddressPubKeyHash(pubKeyHash,
+		&chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	challenge, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create challenge: %v", err)
+	}
+	params := chaincfg.CustomSignetParams(challenge, nil)
+
+	coinbaseTx := wire.NewMsgTx(wire.TxVersion)
+	coinbaseTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: []byte{0x51, 0x51},
+	})
+	coinbaseTx.AddTxOut(wire.NewTxOut(5000000000, challenge))
+	msgBlock := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   1,
+			PrevBlock: *params.GenesisHash,
+			Timestamp: time.Unix(1500000000, 0),
+		},
+		Transactions: []*wire.MsgTx{coinbaseTx},
+	}
+
+	// Blocks without a solution don't satisfy the challenge.
+	if err := verifySignetBlock(msgBlock, challenge); err == nil {
+		t.Fatal("unsigned block satisfies the challenge")
+	}
+
+	if err := SignBlock(msgBlock, &params, key); err != nil {
+		t.Fatalf("unable to sign block: %v", err)
+	}
+	if err := verifySignetBlock(msgBlock, challenge); err != nil {
+		t.Fatalf("signed block does not satisfy the challenge: %v", err)
+	}
+
+	// The solution no longer holds once the block changes, but the block
+	// can be signed again without adding another solution.
+	msgBlock.Header.Timestamp = msgBlock.Header.Timestamp.Add(time.Second)
+	if err := verifySignetBlock(msgBlock, challenge); err == nil {
+		t.Fatal("modified block satisfies the challenge")
+	}
+	if err := SignBlock(msgBlock, &params, key); err != nil {
+		t.Fatalf("unable to sign block again: %v", err)
+	}
+	if err := verifySignetBlock(msgBlock, challenge); err != nil {
+		t.Fatalf("signed block does not satisfy the challenge: %v", err)
+	}
+	if len(coinbaseTx.TxOut) != 2 {
+		t.Fatalf("coinbase has %d outputs, want 2", len(coinbaseTx.TxOut))
+	}
+
+	// Blocks can't be signed for networks without a challenge.
+	if err := SignBlock(msgBlock, &chaincfg.RegressionNetParams, key); err == nil {
+		t.Fatal("block signed for a network without a challenge")
+	}
+}
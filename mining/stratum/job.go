@@ -0,0 +1,292 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stratum
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/navcoin/navd/blockchain"
+	"github.com/navcoin/navd/chaincfg/chainhash"
+	"github.com/navcoin/navd/mining"
+	"github.com/navcoin/navd/txscript"
+	"github.com/navcoin/navd/wire"
+	"github.com/navcoin/navutil"
+)
+
+const (
+	// extraNonce1Size is the number of bytes of the coinbase extra nonce
+	// which are assigned to each client by the server.
+	extraNonce1Size = 4
+
+	// extraNonce2Size is the number of bytes of the coinbase extra nonce
+	// which are iterated by the miner.
+	extraNonce2Size = 4
+
+	// extraNonceSize is the total number of bytes reserved in the coinbase
+	// script for the extra nonces.
+	extraNonceSize = extraNonce1Size + extraNonce2Size
+
+	// minDifficulty and maxDifficulty are the bounds of the share
+	// difficulty which may be configured for the server.
+	minDifficulty = 1.0 / (1 << 32)
+	maxDifficulty = 1 << 40
+
+	// maxRetargetFactor is the maximum factor by which vardiff changes the
+	// share difficulty of a client in a single retarget.
+	maxRetargetFactor = 4
+)
+
+var (
+	// diff1Target is the target of a share with a difficulty of 1.
+	diff1Target = blockchain.CompactToBig(0x1d00ffff)
+
+	// maxTarget is the largest possible target, 2^256 - 1.
+	maxTarget = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256),
+		big.NewInt(1))
+)
+
+// job houses a block template along with the coinbase split around the extra
+// nonces and the merkle branch of the coinbase which are sent to miners.
+type job struct {
+	id           string
+	seq          uint64
+	template     *mining.BlockTemplate
+	coinbase1    []byte
+	coinbase2    []byte
+	merkleBranch []*chainhash.Hash
+	created      time.Time
+	lastTxUpdate time.Time
+
+	// shares houses the shares submitted for the job to detect duplicates.
+	// It is protected by the server mutex.
+	shares map[string]struct{}
+}
+
+// newJob returns a job for the passed block template.  lastTxUpdate is the
+// time the transaction source was last updated when the template was created.
+func newJob(seq uint64, template *mining.BlockTemplate, lastTxUpdate time.Time) (*job, error) {
+	coinbase1, coinbase2, err := splitCoinbase(template)
+	if err != nil {
+		return nil, err
+	}
+
+	return &job{
+		id:           fmt.Sprintf("%x", seq),
+		seq:          seq,
+		template:     template,
+		coinbase1:    coinbase1,
+		coinbase2:    coinbase2,
+		merkleBranch: merkleBranch(template.Block.Transactions),
+		created:      time.Now(),
+		lastTxUpdate: lastTxUpdate,
+		shares:       make(map[string]struct{}),
+	}, nil
+}
+
+// prevBlock returns the hash of the block the job builds on.
+func (j *job) prevBlock() *chainhash.Hash {
+	return &j.template.Block.Header.PrevBlock
+}
+
+// notifyParams returns the parameters of the mining.notify notification for
+// the job.
+func (j *job) notifyParams(cleanJobs bool) []interface{} {
+	header := &j.template.Block.Header
+	branch := make([]string, 0, len(j.merkleBranch))
+	for _, hash := range j.merkleBranch {
+		branch = append(branch, hex.EncodeToString(hash[:]))
+	}
+
+	// The previous block hash is sent in internal byte order with the
+	// bytes of each 32-bit word reversed.
+	var prevBlock [chainhash.HashSize]byte
+	for i := 0; i < chainhash.HashSize; i += 4 {
+		for k := 0; k < 4; k++ {
+			prevBlock[i+k] = header.PrevBlock[i+3-k]
+		}
+	}
+
+	return []interface{}{
+		j.id,
+		hex.EncodeToString(prevBlock[:]),
+		hex.EncodeToString(j.coinbase1),
+		hex.EncodeToString(j.coinbase2),
+		branch,
+		fmt.Sprintf("%08x", uint32(header.Version)),
+		fmt.Sprintf("%08x", header.Bits),
+		fmt.Sprintf("%08x", uint32(header.Timestamp.Unix())),
+		cleanJobs,
+	}
+}
+
+// block returns the block of the job solved with the passed extra nonces,
+// timestamp and nonce.  The template itself is not modified.
+func (j *job) block(extraNonce1, extraNonce2 []byte, nTime, nonce uint32) (*navutil.Block, error) {
+	extraNonce := make([]byte, 0, extraNonceSize)
+	extraNonce = append(extraNonce, extraNonce1...)
+	extraNonce = append(extraNonce, extraNonce2...)
+	script, err := coinbaseScript(j.template.Height, extraNonce)
+	if err != nil {
+		return nil, err
+	}
+
+	msgBlock := *j.template.Block
+	msgBlock.Transactions = make([]*wire.MsgTx, len(j.template.Block.Transactions))
+	copy(msgBlock.Transactions, j.template.Block.Transactions)
+	coinbaseTx := msgBlock.Transactions[0].Copy()
+	coinbaseTx.TxIn[0].SignatureScript = script
+	msgBlock.Transactions[0] = coinbaseTx
+
+	// Calculate the merkle root the same way as the miner, from the hash of
+	// the coinbase and its merkle branch.
+	coinbaseHash := coinbaseTx.TxHash()
+	merkleRoot := &coinbaseHash
+	for _, hash := range j.merkleBranch {
+		merkleRoot = blockchain.HashMerkleBranches(merkleRoot, hash)
+	}
+	msgBlock.Header.MerkleRoot = *merkleRoot
+	msgBlock.Header.Timestamp = time.Unix(int64(nTime), 0)
+	msgBlock.Header.Nonce = nonce
+
+	block := navutil.NewBlock(&msgBlock)
+	block.SetHeight(j.template.Height)
+	return block, nil
+}
+
+// coinbaseScript returns the coinbase signature script for a block at the
+// passed height with the passed extra nonce.
+func coinbaseScript(height int32, extraNonce []byte) ([]byte, error) {
+	script, err := txscript.NewScriptBuilder().AddInt64(int64(height)).
+		AddData(extraNonce).AddData([]byte(mining.CoinbaseFlags)).
+		Script()
+	if err != nil {
+		return nil, err
+	}
+	if len(script) > blockchain.MaxCoinbaseScriptLen {
+		return nil, fmt.Errorf("coinbase transaction script length "+
+			"of %d is out of range (min: %d, max: %d)",
+			len(script), blockchain.MinCoinbaseScriptLen,
+			blockchain.MaxCoinbaseScriptLen)
+	}
+	return script, nil
+}
+
+// splitCoinbase serializes the coinbase transaction of the passed template
+// with room for the extra nonces and returns the parts before and after them.
+func splitCoinbase(template *mining.BlockTemplate) ([]byte, []byte, error) {
+	coinbaseTx := template.Block.Transactions[0].Copy()
+	serialize := func(fill byte) ([]byte, error) {
+		extraNonce := bytes.Repeat([]byte{fill}, extraNonceSize)
+		script, err := coinbaseScript(template.Height, extraNonce)
+		if err != nil {
+			return nil, err
+		}
+		coinbaseTx.TxIn[0].SignatureScript = script
+
+		var buf bytes.Buffer
+		if err := coinbaseTx.SerializeNoWitness(&buf); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	// Serialize the coinbase with two different fills for the extra nonce
+	// so its offset is the first byte which differs.
+	low, err := serialize(0x00)
+	if err != nil {
+		return nil, nil, err
+	}
+	high, err := serialize(0xff)
+	if err != nil {
+		return nil, nil, err
+	}
+	offset := 0
+	for offset < len(low) && low[offset] == high[offset] {
+		offset++
+	}
+	if offset+extraNonceSize > len(low) {
+		return nil, nil, errors.New("unable to locate extra nonce in " +
+			"coinbase transaction")
+	}
+
+	return low[:offset], low[offset+extraNonceSize:], nil
+}
+
+// merkleBranch returns the hashes needed to calculate the merkle root of the
+// passed transactions from the hash of the coinbase transaction.
+func merkleBranch(transactions []*wire.MsgTx) []*chainhash.Hash {
+	// The coinbase is left out of every level since the branch only needs
+	// the hashes which are combined with it.
+	level := make([]*chainhash.Hash, len(transactions))
+	for i := 1; i < len(transactions); i++ {
+		hash := transactions[i].TxHash()
+		level[i] = &hash
+	}
+
+	var branch []*chainhash.Hash
+	for len(level) > 1 {
+		// Duplicate the last hash of levels with an odd number of
+		// hashes as the merkle tree does.
+		if len(level)%2 != 0 {
+			level = append(level, level[len(level)-1])
+		}
+		branch = append(branch, level[1])
+
+		next := make([]*chainhash.Hash, len(level)/2)
+		for i := 1; i < len(next); i++ {
+			next[i] = blockchain.HashMerkleBranches(level[i*2],
+				level[i*2+1])
+		}
+		level = next
+	}
+	return branch
+}
+
+// difficultyToTarget returns the target a share hash must not exceed to meet
+// the passed share difficulty.
+func difficultyToTarget(difficulty float64) *big.Int {
+	target, _ := new(big.Float).Quo(new(big.Float).SetInt(diff1Target),
+		big.NewFloat(difficulty)).Int(nil)
+	if target.Cmp(maxTarget) > 0 {
+		return new(big.Int).Set(maxTarget)
+	}
+	return target
+}
+
+// clampDifficulty returns the passed share difficulty limited to the passed
+// lower bound and maxDifficulty.
+func clampDifficulty(difficulty, min float64) float64 {
+	if difficulty < min {
+		return min
+	}
+	if difficulty > maxDifficulty {
+		return maxDifficulty
+	}
+	return difficulty
+}
+
+// retargetDifficulty returns the share difficulty which brings a client that
+// submitted the passed number of shares over the elapsed time closer to one
+// share per share interval.  The difficulty changes by at most
+// maxRetargetFactor in either direction and is never below the passed minimum.
+func retargetDifficulty(difficulty, min float64, shares int, elapsed, shareInterval time.Duration) float64 {
+	factor := 1.0 / maxRetargetFactor
+	if shares > 0 && elapsed > 0 {
+		factor = shareInterval.Seconds() * float64(shares) /
+			elapsed.Seconds()
+	}
+	if factor < 1.0/maxRetargetFactor {
+		factor = 1.0 / maxRetargetFactor
+	}
+	if factor > maxRetargetFactor {
+		factor = maxRetargetFactor
+	}
+	return clampDifficulty(difficulty*factor, min)
+}
@@ -0,0 +1,885 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stratum
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/navcoin/navd/blockchain"
+	"github.com/navcoin/navd/chaincfg"
+	"github.com/navcoin/navd/mining"
+	"github.com/navcoin/navutil"
+)
+
+const (
+	// DefaultDifficulty is the default initial share difficulty assigned to
+	// new clients.
+	DefaultDifficulty = 1.0
+
+	// DefaultMinDifficulty is the default lowest share difficulty assigned
+	// to clients.
+	DefaultMinDifficulty = 1.0
+
+	// DefaultShareInterval is the default number of seconds between shares
+	// vardiff aims for.
+	DefaultShareInterval = time.Second * 15
+
+	// retargetIntervals is the number of share intervals between vardiff
+	// retargets of the share difficulty of a client.
+	retargetIntervals = 6
+
+	// jobCheckInterval is the interval at which the server checks whether
+	// a new job must be generated.
+	jobCheckInterval = time.Second
+
+	// jobRefreshInterval is the minimum amount of time between jobs for
+	// the same previous block which are generated to include new
+	// transactions.
+	jobRefreshInterval = time.Minute
+
+	// maxJobs is the maximum number of jobs for the same previous block
+	// that shares are accepted for.
+	maxJobs = 16
+
+	// maxTimeOffset is the maximum amount of time the timestamp of a share
+	// may be ahead of the current time.
+	maxTimeOffset = time.Hour * 2
+
+	// clientReadTimeout is the amount of time a client may stay silent
+	// before it is disconnected.
+	clientReadTimeout = time.Minute * 10
+
+	// clientWriteTimeout is the amount of time a client is given to
+	// receive a message before it is disconnected.
+	clientWriteTimeout = time.Second * 10
+)
+
+// These constants define the error codes returned to stratum clients.
+const (
+	errCodeOther              = 20
+	errCodeJobNotFound        = 21
+	errCodeDuplicateShare     = 22
+	errCodeLowDifficultyShare = 23
+	errCodeUnauthorizedWorker = 24
+	errCodeNotSubscribed      = 25
+)
+
+// stratumError is an error returned to a stratum client.  It is encoded as an
+// array holding the error code, the message and an empty traceback.
+type stratumError struct {
+	code    int
+	message string
+}
+
+// MarshalJSON encodes the error in the format expected by stratum clients.
+func (e *stratumError) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.code, e.message, nil})
+}
+
+// newError returns a stratum error with the passed code and message.
+func newError(code int, message string) *stratumError {
+	return &stratumError{code: code, message: message}
+}
+
+// request is a JSON-RPC request received from a client.
+type request struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// response is a JSON-RPC response sent to a client.
+type response struct {
+	ID     json.RawMessage `json:"id"`
+	Result interface{}     `json:"result"`
+	Error  *stratumError   `json:"error"`
+}
+
+// notification is a JSON-RPC notification sent to a client.
+type notification struct {
+	ID     interface{}   `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+// templateGenerator describes the block template generation capabilities the
+// server relies on.  It is implemented by mining.BlkTmplGenerator.
+type templateGenerator interface {
+	NewBlockTemplate(payToAddress navutil.Address) (*mining.BlockTemplate, error)
+	BestSnapshot() *blockchain.BestState
+	TxSource() mining.TxSource
+	RecordBlock(template *mining.BlockTemplate, block *navutil.Block,
+		status mining.BlockStatus)
+}
+
+// Config is a descriptor containing the stratum server configuration.
+type Config struct {
+	// ChainParams identifies which chain parameters the server is
+	// associated with.
+	ChainParams *chaincfg.Params
+
+	// BlockTemplateGenerator identifies the instance to use in order to
+	// generate the block templates that jobs are created from.
+	BlockTemplateGenerator *mining.BlkTmplGenerator
+
+	// MiningAddrs is a list of payment addresses to use for the generated
+	// blocks.  Each block template will randomly choose one of them.
+	MiningAddrs []navutil.Address
+
+	// ProcessBlock defines the function to call with any solved blocks.
+	// It typically must run the provided block through the same set of
+	// rules and handling as any other block coming from the network.
+	ProcessBlock func(*navutil.Block, blockchain.BehaviorFlags) (bool, error)
+
+	// IsCurrent defines the function to use to obtain whether or not the
+	// block chain is current.  No jobs are generated while the chain is
+	// not current since any solved blocks would end up orphaned.
+	IsCurrent func() bool
+
+	// Listeners defines a slice of listeners on which the server will
+	// accept miners.
+	Listeners []net.Listener
+
+	// Difficulty is the initial share difficulty assigned to new clients.
+	// DefaultDifficulty is used when it is zero.
+	Difficulty float64
+
+	// MinDifficulty is the lowest share difficulty assigned to clients,
+	// including through mining.suggest_difficulty and vardiff.  Since the
+	// server hashes every share it is sent, this limits the work a client
+	// can make it do.  DefaultMinDifficulty is used when it is zero.
+	MinDifficulty float64
+
+	// ShareInterval is the amount of time between shares vardiff adjusts
+	// the share difficulty of each client towards.  Vardiff is disabled
+	// when it is zero.
+	ShareInterval time.Duration
+}
+
+// Server provides a stratum mining server which hands out work built from the
+// block templates of a mining.BlkTmplGenerator to external miners, validates
+// the shares they submit and submits any solved blocks.
+type Server struct {
+	started  int32
+	shutdown int32
+
+	cfg         Config
+	g           templateGenerator
+	extraNonce1 uint32
+
+	mtx     sync.Mutex
+	clients map[*client]struct{}
+	jobs    map[string]*job
+	curJob  *job
+	nextJob uint64
+
+	submitBlockLock sync.Mutex
+	quit            chan struct{}
+	wg              sync.WaitGroup
+}
+
+// New returns a new stratum server which accepts miners on the listeners in
+// the passed config once started.
+func New(cfg *Config) *Server {
+	s := &Server{
+		cfg:         *cfg,
+		g:           cfg.BlockTemplateGenerator,
+		extraNonce1: rand.Uint32(),
+		clients:     make(map[*client]struct{}),
+		jobs:        make(map[string]*job),
+		quit:        make(chan struct{}),
+	}
+	if s.cfg.MinDifficulty <= 0 {
+		s.cfg.MinDifficulty = DefaultMinDifficulty
+	}
+	s.cfg.MinDifficulty = clampDifficulty(s.cfg.MinDifficulty,
+		minDifficulty)
+	if s.cfg.Difficulty <= 0 {
+		s.cfg.Difficulty = DefaultDifficulty
+	}
+	s.cfg.Difficulty = s.clampDifficulty(s.cfg.Difficulty)
+	return s
+}
+
+// clampDifficulty returns the passed share difficulty limited to the bounds
+// allowed by the server.
+func (s *Server) clampDifficulty(difficulty float64) float64 {
+	return clampDifficulty(difficulty, s.cfg.MinDifficulty)
+}
+
+// Start begins generating jobs and accepting miners on all listeners.
+func (s *Server) Start() {
+	// Already started?
+	if atomic.AddInt32(&s.started, 1) != 1 {
+		return
+	}
+
+	log.Trace("Starting stratum server")
+	for _, listener := range s.cfg.Listeners {
+		s.wg.Add(1)
+		go s.listenHandler(listener)
+	}
+	s.wg.Add(1)
+	go s.jobHandler()
+}
+
+// Stop closes all listeners and disconnects all miners.
+func (s *Server) Stop() error {
+	// Make sure this only happens once.
+	if atomic.AddInt32(&s.shutdown, 1) != 1 {
+		log.Infof("Stratum server is already in the process of " +
+			"shutting down")
+		return nil
+	}
+
+	log.Infof("Stratum server shutting down")
+	close(s.quit)
+	for _, listener := range s.cfg.Listeners {
+		listener.Close()
+	}
+
+	s.mtx.Lock()
+	for c := range s.clients {
+		c.conn.Close()
+	}
+	s.mtx.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+// NumClients returns the number of connected miners.
+func (s *Server) NumClients() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return len(s.clients)
+}
+
+// listenHandler accepts miners on the passed listener until the server is
+// stopped.  It must be run as a goroutine.
+func (s *Server) listenHandler(listener net.Listener) {
+	log.Infof("Stratum server listening on %s", listener.Addr())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			// Only log the error if not forcibly shutting down.
+			if atomic.LoadInt32(&s.shutdown) == 0 {
+				log.Errorf("Can't accept miner: %v", err)
+			}
+			break
+		}
+
+		c := newClient(s, conn)
+		s.mtx.Lock()
+		if atomic.LoadInt32(&s.shutdown) != 0 {
+			s.mtx.Unlock()
+			conn.Close()
+			break
+		}
+		s.clients[c] = struct{}{}
+		s.mtx.Unlock()
+
+		log.Debugf("New stratum client %s", conn.RemoteAddr())
+		s.wg.Add(1)
+		go s.clientHandler(c)
+	}
+	s.wg.Done()
+}
+
+// clientHandler reads and handles requests from a miner until it disconnects
+// or the server is stopped.  It must be run as a goroutine.
+func (s *Server) clientHandler(c *client) {
+	scanner := bufio.NewScanner(c.conn)
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(clientReadTimeout))
+		if !scanner.Scan() {
+			break
+		}
+
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			log.Debugf("Malformed request from stratum client %s: %v",
+				c.conn.RemoteAddr(), err)
+			break
+		}
+		if err := c.handleRequest(&req); err != nil {
+			log.Debugf("Unable to write to stratum client %s: %v",
+				c.conn.RemoteAddr(), err)
+			break
+		}
+	}
+
+	c.conn.Close()
+	s.mtx.Lock()
+	delete(s.clients, c)
+	s.mtx.Unlock()
+	log.Debugf("Stratum client %s disconnected", c.conn.RemoteAddr())
+	s.wg.Done()
+}
+
+// jobHandler periodically generates new jobs when the best chain changes or
+// new transactions are available and retargets the share difficulty of
+// clients.  It must be run as a goroutine.
+func (s *Server) jobHandler() {
+	ticker := time.NewTicker(jobCheckInterval)
+	defer ticker.Stop()
+
+	s.updateJob()
+out:
+	for {
+		select {
+		case <-ticker.C:
+			s.updateJob()
+			s.retargetClients()
+
+		case <-s.quit:
+			break out
+		}
+	}
+	s.wg.Done()
+}
+
+// currentJob returns the most recent job or nil when there is none.
+func (s *Server) currentJob() *job {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.curJob
+}
+
+// clientList returns a snapshot of the connected clients.
+func (s *Server) clientList() []*client {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+// updateJob generates a new job and notifies all clients of it when the best
+// chain has changed or the transaction source has been updated since the
+// current job was generated and the current job is old enough to be
+// refreshed.
+func (s *Server) updateJob() {
+	// Grab the same lock as used for block submission, since the current
+	// block will be changing and this would otherwise end up building a
+	// new block template on a block that is in the process of becoming
+	// stale.
+	s.submitBlockLock.Lock()
+	best := s.g.BestSnapshot()
+	lastTxUpdate := s.g.TxSource().LastUpdated()
+	cur := s.currentJob()
+	cleanJobs := cur == nil || !cur.prevBlock().IsEqual(&best.Hash)
+	if !cleanJobs && (lastTxUpdate == cur.lastTxUpdate ||
+		time.Since(cur.created) < jobRefreshInterval) {
+
+		s.submitBlockLock.Unlock()
+		return
+	}
+
+	// No point in handing out work before the chain is synced.
+	if best.Height != 0 && !s.cfg.IsCurrent() {
+		s.submitBlockLock.Unlock()
+		return
+	}
+
+	// Choose a payment address at random.
+	var payToAddr navutil.Address
+	if len(s.cfg.MiningAddrs) > 0 {
+		payToAddr = s.cfg.MiningAddrs[rand.Intn(len(s.cfg.MiningAddrs))]
+	}
+	template, err := s.g.NewBlockTemplate(payToAddr)
+	s.submitBlockLock.Unlock()
+	if err != nil {
+		log.Errorf("Failed to create new block template: %v", err)
+		return
+	}
+
+	s.mtx.Lock()
+	j, err := newJob(s.nextJob, template, lastTxUpdate)
+	if err != nil {
+		s.mtx.Unlock()
+		log.Errorf("Failed to create new stratum job: %v", err)
+		return
+	}
+	s.nextJob++
+
+	// Shares for jobs which build on an older block are stale, so forget
+	// them along with any jobs too old to keep.
+	for id, oldJob := range s.jobs {
+		if cleanJobs || oldJob.seq+maxJobs <= j.seq {
+			delete(s.jobs, id)
+		}
+	}
+	s.jobs[j.id] = j
+	s.curJob = j
+	s.mtx.Unlock()
+
+	log.Debugf("New stratum job %s at height %d (clean %v)", j.id,
+		template.Height, cleanJobs)
+	for _, c := range s.clientList() {
+		if err := c.notifyJob(j, cleanJobs); err != nil {
+			c.conn.Close()
+		}
+	}
+}
+
+// retargetClients adjusts the share difficulty of all clients which are due
+// for a vardiff retarget.
+func (s *Server) retargetClients() {
+	if s.cfg.ShareInterval <= 0 {
+		return
+	}
+
+	now := time.Now()
+	j := s.currentJob()
+	for _, c := range s.clientList() {
+		if !c.retarget(now) {
+			continue
+		}
+		if err := c.sendDifficulty(); err != nil {
+			c.conn.Close()
+			continue
+		}
+		if j == nil {
+			continue
+		}
+		if err := c.notifyJob(j, false); err != nil {
+			c.conn.Close()
+		}
+	}
+}
+
+// submitShare validates a share submitted by the passed client and submits
+// the block when the share also satisfies the target difficulty of the
+// block.
+func (s *Server) submitShare(c *client, jobID string, extraNonce2 []byte, nTime, nonce uint32) *stratumError {
+	s.mtx.Lock()
+	j, ok := s.jobs[jobID]
+	s.mtx.Unlock()
+	if !ok {
+		return newError(errCodeJobNotFound, "Job not found")
+	}
+	difficulty, ok := c.jobDifficulty(j)
+	if !ok {
+		return newError(errCodeJobNotFound, "Job not found")
+	}
+
+	// The timestamp may not be before the one of the template or too far
+	// in the future.
+	header := &j.template.Block.Header
+	maxTime := time.Now().Add(maxTimeOffset)
+	if int64(nTime) < header.Timestamp.Unix() ||
+		int64(nTime) > maxTime.Unix() {
+
+		return newError(errCodeOther, "Time out of range")
+	}
+
+	block, err := j.block(c.extraNonce1, extraNonce2, nTime, nonce)
+	if err != nil {
+		log.Errorf("Failed to build block for stratum job %s: %v",
+			jobID, err)
+		return newError(errCodeOther, "Unable to build block")
+	}
+	hash := block.MsgBlock().Header.BlockHash()
+	if blockchain.HashToBig(&hash).Cmp(difficultyToTarget(difficulty)) > 0 {
+		return newError(errCodeLowDifficultyShare,
+			"Low difficulty share")
+	}
+
+	// Reject shares which have already been submitted for the job.
+	var shareKey [extraNonceSize + 8]byte
+	copy(shareKey[:], c.extraNonce1)
+	copy(shareKey[extraNonce1Size:], extraNonce2)
+	binary.BigEndian.PutUint32(shareKey[extraNonceSize:], nTime)
+	binary.BigEndian.PutUint32(shareKey[extraNonceSize+4:], nonce)
+	s.mtx.Lock()
+	_, duplicate := j.shares[string(shareKey[:])]
+	j.shares[string(shareKey[:])] = struct{}{}
+	s.mtx.Unlock()
+	if duplicate {
+		return newError(errCodeDuplicateShare, "Duplicate share")
+	}
+	c.shareAccepted()
+
+	// Submit the block when the share also satisfies the target difficulty
+	// of the block.
+	err = blockchain.CheckProofOfWork(block, s.cfg.ChainParams.PowLimit)
+	if err == nil {
+		s.submitBlock(j.template, block)
+	}
+	return nil
+}
+
+// submitBlock submits the passed block to network after ensuring it passes all
+// of the consensus validation rules.  The outcome is recorded in the history of
+// blocks generated from the passed template.
+func (s *Server) submitBlock(template *mining.BlockTemplate, block *navutil.Block) bool {
+	s.submitBlockLock.Lock()
+	defer s.submitBlockLock.Unlock()
+
+	// Ensure the block is not stale since a new block could have shown up
+	// while the share was being submitted.
+	msgBlock := block.MsgBlock()
+	if !msgBlock.Header.PrevBlock.IsEqual(&s.g.BestSnapshot().Hash) {
+		log.Debugf("Block submitted via stratum with previous block "+
+			"%s is stale", msgBlock.Header.PrevBlock)
+		s.g.RecordBlock(template, block, mining.BlockStale)
+		return false
+	}
+
+	// Process this block using the same rules as blocks coming from other
+	// nodes.  This will in turn relay it to the network like normal.
+	isOrphan, err := s.cfg.ProcessBlock(block, blockchain.BFNone)
+	if err != nil {
+		// Anything other than a rule violation is an unexpected error,
+		// so log that error as an internal error.
+		if _, ok := err.(blockchain.RuleError); !ok {
+			log.Errorf("Unexpected error while processing "+
+				"block submitted via stratum: %v", err)
+			s.g.RecordBlock(template, block, mining.BlockRejected)
+			return false
+		}
+
+		log.Debugf("Block submitted via stratum rejected: %v", err)
+		s.g.RecordBlock(template, block, mining.BlockRejected)
+		return false
+	}
+	if isOrphan {
+		log.Debugf("Block submitted via stratum is an orphan")
+		s.g.RecordBlock(template, block, mining.BlockRejected)
+		return false
+	}
+
+	// The block was accepted.
+	s.g.RecordBlock(template, block, mining.BlockAccepted)
+	coinbaseTx := msgBlock.Transactions[0].TxOut[0]
+	log.Infof("Block submitted via stratum accepted (hash %s, "+
+		"amount %v)", block.Hash(), navutil.Amount(coinbaseTx.Value))
+	return true
+}
+
+// client houses the state of a single connected miner.
+type client struct {
+	server      *Server
+	conn        net.Conn
+	extraNonce1 []byte
+	writeMtx    sync.Mutex
+
+	// The following fields are protected by the mutex.
+	mtx          sync.Mutex
+	subscribed   bool
+	workers      map[string]struct{}
+	difficulty   float64
+	difficulties map[uint64]float64
+	shares       int
+	lastRetarget time.Time
+}
+
+// newClient returns a new client for the passed connection with the next
+// extra nonce assigned by the server.
+func newClient(s *Server, conn net.Conn) *client {
+	extraNonce1 := make([]byte, extraNonce1Size)
+	binary.BigEndian.PutUint32(extraNonce1,
+		atomic.AddUint32(&s.extraNonce1, 1))
+
+	return &client{
+		server:       s,
+		conn:         conn,
+		extraNonce1:  extraNonce1,
+		workers:      make(map[string]struct{}),
+		difficulty:   s.cfg.Difficulty,
+		difficulties: make(map[uint64]float64),
+		lastRetarget: time.Now(),
+	}
+}
+
+// send writes the passed message to the client.
+func (c *client) send(msg interface{}) error {
+	serialized, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.writeMtx.Lock()
+	defer c.writeMtx.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(clientWriteTimeout))
+	_, err = c.conn.Write(append(serialized, '\n'))
+	return err
+}
+
+// ready returns whether or not the client has subscribed and authorized at
+// least one worker, and is therefore ready to receive jobs.
+//
+// This function MUST be called with the client lock held.
+func (c *client) ready() bool {
+	return c.subscribed && len(c.workers) > 0
+}
+
+// sendDifficulty sends the current share difficulty to the client.
+func (c *client) sendDifficulty() error {
+	c.mtx.Lock()
+	difficulty := c.difficulty
+	c.mtx.Unlock()
+
+	return c.send(&notification{
+		Method: "mining.set_difficulty",
+		Params: []interface{}{difficulty},
+	})
+}
+
+// notifyJob sends the passed job to the client if it is ready to receive jobs
+// and records the share difficulty the job was sent with.
+func (c *client) notifyJob(j *job, cleanJobs bool) error {
+	c.mtx.Lock()
+	if !c.ready() {
+		c.mtx.Unlock()
+		return nil
+	}
+	for seq := range c.difficulties {
+		if cleanJobs || seq+maxJobs <= j.seq {
+			delete(c.difficulties, seq)
+		}
+	}
+
+	// Shares for a job which is sent again after a difficulty change are
+	// accepted at the lower of both difficulties, since the miner may still
+	// be working on it with the old one.
+	if old, ok := c.difficulties[j.seq]; !ok || c.difficulty < old {
+		c.difficulties[j.seq] = c.difficulty
+	}
+	c.mtx.Unlock()
+
+	return c.send(&notification{
+		Method: "mining.notify",
+		Params: j.notifyParams(cleanJobs),
+	})
+}
+
+// jobDifficulty returns the share difficulty the passed job was sent to the
+// client with and whether or not the job was sent to the client at all.
+func (c *client) jobDifficulty(j *job) (float64, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	difficulty, ok := c.difficulties[j.seq]
+	return difficulty, ok
+}
+
+// shareAccepted records an accepted share for vardiff.
+func (c *client) shareAccepted() {
+	c.mtx.Lock()
+	c.shares++
+	c.mtx.Unlock()
+}
+
+// retarget updates the share difficulty of the client when it is due for a
+// vardiff retarget and returns whether or not the difficulty changed.
+func (c *client) retarget(now time.Time) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	shareInterval := c.server.cfg.ShareInterval
+	elapsed := now.Sub(c.lastRetarget)
+	if !c.ready() || elapsed < shareInterval*retargetIntervals {
+		return false
+	}
+
+	difficulty := retargetDifficulty(c.difficulty,
+		c.server.cfg.MinDifficulty, c.shares, elapsed, shareInterval)
+	c.shares = 0
+	c.lastRetarget = now
+	if difficulty == c.difficulty {
+		return false
+	}
+
+	log.Debugf("Retargeting share difficulty of stratum client %s from "+
+		"%g to %g", c.conn.RemoteAddr(), c.difficulty, difficulty)
+	c.difficulty = difficulty
+	return true
+}
+
+// handleRequest handles a request from the client and sends the response.
+func (c *client) handleRequest(req *request) error {
+	var result interface{}
+	var rerr *stratumError
+	becameReady, difficultyChanged := false, false
+	switch req.Method {
+	case "mining.subscribe":
+		result, becameReady = c.handleSubscribe()
+
+	case "mining.authorize":
+		result, becameReady, rerr = c.handleAuthorize(req.Params)
+
+	case "mining.submit":
+		result, rerr = c.handleSubmit(req.Params)
+
+	case "mining.suggest_difficulty":
+		result, difficultyChanged, rerr = c.handleSuggestDifficulty(
+			req.Params)
+
+	default:
+		rerr = newError(errCodeOther, "Unknown method "+req.Method)
+	}
+
+	id := req.ID
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	err := c.send(&response{ID: id, Result: result, Error: rerr})
+	if err != nil || (!becameReady && !difficultyChanged) {
+		return err
+	}
+
+	// Hand out the initial difficulty and work once the client has both
+	// subscribed and authorized a worker.  Clients which changed their
+	// difficulty receive the current job again so the new difficulty
+	// applies right away.
+	if err := c.sendDifficulty(); err != nil {
+		return err
+	}
+	if j := c.server.currentJob(); j != nil {
+		return c.notifyJob(j, becameReady)
+	}
+	return nil
+}
+
+// handleSubscribe handles the mining.subscribe method.  It returns the result
+// and whether or not the client became ready to receive jobs.
+func (c *client) handleSubscribe() (interface{}, bool) {
+	c.mtx.Lock()
+	wasReady := c.ready()
+	c.subscribed = true
+	becameReady := !wasReady && c.ready()
+	c.mtx.Unlock()
+
+	subscriptionID := hex.EncodeToString(c.extraNonce1)
+	return []interface{}{
+		[]interface{}{
+			[]string{"mining.set_difficulty", subscriptionID},
+			[]string{"mining.notify", subscriptionID},
+		},
+		hex.EncodeToString(c.extraNonce1),
+		extraNonce2Size,
+	}, becameReady
+}
+
+// handleAuthorize handles the mining.authorize method.  Any worker name is
+// accepted since solved blocks always pay to the configured mining addresses.
+// It returns the result and whether or not the client became ready to receive
+// jobs.
+func (c *client) handleAuthorize(params []json.RawMessage) (interface{}, bool, *stratumError) {
+	var worker string
+	if len(params) < 1 || json.Unmarshal(params[0], &worker) != nil ||
+		worker == "" {
+
+		return false, false, newError(errCodeOther, "Invalid worker name")
+	}
+
+	c.mtx.Lock()
+	wasReady := c.ready()
+	c.workers[worker] = struct{}{}
+	becameReady := !wasReady && c.ready()
+	if becameReady {
+		c.lastRetarget = time.Now()
+	}
+	c.mtx.Unlock()
+
+	log.Debugf("Stratum client %s authorized worker %s",
+		c.conn.RemoteAddr(), worker)
+	return true, becameReady, nil
+}
+
+// handleSubmit handles the mining.submit method.
+func (c *client) handleSubmit(params []json.RawMessage) (interface{}, *stratumError) {
+	var strParams [5]string
+	if len(params) < len(strParams) {
+		return false, newError(errCodeOther, "Invalid parameters")
+	}
+	for i := range strParams {
+		if err := json.Unmarshal(params[i], &strParams[i]); err != nil {
+			return false, newError(errCodeOther, "Invalid parameters")
+		}
+	}
+	worker, jobID := strParams[0], strParams[1]
+
+	c.mtx.Lock()
+	subscribed := c.subscribed
+	_, authorized := c.workers[worker]
+	c.mtx.Unlock()
+	if !subscribed {
+		return false, newError(errCodeNotSubscribed, "Not subscribed")
+	}
+	if !authorized {
+		return false, newError(errCodeUnauthorizedWorker,
+			"Unauthorized worker")
+	}
+
+	extraNonce2, err := hex.DecodeString(strParams[2])
+	if err != nil || len(extraNonce2) != extraNonce2Size {
+		return false, newError(errCodeOther, "Invalid extranonce2")
+	}
+	nTime, err := parseHexUint32(strParams[3])
+	if err != nil {
+		return false, newError(errCodeOther, "Invalid ntime")
+	}
+	nonce, err := parseHexUint32(strParams[4])
+	if err != nil {
+		return false, newError(errCodeOther, "Invalid nonce")
+	}
+
+	if rerr := c.server.submitShare(c, jobID, extraNonce2, nTime, nonce); rerr != nil {
+		log.Debugf("Rejected share from stratum worker %s: %s", worker,
+			rerr.message)
+		return false, rerr
+	}
+	return true, nil
+}
+
+// handleSuggestDifficulty handles the mining.suggest_difficulty method.  It
+// returns the result and whether or not the new difficulty needs to be sent to
+// the client.  Clients which are not ready yet receive the difficulty along
+// with their first job instead.
+func (c *client) handleSuggestDifficulty(params []json.RawMessage) (interface{}, bool, *stratumError) {
+	var difficulty float64
+	if len(params) < 1 || json.Unmarshal(params[0], &difficulty) != nil ||
+		difficulty <= 0 {
+
+		return false, false, newError(errCodeOther, "Invalid difficulty")
+	}
+
+	c.mtx.Lock()
+	c.difficulty = c.server.clampDifficulty(difficulty)
+	c.shares = 0
+	c.lastRetarget = time.Now()
+	ready := c.ready()
+	c.mtx.Unlock()
+
+	return true, ready, nil
+}
+
+// parseHexUint32 parses a big-endian hex encoded 32-bit value as sent by
+// miners for the timestamp and nonce of a share.
+func parseHexUint32(s string) (uint32, error) {
+	if len(s) != 8 {
+		return 0, strconv.ErrSyntax
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	return uint32(v), err
+}
@@ -0,0 +1,544 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stratum
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/navcoin/navd/blockchain"
+	"github.com/navcoin/navd/chaincfg"
+	"github.com/navcoin/navd/chaincfg/chainhash"
+	"github.com/navcoin/navd/mining"
+	"github.com/navcoin/navd/txscript"
+	"github.com/navcoin/navd/wire"
+	"github.com/navcoin/navutil"
+)
+
+// fakeTxSource provides a mining.TxSource without any transactions.
+type fakeTxSource struct{}
+
+func (fakeTxSource) LastUpdated() time.Time                    { return time.Time{} }
+func (fakeTxSource) MiningDescs() []*mining.TxDesc             { return nil }
+func (fakeTxSource) HaveTransaction(hash *chainhash.Hash) bool { return false }
+
+// fakeGenerator provides block templates on top of a configurable best block
+// without a chain instance.
+type fakeGenerator struct {
+	mtx  sync.Mutex
+	best blockchain.BestState
+}
+
+// setBest changes the best block templates build on.
+func (g *fakeGenerator) setBest(hash chainhash.Hash, height int32) {
+	g.mtx.Lock()
+	g.best = blockchain.BestState{Hash: hash, Height: height}
+	g.mtx.Unlock()
+}
+
+func (g *fakeGenerator) BestSnapshot() *blockchain.BestState {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	best := g.best
+	return &best
+}
+
+func (g *fakeGenerator) TxSource() mining.TxSource {
+	return fakeTxSource{}
+}
+
+func (g *fakeGenerator) RecordBlock(template *mining.BlockTemplate,
+	block *navutil.Block, status mining.BlockStatus) {
+}
+
+func (g *fakeGenerator) NewBlockTemplate(payToAddress navutil.Address) (*mining.BlockTemplate, error) {
+	best := g.BestSnapshot()
+
+	coinbaseTx := wire.NewMsgTx(wire.TxVersion)
+	coinbaseTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: []byte{txscript.OP_0, txscript.OP_0},
+		Sequence:        wire.MaxTxInSequenceNum,
+	})
+	coinbaseTx.AddTxOut(wire.NewTxOut(5000000000, []byte{txscript.OP_TRUE}))
+
+	// Include a couple of transactions so the coinbase has a merkle
+	// branch.
+	msgBlock := wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   0x20000000,
+			PrevBlock: best.Hash,
+			Timestamp: time.Unix(time.Now().Unix(), 0),
+			Bits:      chaincfg.RegressionNetParams.PowLimitBits,
+		},
+		Transactions: []*wire.MsgTx{coinbaseTx},
+	}
+	for i := 0; i < 2; i++ {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&best.Hash, uint32(i)),
+			nil, nil))
+		tx.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}))
+		msgBlock.Transactions = append(msgBlock.Transactions, tx)
+	}
+
+	return &mining.BlockTemplate{
+		Block:  &msgBlock,
+		Height: best.Height + 1,
+	}, nil
+}
+
+// testMessage is any message sent by the server to a client.
+type testMessage struct {
+	ID     *int              `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	Result json.RawMessage   `json:"result"`
+	Error  []interface{}     `json:"error"`
+}
+
+// testClient is a minimal stratum client used to exercise the server.
+type testClient struct {
+	t             *testing.T
+	conn          net.Conn
+	scanner       *bufio.Scanner
+	nextID        int
+	notifications []*testMessage
+}
+
+// read reads the next message from the server.
+func (c *testClient) read() *testMessage {
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if !c.scanner.Scan() {
+		c.t.Fatalf("unable to read message: %v", c.scanner.Err())
+	}
+	var msg testMessage
+	if err := json.Unmarshal(c.scanner.Bytes(), &msg); err != nil {
+		c.t.Fatalf("unable to decode message %s: %v", c.scanner.Text(),
+			err)
+	}
+	return &msg
+}
+
+// call sends a request and returns the response while queueing any
+// notifications received in the mean time.
+func (c *testClient) call(method string, params ...interface{}) *testMessage {
+	c.nextID++
+	req, err := json.Marshal(map[string]interface{}{
+		"id":     c.nextID,
+		"method": method,
+		"params": params,
+	})
+	if err != nil {
+		c.t.Fatalf("unable to encode request: %v", err)
+	}
+	if _, err := c.conn.Write(append(req, '\n')); err != nil {
+		c.t.Fatalf("unable to send request: %v", err)
+	}
+
+	for {
+		msg := c.read()
+		if msg.ID != nil && *msg.ID == c.nextID {
+			return msg
+		}
+		c.notifications = append(c.notifications, msg)
+	}
+}
+
+// notification returns the next notification with the passed method.
+func (c *testClient) notification(method string) *testMessage {
+	for len(c.notifications) > 0 {
+		msg := c.notifications[0]
+		c.notifications = c.notifications[1:]
+		if msg.Method == method {
+			return msg
+		}
+	}
+	for {
+		msg := c.read()
+		if msg.Method == method {
+			return msg
+		}
+	}
+}
+
+// testJob houses the work from a mining.notify notification.
+type testJob struct {
+	id           string
+	prevBlock    []byte
+	coinbase1    []byte
+	coinbase2    []byte
+	merkleBranch [][]byte
+	version      uint32
+	bits         uint32
+	nTime        uint32
+	cleanJobs    bool
+}
+
+// parseJob parses the parameters of a mining.notify notification.
+func parseJob(t *testing.T, msg *testMessage) *testJob {
+	var (
+		strs   [8]string
+		branch []string
+		j      testJob
+	)
+	for i, idx := range []int{0, 1, 2, 3, 5, 6, 7} {
+		if err := json.Unmarshal(msg.Params[idx], &strs[i]); err != nil {
+			t.Fatalf("invalid notify param %d: %v", idx, err)
+		}
+	}
+	if err := json.Unmarshal(msg.Params[4], &branch); err != nil {
+		t.Fatalf("invalid merkle branch: %v", err)
+	}
+	if err := json.Unmarshal(msg.Params[8], &j.cleanJobs); err != nil {
+		t.Fatalf("invalid clean jobs flag: %v", err)
+	}
+
+	decodeHex := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatalf("invalid hex %q: %v", s, err)
+		}
+		return b
+	}
+	decodeUint32 := func(s string) uint32 {
+		v, err := strconv.ParseUint(s, 16, 32)
+		if err != nil {
+			t.Fatalf("invalid hex value %q: %v", s, err)
+		}
+		return uint32(v)
+	}
+	j.id = strs[0]
+	j.prevBlock = decodeHex(strs[1])
+	j.coinbase1 = decodeHex(strs[2])
+	j.coinbase2 = decodeHex(strs[3])
+	for _, hash := range branch {
+		j.merkleBranch = append(j.merkleBranch, decodeHex(hash))
+	}
+	j.version = decodeUint32(strs[4])
+	j.bits = decodeUint32(strs[5])
+	j.nTime = decodeUint32(strs[6])
+	return &j
+}
+
+// header returns the block header a miner builds from the job for the passed
+// extra nonces and nonce.
+func (j *testJob) header(extraNonce1, extraNonce2 []byte, nonce uint32) *wire.BlockHeader {
+	var coinbase []byte
+	coinbase = append(coinbase, j.coinbase1...)
+	coinbase = append(coinbase, extraNonce1...)
+	coinbase = append(coinbase, extraNonce2...)
+	coinbase = append(coinbase, j.coinbase2...)
+	merkleRoot := chainhash.DoubleHashB(coinbase)
+	for _, hash := range j.merkleBranch {
+		merkleRoot = chainhash.DoubleHashB(append(merkleRoot, hash...))
+	}
+
+	var prevBlock chainhash.Hash
+	for i := 0; i < chainhash.HashSize; i += 4 {
+		for k := 0; k < 4; k++ {
+			prevBlock[i+k] = j.prevBlock[i+3-k]
+		}
+	}
+	header := wire.BlockHeader{
+		Version:   int32(j.version),
+		PrevBlock: prevBlock,
+		Timestamp: time.Unix(int64(j.nTime), 0),
+		Bits:      j.bits,
+		Nonce:     nonce,
+	}
+	copy(header.MerkleRoot[:], merkleRoot)
+	return &header
+}
+
+// TestServer exercises the stratum server with an in-process client.
+func TestServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+
+	// Use a share difficulty which roughly half of all hashes meet.
+	const difficulty = minDifficulty * 2
+	blocks := make(chan *navutil.Block, 10)
+	generator := &fakeGenerator{}
+	generator.setBest(chainhash.Hash{0x01}, 100)
+	s := New(&Config{
+		ChainParams: &chaincfg.RegressionNetParams,
+		ProcessBlock: func(block *navutil.Block, flags blockchain.BehaviorFlags) (bool, error) {
+			blocks <- block
+			return false, nil
+		},
+		IsCurrent:     func() bool { return true },
+		Listeners:     []net.Listener{listener},
+		Difficulty:    difficulty,
+		MinDifficulty: minDifficulty,
+	})
+	s.g = generator
+	s.Start()
+	defer s.Stop()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unable to connect: %v", err)
+	}
+	defer conn.Close()
+	c := &testClient{t: t, conn: conn, scanner: bufio.NewScanner(conn)}
+
+	// Shares may not be submitted before subscribing.
+	resp := c.call("mining.submit", "worker", "0", "00000000",
+		"00000000", "00000000")
+	if len(resp.Error) == 0 || resp.Error[0] != float64(errCodeNotSubscribed) {
+		t.Fatalf("unexpected submit error before subscribing: %v",
+			resp.Error)
+	}
+
+	// Subscribe and authorize a worker.
+	resp = c.call("mining.subscribe", "test/1.0")
+	var subscribeResult []json.RawMessage
+	if err := json.Unmarshal(resp.Result, &subscribeResult); err != nil ||
+		len(subscribeResult) != 3 {
+
+		t.Fatalf("unexpected subscribe result: %s", resp.Result)
+	}
+	var extraNonce1Hex string
+	var en2Size int
+	json.Unmarshal(subscribeResult[1], &extraNonce1Hex)
+	json.Unmarshal(subscribeResult[2], &en2Size)
+	extraNonce1, err := hex.DecodeString(extraNonce1Hex)
+	if err != nil || len(extraNonce1) != extraNonce1Size ||
+		en2Size != extraNonce2Size {
+
+		t.Fatalf("unexpected extra nonces in subscribe result: %s",
+			resp.Result)
+	}
+	resp = c.call("mining.authorize", "worker", "x")
+	if string(resp.Result) != "true" {
+		t.Fatalf("unexpected authorize result: %s", resp.Result)
+	}
+
+	// The difficulty and the first job follow the authorization.
+	msg := c.notification("mining.set_difficulty")
+	var gotDifficulty float64
+	json.Unmarshal(msg.Params[0], &gotDifficulty)
+	if gotDifficulty != difficulty {
+		t.Fatalf("got difficulty %g, want %g", gotDifficulty, difficulty)
+	}
+	j := parseJob(t, c.notification("mining.notify"))
+	if !j.cleanJobs {
+		t.Fatal("first job does not clean previous jobs")
+	}
+	if len(j.merkleBranch) != 2 {
+		t.Fatalf("got merkle branch with %d hashes, want 2",
+			len(j.merkleBranch))
+	}
+
+	// Find a nonce which yields a valid share and one which does not.
+	extraNonce2 := []byte{0x00, 0x00, 0x00, 0x01}
+	shareTarget := difficultyToTarget(difficulty)
+	validNonce, invalidNonce := int64(-1), int64(-1)
+	var validHeader *wire.BlockHeader
+	for nonce := uint32(0); validNonce < 0 || invalidNonce < 0; nonce++ {
+		header := j.header(extraNonce1, extraNonce2, nonce)
+		hash := header.BlockHash()
+		if blockchain.HashToBig(&hash).Cmp(shareTarget) <= 0 {
+			if validNonce < 0 {
+				validNonce = int64(nonce)
+				validHeader = header
+			}
+		} else if invalidNonce < 0 {
+			invalidNonce = int64(nonce)
+		}
+	}
+	submit := func(worker, jobID string, nonce int64) *testMessage {
+		return c.call("mining.submit", worker, jobID,
+			hex.EncodeToString(extraNonce2),
+			fmt.Sprintf("%08x", j.nTime), fmt.Sprintf("%08x", nonce))
+	}
+
+	tests := []struct {
+		name    string
+		worker  string
+		jobID   string
+		nonce   int64
+		errCode int
+	}{
+		{"unauthorized worker", "other", j.id, validNonce,
+			errCodeUnauthorizedWorker},
+		{"unknown job", "worker", "ffff", validNonce,
+			errCodeJobNotFound},
+		{"low difficulty", "worker", j.id, invalidNonce,
+			errCodeLowDifficultyShare},
+		{"valid share", "worker", j.id, validNonce, 0},
+		{"duplicate share", "worker", j.id, validNonce,
+			errCodeDuplicateShare},
+	}
+	for _, test := range tests {
+		resp := submit(test.worker, test.jobID, test.nonce)
+		if test.errCode == 0 {
+			if string(resp.Result) != "true" || len(resp.Error) != 0 {
+				t.Fatalf("%s: share rejected: %v", test.name,
+					resp.Error)
+			}
+			continue
+		}
+		if len(resp.Error) == 0 || resp.Error[0] != float64(test.errCode) {
+			t.Fatalf("%s: got error %v, want code %d", test.name,
+				resp.Error, test.errCode)
+		}
+	}
+
+	// The valid share also meets the target of the block, so it must have
+	// been submitted with the merkle root the miner calculated.
+	var block *navutil.Block
+	select {
+	case block = <-blocks:
+	case <-time.After(5 * time.Second):
+		t.Fatal("solved block was not submitted")
+	}
+	if block.MsgBlock().Header.BlockHash() != validHeader.BlockHash() {
+		t.Fatalf("submitted block header does not match the share")
+	}
+	merkles := blockchain.BuildMerkleTreeStore(block.Transactions(), false)
+	if !merkles[len(merkles)-1].IsEqual(&validHeader.MerkleRoot) {
+		t.Fatalf("got merkle root %v, want %v", merkles[len(merkles)-1],
+			validHeader.MerkleRoot)
+	}
+
+	// A new best block results in a new job which cleans previous jobs.
+	generator.setBest(chainhash.Hash{0x02}, 101)
+	j2 := parseJob(t, c.notification("mining.notify"))
+	if !j2.cleanJobs || j2.id == j.id || j2.prevBlock[3] != 0x02 {
+		t.Fatalf("unexpected job after new block: %+v", j2)
+	}
+	resp = submit("worker", j.id, validNonce)
+	if len(resp.Error) == 0 || resp.Error[0] != float64(errCodeJobNotFound) {
+		t.Fatalf("stale share not rejected: %v", resp.Error)
+	}
+
+	// A suggested difficulty below the minimum is limited to it.  The
+	// response comes before the new difficulty and the resent job.
+	c.notifications = nil
+	resp = c.call("mining.suggest_difficulty", minDifficulty/4)
+	if string(resp.Result) != "true" {
+		t.Fatalf("unexpected suggest_difficulty result: %s", resp.Result)
+	}
+	if len(c.notifications) != 0 {
+		t.Fatalf("got %d notifications before the suggest_difficulty "+
+			"response", len(c.notifications))
+	}
+	msg = c.notification("mining.set_difficulty")
+	json.Unmarshal(msg.Params[0], &gotDifficulty)
+	if gotDifficulty != minDifficulty {
+		t.Fatalf("got difficulty %g, want %g", gotDifficulty,
+			minDifficulty)
+	}
+	j3 := parseJob(t, c.notification("mining.notify"))
+	if j3.cleanJobs || j3.id != j2.id {
+		t.Fatalf("unexpected job after difficulty change: %+v", j3)
+	}
+}
+
+// TestMinDifficulty ensures the share difficulty of clients defaults to being
+// no lower than DefaultMinDifficulty.
+func TestMinDifficulty(t *testing.T) {
+	s := New(&Config{Difficulty: DefaultMinDifficulty / 1024})
+	if s.cfg.MinDifficulty != DefaultMinDifficulty {
+		t.Fatalf("got min difficulty %g, want %g", s.cfg.MinDifficulty,
+			DefaultMinDifficulty)
+	}
+	if s.cfg.Difficulty != DefaultMinDifficulty {
+		t.Fatalf("got initial difficulty %g, want %g", s.cfg.Difficulty,
+			DefaultMinDifficulty)
+	}
+	if got := s.clampDifficulty(minDifficulty); got != DefaultMinDifficulty {
+		t.Fatalf("got suggested difficulty %g, want %g", got,
+			DefaultMinDifficulty)
+	}
+
+	// The configured minimum may not be below the lowest supported one.
+	s = New(&Config{MinDifficulty: minDifficulty / 2})
+	if s.cfg.MinDifficulty != minDifficulty {
+		t.Fatalf("got min difficulty %g, want %g", s.cfg.MinDifficulty,
+			minDifficulty)
+	}
+}
+
+// TestMerkleBranch ensures the merkle root calculated from the coinbase hash
+// and its merkle branch matches the merkle root of the block.
+func TestMerkleBranch(t *testing.T) {
+	for numTxns := 1; numTxns <= 7; numTxns++ {
+		var txns []*wire.MsgTx
+		for i := 0; i < numTxns; i++ {
+			tx := wire.NewMsgTx(wire.TxVersion)
+			tx.AddTxOut(wire.NewTxOut(int64(i), nil))
+			txns = append(txns, tx)
+		}
+		block := navutil.NewBlock(&wire.MsgBlock{Transactions: txns})
+		merkles := blockchain.BuildMerkleTreeStore(block.Transactions(),
+			false)
+
+		root := txns[0].TxHash()
+		for _, hash := range merkleBranch(txns) {
+			var buf bytes.Buffer
+			buf.Write(root[:])
+			buf.Write(hash[:])
+			root = chainhash.DoubleHashH(buf.Bytes())
+		}
+		if !merkles[len(merkles)-1].IsEqual(&root) {
+			t.Fatalf("%d transactions: got merkle root %v, want %v",
+				numTxns, root, merkles[len(merkles)-1])
+		}
+	}
+}
+
+// TestRetargetDifficulty ensures vardiff moves the share difficulty towards
+// the share interval within the allowed bounds.
+func TestRetargetDifficulty(t *testing.T) {
+	const interval = 10 * time.Second
+	tests := []struct {
+		name       string
+		difficulty float64
+		shares     int
+		elapsed    time.Duration
+		want       float64
+	}{
+		{"on target", 8, 6, 60 * time.Second, 8},
+		{"twice as fast", 8, 12, 60 * time.Second, 16},
+		{"twice as slow", 8, 3, 60 * time.Second, 4},
+		{"max increase", 8, 600, 60 * time.Second, 32},
+		{"no shares", 8, 0, 60 * time.Second, 2},
+		{"min difficulty", minDifficulty, 0, 60 * time.Second,
+			minDifficulty},
+		{"max difficulty", maxDifficulty, 600, 60 * time.Second,
+			maxDifficulty},
+	}
+	for _, test := range tests {
+		got := retargetDifficulty(test.difficulty, minDifficulty,
+			test.shares, test.elapsed, interval)
+		if got != test.want {
+			t.Errorf("%s: got difficulty %g, want %g", test.name, got,
+				test.want)
+		}
+	}
+
+	// Check the share target of the difficulty 1 and limits.
+	if difficultyToTarget(1).Cmp(diff1Target) != 0 {
+		t.Errorf("unexpected target for difficulty 1: %064x",
+			difficultyToTarget(1))
+	}
+	if difficultyToTarget(minDifficulty/2).Cmp(maxTarget) != 0 {
+		t.Errorf("target for tiny difficulty is not clamped")
+	}
+}
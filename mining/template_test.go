@@ -0,0 +1,453 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/navcoin/navd/blockchain"
+	"github.com/navcoin/navd/chaincfg"
+	"github.com/navcoin/navd/chaincfg/chainhash"
+	"github.com/navcoin/navd/database"
+	_ "github.com/navcoin/navd/database/ffldb"
+	"github.com/navcoin/navd/txscript"
+	"github.com/navcoin/navd/wire"
+	"github.com/navcoin/navutil"
+)
+
+// fakeTxSource is a TxSource which hands out the transactions added to it.
+// The time it reports it was last updated is moved forward by a second on every
+// change, so changes are noticed regardless of how quickly they are made.
+type fakeTxSource struct {
+	mtx         sync.Mutex
+	descs       []*TxDesc
+	lastUpdated time.Time
+}
+
+// Ensure fakeTxSource implements the TxSource interface.
+var _ TxSource = (*fakeTxSource)(nil)
+
+// add adds the passed transaction with the passed fee to the source pool.
+func (s *fakeTxSource) add(tx *navutil.Tx, fee int64) {
+	s.mtx.Lock()
+	s.descs = append(s.descs, &TxDesc{
+		Tx:       tx,
+		Added:    time.Now(),
+		Fee:      fee,
+		FeePerKB: fee * 1000 / int64(tx.MsgTx().SerializeSize()),
+	})
+	s.lastUpdated = s.lastUpdated.Add(time.Second)
+	s.mtx.Unlock()
+}
+
+// remove removes the passed transaction from the source pool.
+func (s *fakeTxSource) remove(tx *navutil.Tx) {
+	s.mtx.Lock()
+	for i, desc := range s.descs {
+		if desc.Tx.Hash().IsEqual(tx.Hash()) {
+			s.descs = append(s.descs[:i], s.descs[i+1:]...)
+			break
+		}
+	}
+	s.lastUpdated = s.lastUpdated.Add(time.Second)
+	s.mtx.Unlock()
+}
+
+// LastUpdated returns the last time a transaction was added to or removed from
+// the source pool.
+func (s *fakeTxSource) LastUpdated() time.Time {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.lastUpdated
+}
+
+// MiningDescs returns the descriptors of all transactions in the source pool.
+func (s *fakeTxSource) MiningDescs() []*TxDesc {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]*TxDesc(nil), s.descs...)
+}
+
+// HaveTransaction returns whether or not the passed transaction is in the
+// source pool.
+func (s *fakeTxSource) HaveTransaction(hash *chainhash.Hash) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, desc := range s.descs {
+		if desc.Tx.Hash().IsEqual(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// generatorHarness houses a block template generator building on top of a
+// regression test chain along with the source pool it selects from.
+type generatorHarness struct {
+	t          *testing.T
+	params     *chaincfg.Params
+	chain      *blockchain.BlockChain
+	timeSource blockchain.MedianTimeSource
+	source     *fakeTxSource
+	g          *BlkTmplGenerator
+
+	// spendable houses the mature coinbase outputs which are not spent by
+	// any transaction created by the harness yet.
+	spendable []*navutil.Tx
+}
+
+// newGeneratorHarness returns a generator harness with enough blocks mined for
+// the passed number of coinbase outputs to be spendable.  The returned function
+// must be called to remove the chain once the test is done.
+func newGeneratorHarness(t *testing.T, numSpendable int) (*generatorHarness, func()) {
+	dbPath, err := ioutil.TempDir("", "mininggenerator")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	// The regression test network retargets every block, so use a long
+	// retarget interval to keep the difficulty at its minimum while the
+	// harness mines blocks in quick succession.
+	params := chaincfg.RegressionNetParams
+	params.TargetTimespan = time.Hour * 24 * 14
+	params.TargetTimePerBlock = time.Minute * 10
+	db, err := database.Create("ffldb", dbPath, params.Net)
+	if err != nil {
+		os.RemoveAll(dbPath)
+		t.Fatalf("unable to create database: %v", err)
+	}
+	teardown := func() {
+		db.Close()
+		os.RemoveAll(dbPath)
+	}
+
+	timeSource := blockchain.NewMedianTime()
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: &params,
+		TimeSource:  timeSource,
+	})
+	if err != nil {
+		teardown()
+		t.Fatalf("unable to create chain: %v", err)
+	}
+
+	h := &generatorHarness{
+		t:          t,
+		params:     &params,
+		chain:      chain,
+		timeSource: timeSource,
+		source:     &fakeTxSource{},
+	}
+	h.g = h.newGenerator()
+
+	// Mine enough blocks for the coinbases of the first ones to mature.
+	numBlocks := int(params.CoinbaseMaturity) + numSpendable
+	var coinbases []*navutil.Tx
+	for i := 0; i < numBlocks; i++ {
+		block := h.mine(nil)
+		coinbases = append(coinbases, block.Transactions()[0])
+	}
+	h.spendable = coinbases[:numSpendable]
+	return h, teardown
+}
+
+// newGenerator returns a new block template generator which selects from the
+// source pool of the harness.
+func (h *generatorHarness) newGenerator() *BlkTmplGenerator {
+	policy := &Policy{BlockMaxWeight: blockchain.MaxBlockWeight - 4000}
+	return NewBlkTmplGenerator(policy, h.params, h.source, h.chain,
+		h.timeSource, txscript.NewSigCache(1000), nil)
+}
+
+// solve finds a nonce for the passed block which satisfies its target.
+func (h *generatorHarness) solve(msgBlock *wire.MsgBlock) {
+	target := blockchain.CompactToBig(msgBlock.Header.Bits)
+	for nonce := uint32(0); ; nonce++ {
+		msgBlock.Header.Nonce = nonce
+		hash := msgBlock.Header.BlockHash()
+		if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+			return
+		}
+	}
+}
+
+// mine builds a block template paying to the passed address, solves it and
+// adds it to the chain.
+func (h *generatorHarness) mine(payToAddress navutil.Address) *navutil.Block {
+	template, err := h.g.NewBlockTemplate(payToAddress)
+	if err != nil {
+		h.t.Fatalf("NewBlockTemplate: unexpected error: %v", err)
+	}
+	h.solve(template.Block)
+	block := navutil.NewBlock(template.Block)
+	isMainChain, isOrphan, err := h.chain.ProcessBlock(block,
+		blockchain.BFNone)
+	if err != nil || !isMainChain || isOrphan {
+		h.t.Fatalf("ProcessBlock: main chain %v, orphan %v, error %v",
+			isMainChain, isOrphan, err)
+	}
+	return block
+}
+
+// spend returns a transaction which spends the first output of the passed
+// transaction, which must pay to OP_TRUE, into the passed number of outputs
+// paying to OP_TRUE after deducting the passed fee.
+func (h *generatorHarness) spend(tx *navutil.Tx, numOutputs int, fee int64) *navutil.Tx {
+	opTrue := []byte{txscript.OP_TRUE}
+	msgTx := wire.NewMsgTx(1)
+	msgTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: *tx.Hash()},
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	value := (tx.MsgTx().TxOut[0].Value - fee) / int64(numOutputs)
+	for i := 0; i < numOutputs; i++ {
+		msgTx.AddTxOut(wire.NewTxOut(value, opTrue))
+	}
+	return navutil.NewTx(msgTx)
+}
+
+// TestGeneratorHarness ensures the harness mines blocks and spends their
+// coinbases.
+func TestGeneratorHarness(t *testing.T) {
+	h, teardown := newGeneratorHarness(t, 2)
+	defer teardown()
+
+	tx := h.spend(h.spendable[0], 1, 1000)
+	h.source.add(tx, 1000)
+	block := h.mine(nil)
+	if len(block.Transactions()) != 2 {
+		t.Fatalf("got %d transactions, want 2", len(block.Transactions()))
+	}
+}
+
+// checkTemplatesEqual ensures the passed block templates contain the same
+// transactions in the same order along with the same fees and signature
+// operation costs.
+func checkTemplatesEqual(t *testing.T, got, want *BlockTemplate) {
+	t.Helper()
+
+	gotTxns := got.Block.Transactions
+	wantTxns := want.Block.Transactions
+	if len(gotTxns) != len(wantTxns) {
+		t.Fatalf("got %d transactions, want %d", len(gotTxns),
+			len(wantTxns))
+	}
+	for i := range gotTxns {
+		if gotTxns[i].TxHash() != wantTxns[i].TxHash() {
+			t.Fatalf("transaction %d: got %v, want %v", i,
+				gotTxns[i].TxHash(), wantTxns[i].TxHash())
+		}
+	}
+	if !reflect.DeepEqual(got.Fees, want.Fees) {
+		t.Fatalf("got fees %v, want %v", got.Fees, want.Fees)
+	}
+	if !reflect.DeepEqual(got.SigOpCosts, want.SigOpCosts) {
+		t.Fatalf("got sigop costs %v, want %v", got.SigOpCosts,
+			want.SigOpCosts)
+	}
+	if !bytes.Equal(got.WitnessCommitment, want.WitnessCommitment) {
+		t.Fatalf("got witness commitment %x, want %x",
+			got.WitnessCommitment, want.WitnessCommitment)
+	}
+	if got.Block.Header.MerkleRoot != want.Block.Header.MerkleRoot {
+		t.Fatalf("got merkle root %v, want %v",
+			got.Block.Header.MerkleRoot, want.Block.Header.MerkleRoot)
+	}
+}
+
+// TestTemplateReuse ensures the most recent block template is handed out again
+// while the source pool does not change and that the copies handed out are
+// independent of each other.
+func TestTemplateReuse(t *testing.T) {
+	h, teardown := newGeneratorHarness(t, 2)
+	defer teardown()
+
+	h.source.add(h.spend(h.spendable[0], 1, 1000), 1000)
+	template, err := h.g.NewBlockTemplate(nil)
+	if err != nil {
+		t.Fatalf("NewBlockTemplate: unexpected error: %v", err)
+	}
+	builds := h.g.TemplateStats().Builds
+
+	reused, err := h.g.NewBlockTemplate(nil)
+	if err != nil {
+		t.Fatalf("NewBlockTemplate: unexpected error: %v", err)
+	}
+	stats := h.g.TemplateStats()
+	if stats.Builds != builds || stats.Reused != 1 {
+		t.Fatalf("got %d builds and %d reused templates, want %d and 1",
+			stats.Builds, stats.Reused, builds)
+	}
+	checkTemplatesEqual(t, reused, template)
+
+	// Modifying a template handed out must not modify the cached one.
+	err = h.g.UpdateExtraNonce(reused.Block, reused.Height, 1)
+	if err != nil {
+		t.Fatalf("UpdateExtraNonce: unexpected error: %v", err)
+	}
+	reused.Fees[0] = 0
+	again, err := h.g.NewBlockTemplate(nil)
+	if err != nil {
+		t.Fatalf("NewBlockTemplate: unexpected error: %v", err)
+	}
+	checkTemplatesEqual(t, again, template)
+
+	// A template paying to a different address is built.
+	addr, err := navutil.NewAddressPubKeyHash(make([]byte, 20),
+		h.params)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash: unexpected error: %v", err)
+	}
+	if _, err := h.g.NewBlockTemplate(addr); err != nil {
+		t.Fatalf("NewBlockTemplate: unexpected error: %v", err)
+	}
+	if stats := h.g.TemplateStats(); stats.Builds != builds+1 {
+		t.Fatalf("got %d builds, want %d", stats.Builds, builds+1)
+	}
+}
+
+// TestIncrementalTemplate ensures block templates which are updated
+// incrementally with the transactions added to and removed from the source
+// pool are the same as templates built from scratch.
+func TestIncrementalTemplate(t *testing.T) {
+	h, teardown := newGeneratorHarness(t, 4)
+	defer teardown()
+
+	// Add a few transactions, including one which spends another one in the
+	// source pool, and build a template to populate the cache.
+	txA := h.spend(h.spendable[0], 1, 1000)
+	txB := h.spend(h.spendable[1], 2, 5000)
+	txC := h.spend(txB, 1, 2000)
+	h.source.add(txA, 1000)
+	h.source.add(txB, 5000)
+	h.source.add(txC, 2000)
+	if _, err := h.g.NewBlockTemplate(nil); err != nil {
+		t.Fatalf("NewBlockTemplate: unexpected error: %v", err)
+	}
+	fullRebuilds := h.g.TemplateStats().FullRebuilds
+
+	tests := []struct {
+		name   string
+		update func()
+	}{{
+		name: "add transaction",
+		update: func() {
+			h.source.add(h.spend(h.spendable[2], 1, 3000), 3000)
+		},
+	}, {
+		name: "remove transaction",
+		update: func() {
+			h.source.remove(txA)
+		},
+	}, {
+		name: "remove parent transaction",
+		update: func() {
+			h.source.remove(txB)
+		},
+	}, {
+		name: "add and remove transactions",
+		update: func() {
+			h.source.add(txA, 1000)
+			h.source.add(h.spend(h.spendable[3], 1, 500), 500)
+			h.source.remove(txC)
+		},
+	}}
+
+	for _, test := range tests {
+		test.update()
+		incremental, err := h.g.NewBlockTemplate(nil)
+		if err != nil {
+			t.Fatalf("%s: NewBlockTemplate: unexpected error: %v",
+				test.name, err)
+		}
+		full, err := h.newGenerator().NewBlockTemplate(nil)
+		if err != nil {
+			t.Fatalf("%s: NewBlockTemplate: unexpected error: %v",
+				test.name, err)
+		}
+		checkTemplatesEqual(t, incremental, full)
+	}
+
+	stats := h.g.TemplateStats()
+	if stats.FullRebuilds != fullRebuilds || stats.Reused != 0 {
+		t.Fatalf("got %d full rebuilds and %d reused templates, want "+
+			"%d and 0", stats.FullRebuilds, stats.Reused, fullRebuilds)
+	}
+
+	// Extending the best chain requires every transaction to be gathered
+	// again.
+	h.mine(nil)
+	if _, err := h.g.NewBlockTemplate(nil); err != nil {
+		t.Fatalf("NewBlockTemplate: unexpected error: %v", err)
+	}
+	if stats := h.g.TemplateStats(); stats.FullRebuilds != fullRebuilds+1 {
+		t.Fatalf("got %d full rebuilds, want %d", stats.FullRebuilds,
+			fullRebuilds+1)
+	}
+}
+
+// TestNewBlockTemplateWithTxns ensures block templates with explicit
+// transactions contain exactly those transactions in order and that
+// transactions which can't be included are rejected.
+func TestNewBlockTemplateWithTxns(t *testing.T) {
+	h, teardown := newGeneratorHarness(t, 2)
+	defer teardown()
+
+	parent := h.spend(h.spendable[0], 1, 1000)
+	child := h.spend(parent, 1, 2000)
+	unknown := h.spend(h.spend(h.spendable[1], 1, 0), 1, 0)
+	overspend := h.spend(h.spendable[1], 1, -1)
+	coinbase := h.spendable[1]
+
+	tests := []struct {
+		name string
+		txns []*navutil.Tx
+	}{
+		{"child before parent", []*navutil.Tx{child, parent}},
+		{"duplicate", []*navutil.Tx{parent, parent}},
+		{"coinbase", []*navutil.Tx{coinbase}},
+		{"unknown input", []*navutil.Tx{unknown}},
+		{"overspend", []*navutil.Tx{overspend}},
+	}
+	for _, test := range tests {
+		_, err := h.g.NewBlockTemplateWithTxns(nil, test.txns)
+		if err == nil {
+			t.Fatalf("%s: NewBlockTemplateWithTxns: did not return "+
+				"an error", test.name)
+		}
+	}
+
+	// The transactions are included without being in the source pool and
+	// the coinbase collects their fees.
+	template, err := h.g.NewBlockTemplateWithTxns(nil,
+		[]*navutil.Tx{parent, child})
+	if err != nil {
+		t.Fatalf("NewBlockTemplateWithTxns: unexpected error: %v", err)
+	}
+	txns := template.Block.Transactions
+	if len(txns) != 3 || txns[1].TxHash() != *parent.Hash() ||
+		txns[2].TxHash() != *child.Hash() {
+
+		t.Fatalf("got %d transactions, want the coinbase, parent and "+
+			"child", len(txns))
+	}
+	wantFees := []int64{-3000, 1000, 2000}
+	if !reflect.DeepEqual(template.Fees, wantFees) {
+		t.Fatalf("got fees %v, want %v", template.Fees, wantFees)
+	}
+	h.solve(template.Block)
+	isMainChain, _, err := h.chain.ProcessBlock(
+		navutil.NewBlock(template.Block), blockchain.BFNone)
+	if err != nil || !isMainChain {
+		t.Fatalf("ProcessBlock: main chain %v, error %v", isMainChain,
+			err)
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"time"
+
+	"github.com/navcoin/navd/blockchain"
+	"github.com/navcoin/navd/chaincfg/chainhash"
+	"github.com/navcoin/navd/wire"
+	"github.com/navcoin/navutil"
+)
+
+const (
+	// txCacheTimeout is the maximum amount of time the details gathered
+	// about the source transactions are reused before every transaction is
+	// gathered again, even when the best chain has not changed.
+	txCacheTimeout = time.Minute * 2
+
+	// templateReuseTimeout is the maximum amount of time a block template
+	// is handed out again while the source pool does not change.  It
+	// bounds how long transactions which become final as time passes are
+	// left out of the templates.
+	templateReuseTimeout = time.Second * 5
+)
+
+// cachedTx houses the details about a source transaction which are gathered
+// while building a block template and only change when the best chain
+// changes.
+type cachedTx struct {
+	// priority is the priority of the transaction for the next block.
+	priority float64
+
+	// weight is the weight of the transaction.
+	weight int64
+
+	// utxos houses the outputs in the best chain the transaction spends.
+	// The entries are shared by every template, so they must be cloned
+	// before they are modified.
+	utxos *blockchain.UtxoViewpoint
+
+	// dependsOn houses the hashes of the transactions in the source pool
+	// the transaction spends outputs of.
+	dependsOn map[chainhash.Hash]struct{}
+}
+
+// cachedTemplate houses the most recent block template built from the source
+// pool along with the parameters it was built with.
+type cachedTemplate struct {
+	template      *BlockTemplate
+	payAddress    string
+	split         *CoinbaseSplit
+	sourceUpdated time.Time
+	built         time.Time
+}
+
+// reusable returns whether or not the cached template is the same as a new
+// block template paying to the passed address with the passed coinbase split
+// would be when the source pool was last updated at the passed time.
+func (c *cachedTemplate) reusable(payAddress string, split *CoinbaseSplit, sourceUpdated time.Time) bool {
+	return c.payAddress == payAddress && c.split == split &&
+		c.sourceUpdated.Equal(sourceUpdated) &&
+		time.Since(c.built) < templateReuseTimeout
+}
+
+// txCache houses the details about the source transactions gathered for the
+// block templates built on top of a best chain block along with the most
+// recent template.  It allows block templates to be updated incrementally:
+// the most recent template is handed out again while the source pool does not
+// change, and otherwise only the transactions added to the source pool since
+// the previous template are gathered before the transactions are selected
+// again, instead of every transaction in it.
+type txCache struct {
+	prevHash chainhash.Hash
+	created  time.Time
+	txns     map[chainhash.Hash]*cachedTx
+	template *cachedTemplate
+}
+
+// newTxCache returns an empty transaction cache for block templates built on
+// top of the passed best chain block.
+func newTxCache(prevHash *chainhash.Hash) *txCache {
+	return &txCache{
+		prevHash: *prevHash,
+		created:  time.Now(),
+		txns:     make(map[chainhash.Hash]*cachedTx),
+	}
+}
+
+// expired returns whether or not the cache can no longer be used for block
+// templates built on top of the passed best chain block.
+func (c *txCache) expired(prevHash *chainhash.Hash) bool {
+	return c.prevHash != *prevHash || time.Since(c.created) >= txCacheTimeout
+}
+
+// TemplateStats houses statistics about the block templates built by a block
+// template generator.
+type TemplateStats struct {
+	// Builds is the total number of block templates built.
+	Builds uint64
+
+	// Reused is the number of block templates which were handed out again
+	// instead of being built because neither the best chain nor the source
+	// pool changed.
+	Reused uint64
+
+	// FullRebuilds is the number of block templates which had to gather the
+	// details about every source transaction because the best chain
+	// changed or the cached details timed out.
+	FullRebuilds uint64
+
+	// LastBuildTime is how long the most recent block template took to
+	// build.
+	LastBuildTime time.Duration
+
+	// AvgBuildTime is the average time taken to build a block template.
+	AvgBuildTime time.Duration
+}
+
+// encodePayAddress returns the encoded form of the passed payment address used to
+// tell whether the cached block template pays to it.
+func encodePayAddress(payToAddress navutil.Address) string {
+	if payToAddress == nil {
+		return ""
+	}
+	return payToAddress.EncodeAddress()
+}
+
+// copy returns a copy of the block template which can be modified, such as by
+// updating the coinbase or the header, without affecting the original.  The
+// transactions other than the coinbase are shared since they are never
+// modified.
+func (t *BlockTemplate) copy() *BlockTemplate {
+	msgBlock := &wire.MsgBlock{
+		Header:       t.Block.Header,
+		Transactions: make([]*wire.MsgTx, len(t.Block.Transactions)),
+	}
+	copy(msgBlock.Transactions, t.Block.Transactions)
+	msgBlock.Transactions[0] = t.Block.Transactions[0].Copy()
+
+	template := *t
+	template.Block = msgBlock
+	template.Fees = append([]int64(nil), t.Fees...)
+	template.SigOpCosts = append([]int64(nil), t.SigOpCosts...)
+	template.WitnessCommitment = append([]byte(nil),
+		t.WitnessCommitment...)
+	return &template
+}
+
+// reuseTemplate returns a copy of the most recent block template with an
+// updated timestamp when it was built on top of the passed best chain block
+// with the passed parameters and the source pool has not changed since.  It
+// returns nil otherwise.
+func (g *BlkTmplGenerator) reuseTemplate(prevHash *chainhash.Hash, payAddress string, split *CoinbaseSplit, sourceUpdated time.Time) (*BlockTemplate, error) {
+	g.cacheMtx.Lock()
+	if g.txCache == nil || g.txCache.expired(prevHash) ||
+		g.txCache.template == nil ||
+		!g.txCache.template.reusable(payAddress, split, sourceUpdated) {
+
+		g.cacheMtx.Unlock()
+		return nil, nil
+	}
+	template := g.txCache.template.template.copy()
+	g.cacheMtx.Unlock()
+
+	if err := g.UpdateBlockTime(template.Block); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// cacheTemplate caches a copy of the passed block template, which was built on
+// top of the passed best chain block with the passed parameters, so it can be
+// handed out again while the source pool does not change.
+//
+// The source pool only reports the time it was last updated with a granularity
+// of one second, so templates which were built during the same second as the
+// update are not cached since a later update during that second can't be told
+// apart from it.
+func (g *BlkTmplGenerator) cacheTemplate(prevHash *chainhash.Hash, payAddress string, split *CoinbaseSplit, sourceUpdated, buildStart time.Time, template *BlockTemplate) {
+	if !sourceUpdated.Before(buildStart.Truncate(time.Second)) {
+		return
+	}
+
+	g.cacheMtx.Lock()
+	if g.txCache != nil && g.txCache.prevHash == *prevHash {
+		g.txCache.template = &cachedTemplate{
+			template:      template.copy(),
+			payAddress:    payAddress,
+			split:         split,
+			sourceUpdated: sourceUpdated,
+			built:         buildStart,
+		}
+	}
+	g.cacheMtx.Unlock()
+}
+
+// gatherTx returns the details about the passed source transaction needed to
+// consider it for inclusion in a block at the passed height.  It returns nil
+// when the transaction spends an output which is neither in the best chain
+// nor the source pool.
+func (g *BlkTmplGenerator) gatherTx(tx *navutil.Tx, nextBlockHeight int32) *cachedTx {
+	// Fetch all of the utxos referenced by the this transaction.
+	// NOTE: This intentionally does not fetch inputs from the mempool
+	// since a transaction which depends on other transactions in the
+	// mempool must come after those dependencies in the final generated
+	// block.
+	utxos, err := g.chain.FetchUtxoView(tx)
+	if err != nil {
+		log.Warnf("Unable to fetch utxo view for tx %s: %v",
+			tx.Hash(), err)
+		return nil
+	}
+
+	// Setup dependencies for any transactions which reference other
+	// transactions in the mempool so they can be properly ordered.
+	var dependsOn map[chainhash.Hash]struct{}
+	for _, txIn := range tx.MsgTx().TxIn {
+		originHash := &txIn.PreviousOutPoint.Hash
+		originIndex := txIn.PreviousOutPoint.Index
+		utxoEntry := utxos.LookupEntry(originHash)
+		if utxoEntry == nil || utxoEntry.IsOutputSpent(originIndex) {
+			if !g.txSource.HaveTransaction(originHash) {
+				log.Tracef("Skipping tx %s because it "+
+					"references unspent output %s "+
+					"which is not available",
+					tx.Hash(), txIn.PreviousOutPoint)
+				return nil
+			}
+
+			// The transaction is referencing another transaction
+			// in the source pool, so setup an ordering
+			// dependency.
+			if dependsOn == nil {
+				dependsOn = make(map[chainhash.Hash]struct{})
+			}
+			dependsOn[*originHash] = struct{}{}
+		}
+	}
+
+	// Calculate the final transaction priority using the input value age
+	// sum as well as the adjusted transaction size.  The formula is:
+	// sum(inputValue * inputAge) / adjustedTxSize
+	return &cachedTx{
+		priority:  CalcPriority(tx.MsgTx(), utxos, nextBlockHeight),
+		weight:    blockchain.GetTransactionWeight(tx),
+		utxos:     utxos,
+		dependsOn: dependsOn,
+	}
+}
+
+// recordBuild updates the template statistics with a block template which
+// took the passed amount of time to build.
+func (g *BlkTmplGenerator) recordBuild(buildTime time.Duration, fullRebuild bool) {
+	g.statsMtx.Lock()
+	g.stats.Builds++
+	if fullRebuild {
+		g.stats.FullRebuilds++
+	}
+	g.stats.LastBuildTime = buildTime
+	g.totalBuildTime += buildTime
+	g.stats.AvgBuildTime = g.totalBuildTime /
+		time.Duration(g.stats.Builds)
+	g.statsMtx.Unlock()
+}
+
+// recordReuse updates the template statistics with a block template which was
+// handed out again instead of being built.
+func (g *BlkTmplGenerator) recordReuse() {
+	g.statsMtx.Lock()
+	g.stats.Reused++
+	g.statsMtx.Unlock()
+}
+
+// TemplateStats returns statistics about the block templates built by the
+// generator.
+//
+// This function is safe for concurrent access.
+func (g *BlkTmplGenerator) TemplateStats() TemplateStats {
+	g.statsMtx.Lock()
+	stats := g.stats
+	g.statsMtx.Unlock()
+	return stats
+}
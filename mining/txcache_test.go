@@ -0,0 +1,53 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"testing"
+	"time"
+
+	"github.com/navcoin/navd/chaincfg/chainhash"
+)
+
+// TestTxCacheExpired ensures the transaction cache expires when the best chain
+// changes or it times out.
+func TestTxCacheExpired(t *testing.T) {
+	prevHash := chainhash.Hash{0x01}
+	otherHash := chainhash.Hash{0x02}
+
+	cache := newTxCache(&prevHash)
+	if cache.expired(&prevHash) {
+		t.Fatal("new cache is expired")
+	}
+	if !cache.expired(&otherHash) {
+		t.Fatal("cache is not expired after the best chain changed")
+	}
+
+	cache.created = time.Now().Add(-txCacheTimeout)
+	if !cache.expired(&prevHash) {
+		t.Fatal("cache is not expired after the timeout")
+	}
+}
+
+// TestTemplateStats ensures the template statistics track the number of builds
+// and reused templates along with the build times.
+func TestTemplateStats(t *testing.T) {
+	var g BlkTmplGenerator
+	g.recordBuild(time.Second, true)
+	g.recordBuild(time.Second*3, false)
+	g.recordReuse()
+	g.recordBuild(time.Second*2, false)
+
+	want := TemplateStats{
+		Builds:        3,
+		Reused:        1,
+		FullRebuilds:  1,
+		LastBuildTime: time.Second * 2,
+		AvgBuildTime:  time.Second * 2,
+	}
+	if stats := g.TemplateStats(); stats != want {
+		t.Fatalf("TemplateStats: got %+v, want %+v", stats, want)
+	}
+}
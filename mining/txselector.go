@@ -0,0 +1,515 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"container/heap"
+
+	"github.com/navcoin/navd/blockchain"
+	"github.com/navcoin/navd/chaincfg"
+	"github.com/navcoin/navd/txscript"
+	"github.com/navcoin/navutil"
+)
+
+// TxCandidate houses a transaction from the source pool which is eligible for
+// inclusion in a block template along with the details transaction selectors
+// use to order it.
+type TxCandidate struct {
+	// Tx is the candidate transaction.
+	Tx *navutil.Tx
+
+	// Fee is the total fee the transaction pays.
+	Fee int64
+
+	// ModifiedFee is the fee of the transaction after applying the fee
+	// delta, if any.  It only affects the order in which transactions are
+	// selected and does not change the fees paid to the coinbase.
+	ModifiedFee int64
+
+	// FeePerKB is the modified fee of the transaction in Satoshi per 1000
+	// bytes.
+	FeePerKB int64
+
+	// Priority is the priority of the transaction as calculated by
+	// CalcPriority.
+	Priority float64
+
+	// Weight is the weight of the transaction.
+	Weight int64
+
+	// Parents houses the candidates which the transaction spends outputs
+	// of.  A candidate can only be added to a block template after all of
+	// its parents.
+	Parents []*TxCandidate
+
+	// Children houses the candidates which spend outputs of the
+	// transaction.
+	Children []*TxCandidate
+}
+
+// TemplateBuilder is the interface transaction selectors use to add the
+// transactions they choose to the block template being generated.
+type TemplateBuilder interface {
+	// AddTransaction attempts to add the passed candidate to the block
+	// template and returns whether or not it was added.  Candidates are
+	// not added when any of their parents have not been added, when they
+	// would exceed the block weight or signature operation limits, or
+	// when they are otherwise invalid.
+	AddTransaction(candidate *TxCandidate) bool
+
+	// BlockWeight returns the current weight of the block template.
+	BlockWeight() uint32
+}
+
+// TxSelector chooses which of the candidate transactions from the source pool
+// are added to a block template and in which order.
+//
+// The interface contract requires that implementations are safe for concurrent
+// access since the same selector is used for every block template.
+type TxSelector interface {
+	// SelectTransactions adds the candidates the selector chooses to the
+	// passed block template builder in the order they should appear in the
+	// block.  The candidates are only valid for the duration of the call.
+	SelectTransactions(candidates []*TxCandidate, policy *Policy, builder TemplateBuilder)
+}
+
+// vsize returns the virtual size of a transaction with the passed weight.
+func vsize(weight int64) int64 {
+	return (weight + blockchain.WitnessScaleFactor - 1) /
+		blockchain.WitnessScaleFactor
+}
+
+// readyCandidates tracks the number of parents of each candidate which have not
+// been added to the block template yet so candidates can be queued once all of
+// their parents have been added.
+type readyCandidates map[*TxCandidate]int
+
+// newReadyCandidates returns the dependency tracking for the passed candidates
+// along with the candidates which have no parents and are ready to be added.
+// Candidates which are not part of the passed slice are never considered
+// ready.
+func newReadyCandidates(candidates []*TxCandidate) (readyCandidates, []*TxCandidate) {
+	pending := make(readyCandidates, len(candidates))
+	ready := make([]*TxCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		pending[candidate] = len(candidate.Parents)
+		if len(candidate.Parents) == 0 {
+			ready = append(ready, candidate)
+		}
+	}
+	return pending, ready
+}
+
+// added marks the passed candidate as added to the block template and returns
+// the children which became ready as a result.
+func (pending readyCandidates) added(candidate *TxCandidate) []*TxCandidate {
+	var ready []*TxCandidate
+	for _, child := range candidate.Children {
+		remaining, ok := pending[child]
+		if !ok {
+			continue
+		}
+		pending[child] = remaining - 1
+		if remaining == 1 {
+			ready = append(ready, child)
+		}
+	}
+	return ready
+}
+
+// PriorityTxSelector is a transaction selector which fills the high-priority
+// area of the block configured by the BlockPrioritySize policy setting with
+// the transactions with the highest priority and the rest of the block with
+// the transactions which pay the highest fee per kilobyte.  Transactions which
+// pay less than the TxMinFreeFee policy setting are only added while the block
+// weight is below the BlockMinWeight policy setting.
+//
+// This is the default transaction selector.
+type PriorityTxSelector struct{}
+
+// Ensure PriorityTxSelector implements the TxSelector interface.
+var _ TxSelector = PriorityTxSelector{}
+
+// SelectTransactions adds candidates to the block template by priority and
+// then fee per kilobyte.
+//
+// This is part of the TxSelector interface.
+func (PriorityTxSelector) SelectTransactions(candidates []*TxCandidate, policy *Policy, builder TemplateBuilder) {
+	selectByFeePerKB(candidates, policy, builder, policy.BlockPrioritySize == 0)
+}
+
+// FeeRateTxSelector is a transaction selector which adds the transactions which
+// pay the highest fee per kilobyte first regardless of their priority.
+// Transactions which pay less than the TxMinFreeFee policy setting are only
+// added while the block weight is below the BlockMinWeight policy setting.
+type FeeRateTxSelector struct{}
+
+// Ensure FeeRateTxSelector implements the TxSelector interface.
+var _ TxSelector = FeeRateTxSelector{}
+
+// SelectTransactions adds candidates to the block template by fee per
+// kilobyte.
+//
+// This is part of the TxSelector interface.
+func (FeeRateTxSelector) SelectTransactions(candidates []*TxCandidate, policy *Policy, builder TemplateBuilder) {
+	selectByFeePerKB(candidates, policy, builder, true)
+}
+
+// selectByFeePerKB adds the passed candidates to the block template once all of
+// their parents have been added.  When sortedByFee is false, candidates are
+// first added by priority until the high-priority area of the block is full
+// and the rest are added by fee per kilobyte.
+func selectByFeePerKB(candidates []*TxCandidate, policy *Policy, builder TemplateBuilder, sortedByFee bool) {
+	priorityQueue := newTxPriorityQueue(len(candidates), sortedByFee)
+	pending, ready := newReadyCandidates(candidates)
+	for _, candidate := range ready {
+		heap.Push(priorityQueue, newTxPrioItem(candidate))
+	}
+
+	for priorityQueue.Len() > 0 {
+		// Grab the highest priority (or highest fee per kilobyte
+		// depending on the sort order) transaction.
+		prioItem := heap.Pop(priorityQueue).(*txPrioItem)
+		candidate := prioItem.candidate
+		tx := candidate.Tx
+		blockPlusTxWeight := builder.BlockWeight() + uint32(candidate.Weight)
+
+		// Skip free transactions once the block is larger than the
+		// minimum block size.
+		if sortedByFee &&
+			prioItem.feePerKB < int64(policy.TxMinFreeFee) &&
+			blockPlusTxWeight >= policy.BlockMinWeight {
+
+			log.Tracef("Skipping tx %s with feePerKB %d "+
+				"< TxMinFreeFee %d and block weight %d >= "+
+				"minBlockWeight %d", tx.Hash(), prioItem.feePerKB,
+				policy.TxMinFreeFee, blockPlusTxWeight,
+				policy.BlockMinWeight)
+			logSkippedDeps(candidate)
+			continue
+		}
+
+		// Prioritize by fee per kilobyte once the block is larger than
+		// the priority size or there are no more high-priority
+		// transactions.
+		if !sortedByFee && (blockPlusTxWeight >= policy.BlockPrioritySize ||
+			prioItem.priority <= MinHighPriority) {
+
+			log.Tracef("Switching to sort by fees per "+
+				"kilobyte blockSize %d >= BlockPrioritySize "+
+				"%d || priority %.2f <= minHighPriority %.2f",
+				blockPlusTxWeight, policy.BlockPrioritySize,
+				prioItem.priority, MinHighPriority)
+
+			sortedByFee = true
+			priorityQueue.SetLessFunc(txPQByFee)
+
+			// Put the transaction back into the priority queue and
+			// skip it so it is re-priortized by fees if it won't
+			// fit into the high-priority section or the priority
+			// is too low.  Otherwise this transaction will be the
+			// final one in the high-priority section, so just fall
+			// though to the code below so it is added now.
+			if blockPlusTxWeight > policy.BlockPrioritySize ||
+				prioItem.priority < MinHighPriority {
+
+				heap.Push(priorityQueue, prioItem)
+				continue
+			}
+		}
+
+		if !builder.AddTransaction(candidate) {
+			continue
+		}
+
+		// Add transactions which depend on this one (and also do not
+		// have any other unsatisified dependencies) to the priority
+		// queue.
+		for _, child := range pending.added(candidate) {
+			heap.Push(priorityQueue, newTxPrioItem(child))
+		}
+	}
+}
+
+// AncestorTxSelector is a transaction selector which adds transactions along
+// with all of their ancestors which have not been added yet as packages in
+// order of the fee per kilobyte of the whole package.  This allows a child
+// transaction which pays a high fee to pull in parents which pay a low fee.
+// Packages which pay less than the TxMinFreeFee policy setting are only added
+// while the block weight is below the BlockMinWeight policy setting, and
+// packages which would exceed the BlockMaxWeight policy setting are skipped as
+// a whole.
+type AncestorTxSelector struct{}
+
+// Ensure AncestorTxSelector implements the TxSelector interface.
+var _ TxSelector = AncestorTxSelector{}
+
+// ancestorPackage houses a candidate along with its ancestors which have not
+// been added to the block template yet.  The ancestors are ordered so every
+// transaction comes after its parents.
+type ancestorPackage struct {
+	candidate *TxCandidate
+	txns      []*TxCandidate
+	fee       int64
+	weight    int64
+	feePerKB  int64
+	version   int
+}
+
+// newAncestorPrioItem returns a priority queue item which orders the passed
+// ancestor package by the fee per kilobyte of the whole package.
+func newAncestorPrioItem(pkg *ancestorPackage) *txPrioItem {
+	return &txPrioItem{
+		candidate: pkg.candidate,
+		pkg:       pkg,
+		priority:  pkg.candidate.Priority,
+		feePerKB:  pkg.feePerKB,
+	}
+}
+
+// SelectTransactions adds candidates to the block template as packages with
+// their ancestors by the fee per kilobyte of the package.
+//
+// This is part of the TxSelector interface.
+func (AncestorTxSelector) SelectTransactions(candidates []*TxCandidate, policy *Policy, builder TemplateBuilder) {
+	// eligible tracks the passed candidates, done tracks the candidates
+	// which have either been added to the block template or can't be
+	// added, and versions tracks how many times the package of each
+	// remaining candidate has been recalculated so outdated packages in
+	// the queue are ignored.
+	eligible := make(map[*TxCandidate]struct{}, len(candidates))
+	for _, candidate := range candidates {
+		eligible[candidate] = struct{}{}
+	}
+	done := make(map[*TxCandidate]struct{}, len(candidates))
+	versions := make(map[*TxCandidate]int, len(candidates))
+
+	// newPackage returns the package of the passed candidate or nil when
+	// any of its ancestors is not eligible.
+	newPackage := func(candidate *TxCandidate) *ancestorPackage {
+		pkg := &ancestorPackage{
+			candidate: candidate,
+			version:   versions[candidate],
+		}
+		visited := make(map[*TxCandidate]struct{})
+		var visit func(*TxCandidate) bool
+		visit = func(c *TxCandidate) bool {
+			if _, ok := visited[c]; ok {
+				return true
+			}
+			visited[c] = struct{}{}
+			if _, ok := eligible[c]; !ok {
+				return false
+			}
+			for _, parent := range c.Parents {
+				if _, ok := done[parent]; ok {
+					continue
+				}
+				if !visit(parent) {
+					return false
+				}
+			}
+			pkg.txns = append(pkg.txns, c)
+			pkg.fee += c.ModifiedFee
+			pkg.weight += c.Weight
+			return true
+		}
+		if !visit(candidate) {
+			return nil
+		}
+		pkg.feePerKB = pkg.fee * 1000 / vsize(pkg.weight)
+		return pkg
+	}
+
+	queue := newTxPriorityQueue(len(candidates), true)
+	for _, candidate := range candidates {
+		if pkg := newPackage(candidate); pkg != nil {
+			heap.Push(queue, newAncestorPrioItem(pkg))
+		}
+	}
+
+	for queue.Len() > 0 {
+		pkg := heap.Pop(queue).(*txPrioItem).pkg
+		candidate := pkg.candidate
+		if _, ok := done[candidate]; ok {
+			continue
+		}
+		if pkg.version != versions[candidate] {
+			continue
+		}
+
+		// Skip packages which pay less than the minimum fee for free
+		// transactions once the block is larger than the minimum block
+		// size.
+		blockPlusPkgWeight := builder.BlockWeight() + uint32(pkg.weight)
+		if pkg.feePerKB < int64(policy.TxMinFreeFee) &&
+			blockPlusPkgWeight >= policy.BlockMinWeight {
+
+			log.Tracef("Skipping tx %s with package feePerKB %d "+
+				"< TxMinFreeFee %d and block weight %d >= "+
+				"minBlockWeight %d", candidate.Tx.Hash(),
+				pkg.feePerKB, policy.TxMinFreeFee,
+				blockPlusPkgWeight, policy.BlockMinWeight)
+			done[candidate] = struct{}{}
+			continue
+		}
+
+		// Skip packages which would exceed the max block weight as a
+		// whole so ancestors are not added without the descendant
+		// which pays for them.  The package is reconsidered should any
+		// of its ancestors be added as part of another package.
+		if blockPlusPkgWeight < builder.BlockWeight() ||
+			blockPlusPkgWeight >= policy.BlockMaxWeight {
+
+			log.Tracef("Skipping tx %s with package weight %d "+
+				"which would exceed the max block weight",
+				candidate.Tx.Hash(), pkg.weight)
+			continue
+		}
+
+		// Add the package in order.  Transactions are only done once
+		// they have been added.  A transaction which fails to be added
+		// causes the remaining descendants in the package to fail as
+		// well since their parents are missing.
+		added := make([]*TxCandidate, 0, len(pkg.txns))
+		for _, c := range pkg.txns {
+			if !builder.AddTransaction(c) {
+				break
+			}
+			done[c] = struct{}{}
+			added = append(added, c)
+		}
+
+		// Recalculate the packages of the descendants of the added
+		// transactions since they are no longer part of them.
+		updated := make(map[*TxCandidate]struct{})
+		var update func(*TxCandidate)
+		update = func(c *TxCandidate) {
+			for _, child := range c.Children {
+				if _, ok := updated[child]; ok {
+					continue
+				}
+				updated[child] = struct{}{}
+				if _, ok := done[child]; !ok {
+					versions[child]++
+					if childPkg := newPackage(child); childPkg != nil {
+						heap.Push(queue,
+							newAncestorPrioItem(childPkg))
+					}
+				}
+				update(child)
+			}
+		}
+		for _, c := range added {
+			update(c)
+		}
+	}
+}
+
+// TxFilter describes which transactions a FilterTxSelector allows based on the
+// outputs they pay to.
+type TxFilter struct {
+	// AllowClasses and AllowAddrs restrict the outputs of allowed
+	// transactions.  When either is set, every output must either be of
+	// one of the allowed script classes or pay to one of the allowed
+	// addresses.
+	AllowClasses []txscript.ScriptClass
+	AllowAddrs   []navutil.Address
+
+	// DenyClasses and DenyAddrs exclude transactions with any output which
+	// is of one of the denied script classes or pays to one of the denied
+	// addresses.  Denied outputs take precedence over allowed ones.
+	DenyClasses []txscript.ScriptClass
+	DenyAddrs   []navutil.Address
+}
+
+// FilterTxSelector is a transaction selector which only passes the candidates
+// allowed by a TxFilter on to another transaction selector.  Candidates which
+// spend outputs of transactions which are not allowed are not added either.
+type FilterTxSelector struct {
+	selector     TxSelector
+	chainParams  *chaincfg.Params
+	allowClasses map[txscript.ScriptClass]struct{}
+	allowAddrs   map[string]struct{}
+	denyClasses  map[txscript.ScriptClass]struct{}
+	denyAddrs    map[string]struct{}
+}
+
+// Ensure FilterTxSelector implements the TxSelector interface.
+var _ TxSelector = (*FilterTxSelector)(nil)
+
+// NewFilterTxSelector returns a transaction selector which only passes the
+// candidates allowed by the passed filter on to the passed selector.  The
+// chain parameters are used to extract the addresses outputs pay to.
+func NewFilterTxSelector(selector TxSelector, filter *TxFilter, params *chaincfg.Params) *FilterTxSelector {
+	classSet := func(classes []txscript.ScriptClass) map[txscript.ScriptClass]struct{} {
+		set := make(map[txscript.ScriptClass]struct{}, len(classes))
+		for _, class := range classes {
+			set[class] = struct{}{}
+		}
+		return set
+	}
+	addrSet := func(addrs []navutil.Address) map[string]struct{} {
+		set := make(map[string]struct{}, len(addrs))
+		for _, addr := range addrs {
+			set[addr.EncodeAddress()] = struct{}{}
+		}
+		return set
+	}
+
+	return &FilterTxSelector{
+		selector:     selector,
+		chainParams:  params,
+		allowClasses: classSet(filter.AllowClasses),
+		allowAddrs:   addrSet(filter.AllowAddrs),
+		denyClasses:  classSet(filter.DenyClasses),
+		denyAddrs:    addrSet(filter.DenyAddrs),
+	}
+}
+
+// Allowed returns whether or not the filter allows the passed transaction.
+func (s *FilterTxSelector) Allowed(tx *navutil.Tx) bool {
+	restricted := len(s.allowClasses) > 0 || len(s.allowAddrs) > 0
+	for _, txOut := range tx.MsgTx().TxOut {
+		// Scripts which fail to parse are treated as non-standard.
+		class, addrs, _, _ := txscript.ExtractPkScriptAddrs(
+			txOut.PkScript, s.chainParams)
+		if _, ok := s.denyClasses[class]; ok {
+			return false
+		}
+
+		_, allowed := s.allowClasses[class]
+		for _, addr := range addrs {
+			encoded := addr.EncodeAddress()
+			if _, ok := s.denyAddrs[encoded]; ok {
+				return false
+			}
+			if _, ok := s.allowAddrs[encoded]; ok {
+				allowed = true
+			}
+		}
+		if restricted && !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// SelectTransactions passes the candidates allowed by the filter on to the
+// underlying selector.
+//
+// This is part of the TxSelector interface.
+func (s *FilterTxSelector) SelectTransactions(candidates []*TxCandidate, policy *Policy, builder TemplateBuilder) {
+	allowed := make([]*TxCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		if !s.Allowed(candidate.Tx) {
+			log.Tracef("Skipping tx %s which is not allowed by the "+
+				"transaction filter", candidate.Tx.Hash())
+			continue
+		}
+		allowed = append(allowed, candidate)
+	}
+	s.selector.SelectTransactions(allowed, policy, builder)
+}
@@ -0,0 +1,331 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/navcoin/navd/blockchain"
+	"github.com/navcoin/navd/chaincfg"
+	"github.com/navcoin/navd/txscript"
+	"github.com/navcoin/navd/wire"
+	"github.com/navcoin/navutil"
+)
+
+// fakeBuilder is a TemplateBuilder which records the transactions added to it
+// and only enforces the parent ordering and the maximum block weight the same
+// way the template builder does.
+type fakeBuilder struct {
+	maxWeight uint32
+	weight    uint32
+	added     map[*TxCandidate]struct{}
+	order     []*TxCandidate
+}
+
+// AddTransaction adds the passed candidate when all of its parents have been
+// added and it fits in the block.
+func (b *fakeBuilder) AddTransaction(candidate *TxCandidate) bool {
+	for _, parent := range candidate.Parents {
+		if _, ok := b.added[parent]; !ok {
+			return false
+		}
+	}
+	if b.weight+uint32(candidate.Weight) >= b.maxWeight {
+		return false
+	}
+	b.added[candidate] = struct{}{}
+	b.weight += uint32(candidate.Weight)
+	b.order = append(b.order, candidate)
+	return true
+}
+
+// BlockWeight returns the total weight of the added transactions.
+func (b *fakeBuilder) BlockWeight() uint32 {
+	return b.weight
+}
+
+// selectorHarness houses named candidates to run transaction selectors
+// against.
+type selectorHarness struct {
+	t          *testing.T
+	candidates []*TxCandidate
+	names      map[*TxCandidate]string
+	byName     map[string]*TxCandidate
+}
+
+// newSelectorHarness returns an empty selector harness.
+func newSelectorHarness(t *testing.T) *selectorHarness {
+	return &selectorHarness{
+		t:      t,
+		names:  make(map[*TxCandidate]string),
+		byName: make(map[string]*TxCandidate),
+	}
+}
+
+// add creates a candidate with the passed name, fee per kilobyte, priority and
+// output script which spends outputs of the named parents.  Every candidate has
+// a weight of 1000, so its fee is its fee per kilobyte divided by four.
+func (h *selectorHarness) add(name string, feePerKB int64, priority float64, pkScript []byte, parents ...string) *TxCandidate {
+	msgTx := wire.NewMsgTx(wire.TxVersion)
+	for _, parent := range parents {
+		msgTx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{
+				Hash: *h.byName[parent].Tx.Hash(),
+			},
+		})
+	}
+	msgTx.AddTxOut(wire.NewTxOut(int64(len(h.candidates)+1), pkScript))
+
+	candidate := &TxCandidate{
+		Tx:          navutil.NewTx(msgTx),
+		Fee:         feePerKB / 4,
+		ModifiedFee: feePerKB / 4,
+		FeePerKB:    feePerKB,
+		Priority:    priority,
+		Weight:      1000,
+	}
+	for _, parentName := range parents {
+		parent := h.byName[parentName]
+		candidate.Parents = append(candidate.Parents, parent)
+		parent.Children = append(parent.Children, candidate)
+	}
+	h.candidates = append(h.candidates, candidate)
+	h.names[candidate] = name
+	h.byName[name] = candidate
+	return candidate
+}
+
+// run runs the passed selector against the candidates with the passed max
+// block weight, or the consensus limit when it is zero, and returns the names
+// of the added candidates in order.
+func (h *selectorHarness) run(selector TxSelector, policy *Policy, maxWeight uint32) []string {
+	if maxWeight == 0 {
+		maxWeight = blockchain.MaxBlockWeight
+	}
+	blockPolicy := *policy
+	blockPolicy.BlockMaxWeight = maxWeight
+	builder := &fakeBuilder{
+		maxWeight: maxWeight,
+		added:     make(map[*TxCandidate]struct{}),
+	}
+	selector.SelectTransactions(h.candidates, &blockPolicy, builder)
+	order := make([]string, 0, len(builder.order))
+	for _, candidate := range builder.order {
+		order = append(order, h.names[candidate])
+	}
+	return order
+}
+
+// TestTxSelectors ensures the transaction selectors add candidates in the
+// expected order while respecting their dependencies.
+func TestTxSelectors(t *testing.T) {
+	pkScript := []byte{txscript.OP_TRUE}
+	feePolicy := &Policy{TxMinFreeFee: 1000}
+	priorityPolicy := &Policy{
+		TxMinFreeFee:      1000,
+		BlockPrioritySize: 2000,
+	}
+
+	tests := []struct {
+		name     string
+		selector TxSelector
+		policy   *Policy
+		want     []string
+	}{
+		{
+			name:     "priority without priority area",
+			selector: PriorityTxSelector{},
+			policy:   feePolicy,
+			want:     []string{"rich", "medium", "parent", "child"},
+		},
+		{
+			name:     "priority with priority area",
+			selector: PriorityTxSelector{},
+			policy:   priorityPolicy,
+			want:     []string{"old", "rich", "medium", "parent", "child"},
+		},
+		{
+			name:     "fee rate",
+			selector: FeeRateTxSelector{},
+			policy:   priorityPolicy,
+			want:     []string{"rich", "medium", "parent", "child"},
+		},
+		{
+			name:     "ancestor package",
+			selector: AncestorTxSelector{},
+			policy:   feePolicy,
+			want:     []string{"rich", "parent", "child", "medium"},
+		},
+	}
+
+	for _, test := range tests {
+		h := newSelectorHarness(t)
+		h.add("rich", 50000, 0, pkScript)
+		h.add("medium", 5000, 0, pkScript)
+		h.add("parent", 2000, 0, pkScript)
+		h.add("child", 40000, 0, pkScript, "parent")
+		h.add("old", 0, MinHighPriority*2, pkScript)
+		h.add("free", 0, 0, pkScript)
+
+		got := h.run(test.selector, test.policy, 0)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// TestAncestorTxSelector ensures the ancestor package selector adds parents
+// paying a low fee ahead of unrelated transactions when their children make
+// up for it, while the fee rate selector does not.
+func TestAncestorTxSelector(t *testing.T) {
+	pkScript := []byte{txscript.OP_TRUE}
+	policy := &Policy{TxMinFreeFee: 1000}
+
+	h := newSelectorHarness(t)
+	h.add("medium", 8000, 0, pkScript)
+	h.add("parent", 2000, 0, pkScript)
+	h.add("child", 20000, 0, pkScript, "parent")
+	h.add("grandchild", 1000, 0, pkScript, "child")
+
+	got := h.run(AncestorTxSelector{}, policy, 0)
+	want := []string{"parent", "child", "medium", "grandchild"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ancestor: got %v, want %v", got, want)
+	}
+
+	got = h.run(FeeRateTxSelector{}, policy, 0)
+	want = []string{"medium", "parent", "child", "grandchild"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fee rate: got %v, want %v", got, want)
+	}
+
+	// Only the package of the child fits when the block is limited to
+	// two transactions.
+	got = h.run(AncestorTxSelector{}, policy, 2001)
+	want = []string{"parent", "child"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ancestor limited: got %v, want %v", got, want)
+	}
+}
+
+// TestAncestorTxSelectorWeight ensures the ancestor package selector skips
+// packages which would exceed the max block weight as a whole instead of
+// adding the ancestors paying a low fee without the child paying for them.
+func TestAncestorTxSelectorWeight(t *testing.T) {
+	pkScript := []byte{txscript.OP_TRUE}
+	policy := &Policy{TxMinFreeFee: 1000}
+
+	h := newSelectorHarness(t)
+	h.add("rich", 50000, 0, pkScript)
+	h.add("medium", 5000, 0, pkScript)
+	h.add("grandparent", 500, 0, pkScript)
+	h.add("parent", 500, 0, pkScript, "grandparent")
+	h.add("child", 60000, 0, pkScript, "parent")
+
+	// The package of the child pays more than the medium transaction, so
+	// it is added as a whole ahead of it when it fits.
+	got := h.run(AncestorTxSelector{}, policy, 0)
+	want := []string{"rich", "grandparent", "parent", "child", "medium"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unlimited: got %v, want %v", got, want)
+	}
+
+	// The package of the child does not fit once the block is limited to
+	// two transactions, so the next transactions which fit are added.
+	got = h.run(AncestorTxSelector{}, policy, 2001)
+	want = []string{"rich", "medium"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("limited: got %v, want %v", got, want)
+	}
+
+	// The package of the child is added instead of the medium transaction
+	// when only it fits.
+	got = h.run(AncestorTxSelector{}, policy, 4001)
+	want = []string{"rich", "grandparent", "parent", "child"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("partially limited: got %v, want %v", got, want)
+	}
+}
+
+// TestFilterTxSelector ensures the filter selector only passes allowed
+// candidates and their allowed descendants on to the underlying selector.
+func TestFilterTxSelector(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	newAddr := func(b byte) navutil.Address {
+		var hash [20]byte
+		hash[0] = b
+		addr, err := navutil.NewAddressPubKeyHash(hash[:], params)
+		if err != nil {
+			t.Fatalf("NewAddressPubKeyHash: %v", err)
+		}
+		return addr
+	}
+	payTo := func(addr navutil.Address) []byte {
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Fatalf("PayToAddrScript: %v", err)
+		}
+		return pkScript
+	}
+	nullData, err := txscript.NullDataScript([]byte("navd"))
+	if err != nil {
+		t.Fatalf("NullDataScript: %v", err)
+	}
+	pool, other, denied := newAddr(1), newAddr(2), newAddr(3)
+	policy := &Policy{}
+
+	tests := []struct {
+		name   string
+		filter TxFilter
+		want   []string
+	}{
+		{
+			name:   "no filter",
+			filter: TxFilter{},
+			want: []string{"pool", "other", "denied", "nulldata",
+				"child"},
+		},
+		{
+			name: "deny",
+			filter: TxFilter{
+				DenyClasses: []txscript.ScriptClass{txscript.NullDataTy},
+				DenyAddrs:   []navutil.Address{denied},
+			},
+			want: []string{"pool", "other"},
+		},
+		{
+			name: "allow address",
+			filter: TxFilter{
+				AllowAddrs: []navutil.Address{pool},
+			},
+			want: []string{"pool"},
+		},
+		{
+			name: "allow class",
+			filter: TxFilter{
+				AllowClasses: []txscript.ScriptClass{txscript.PubKeyHashTy},
+				DenyAddrs:    []navutil.Address{other},
+			},
+			want: []string{"pool", "denied"},
+		},
+	}
+
+	for _, test := range tests {
+		h := newSelectorHarness(t)
+		h.add("pool", 50000, 0, payTo(pool))
+		h.add("other", 40000, 0, payTo(other))
+		h.add("denied", 30000, 0, payTo(denied))
+		h.add("nulldata", 20000, 0, nullData)
+		h.add("child", 10000, 0, payTo(pool), "nulldata")
+
+		selector := NewFilterTxSelector(FeeRateTxSelector{}, &test.filter,
+			params)
+		got := h.run(selector, policy, 0)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
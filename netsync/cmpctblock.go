@@ -0,0 +1,154 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/navcoin/navd/blockchain"
+	"github.com/navcoin/navd/wire"
+	"github.com/navcoin/navutil"
+)
+
+const (
+	// maxHighBandwidthPeers is the maximum number of peers which are asked
+	// to announce new blocks by directly sending compact blocks.  The peers
+	// which most recently delivered new blocks first are selected.
+	maxHighBandwidthPeers = 3
+)
+
+var (
+	// errShortIDCollision is returned when a compact block can't be
+	// reconstructed because several transactions share a short ID.  The
+	// full block must be requested instead.
+	errShortIDCollision = errors.New("short id collision")
+
+	// errMerkleRootMismatch is returned when a reconstructed block does not
+	// match the merkle root of its header, which happens when a short ID
+	// matched the wrong transaction.  The full block must be requested
+	// instead.
+	errMerkleRootMismatch = errors.New("reconstructed block does not " +
+		"match its merkle root")
+)
+
+// partialBlock houses a block which is being reconstructed from a compact
+// block along with the indexes of the transactions which are still missing.
+type partialBlock struct {
+	msgBlock *wire.MsgBlock
+	missing  []uint32
+}
+
+// newPartialBlock reconstructs as much of the block described by the passed
+// compact block as possible from the prefilled transactions and the passed
+// known transactions, which are typically the transactions in the memory
+// pool.  The short IDs of the known transactions are calculated from their
+// hashes for compact block version 1 and their witness hashes for version 2.
+//
+// An error is returned when the compact block is malformed.  The
+// errShortIDCollision error is returned when the block can't be reconstructed
+// because several transactions share a short ID.
+func newPartialBlock(msg *wire.MsgCmpctBlock, version uint64,
+	knownTxns []*navutil.Tx) (*partialBlock, error) {
+
+	numTxns := msg.TotalTxns()
+	if numTxns == 0 {
+		return nil, errors.New("compact block does not contain any " +
+			"transactions")
+	}
+
+	// Place the prefilled transactions and keep track of the positions of
+	// the remaining transactions, which are identified by the short IDs in
+	// order.
+	txns := make([]*wire.MsgTx, numTxns)
+	for _, prefilled := range msg.PrefilledTxns {
+		if int(prefilled.Index) >= numTxns {
+			str := fmt.Sprintf("prefilled transaction index %d is "+
+				"out of range for %d transactions",
+				prefilled.Index, numTxns)
+			return nil, errors.New(str)
+		}
+		txns[prefilled.Index] = prefilled.Tx
+	}
+	shortIDIndexes := make(map[uint64]int, len(msg.ShortIDs))
+	shortIDPos := 0
+	for i := range txns {
+		if txns[i] != nil {
+			continue
+		}
+		shortID := msg.ShortIDs[shortIDPos]
+		if _, exists := shortIDIndexes[shortID]; exists {
+			return nil, errShortIDCollision
+		}
+		shortIDIndexes[shortID] = i
+		shortIDPos++
+	}
+
+	// Fill in the known transactions which match the short IDs.
+	key := msg.ShortIDKey()
+	found := make(map[uint64]struct{}, len(shortIDIndexes))
+	for _, tx := range knownTxns {
+		hash := tx.Hash()
+		if version == wire.CmpctBlockVersion2 {
+			hash = tx.WitnessHash()
+		}
+		shortID := wire.ShortTxID(&key, hash)
+		index, exists := shortIDIndexes[shortID]
+		if !exists {
+			continue
+		}
+		if _, exists := found[shortID]; exists {
+			return nil, errShortIDCollision
+		}
+		found[shortID] = struct{}{}
+		txns[index] = tx.MsgTx()
+	}
+
+	pb := &partialBlock{
+		msgBlock: &wire.MsgBlock{
+			Header:       msg.Header,
+			Transactions: txns,
+		},
+	}
+	for i, tx := range txns {
+		if tx == nil {
+			pb.missing = append(pb.missing, uint32(i))
+		}
+	}
+	return pb, nil
+}
+
+// fill adds the passed transactions, which must be in the same order as the
+// missing transaction indexes, to the partial block.
+func (pb *partialBlock) fill(txns []*wire.MsgTx) error {
+	if len(txns) != len(pb.missing) {
+		str := fmt.Sprintf("received %d transactions, expected %d",
+			len(txns), len(pb.missing))
+		return errors.New(str)
+	}
+	for i, index := range pb.missing {
+		pb.msgBlock.Transactions[index] = txns[i]
+	}
+	pb.missing = nil
+	return nil
+}
+
+// block returns the reconstructed block once all of its transactions are
+// known.  The errMerkleRootMismatch error is returned when the transactions
+// don't match the merkle root of the block header.
+func (pb *partialBlock) block() (*navutil.Block, error) {
+	if len(pb.missing) != 0 {
+		str := fmt.Sprintf("block is missing %d transactions",
+			len(pb.missing))
+		return nil, errors.New(str)
+	}
+
+	block := navutil.NewBlock(pb.msgBlock)
+	merkles := blockchain.BuildMerkleTreeStore(block.Transactions(), false)
+	if !merkles[len(merkles)-1].IsEqual(&pb.msgBlock.Header.MerkleRoot) {
+		return nil, errMerkleRootMismatch
+	}
+	return block, nil
+}
@@ -38,6 +38,11 @@ const (
 	// maxRequestedTxns is the maximum number of requested transactions
 	// hashes to store in memory.
 	maxRequestedTxns = wire.MaxInvPerMsg
+
+	// maxPartialBlocks is the maximum number of compact blocks per peer
+	// which can wait for their missing transactions at once.  Compact
+	// blocks received beyond it are requested in full instead.
+	maxPartialBlocks = 3
 )
 
 // zeroHash is the zero value hash (all zeros).  It is defined as a convenience.
@@ -70,6 +75,22 @@ type headersMsg struct {
 	peer    *peerpkg.Peer
 }
 
+// cmpctBlockMsg packages a navcoin cmpctblock message and the peer it came
+// from together so the block handler has access to that information.
+type cmpctBlockMsg struct {
+	cmpctBlock *wire.MsgCmpctBlock
+	peer       *peerpkg.Peer
+	reply      chan struct{}
+}
+
+// blockTxnMsg packages a navcoin blocktxn message and the peer it came from
+// together so the block handler has access to that information.
+type blockTxnMsg struct {
+	blockTxn *wire.MsgBlockTxn
+	peer     *peerpkg.Peer
+	reply    chan struct{}
+}
+
 // donePeerMsg signifies a newly disconnected peer to the block handler.
 type donePeerMsg struct {
 	peer *peerpkg.Peer
@@ -136,6 +157,7 @@ type peerSyncState struct {
 	requestQueue    []*wire.InvVect
 	requestedTxns   map[chainhash.Hash]struct{}
 	requestedBlocks map[chainhash.Hash]struct{}
+	partialBlocks   map[chainhash.Hash]*partialBlock
 }
 
 // SyncManager is used to communicate block related messages with peers. The
@@ -162,6 +184,11 @@ type SyncManager struct {
 	syncPeer        *peerpkg.Peer
 	peerStates      map[*peerpkg.Peer]*peerSyncState
 
+	// highBandwidthPeers houses the peers which were asked to announce new
+	// blocks by directly sending compact blocks ordered from the least to
+	// the most recently selected.
+	highBandwidthPeers []*peerpkg.Peer
+
 	// The following fields are used for headers-first mode.
 	headersFirstMode bool
 	headerList       *list.List
@@ -369,6 +396,7 @@ func (sm *SyncManager) handleNewPeerMsg(peer *peerpkg.Peer) {
 		syncCandidate:   isSyncCandidate,
 		requestedTxns:   make(map[chainhash.Hash]struct{}),
 		requestedBlocks: make(map[chainhash.Hash]struct{}),
+		partialBlocks:   make(map[chainhash.Hash]*partialBlock),
 	}
 
 	// Start syncing by choosing the best candidate if needed.
@@ -407,6 +435,15 @@ func (sm *SyncManager) handleDonePeerMsg(peer *peerpkg.Peer) {
 		delete(sm.requestedBlocks, blockHash)
 	}
 
+	// Stop tracking the peer for high-bandwidth compact block relay.
+	for i, hbPeer := range sm.highBandwidthPeers {
+		if hbPeer == peer {
+			sm.highBandwidthPeers = append(sm.highBandwidthPeers[:i],
+				sm.highBandwidthPeers[i+1:]...)
+			break
+		}
+	}
+
 	// Attempt to find a new peer to sync from if the quitting peer is the
 	// sync peer.  Also, reset the headers-first state if in headers-first
 	// mode so
@@ -438,11 +475,12 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 	// to disconnect peers for sending unsolicited transactions to provide
 	// interoperability.
 	txHash := tmsg.tx.Hash()
+	wtxid := tmsg.tx.WitnessHash()
 
 	// Ignore transactions that we have already rejected.  Do not
 	// send a reject message here because if the transaction was already
 	// rejected, the transaction was unsolicited.
-	if _, exists = sm.rejectedTxns[*txHash]; exists {
+	if _, exists = sm.rejectedTxns[*wtxid]; exists {
 		log.Debugf("Ignoring unsolicited previously rejected "+
 			"transaction %v from %s", txHash, peer)
 		return
@@ -459,11 +497,21 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 	// we'll retry next time we get an inv.
 	delete(state.requestedTxns, *txHash)
 	delete(sm.requestedTxns, *txHash)
+	delete(state.requestedTxns, *wtxid)
+	delete(sm.requestedTxns, *wtxid)
 
 	if err != nil {
 		// Do not request this transaction again until a new block
-		// has been processed.
-		sm.rejectedTxns[*txHash] = struct{}{}
+		// has been processed.  The rejection is recorded by witness
+		// hash so a transaction with a malleated witness can't prevent
+		// the valid transaction with the same hash from being accepted.
+		// When the rejection does not depend on the witness, every
+		// transaction with the same hash is rejected as well, so it is
+		// also recorded by hash to skip announcements by hash.
+		sm.rejectedTxns[*wtxid] = struct{}{}
+		if mempool.IsWitnessIndependent(err) {
+			sm.rejectedTxns[*txHash] = struct{}{}
+		}
 		sm.limitMap(sm.rejectedTxns, maxRejectedTxns)
 
 		// When the error is a rule error, it means the transaction was
@@ -563,6 +611,7 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 	// will fail the insert and thus we'll retry next time we get an inv.
 	delete(state.requestedBlocks, *blockHash)
 	delete(sm.requestedBlocks, *blockHash)
+	delete(state.partialBlocks, *blockHash)
 
 	// Process the block to include validation, best chain selection, orphan
 	// handling, etc.
@@ -646,6 +695,12 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 
 		// Clear the rejected transactions.
 		sm.rejectedTxns = make(map[chainhash.Hash]struct{})
+
+		// Ask the peer to announce new blocks by directly sending
+		// compact blocks since it delivered this one.
+		if sm.current() {
+			sm.selectHighBandwidthPeer(peer)
+		}
 	}
 
 	// Update the block height for this peer. But only send a message to
@@ -712,6 +767,225 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 	}
 }
 
+// handleCmpctBlockMsg handles cmpctblock messages from all peers.  The block is
+// reconstructed from the prefilled transactions and the transactions in the
+// memory pool.  Any transactions which are still missing are requested from
+// the peer, and the full block is requested instead when the block can't be
+// reconstructed.  Peers which send compact blocks with invalid headers are
+// disconnected before any reconstruction is attempted.
+func (sm *SyncManager) handleCmpctBlockMsg(cmsg *cmpctBlockMsg) {
+	peer := cmsg.peer
+	state, exists := sm.peerStates[peer]
+	if !exists {
+		log.Warnf("Received cmpctblock message from unknown peer %s", peer)
+		return
+	}
+
+	// Ignore compact blocks from peers which did not negotiate compact
+	// block relay.
+	version := peer.CompactBlockVersion()
+	if version == 0 {
+		log.Debugf("Ignoring cmpctblock from %s -- compact blocks "+
+			"were not negotiated", peer)
+		return
+	}
+
+	msg := cmsg.cmpctBlock
+	blockHash := msg.BlockHash()
+	peer.AddKnownInventory(wire.NewInvVect(wire.InvTypeBlock, &blockHash))
+
+	// Nothing to do when the block is already known or is already being
+	// reconstructed.
+	haveBlock, err := sm.chain.HaveBlock(&blockHash)
+	if err != nil || haveBlock {
+		delete(state.requestedBlocks, blockHash)
+		delete(sm.requestedBlocks, blockHash)
+		return
+	}
+	if _, exists := state.partialBlocks[blockHash]; exists {
+		return
+	}
+
+	// Compact blocks are only requested once the chain is current, so
+	// unsolicited ones are ignored while syncing to avoid fetching a mass
+	// of orphans.
+	_, requested := state.requestedBlocks[blockHash]
+	if !requested && (sm.headersFirstMode || !sm.current()) {
+		return
+	}
+
+	// Check the header before doing any work to reconstruct the block so
+	// peers can't make the manager track blocks which can never be valid.
+	// The parent of a requested block may legitimately be unknown, in
+	// which case the full block is fetched so it is handled as an orphan.
+	if err := sm.chain.CheckBlockHeader(&msg.Header); err != nil {
+		if rerr, ok := err.(blockchain.RuleError); !ok {
+			log.Errorf("Unable to check the header of compact block "+
+				"%v from %s: %v", blockHash, peer, err)
+			return
+		} else if rerr.ErrorCode == blockchain.ErrPreviousBlockUnknown {
+			log.Debugf("Unable to check the header of compact block "+
+				"%v from %s: %v", blockHash, peer, err)
+			if requested {
+				sm.requestFullBlock(peer, &blockHash)
+			}
+			return
+		}
+		log.Warnf("Invalid header for compact block %v from %s: %v -- "+
+			"disconnecting", blockHash, peer, err)
+		delete(state.requestedBlocks, blockHash)
+		delete(sm.requestedBlocks, blockHash)
+		peer.Disconnect()
+		return
+	}
+
+	sm.requestedBlocks[blockHash] = struct{}{}
+	sm.limitMap(sm.requestedBlocks, maxRequestedBlocks)
+	state.requestedBlocks[blockHash] = struct{}{}
+
+	pb, err := newPartialBlock(msg, version, sm.txMemPoolTxns())
+	if err == errShortIDCollision {
+		log.Debugf("Unable to reconstruct compact block %v from %s: "+
+			"%v -- requesting full block", blockHash, peer, err)
+		sm.requestFullBlock(peer, &blockHash)
+		return
+	}
+	if err != nil {
+		log.Warnf("Invalid compact block %v from %s: %v -- "+
+			"disconnecting", blockHash, peer, err)
+		peer.Disconnect()
+		return
+	}
+
+	// Request the missing transactions from the peer, or the full block
+	// when too many compact blocks from the peer are already waiting for
+	// their transactions.
+	if len(pb.missing) > 0 && len(state.partialBlocks) >= maxPartialBlocks {
+		log.Debugf("Too many compact blocks waiting for transactions "+
+			"from %s -- requesting full block %v", peer, blockHash)
+		sm.requestFullBlock(peer, &blockHash)
+		return
+	}
+	if len(pb.missing) > 0 {
+		log.Debugf("Requesting %d of %d transactions of compact block "+
+			"%v from %s", len(pb.missing), msg.TotalTxns(), blockHash,
+			peer)
+		state.partialBlocks[blockHash] = pb
+		getBlockTxn := wire.NewMsgGetBlockTxn(&blockHash, pb.missing)
+		peer.QueueMessage(getBlockTxn, nil)
+		return
+	}
+
+	sm.processPartialBlock(peer, pb)
+}
+
+// handleBlockTxnMsg handles blocktxn messages from all peers by completing the
+// reconstruction of the compact block the transactions belong to.
+func (sm *SyncManager) handleBlockTxnMsg(bmsg *blockTxnMsg) {
+	peer := bmsg.peer
+	state, exists := sm.peerStates[peer]
+	if !exists {
+		log.Warnf("Received blocktxn message from unknown peer %s", peer)
+		return
+	}
+
+	msg := bmsg.blockTxn
+	pb, exists := state.partialBlocks[msg.BlockHash]
+	if !exists {
+		log.Debugf("Ignoring unrequested blocktxn for block %v from %s",
+			msg.BlockHash, peer)
+		return
+	}
+	delete(state.partialBlocks, msg.BlockHash)
+
+	if err := pb.fill(msg.Transactions); err != nil {
+		log.Warnf("Invalid blocktxn for block %v from %s: %v -- "+
+			"disconnecting", msg.BlockHash, peer, err)
+		peer.Disconnect()
+		return
+	}
+
+	sm.processPartialBlock(peer, pb)
+}
+
+// processPartialBlock processes a block which has been fully reconstructed
+// from a compact block sent by the passed peer in the same way as a block
+// received in full.  The full block is requested instead when the
+// reconstructed block does not match its header.
+func (sm *SyncManager) processPartialBlock(peer *peerpkg.Peer, pb *partialBlock) {
+	blockHash := pb.msgBlock.BlockHash()
+	block, err := pb.block()
+	if err != nil {
+		log.Debugf("Unable to reconstruct compact block %v from %s: "+
+			"%v -- requesting full block", blockHash, peer, err)
+		sm.requestFullBlock(peer, &blockHash)
+		return
+	}
+
+	sm.handleBlockMsg(&blockMsg{block: block, peer: peer})
+}
+
+// requestFullBlock requests the block with the passed hash in full from the
+// passed peer, which is done when a compact block can't be reconstructed.
+func (sm *SyncManager) requestFullBlock(peer *peerpkg.Peer, hash *chainhash.Hash) {
+	state, exists := sm.peerStates[peer]
+	if !exists {
+		return
+	}
+	sm.requestedBlocks[*hash] = struct{}{}
+	sm.limitMap(sm.requestedBlocks, maxRequestedBlocks)
+	state.requestedBlocks[*hash] = struct{}{}
+
+	ivType := wire.InvTypeBlock
+	if peer.IsWitnessEnabled() {
+		ivType = wire.InvTypeWitnessBlock
+	}
+	gdmsg := wire.NewMsgGetData()
+	gdmsg.AddInvVect(wire.NewInvVect(ivType, hash))
+	peer.QueueMessage(gdmsg, nil)
+}
+
+// txMemPoolTxns returns the transactions in the memory pool for use when
+// reconstructing compact blocks.
+func (sm *SyncManager) txMemPoolTxns() []*navutil.Tx {
+	descs := sm.txMemPool.TxDescs()
+	txns := make([]*navutil.Tx, 0, len(descs))
+	for _, desc := range descs {
+		txns = append(txns, desc.Tx)
+	}
+	return txns
+}
+
+// selectHighBandwidthPeer asks the passed peer to announce new blocks by
+// directly sending compact blocks when it supports compact block relay.  Only
+// the peers which most recently delivered new blocks are kept in
+// high-bandwidth mode, so the least recently selected peer is asked to go
+// back to announcing blocks normally when there are too many.
+func (sm *SyncManager) selectHighBandwidthPeer(peer *peerpkg.Peer) {
+	if peer.CompactBlockVersion() == 0 {
+		return
+	}
+
+	// Move the peer to the end of the list when it is already selected.
+	for i, hbPeer := range sm.highBandwidthPeers {
+		if hbPeer == peer {
+			copy(sm.highBandwidthPeers[i:], sm.highBandwidthPeers[i+1:])
+			sm.highBandwidthPeers[len(sm.highBandwidthPeers)-1] = peer
+			return
+		}
+	}
+
+	if len(sm.highBandwidthPeers) >= maxHighBandwidthPeers {
+		oldest := sm.highBandwidthPeers[0]
+		sm.highBandwidthPeers = sm.highBandwidthPeers[1:]
+		oldest.PushSendCmpctMsg(false)
+	}
+	sm.highBandwidthPeers = append(sm.highBandwidthPeers, peer)
+	peer.PushSendCmpctMsg(true)
+	log.Debugf("Selected peer %s for high-bandwidth compact block relay",
+		peer)
+}
+
 // fetchHeaderBlocks creates and sends a request to the syncPeer for the next
 // list of blocks to be downloaded based on the current list of headers.
 func (sm *SyncManager) fetchHeaderBlocks() {
@@ -904,6 +1178,13 @@ func (sm *SyncManager) haveInventory(invVect *wire.InvVect) (bool, error) {
 			return false, err
 		}
 		return entry != nil && !entry.IsFullySpent(), nil
+
+	case wire.InvTypeWTx:
+		// Ask the transaction memory pool if the transaction is known
+		// to it in any form (main pool or orphan).  Transactions which
+		// are already confirmed can't be looked up by witness hash, so
+		// they are requested again and rejected by the memory pool.
+		return sm.txMemPool.HaveTransactionByWTxID(&invVect.Hash), nil
 	}
 
 	// The requested inventory is is an unsupported type, so just claim
@@ -960,6 +1241,7 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 	// request parent blocks of orphans if we receive one we already have.
 	// Finally, attempt to detect potential stalls due to long side chains
 	// we already have and request more blocks to prevent them.
+	wtxidRelay := peer.WTxIDRelay()
 	for i, iv := range invVects {
 		// Ignore unsupported inventory types.
 		switch iv.Type {
@@ -967,10 +1249,20 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 		case wire.InvTypeTx:
 		case wire.InvTypeWitnessBlock:
 		case wire.InvTypeWitnessTx:
+		case wire.InvTypeWTx:
 		default:
 			continue
 		}
 
+		// Peers which negotiated relaying transactions by witness hash
+		// announce them by witness hash only and other peers by hash
+		// only, so ignore announcements of the other kind.
+		if (iv.Type == wire.InvTypeTx && wtxidRelay) ||
+			(iv.Type == wire.InvTypeWTx && !wtxidRelay) {
+
+			continue
+		}
+
 		// Add the inventory to the cache of known inventory
 		// for the peer.
 		peer.AddKnownInventory(iv)
@@ -989,7 +1281,7 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 			continue
 		}
 		if !haveInv {
-			if iv.Type == wire.InvTypeTx {
+			if iv.Type == wire.InvTypeTx || iv.Type == wire.InvTypeWTx {
 				// Skip the transaction if it has already been
 				// rejected.
 				if _, exists := sm.rejectedTxns[iv.Hash]; exists {
@@ -1072,7 +1364,13 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 				sm.limitMap(sm.requestedBlocks, maxRequestedBlocks)
 				state.requestedBlocks[iv.Hash] = struct{}{}
 
-				if peer.IsWitnessEnabled() {
+				// Request a compact block once the chain is
+				// current when the peer supports them.
+				switch {
+				case peer.CompactBlockVersion() != 0 &&
+					sm.current():
+					iv.Type = wire.InvTypeCmpctBlock
+				case peer.IsWitnessEnabled():
 					iv.Type = wire.InvTypeWitnessBlock
 				}
 
@@ -1096,6 +1394,19 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 					iv.Type = wire.InvTypeWitnessTx
 				}
 
+				gdmsg.AddInvVect(iv)
+				numRequested++
+			}
+
+		case wire.InvTypeWTx:
+			// Request the transaction by witness hash, which
+			// always includes its witness data, if there is not
+			// already a pending request.
+			if _, exists := sm.requestedTxns[iv.Hash]; !exists {
+				sm.requestedTxns[iv.Hash] = struct{}{}
+				sm.limitMap(sm.requestedTxns, maxRequestedTxns)
+				state.requestedTxns[iv.Hash] = struct{}{}
+
 				gdmsg.AddInvVect(iv)
 				numRequested++
 			}
@@ -1152,6 +1463,14 @@ out:
 				sm.handleBlockMsg(msg)
 				msg.reply <- struct{}{}
 
+			case *cmpctBlockMsg:
+				sm.handleCmpctBlockMsg(msg)
+				msg.reply <- struct{}{}
+
+			case *blockTxnMsg:
+				sm.handleBlockTxnMsg(msg)
+				msg.reply <- struct{}{}
+
 			case *invMsg:
 				sm.handleInvMsg(msg)
 
@@ -1224,9 +1543,11 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 			break
 		}
 
-		// Generate the inventory vector and relay it.
+		// Generate the inventory vector and relay it.  The block is
+		// relayed along with the inventory vector so it can be sent
+		// directly as a compact block to peers which requested it.
 		iv := wire.NewInvVect(wire.InvTypeBlock, block.Hash())
-		sm.peerNotifier.RelayInventory(iv, block.MsgBlock().Header)
+		sm.peerNotifier.RelayInventory(iv, block)
 
 	// A block has been connected to the main block chain.
 	case blockchain.NTBlockConnected:
@@ -1244,7 +1565,8 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 		// transaction are NOT removed recursively because they are still
 		// valid.
 		for _, tx := range block.Transactions()[1:] {
-			sm.txMemPool.RemoveTransaction(tx, false)
+			sm.txMemPool.RemoveTransaction(tx, false,
+				mempool.RemovalConfirmed)
 			sm.txMemPool.RemoveDoubleSpends(tx)
 			sm.txMemPool.RemoveOrphan(tx)
 			sm.peerNotifier.TransactionConfirmed(tx)
@@ -1283,7 +1605,8 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 				// Remove the transaction and all transactions
 				// that depend on it if it wasn't accepted into
 				// the transaction pool.
-				sm.txMemPool.RemoveTransaction(tx, true)
+				sm.txMemPool.RemoveTransaction(tx, true,
+					mempool.RemovalConflict)
 			}
 		}
 
@@ -1329,6 +1652,32 @@ func (sm *SyncManager) QueueBlock(block *navutil.Block, peer *peerpkg.Peer, done
 	sm.msgChan <- &blockMsg{block: block, peer: peer, reply: done}
 }
 
+// QueueCmpctBlock adds the passed cmpctblock message and peer to the block
+// handling queue.  Responds to the done channel argument after the cmpctblock
+// message is processed.
+func (sm *SyncManager) QueueCmpctBlock(cmpctBlock *wire.MsgCmpctBlock, peer *peerpkg.Peer, done chan struct{}) {
+	// Don't accept more blocks if we're shutting down.
+	if atomic.LoadInt32(&sm.shutdown) != 0 {
+		done <- struct{}{}
+		return
+	}
+
+	sm.msgChan <- &cmpctBlockMsg{cmpctBlock: cmpctBlock, peer: peer, reply: done}
+}
+
+// QueueBlockTxn adds the passed blocktxn message and peer to the block handling
+// queue.  Responds to the done channel argument after the blocktxn message is
+// processed.
+func (sm *SyncManager) QueueBlockTxn(blockTxn *wire.MsgBlockTxn, peer *peerpkg.Peer, done chan struct{}) {
+	// Don't accept more blocks if we're shutting down.
+	if atomic.LoadInt32(&sm.shutdown) != 0 {
+		done <- struct{}{}
+		return
+	}
+
+	sm.msgChan <- &blockTxnMsg{blockTxn: blockTxn, peer: peer, reply: done}
+}
+
 // QueueInv adds the passed inv message and peer to the block handling queue.
 func (sm *SyncManager) QueueInv(inv *wire.MsgInv, peer *peerpkg.Peer) {
 	// No channel handling here because peers do not need to block on inv
@@ -0,0 +1,221 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/navcoin/navd/blockchain"
+	"github.com/navcoin/navd/chaincfg"
+	"github.com/navcoin/navd/chaincfg/chainhash"
+	"github.com/navcoin/navd/database"
+	_ "github.com/navcoin/navd/database/ffldb"
+	peerpkg "github.com/navcoin/navd/peer"
+	"github.com/navcoin/navd/wire"
+)
+
+// fixedTimeSource is a blockchain.MedianTimeSource which always reports the
+// same time.
+type fixedTimeSource struct {
+	now time.Time
+}
+
+func (s fixedTimeSource) AdjustedTime() time.Time         { return s.now }
+func (s fixedTimeSource) AddTimeSample(string, time.Time) {}
+func (s fixedTimeSource) Offset() time.Duration           { return 0 }
+
+// addrConn is a net.Conn which reports a fixed TCP remote address so peers can
+// be created over in-memory pipes.
+type addrConn struct {
+	net.Conn
+	raddr *net.TCPAddr
+}
+
+// RemoteAddr returns the remote address of the connection.
+func (c addrConn) RemoteAddr() net.Addr {
+	return c.raddr
+}
+
+// newCmpctBlockPeer returns an inbound peer connected over an in-memory pipe to
+// a remote peer driven by the test, which negotiates compact block relay with
+// it.  It waits until compact block relay has been negotiated.
+func newCmpctBlockPeer(t *testing.T, params *chaincfg.Params) (*peerpkg.Peer, func()) {
+	inConn, remoteConn := net.Pipe()
+	p := peerpkg.NewInboundPeer(&peerpkg.Config{
+		UserAgentName:    "peer",
+		UserAgentVersion: "1.0",
+		ChainParams:      params,
+		Services:         wire.SFNodeNetwork | wire.SFNodeWitness,
+		CompactBlocks:    true,
+	})
+	p.AssociateConnection(addrConn{inConn, &net.TCPAddr{
+		IP: net.ParseIP("10.0.0.2"), Port: 18444,
+	}})
+	teardown := func() {
+		p.Disconnect()
+		remoteConn.Close()
+	}
+
+	// Write the remote side of the handshake, which negotiates compact
+	// block relay, and discard everything the peer sends.
+	services := wire.SFNodeNetwork | wire.SFNodeWitness
+	me := wire.NewNetAddressIPPort(net.ParseIP("10.0.0.2"), 18444, services)
+	you := wire.NewNetAddressIPPort(net.ParseIP("10.0.0.1"), 18444, services)
+	version := wire.NewMsgVersion(me, you, 1, 0)
+	version.Services = services
+	msgs := []wire.Message{
+		version,
+		wire.NewMsgVerAck(),
+		wire.NewMsgSendCmpct(false, wire.CmpctBlockVersion1),
+	}
+	go func() {
+		for _, msg := range msgs {
+			err := wire.WriteMessage(remoteConn, msg,
+				wire.ProtocolVersion, params.Net)
+			if err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		for {
+			_, _, err := wire.ReadMessage(remoteConn,
+				wire.ProtocolVersion, params.Net)
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for p.CompactBlockVersion() == 0 {
+		if time.Now().After(deadline) {
+			teardown()
+			t.Fatal("timeout waiting for compact block negotiation")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return p, teardown
+}
+
+// newTestSyncManager returns a sync manager backed by a new regression test
+// chain which considers itself current.
+func newTestSyncManager(t *testing.T) (*SyncManager, func()) {
+	DisableLog()
+
+	dbPath, err := ioutil.TempDir("", "netsyncmanager")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	params := chaincfg.RegressionNetParams
+	db, err := database.Create("ffldb", dbPath, params.Net)
+	if err != nil {
+		os.RemoveAll(dbPath)
+		t.Fatalf("unable to create database: %v", err)
+	}
+	teardown := func() {
+		db.Close()
+		os.RemoveAll(dbPath)
+	}
+
+	// The chain is only current when its tip is recent, so report a time
+	// shortly after the genesis block.
+	genesisTime := params.GenesisBlock.Header.Timestamp
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: &params,
+		TimeSource:  fixedTimeSource{genesisTime.Add(time.Hour)},
+	})
+	if err != nil {
+		teardown()
+		t.Fatalf("unable to create chain: %v", err)
+	}
+
+	sm, err := New(&Config{
+		Chain:              chain,
+		ChainParams:        &params,
+		DisableCheckpoints: true,
+		MaxPeers:           8,
+	})
+	if err != nil {
+		teardown()
+		t.Fatalf("unable to create sync manager: %v", err)
+	}
+	return sm, teardown
+}
+
+// TestUnsolicitedCmpctBlockHeader ensures unsolicited compact blocks are only
+// reconstructed when their headers are valid, and that peers which send
+// invalid headers are disconnected.
+func TestUnsolicitedCmpctBlockHeader(t *testing.T) {
+	sm, teardown := newTestSyncManager(t)
+	defer teardown()
+	if !sm.current() {
+		t.Fatal("sync manager is not current")
+	}
+
+	genesis := sm.chainParams.GenesisBlock
+	genesisHash := genesis.BlockHash()
+	tests := []struct {
+		name       string
+		prevBlock  chainhash.Hash
+		bits       uint32
+		disconnect bool
+	}{{
+		name:       "proof of work does not meet target",
+		prevBlock:  genesisHash,
+		bits:       0x1d00ffff,
+		disconnect: true,
+	}, {
+		name:       "target above proof of work limit",
+		prevBlock:  genesisHash,
+		bits:       0x21010000,
+		disconnect: true,
+	}, {
+		name:       "unknown parent",
+		prevBlock:  chainhash.Hash{0x01},
+		bits:       genesis.Header.Bits,
+		disconnect: false,
+	}}
+
+	for _, test := range tests {
+		peer, peerTeardown := newCmpctBlockPeer(t, sm.chainParams)
+		state := &peerSyncState{
+			requestedTxns:   make(map[chainhash.Hash]struct{}),
+			requestedBlocks: make(map[chainhash.Hash]struct{}),
+			partialBlocks:   make(map[chainhash.Hash]*partialBlock),
+		}
+		sm.peerStates[peer] = state
+
+		msg := &wire.MsgCmpctBlock{Header: wire.BlockHeader{
+			Version:   4,
+			PrevBlock: test.prevBlock,
+			Timestamp: genesis.Header.Timestamp.Add(time.Minute),
+			Bits:      test.bits,
+		}}
+		blockHash := msg.BlockHash()
+		sm.handleCmpctBlockMsg(&cmpctBlockMsg{cmpctBlock: msg, peer: peer})
+
+		if connected := peer.Connected(); connected == test.disconnect {
+			t.Errorf("%s: peer connected %v, want %v", test.name,
+				connected, !test.disconnect)
+		}
+		if _, ok := sm.requestedBlocks[blockHash]; ok {
+			t.Errorf("%s: block was requested", test.name)
+		}
+		if len(state.requestedBlocks) != 0 || len(state.partialBlocks) != 0 {
+			t.Errorf("%s: peer has %d requested and %d partial blocks, "+
+				"want none", test.name, len(state.requestedBlocks),
+				len(state.partialBlocks))
+		}
+
+		delete(sm.peerStates, peer)
+		peerTeardown()
+	}
+}
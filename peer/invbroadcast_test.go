@@ -0,0 +1,52 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"testing"
+	"time"
+)
+
+// TestPoissonDelay ensures the random announcement delays are never negative
+// and average out to the requested mean.
+func TestPoissonDelay(t *testing.T) {
+	const samples = 20000
+	mean := time.Second
+
+	var total time.Duration
+	for i := 0; i < samples; i++ {
+		delay := poissonDelay(mean)
+		if delay < 0 {
+			t.Fatalf("poissonDelay: got negative delay %v", delay)
+		}
+		total += delay
+	}
+
+	// The standard deviation of the average of the samples is about
+	// mean/sqrt(samples), so a 10% tolerance is far outside of it.
+	avg := total / samples
+	if avg < mean*9/10 || avg > mean*11/10 {
+		t.Fatalf("poissonDelay: got average %v, want about %v", avg, mean)
+	}
+}
+
+// TestNextInboundInvBroadcast ensures all inbound peers share the time of the
+// next inventory announcement and that it moves forward once reached.
+func TestNextInboundInvBroadcast(t *testing.T) {
+	now := time.Now()
+	next := nextInboundInvBroadcast(now)
+	if next.Before(now) {
+		t.Fatalf("nextInboundInvBroadcast: got %v before %v", next, now)
+	}
+	if got := nextInboundInvBroadcast(now); !got.Equal(next) {
+		t.Fatalf("nextInboundInvBroadcast: got %v, want shared %v", got,
+			next)
+	}
+
+	// Once the shared time is reached a new one is scheduled.
+	if got := nextInboundInvBroadcast(next); got.Before(next) {
+		t.Fatalf("nextInboundInvBroadcast: got %v before %v", got, next)
+	}
+}
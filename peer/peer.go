@@ -22,12 +22,13 @@ import (
 	"github.com/navcoin/navd/blockchain"
 	"github.com/navcoin/navd/chaincfg"
 	"github.com/navcoin/navd/chaincfg/chainhash"
+	"github.com/navcoin/navd/v2transport"
 	"github.com/navcoin/navd/wire"
 )
 
 const (
 	// MaxProtocolVersion is the max protocol version the peer supports.
-	MaxProtocolVersion = wire.FeeFilterVersion
+	MaxProtocolVersion = wire.WTxIDRelayVersion
 
 	// minAcceptableProtocolVersion is the lowest protocol version that a
 	// connected peer may support.
@@ -65,9 +66,15 @@ const (
 	// only checked on each stall tick interval.
 	stallResponseTimeout = 30 * time.Second
 
-	// trickleTimeout is the duration of the ticker which trickles down the
-	// inventory to a peer.
-	trickleTimeout = 10 * time.Second
+	// outboundInvBroadcastInterval is the average interval between the
+	// inventory announcements trickled to an outbound peer.
+	outboundInvBroadcastInterval = 2 * time.Second
+
+	// inboundInvBroadcastInterval is the average interval between the
+	// inventory announcements trickled to inbound peers.  It is longer than
+	// the outbound interval so an attacker that makes many inbound
+	// connections learns less about the origin of transactions.
+	inboundInvBroadcastInterval = 5 * time.Second
 )
 
 var (
@@ -87,6 +94,12 @@ var (
 	// connection detecting and disconnect logic since they intentionally
 	// do so for testing purposes.
 	allowSelfConns bool
+
+	// inboundInvBroadcast is the time of the next inventory announcement
+	// to inbound peers, which is shared by all of them.  It is protected by
+	// inboundInvBroadcastMtx.
+	inboundInvBroadcast    time.Time
+	inboundInvBroadcastMtx sync.Mutex
 )
 
 // MessageListeners defines callback function pointers to invoke with message
@@ -197,6 +210,33 @@ type MessageListeners struct {
 	// message.
 	OnSendHeaders func(p *Peer, msg *wire.MsgSendHeaders)
 
+	// OnSendCmpct is invoked when a peer receives a sendcmpct navcoin
+	// message.
+	OnSendCmpct func(p *Peer, msg *wire.MsgSendCmpct)
+
+	// OnCmpctBlock is invoked when a peer receives a cmpctblock navcoin
+	// message.
+	OnCmpctBlock func(p *Peer, msg *wire.MsgCmpctBlock)
+
+	// OnGetBlockTxn is invoked when a peer receives a getblocktxn navcoin
+	// message.
+	OnGetBlockTxn func(p *Peer, msg *wire.MsgGetBlockTxn)
+
+	// OnBlockTxn is invoked when a peer receives a blocktxn navcoin
+	// message.
+	OnBlockTxn func(p *Peer, msg *wire.MsgBlockTxn)
+
+	// OnSendAddrV2 is invoked when a peer receives a sendaddrv2 navcoin
+	// message.
+	OnSendAddrV2 func(p *Peer, msg *wire.MsgSendAddrV2)
+
+	// OnAddrV2 is invoked when a peer receives an addrv2 navcoin message.
+	OnAddrV2 func(p *Peer, msg *wire.MsgAddrV2)
+
+	// OnWTxIDRelay is invoked when a peer receives a wtxidrelay navcoin
+	// message.
+	OnWTxIDRelay func(p *Peer, msg *wire.MsgWTxIDRelay)
+
 	// OnRead is invoked when a peer receives a navcoin message.  It
 	// consists of the number of bytes read, the message, and whether or not
 	// an error in the read occurred.  Typically, callers will opt to use
@@ -264,6 +304,32 @@ type Config struct {
 	// not send inv messages for transactions.
 	DisableRelayTx bool
 
+	// CompactBlocks specifies whether or not the local peer supports
+	// compact block relay (BIP0152).  When set, support is announced to
+	// remote peers with a new enough protocol version once the handshake
+	// is complete, and the compact block version announced by the remote
+	// peer is tracked.
+	CompactBlocks bool
+
+	// AddrV2 specifies whether or not the local peer supports relaying
+	// addresses by addrv2 messages (BIP0155).  When set, remote peers with
+	// a new enough protocol version are requested to relay addresses by
+	// addrv2 messages during the handshake.
+	AddrV2 bool
+
+	// WTxIDRelay specifies whether or not the local peer supports relaying
+	// transactions by their witness hash (BIP0339).  When set, support is
+	// announced to remote peers with a new enough protocol version during
+	// the handshake, and transactions are relayed by witness hash once the
+	// remote peer announced support as well.
+	WTxIDRelay bool
+
+	// V2Transport specifies whether or not the encrypted v2 transport
+	// protocol (BIP0324) is used for the connection.  Outbound peers
+	// require the remote peer to support it, while inbound peers fall back
+	// to the v1 transport protocol when the remote peer starts it.
+	V2Transport bool
+
 	// Listeners houses callback functions to be invoked on receiving peer
 	// messages.
 	Listeners MessageListeners
@@ -371,6 +437,7 @@ type StatsSnap struct {
 	LastPingNonce  uint64
 	LastPingTime   time.Time
 	LastPingMicros int64
+	Transport      uint32
 }
 
 // HashFunc is a function which returns a block hash, height and error
@@ -422,6 +489,12 @@ type Peer struct {
 
 	conn net.Conn
 
+	// transport is the v2 transport used to read and write messages when
+	// the v2 transport protocol is enabled.  It is set during the protocol
+	// negotiation before any messages are read or written, so it is safe
+	// to read from concurrently afterwards.
+	transport *v2transport.Transport
+
 	// These fields are set at creation time and never modified, so they are
 	// safe to read from concurrently without a mutex.
 	addr    string
@@ -439,6 +512,12 @@ type Peer struct {
 	sendHeadersPreferred bool   // peer sent a sendheaders message
 	verAckReceived       bool
 	witnessEnabled       bool
+	cmpctBlockVersion    uint64 // compact block version used with the peer
+	cmpctHighBandwidth   bool   // peer wants compact blocks announced
+	sendAddrV2           bool   // peer sent a sendaddrv2 message
+	wtxidRelay           bool   // transactions are relayed by wtxid
+	transportVersion     uint32 // negotiated transport protocol version
+	v2TransportRefused   bool   // peer refused the v2 transport protocol
 
 	wireEncoding wire.MessageEncoding
 
@@ -512,6 +591,14 @@ func (p *Peer) AddKnownInventory(invVect *wire.InvVect) {
 	p.knownInventory.Add(invVect)
 }
 
+// HasKnownInventory returns whether the passed inventory is in the cache of
+// known inventory for the peer.
+//
+// This function is safe for concurrent access.
+func (p *Peer) HasKnownInventory(invVect *wire.InvVect) bool {
+	return p.knownInventory.Exists(invVect)
+}
+
 // StatsSnapshot returns a snapshot of the current peer flags and statistics.
 //
 // This function is safe for concurrent access.
@@ -524,6 +611,7 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 	userAgent := p.userAgent
 	services := p.services
 	protocolVersion := p.advertisedProtoVer
+	transportVersion := p.transportVersion
 	p.flagsMtx.Unlock()
 
 	// Get a copy of all relevant flags and stats.
@@ -545,6 +633,7 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 		LastPingNonce:  p.lastPingNonce,
 		LastPingMicros: p.lastPingMicros,
 		LastPingTime:   p.lastPingTime,
+		Transport:      transportVersion,
 	}
 
 	p.statsMtx.RUnlock()
@@ -800,6 +889,93 @@ func (p *Peer) IsWitnessEnabled() bool {
 	return witnessEnabled
 }
 
+// CompactBlockVersion returns the compact block version negotiated with the
+// peer, or 0 when compact block relay is not supported by both sides.
+//
+// This function is safe for concurrent access.
+func (p *Peer) CompactBlockVersion() uint64 {
+	p.flagsMtx.Lock()
+	version := p.cmpctBlockVersion
+	p.flagsMtx.Unlock()
+
+	return version
+}
+
+// WantsCompactBlocks returns if the peer wants new blocks to be announced by
+// directly sending cmpctblock messages (high-bandwidth mode) instead of
+// inventory vectors or headers.
+//
+// This function is safe for concurrent access.
+func (p *Peer) WantsCompactBlocks() bool {
+	p.flagsMtx.Lock()
+	wantsCmpct := p.cmpctBlockVersion != 0 && p.cmpctHighBandwidth
+	p.flagsMtx.Unlock()
+
+	return wantsCmpct
+}
+
+// WantsAddrV2 returns if the peer requested addresses to be relayed by addrv2
+// messages instead of addr messages.
+//
+// This function is safe for concurrent access.
+func (p *Peer) WantsAddrV2() bool {
+	p.flagsMtx.Lock()
+	sendAddrV2 := p.sendAddrV2
+	p.flagsMtx.Unlock()
+
+	return sendAddrV2
+}
+
+// WTxIDRelay returns whether transactions are announced and requested by their
+// witness hash (wtxid) with the peer, which is the case once both the local
+// and the remote peer announced support for it (BIP0339).
+//
+// This function is safe for concurrent access.
+func (p *Peer) WTxIDRelay() bool {
+	p.flagsMtx.Lock()
+	wtxidRelay := p.wtxidRelay
+	p.flagsMtx.Unlock()
+
+	return wtxidRelay
+}
+
+// TransportVersion returns the version of the transport protocol used for the
+// connection, which is either v2transport.ProtocolV1 or
+// v2transport.ProtocolV2.  It is zero until the transport protocol has been
+// negotiated.
+//
+// This function is safe for concurrent access.
+func (p *Peer) TransportVersion() uint32 {
+	p.flagsMtx.Lock()
+	transportVersion := p.transportVersion
+	p.flagsMtx.Unlock()
+
+	return transportVersion
+}
+
+// V2TransportRefused returns whether the remote peer refused the v2 transport
+// handshake the way peers which only support the v1 transport protocol do, in
+// which case the connection may be retried using the v1 transport protocol.
+//
+// This function is safe for concurrent access.
+func (p *Peer) V2TransportRefused() bool {
+	p.flagsMtx.Lock()
+	refused := p.v2TransportRefused
+	p.flagsMtx.Unlock()
+
+	return refused
+}
+
+// localCmpctBlockVersion returns the highest compact block version the local
+// peer supports.  Version 2 relays witness data, so it requires the local peer
+// to advertise segregated witness support.
+func (p *Peer) localCmpctBlockVersion() uint64 {
+	if p.cfg.Services&wire.SFNodeWitness == wire.SFNodeWitness {
+		return wire.CmpctBlockVersion2
+	}
+	return wire.CmpctBlockVersion1
+}
+
 // localVersionMsg creates a version message that can be used to send to the
 // remote peer.
 func (p *Peer) localVersionMsg() (*wire.MsgVersion, error) {
@@ -886,33 +1062,103 @@ func (p *Peer) localVersionMsg() (*wire.MsgVersion, error) {
 // are too many.  It returns the addresses that were actually sent and no
 // message will be sent if there are no entries in the provided addresses slice.
 //
+// An addrv2 message is sent instead when the peer requested it.  Otherwise,
+// the addresses which can only be relayed by addrv2 messages are skipped.
+//
 // This function is safe for concurrent access.
 func (p *Peer) PushAddrMsg(addresses []*wire.NetAddress) ([]*wire.NetAddress, error) {
-	addressCount := len(addresses)
+	wantsAddrV2 := p.WantsAddrV2()
+	addrList := make([]*wire.NetAddress, 0, len(addresses))
+	for _, na := range addresses {
+		if !wantsAddrV2 && na.RequiresAddrV2() {
+			continue
+		}
+		addrList = append(addrList, na)
+	}
+	addressCount := len(addrList)
 
 	// Nothing to send.
 	if addressCount == 0 {
 		return nil, nil
 	}
 
-	msg := wire.NewMsgAddr()
-	msg.AddrList = make([]*wire.NetAddress, addressCount)
-	copy(msg.AddrList, addresses)
-
 	// Randomize the addresses sent if there are more than the maximum allowed.
 	if addressCount > wire.MaxAddrPerMsg {
 		// Shuffle the address list.
 		for i := 0; i < wire.MaxAddrPerMsg; i++ {
 			j := i + rand.Intn(addressCount-i)
-			msg.AddrList[i], msg.AddrList[j] = msg.AddrList[j], msg.AddrList[i]
+			addrList[i], addrList[j] = addrList[j], addrList[i]
 		}
 
 		// Truncate it to the maximum size.
-		msg.AddrList = msg.AddrList[:wire.MaxAddrPerMsg]
+		addrList = addrList[:wire.MaxAddrPerMsg]
+	}
+
+	if wantsAddrV2 {
+		msg := wire.NewMsgAddrV2()
+		msg.AddrList = addrList
+		p.QueueMessage(msg, nil)
+		return addrList, nil
 	}
 
+	msg := wire.NewMsgAddr()
+	msg.AddrList = addrList
 	p.QueueMessage(msg, nil)
-	return msg.AddrList, nil
+	return addrList, nil
+}
+
+// PushSendCmpctMsg sends a sendcmpct message using the negotiated compact
+// block version to request the remote peer to announce new blocks either by
+// directly sending cmpctblock messages (high-bandwidth mode) or by inventory
+// vectors or headers (low-bandwidth mode).  Nothing is sent when compact block
+// relay has not been negotiated with the peer.
+//
+// This function is safe for concurrent access.
+func (p *Peer) PushSendCmpctMsg(highBandwidth bool) {
+	version := p.CompactBlockVersion()
+	if version == 0 {
+		return
+	}
+	p.QueueMessage(wire.NewMsgSendCmpct(highBandwidth, version), nil)
+}
+
+// announceCompactBlocks announces support for compact block relay to the
+// remote peer when both sides support it.  All supported compact block
+// versions are announced in order of preference and none of them requests
+// high-bandwidth mode.
+func (p *Peer) announceCompactBlocks() {
+	if !p.cfg.CompactBlocks ||
+		p.ProtocolVersion() < wire.CompactBlocksVersion {
+
+		return
+	}
+
+	for version := p.localCmpctBlockVersion(); version > 0; version-- {
+		p.QueueMessage(wire.NewMsgSendCmpct(false, version), nil)
+	}
+}
+
+// handleSendCmpctMsg is invoked when a peer receives a sendcmpct navcoin
+// message.  The highest compact block version supported by both peers is used
+// for compact block relay, and the remote peer's choice of announcement mode
+// is only updated by messages using that version.  Version 2 requires both
+// peers to support segregated witness.
+func (p *Peer) handleSendCmpctMsg(msg *wire.MsgSendCmpct) {
+	if !p.cfg.CompactBlocks || msg.Version == 0 ||
+		msg.Version > p.localCmpctBlockVersion() {
+
+		return
+	}
+
+	p.flagsMtx.Lock()
+	defer p.flagsMtx.Unlock()
+	if msg.Version == wire.CmpctBlockVersion2 && !p.witnessEnabled {
+		return
+	}
+	if msg.Version >= p.cmpctBlockVersion {
+		p.cmpctBlockVersion = msg.Version
+		p.cmpctHighBandwidth = msg.AnnounceCmpctBlock
+	}
 }
 
 // PushGetBlocksMsg sends a getblocks message for the provided block locator
@@ -1143,8 +1389,17 @@ func (p *Peer) handlePongMsg(msg *wire.MsgPong) {
 
 // readMessage reads the next navcoin message from the peer with logging.
 func (p *Peer) readMessage(encoding wire.MessageEncoding) (wire.Message, []byte, error) {
-	n, msg, buf, err := wire.ReadMessageWithEncodingN(p.conn,
-		p.ProtocolVersion(), p.cfg.ChainParams.Net, encoding)
+	var n int
+	var msg wire.Message
+	var buf []byte
+	var err error
+	if p.transport != nil {
+		n, msg, buf, err = p.transport.ReadMessage(p.ProtocolVersion(),
+			encoding)
+	} else {
+		n, msg, buf, err = wire.ReadMessageWithEncodingN(p.conn,
+			p.ProtocolVersion(), p.cfg.ChainParams.Net, encoding)
+	}
 	atomic.AddUint64(&p.bytesReceived, uint64(n))
 	if p.cfg.Listeners.OnRead != nil {
 		p.cfg.Listeners.OnRead(p, n, msg, err)
@@ -1206,8 +1461,14 @@ func (p *Peer) writeMessage(msg wire.Message, enc wire.MessageEncoding) error {
 	}))
 
 	// Write the message to the peer.
-	n, err := wire.WriteMessageWithEncodingN(p.conn, msg,
-		p.ProtocolVersion(), p.cfg.ChainParams.Net, enc)
+	var n int
+	var err error
+	if p.transport != nil {
+		n, err = p.transport.WriteMessage(msg, p.ProtocolVersion(), enc)
+	} else {
+		n, err = wire.WriteMessageWithEncodingN(p.conn, msg,
+			p.ProtocolVersion(), p.cfg.ChainParams.Net, enc)
+	}
 	atomic.AddUint64(&p.bytesSent, uint64(n))
 	if p.cfg.Listeners.OnWrite != nil {
 		p.cfg.Listeners.OnWrite(p, n, msg, err)
@@ -1286,12 +1547,18 @@ func (p *Peer) maybeAddDeadline(pendingResponses map[string]time.Time, msgCmd st
 		pendingResponses[wire.CmdInv] = deadline
 
 	case wire.CmdGetData:
-		// Expects a block, merkleblock, tx, or notfound message.
+		// Expects a block, cmpctblock, merkleblock, tx, or notfound
+		// message.
 		pendingResponses[wire.CmdBlock] = deadline
+		pendingResponses[wire.CmdCmpctBlock] = deadline
 		pendingResponses[wire.CmdMerkleBlock] = deadline
 		pendingResponses[wire.CmdTx] = deadline
 		pendingResponses[wire.CmdNotFound] = deadline
 
+	case wire.CmdGetBlockTxn:
+		// Expects a blocktxn message.
+		pendingResponses[wire.CmdBlockTxn] = deadline
+
 	case wire.CmdGetHeaders:
 		// Expects a headers message.  Use a longer deadline since it
 		// can take a while for the remote peer to load all of the
@@ -1345,12 +1612,15 @@ out:
 				switch msgCmd := msg.message.Command(); msgCmd {
 				case wire.CmdBlock:
 					fallthrough
+				case wire.CmdCmpctBlock:
+					fallthrough
 				case wire.CmdMerkleBlock:
 					fallthrough
 				case wire.CmdTx:
 					fallthrough
 				case wire.CmdNotFound:
 					delete(pendingResponses, wire.CmdBlock)
+					delete(pendingResponses, wire.CmdCmpctBlock)
 					delete(pendingResponses, wire.CmdMerkleBlock)
 					delete(pendingResponses, wire.CmdTx)
 					delete(pendingResponses, wire.CmdNotFound)
@@ -1522,6 +1792,7 @@ out:
 			p.flagsMtx.Lock()
 			p.verAckReceived = true
 			p.flagsMtx.Unlock()
+			p.announceCompactBlocks()
 			if p.cfg.Listeners.OnVerAck != nil {
 				p.cfg.Listeners.OnVerAck(p, msg)
 			}
@@ -1667,6 +1938,67 @@ out:
 				p.cfg.Listeners.OnSendHeaders(p, msg)
 			}
 
+		case *wire.MsgSendCmpct:
+			p.handleSendCmpctMsg(msg)
+
+			if p.cfg.Listeners.OnSendCmpct != nil {
+				p.cfg.Listeners.OnSendCmpct(p, msg)
+			}
+
+		case *wire.MsgCmpctBlock:
+			if p.cfg.Listeners.OnCmpctBlock != nil {
+				p.cfg.Listeners.OnCmpctBlock(p, msg)
+			}
+
+		case *wire.MsgGetBlockTxn:
+			if p.cfg.Listeners.OnGetBlockTxn != nil {
+				p.cfg.Listeners.OnGetBlockTxn(p, msg)
+			}
+
+		case *wire.MsgBlockTxn:
+			if p.cfg.Listeners.OnBlockTxn != nil {
+				p.cfg.Listeners.OnBlockTxn(p, msg)
+			}
+
+		case *wire.MsgSendAddrV2:
+			// The request to relay addresses by addrv2 messages must
+			// be made before the handshake is complete.
+			if p.verAckReceived {
+				log.Infof("Received 'sendaddrv2' after 'verack' "+
+					"from peer %v -- disconnecting", p)
+				break out
+			}
+			p.flagsMtx.Lock()
+			p.sendAddrV2 = true
+			p.flagsMtx.Unlock()
+
+			if p.cfg.Listeners.OnSendAddrV2 != nil {
+				p.cfg.Listeners.OnSendAddrV2(p, msg)
+			}
+
+		case *wire.MsgAddrV2:
+			if p.cfg.Listeners.OnAddrV2 != nil {
+				p.cfg.Listeners.OnAddrV2(p, msg)
+			}
+
+		case *wire.MsgWTxIDRelay:
+			// Support for relaying transactions by wtxid must be
+			// announced before the handshake is complete.
+			if p.verAckReceived {
+				log.Infof("Received 'wtxidrelay' after 'verack' "+
+					"from peer %v -- disconnecting", p)
+				break out
+			}
+			if p.cfg.WTxIDRelay {
+				p.flagsMtx.Lock()
+				p.wtxidRelay = true
+				p.flagsMtx.Unlock()
+			}
+
+			if p.cfg.Listeners.OnWTxIDRelay != nil {
+				p.cfg.Listeners.OnWTxIDRelay(p, msg)
+			}
+
 		default:
 			log.Debugf("Received unhandled message of type %v "+
 				"from %v", rmsg.Command(), p)
@@ -1687,6 +2019,39 @@ out:
 	log.Tracef("Peer input handler done for %s", p)
 }
 
+// poissonDelay returns a random delay following an exponential distribution
+// with the provided mean, so the delays model the intervals between the events
+// of a Poisson process.
+func poissonDelay(mean time.Duration) time.Duration {
+	return time.Duration(rand.ExpFloat64() * float64(mean))
+}
+
+// nextInboundInvBroadcast returns the time of the next inventory announcement
+// to inbound peers.  The time is shared by all inbound peers so connecting
+// several times does not let an attacker observe more announcements than a
+// single connection would.
+func nextInboundInvBroadcast(now time.Time) time.Time {
+	inboundInvBroadcastMtx.Lock()
+	defer inboundInvBroadcastMtx.Unlock()
+
+	if !now.Before(inboundInvBroadcast) {
+		inboundInvBroadcast = now.Add(poissonDelay(
+			inboundInvBroadcastInterval))
+	}
+	return inboundInvBroadcast
+}
+
+// nextInvBroadcastDelay returns how long to wait before trickling the queued
+// inventory to the peer.  Outbound peers have their own timer, while inbound
+// peers share a timer with a longer average interval.
+func (p *Peer) nextInvBroadcastDelay() time.Duration {
+	if p.inbound {
+		now := time.Now()
+		return nextInboundInvBroadcast(now).Sub(now)
+	}
+	return poissonDelay(outboundInvBroadcastInterval)
+}
+
 // queueHandler handles the queuing of outgoing data for the peer. This runs as
 // a muxer for various sources of input so we can ensure that server and peer
 // handlers will not block on us sending a message.  That data is then passed on
@@ -1694,8 +2059,8 @@ out:
 func (p *Peer) queueHandler() {
 	pendingMsgs := list.New()
 	invSendQueue := list.New()
-	trickleTicker := time.NewTicker(trickleTimeout)
-	defer trickleTicker.Stop()
+	trickleTimer := time.NewTimer(p.nextInvBroadcastDelay())
+	defer trickleTimer.Stop()
 
 	// We keep the waiting flag so that we know if we have a message queued
 	// to the outHandler or not.  We could use the presence of a head of
@@ -1756,7 +2121,9 @@ out:
 				}
 			}
 
-		case <-trickleTicker.C:
+		case <-trickleTimer.C:
+			trickleTimer.Reset(p.nextInvBroadcastDelay())
+
 			// Don't send anything if we're disconnecting or there
 			// is no queued inventory.
 			// version is known if send queue has any entries.
@@ -2056,6 +2423,10 @@ func (p *Peer) start() error {
 
 	negotiateErr := make(chan error)
 	go func() {
+		if err := p.negotiateTransport(); err != nil {
+			negotiateErr <- err
+			return
+		}
 		if p.inbound {
 			negotiateErr <- p.negotiateInboundProtocol()
 		} else {
@@ -2082,6 +2453,18 @@ func (p *Peer) start() error {
 	go p.outHandler()
 	go p.pingHandler()
 
+	// Request addresses to be relayed by addrv2 messages when supported,
+	// which must be done before sending our verack message.
+	if p.cfg.AddrV2 && p.ProtocolVersion() >= wire.AddrV2Version {
+		p.QueueMessage(wire.NewMsgSendAddrV2(), nil)
+	}
+
+	// Announce support for relaying transactions by wtxid when supported,
+	// which must also be done before sending our verack message.
+	if p.cfg.WTxIDRelay && p.ProtocolVersion() >= wire.WTxIDRelayVersion {
+		p.QueueMessage(wire.NewMsgWTxIDRelay(), nil)
+	}
+
 	// Send our verack message now that the IO processing machinery has started.
 	p.QueueMessage(wire.NewMsgVerAck(), nil)
 	return nil
@@ -2135,6 +2518,36 @@ func (p *Peer) writeLocalVersionMsg() error {
 	return p.writeMessage(localVerMsg, wire.LatestEncoding)
 }
 
+// negotiateTransport sets up the transport protocol used for the connection.
+// The encrypted v2 transport protocol is only used when enabled by the config.
+func (p *Peer) negotiateTransport() error {
+	transportVersion := v2transport.ProtocolV1
+	if p.cfg.V2Transport {
+		var t *v2transport.Transport
+		var err error
+		if p.inbound {
+			t, err = v2transport.Respond(p.conn, p.cfg.ChainParams.Net)
+		} else {
+			t, err = v2transport.Initiate(p.conn, p.cfg.ChainParams.Net)
+		}
+		if err == v2transport.ErrV1Peer {
+			p.flagsMtx.Lock()
+			p.v2TransportRefused = true
+			p.flagsMtx.Unlock()
+		}
+		if err != nil {
+			return fmt.Errorf("v2 transport handshake failed: %v", err)
+		}
+		p.transport = t
+		transportVersion = t.Version()
+	}
+
+	p.flagsMtx.Lock()
+	p.transportVersion = transportVersion
+	p.flagsMtx.Unlock()
+	return nil
+}
+
 // negotiateInboundProtocol waits to receive a version message from the peer
 // then sends our version message. If the events do not occur in that order then
 // it returns an error.
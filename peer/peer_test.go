@@ -16,6 +16,7 @@ import (
 	"github.com/navcoin/navd/chaincfg"
 	"github.com/navcoin/navd/chaincfg/chainhash"
 	"github.com/navcoin/navd/peer"
+	"github.com/navcoin/navd/v2transport"
 	"github.com/navcoin/navd/wire"
 )
 
@@ -444,6 +445,21 @@ func TestPeerListeners(t *testing.T) {
 			OnSendHeaders: func(p *peer.Peer, msg *wire.MsgSendHeaders) {
 				ok <- msg
 			},
+			OnSendCmpct: func(p *peer.Peer, msg *wire.MsgSendCmpct) {
+				ok <- msg
+			},
+			OnCmpctBlock: func(p *peer.Peer, msg *wire.MsgCmpctBlock) {
+				ok <- msg
+			},
+			OnGetBlockTxn: func(p *peer.Peer, msg *wire.MsgGetBlockTxn) {
+				ok <- msg
+			},
+			OnBlockTxn: func(p *peer.Peer, msg *wire.MsgBlockTxn) {
+				ok <- msg
+			},
+			OnAddrV2: func(p *peer.Peer, msg *wire.MsgAddrV2) {
+				ok <- msg
+			},
 		},
 		UserAgentName:     "peer",
 		UserAgentVersion:  "1.0",
@@ -598,6 +614,28 @@ func TestPeerListeners(t *testing.T) {
 			"OnSendHeaders",
 			wire.NewMsgSendHeaders(),
 		},
+		{
+			"OnSendCmpct",
+			wire.NewMsgSendCmpct(true, wire.CmpctBlockVersion1),
+		},
+		{
+			"OnCmpctBlock",
+			wire.NewMsgCmpctBlock(wire.NewMsgBlock(wire.NewBlockHeader(1,
+				&chainhash.Hash{}, &chainhash.Hash{}, 1, 1)),
+				wire.CmpctBlockVersion1, 1),
+		},
+		{
+			"OnGetBlockTxn",
+			wire.NewMsgGetBlockTxn(&chainhash.Hash{}, []uint32{1}),
+		},
+		{
+			"OnBlockTxn",
+			wire.NewMsgBlockTxn(&chainhash.Hash{}),
+		},
+		{
+			"OnAddrV2",
+			wire.NewMsgAddrV2(),
+		},
 	}
 	t.Logf("Running %d tests", len(tests))
 	for _, test := range tests {
@@ -614,6 +652,310 @@ func TestPeerListeners(t *testing.T) {
 	outPeer.Disconnect()
 }
 
+// TestCompactBlockNegotiation ensures peers which support compact block relay
+// negotiate the highest version supported by both sides and track whether the
+// remote peer requested high-bandwidth mode.
+func TestCompactBlockNegotiation(t *testing.T) {
+	sendCmpct := make(chan *wire.MsgSendCmpct, 10)
+	inCfg := &peer.Config{
+		Listeners: peer.MessageListeners{
+			OnSendCmpct: func(p *peer.Peer, msg *wire.MsgSendCmpct) {
+				sendCmpct <- msg
+			},
+		},
+		UserAgentName:    "peer",
+		UserAgentVersion: "1.0",
+		ChainParams:      &chaincfg.MainNetParams,
+		Services:         wire.SFNodeNetwork | wire.SFNodeWitness,
+		CompactBlocks:    true,
+	}
+	outSendCmpct := make(chan struct{}, 10)
+	outCfg := *inCfg
+	outCfg.Listeners = peer.MessageListeners{
+		OnSendCmpct: func(p *peer.Peer, msg *wire.MsgSendCmpct) {
+			outSendCmpct <- struct{}{}
+		},
+	}
+
+	inConn, outConn := pipe(
+		&conn{raddr: "10.0.0.1:8333"},
+		&conn{raddr: "10.0.0.2:8333"},
+	)
+	inPeer := peer.NewInboundPeer(inCfg)
+	inPeer.AssociateConnection(inConn)
+	outPeer, err := peer.NewOutboundPeer(&outCfg, "10.0.0.1:8333")
+	if err != nil {
+		t.Fatalf("NewOutboundPeer: unexpected err %v", err)
+	}
+	outPeer.AssociateConnection(outConn)
+	defer inPeer.Disconnect()
+	defer outPeer.Disconnect()
+
+	// waitSendCmpct waits for the inbound peer to receive a sendcmpct
+	// message with the passed details.
+	waitSendCmpct := func(announce bool, version uint64) {
+		t.Helper()
+		select {
+		case msg := <-sendCmpct:
+			if msg.AnnounceCmpctBlock != announce ||
+				msg.Version != version {
+
+				t.Fatalf("unexpected sendcmpct message %+v", msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for sendcmpct message")
+		}
+	}
+
+	// Both supported versions are announced in order of preference in
+	// low-bandwidth mode once the handshake is complete.
+	waitSendCmpct(false, wire.CmpctBlockVersion2)
+	waitSendCmpct(false, wire.CmpctBlockVersion1)
+	if version := inPeer.CompactBlockVersion(); version != wire.CmpctBlockVersion2 {
+		t.Fatalf("negotiated compact block version %d, want %d",
+			version, wire.CmpctBlockVersion2)
+	}
+	if inPeer.WantsCompactBlocks() {
+		t.Fatal("peer wants compact blocks before requesting them")
+	}
+
+	// Wait for the outbound peer to negotiate the version as well.
+	for i := 0; i < 2; i++ {
+		select {
+		case <-outSendCmpct:
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for outbound sendcmpct message")
+		}
+	}
+
+	// Requesting high-bandwidth mode with the negotiated version is
+	// tracked, while requests for other versions are ignored.
+	outPeer.QueueMessage(wire.NewMsgSendCmpct(true,
+		wire.CmpctBlockVersion1), nil)
+	waitSendCmpct(true, wire.CmpctBlockVersion1)
+	if inPeer.WantsCompactBlocks() {
+		t.Fatal("peer wants compact blocks for an unused version")
+	}
+	outPeer.PushSendCmpctMsg(true)
+	waitSendCmpct(true, wire.CmpctBlockVersion2)
+	if !inPeer.WantsCompactBlocks() {
+		t.Fatal("peer does not want compact blocks after requesting " +
+			"them")
+	}
+}
+
+// TestAddrV2Negotiation ensures peers which support addrv2 messages request
+// them during the handshake and relay addresses which require them only to
+// peers which requested them.
+func TestAddrV2Negotiation(t *testing.T) {
+	inSendAddrV2 := make(chan struct{}, 1)
+	addrV2 := make(chan *wire.MsgAddrV2, 1)
+	inCfg := &peer.Config{
+		Listeners: peer.MessageListeners{
+			OnSendAddrV2: func(p *peer.Peer, msg *wire.MsgSendAddrV2) {
+				inSendAddrV2 <- struct{}{}
+			},
+			OnAddrV2: func(p *peer.Peer, msg *wire.MsgAddrV2) {
+				addrV2 <- msg
+			},
+		},
+		UserAgentName:    "peer",
+		UserAgentVersion: "1.0",
+		ChainParams:      &chaincfg.MainNetParams,
+		Services:         wire.SFNodeNetwork,
+		AddrV2:           true,
+	}
+	outSendAddrV2 := make(chan struct{}, 1)
+	outCfg := *inCfg
+	outCfg.Listeners = peer.MessageListeners{
+		OnSendAddrV2: func(p *peer.Peer, msg *wire.MsgSendAddrV2) {
+			outSendAddrV2 <- struct{}{}
+		},
+	}
+
+	inConn, outConn := pipe(
+		&conn{raddr: "10.0.0.1:8333"},
+		&conn{raddr: "10.0.0.2:8333"},
+	)
+	inPeer := peer.NewInboundPeer(inCfg)
+	inPeer.AssociateConnection(inConn)
+	outPeer, err := peer.NewOutboundPeer(&outCfg, "10.0.0.1:8333")
+	if err != nil {
+		t.Fatalf("NewOutboundPeer: unexpected err %v", err)
+	}
+	outPeer.AssociateConnection(outConn)
+	defer inPeer.Disconnect()
+	defer outPeer.Disconnect()
+
+	for _, ch := range []chan struct{}{inSendAddrV2, outSendAddrV2} {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for sendaddrv2 message")
+		}
+	}
+	if !inPeer.WantsAddrV2() || !outPeer.WantsAddrV2() {
+		t.Fatal("peer does not want addrv2 after requesting it")
+	}
+
+	torV3 := make([]byte, 32)
+	torV3[0] = 0x01
+	na, err := wire.NewNetAddressNetwork(wire.NetTorV3, torV3, 8333,
+		wire.SFNodeNetwork)
+	if err != nil {
+		t.Fatalf("NewNetAddressNetwork: unexpected err %v", err)
+	}
+	sent, err := outPeer.PushAddrMsg([]*wire.NetAddress{na})
+	if err != nil {
+		t.Fatalf("PushAddrMsg: unexpected err %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("PushAddrMsg: sent %d addresses, want 1", len(sent))
+	}
+	select {
+	case msg := <-addrV2:
+		if len(msg.AddrList) != 1 ||
+			msg.AddrList[0].NetworkID() != wire.NetTorV3 {
+
+			t.Fatalf("unexpected addrv2 message %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for addrv2 message")
+	}
+}
+
+// TestWTxIDRelayNegotiation ensures transactions are relayed by wtxid only when
+// both peers announce support for it during the handshake.
+func TestWTxIDRelayNegotiation(t *testing.T) {
+	tests := []struct {
+		name       string
+		inWTxID    bool
+		outWTxID   bool
+		wtxidRelay bool
+	}{
+		{"both wtxidrelay", true, true, true},
+		{"inbound wtxidrelay", true, false, false},
+		{"outbound wtxidrelay", false, true, false},
+	}
+
+	for _, test := range tests {
+		verack := make(chan struct{}, 2)
+		inCfg := &peer.Config{
+			Listeners: peer.MessageListeners{
+				OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+					verack <- struct{}{}
+				},
+			},
+			UserAgentName:    "peer",
+			UserAgentVersion: "1.0",
+			ChainParams:      &chaincfg.MainNetParams,
+			Services:         wire.SFNodeNetwork,
+			WTxIDRelay:       test.inWTxID,
+		}
+		outCfg := *inCfg
+		outCfg.WTxIDRelay = test.outWTxID
+
+		inConn, outConn := pipe(
+			&conn{raddr: "10.0.0.1:8333"},
+			&conn{raddr: "10.0.0.2:8333"},
+		)
+		inPeer := peer.NewInboundPeer(inCfg)
+		inPeer.AssociateConnection(inConn)
+		outPeer, err := peer.NewOutboundPeer(&outCfg, "10.0.0.1:8333")
+		if err != nil {
+			t.Fatalf("%s: NewOutboundPeer: unexpected err %v",
+				test.name, err)
+		}
+		outPeer.AssociateConnection(outConn)
+
+		for i := 0; i < 2; i++ {
+			select {
+			case <-verack:
+			case <-time.After(time.Second):
+				t.Fatalf("%s: timeout waiting for verack", test.name)
+			}
+		}
+		if inPeer.WTxIDRelay() != test.wtxidRelay ||
+			outPeer.WTxIDRelay() != test.wtxidRelay {
+
+			t.Errorf("%s: WTxIDRelay: got inbound %v outbound %v, "+
+				"want %v", test.name, inPeer.WTxIDRelay(),
+				outPeer.WTxIDRelay(), test.wtxidRelay)
+		}
+
+		inPeer.Disconnect()
+		outPeer.Disconnect()
+	}
+}
+
+// TestV2Transport ensures peers negotiate the encrypted v2 transport protocol
+// when both support it and that inbound peers fall back to the v1 transport
+// protocol for remote peers which don't.
+func TestV2Transport(t *testing.T) {
+	tests := []struct {
+		name      string
+		inV2      bool
+		outV2     bool
+		transport uint32
+	}{
+		{"both v2", true, true, v2transport.ProtocolV2},
+		{"inbound v2", true, false, v2transport.ProtocolV1},
+		{"neither v2", false, false, v2transport.ProtocolV1},
+	}
+
+	for _, test := range tests {
+		verack := make(chan struct{}, 2)
+		inCfg := &peer.Config{
+			Listeners: peer.MessageListeners{
+				OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+					verack <- struct{}{}
+				},
+			},
+			UserAgentName:    "peer",
+			UserAgentVersion: "1.0",
+			ChainParams:      &chaincfg.MainNetParams,
+			Services:         wire.SFNodeNetwork,
+			V2Transport:      test.inV2,
+		}
+		outCfg := *inCfg
+		outCfg.V2Transport = test.outV2
+
+		inConn, outConn := pipe(
+			&conn{raddr: "10.0.0.1:8333"},
+			&conn{raddr: "10.0.0.2:8333"},
+		)
+		inPeer := peer.NewInboundPeer(inCfg)
+		inPeer.AssociateConnection(inConn)
+		outPeer, err := peer.NewOutboundPeer(&outCfg, "10.0.0.1:8333")
+		if err != nil {
+			t.Fatalf("%s: NewOutboundPeer: unexpected err %v",
+				test.name, err)
+		}
+		outPeer.AssociateConnection(outConn)
+
+		for i := 0; i < 2; i++ {
+			select {
+			case <-verack:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%s: timeout waiting for verack", test.name)
+			}
+		}
+		for _, p := range []*peer.Peer{inPeer, outPeer} {
+			if v := p.TransportVersion(); v != test.transport {
+				t.Errorf("%s: transport version %d, want %d",
+					test.name, v, test.transport)
+			}
+			if v := p.StatsSnapshot().Transport; v != test.transport {
+				t.Errorf("%s: stats transport version %d, want "+
+					"%d", test.name, v, test.transport)
+			}
+		}
+
+		inPeer.Disconnect()
+		outPeer.Disconnect()
+	}
+}
+
 // TestOutboundPeer tests that the outbound peer works as expected.
 func TestOutboundPeer(t *testing.T) {
 
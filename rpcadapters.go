@@ -5,10 +5,14 @@
 package main
 
 import (
+	"errors"
+	"net"
 	"sync/atomic"
+	"time"
 
 	"github.com/navcoin/navd/blockchain"
 	"github.com/navcoin/navd/chaincfg/chainhash"
+	"github.com/navcoin/navd/connmgr"
 	"github.com/navcoin/navd/mempool"
 	"github.com/navcoin/navd/netsync"
 	"github.com/navcoin/navd/peer"
@@ -61,6 +65,64 @@ func (p *rpcPeer) FeeFilter() int64 {
 	return atomic.LoadInt64(&(*serverPeer)(p).feeFilter)
 }
 
+// IsWhitelisted returns whether or not the peer is whitelisted.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) IsWhitelisted() bool {
+	return (*serverPeer)(p).whitelisted()
+}
+
+// SetWhitelisted toggles whether the peer is whitelisted.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) SetWhitelisted(whitelisted bool) {
+	(*serverPeer)(p).setWhitelisted(whitelisted)
+}
+
+// IsNoBan returns whether or not the peer is exempt from being banned.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) IsNoBan() bool {
+	return (*serverPeer)(p).noBan()
+}
+
+// SetNoBan toggles whether the peer is exempt from being banned.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) SetNoBan(noBan bool) {
+	(*serverPeer)(p).setNoBan(noBan)
+}
+
+// AddBanScore increases the ban score of the peer by the provided persistent
+// and transient values for the provided reason and returns the resulting ban
+// score.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) AddBanScore(persistent, transient uint32, reason string) uint32 {
+	sp := (*serverPeer)(p)
+	sp.addBanScore(persistent, transient, reason)
+	return sp.banScore.Int()
+}
+
+// MappedAS returns the number of the autonomous system which announces the
+// address of the peer according to the asmap in use, or zero when it is
+// unknown.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) MappedAS() uint32 {
+	sp := (*serverPeer)(p)
+	if sp.NA() == nil {
+		return 0
+	}
+	return sp.server.addrManager.ASN(sp.NA())
+}
+
 // rpcConnManager provides a connection manager for use with the RPC server and
 // implements the rpcserverConnManager interface.
 type rpcConnManager struct {
@@ -218,9 +280,71 @@ func (cm *rpcConnManager) AddRebroadcastInventory(iv *wire.InvVect, data interfa
 }
 
 // RelayTransactions generates and relays inventory vectors for all of the
-// passed transactions to all connected peers.
+// passed transactions to all connected peers, or only to the peers reached over
+// Tor when the --torbroadcast option is set.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
 func (cm *rpcConnManager) RelayTransactions(txns []*mempool.TxDesc) {
-	cm.server.relayTransactions(txns)
+	cm.server.relayLocalTransactions(txns)
+}
+
+// BanSubnet bans the provided subnet until the provided time and disconnects
+// all connected peers within it.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) BanSubnet(subnet *net.IPNet, until time.Time) {
+	cm.server.banList.Ban(subnet, until)
+
+	// Disconnect the peers within the subnet one by one until none are
+	// left.
+	cmp := func(sp *serverPeer) bool {
+		host, _, err := net.SplitHostPort(sp.Addr())
+		if err != nil {
+			return false
+		}
+		ip := net.ParseIP(host)
+		return ip != nil && subnet.Contains(ip)
+	}
+	for {
+		replyChan := make(chan error)
+		cm.server.query <- disconnectNodeMsg{
+			cmp:   cmp,
+			reply: replyChan,
+		}
+		if err := <-replyChan; err != nil {
+			return
+		}
+	}
+}
+
+// UnbanSubnet removes the ban of the provided subnet.  Attempting to remove a
+// subnet that is not banned will return an error.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) UnbanSubnet(subnet *net.IPNet) error {
+	if !cm.server.banList.Unban(subnet) {
+		return errors.New("subnet not banned")
+	}
+	return nil
+}
+
+// BannedSubnets returns all subnets which are currently banned.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) BannedSubnets() []connmgr.BanEntry {
+	return cm.server.banList.Entries()
+}
+
+// ClearBanned removes all bans.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) ClearBanned() {
+	cm.server.banList.Clear()
 }
 
 // rpcSyncMgr provides a block manager for use with the RPC server and
@@ -235,6 +235,131 @@ func (c *Client) GetCurrentNet() (wire.NavCoinNet, error) {
 	return c.GetCurrentNetAsync().Receive()
 }
 
+// FutureGetMiningStatsResult is a future promise to deliver the result of a
+// GetMiningStatsAsync RPC invocation (or an applicable error).
+type FutureGetMiningStatsResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// statistics about the block templates built by the server and the blocks it
+// generated.
+func (r FutureGetMiningStatsResult) Receive() (*btcjson.GetMiningStatsResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a getminingstats result object.
+	var stats btcjson.GetMiningStatsResult
+	err = json.Unmarshal(res, &stats)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// GetMiningStatsAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GetMiningStats for the blocking version and more details.
+//
+// NOTE: This is a navd extension.
+func (c *Client) GetMiningStatsAsync() FutureGetMiningStatsResult {
+	cmd := btcjson.NewGetMiningStatsCmd()
+	return c.sendCmd(cmd)
+}
+
+// GetMiningStats returns statistics about the block templates built by the
+// server and a rolling history of the blocks it generated.
+//
+// NOTE: This is a navd extension.
+func (c *Client) GetMiningStats() (*btcjson.GetMiningStatsResult, error) {
+	return c.GetMiningStatsAsync().Receive()
+}
+
+// FutureSetPeerPermissionResult is a future promise to deliver the result of a
+// SetPeerPermissionAsync RPC invocation (or an applicable error).
+//
+// NOTE: This is a navd extension.
+type FutureSetPeerPermissionResult chan *response
+
+// Receive waits for the response promised by the future and returns an error if
+// any occurred when changing the permission of the peer.
+//
+// NOTE: This is a navd extension.
+func (r FutureSetPeerPermissionResult) Receive() error {
+	_, err := receiveFuture(r)
+	return err
+}
+
+// SetPeerPermissionAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See SetPeerPermission for the blocking version and more details.
+//
+// NOTE: This is a navd extension.
+func (c *Client) SetPeerPermissionAsync(nodeID int32, permission btcjson.PeerPermission, enable bool) FutureSetPeerPermissionResult {
+	cmd := btcjson.NewSetPeerPermissionCmd(nodeID, permission, &enable)
+	return c.sendCmd(cmd)
+}
+
+// SetPeerPermission grants or revokes the passed permission of the connected
+// peer with the passed node id.
+//
+// NOTE: This is a navd extension.
+func (c *Client) SetPeerPermission(nodeID int32, permission btcjson.PeerPermission, enable bool) error {
+	return c.SetPeerPermissionAsync(nodeID, permission, enable).Receive()
+}
+
+// FutureAddPeerBanScoreResult is a future promise to deliver the result of an
+// AddPeerBanScoreAsync RPC invocation (or an applicable error).
+//
+// NOTE: This is a navd extension.
+type FutureAddPeerBanScoreResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// resulting ban score of the peer.
+//
+// NOTE: This is a navd extension.
+func (r FutureAddPeerBanScoreResult) Receive() (uint32, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return 0, err
+	}
+
+	// Unmarshal result as a numeric.
+	var score uint32
+	err = json.Unmarshal(res, &score)
+	if err != nil {
+		return 0, err
+	}
+
+	return score, nil
+}
+
+// AddPeerBanScoreAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See AddPeerBanScore for the blocking version and more details.
+//
+// NOTE: This is a navd extension.
+func (c *Client) AddPeerBanScoreAsync(nodeID int32, score uint32, reason string) FutureAddPeerBanScoreResult {
+	cmd := btcjson.NewAddPeerBanScoreCmd(nodeID, score, reason)
+	return c.sendCmd(cmd)
+}
+
+// AddPeerBanScore increases the ban score of the connected peer with the passed
+// node id for the passed reason and returns the resulting ban score.  The peer
+// is banned and disconnected once its ban score exceeds the ban threshold.
+//
+// NOTE: This is a navd extension.
+func (c *Client) AddPeerBanScore(nodeID int32, score uint32, reason string) (uint32, error) {
+	return c.AddPeerBanScoreAsync(nodeID, score, reason).Receive()
+}
+
 // FutureGetHeadersResult is a future promise to deliver the result of a
 // getheaders RPC invocation (or an applicable error).
 //
@@ -61,6 +61,76 @@ func (c *Client) Generate(numBlocks uint32) ([]*chainhash.Hash, error) {
 	return c.GenerateAsync(numBlocks).Receive()
 }
 
+// FutureGenerateToAddressResult is a future promise to deliver the result of
+// a GenerateToAddressAsync RPC invocation (or an applicable error).
+type FutureGenerateToAddressResult chan *response
+
+// Receive waits for the response promised by the future and returns a list of
+// block hashes generated by the call.
+func (r FutureGenerateToAddressResult) Receive() ([]*chainhash.Hash, error) {
+	return FutureGenerateResult(r).Receive()
+}
+
+// GenerateToAddressAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GenerateToAddress for the blocking version and more details.
+func (c *Client) GenerateToAddressAsync(numBlocks uint32, address navutil.Address) FutureGenerateToAddressResult {
+	cmd := btcjson.NewGenerateToAddressCmd(numBlocks, address.EncodeAddress())
+	return c.sendCmd(cmd)
+}
+
+// GenerateToAddress generates numBlocks blocks paying to the passed address and
+// returns their hashes.
+func (c *Client) GenerateToAddress(numBlocks uint32, address navutil.Address) ([]*chainhash.Hash, error) {
+	return c.GenerateToAddressAsync(numBlocks, address).Receive()
+}
+
+// FutureGenerateBlockResult is a future promise to deliver the result of a
+// GenerateBlockAsync RPC invocation (or an applicable error).
+type FutureGenerateBlockResult chan *response
+
+// Receive waits for the response promised by the future and returns the hash
+// of the block generated by the call.
+func (r FutureGenerateBlockResult) Receive() (*chainhash.Hash, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a generateblock result object.
+	var result btcjson.GenerateBlockResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return chainhash.NewHashFromStr(result.Hash)
+}
+
+// GenerateBlockAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GenerateBlock for the blocking version and more details.
+func (c *Client) GenerateBlockAsync(address navutil.Address, transactions []string) FutureGenerateBlockResult {
+	var txns *[]string
+	if len(transactions) > 0 {
+		txns = &transactions
+	}
+	cmd := btcjson.NewGenerateBlockCmd(address.EncodeAddress(), txns)
+	return c.sendCmd(cmd)
+}
+
+// GenerateBlock generates a block containing exactly the passed transactions,
+// in order, with a coinbase paying to the passed address and returns its hash.
+// Each transaction is either the id of a transaction in the memory pool of the
+// server or a raw hex-encoded transaction.
+func (c *Client) GenerateBlock(address navutil.Address, transactions []string) (*chainhash.Hash, error) {
+	return c.GenerateBlockAsync(address, transactions).Receive()
+}
+
 // FutureGetGenerateResult is a future promise to deliver the result of a
 // GetGenerateAsync RPC invocation (or an applicable error).
 type FutureGetGenerateResult chan *response
@@ -115,7 +185,7 @@ func (r FutureSetGenerateResult) Receive() error {
 //
 // See SetGenerate for the blocking version and more details.
 func (c *Client) SetGenerateAsync(enable bool, numCPUs int) FutureSetGenerateResult {
-	cmd := btcjson.NewSetGenerateCmd(enable, &numCPUs)
+	cmd := btcjson.NewSetGenerateCmd(enable, &numCPUs, nil, nil)
 	return c.sendCmd(cmd)
 }
 
@@ -124,6 +194,31 @@ func (c *Client) SetGenerate(enable bool, numCPUs int) error {
 	return c.SetGenerateAsync(enable, numCPUs).Receive()
 }
 
+// SetGenerateSplitAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See SetGenerateSplit for the blocking version and more details.
+func (c *Client) SetGenerateSplitAsync(enable bool, numCPUs int, payouts map[navutil.Address]uint32, coinbaseTag string) FutureSetGenerateResult {
+	payoutWeights := make(map[string]uint32, len(payouts))
+	for addr, weight := range payouts {
+		payoutWeights[addr.EncodeAddress()] = weight
+	}
+	cmd := btcjson.NewSetGenerateCmd(enable, &numCPUs, &payoutWeights,
+		&coinbaseTag)
+	return c.sendCmd(cmd)
+}
+
+// SetGenerateSplit sets the server to generate coins (mine) or not while
+// splitting the value of the coinbase of generated blocks across the passed
+// addresses in proportion to their weights and tagging it with the passed
+// coinbase tag.  An empty map pays the value to a single mining address and
+// an empty tag removes the tag.
+func (c *Client) SetGenerateSplit(enable bool, numCPUs int, payouts map[navutil.Address]uint32, coinbaseTag string) error {
+	return c.SetGenerateSplitAsync(enable, numCPUs, payouts,
+		coinbaseTag).Receive()
+}
+
 // FutureGetHashesPerSecResult is a future promise to deliver the result of a
 // GetHashesPerSecAsync RPC invocation (or an applicable error).
 type FutureGetHashesPerSecResult chan *response
@@ -414,4 +509,36 @@ func (c *Client) SubmitBlock(block *navutil.Block, options *btcjson.SubmitBlockO
 	return c.SubmitBlockAsync(block, options).Receive()
 }
 
+// FuturePrioritiseTransactionResult is a future promise to deliver the result
+// of a PrioritiseTransactionAsync RPC invocation (or an applicable error).
+type FuturePrioritiseTransactionResult chan *response
+
+// Receive waits for the response promised by the future and returns an error if
+// any occurred when adjusting the fee delta of the transaction.
+func (r FuturePrioritiseTransactionResult) Receive() error {
+	_, err := receiveFuture(r)
+	return err
+}
+
+// PrioritiseTransactionAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See PrioritiseTransaction for the blocking version and more details.
+func (c *Client) PrioritiseTransactionAsync(txHash *chainhash.Hash, feeDelta int64) FuturePrioritiseTransactionResult {
+	hash := ""
+	if txHash != nil {
+		hash = txHash.String()
+	}
+
+	cmd := btcjson.NewPrioritiseTransactionCmd(hash, 0, feeDelta)
+	return c.sendCmd(cmd)
+}
+
+// PrioritiseTransaction adjusts the fee, in satoshi, that the server uses to
+// order the passed transaction for inclusion in the blocks it generates.
+func (c *Client) PrioritiseTransaction(txHash *chainhash.Hash, feeDelta int64) error {
+	return c.PrioritiseTransactionAsync(txHash, feeDelta).Receive()
+}
+
 // TODO(davec): Implement GetBlockTemplate
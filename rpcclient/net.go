@@ -283,3 +283,159 @@ func (c *Client) GetNetTotalsAsync() FutureGetNetTotalsResult {
 func (c *Client) GetNetTotals() (*btcjson.GetNetTotalsResult, error) {
 	return c.GetNetTotalsAsync().Receive()
 }
+
+// SetBanCommand enumerates the available commands that the SetBan function
+// accepts.
+type SetBanCommand string
+
+// Constants used to indicate the command for the SetBan function.
+const (
+	// SBAdd indicates the specified IP address or subnet should be banned.
+	SBAdd SetBanCommand = "add"
+
+	// SBRemove indicates the ban of the specified IP address or subnet
+	// should be removed.
+	SBRemove SetBanCommand = "remove"
+)
+
+// String returns the SetBanCommand in human-readable form.
+func (cmd SetBanCommand) String() string {
+	return string(cmd)
+}
+
+// FutureSetBanResult is a future promise to deliver the result of a
+// SetBanAsync RPC invocation (or an applicable error).
+type FutureSetBanResult chan *response
+
+// Receive waits for the response promised by the future and returns an error if
+// any occurred when performing the specified command.
+func (r FutureSetBanResult) Receive() error {
+	_, err := receiveFuture(r)
+	return err
+}
+
+// SetBanAsync returns an instance of a type that can be used to get the result
+// of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See SetBan for the blocking version and more details.
+func (c *Client) SetBanAsync(subnet string, command SetBanCommand, banTime int64, absolute bool) FutureSetBanResult {
+	cmd := btcjson.NewSetBanCmd(subnet, btcjson.SetBanSubCmd(command),
+		&banTime, &absolute)
+	return c.sendCmd(cmd)
+}
+
+// SetBan bans the passed IP address or subnet in CIDR notation, or removes its
+// ban.  The ban time is the number of seconds to ban for, or the unix time the
+// ban expires when absolute is set.  A ban time of zero uses the default ban
+// duration of the server.
+func (c *Client) SetBan(subnet string, command SetBanCommand, banTime int64, absolute bool) error {
+	return c.SetBanAsync(subnet, command, banTime, absolute).Receive()
+}
+
+// FutureListBannedResult is a future promise to deliver the result of a
+// ListBannedAsync RPC invocation (or an applicable error).
+type FutureListBannedResult chan *response
+
+// Receive waits for the response promised by the future and returns the banned
+// IP addresses and subnets.
+func (r FutureListBannedResult) Receive() ([]btcjson.ListBannedResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as an array of listbanned result objects.
+	var bans []btcjson.ListBannedResult
+	err = json.Unmarshal(res, &bans)
+	if err != nil {
+		return nil, err
+	}
+
+	return bans, nil
+}
+
+// ListBannedAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See ListBanned for the blocking version and more details.
+func (c *Client) ListBannedAsync() FutureListBannedResult {
+	cmd := btcjson.NewListBannedCmd()
+	return c.sendCmd(cmd)
+}
+
+// ListBanned returns the banned IP addresses and subnets.
+func (c *Client) ListBanned() ([]btcjson.ListBannedResult, error) {
+	return c.ListBannedAsync().Receive()
+}
+
+// FutureClearBannedResult is a future promise to deliver the result of a
+// ClearBannedAsync RPC invocation (or an applicable error).
+type FutureClearBannedResult chan *response
+
+// Receive waits for the response promised by the future and returns an error if
+// any occurred when clearing the bans.
+func (r FutureClearBannedResult) Receive() error {
+	_, err := receiveFuture(r)
+	return err
+}
+
+// ClearBannedAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See ClearBanned for the blocking version and more details.
+func (c *Client) ClearBannedAsync() FutureClearBannedResult {
+	cmd := btcjson.NewClearBannedCmd()
+	return c.sendCmd(cmd)
+}
+
+// ClearBanned removes all banned IP addresses and subnets.
+func (c *Client) ClearBanned() error {
+	return c.ClearBannedAsync().Receive()
+}
+
+// FutureDisconnectNodeResult is a future promise to deliver the result of a
+// DisconnectNodeAsync RPC invocation (or an applicable error).
+type FutureDisconnectNodeResult chan *response
+
+// Receive waits for the response promised by the future and returns an error if
+// any occurred when disconnecting the peer.
+func (r FutureDisconnectNodeResult) Receive() error {
+	_, err := receiveFuture(r)
+	return err
+}
+
+// DisconnectNodeAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See DisconnectNode for the blocking version and more details.
+func (c *Client) DisconnectNodeAsync(addr string) FutureDisconnectNodeResult {
+	cmd := btcjson.NewDisconnectNodeCmd(&addr, nil)
+	return c.sendCmd(cmd)
+}
+
+// DisconnectNode disconnects the connected peer with the passed address.
+//
+// See DisconnectNodeByID to disconnect a peer by its node id.
+func (c *Client) DisconnectNode(addr string) error {
+	return c.DisconnectNodeAsync(addr).Receive()
+}
+
+// DisconnectNodeByIDAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See DisconnectNodeByID for the blocking version and more details.
+func (c *Client) DisconnectNodeByIDAsync(nodeID int32) FutureDisconnectNodeResult {
+	addr := ""
+	cmd := btcjson.NewDisconnectNodeCmd(&addr, &nodeID)
+	return c.sendCmd(cmd)
+}
+
+// DisconnectNodeByID disconnects the connected peer with the passed node id.
+func (c *Client) DisconnectNodeByID(nodeID int32) error {
+	return c.DisconnectNodeByIDAsync(nodeID).Receive()
+}
@@ -121,6 +121,14 @@ type NotificationHandlers struct {
 	// OnBlockDisconnected: it receives the block's height and header.
 	OnFilteredBlockDisconnected func(height int32, header *wire.BlockHeader)
 
+	// OnMinedBlockOrphaned is invoked when a block generated by the server
+	// is disconnected from the longest (best) chain by a reorganization.
+	// It will only be invoked if a preceding call to NotifyBlocks has been
+	// made to register for the notification and the function is non-nil.
+	//
+	// NOTE: This is a navd extension.
+	OnMinedBlockOrphaned func(hash *chainhash.Hash, height int32)
+
 	// OnRecvTx is invoked when a transaction that receives funds to a
 	// registered address is received into the memory pool and also
 	// connected to the longest (best) chain.  It will only be invoked if a
@@ -179,6 +187,13 @@ type NotificationHandlers struct {
 	// made to register for the notification and the function is non-nil.
 	OnTxAcceptedVerbose func(txDetails *btcjson.TxRawResult)
 
+	// OnTxExpired is invoked when a transaction is evicted from the memory
+	// pool because it remained unconfirmed for longer than the mempool
+	// expiry.  It will only be invoked if a preceding call to
+	// NotifyNewTransactions has been made to register for the
+	// notification and the function is non-nil.
+	OnTxExpired func(hash *chainhash.Hash)
+
 	// OnBtcdConnected is invoked when a wallet connects or disconnects from
 	// navd.
 	//
@@ -290,6 +305,24 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 		c.ntfnHandlers.OnFilteredBlockDisconnected(blockHeight,
 			blockHeader)
 
+	// OnMinedBlockOrphaned
+	case btcjson.MinedBlockOrphanedNtfnMethod:
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnMinedBlockOrphaned == nil {
+			return
+		}
+
+		blockHash, blockHeight, err :=
+			parseMinedBlockOrphanedNtfnParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid mined block orphaned "+
+				"notification: %v", err)
+			return
+		}
+
+		c.ntfnHandlers.OnMinedBlockOrphaned(blockHash, blockHeight)
+
 	// OnRecvTx
 	case btcjson.RecvTxNtfnMethod:
 		// Ignore the notification if the client is not interested in
@@ -409,6 +442,23 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 
 		c.ntfnHandlers.OnTxAcceptedVerbose(rawTx)
 
+	// OnTxExpired
+	case btcjson.TxExpiredNtfnMethod:
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnTxExpired == nil {
+			return
+		}
+
+		hash, err := parseTxExpiredNtfnParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid tx expired "+
+				"notification: %v", err)
+			return
+		}
+
+		c.ntfnHandlers.OnTxExpired(hash)
+
 	// OnBtcdConnected
 	case btcjson.BtcdConnectedNtfnMethod:
 		// Ignore the notification if the client is not interested in
@@ -775,6 +825,56 @@ func parseTxAcceptedVerboseNtfnParams(params []json.RawMessage) (*btcjson.TxRawR
 	return &rawTx, nil
 }
 
+// parseMinedBlockOrphanedNtfnParams parses out the block hash and height from
+// the parameters of a minedblockorphaned notification.
+func parseMinedBlockOrphanedNtfnParams(params []json.RawMessage) (*chainhash.Hash,
+	int32, error) {
+
+	if len(params) != 2 {
+		return nil, 0, wrongNumParams(len(params))
+	}
+
+	// Unmarshal first parameter as a string.
+	var blockHashStr string
+	err := json.Unmarshal(params[0], &blockHashStr)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Unmarshal second parameter as an integer.
+	var blockHeight int32
+	err = json.Unmarshal(params[1], &blockHeight)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Create hash from block hash string.
+	blockHash, err := chainhash.NewHashFromStr(blockHashStr)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return blockHash, blockHeight, nil
+}
+
+// parseTxExpiredNtfnParams parses out the transaction hash from the parameters
+// of a txexpired notification.
+func parseTxExpiredNtfnParams(params []json.RawMessage) (*chainhash.Hash, error) {
+	if len(params) != 1 {
+		return nil, wrongNumParams(len(params))
+	}
+
+	// Unmarshal first parameter as a string.
+	var txHashStr string
+	err := json.Unmarshal(params[0], &txHashStr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode string encoding of transaction sha.
+	return chainhash.NewHashFromStr(txHashStr)
+}
+
 // parseBtcdConnectedNtfnParams parses out the connection status of navd
 // and navwallet from the parameters of a navdconnected notification.
 func parseBtcdConnectedNtfnParams(params []json.RawMessage) (bool, error) {
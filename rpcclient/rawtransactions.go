@@ -306,6 +306,58 @@ func (c *Client) SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (*chainh
 	return c.SendRawTransactionAsync(tx, allowHighFees).Receive()
 }
 
+// FutureTestMempoolAcceptResult is a future promise to deliver the result
+// of a TestMempoolAcceptAsync RPC invocation (or an applicable error).
+type FutureTestMempoolAcceptResult chan *response
+
+// Receive waits for the response promised by the future and returns whether
+// or not each of the tested transactions would be accepted into the memory
+// pool.
+func (r FutureTestMempoolAcceptResult) Receive() ([]btcjson.TestMempoolAcceptResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as an array of testmempoolaccept result objects.
+	var results []btcjson.TestMempoolAcceptResult
+	err = json.Unmarshal(res, &results)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// TestMempoolAcceptAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See TestMempoolAccept for the blocking version and more details.
+func (c *Client) TestMempoolAcceptAsync(txns []*wire.MsgTx, maxFeeRate *float64) FutureTestMempoolAcceptResult {
+	rawTxns := make([]string, 0, len(txns))
+	for _, tx := range txns {
+		// Serialize the transaction and convert to hex string.
+		buf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
+		if err := tx.Serialize(buf); err != nil {
+			return newFutureError(err)
+		}
+		rawTxns = append(rawTxns, hex.EncodeToString(buf.Bytes()))
+	}
+
+	cmd := btcjson.NewTestMempoolAcceptCmd(rawTxns, maxFeeRate)
+	return c.sendCmd(cmd)
+}
+
+// TestMempoolAccept returns whether or not the passed transactions would be
+// accepted into the memory pool of the server without adding or relaying them.
+// The transactions are tested in order as a package, so later transactions may
+// spend the outputs of earlier ones.  Passing nil for maxFeeRate uses the
+// server default.
+func (c *Client) TestMempoolAccept(txns []*wire.MsgTx, maxFeeRate *float64) ([]btcjson.TestMempoolAcceptResult, error) {
+	return c.TestMempoolAcceptAsync(txns, maxFeeRate).Receive()
+}
+
 // FutureSignRawTransactionResult is a future promise to deliver the result
 // of one of the SignRawTransactionAsync family of RPC invocations (or an
 // applicable error).
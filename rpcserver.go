@@ -7,20 +7,25 @@ package main
 
 import (
 	"bytes"
+	"container/list"
 	"crypto/sha256"
 	"crypto/subtle"
+	"encoding"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"math/big"
 	"math/rand"
 	"net"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -34,12 +39,14 @@ import (
 	"github.com/navcoin/navd/btcjson"
 	"github.com/navcoin/navd/chaincfg"
 	"github.com/navcoin/navd/chaincfg/chainhash"
+	"github.com/navcoin/navd/connmgr"
 	"github.com/navcoin/navd/database"
 	"github.com/navcoin/navd/mempool"
 	"github.com/navcoin/navd/mining"
 	"github.com/navcoin/navd/mining/cpuminer"
 	"github.com/navcoin/navd/peer"
 	"github.com/navcoin/navd/txscript"
+	"github.com/navcoin/navd/v2transport"
 	"github.com/navcoin/navd/wire"
 	"github.com/navcoin/navutil"
 )
@@ -73,6 +80,28 @@ const (
 	// in the memory pool.
 	gbtRegenerateSeconds = 60
 
+	// getworkDataLen is the length of the data field of the getwork RPC.
+	// It consists of the serialized block header plus the internal sha256
+	// padding.  The internal sha256 padding consists of a single 1 bit
+	// followed by enough zeros to pad the message out to 56 bytes followed
+	// by length of the message in bits encoded as a big-endian uint64
+	// (8 bytes).  Thus, the resulting length is a multiple of the sha256
+	// block size (64 bytes).
+	getworkDataLen = (1 + ((wire.MaxBlockHeaderPayload + 8) /
+		sha256.BlockSize)) * sha256.BlockSize
+
+	// hash1Len is the length of the hash1 field of the getwork RPC.  It
+	// consists of a zero hash plus the internal sha256 padding.  See
+	// the getworkDataLen comment for details about the internal sha256
+	// padding format.
+	hash1Len = (1 + ((chainhash.HashSize + 8) / sha256.BlockSize)) *
+		sha256.BlockSize
+
+	// maxGetworkBlocks is the maximum number of blocks handed out by the
+	// getwork RPC which are kept around to match submitted headers
+	// against.  Once it is reached, the oldest work is forgotten.
+	maxGetworkBlocks = 100
+
 	// maxProtocolVersion is the max protocol version the server supports.
 	maxProtocolVersion = 70002
 )
@@ -86,6 +115,15 @@ var (
 		"time", "transactions/add", "prevblock", "coinbase/append",
 	}
 
+	// gbtAllowedMutations are all of the BIP0023 mutations the server
+	// allows callers of the getblocktemplate RPC to request via their
+	// capabilities.  It includes the finer grained forms of the default
+	// mutable fields.
+	gbtAllowedMutations = []string{
+		"time", "time/increment", "time/decrement", "transactions/add",
+		"prevblock", "coinbase/append",
+	}
+
 	// gbtCoinbaseAux describes additional data that miners should include
 	// in the coinbase signature script.  It is declared here to avoid the
 	// overhead of creating a new object on every invocation for constant
@@ -118,6 +156,15 @@ var (
 		Code:    btcjson.ErrRPCNoWallet,
 		Message: "This implementation does not implement wallet commands",
 	}
+
+	// errSignetMining is an error returned to RPC clients which request
+	// work on a signet network since they can't sign the blocks they
+	// solve.
+	errSignetMining = &btcjson.RPCError{
+		Code: btcjson.ErrRPCMisc,
+		Message: "Work can't be provided to external miners on signet " +
+			"networks -- use --generate with --signetkey instead",
+	}
 )
 
 type commandHandler func(*rpcServer, interface{}, <-chan struct{}) (interface{}, error)
@@ -128,12 +175,18 @@ type commandHandler func(*rpcServer, interface{}, <-chan struct{}) (interface{},
 var rpcHandlers map[string]commandHandler
 var rpcHandlersBeforeInit = map[string]commandHandler{
 	"addnode":               handleAddNode,
+	"addpeerbanscore":       handleAddPeerBanScore,
+	"clearbanned":           handleClearBanned,
 	"createrawtransaction":  handleCreateRawTransaction,
 	"debuglevel":            handleDebugLevel,
 	"decoderawtransaction":  handleDecodeRawTransaction,
 	"decodescript":          handleDecodeScript,
+	"disconnectnode":        handleDisconnectNode,
 	"estimatefee":           handleEstimateFee,
+	"estimatesmartfee":      handleEstimateSmartFee,
 	"generate":              handleGenerate,
+	"generateblock":         handleGenerateBlock,
+	"generatetoaddress":     handleGenerateToAddress,
 	"getaddednodeinfo":      handleGetAddedNodeInfo,
 	"getbestblock":          handleGetBestBlock,
 	"getbestblockhash":      handleGetBestBlockHash,
@@ -154,20 +207,27 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"getinfo":               handleGetInfo,
 	"getmempoolinfo":        handleGetMempoolInfo,
 	"getmininginfo":         handleGetMiningInfo,
+	"getminingstats":        handleGetMiningStats,
 	"getnettotals":          handleGetNetTotals,
 	"getnetworkhashps":      handleGetNetworkHashPS,
 	"getpeerinfo":           handleGetPeerInfo,
 	"getrawmempool":         handleGetRawMempool,
 	"getrawtransaction":     handleGetRawTransaction,
 	"gettxout":              handleGetTxOut,
+	"getwork":               handleGetWork,
 	"help":                  handleHelp,
+	"listbanned":            handleListBanned,
 	"node":                  handleNode,
 	"ping":                  handlePing,
+	"prioritisetransaction": handlePrioritiseTransaction,
 	"searchrawtransactions": handleSearchRawTransactions,
 	"sendrawtransaction":    handleSendRawTransaction,
+	"setban":                handleSetBan,
 	"setgenerate":           handleSetGenerate,
+	"setpeerpermission":     handleSetPeerPermission,
 	"stop":                  handleStop,
 	"submitblock":           handleSubmitBlock,
+	"testmempoolaccept":     handleTestMempoolAccept,
 	"uptime":                handleUptime,
 	"validateaddress":       handleValidateAddress,
 	"verifychain":           handleVerifyChain,
@@ -229,7 +289,6 @@ var rpcUnimplemented = map[string]struct{}{
 	"getchaintips":     {},
 	"getmempoolentry":  {},
 	"getnetworkinfo":   {},
-	"getwork":          {},
 	"invalidateblock":  {},
 	"preciousblock":    {},
 	"reconsiderblock":  {},
@@ -255,6 +314,7 @@ var rpcLimited = map[string]struct{}{
 	"decoderawtransaction":  {},
 	"decodescript":          {},
 	"estimatefee":           {},
+	"estimatesmartfee":      {},
 	"getbestblock":          {},
 	"getbestblockhash":      {},
 	"getblock":              {},
@@ -275,6 +335,7 @@ var rpcLimited = map[string]struct{}{
 	"searchrawtransactions": {},
 	"sendrawtransaction":    {},
 	"submitblock":           {},
+	"testmempoolaccept":     {},
 	"uptime":                {},
 	"validateaddress":       {},
 	"verifymessage":         {},
@@ -335,17 +396,60 @@ type gbtWorkState struct {
 	template      *mining.BlockTemplate
 	notifyMap     map[chainhash.Hash]map[int64]chan struct{}
 	timeSource    blockchain.MedianTimeSource
+
+	// getworkBlocks houses the block templates handed out by the getwork
+	// RPC keyed by the merkle root of their block so submitted headers can
+	// be matched back to the full block.  It is reset whenever the
+	// template builds on a new block and holds at most maxGetworkBlocks
+	// templates, with getworkOrder tracking the merkle roots from the
+	// oldest to the newest work.
+	getworkBlocks     map[chainhash.Hash]*mining.BlockTemplate
+	getworkOrder      *list.List
+	getworkPrevHash   chainhash.Hash
+	getworkExtraNonce uint64
 }
 
 // newGbtWorkState returns a new instance of a gbtWorkState with all internal
 // fields initialized and ready to use.
 func newGbtWorkState(timeSource blockchain.MedianTimeSource) *gbtWorkState {
 	return &gbtWorkState{
-		notifyMap:  make(map[chainhash.Hash]map[int64]chan struct{}),
-		timeSource: timeSource,
+		notifyMap:     make(map[chainhash.Hash]map[int64]chan struct{}),
+		timeSource:    timeSource,
+		getworkBlocks: make(map[chainhash.Hash]*mining.BlockTemplate),
+		getworkOrder:  list.New(),
 	}
 }
 
+// addGetworkBlock records the passed block template handed out by the getwork
+// RPC, forgetting the oldest work when there are already maxGetworkBlocks
+// templates.
+//
+// This function MUST be called with the state locked.
+func (state *gbtWorkState) addGetworkBlock(template *mining.BlockTemplate) {
+	for state.getworkOrder.Len() >= maxGetworkBlocks {
+		oldest := state.getworkOrder.Remove(state.getworkOrder.Front())
+		delete(state.getworkBlocks, oldest.(chainhash.Hash))
+	}
+	merkleRoot := template.Block.Header.MerkleRoot
+	state.getworkBlocks[merkleRoot] = template
+	state.getworkOrder.PushBack(merkleRoot)
+}
+
+// currentTemplate returns a copy of the details of the block template most
+// recently returned by getblocktemplate, or nil when there is none.  The block
+// of the copy is shared with the template.
+//
+// This function is safe for concurrent access.
+func (state *gbtWorkState) currentTemplate() *mining.BlockTemplate {
+	state.Lock()
+	defer state.Unlock()
+	if state.template == nil {
+		return nil
+	}
+	template := *state.template
+	return &template
+}
+
 // handleUnimplemented is the handler for commands that should ultimately be
 // supported but are not yet implemented.
 func handleUnimplemented(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
@@ -484,6 +588,123 @@ func handleNode(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (inter
 	return nil, nil
 }
 
+// findPeerByID returns the connected peer with the given node id or nil when
+// no such peer is connected.
+func findPeerByID(connMgr rpcserverConnManager, nodeID int32) rpcserverPeer {
+	for _, p := range connMgr.ConnectedPeers() {
+		if p.ToPeer().ID() == nodeID {
+			return p
+		}
+	}
+	return nil
+}
+
+// handleDisconnectNode handles disconnectnode commands.
+func handleDisconnectNode(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DisconnectNodeCmd)
+
+	var addr string
+	if c.Address != nil {
+		addr = *c.Address
+	}
+	if (addr == "") == (c.NodeID == nil) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "exactly one of address and nodeid must be provided",
+		}
+	}
+
+	var target rpcserverPeer
+	if c.NodeID != nil {
+		target = findPeerByID(s.cfg.ConnMgr, *c.NodeID)
+	} else {
+		addr = normalizeAddress(addr, s.cfg.ChainParams.DefaultPort)
+		for _, p := range s.cfg.ConnMgr.ConnectedPeers() {
+			if p.ToPeer().Addr() == addr {
+				target = p
+				break
+			}
+		}
+	}
+	if target == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCClientNodeNotConnected,
+			Message: "peer not found",
+		}
+	}
+
+	// Persistent peers are reconnected to by the connection manager once
+	// they are disconnected.
+	rpcsLog.Infof("Audit: disconnecting peer %s (id %d) by RPC request",
+		target.ToPeer(), target.ToPeer().ID())
+	target.ToPeer().Disconnect()
+
+	// no data returned unless an error.
+	return nil, nil
+}
+
+// handleSetPeerPermission handles setpeerpermission commands.
+func handleSetPeerPermission(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetPeerPermissionCmd)
+
+	target := findPeerByID(s.cfg.ConnMgr, c.NodeID)
+	if target == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCClientNodeNotConnected,
+			Message: "peer not found",
+		}
+	}
+
+	enable := true
+	if c.Enable != nil {
+		enable = *c.Enable
+	}
+	switch c.Permission {
+	case btcjson.PPWhitelist:
+		target.SetWhitelisted(enable)
+	case btcjson.PPNoBan:
+		target.SetNoBan(enable)
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "invalid permission for setpeerpermission",
+		}
+	}
+
+	action := "granting"
+	if !enable {
+		action = "revoking"
+	}
+	rpcsLog.Infof("Audit: %s %s permission of peer %s (id %d) by RPC "+
+		"request", action, c.Permission, target.ToPeer(), c.NodeID)
+
+	// no data returned unless an error.
+	return nil, nil
+}
+
+// handleAddPeerBanScore handles addpeerbanscore commands.
+func handleAddPeerBanScore(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AddPeerBanScoreCmd)
+
+	if c.Reason == "" {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "a reason for the ban score must be provided",
+		}
+	}
+	target := findPeerByID(s.cfg.ConnMgr, c.NodeID)
+	if target == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCClientNodeNotConnected,
+			Message: "peer not found",
+		}
+	}
+
+	rpcsLog.Infof("Audit: increasing ban score of peer %s (id %d) by %d "+
+		"by RPC request: %s", target.ToPeer(), c.NodeID, c.Score, c.Reason)
+	return target.AddBanScore(c.Score, 0, c.Reason), nil
+}
+
 // peerExists determines if a certain peer is currently connected given
 // information about all currently connected peers. Peer existence is
 // determined using either a target address or node id.
@@ -508,6 +729,12 @@ func messageToHex(msg wire.Message) (string, error) {
 	return hex.EncodeToString(buf.Bytes()), nil
 }
 
+// handleClearBanned handles clearbanned commands.
+func handleClearBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	s.cfg.ConnMgr.ClearBanned()
+	return nil, nil
+}
+
 // handleCreateRawTransaction handles createrawtransaction commands.
 func handleCreateRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.CreateRawTransactionCmd)
@@ -876,6 +1103,55 @@ func handleEstimateFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return float64(feeRate), nil
 }
 
+// handleEstimateSmartFee handles estimatesmartfee commands.
+func handleEstimateSmartFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.EstimateSmartFeeCmd)
+
+	if s.cfg.FeeEstimator == nil {
+		return nil, errors.New("Fee estimation disabled")
+	}
+
+	if c.ConfTarget <= 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Parameter ConfTarget must be positive",
+		}
+	}
+
+	mode := mempool.EstimateModeConservative
+	if c.EstimateMode != nil {
+		switch btcjson.EstimateSmartFeeMode(strings.ToUpper(string(*c.EstimateMode))) {
+		case btcjson.EstimateModeConservative:
+		case btcjson.EstimateModeEconomical:
+			mode = mempool.EstimateModeEconomical
+		default:
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid estimate mode: " + string(*c.EstimateMode),
+			}
+		}
+	}
+
+	confTarget := uint32(math.MaxUint32)
+	if c.ConfTarget < math.MaxUint32 {
+		confTarget = uint32(c.ConfTarget)
+	}
+	feeRate, blocks, err := s.cfg.FeeEstimator.EstimateSmartFee(confTarget,
+		mode)
+	if err != nil {
+		return &btcjson.EstimateSmartFeeResult{
+			Errors: []string{err.Error()},
+			Blocks: int64(blocks),
+		}, nil
+	}
+
+	rate := float64(feeRate)
+	return &btcjson.EstimateSmartFeeResult{
+		FeeRate: &rate,
+		Blocks:  int64(blocks),
+	}, nil
+}
+
 // handleGenerate handles generate commands.
 func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Respond with an error if there are no addresses to pay the
@@ -930,6 +1206,146 @@ func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 	return reply, nil
 }
 
+// checkGenerateSupported returns an error if there's virtually 0 chance of
+// mining a block with the CPU on the current network.
+func checkGenerateSupported(s *rpcServer, method string) error {
+	if !s.cfg.ChainParams.GenerateSupported {
+		return &btcjson.RPCError{
+			Code: btcjson.ErrRPCDifficulty,
+			Message: fmt.Sprintf("No support for `%s` on the "+
+				"current network, %s, as it's unlikely to be "+
+				"possible to main a block with the CPU.",
+				method, s.cfg.ChainParams.Net),
+		}
+	}
+	return nil
+}
+
+// handleGenerateToAddress handles generatetoaddress commands.
+func handleGenerateToAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if err := checkGenerateSupported(s, "generatetoaddress"); err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.GenerateToAddressCmd)
+
+	// Respond with an error if the client is requesting 0 blocks to be generated.
+	if c.NumBlocks == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInternal.Code,
+			Message: "Please request a nonzero number of blocks to generate.",
+		}
+	}
+
+	addr, err := navutil.DecodeAddress(c.Address, s.cfg.ChainParams)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: " + err.Error(),
+		}
+	}
+	if !addr.IsForNet(s.cfg.ChainParams) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address: " + c.Address +
+				" is for the wrong network",
+		}
+	}
+
+	blockHashes, err := s.cfg.CPUMiner.GenerateNBlocksToAddress(c.NumBlocks,
+		addr)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInternal.Code,
+			Message: err.Error(),
+		}
+	}
+
+	reply := make([]string, len(blockHashes))
+	for i, hash := range blockHashes {
+		reply[i] = hash.String()
+	}
+	return reply, nil
+}
+
+// handleGenerateBlock handles generateblock commands.
+func handleGenerateBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if err := checkGenerateSupported(s, "generateblock"); err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.GenerateBlockCmd)
+	addr, err := navutil.DecodeAddress(c.Address, s.cfg.ChainParams)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: " + err.Error(),
+		}
+	}
+	if !addr.IsForNet(s.cfg.ChainParams) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address: " + c.Address +
+				" is for the wrong network",
+		}
+	}
+
+	// Each transaction is either the id of a transaction in the memory
+	// pool or a raw serialized transaction.
+	var txns []*navutil.Tx
+	if c.Transactions != nil {
+		txns = make([]*navutil.Tx, 0, len(*c.Transactions))
+		for _, txStr := range *c.Transactions {
+			if len(txStr) == chainhash.MaxHashStringSize {
+				txHash, err := chainhash.NewHashFromStr(txStr)
+				if err != nil {
+					return nil, rpcDecodeHexError(txStr)
+				}
+				tx, err := s.cfg.TxMemPool.FetchTransaction(txHash)
+				if err != nil {
+					return nil, &btcjson.RPCError{
+						Code: btcjson.ErrRPCNoTxInfo,
+						Message: "Transaction " + txStr +
+							" is not in the memory pool",
+					}
+				}
+				txns = append(txns, tx)
+				continue
+			}
+
+			hexStr := txStr
+			if len(hexStr)%2 != 0 {
+				hexStr = "0" + hexStr
+			}
+			serializedTx, err := hex.DecodeString(hexStr)
+			if err != nil {
+				return nil, rpcDecodeHexError(hexStr)
+			}
+			var msgTx wire.MsgTx
+			err = msgTx.Deserialize(bytes.NewReader(serializedTx))
+			if err != nil {
+				return nil, &btcjson.RPCError{
+					Code:    btcjson.ErrRPCDeserialization,
+					Message: "TX decode failed: " + err.Error(),
+				}
+			}
+			txns = append(txns, navutil.NewTx(&msgTx))
+		}
+	}
+
+	blockHash, err := s.cfg.CPUMiner.GenerateBlock(addr, txns)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInternal.Code,
+			Message: err.Error(),
+		}
+	}
+
+	return &btcjson.GenerateBlockResult{
+		Hash: blockHash.String(),
+	}, nil
+}
+
 // handleGetAddedNodeInfo handles getaddednodeinfo commands.
 func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetAddedNodeInfoCmd)
@@ -1619,20 +2035,18 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 			// Choose a payment address at random.
 			payToAddr := cfg.miningAddrs[rand.Intn(len(cfg.miningAddrs))]
 
-			// Update the block coinbase output of the template to
-			// pay to the randomly selected payment address.
-			pkScript, err := txscript.PayToAddrScript(payToAddr)
+			// Update the block coinbase outputs of the template to
+			// pay to the randomly selected payment address, or
+			// split the value according to the coinbase split.
+			// This also updates the signature operation cost of
+			// the coinbase and the merkle root.
+			err := generator.UpdateCoinbasePayout(template,
+				payToAddr)
 			if err != nil {
-				context := "Failed to create pay-to-addr script"
+				context := "Failed to update coinbase payout"
 				return internalRPCError(err.Error(), context)
 			}
-			template.Block.Transactions[0].TxOut[0].PkScript = pkScript
 			template.ValidPayAddress = true
-
-			// Update the merkle root.
-			block := navutil.NewBlock(template.Block)
-			merkles := blockchain.BuildMerkleTreeStore(block.Transactions(), false)
-			template.Block.Header.MerkleRoot = *merkles[len(merkles)-1]
 		}
 
 		// Set locals for convenience.
@@ -1659,7 +2073,7 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 // and returned to the caller.
 //
 // This function MUST be called with the state locked.
-func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld *bool) (*btcjson.GetBlockTemplateResult, error) {
+func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, mutable []string, submitOld *bool) (*btcjson.GetBlockTemplateResult, error) {
 	// Ensure the timestamps are still in valid range for the template.
 	// This should really only ever happen if the local clock is changed
 	// after the template is generated, but it's important to avoid serving
@@ -1751,7 +2165,7 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 		Target:       targetDifficulty,
 		MinTime:      state.minTimestamp.Unix(),
 		MaxTime:      maxTime.Unix(),
-		Mutable:      gbtMutableFields,
+		Mutable:      mutable,
 		NonceRange:   gbtNonceRange,
 		Capabilities: gbtCapabilities,
 	}
@@ -1791,6 +2205,7 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 			Depends: []int64{},
 			Fee:     template.Fees[0],
 			SigOps:  template.SigOpCosts[0],
+			Weight:  blockchain.GetTransactionWeight(navutil.NewTx(tx)),
 		}
 
 		reply.CoinbaseTxn = &resultTx
@@ -1799,6 +2214,30 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 	return &reply, nil
 }
 
+// gbtMutations returns the mutations to advertise in a block template for a
+// caller which reported the passed capabilities.  Callers which do not report
+// any of the mutations the server allows are given the default mutable fields,
+// while the rest are only given the allowed mutations they reported.
+//
+// See https://en.bitcoin.it/wiki/BIP_0023 for more details.
+func gbtMutations(capabilities []string) []string {
+	reported := make(map[string]struct{}, len(capabilities))
+	for _, capability := range capabilities {
+		reported[capability] = struct{}{}
+	}
+
+	var mutable []string
+	for _, mutation := range gbtAllowedMutations {
+		if _, ok := reported[mutation]; ok {
+			mutable = append(mutable, mutation)
+		}
+	}
+	if len(mutable) == 0 {
+		return gbtMutableFields
+	}
+	return mutable
+}
+
 // handleGetBlockTemplateLongPoll is a helper for handleGetBlockTemplateRequest
 // which deals with handling long polling for block templates.  When a caller
 // sends a request with a long poll ID that was previously returned, a response
@@ -1809,7 +2248,7 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 // has passed without finding a solution.
 //
 // See https://en.bitcoin.it/wiki/BIP_0022 for more details.
-func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbaseValue bool, closeChan <-chan struct{}) (interface{}, error) {
+func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbaseValue bool, mutable []string, closeChan <-chan struct{}) (interface{}, error) {
 	state := s.gbtWorkState
 	state.Lock()
 	// The state unlock is intentionally not deferred here since it needs to
@@ -1825,7 +2264,7 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 	// the caller is invalid.
 	prevHash, lastGenerated, err := decodeTemplateID(longPollID)
 	if err != nil {
-		result, err := state.blockTemplateResult(useCoinbaseValue, nil)
+		result, err := state.blockTemplateResult(useCoinbaseValue, mutable, nil)
 		if err != nil {
 			state.Unlock()
 			return nil, err
@@ -1847,7 +2286,7 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 		// already been found and added to the block chain.
 		submitOld := prevHash.IsEqual(prevTemplateHash)
 		result, err := state.blockTemplateResult(useCoinbaseValue,
-			&submitOld)
+			mutable, &submitOld)
 		if err != nil {
 			state.Unlock()
 			return nil, err
@@ -1887,7 +2326,8 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 	// block template depending on whether or not a solution has already
 	// been found and added to the block chain.
 	submitOld := prevHash.IsEqual(&state.template.Block.Header.PrevBlock)
-	result, err := state.blockTemplateResult(useCoinbaseValue, &submitOld)
+	result, err := state.blockTemplateResult(useCoinbaseValue, mutable,
+		&submitOld)
 	if err != nil {
 		return nil, err
 	}
@@ -1903,11 +2343,21 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 // coinbasetxn and coinbasevalue capabilities) and modifies the returned block
 // template accordingly.
 func handleGetBlockTemplateRequest(s *rpcServer, request *btcjson.TemplateRequest, closeChan <-chan struct{}) (interface{}, error) {
+	// Blocks on signet networks must be signed by the holder of the
+	// challenge keys, which external miners can't do.
+	if len(s.cfg.ChainParams.SignetChallenge) != 0 {
+		return nil, errSignetMining
+	}
+
 	// Extract the relevant passed capabilities and restrict the result to
 	// either a coinbase value or a coinbase transaction object depending on
-	// the request.  Default to only providing a coinbase value.
+	// the request.  Default to only providing a coinbase value.  Also
+	// restrict the advertised mutations to those the caller supports.
 	useCoinbaseValue := true
+	mutable := gbtMutableFields
 	if request != nil {
+		mutable = gbtMutations(request.Capabilities)
+
 		var hasCoinbaseValue, hasCoinbaseTxn bool
 		for _, capability := range request.Capabilities {
 			switch capability {
@@ -1961,7 +2411,7 @@ func handleGetBlockTemplateRequest(s *rpcServer, request *btcjson.TemplateReques
 	// be replaced with a new one.
 	if request != nil && request.LongPollID != "" {
 		return handleGetBlockTemplateLongPoll(s, request.LongPollID,
-			useCoinbaseValue, closeChan)
+			useCoinbaseValue, mutable, closeChan)
 	}
 
 	// Protect concurrent access when updating block templates.
@@ -1978,7 +2428,7 @@ func handleGetBlockTemplateRequest(s *rpcServer, request *btcjson.TemplateReques
 	if err := state.updateBlockTemplate(s, useCoinbaseValue); err != nil {
 		return nil, err
 	}
-	return state.blockTemplateResult(useCoinbaseValue, nil)
+	return state.blockTemplateResult(useCoinbaseValue, mutable, nil)
 }
 
 // chainErrToGBTErrString converts an error returned from navchain to a string
@@ -2120,10 +2570,31 @@ func handleGetBlockTemplateProposal(s *rpcServer, request *btcjson.TemplateReque
 	}
 	block := navutil.NewBlock(&msgBlock)
 
+	// Reject blocks which are already known.
+	exists, err := s.cfg.Chain.HaveBlock(block.Hash())
+	if err != nil {
+		context := "Failed to check block existence"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if exists {
+		return "duplicate", nil
+	}
+
 	// Ensure the block is building from the expected previous block.
+	// Since the prevblock mutation is allowed, a block which builds on
+	// another known block is not necessarily invalid, but it can only be
+	// fully checked when it extends the best chain.
 	expectedPrevHash := s.cfg.Chain.BestSnapshot().Hash
 	prevHash := &block.MsgBlock().Header.PrevBlock
 	if !expectedPrevHash.IsEqual(prevHash) {
+		exists, err := s.cfg.Chain.HaveBlock(prevHash)
+		if err != nil {
+			context := "Failed to check previous block existence"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		if exists {
+			return "inconclusive-not-best-prevblk", nil
+		}
 		return "bad-prevblk", nil
 	}
 
@@ -2354,18 +2825,62 @@ func handleGetMiningInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 	}
 
 	best := s.cfg.Chain.BestSnapshot()
+	templateStats := s.cfg.Generator.TemplateStats()
 	result := btcjson.GetMiningInfoResult{
-		Blocks:             int64(best.Height),
-		CurrentBlockSize:   best.BlockSize,
-		CurrentBlockWeight: best.BlockWeight,
-		CurrentBlockTx:     best.NumTxns,
-		Difficulty:         getDifficultyRatio(best.Bits, s.cfg.ChainParams),
-		Generate:           s.cfg.CPUMiner.IsMining(),
-		GenProcLimit:       s.cfg.CPUMiner.NumWorkers(),
-		HashesPerSec:       int64(s.cfg.CPUMiner.HashesPerSecond()),
-		NetworkHashPS:      networkHashesPerSec,
-		PooledTx:           uint64(s.cfg.TxMemPool.Count()),
-		TestNet:            cfg.TestNet3,
+		Blocks:               int64(best.Height),
+		CurrentBlockSize:     best.BlockSize,
+		CurrentBlockWeight:   best.BlockWeight,
+		CurrentBlockTx:       best.NumTxns,
+		Difficulty:           getDifficultyRatio(best.Bits, s.cfg.ChainParams),
+		Generate:             s.cfg.CPUMiner.IsMining(),
+		GenProcLimit:         s.cfg.CPUMiner.NumWorkers(),
+		HashesPerSec:         int64(s.cfg.CPUMiner.HashesPerSecond()),
+		NetworkHashPS:        networkHashesPerSec,
+		PooledTx:             uint64(s.cfg.TxMemPool.Count()),
+		TemplateBuildTime:    templateStats.LastBuildTime.Seconds(),
+		AvgTemplateBuildTime: templateStats.AvgBuildTime.Seconds(),
+		TestNet:              cfg.TestNet3,
+	}
+	return &result, nil
+}
+
+// handleGetMiningStats implements the getminingstats command.
+func handleGetMiningStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	best := s.cfg.Chain.BestSnapshot()
+	templateStats := s.cfg.Generator.TemplateStats()
+	history := s.cfg.Generator.BlockHistory()
+	result := btcjson.GetMiningStatsResult{
+		TemplateBuilds:       templateStats.Builds,
+		TemplatesReused:      templateStats.Reused,
+		FullRebuilds:         templateStats.FullRebuilds,
+		TemplateBuildTime:    templateStats.LastBuildTime.Seconds(),
+		AvgTemplateBuildTime: templateStats.AvgBuildTime.Seconds(),
+		Blocks:               make([]btcjson.GeneratedBlockResult, 0, len(history)),
+	}
+	for _, generated := range history {
+		var confirmations int64
+		switch generated.Status {
+		case mining.BlockAccepted:
+			result.Accepted++
+			confirmations = 1 + int64(best.Height-generated.Height)
+		case mining.BlockOrphaned:
+			result.Orphaned++
+		case mining.BlockStale:
+			result.Stale++
+		case mining.BlockRejected:
+			result.Rejected++
+		}
+
+		result.Blocks = append(result.Blocks, btcjson.GeneratedBlockResult{
+			Hash:          generated.Hash.String(),
+			Height:        generated.Height,
+			Time:          generated.Time.Unix(),
+			BuildTime:     generated.BuildTime.Seconds(),
+			Fees:          navutil.Amount(generated.Fees).ToNAV(),
+			TxCount:       generated.NumTxns,
+			Status:        generated.Status.String(),
+			Confirmations: confirmations,
+		})
 	}
 	return &result, nil
 }
@@ -2503,6 +3018,15 @@ func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 			BanScore:       int32(p.BanScore()),
 			FeeFilter:      p.FeeFilter(),
 			SyncNode:       statsSnap.ID == syncPeerID,
+			MappedAS:       p.MappedAS(),
+		}
+		switch statsSnap.Transport {
+		case v2transport.ProtocolV1:
+			info.TransportProtocol = "v1"
+		case v2transport.ProtocolV2:
+			info.TransportProtocol = "v2"
+		default:
+			info.TransportProtocol = "detecting"
 		}
 		if p.ToPeer().LastPingNonce() != 0 {
 			wait := float64(time.Since(statsSnap.LastPingTime).Nanoseconds())
@@ -2762,6 +3286,338 @@ func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 	return txOutReply, nil
 }
 
+// reverseUint32Array treats the passed bytes as a series of uint32s and
+// reverses the byte order of each uint32.  The passed byte slice must be a
+// multiple of 4 for a correct result.  The passed bytes slice is modified.
+func reverseUint32Array(b []byte) {
+	blen := len(b)
+	for i := 0; i < blen; i += 4 {
+		b[i], b[i+3] = b[i+3], b[i]
+		b[i+1], b[i+2] = b[i+2], b[i+1]
+	}
+}
+
+// bigToLEUint256 returns the passed big integer as an unsigned 256-bit integer
+// encoded as little-endian bytes.  Numbers which are larger than the max
+// unsigned 256-bit integer are truncated.
+func bigToLEUint256(n *big.Int) [uint256Size]byte {
+	// Pad or truncate the big-endian big int to correct number of bytes.
+	nBytes := n.Bytes()
+	nlen := len(nBytes)
+	pad := 0
+	start := 0
+	if nlen <= uint256Size {
+		pad = uint256Size - nlen
+	} else {
+		start = nlen - uint256Size
+	}
+	var buf [uint256Size]byte
+	copy(buf[pad:], nBytes[start:])
+
+	// Reverse the bytes to little endian and return them.
+	for i := 0; i < uint256Size/2; i++ {
+		buf[i], buf[uint256Size-1-i] = buf[uint256Size-1-i], buf[i]
+	}
+	return buf
+}
+
+// getworkData returns the data field of the getwork RPC for the passed block
+// header.  It is the serialized header followed by the internal sha256 padding
+// with the bytes of every uint32 reversed as legacy miners expect.
+func getworkData(header *wire.BlockHeader) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := header.Serialize(&buf); err != nil {
+		return nil, err
+	}
+
+	// Add the sha256 padding: a single 1 bit followed by zeros and the
+	// message length in bits as a big-endian uint64.
+	data := make([]byte, getworkDataLen)
+	copy(data, buf.Bytes())
+	data[wire.MaxBlockHeaderPayload] = 0x80
+	binary.BigEndian.PutUint64(data[getworkDataLen-8:],
+		wire.MaxBlockHeaderPayload*8)
+
+	reverseUint32Array(data)
+	return data, nil
+}
+
+// getworkMidstate returns the sha256 state after hashing the first block of
+// the passed serialized header encoded as little-endian uint32s.  Miners which
+// hash X13 headers ignore it, but it is still provided for compatibility with
+// the original getwork format.
+func getworkMidstate(serializedHeader []byte) ([]byte, error) {
+	h := sha256.New()
+	h.Write(serializedHeader[:sha256.BlockSize])
+
+	// The marshalled state of the standard library implementation is a
+	// 4-byte magic followed by the eight big-endian state words.
+	state, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	midstate := make([]byte, sha256.Size)
+	copy(midstate, state[4:4+sha256.Size])
+	reverseUint32Array(midstate)
+	return midstate, nil
+}
+
+// handleGetWorkRequest is a helper for handleGetWork which deals with
+// generating and returning work to the caller.
+//
+// This function MUST be called with the RPC workstate locked.
+func handleGetWorkRequest(s *rpcServer) (interface{}, error) {
+	state := s.gbtWorkState
+
+	// Legacy miners are given a full coinbase paying to one of the
+	// configured payment addresses, so reuse the current block template
+	// with a payment address.
+	if err := state.updateBlockTemplate(s, false); err != nil {
+		return nil, err
+	}
+	template := state.template
+
+	// Forget about the work handed out for previous blocks since any
+	// solutions to it are now stale.
+	if !state.getworkPrevHash.IsEqual(&template.Block.Header.PrevBlock) {
+		state.getworkBlocks = make(map[chainhash.Hash]*mining.BlockTemplate)
+		state.getworkOrder.Init()
+		state.getworkPrevHash = template.Block.Header.PrevBlock
+	}
+
+	// Copy the template and give it a unique extra nonce so the resulting
+	// merkle root identifies the work when the solved header is submitted.
+	// The template itself is shared with getblocktemplate and must not be
+	// modified.
+	msgBlock := &wire.MsgBlock{
+		Header:       template.Block.Header,
+		Transactions: make([]*wire.MsgTx, len(template.Block.Transactions)),
+	}
+	copy(msgBlock.Transactions, template.Block.Transactions)
+	msgBlock.Transactions[0] = template.Block.Transactions[0].Copy()
+	state.getworkExtraNonce++
+	err := s.cfg.Generator.UpdateExtraNonce(msgBlock, template.Height,
+		state.getworkExtraNonce)
+	if err != nil {
+		errStr := fmt.Sprintf("Failed to update extra nonce: %v", err)
+		return nil, internalRPCError(errStr, "")
+	}
+	getworkTemplate := *template
+	getworkTemplate.Block = msgBlock
+	state.addGetworkBlock(&getworkTemplate)
+
+	rpcsLog.Debugf("Handing out getwork for block template (timestamp %v, "+
+		"merkle root %s)", msgBlock.Header.Timestamp,
+		msgBlock.Header.MerkleRoot)
+
+	data, err := getworkData(&msgBlock.Header)
+	if err != nil {
+		errStr := fmt.Sprintf("Failed to serialize data: %v", err)
+		return nil, internalRPCError(errStr, "")
+	}
+
+	// The midstate is calculated over the header in its original byte
+	// order, so undo the uint32 byte swapping on a copy of the data.
+	serializedHeader := make([]byte, getworkDataLen)
+	copy(serializedHeader, data)
+	reverseUint32Array(serializedHeader)
+	midstate, err := getworkMidstate(serializedHeader)
+	if err != nil {
+		errStr := fmt.Sprintf("Failed to calculate midstate: %v", err)
+		return nil, internalRPCError(errStr, "")
+	}
+
+	// The hash1 field is a zero hash with the internal sha256 padding and
+	// the bytes of every uint32 reversed like the data field.
+	var hash1 [hash1Len]byte
+	hash1[chainhash.HashSize] = 0x80
+	binary.BigEndian.PutUint64(hash1[hash1Len-8:], chainhash.HashSize*8)
+	reverseUint32Array(hash1[:])
+
+	target := bigToLEUint256(blockchain.CompactToBig(msgBlock.Header.Bits))
+	reply := &btcjson.GetWorkResult{
+		Data:     hex.EncodeToString(data),
+		Hash1:    hex.EncodeToString(hash1[:]),
+		Midstate: hex.EncodeToString(midstate),
+		Target:   hex.EncodeToString(target[:]),
+	}
+	return reply, nil
+}
+
+// handleGetWorkSubmission is a helper for handleGetWork which deals with
+// the calling submitting work to be verified and processed.
+//
+// This function MUST be called with the RPC workstate locked.
+func handleGetWorkSubmission(s *rpcServer, hexData string) (interface{}, error) {
+	// Ensure the provided data is sane.
+	if len(hexData)%2 != 0 {
+		hexData = "0" + hexData
+	}
+	data, err := hex.DecodeString(hexData)
+	if err != nil {
+		return false, rpcDecodeHexError(hexData)
+	}
+	if len(data) != getworkDataLen {
+		return false, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Argument must be %d bytes (not "+
+				"%d)", getworkDataLen, len(data)),
+		}
+	}
+
+	// Reverse the data as if it were an array of 32-bit unsigned integers.
+	// The fact the getwork request and submission data is reversed in this
+	// way is rather odd, but it's an artifact of some legacy internal state
+	// in the reference implementation.
+	reverseUint32Array(data)
+
+	// Deserialize the block header from the data.
+	var submittedHeader wire.BlockHeader
+	err = submittedHeader.Deserialize(bytes.NewReader(data))
+	if err != nil {
+		return false, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Argument does not contain a "+
+				"valid block header: %v", err),
+		}
+	}
+
+	// Look up the full block for the provided data based on the merkle
+	// root.  Return false to indicate the solve failed if it's not
+	// available.
+	state := s.gbtWorkState
+	template, ok := state.getworkBlocks[submittedHeader.MerkleRoot]
+	if !ok {
+		rpcsLog.Debugf("Block submitted via getwork has no matching "+
+			"template for merkle root %s",
+			submittedHeader.MerkleRoot)
+		return false, nil
+	}
+
+	// Reconstruct the block using the submitted header stored block info.
+	// Only the fields the miner is allowed to change are taken from the
+	// submitted header.
+	msgBlock := *template.Block
+	msgBlock.Header.Timestamp = submittedHeader.Timestamp
+	msgBlock.Header.Nonce = submittedHeader.Nonce
+	block := navutil.NewBlock(&msgBlock)
+
+	// Ensure the submitted block hash is less than the target difficulty.
+	// The block hash is the X13 hash for headers with a version of 6 or
+	// less.
+	err = blockchain.CheckProofOfWork(block, s.cfg.ChainParams.PowLimit)
+	if err != nil {
+		// Anything other than a rule violation is an unexpected error,
+		// so return that error as an internal error.
+		if _, ok := err.(blockchain.RuleError); !ok {
+			return false, internalRPCError("Unexpected error while "+
+				"checking proof of work: "+err.Error(), "")
+		}
+
+		rpcsLog.Debugf("Block submitted via getwork does not meet "+
+			"the required proof of work: %v", err)
+		return false, nil
+	}
+
+	// The outcome of the solved block is recorded in the history of
+	// generated blocks from here on.
+	generator := s.cfg.Generator
+	latestHash := &s.cfg.Chain.BestSnapshot().Hash
+	if !msgBlock.Header.PrevBlock.IsEqual(latestHash) {
+		rpcsLog.Debugf("Block submitted via getwork with previous "+
+			"block %s is stale", msgBlock.Header.PrevBlock)
+		generator.RecordBlock(template, block, mining.BlockStale)
+		return false, nil
+	}
+
+	// Process this block using the same rules as blocks coming from other
+	// nodes.  This will in turn relay it to the network like normal.
+	isOrphan, err := s.cfg.SyncMgr.SubmitBlock(block, blockchain.BFNone)
+	if err != nil {
+		generator.RecordBlock(template, block, mining.BlockRejected)
+
+		// Anything other than a rule violation is an unexpected error,
+		// so return that error as an internal error.
+		if _, ok := err.(blockchain.RuleError); !ok {
+			return false, internalRPCError("Unexpected error while "+
+				"processing block: "+err.Error(), "")
+		}
+
+		rpcsLog.Infof("Block submitted via getwork rejected: %v", err)
+		return false, nil
+	}
+	if isOrphan {
+		rpcsLog.Infof("Block submitted via getwork is an orphan: %s",
+			block.Hash())
+		generator.RecordBlock(template, block, mining.BlockRejected)
+		return false, nil
+	}
+
+	// The block was accepted.
+	generator.RecordBlock(template, block, mining.BlockAccepted)
+	rpcsLog.Infof("Block submitted via getwork accepted: %s", block.Hash())
+	return true, nil
+}
+
+// handleGetWork implements the getwork command.
+func handleGetWork(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetWorkCmd)
+
+	// Blocks on signet networks must be signed by the holder of the
+	// challenge keys, which external miners can't do.
+	if len(s.cfg.ChainParams.SignetChallenge) != 0 {
+		return nil, errSignetMining
+	}
+
+	// Respond with an error if there are no addresses to pay the created
+	// blocks to.
+	if len(cfg.miningAddrs) == 0 {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInternal.Code,
+			Message: "No payment addresses specified via " +
+				"--miningaddr",
+		}
+	}
+
+	// Return an error if there are no peers connected since there is no
+	// way to relay a found block or receive transactions to work on.
+	// However, allow this state when running in the regression test or
+	// simulation test mode.
+	if !(cfg.RegressionTest || cfg.SimNet) &&
+		s.cfg.ConnMgr.ConnectedCount() == 0 {
+
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCClientNotConnected,
+			Message: "NavCoin is not connected",
+		}
+	}
+
+	// No point in generating or accepting work before the chain is synced.
+	currentHeight := s.cfg.Chain.BestSnapshot().Height
+	if currentHeight != 0 && !s.cfg.SyncMgr.IsCurrent() {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCClientInInitialDownload,
+			Message: "NavCoin is downloading blocks...",
+		}
+	}
+
+	// Protect concurrent access from multiple RPC invocations for work
+	// requests and submission.
+	state := s.gbtWorkState
+	state.Lock()
+	defer state.Unlock()
+
+	// When the caller provides data, it is a submission of a supposedly
+	// solved block that needs to be checked and submitted to the network
+	// if valid.
+	if c.Data != nil && *c.Data != "" {
+		return handleGetWorkSubmission(s, *c.Data)
+	}
+
+	// No data was provided, so the caller is requesting work.
+	return handleGetWorkRequest(s)
+}
+
 // handleHelp implements the help command.
 func handleHelp(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.HelpCmd)
@@ -2801,6 +3657,20 @@ func handleHelp(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (inter
 	return help, nil
 }
 
+// handleListBanned handles listbanned commands.
+func handleListBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	bans := s.cfg.ConnMgr.BannedSubnets()
+	results := make([]btcjson.ListBannedResult, 0, len(bans))
+	for _, ban := range bans {
+		results = append(results, btcjson.ListBannedResult{
+			Address:     ban.String(),
+			BanCreated:  ban.Created.Unix(),
+			BannedUntil: ban.Until.Unix(),
+		})
+	}
+	return results, nil
+}
+
 // handlePing implements the ping command.
 func handlePing(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Ask server to ping \o_
@@ -3035,6 +3905,28 @@ func fetchMempoolTxnsForAddress(s *rpcServer, addr navutil.Address, numToSkip, n
 	return mpTxns[numToSkip:rangeEnd], numToSkip
 }
 
+// handlePrioritiseTransaction implements the prioritisetransaction command.
+func handlePrioritiseTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.PrioritiseTransactionCmd)
+
+	txHash, err := chainhash.NewHashFromStr(c.Txid)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Txid)
+	}
+
+	// Only fee based adjustments are supported, so the priority delta is
+	// only accepted for compatibility when it has no effect.
+	if c.PriorityDelta != 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Priority delta is not supported and must be 0",
+		}
+	}
+
+	s.cfg.TxMemPool.PrioritiseTransaction(txHash, c.FeeDelta)
+	return true, nil
+}
+
 // handleSearchRawTransactions implements the searchrawtransactions command.
 func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Respond with an error if the address index is not enabled.
@@ -3351,7 +4243,8 @@ func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan st
 	// Also, since an error is being returned to the caller, ensure the
 	// transaction is removed from the memory pool.
 	if len(acceptedTxs) == 0 || !acceptedTxs[0].Tx.Hash().IsEqual(tx.Hash()) {
-		s.cfg.TxMemPool.RemoveTransaction(tx, true)
+		s.cfg.TxMemPool.RemoveTransaction(tx, true,
+			mempool.RemovalUnknown)
 
 		errStr := fmt.Sprintf("transaction %v is not in accepted list",
 			tx.Hash())
@@ -3376,6 +4269,122 @@ func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan st
 	return tx.Hash().String(), nil
 }
 
+// setGenerateSplit returns the coinbase split which results from replacing the
+// payouts and tag of the passed split with the passed payouts and tag when they
+// are not nil.  It returns nil when the resulting split has neither payouts nor
+// a tag.
+func setGenerateSplit(split *mining.CoinbaseSplit, payouts *map[string]uint32, coinbaseTag *string, params *chaincfg.Params) (*mining.CoinbaseSplit, error) {
+	var newSplit mining.CoinbaseSplit
+	if split != nil {
+		newSplit = *split
+	}
+
+	if payouts != nil {
+		// Sort the addresses so the remainder of the coinbase value is
+		// always paid to the same address.
+		addrs := make([]string, 0, len(*payouts))
+		for addr := range *payouts {
+			addrs = append(addrs, addr)
+		}
+		sort.Strings(addrs)
+
+		newSplit.Payouts = make([]mining.CoinbasePayout, 0, len(addrs))
+		for _, strAddr := range addrs {
+			addr, err := navutil.DecodeAddress(strAddr, params)
+			if err != nil {
+				return nil, &btcjson.RPCError{
+					Code: btcjson.ErrRPCInvalidAddressOrKey,
+					Message: "Invalid address or key: " +
+						err.Error(),
+				}
+			}
+			if !addr.IsForNet(params) {
+				return nil, &btcjson.RPCError{
+					Code: btcjson.ErrRPCInvalidAddressOrKey,
+					Message: "Invalid address: " + strAddr +
+						" is for the wrong network",
+				}
+			}
+			newSplit.Payouts = append(newSplit.Payouts,
+				mining.CoinbasePayout{
+					Addr:   addr,
+					Weight: (*payouts)[strAddr],
+				})
+		}
+	}
+	if coinbaseTag != nil {
+		newSplit.Tag = []byte(*coinbaseTag)
+	}
+	if err := newSplit.Validate(); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: err.Error(),
+		}
+	}
+
+	if len(newSplit.Payouts) == 0 && len(newSplit.Tag) == 0 {
+		return nil, nil
+	}
+	return &newSplit, nil
+}
+
+// handleSetBan handles setban commands.
+func handleSetBan(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetBanCmd)
+
+	subnet, err := connmgr.ParseSubnet(c.Subnet)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCClientInvalidIPOrSubnet,
+			Message: err.Error(),
+		}
+	}
+
+	switch c.SubCmd {
+	case btcjson.SBAdd:
+		// The ban time is either the number of seconds to ban the subnet
+		// for or, when absolute, the unix time the ban expires.  A ban
+		// time of zero uses the default ban duration.
+		var banTime int64
+		if c.BanTime != nil {
+			banTime = *c.BanTime
+		}
+		var until time.Time
+		switch {
+		case banTime <= 0:
+			until = time.Now().Add(cfg.BanDuration)
+		case c.Absolute != nil && *c.Absolute:
+			until = time.Unix(banTime, 0)
+		default:
+			until = time.Now().Add(time.Duration(banTime) * time.Second)
+		}
+		if !until.After(time.Now()) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "ban expiry time is in the past",
+			}
+		}
+		s.cfg.ConnMgr.BanSubnet(subnet, until)
+
+	case btcjson.SBRemove:
+		if err := s.cfg.ConnMgr.UnbanSubnet(subnet); err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCClientInvalidIPOrSubnet,
+				Message: err.Error(),
+			}
+		}
+
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "invalid subcommand for setban",
+		}
+	}
+
+	// no data returned unless an error.
+	return nil, nil
+}
+
 // handleSetGenerate implements the setgenerate command.
 func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.SetGenerateCmd)
@@ -3392,6 +4401,17 @@ func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 		generate = false
 	}
 
+	// Update how the value of the coinbase of generated blocks is split
+	// when requested.
+	if c.Payouts != nil || c.CoinbaseTag != nil {
+		split, err := setGenerateSplit(s.cfg.Generator.CoinbaseSplit(),
+			c.Payouts, c.CoinbaseTag, s.cfg.ChainParams)
+		if err != nil {
+			return nil, err
+		}
+		s.cfg.Generator.SetCoinbaseSplit(split)
+	}
+
 	if !generate {
 		s.cfg.CPUMiner.Stop()
 	} else {
@@ -3401,7 +4421,7 @@ func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 			return nil, &btcjson.RPCError{
 				Code: btcjson.ErrRPCInternal.Code,
 				Message: "No payment addresses specified " +
-					"via --miningaddr",
+					"via --miningaddr or --coinbasesplit",
 			}
 		}
 
@@ -3443,17 +4463,123 @@ func handleSubmitBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 		}
 	}
 
+	// Blocks solved from the current block template returned by
+	// getblocktemplate are recorded in the history of generated blocks.
+	// Blocks which don't build on the same block as the template were not
+	// generated from it, so they are not recorded.
+	msgBlock := block.MsgBlock()
+	template := s.gbtWorkState.currentTemplate()
+	if template != nil &&
+		template.Block.Header.PrevBlock != msgBlock.Header.PrevBlock {
+
+		template = nil
+	}
+	recordBlock := func(status mining.BlockStatus) {
+		if template != nil {
+			s.cfg.Generator.RecordBlock(template, block, status)
+		}
+	}
+	isStale := msgBlock.Header.PrevBlock != s.cfg.Chain.BestSnapshot().Hash
+
 	// Process this block using the same rules as blocks coming from other
 	// nodes.  This will in turn relay it to the network like normal.
-	_, err = s.cfg.SyncMgr.SubmitBlock(block, blockchain.BFNone)
+	isOrphan, err := s.cfg.SyncMgr.SubmitBlock(block, blockchain.BFNone)
 	if err != nil {
+		recordBlock(mining.BlockRejected)
 		return fmt.Sprintf("rejected: %s", err.Error()), nil
 	}
+	switch {
+	case isOrphan:
+		recordBlock(mining.BlockRejected)
+	case isStale:
+		recordBlock(mining.BlockStale)
+	default:
+		recordBlock(mining.BlockAccepted)
+	}
 
 	rpcsLog.Infof("Accepted block %s via submitblock", block.Hash())
 	return nil, nil
 }
 
+// handleTestMempoolAccept implements the testmempoolaccept command.
+func handleTestMempoolAccept(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.TestMempoolAcceptCmd)
+
+	if len(c.RawTxns) == 0 ||
+		len(c.RawTxns) > mempool.MaxTestAcceptPackageSize {
+
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Number of transactions must be "+
+				"between 1 and %d", mempool.MaxTestAcceptPackageSize),
+		}
+	}
+	if *c.MaxFeeRate < 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Parameter MaxFeeRate may not be negative",
+		}
+	}
+
+	// Deserialize all of the transactions before testing any of them.
+	txns := make([]*navutil.Tx, 0, len(c.RawTxns))
+	for _, hexStr := range c.RawTxns {
+		if len(hexStr)%2 != 0 {
+			hexStr = "0" + hexStr
+		}
+		serializedTx, err := hex.DecodeString(hexStr)
+		if err != nil {
+			return nil, rpcDecodeHexError(hexStr)
+		}
+		var msgTx wire.MsgTx
+		err = msgTx.Deserialize(bytes.NewReader(serializedTx))
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCDeserialization,
+				Message: "TX decode failed: " + err.Error(),
+			}
+		}
+		txns = append(txns, navutil.NewTx(&msgTx))
+	}
+
+	results := s.cfg.TxMemPool.TestAcceptTransactions(txns)
+	reply := make([]btcjson.TestMempoolAcceptResult, 0, len(results))
+	for _, result := range results {
+		txHash := result.Tx.Hash()
+		item := btcjson.TestMempoolAcceptResult{
+			Txid: txHash.String(),
+		}
+		switch {
+		case result.Err != nil:
+			// Only errors which are not rule errors indicate that
+			// something actually went wrong.
+			if _, ok := result.Err.(mempool.RuleError); !ok {
+				rpcsLog.Errorf("Failed to test transaction %v: %v",
+					txHash, result.Err)
+			}
+			item.RejectReason = result.Err.Error()
+
+		case len(result.MissingParents) > 0:
+			item.RejectReason = "missing-inputs"
+
+		default:
+			vsize := mempool.GetTxVirtualSize(result.Tx)
+			fee := navutil.Amount(result.TxDesc.Fee).ToNAV()
+			feeRate := fee * 1000 / float64(vsize)
+			if *c.MaxFeeRate > 0 && feeRate > *c.MaxFeeRate {
+				item.RejectReason = "max-fee-exceeded"
+				break
+			}
+			item.Allowed = true
+			item.Vsize = vsize
+			item.Fees = &btcjson.TestMempoolAcceptFees{Base: fee}
+		}
+		reply = append(reply, item)
+	}
+
+	return reply, nil
+}
+
 // handleUptime implements the uptime command.
 func handleUptime(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	return time.Now().Unix() - s.cfg.StartupTime, nil
@@ -3721,6 +4847,24 @@ func (s *rpcServer) NotifyNewTransactions(txns []*mempool.TxDesc) {
 	}
 }
 
+// NotifyExpiredTransactions notifies both websocket and getblocktemplate long
+// poll clients of the passed transactions.  This function should be called
+// whenever transactions are evicted from the mempool due to expiry.
+func (s *rpcServer) NotifyExpiredTransactions(txns []*navutil.Tx) {
+	if len(txns) == 0 {
+		return
+	}
+
+	// Notify websocket clients about the expired transactions.
+	for _, tx := range txns {
+		s.ntfnMgr.NotifyMempoolTxExpired(tx)
+	}
+
+	// Potentially notify any getblocktemplate long poll clients about
+	// stale block templates due to the removed transactions.
+	s.gbtWorkState.NotifyMempoolTx(s.cfg.TxMemPool.LastUpdated())
+}
+
 // limitConnections responds with a 503 service unavailable and returns true if
 // adding another client would exceed the maximum allow RPC clients.
 //
@@ -4136,6 +5280,30 @@ type rpcserverPeer interface {
 	// FeeFilter returns the requested current minimum fee rate for which
 	// transactions should be announced.
 	FeeFilter() int64
+
+	// IsWhitelisted returns whether or not the peer is whitelisted.
+	IsWhitelisted() bool
+
+	// SetWhitelisted toggles whether the peer is whitelisted.  Whitelisted
+	// peers are never penalized for misbehaving.
+	SetWhitelisted(whitelisted bool)
+
+	// IsNoBan returns whether or not the peer is exempt from being banned.
+	IsNoBan() bool
+
+	// SetNoBan toggles whether the peer is exempt from being banned.
+	SetNoBan(noBan bool)
+
+	// AddBanScore increases the ban score of the peer by the provided
+	// persistent and transient values for the provided reason, banning
+	// the peer once the ban threshold is exceeded.  It returns the
+	// resulting ban score.
+	AddBanScore(persistent, transient uint32, reason string) uint32
+
+	// MappedAS returns the number of the autonomous system which announces
+	// the address of the peer according to the asmap in use, or zero when
+	// it is unknown.
+	MappedAS() uint32
 }
 
 // rpcserverConnManager represents a connection manager for use with the RPC
@@ -4197,6 +5365,20 @@ type rpcserverConnManager interface {
 	// RelayTransactions generates and relays inventory vectors for all of
 	// the passed transactions to all connected peers.
 	RelayTransactions(txns []*mempool.TxDesc)
+
+	// BanSubnet bans the provided subnet until the provided time and
+	// disconnects all connected peers within it.
+	BanSubnet(subnet *net.IPNet, until time.Time)
+
+	// UnbanSubnet removes the ban of the provided subnet.  Attempting to
+	// remove a subnet that is not banned will return an error.
+	UnbanSubnet(subnet *net.IPNet) error
+
+	// BannedSubnets returns all subnets which are currently banned.
+	BannedSubnets() []connmgr.BanEntry
+
+	// ClearBanned removes all bans.
+	ClearBanned()
 }
 
 // rpcserverSyncManager represents a sync manager for use with the RPC server.
@@ -4328,6 +5510,10 @@ func (s *rpcServer) handleBlockchainNotification(notification *blockchain.Notifi
 		// Notify registered websocket clients of incoming block.
 		s.ntfnMgr.NotifyBlockConnected(block)
 
+		// A generated block which is connected again after it was
+		// orphaned by a reorganization is part of the main chain again.
+		s.cfg.Generator.UpdateBlockStatus(block.Hash(), mining.BlockAccepted)
+
 	case blockchain.NTBlockDisconnected:
 		block, ok := notification.Data.(*navutil.Block)
 		if !ok {
@@ -4337,6 +5523,14 @@ func (s *rpcServer) handleBlockchainNotification(notification *blockchain.Notifi
 
 		// Notify registered websocket clients.
 		s.ntfnMgr.NotifyBlockDisconnected(block)
+
+		// Mark the block as orphaned when it is one the server generated
+		// and notify registered websocket clients.
+		if s.cfg.Generator.UpdateBlockStatus(block.Hash(), mining.BlockOrphaned) {
+			rpcsLog.Infof("Generated block %s (height %d) was orphaned "+
+				"by a reorganization", block.Hash(), block.Height())
+			s.ntfnMgr.NotifyMinedBlockOrphaned(block)
+		}
 	}
 }
 
@@ -0,0 +1,457 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/navcoin/navd/blockchain"
+	"github.com/navcoin/navd/btcjson"
+	"github.com/navcoin/navd/chaincfg"
+	"github.com/navcoin/navd/chaincfg/chainhash"
+	"github.com/navcoin/navd/database"
+	"github.com/navcoin/navd/mining"
+	"github.com/navcoin/navd/txscript"
+	"github.com/navcoin/navd/wire"
+	"github.com/navcoin/navutil"
+)
+
+// TestReverseUint32Array ensures the bytes of every uint32 are reversed.
+func TestReverseUint32Array(t *testing.T) {
+	b := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+	reverseUint32Array(b)
+	want := []byte{0x04, 0x03, 0x02, 0x01, 0x08, 0x07, 0x06, 0x05}
+	if !bytes.Equal(b, want) {
+		t.Fatalf("reverseUint32Array: got %x, want %x", b, want)
+	}
+}
+
+// TestBigToLEUint256 ensures big integers are encoded as little-endian
+// unsigned 256-bit integers and truncated when they do not fit.
+func TestBigToLEUint256(t *testing.T) {
+	tests := []struct {
+		name string
+		n    *big.Int
+		want string
+	}{
+		{
+			name: "zero",
+			n:    big.NewInt(0),
+			want: "0000000000000000000000000000000000000000000000000000000000000000",
+		},
+		{
+			name: "small",
+			n:    big.NewInt(0x0102),
+			want: "0201000000000000000000000000000000000000000000000000000000000000",
+		},
+		{
+			name: "truncated",
+			n:    new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(5)),
+			want: "0500000000000000000000000000000000000000000000000000000000000000",
+		},
+	}
+
+	for _, test := range tests {
+		got := bigToLEUint256(test.n)
+		if hex.EncodeToString(got[:]) != test.want {
+			t.Errorf("%s: got %x, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+// TestGetworkData ensures the getwork data is the padded header with the bytes
+// of every uint32 reversed and that it decodes back to the original header.
+func TestGetworkData(t *testing.T) {
+	header := wire.BlockHeader{
+		Version:    6,
+		PrevBlock:  chainhash.Hash{0x01, 0x02, 0x03, 0x04},
+		MerkleRoot: chainhash.Hash{0x05, 0x06, 0x07, 0x08},
+		Timestamp:  time.Unix(0x5a000000, 0),
+		Bits:       0x1d00ffff,
+		Nonce:      0x01020304,
+	}
+	data, err := getworkData(&header)
+	if err != nil {
+		t.Fatalf("getworkData: %v", err)
+	}
+	if len(data) != getworkDataLen {
+		t.Fatalf("getworkData: got %d bytes, want %d", len(data),
+			getworkDataLen)
+	}
+
+	// The version is the first uint32 and is serialized little-endian, so
+	// it is big-endian once reversed.
+	if !bytes.Equal(data[:4], []byte{0x00, 0x00, 0x00, 0x06}) {
+		t.Fatalf("getworkData: unexpected version bytes %x", data[:4])
+	}
+
+	reverseUint32Array(data)
+	if data[wire.MaxBlockHeaderPayload] != 0x80 {
+		t.Fatalf("getworkData: missing padding bit")
+	}
+	if data[getworkDataLen-2] != 0x02 || data[getworkDataLen-1] != 0x80 {
+		t.Fatalf("getworkData: unexpected message length %x",
+			data[getworkDataLen-8:])
+	}
+
+	var decoded wire.BlockHeader
+	if err := decoded.Deserialize(bytes.NewReader(data)); err != nil {
+		t.Fatalf("Deserialize: %v", err)
+	}
+	if decoded.BlockHash() != header.BlockHash() {
+		t.Fatalf("decoded header mismatch: got %v, want %v",
+			decoded.BlockHash(), header.BlockHash())
+	}
+}
+
+// TestGetworkMidstate ensures the midstate is the sha256 state after the first
+// block by hashing a message which fits in a single padded block, in which
+// case the state is the final digest.
+func TestGetworkMidstate(t *testing.T) {
+	msg := []byte("abc")
+	block := make([]byte, sha256.BlockSize)
+	copy(block, msg)
+	block[len(msg)] = 0x80
+	block[sha256.BlockSize-1] = byte(len(msg) * 8)
+
+	midstate, err := getworkMidstate(block)
+	if err != nil {
+		t.Fatalf("getworkMidstate: %v", err)
+	}
+	reverseUint32Array(midstate)
+	want := sha256.Sum256(msg)
+	if !bytes.Equal(midstate, want[:]) {
+		t.Fatalf("getworkMidstate: got %x, want %x", midstate, want)
+	}
+}
+
+// TestGetworkBlocksLimit ensures only the most recent work handed out by the
+// getwork RPC is kept around.
+func TestGetworkBlocksLimit(t *testing.T) {
+	state := newGbtWorkState(nil)
+	blocks := make([]*wire.MsgBlock, maxGetworkBlocks+10)
+	for i := range blocks {
+		blocks[i] = &wire.MsgBlock{Header: wire.BlockHeader{
+			MerkleRoot: chainhash.Hash{byte(i), byte(i >> 8)},
+		}}
+		state.addGetworkBlock(&mining.BlockTemplate{Block: blocks[i]})
+	}
+
+	if len(state.getworkBlocks) != maxGetworkBlocks {
+		t.Fatalf("got %d getwork blocks, want %d",
+			len(state.getworkBlocks), maxGetworkBlocks)
+	}
+	for i, block := range blocks {
+		_, ok := state.getworkBlocks[block.Header.MerkleRoot]
+		if wantOk := i >= len(blocks)-maxGetworkBlocks; ok != wantOk {
+			t.Fatalf("getwork block %d: got present %v, want %v", i,
+				ok, wantOk)
+		}
+	}
+}
+
+// TestGenerateWrongNetwork ensures the block generation RPCs reject addresses
+// for a different network than the one the server is on.
+func TestGenerateWrongNetwork(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	addr, err := navutil.NewAddressPubKeyHash(make([]byte, 20), params)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash: unexpected error: %v", err)
+	}
+	s := &rpcServer{cfg: rpcserverConfig{
+		ChainParams: &chaincfg.RegressionNetParams,
+	}}
+
+	tests := []struct {
+		name    string
+		handler commandHandler
+		cmd     interface{}
+	}{{
+		name:    "generatetoaddress",
+		handler: handleGenerateToAddress,
+		cmd: btcjson.NewGenerateToAddressCmd(1,
+			addr.EncodeAddress()),
+	}, {
+		name:    "generateblock",
+		handler: handleGenerateBlock,
+		cmd:     btcjson.NewGenerateBlockCmd(addr.EncodeAddress(), nil),
+	}}
+
+	for _, test := range tests {
+		_, err := test.handler(s, test.cmd, nil)
+		rpcErr, ok := err.(*btcjson.RPCError)
+		if !ok || rpcErr.Code != btcjson.ErrRPCInvalidAddressOrKey {
+			t.Fatalf("%s: got error %v, want invalid address",
+				test.name, err)
+		}
+	}
+}
+
+// TestSignetWork ensures the RPCs which hand out work to external miners are
+// refused on signet networks.
+func TestSignetWork(t *testing.T) {
+	params := chaincfg.CustomSignetParams([]byte{txscript.OP_TRUE}, nil)
+	s := &rpcServer{cfg: rpcserverConfig{ChainParams: &params}}
+
+	tests := []struct {
+		name    string
+		handler commandHandler
+		cmd     interface{}
+	}{{
+		name:    "getwork",
+		handler: handleGetWork,
+		cmd:     btcjson.NewGetWorkCmd(nil),
+	}, {
+		name:    "getblocktemplate",
+		handler: handleGetBlockTemplate,
+		cmd:     btcjson.NewGetBlockTemplateCmd(nil),
+	}}
+
+	for _, test := range tests {
+		_, err := test.handler(s, test.cmd, nil)
+		if err != errSignetMining {
+			t.Fatalf("%s: got error %v, want %v", test.name, err,
+				errSignetMining)
+		}
+	}
+}
+
+// TestGbtMutations ensures the mutations advertised in block templates are
+// restricted to the allowed mutations reported by the caller.
+func TestGbtMutations(t *testing.T) {
+	tests := []struct {
+		name         string
+		capabilities []string
+		want         []string
+	}{
+		{
+			name:         "no capabilities",
+			capabilities: nil,
+			want:         gbtMutableFields,
+		},
+		{
+			name:         "no mutations",
+			capabilities: []string{"coinbasetxn", "longpoll"},
+			want:         gbtMutableFields,
+		},
+		{
+			name: "reported mutations",
+			capabilities: []string{"coinbasetxn", "prevblock",
+				"time/increment"},
+			want: []string{"time/increment", "prevblock"},
+		},
+		{
+			name:         "unsupported mutations",
+			capabilities: []string{"version/force", "time"},
+			want:         []string{"time"},
+		},
+	}
+
+	for _, test := range tests {
+		got := gbtMutations(test.capabilities)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// emptyTxSource is a mining.TxSource without any transactions.
+type emptyTxSource struct{}
+
+func (emptyTxSource) LastUpdated() time.Time               { return time.Time{} }
+func (emptyTxSource) MiningDescs() []*mining.TxDesc        { return nil }
+func (emptyTxSource) HaveTransaction(*chainhash.Hash) bool { return false }
+
+// chainSyncManager is an rpcserverSyncManager which submits blocks directly to
+// a chain.  Only SubmitBlock is implemented.
+type chainSyncManager struct {
+	rpcserverSyncManager
+	chain *blockchain.BlockChain
+}
+
+func (m chainSyncManager) SubmitBlock(block *navutil.Block, flags blockchain.BehaviorFlags) (bool, error) {
+	_, isOrphan, err := m.chain.ProcessBlock(block, flags)
+	return isOrphan, err
+}
+
+// newGeneratorTestServer returns an RPC server with a block template generator
+// building on top of a new regression test chain along with the address the
+// templates pay to.  The returned function must be called to remove the chain
+// once the test is done.
+func newGeneratorTestServer(t *testing.T) (*rpcServer, navutil.Address, func()) {
+	// The log rotator is not initialized by the tests, so turn the loggers
+	// off while the server is in use.
+	setLogLevels("off")
+	dbPath, err := ioutil.TempDir("", "rpcgenerator")
+	if err != nil {
+		setLogLevels(defaultLogLevel)
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+
+	// The regression test network retargets every block, so use a long
+	// retarget interval to keep the difficulty at its minimum.
+	params := chaincfg.RegressionNetParams
+	params.TargetTimespan = time.Hour * 24 * 14
+	params.TargetTimePerBlock = time.Minute * 10
+	db, err := database.Create("ffldb", dbPath, params.Net)
+	if err != nil {
+		os.RemoveAll(dbPath)
+		setLogLevels(defaultLogLevel)
+		t.Fatalf("unable to create database: %v", err)
+	}
+	teardown := func() {
+		db.Close()
+		os.RemoveAll(dbPath)
+		setLogLevels(defaultLogLevel)
+	}
+
+	timeSource := blockchain.NewMedianTime()
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: &params,
+		TimeSource:  timeSource,
+	})
+	if err != nil {
+		teardown()
+		t.Fatalf("unable to create chain: %v", err)
+	}
+	addr, err := navutil.NewAddressPubKeyHash(make([]byte, 20), &params)
+	if err != nil {
+		teardown()
+		t.Fatalf("NewAddressPubKeyHash: unexpected error: %v", err)
+	}
+
+	policy := &mining.Policy{BlockMaxWeight: blockchain.MaxBlockWeight - 4000}
+	s := &rpcServer{
+		cfg: rpcserverConfig{
+			Chain:       chain,
+			ChainParams: &params,
+			SyncMgr:     chainSyncManager{chain: chain},
+			Generator: mining.NewBlkTmplGenerator(policy, &params,
+				emptyTxSource{}, chain, timeSource,
+				txscript.NewSigCache(100), nil),
+		},
+		gbtWorkState: newGbtWorkState(timeSource),
+	}
+	return s, addr, teardown
+}
+
+// solveTestBlock finds a nonce for the passed block which satisfies its
+// target.
+func solveTestBlock(msgBlock *wire.MsgBlock) {
+	target := blockchain.CompactToBig(msgBlock.Header.Bits)
+	for nonce := uint32(0); ; nonce++ {
+		msgBlock.Header.Nonce = nonce
+		hash := msgBlock.Header.BlockHash()
+		if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+			return
+		}
+	}
+}
+
+// TestGeneratedBlockHistory ensures blocks solved from the work handed out by
+// getwork and from the getblocktemplate template submitted via submitblock are
+// recorded in the history of generated blocks.
+func TestGeneratedBlockHistory(t *testing.T) {
+	s, addr, teardown := newGeneratorTestServer(t)
+	defer teardown()
+
+	oldCfg := cfg
+	cfg = &config{miningAddrs: []navutil.Address{addr}}
+	defer func() { cfg = oldCfg }()
+
+	checkHistory := func(want ...mining.BlockStatus) {
+		t.Helper()
+		history := s.cfg.Generator.BlockHistory()
+		if len(history) != len(want) {
+			t.Fatalf("got %d generated blocks, want %d", len(history),
+				len(want))
+		}
+		for i, generated := range history {
+			if generated.Status != want[i] {
+				t.Fatalf("generated block %d: got status %v, "+
+					"want %v", i, generated.Status, want[i])
+			}
+		}
+	}
+
+	// Solve the work handed out by getwork and submit it.
+	state := s.gbtWorkState
+	state.Lock()
+	_, err := handleGetWorkRequest(s)
+	if err != nil {
+		state.Unlock()
+		t.Fatalf("handleGetWorkRequest: unexpected error: %v", err)
+	}
+	var header wire.BlockHeader
+	for _, template := range state.getworkBlocks {
+		header = template.Block.Header
+	}
+	msgBlock := wire.MsgBlock{Header: header}
+	solveTestBlock(&msgBlock)
+	data, err := getworkData(&msgBlock.Header)
+	if err != nil {
+		state.Unlock()
+		t.Fatalf("getworkData: unexpected error: %v", err)
+	}
+	accepted, err := handleGetWorkSubmission(s, hex.EncodeToString(data))
+	state.Unlock()
+	if err != nil || accepted != true {
+		t.Fatalf("handleGetWorkSubmission: got %v, %v, want true",
+			accepted, err)
+	}
+	checkHistory(mining.BlockAccepted)
+
+	// Solve the block of the getblocktemplate template and submit it.
+	state.Lock()
+	err = state.updateBlockTemplate(s, false)
+	state.Unlock()
+	if err != nil {
+		t.Fatalf("updateBlockTemplate: unexpected error: %v", err)
+	}
+	submit := func(msgBlock *wire.MsgBlock) {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := msgBlock.Serialize(&buf); err != nil {
+			t.Fatalf("Serialize: unexpected error: %v", err)
+		}
+		cmd := &btcjson.SubmitBlockCmd{
+			HexBlock: hex.EncodeToString(buf.Bytes()),
+		}
+		reply, err := handleSubmitBlock(s, cmd, nil)
+		if err != nil || reply != nil {
+			t.Fatalf("handleSubmitBlock: got %v, %v, want nil",
+				reply, err)
+		}
+	}
+	msgBlock = *state.currentTemplate().Block
+	solveTestBlock(&msgBlock)
+	submit(&msgBlock)
+	checkHistory(mining.BlockAccepted, mining.BlockAccepted)
+
+	// A block solved from the same template which doesn't extend the best
+	// chain anymore is stale.
+	msgBlock.Header.Timestamp = msgBlock.Header.Timestamp.Add(time.Second)
+	solveTestBlock(&msgBlock)
+	submit(&msgBlock)
+	checkHistory(mining.BlockAccepted, mining.BlockAccepted,
+		mining.BlockStale)
+
+	// A block which doesn't build on the same block as the template is not
+	// recorded.
+	msgBlock.Header.PrevBlock = chainhash.Hash{0x01}
+	solveTestBlock(&msgBlock)
+	submit(&msgBlock)
+	checkHistory(mining.BlockAccepted, mining.BlockAccepted,
+		mining.BlockStale)
+}
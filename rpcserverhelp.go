@@ -40,6 +40,28 @@ var helpDescsEnUS = map[string]string{
 	"node-target":        "Either the IP address and port of the peer to operate on, or a valid peer ID.",
 	"node-connectsubcmd": "'perm' to make the connected peer a permanent one, 'temp' to try a single connect to a peer",
 
+	// ClearBannedCmd help.
+	"clearbanned--synopsis": "Removes all banned IP addresses and subnets.",
+
+	// DisconnectNodeCmd help.
+	"disconnectnode--synopsis": "Disconnects a connected peer by address or node id.\n" +
+		"Exactly one of the address and node id must be provided.  Persistent peers are reconnected to afterwards.",
+	"disconnectnode-address": "The IP address and port of the peer to disconnect, or empty when disconnecting by node id",
+	"disconnectnode-nodeid":  "The node id of the peer to disconnect as shown by getpeerinfo",
+
+	// SetPeerPermissionCmd help.
+	"setpeerpermission--synopsis":  "Grants or revokes a permission of a connected peer.",
+	"setpeerpermission-nodeid":     "The node id of the peer as shown by getpeerinfo",
+	"setpeerpermission-permission": "'whitelist' to never penalize the peer for misbehaving, or 'noban' to track the ban score of the peer without banning it",
+	"setpeerpermission-enable":     "Whether to grant or revoke the permission",
+
+	// AddPeerBanScoreCmd help.
+	"addpeerbanscore--synopsis": "Increases the ban score of a connected peer, banning and disconnecting it once the ban threshold is exceeded.",
+	"addpeerbanscore-nodeid":    "The node id of the peer as shown by getpeerinfo",
+	"addpeerbanscore-score":     "The amount to increase the ban score by",
+	"addpeerbanscore-reason":    "The reason for increasing the ban score",
+	"addpeerbanscore--result0":  "The resulting ban score of the peer",
+
 	// TransactionInput help.
 	"transactioninput-txid": "The hash of the input transaction",
 	"transactioninput-vout": "The specific output of the input transaction to redeem",
@@ -124,12 +146,41 @@ var helpDescsEnUS = map[string]string{
 	"estimatefee--result0": "Estimated fee per kilobyte in satoshis for a block to " +
 		"be mined in the next NumBlocks blocks.",
 
+	// EstimateSmartFeeCmd help.
+	"estimatesmartfee--synopsis": "Estimate the fee per kilobyte in navcoins " +
+		"required for a transaction to begin confirmation within a certain " +
+		"number of blocks with high confidence, based on the confirmation " +
+		"success rates of recent fee rates.",
+	"estimatesmartfee-conftarget":   "The number of blocks within which the transaction should begin confirmation",
+	"estimatesmartfee-estimatemode": "The estimate mode: CONSERVATIVE favours fee rates that succeeded over longer periods, ECONOMICAL favours the most recent data",
+
+	// EstimateSmartFeeResult help.
+	"estimatesmartfeeresult-feerate": "Estimated fee per kilobyte in navcoins (omitted if no estimate could be made)",
+	"estimatesmartfeeresult-errors":  "Errors encountered while estimating (omitted if there were none)",
+	"estimatesmartfeeresult-blocks":  "The number of blocks the estimate applies to, which may be lower than requested when not enough blocks have been observed",
+
 	// GenerateCmd help
 	"generate--synopsis": "Generates a set number of blocks (simnet or regtest only) and returns a JSON\n" +
 		" array of their hashes.",
 	"generate-numblocks": "Number of blocks to generate",
 	"generate--result0":  "The hashes, in order, of blocks generated by the call",
 
+	// GenerateToAddressCmd help
+	"generatetoaddress--synopsis": "Generates a set number of blocks paying to the given address (simnet or regtest only) and returns a JSON\n" +
+		" array of their hashes.",
+	"generatetoaddress-numblocks": "Number of blocks to generate",
+	"generatetoaddress-address":   "The address the coinbase of every generated block pays to",
+	"generatetoaddress--result0":  "The hashes, in order, of blocks generated by the call",
+
+	// GenerateBlockCmd help
+	"generateblock--synopsis": "Generates a single block containing exactly the given transactions, in order, and a coinbase paying to the given address (simnet or regtest only).\n" +
+		"The memory pool is not used to select the transactions.",
+	"generateblock-address":      "The address the coinbase of the generated block pays to",
+	"generateblock-transactions": "The transactions to include, each either the id of a transaction in the memory pool or a raw hex-encoded transaction",
+
+	// GenerateBlockResult help
+	"generateblockresult-hash": "The hash of the generated block",
+
 	// GetAddedNodeInfoResultAddr help.
 	"getaddednodeinforesultaddr-address":   "The ip address for this DNS entry",
 	"getaddednodeinforesultaddr-connected": "The connection 'direction' (inbound/outbound/false)",
@@ -276,7 +327,7 @@ var helpDescsEnUS = map[string]string{
 
 	// TemplateRequest help.
 	"templaterequest-mode":         "This is 'template', 'proposal', or omitted",
-	"templaterequest-capabilities": "List of capabilities",
+	"templaterequest-capabilities": "List of capabilities, including 'coinbasetxn' to request a full coinbase transaction and the mutations the caller supports",
 	"templaterequest-longpollid":   "The long poll ID of a job to monitor for expiration; required and valid only for long poll requests ",
 	"templaterequest-sigoplimit":   "Number of signature operations allowed in blocks (this parameter is ignored)",
 	"templaterequest-sizelimit":    "Number of bytes allowed in blocks (this parameter is ignored)",
@@ -316,7 +367,7 @@ var helpDescsEnUS = map[string]string{
 	"getblocktemplateresult-expires":                    "Maximum number of seconds (starting from when the server sent the response) this work is valid for",
 	"getblocktemplateresult-maxtime":                    "Maximum allowed time",
 	"getblocktemplateresult-mintime":                    "Minimum allowed time",
-	"getblocktemplateresult-mutable":                    "List of mutations the server explicitly allows",
+	"getblocktemplateresult-mutable":                    "List of mutations the server explicitly allows, restricted to those reported in the capabilities when any are",
 	"getblocktemplateresult-noncerange":                 "Two concatenated hex-encoded big-endian 32-bit integers which represent the valid ranges of nonces the miner may scan",
 	"getblocktemplateresult-capabilities":               "List of server capabilities including 'proposal' to indicate support for block proposals",
 	"getblocktemplateresult-reject-reason":              "Reason the proposal was invalid as-is (only applies to proposal responses)",
@@ -404,22 +455,49 @@ var helpDescsEnUS = map[string]string{
 	"getmempoolinforesult-size":  "Number of transactions in the mempool",
 
 	// GetMiningInfoResult help.
-	"getmininginforesult-blocks":             "Height of the latest best block",
-	"getmininginforesult-currentblocksize":   "Size of the latest best block",
-	"getmininginforesult-currentblockweight": "Weight of the latest best block",
-	"getmininginforesult-currentblocktx":     "Number of transactions in the latest best block",
-	"getmininginforesult-difficulty":         "Current target difficulty",
-	"getmininginforesult-errors":             "Any current errors",
-	"getmininginforesult-generate":           "Whether or not server is set to generate coins",
-	"getmininginforesult-genproclimit":       "Number of processors to use for coin generation (-1 when disabled)",
-	"getmininginforesult-hashespersec":       "Recent hashes per second performance measurement while generating coins",
-	"getmininginforesult-networkhashps":      "Estimated network hashes per second for the most recent blocks",
-	"getmininginforesult-pooledtx":           "Number of transactions in the memory pool",
-	"getmininginforesult-testnet":            "Whether or not server is using testnet",
+	"getmininginforesult-blocks":               "Height of the latest best block",
+	"getmininginforesult-currentblocksize":     "Size of the latest best block",
+	"getmininginforesult-currentblockweight":   "Weight of the latest best block",
+	"getmininginforesult-currentblocktx":       "Number of transactions in the latest best block",
+	"getmininginforesult-difficulty":           "Current target difficulty",
+	"getmininginforesult-errors":               "Any current errors",
+	"getmininginforesult-generate":             "Whether or not server is set to generate coins",
+	"getmininginforesult-genproclimit":         "Number of processors to use for coin generation (-1 when disabled)",
+	"getmininginforesult-hashespersec":         "Recent hashes per second performance measurement while generating coins",
+	"getmininginforesult-networkhashps":        "Estimated network hashes per second for the most recent blocks",
+	"getmininginforesult-pooledtx":             "Number of transactions in the memory pool",
+	"getmininginforesult-templatebuildtime":    "Seconds taken to build the most recent block template",
+	"getmininginforesult-avgtemplatebuildtime": "Average seconds taken to build a block template",
+	"getmininginforesult-testnet":              "Whether or not server is using testnet",
 
 	// GetMiningInfoCmd help.
 	"getmininginfo--synopsis": "Returns a JSON object containing mining-related information.",
 
+	// GeneratedBlockResult help.
+	"generatedblockresult-hash":          "The hash of the block",
+	"generatedblockresult-height":        "The height of the block",
+	"generatedblockresult-time":          "When the block was submitted in seconds since 1 Jan 1970 GMT",
+	"generatedblockresult-buildtime":     "Seconds taken to build the block template the block was solved from",
+	"generatedblockresult-fees":          "The total fees collected by the block in NAV",
+	"generatedblockresult-txcount":       "The number of transactions in the block including the coinbase",
+	"generatedblockresult-status":        "The outcome of the block (accepted, orphaned, stale or rejected)",
+	"generatedblockresult-confirmations": "The number of confirmations of the block if it is accepted, otherwise 0",
+
+	// GetMiningStatsResult help.
+	"getminingstatsresult-templatebuilds":       "Number of block templates built",
+	"getminingstatsresult-templatesreused":      "Number of block templates handed out again because neither the best chain nor the memory pool changed",
+	"getminingstatsresult-fullrebuilds":         "Number of block templates which had to gather every transaction in the memory pool",
+	"getminingstatsresult-templatebuildtime":    "Seconds taken to build the most recent block template",
+	"getminingstatsresult-avgtemplatebuildtime": "Average seconds taken to build a block template",
+	"getminingstatsresult-accepted":             "Number of blocks in the history which are accepted",
+	"getminingstatsresult-orphaned":             "Number of blocks in the history which were orphaned by a reorganization",
+	"getminingstatsresult-stale":                "Number of blocks in the history which became stale before they were submitted",
+	"getminingstatsresult-rejected":             "Number of blocks in the history which were rejected",
+	"getminingstatsresult-blocks":               "The most recently generated blocks from oldest to newest",
+
+	// GetMiningStatsCmd help.
+	"getminingstats--synopsis": "Returns statistics about the block templates built by the server and a rolling history of the blocks it generated.",
+
 	// GetNetworkHashPSCmd help.
 	"getnetworkhashps--synopsis": "Returns the estimated network hashes per second for the block heights provided by the parameters.",
 	"getnetworkhashps-blocks":    "The number of blocks, or -1 for blocks since last difficulty change",
@@ -435,27 +513,29 @@ var helpDescsEnUS = map[string]string{
 	"getnettotalsresult-timemillis":     "Number of milliseconds since 1 Jan 1970 GMT",
 
 	// GetPeerInfoResult help.
-	"getpeerinforesult-id":             "A unique node ID",
-	"getpeerinforesult-addr":           "The ip address and port of the peer",
-	"getpeerinforesult-addrlocal":      "Local address",
-	"getpeerinforesult-services":       "Services bitmask which represents the services supported by the peer",
-	"getpeerinforesult-relaytxes":      "Peer has requested transactions be relayed to it",
-	"getpeerinforesult-lastsend":       "Time the last message was received in seconds since 1 Jan 1970 GMT",
-	"getpeerinforesult-lastrecv":       "Time the last message was sent in seconds since 1 Jan 1970 GMT",
-	"getpeerinforesult-bytessent":      "Total bytes sent",
-	"getpeerinforesult-bytesrecv":      "Total bytes received",
-	"getpeerinforesult-conntime":       "Time the connection was made in seconds since 1 Jan 1970 GMT",
-	"getpeerinforesult-timeoffset":     "The time offset of the peer",
-	"getpeerinforesult-pingtime":       "Number of microseconds the last ping took",
-	"getpeerinforesult-pingwait":       "Number of microseconds a queued ping has been waiting for a response",
-	"getpeerinforesult-version":        "The protocol version of the peer",
-	"getpeerinforesult-subver":         "The user agent of the peer",
-	"getpeerinforesult-inbound":        "Whether or not the peer is an inbound connection",
-	"getpeerinforesult-startingheight": "The latest block height the peer knew about when the connection was established",
-	"getpeerinforesult-currentheight":  "The current height of the peer",
-	"getpeerinforesult-banscore":       "The ban score",
-	"getpeerinforesult-feefilter":      "The requested minimum fee a transaction must have to be announced to the peer",
-	"getpeerinforesult-syncnode":       "Whether or not the peer is the sync peer",
+	"getpeerinforesult-id":                "A unique node ID",
+	"getpeerinforesult-addr":              "The ip address and port of the peer",
+	"getpeerinforesult-addrlocal":         "Local address",
+	"getpeerinforesult-services":          "Services bitmask which represents the services supported by the peer",
+	"getpeerinforesult-relaytxes":         "Peer has requested transactions be relayed to it",
+	"getpeerinforesult-lastsend":          "Time the last message was received in seconds since 1 Jan 1970 GMT",
+	"getpeerinforesult-lastrecv":          "Time the last message was sent in seconds since 1 Jan 1970 GMT",
+	"getpeerinforesult-bytessent":         "Total bytes sent",
+	"getpeerinforesult-bytesrecv":         "Total bytes received",
+	"getpeerinforesult-conntime":          "Time the connection was made in seconds since 1 Jan 1970 GMT",
+	"getpeerinforesult-timeoffset":        "The time offset of the peer",
+	"getpeerinforesult-pingtime":          "Number of microseconds the last ping took",
+	"getpeerinforesult-pingwait":          "Number of microseconds a queued ping has been waiting for a response",
+	"getpeerinforesult-version":           "The protocol version of the peer",
+	"getpeerinforesult-subver":            "The user agent of the peer",
+	"getpeerinforesult-inbound":           "Whether or not the peer is an inbound connection",
+	"getpeerinforesult-startingheight":    "The latest block height the peer knew about when the connection was established",
+	"getpeerinforesult-currentheight":     "The current height of the peer",
+	"getpeerinforesult-banscore":          "The ban score",
+	"getpeerinforesult-feefilter":         "The requested minimum fee a transaction must have to be announced to the peer",
+	"getpeerinforesult-syncnode":          "Whether or not the peer is the sync peer",
+	"getpeerinforesult-transportprotocol": "The transport protocol used for the connection (v1, v2 or detecting while it is negotiated)",
+	"getpeerinforesult-mappedas":          "The number of the autonomous system announcing the address of the peer according to the asmap in use (omitted when unknown)",
 
 	// GetPeerInfoCmd help.
 	"getpeerinfo--synopsis": "Returns data about each connected network peer as an array of json objects.",
@@ -463,6 +543,7 @@ var helpDescsEnUS = map[string]string{
 	// GetRawMempoolVerboseResult help.
 	"getrawmempoolverboseresult-size":             "Transaction size in bytes",
 	"getrawmempoolverboseresult-fee":              "Transaction fee in navcoins",
+	"getrawmempoolverboseresult-modifiedfee":      "Transaction fee in navcoins including any fee delta set with prioritisetransaction",
 	"getrawmempoolverboseresult-time":             "Local time transaction entered pool in seconds since 1 Jan 1970 GMT",
 	"getrawmempoolverboseresult-height":           "Block height when transaction entered the pool",
 	"getrawmempoolverboseresult-startingpriority": "Priority when transaction entered the pool",
@@ -499,6 +580,20 @@ var helpDescsEnUS = map[string]string{
 	"gettxout-vout":           "The index of the output",
 	"gettxout-includemempool": "Include the mempool when true",
 
+	// GetWorkResult help.
+	"getworkresult-data":     "Hex-encoded block header with the internal sha256 padding and the bytes of each uint32 reversed",
+	"getworkresult-hash1":    "Hex-encoded formatted hash buffer (deprecated)",
+	"getworkresult-midstate": "Hex-encoded precomputed sha256 state of the first half of the data (deprecated)",
+	"getworkresult-target":   "Hex-encoded little-endian number which the block hash must be less than or equal to",
+
+	// GetWorkCmd help.
+	"getwork--synopsis": "Returns formatted hash data to work on or checks and submits solved data.\n" +
+		"Block headers with a version of 6 or less are hashed with X13 and the rest with double sha256.",
+	"getwork-data":        "Hex-encoded data returned by a previous getwork call with the nonce and time of the solution filled in",
+	"getwork--condition0": "no data provided",
+	"getwork--condition1": "data provided",
+	"getwork--result1":    "Whether or not the solved data was accepted as a block",
+
 	// HelpCmd help.
 	"help--synopsis":   "Returns a list of all commands or help for a specified command.",
 	"help-command":     "The command to retrieve help for",
@@ -507,10 +602,26 @@ var helpDescsEnUS = map[string]string{
 	"help--result0":    "List of commands",
 	"help--result1":    "Help for specified command",
 
+	// ListBannedCmd help.
+	"listbanned--synopsis": "Returns the banned IP addresses and subnets.",
+
+	// ListBannedResult help.
+	"listbannedresult-address":      "The banned IP address or subnet",
+	"listbannedresult-ban_created":  "The time the ban was created in seconds since 1 Jan 1970 GMT",
+	"listbannedresult-banned_until": "The time the ban expires in seconds since 1 Jan 1970 GMT",
+
 	// PingCmd help.
 	"ping--synopsis": "Queues a ping to be sent to each connected peer.\n" +
 		"Ping times are provided by getpeerinfo via the pingtime and pingwait fields.",
 
+	// PrioritiseTransactionCmd help.
+	"prioritisetransaction--synopsis": "Adjusts the fee used to order a transaction for inclusion in generated blocks.\n" +
+		"The adjustment accumulates across calls, may be set before the transaction enters the memory pool and does not change the fee actually paid.",
+	"prioritisetransaction-txid":          "The hash of the transaction",
+	"prioritisetransaction-prioritydelta": "Unsupported priority adjustment which must be 0",
+	"prioritisetransaction-feedelta":      "The fee adjustment in satoshi to add to (or subtract from when negative) the transaction fee",
+	"prioritisetransaction--result0":      "Always true",
+
 	// SearchRawTransactionsCmd help.
 	"searchrawtransactions--synopsis": "Returns raw data for transactions involving the passed address.\n" +
 		"Returned transactions are pulled from both the database, and transactions currently in the mempool.\n" +
@@ -534,10 +645,22 @@ var helpDescsEnUS = map[string]string{
 	"sendrawtransaction-allowhighfees": "Whether or not to allow insanely high fees (navd does not yet implement this parameter, so it has no effect)",
 	"sendrawtransaction--result0":      "The hash of the transaction",
 
+	// SetBanCmd help.
+	"setban--synopsis": "Bans an IP address or subnet, disconnecting the connected peers within it, or removes a ban.",
+	"setban-subnet":    "The IP address or subnet in CIDR notation (for example 10.0.0.0/8) to operate on",
+	"setban-subcmd":    "'add' to ban the IP address or subnet or 'remove' to remove its ban",
+	"setban-bantime":   "The number of seconds to ban for, or 0 for the default ban duration (only for 'add')",
+	"setban-absolute":  "Whether the ban time is the unix time the ban expires instead of a number of seconds",
+
 	// SetGenerateCmd help.
-	"setgenerate--synopsis":    "Set the server to generate coins (mine) or not.",
-	"setgenerate-generate":     "Use true to enable generation, false to disable it",
-	"setgenerate-genproclimit": "The number of processors (cores) to limit generation to or -1 for default",
+	"setgenerate--synopsis":      "Set the server to generate coins (mine) or not.",
+	"setgenerate-generate":       "Use true to enable generation, false to disable it",
+	"setgenerate-genproclimit":   "The number of processors (cores) to limit generation to or -1 for default",
+	"setgenerate-payouts":        "Split the value of the coinbase of generated blocks across up to 16 of these addresses in proportion to their weights, or pay it to a single mining address when empty",
+	"setgenerate-coinbasetag":    "Text added to the coinbase of generated blocks in an OP_RETURN output, or empty to remove it",
+	"setgenerate-payouts--key":   "address",
+	"setgenerate-payouts--value": "weight",
+	"setgenerate-payouts--desc":  "The address to weight mapping",
 
 	// StopCmd help.
 	"stop--synopsis": "Shutdown navd.",
@@ -554,6 +677,22 @@ var helpDescsEnUS = map[string]string{
 	"submitblock--condition1": "Block rejected",
 	"submitblock--result1":    "The reason the block was rejected",
 
+	// TestMempoolAcceptFees help.
+	"testmempoolacceptfees-base": "The transaction fee in NAV",
+
+	// TestMempoolAcceptResult help.
+	"testmempoolacceptresult-txid":          "The hash of the transaction",
+	"testmempoolacceptresult-allowed":       "Whether or not the transaction would be accepted into the memory pool",
+	"testmempoolacceptresult-vsize":         "The virtual size of the transaction (only when allowed)",
+	"testmempoolacceptresult-fees":          "The fees paid by the transaction (only when allowed)",
+	"testmempoolacceptresult-reject-reason": "The reason the transaction would be rejected (only when not allowed)",
+
+	// TestMempoolAcceptCmd help.
+	"testmempoolaccept--synopsis": "Returns whether or not the serialized, hex-encoded transactions would be accepted into the memory pool without adding or relaying them.\n" +
+		"The transactions are tested in order as a package, so later transactions may spend the outputs of earlier ones.",
+	"testmempoolaccept-rawtxns":    "Serialized, hex-encoded signed transactions (at most 25)",
+	"testmempoolaccept-maxfeerate": "Reject transactions whose fee rate exceeds this value in NAV/kB (0 for no limit)",
+
 	// ValidateAddressResult help.
 	"validateaddresschainresult-isvalid": "Whether or not the address is valid",
 	"validateaddresschainresult-address": "The navcoin address (only when isvalid is true)",
@@ -668,12 +807,18 @@ var helpDescsEnUS = map[string]string{
 // pointer to the type (or nil to indicate no return value).
 var rpcResultTypes = map[string][]interface{}{
 	"addnode":               nil,
+	"addpeerbanscore":       {(*uint32)(nil)},
+	"clearbanned":           nil,
 	"createrawtransaction":  {(*string)(nil)},
 	"debuglevel":            {(*string)(nil), (*string)(nil)},
 	"decoderawtransaction":  {(*btcjson.TxRawDecodeResult)(nil)},
 	"decodescript":          {(*btcjson.DecodeScriptResult)(nil)},
+	"disconnectnode":        nil,
 	"estimatefee":           {(*float64)(nil)},
+	"estimatesmartfee":      {(*btcjson.EstimateSmartFeeResult)(nil)},
 	"generate":              {(*[]string)(nil)},
+	"generateblock":         {(*btcjson.GenerateBlockResult)(nil)},
+	"generatetoaddress":     {(*[]string)(nil)},
 	"getaddednodeinfo":      {(*[]string)(nil), (*[]btcjson.GetAddedNodeInfoResult)(nil)},
 	"getbestblock":          {(*btcjson.GetBestBlockResult)(nil)},
 	"getbestblockhash":      {(*string)(nil)},
@@ -693,20 +838,27 @@ var rpcResultTypes = map[string][]interface{}{
 	"getinfo":               {(*btcjson.InfoChainResult)(nil)},
 	"getmempoolinfo":        {(*btcjson.GetMempoolInfoResult)(nil)},
 	"getmininginfo":         {(*btcjson.GetMiningInfoResult)(nil)},
+	"getminingstats":        {(*btcjson.GetMiningStatsResult)(nil)},
 	"getnettotals":          {(*btcjson.GetNetTotalsResult)(nil)},
 	"getnetworkhashps":      {(*int64)(nil)},
 	"getpeerinfo":           {(*[]btcjson.GetPeerInfoResult)(nil)},
 	"getrawmempool":         {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil)},
 	"getrawtransaction":     {(*string)(nil), (*btcjson.TxRawResult)(nil)},
 	"gettxout":              {(*btcjson.GetTxOutResult)(nil)},
+	"getwork":               {(*btcjson.GetWorkResult)(nil), (*bool)(nil)},
 	"node":                  nil,
 	"help":                  {(*string)(nil), (*string)(nil)},
+	"listbanned":            {(*[]btcjson.ListBannedResult)(nil)},
 	"ping":                  nil,
+	"prioritisetransaction": {(*bool)(nil)},
 	"searchrawtransactions": {(*string)(nil), (*[]btcjson.SearchRawTransactionsResult)(nil)},
 	"sendrawtransaction":    {(*string)(nil)},
+	"setban":                nil,
 	"setgenerate":           nil,
+	"setpeerpermission":     nil,
 	"stop":                  {(*string)(nil)},
 	"submitblock":           {nil, (*string)(nil)},
+	"testmempoolaccept":     {(*[]btcjson.TestMempoolAcceptResult)(nil)},
 	"uptime":                {(*int64)(nil)},
 	"validateaddress":       {(*btcjson.ValidateAddressChainResult)(nil)},
 	"verifychain":           {(*bool)(nil)},
@@ -227,6 +227,19 @@ func (m *wsNotificationManager) NotifyBlockDisconnected(block *navutil.Block) {
 	}
 }
 
+// NotifyMinedBlockOrphaned passes a block generated by the server which was
+// disconnected from the best chain by a reorganization to the notification
+// manager for block notification processing.
+func (m *wsNotificationManager) NotifyMinedBlockOrphaned(block *navutil.Block) {
+	// As with NotifyBlockDisconnected, use a select statement to unblock
+	// enqueuing the notification once the RPC server has begun shutting
+	// down.
+	select {
+	case m.queueNotification <- (*notificationMinedBlockOrphaned)(block):
+	case <-m.quit:
+	}
+}
+
 // NotifyMempoolTx passes a transaction accepted by mempool to the
 // notification manager for transaction notification processing.  If
 // isNew is true, the tx is is a new transaction, rather than one
@@ -247,6 +260,19 @@ func (m *wsNotificationManager) NotifyMempoolTx(tx *navutil.Tx, isNew bool) {
 	}
 }
 
+// NotifyMempoolTxExpired passes a transaction which was evicted from the
+// memory pool due to expiry to the notification manager for transaction
+// notification processing.
+func (m *wsNotificationManager) NotifyMempoolTxExpired(tx *navutil.Tx) {
+	// As with NotifyMempoolTx, use a select statement to unblock
+	// enqueuing the notification once the RPC server has begun shutting
+	// down.
+	select {
+	case m.queueNotification <- (*notificationTxExpiredFromMempool)(tx):
+	case <-m.quit:
+	}
+}
+
 // wsClientFilter tracks relevant addresses for each websocket client for
 // the `rescanblocks` extension. It is modified by the `loadtxfilter` command.
 //
@@ -448,10 +474,12 @@ func (f *wsClientFilter) removeUnspentOutPoint(op *wire.OutPoint) {
 // Notification types
 type notificationBlockConnected navutil.Block
 type notificationBlockDisconnected navutil.Block
+type notificationMinedBlockOrphaned navutil.Block
 type notificationTxAcceptedByMempool struct {
 	isNew bool
 	tx    *navutil.Tx
 }
+type notificationTxExpiredFromMempool navutil.Tx
 
 // Notification control requests
 type notificationRegisterClient wsClient
@@ -533,6 +561,12 @@ out:
 						block)
 				}
 
+			case *notificationMinedBlockOrphaned:
+				if len(blockNotifications) != 0 {
+					m.notifyMinedBlockOrphaned(blockNotifications,
+						(*navutil.Block)(n))
+				}
+
 			case *notificationTxAcceptedByMempool:
 				if n.isNew && len(txNotifications) != 0 {
 					m.notifyForNewTx(txNotifications, n.tx)
@@ -540,6 +574,12 @@ out:
 				m.notifyForTx(watchedOutPoints, watchedAddrs, n.tx, nil)
 				m.notifyRelevantTxAccepted(n.tx, clients)
 
+			case *notificationTxExpiredFromMempool:
+				if len(txNotifications) != 0 {
+					m.notifyTxExpired(txNotifications,
+						(*navutil.Tx)(n))
+				}
+
 			case *notificationRegisterBlocks:
 				wsc := (*wsClient)(n)
 				blockNotifications[wsc.quit] = wsc
@@ -869,6 +909,38 @@ func (m *wsNotificationManager) notifyForNewTx(clients map[chan struct{}]*wsClie
 	}
 }
 
+// notifyMinedBlockOrphaned notifies websocket clients that have registered for
+// block updates when a block generated by the server is orphaned by a
+// reorganization.
+func (*wsNotificationManager) notifyMinedBlockOrphaned(clients map[chan struct{}]*wsClient, block *navutil.Block) {
+	ntfn := btcjson.NewMinedBlockOrphanedNtfn(block.Hash().String(),
+		block.Height())
+	marshalledJSON, err := btcjson.MarshalCmd(nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal mined block orphaned "+
+			"notification: %v", err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
+// notifyTxExpired notifies websocket clients that have registered for updates
+// when a transaction is evicted from the memory pool due to expiry.
+func (*wsNotificationManager) notifyTxExpired(clients map[chan struct{}]*wsClient, tx *navutil.Tx) {
+	ntfn := btcjson.NewTxExpiredNtfn(tx.Hash().String())
+	marshalledJSON, err := btcjson.MarshalCmd(nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal tx expired notification: %v",
+			err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
 // RegisterSpentRequests requests a notification when each of the passed
 // outpoints is confirmed spent (contained in a block connected to the main
 // chain) for the passed websocket client.  The request is automatically
@@ -14,6 +14,8 @@ import (
 	"fmt"
 	"math"
 	"net"
+	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
@@ -29,9 +31,11 @@ import (
 	"github.com/navcoin/navd/chaincfg/chainhash"
 	"github.com/navcoin/navd/connmgr"
 	"github.com/navcoin/navd/database"
+	"github.com/navcoin/navd/eventpub"
 	"github.com/navcoin/navd/mempool"
 	"github.com/navcoin/navd/mining"
 	"github.com/navcoin/navd/mining/cpuminer"
+	"github.com/navcoin/navd/mining/stratum"
 	"github.com/navcoin/navd/netsync"
 	"github.com/navcoin/navd/peer"
 	"github.com/navcoin/navd/txscript"
@@ -57,6 +61,34 @@ const (
 	// retries when connecting to persistent peers.  It is adjusted by the
 	// number of retries such that there is a retry backoff.
 	connectionRetryInterval = time.Second * 5
+
+	// mempoolExpireScanInterval is the amount of time in between scans of
+	// the transaction memory pool to evict expired transactions.
+	mempoolExpireScanInterval = time.Minute * 5
+
+	// maxV1OnlyAddrs is the maximum number of addresses of outbound peers
+	// which failed the v2 transport handshake to remember.  An arbitrary
+	// one is forgotten to make room for a new one once it is reached.
+	maxV1OnlyAddrs = 1000
+
+	// maxCmpctBlockDepth is the depth from the best chain tip from which
+	// blocks are served in full instead of as compact blocks since peers
+	// are unlikely to have their transactions in the memory pool.
+	maxCmpctBlockDepth = 10
+
+	// banListFilename is the name of the file in the data directory which
+	// houses the banned IP addresses and subnets.
+	banListFilename = "banlist.json"
+
+	// anchorsFilename is the name of the file in the data directory which
+	// houses the addresses of the block-relay-only peers connected at
+	// shutdown, which are connected to first at startup.
+	anchorsFilename = "anchors.dat"
+
+	// defaultBlockRelayOnlyOutbound is the default number of
+	// block-relay-only outbound peers to maintain in addition to the
+	// outbound peers which relay transactions and addresses.
+	defaultBlockRelayOnlyOutbound = 2
 )
 
 var (
@@ -72,28 +104,6 @@ var (
 // zeroHash is the zero value hash (all zeros).  It is defined as a convenience.
 var zeroHash chainhash.Hash
 
-// onionAddr implements the net.Addr interface and represents a tor address.
-type onionAddr struct {
-	addr string
-}
-
-// String returns the onion address.
-//
-// This is part of the net.Addr interface.
-func (oa *onionAddr) String() string {
-	return oa.addr
-}
-
-// Network returns "onion".
-//
-// This is part of the net.Addr interface.
-func (oa *onionAddr) Network() string {
-	return "onion"
-}
-
-// Ensure onionAddr implements the net.Addr interface.
-var _ net.Addr = (*onionAddr)(nil)
-
 // onionAddr implements the net.Addr interface with two struct fields
 type simpleAddr struct {
 	net, addr string
@@ -136,6 +146,7 @@ type broadcastInventoryDel *wire.InvVect
 type relayMsg struct {
 	invVect *wire.InvVect
 	data    interface{}
+	torOnly bool
 }
 
 // updatePeerHeightsMsg is a message sent from the blockmanager to the server
@@ -151,12 +162,11 @@ type updatePeerHeightsMsg struct {
 }
 
 // peerState maintains state of inbound, persistent, outbound peers as well
-// as banned peers and outbound groups.
+// as outbound groups.
 type peerState struct {
 	inboundPeers    map[int32]*serverPeer
 	outboundPeers   map[int32]*serverPeer
 	persistentPeers map[int32]*serverPeer
-	banned          map[string]time.Time
 	outboundGroups  map[string]int
 }
 
@@ -186,6 +196,18 @@ func (ps *peerState) forAllPeers(closure func(sp *serverPeer)) {
 	ps.forAllOutboundPeers(closure)
 }
 
+// hasTorPeer returns whether any of the connected peers known to peerState is
+// reached over Tor.
+func (ps *peerState) hasTorPeer() bool {
+	var found bool
+	ps.forAllPeers(func(sp *serverPeer) {
+		if !found && sp.Connected() && sp.reachedOverTor() {
+			found = true
+		}
+	})
+	return found
+}
+
 // server provides a navcoin server for handling communications to and from
 // navcoin peers.
 type server struct {
@@ -223,6 +245,25 @@ type server struct {
 	timeSource           blockchain.MedianTimeSource
 	services             wire.ServiceFlag
 
+	// evictionSeed keys the network groups of inbound peers when selecting
+	// a peer to evict, so which groups are protected is unpredictable.
+	evictionSeed uint64
+
+	// banList houses the banned IP addresses and subnets.  It is persisted
+	// to the data directory so bans survive restarts.
+	banList *connmgr.BanList
+
+	// anchors houses the addresses of the block-relay-only peers connected
+	// at the previous shutdown which remain to be connected to.
+	anchorsMtx sync.Mutex
+	anchors    []*wire.NetAddress
+
+	// v1OnlyAddrs houses the addresses of outbound peers whose v2 transport
+	// handshake failed, so they are connected to using the v1 transport
+	// protocol from then on.
+	v1OnlyMtx   sync.Mutex
+	v1OnlyAddrs map[string]struct{}
+
 	// The following fields are used for optional indexes.  They will be nil
 	// if the associated index is not enabled.  These fields are set during
 	// initial creation of the server and never changed afterwards, so they
@@ -234,13 +275,24 @@ type server struct {
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
 	feeEstimator *mempool.FeeEstimator
+
+	// eventPub publishes mempool and block events to subscribers.  It is
+	// nil when no event publisher listeners are configured.
+	eventPub *eventpub.Publisher
+
+	// stratumServer serves block templates to Stratum miners.  It is nil
+	// when no Stratum listeners are configured.
+	stratumServer *stratum.Server
 }
 
 // serverPeer extends the peer to maintain state shared by the server and
 // the blockmanager.
 type serverPeer struct {
 	// The following variables must only be used atomically
-	feeFilter int64
+	feeFilter     int64
+	minPingMicros int64
+	lastTxTime    int64
+	lastBlockTime int64
 
 	*peer.Peer
 
@@ -251,10 +303,14 @@ type serverPeer struct {
 	relayMtx       sync.Mutex
 	disableRelayTx bool
 	sentAddrs      bool
+	permMtx        sync.Mutex
 	isWhitelisted  bool
+	isNoBan        bool
 	filter         *bloom.Filter
 	knownAddresses map[string]struct{}
 	banScore       connmgr.DynamicBanScore
+	v2Transport    bool
+	blockRelayOnly bool
 	quit           chan struct{}
 	// The following chans are used to sync blockmanager and server.
 	txProcessed    chan struct{}
@@ -296,6 +352,16 @@ func (sp *serverPeer) addressKnown(na *wire.NetAddress) bool {
 	return exists
 }
 
+// txInvVect returns the inventory vector used to announce the given transaction
+// to the peer, which is by witness hash when the peer negotiated it (BIP0339)
+// and by hash otherwise.
+func (sp *serverPeer) txInvVect(tx *navutil.Tx) *wire.InvVect {
+	if sp.WTxIDRelay() {
+		return wire.NewInvVect(wire.InvTypeWTx, tx.WitnessHash())
+	}
+	return wire.NewInvVect(wire.InvTypeTx, tx.Hash())
+}
+
 // setDisableRelayTx toggles relaying of transactions for the given peer.
 // It is safe for concurrent access.
 func (sp *serverPeer) setDisableRelayTx(disable bool) {
@@ -305,19 +371,82 @@ func (sp *serverPeer) setDisableRelayTx(disable bool) {
 }
 
 // relayTxDisabled returns whether or not relaying of transactions for the given
-// peer is disabled.
+// peer is disabled.  It is always disabled for block-relay-only peers.
 // It is safe for concurrent access.
 func (sp *serverPeer) relayTxDisabled() bool {
 	sp.relayMtx.Lock()
-	isDisabled := sp.disableRelayTx
+	isDisabled := sp.disableRelayTx || sp.blockRelayOnly
 	sp.relayMtx.Unlock()
 
 	return isDisabled
 }
 
+// reachedOverTor returns whether or not the connection to the given peer goes
+// through Tor.  That is the case for peers with onion addresses and for all
+// outbound peers when the general proxy is treated as Tor.
+func (sp *serverPeer) reachedOverTor() bool {
+	na := sp.NA()
+	if na != nil && (addrmgr.IsOnionCatTor(na) || addrmgr.IsTorV3(na)) {
+		return true
+	}
+	return !sp.Inbound() && cfg.Proxy != "" && cfg.OnionProxy == "" &&
+		!cfg.NoOnion
+}
+
+// v2HandshakeFailed returns whether the given peer is an outbound peer which
+// refused the v2 transport handshake the way peers which only support the v1
+// transport protocol do.  Other disconnects before the handshake completed,
+// such as timeouts, don't count.
+func (sp *serverPeer) v2HandshakeFailed() bool {
+	return sp.v2Transport && !sp.Inbound() && sp.V2TransportRefused()
+}
+
+// setWhitelisted toggles whether the given peer is whitelisted.  Whitelisted
+// peers are never penalized for misbehaving.
+// It is safe for concurrent access.
+func (sp *serverPeer) setWhitelisted(whitelisted bool) {
+	sp.permMtx.Lock()
+	sp.isWhitelisted = whitelisted
+	sp.permMtx.Unlock()
+}
+
+// whitelisted returns whether or not the given peer is whitelisted.
+// It is safe for concurrent access.
+func (sp *serverPeer) whitelisted() bool {
+	sp.permMtx.Lock()
+	whitelisted := sp.isWhitelisted
+	sp.permMtx.Unlock()
+
+	return whitelisted
+}
+
+// setNoBan toggles whether the given peer is exempt from being banned.  The
+// ban score of such peers is still tracked, but they are not banned once it
+// exceeds the ban threshold.
+// It is safe for concurrent access.
+func (sp *serverPeer) setNoBan(noBan bool) {
+	sp.permMtx.Lock()
+	sp.isNoBan = noBan
+	sp.permMtx.Unlock()
+}
+
+// noBan returns whether or not the given peer is exempt from being banned.
+// It is safe for concurrent access.
+func (sp *serverPeer) noBan() bool {
+	sp.permMtx.Lock()
+	noBan := sp.isNoBan
+	sp.permMtx.Unlock()
+
+	return noBan
+}
+
 // pushAddrMsg sends an addr message to the connected peer using the provided
-// addresses.
+// addresses.  Addresses are never relayed to block-relay-only peers.
 func (sp *serverPeer) pushAddrMsg(addresses []*wire.NetAddress) {
+	if sp.blockRelayOnly {
+		return
+	}
+
 	// Filter addresses already known to the peer.
 	addrs := make([]*wire.NetAddress, 0, len(addresses))
 	for _, addr := range addresses {
@@ -344,7 +473,7 @@ func (sp *serverPeer) addBanScore(persistent, transient uint32, reason string) {
 	if cfg.DisableBanning {
 		return
 	}
-	if sp.isWhitelisted {
+	if sp.whitelisted() {
 		peerLog.Debugf("Misbehaving whitelisted peer %s: %s", sp, reason)
 		return
 	}
@@ -365,6 +494,11 @@ func (sp *serverPeer) addBanScore(persistent, transient uint32, reason string) {
 		peerLog.Warnf("Misbehaving peer %s: %s -- ban score increased to %d",
 			sp, reason, score)
 		if score > cfg.BanThreshold {
+			if sp.noBan() {
+				peerLog.Warnf("Misbehaving peer %s -- not banning "+
+					"since it is exempt from bans", sp)
+				return
+			}
 			peerLog.Warnf("Misbehaving peer %s -- banning and disconnecting",
 				sp)
 			sp.server.BanPeer(sp)
@@ -431,10 +565,13 @@ func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) {
 
 			// Request known addresses if the server address manager needs
 			// more and the peer has a protocol version new enough to
-			// include a timestamp with addresses.
+			// include a timestamp with addresses.  Addresses are not
+			// requested from block-relay-only peers.
 			hasTimestamp := sp.ProtocolVersion() >=
 				wire.NetAddressTimeVersion
-			if addrManager.NeedMoreAddresses() && hasTimestamp {
+			if addrManager.NeedMoreAddresses() && hasTimestamp &&
+				!sp.blockRelayOnly {
+
 				sp.QueueMessage(wire.NewMsgGetAddr(), nil)
 			}
 
@@ -481,8 +618,7 @@ func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 		// or only the transactions that match the filter when there is
 		// one.
 		if !sp.filter.IsLoaded() || sp.filter.MatchTxAndUpdate(txDesc.Tx) {
-			iv := wire.NewInvVect(wire.InvTypeTx, txDesc.Tx.Hash())
-			invMsg.AddInvVect(iv)
+			invMsg.AddInvVect(sp.txInvVect(txDesc.Tx))
 			if len(invMsg.InvList)+1 > wire.MaxInvPerMsg {
 				break
 			}
@@ -505,6 +641,11 @@ func (sp *serverPeer) OnTx(_ *peer.Peer, msg *wire.MsgTx) {
 			msg.TxHash(), sp)
 		return
 	}
+	if sp.blockRelayOnly {
+		peerLog.Tracef("Ignoring tx %v from block-relay-only peer %v",
+			msg.TxHash(), sp)
+		return
+	}
 
 	// Add the transaction to the known inventory for the peer.
 	// Convert the raw MsgTx to a navutil.Tx which provides some convenience
@@ -512,14 +653,22 @@ func (sp *serverPeer) OnTx(_ *peer.Peer, msg *wire.MsgTx) {
 	tx := navutil.NewTx(msg)
 	iv := wire.NewInvVect(wire.InvTypeTx, tx.Hash())
 	sp.AddKnownInventory(iv)
+	sp.AddKnownInventory(sp.txInvVect(tx))
 
 	// Queue the transaction up to be handled by the sync manager and
 	// intentionally block further receives until the transaction is fully
 	// processed and known good or bad.  This helps prevent a malicious peer
 	// from queuing up a bunch of bad transactions before disconnecting (or
 	// being disconnected) and wasting memory.
+	known := sp.server.txMemPool.IsTransactionInPool(tx.Hash())
 	sp.server.syncManager.QueueTx(tx, sp.Peer, sp.txProcessed)
 	<-sp.txProcessed
+
+	// Keep track of the last time the peer relayed a novel transaction
+	// for the inbound peer eviction policy.
+	if !known && sp.server.txMemPool.IsTransactionInPool(tx.Hash()) {
+		atomic.StoreInt64(&sp.lastTxTime, time.Now().UnixNano())
+	}
 }
 
 // OnBlock is invoked when a peer receives a block navcoin message.  It
@@ -544,8 +693,96 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 	// reference implementation processes blocks in the same
 	// thread and therefore blocks further messages until
 	// the navcoin block has been fully processed.
+	known := sp.haveBlock(block.Hash())
 	sp.server.syncManager.QueueBlock(block, sp.Peer, sp.blockProcessed)
 	<-sp.blockProcessed
+	sp.updateLastBlockTime(block.Hash(), known)
+}
+
+// haveBlock returns whether or not the block with the given hash is known.
+func (sp *serverPeer) haveBlock(hash *chainhash.Hash) bool {
+	have, err := sp.server.chain.HaveBlock(hash)
+	return err == nil && have
+}
+
+// updateLastBlockTime keeps track of the last time the peer relayed a novel
+// block for the inbound peer eviction policy.  The block is novel when it is
+// known after being processed while it was not known before.
+func (sp *serverPeer) updateLastBlockTime(hash *chainhash.Hash, known bool) {
+	if !known && sp.haveBlock(hash) {
+		atomic.StoreInt64(&sp.lastBlockTime, time.Now().UnixNano())
+	}
+}
+
+// OnCmpctBlock is invoked when a peer receives a cmpctblock navcoin message.
+// It blocks until the compact block has been fully processed.
+func (sp *serverPeer) OnCmpctBlock(_ *peer.Peer, msg *wire.MsgCmpctBlock) {
+	// Queue the compact block up to be handled by the sync manager and
+	// intentionally block further receives until it is processed for the
+	// same reasons as full blocks.
+	hash := msg.Header.BlockHash()
+	known := sp.haveBlock(&hash)
+	sp.server.syncManager.QueueCmpctBlock(msg, sp.Peer, sp.blockProcessed)
+	<-sp.blockProcessed
+	sp.updateLastBlockTime(&hash, known)
+}
+
+// OnBlockTxn is invoked when a peer receives a blocktxn navcoin message.  It
+// blocks until the compact block the transactions complete has been fully
+// processed.
+func (sp *serverPeer) OnBlockTxn(_ *peer.Peer, msg *wire.MsgBlockTxn) {
+	known := sp.haveBlock(&msg.BlockHash)
+	sp.server.syncManager.QueueBlockTxn(msg, sp.Peer, sp.blockProcessed)
+	<-sp.blockProcessed
+	sp.updateLastBlockTime(&msg.BlockHash, known)
+}
+
+// OnGetBlockTxn is invoked when a peer receives a getblocktxn navcoin message
+// and is used to deliver the requested transactions of a block which was
+// previously sent as a compact block.
+func (sp *serverPeer) OnGetBlockTxn(_ *peer.Peer, msg *wire.MsgGetBlockTxn) {
+	version := sp.CompactBlockVersion()
+	if version == 0 {
+		peerLog.Debugf("Ignoring getblocktxn from %v -- compact blocks "+
+			"were not negotiated", sp)
+		return
+	}
+
+	blk, err := sp.server.chain.BlockByHash(&msg.BlockHash)
+	if err != nil {
+		peerLog.Debugf("Unable to fetch requested block hash %v: %v",
+			msg.BlockHash, err)
+		return
+	}
+
+	encoding := wire.BaseEncoding
+	if version == wire.CmpctBlockVersion2 {
+		encoding = wire.WitnessEncoding
+	}
+
+	// Serve the full block instead of individual transactions for blocks
+	// which are too deep in the chain to have been announced as compact
+	// blocks.
+	best := sp.server.chain.BestSnapshot()
+	if best.Height-blk.Height() >= maxCmpctBlockDepth {
+		sp.QueueMessageWithEncoding(blk.MsgBlock(), nil, encoding)
+		return
+	}
+
+	msgBlock := blk.MsgBlock()
+	blockTxn := wire.NewMsgBlockTxn(&msg.BlockHash)
+	for _, index := range msg.Indexes {
+		if int(index) >= len(msgBlock.Transactions) {
+			peerLog.Debugf("Peer %v requested out of range "+
+				"transaction %d of block %v", sp, index,
+				msg.BlockHash)
+			sp.addBanScore(100, 0, "getblocktxn")
+			sp.Disconnect()
+			return
+		}
+		blockTxn.AddTransaction(msgBlock.Transactions[index])
+	}
+	sp.QueueMessageWithEncoding(blockTxn, nil, encoding)
 }
 
 // OnInv is invoked when a peer receives an inv navcoin message and is
@@ -553,7 +790,7 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 // accordingly.  We pass the message down to blockmanager which will call
 // QueueMessage with any appropriate responses.
 func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
-	if !cfg.BlocksOnly {
+	if !cfg.BlocksOnly && !sp.blockRelayOnly {
 		if len(msg.InvList) > 0 {
 			sp.server.syncManager.QueueInv(msg, sp.Peer)
 		}
@@ -562,9 +799,9 @@ func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
 
 	newInv := wire.NewMsgInvSizeHint(uint(len(msg.InvList)))
 	for _, invVect := range msg.InvList {
-		if invVect.Type == wire.InvTypeTx {
+		if invVect.Type == wire.InvTypeTx || invVect.Type == wire.InvTypeWTx {
 			peerLog.Tracef("Ignoring tx %v in inv from %v -- "+
-				"blocksonly enabled", invVect.Hash, sp)
+				"transaction relay disabled", invVect.Hash, sp)
 			if sp.ProtocolVersion() >= wire.BIP0037Version {
 				peerLog.Infof("Peer %v is announcing "+
 					"transactions -- disconnecting", sp)
@@ -629,10 +866,14 @@ func (sp *serverPeer) OnGetData(_ *peer.Peer, msg *wire.MsgGetData) {
 			err = sp.server.pushTxMsg(sp, &iv.Hash, c, waitChan, wire.WitnessEncoding)
 		case wire.InvTypeTx:
 			err = sp.server.pushTxMsg(sp, &iv.Hash, c, waitChan, wire.BaseEncoding)
+		case wire.InvTypeWTx:
+			err = sp.server.pushWTxMsg(sp, &iv.Hash, c, waitChan)
 		case wire.InvTypeWitnessBlock:
 			err = sp.server.pushBlockMsg(sp, &iv.Hash, c, waitChan, wire.WitnessEncoding)
 		case wire.InvTypeBlock:
 			err = sp.server.pushBlockMsg(sp, &iv.Hash, c, waitChan, wire.BaseEncoding)
+		case wire.InvTypeCmpctBlock:
+			err = sp.server.pushCmpctBlockMsg(sp, &iv.Hash, c, waitChan)
 		case wire.InvTypeFilteredWitnessBlock:
 			err = sp.server.pushMerkleBlockMsg(sp, &iv.Hash, c, waitChan, wire.WitnessEncoding)
 		case wire.InvTypeFilteredBlock:
@@ -927,6 +1168,20 @@ func (sp *serverPeer) enforceNodeBloomFlag(cmd string) bool {
 	return true
 }
 
+// OnPong is invoked when a peer receives a pong navcoin message.  It keeps
+// track of the lowest ping time of the peer for the inbound peer eviction
+// policy.
+func (sp *serverPeer) OnPong(_ *peer.Peer, msg *wire.MsgPong) {
+	pingMicros := sp.LastPingMicros()
+	if pingMicros <= 0 {
+		return
+	}
+	minPing := atomic.LoadInt64(&sp.minPingMicros)
+	if minPing == 0 || pingMicros < minPing {
+		atomic.StoreInt64(&sp.minPingMicros, pingMicros)
+	}
+}
+
 // OnFeeFilter is invoked when a peer receives a feefilter navcoin message and
 // is used by remote peers to request that no transactions which have a fee rate
 // lower than provided value are inventoried to them.  The peer will be
@@ -1041,6 +1296,19 @@ func (sp *serverPeer) OnGetAddr(_ *peer.Peer, msg *wire.MsgGetAddr) {
 // OnAddr is invoked when a peer receives an addr navcoin message and is
 // used to notify the server about advertised addresses.
 func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
+	sp.handleAddrList(msg.Command(), msg.AddrList)
+}
+
+// OnAddrV2 is invoked when a peer receives an addrv2 navcoin message and is
+// used to notify the server about advertised addresses, including those of
+// networks which can't be represented by addr messages.
+func (sp *serverPeer) OnAddrV2(_ *peer.Peer, msg *wire.MsgAddrV2) {
+	sp.handleAddrList(msg.Command(), msg.AddrList)
+}
+
+// handleAddrList adds the addresses advertised by the peer by the provided
+// command to the known addresses of the peer and the server address manager.
+func (sp *serverPeer) handleAddrList(command string, addrList []*wire.NetAddress) {
 	// Ignore addresses when running on the simulation test network.  This
 	// helps prevent the network from becoming another public test network
 	// since it will not be able to learn about other peers that have not
@@ -1049,20 +1317,28 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 		return
 	}
 
+	// Ignore addresses from block-relay-only peers so they can't be used
+	// to learn which addresses are known to us.
+	if sp.blockRelayOnly {
+		peerLog.Debugf("Ignoring %s from block-relay-only peer %v",
+			command, sp)
+		return
+	}
+
 	// Ignore old style addresses which don't include a timestamp.
 	if sp.ProtocolVersion() < wire.NetAddressTimeVersion {
 		return
 	}
 
 	// A message that has no addresses is invalid.
-	if len(msg.AddrList) == 0 {
+	if len(addrList) == 0 {
 		peerLog.Errorf("Command [%s] from %s does not contain any addresses",
-			msg.Command(), sp)
+			command, sp)
 		sp.Disconnect()
 		return
 	}
 
-	for _, na := range msg.AddrList {
+	for _, na := range addrList {
 		// Don't add more address if we're disconnecting.
 		if !sp.Connected() {
 			return
@@ -1085,7 +1361,7 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 	// addresses, and last seen updates.
 	// XXX navcoind gives a 2 hour time penalty here, do we want to do the
 	// same?
-	sp.server.addrManager.AddAddresses(msg.AddrList, sp.NA())
+	sp.server.addrManager.AddAddresses(addrList, sp.NA())
 }
 
 // OnRead is invoked when a peer receives a message and it is used to update
@@ -1149,6 +1425,18 @@ func (s *server) relayTransactions(txns []*mempool.TxDesc) {
 	}
 }
 
+// relayLocalTransactions generates and relays inventory vectors for all of the
+// passed transactions, which were submitted to this node rather than received
+// from a peer.  They are only relayed to peers reached over Tor when the
+// --torbroadcast option is set.
+func (s *server) relayLocalTransactions(txns []*mempool.TxDesc) {
+	for _, txD := range txns {
+		iv := wire.NewInvVect(wire.InvTypeTx, txD.Tx.Hash())
+		s.relayInv <- relayMsg{invVect: iv, data: txD,
+			torOnly: cfg.TorBroadcast}
+	}
+}
+
 // AnnounceNewTransactions generates and relays inventory vectors and notifies
 // both websocket and getblocktemplate long poll clients of the passed
 // transactions.  This function should be called whenever new transactions
@@ -1206,6 +1494,27 @@ func (s *server) pushTxMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<-
 	return nil
 }
 
+// pushWTxMsg sends a tx message for the provided transaction witness hash
+// (wtxid) to the connected peer.  An error is returned if the transaction is
+// not known.
+func (s *server) pushWTxMsg(sp *serverPeer, wtxid *chainhash.Hash, doneChan chan<- struct{},
+	waitChan <-chan struct{}) error {
+
+	tx, err := s.txMemPool.FetchTransactionByWTxID(wtxid)
+	if err != nil {
+		peerLog.Tracef("Unable to fetch wtx %v from transaction "+
+			"pool: %v", wtxid, err)
+
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return err
+	}
+
+	return s.pushTxMsg(sp, tx.Hash(), doneChan, waitChan,
+		wire.WitnessEncoding)
+}
+
 // pushBlockMsg sends a block message for the provided block hash to the
 // connected peer.  An error is returned if the block hash is not known.
 func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<- struct{},
@@ -1272,6 +1581,58 @@ func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan cha
 	return nil
 }
 
+// pushCmpctBlockMsg sends a cmpctblock message for the provided block hash to
+// the connected peer.  The full block is sent instead when compact blocks were
+// not negotiated with the peer or the block is too deep in the chain for the
+// peer to be able to reconstruct it from its memory pool.  An error is
+// returned if the block hash is not known.
+func (s *server) pushCmpctBlockMsg(sp *serverPeer, hash *chainhash.Hash,
+	doneChan chan<- struct{}, waitChan <-chan struct{}) error {
+
+	version := sp.CompactBlockVersion()
+	encoding := wire.BaseEncoding
+	if version == wire.CmpctBlockVersion2 ||
+		(version == 0 && sp.IsWitnessEnabled()) {
+
+		encoding = wire.WitnessEncoding
+	}
+
+	blk, err := sp.server.chain.BlockByHash(hash)
+	if err == nil && (version == 0 ||
+		s.chain.BestSnapshot().Height-blk.Height() >= maxCmpctBlockDepth) {
+
+		return s.pushBlockMsg(sp, hash, doneChan, waitChan, encoding)
+	}
+	if err != nil {
+		peerLog.Tracef("Unable to fetch requested block hash %v: %v",
+			hash, err)
+
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return err
+	}
+
+	nonce, err := wire.RandomUint64()
+	if err != nil {
+		peerLog.Errorf("Unable to generate compact block nonce: %v", err)
+
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return err
+	}
+	cmpctBlock := wire.NewMsgCmpctBlock(blk.MsgBlock(), version, nonce)
+
+	// Once we have fetched data wait for any previous operation to finish.
+	if waitChan != nil {
+		<-waitChan
+	}
+
+	sp.QueueMessageWithEncoding(cmpctBlock, doneChan, encoding)
+	return nil
+}
+
 // pushMerkleBlockMsg sends a merkleblock message for the provided block hash to
 // the connected peer.  Since a merkle block requires the peer to have a filter
 // loaded, this call will simply be ignored if there is no filter loaded.  An
@@ -1382,22 +1743,21 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		sp.Disconnect()
 		return false
 	}
-	if banEnd, ok := state.banned[host]; ok {
-		if time.Now().Before(banEnd) {
-			srvrLog.Debugf("Peer %s is banned for another %v - disconnecting",
-				host, time.Until(banEnd))
-			sp.Disconnect()
-			return false
-		}
-
-		srvrLog.Infof("Peer %s is no longer banned", host)
-		delete(state.banned, host)
+	if banEnd, banned := s.banList.IsHostBanned(host); banned {
+		srvrLog.Debugf("Peer %s is banned for another %v - disconnecting",
+			host, time.Until(banEnd))
+		sp.Disconnect()
+		return false
 	}
 
 	// TODO: Check for max peers from a single IP.
 
-	// Limit max number of total peers.
-	if state.Count() >= cfg.MaxPeers {
+	// Limit max number of total peers.  Once the limit is reached, a new
+	// inbound peer is only admitted when another inbound peer is evicted to
+	// make room for it.
+	if state.Count() >= cfg.MaxPeers &&
+		(!sp.Inbound() || !s.evictInboundPeer(state)) {
+
 		srvrLog.Infof("Max peers reached [%d] - disconnecting peer %s",
 			cfg.MaxPeers, sp)
 		sp.Disconnect()
@@ -1411,7 +1771,7 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	if sp.Inbound() {
 		state.inboundPeers[sp.ID()] = sp
 	} else {
-		state.outboundGroups[addrmgr.GroupKey(sp.NA())]++
+		state.outboundGroups[s.addrManager.GroupKey(sp.NA())]++
 		if sp.persistent {
 			state.persistentPeers[sp.ID()] = sp
 		} else {
@@ -1435,7 +1795,7 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 	}
 	if _, ok := list[sp.ID()]; ok {
 		if !sp.Inbound() && sp.VersionKnown() {
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		}
 		if !sp.Inbound() && sp.connReq != nil {
 			s.connManager.Disconnect(sp.connReq.ID())
@@ -1459,6 +1819,53 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 	// or we purposefully deleted it.
 }
 
+// evictInboundPeer attempts to disconnect an inbound peer selected by the
+// eviction policy to make room for a new inbound peer.  Whitelisted peers and
+// peers which are exempt from bans are never evicted.  It returns whether a
+// peer was evicted.  It is invoked from the peerHandler goroutine.
+func (s *server) evictInboundPeer(state *peerState) bool {
+	candidates := make([]connmgr.EvictionCandidate, 0,
+		len(state.inboundPeers))
+	for _, sp := range state.inboundPeers {
+		if sp.whitelisted() || sp.noBan() {
+			continue
+		}
+		minPing := atomic.LoadInt64(&sp.minPingMicros)
+		candidates = append(candidates, connmgr.EvictionCandidate{
+			ID:            sp.ID(),
+			NetGroup:      s.addrManager.GroupKey(sp.NA()),
+			ConnTime:      sp.TimeConnected(),
+			MinPing:       time.Duration(minPing) * time.Microsecond,
+			LastTxTime:    unixNanoTime(atomic.LoadInt64(&sp.lastTxTime)),
+			LastBlockTime: unixNanoTime(atomic.LoadInt64(&sp.lastBlockTime)),
+			RelayTxes:     !sp.relayTxDisabled(),
+		})
+	}
+
+	id, ok := connmgr.SelectNodeToEvict(candidates, s.evictionSeed)
+	if !ok {
+		return false
+	}
+	evicted := state.inboundPeers[id]
+	srvrLog.Infof("Evicting inbound peer %s to make room for a new "+
+		"inbound peer", evicted)
+
+	// This is ok since the peer is no longer counted once it is removed
+	// and the done peer handler ignores peers which are unknown.
+	delete(state.inboundPeers, id)
+	evicted.Disconnect()
+	return true
+}
+
+// unixNanoTime returns the time for the given number of nanoseconds since the
+// unix epoch, or the zero time when it is zero.
+func unixNanoTime(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
 // handleBanPeerMsg deals with banning peers.  It is invoked from the
 // peerHandler goroutine.
 func (s *server) handleBanPeerMsg(state *peerState, sp *serverPeer) {
@@ -1470,28 +1877,73 @@ func (s *server) handleBanPeerMsg(state *peerState, sp *serverPeer) {
 	direction := directionString(sp.Inbound())
 	srvrLog.Infof("Banned peer %s (%s) for %v", host, direction,
 		cfg.BanDuration)
-	state.banned[host] = time.Now().Add(cfg.BanDuration)
+	s.banList.BanHost(host, time.Now().Add(cfg.BanDuration))
 }
 
 // handleRelayInvMsg deals with relaying inventory to peers that are not already
 // known to have it.  It is invoked from the peerHandler goroutine.
 func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
+	// Transactions which are to be broadcast via Tor only can't be relayed
+	// to anyone when no peers reached over Tor are connected.  They stay in
+	// the rebroadcast inventory, so say so rather than dropping them
+	// silently.
+	if msg.torOnly && msg.invVect.Type == wire.InvTypeTx &&
+		!state.hasTorPeer() {
+
+		srvrLog.Warnf("Not announcing transaction %v: no peers reached "+
+			"over Tor are connected (--torbroadcast), it will be "+
+			"retried when it is rebroadcast", msg.invVect.Hash)
+		return
+	}
+
 	state.forAllPeers(func(sp *serverPeer) {
 		if !sp.Connected() {
 			return
 		}
 
+		// If the inventory is a block and the peer requested
+		// high-bandwidth compact block relay, send a cmpctblock message
+		// instead of an inventory message.
+		if msg.invVect.Type == wire.InvTypeBlock && sp.WantsCompactBlocks() {
+			block, ok := msg.data.(*navutil.Block)
+			if !ok {
+				peerLog.Warnf("Underlying data for compact block" +
+					" is not a block")
+				return
+			}
+			if sp.HasKnownInventory(msg.invVect) {
+				return
+			}
+			nonce, err := wire.RandomUint64()
+			if err != nil {
+				peerLog.Errorf("Unable to generate compact "+
+					"block nonce: %v", err)
+				return
+			}
+			version := sp.CompactBlockVersion()
+			encoding := wire.BaseEncoding
+			if version == wire.CmpctBlockVersion2 {
+				encoding = wire.WitnessEncoding
+			}
+			cmpctBlock := wire.NewMsgCmpctBlock(block.MsgBlock(),
+				version, nonce)
+			sp.AddKnownInventory(msg.invVect)
+			sp.QueueMessageWithEncoding(cmpctBlock, nil, encoding)
+			return
+		}
+
 		// If the inventory is a block and the peer prefers headers,
 		// generate and send a headers message instead of an inventory
 		// message.
 		if msg.invVect.Type == wire.InvTypeBlock && sp.WantsHeaders() {
-			blockHeader, ok := msg.data.(wire.BlockHeader)
+			block, ok := msg.data.(*navutil.Block)
 			if !ok {
 				peerLog.Warnf("Underlying data for headers" +
-					" is not a block header")
+					" is not a block")
 				return
 			}
 			msgHeaders := wire.NewMsgHeaders()
+			blockHeader := block.MsgBlock().Header
 			if err := msgHeaders.AddBlockHeader(&blockHeader); err != nil {
 				peerLog.Errorf("Failed to add block"+
 					" header: %v", err)
@@ -1501,6 +1953,7 @@ func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
 			return
 		}
 
+		invVect := msg.invVect
 		if msg.invVect.Type == wire.InvTypeTx {
 			// Don't relay the transaction to the peer when it has
 			// transaction relaying disabled.
@@ -1508,6 +1961,13 @@ func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
 				return
 			}
 
+			// Don't relay transactions submitted to this node to
+			// peers which are not reached over Tor when they are
+			// to be broadcast via Tor only.
+			if msg.torOnly && !sp.reachedOverTor() {
+				return
+			}
+
 			txD, ok := msg.data.(*mempool.TxDesc)
 			if !ok {
 				peerLog.Warnf("Underlying data for tx inv "+
@@ -1530,12 +1990,16 @@ func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
 					return
 				}
 			}
+
+			// Announce the transaction by witness hash to peers
+			// which negotiated it.
+			invVect = sp.txInvVect(txD.Tx)
 		}
 
 		// Queue the inventory to be relayed with the next batch.
 		// It will be ignored if the peer is already known to
 		// have the inventory.
-		sp.QueueInventory(msg.invVect)
+		sp.QueueInventory(invVect)
 	})
 }
 
@@ -1647,7 +2111,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found := disconnectPeer(state.persistentPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		})
 
 		if found {
@@ -1683,7 +2147,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		})
 		if found {
 			// If there are multiple outbound connections to the same
@@ -1691,7 +2155,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 			// peers are found.
 			for found {
 				found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
-					state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+					state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 				})
 			}
 			msg.reply <- nil
@@ -1736,18 +2200,23 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 			OnBlock:        sp.OnBlock,
 			OnInv:          sp.OnInv,
 			OnHeaders:      sp.OnHeaders,
+			OnCmpctBlock:   sp.OnCmpctBlock,
+			OnGetBlockTxn:  sp.OnGetBlockTxn,
+			OnBlockTxn:     sp.OnBlockTxn,
 			OnGetData:      sp.OnGetData,
 			OnGetBlocks:    sp.OnGetBlocks,
 			OnGetHeaders:   sp.OnGetHeaders,
 			OnGetCFilter:   sp.OnGetCFilter,
 			OnGetCFHeaders: sp.OnGetCFHeaders,
 			OnGetCFTypes:   sp.OnGetCFTypes,
+			OnPong:         sp.OnPong,
 			OnFeeFilter:    sp.OnFeeFilter,
 			OnFilterAdd:    sp.OnFilterAdd,
 			OnFilterClear:  sp.OnFilterClear,
 			OnFilterLoad:   sp.OnFilterLoad,
 			OnGetAddr:      sp.OnGetAddr,
 			OnAddr:         sp.OnAddr,
+			OnAddrV2:       sp.OnAddrV2,
 			OnRead:         sp.OnRead,
 			OnWrite:        sp.OnWrite,
 
@@ -1765,7 +2234,11 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 		UserAgentComments: cfg.UserAgentComments,
 		ChainParams:       sp.server.chainParams,
 		Services:          sp.server.services,
-		DisableRelayTx:    cfg.BlocksOnly,
+		DisableRelayTx:    cfg.BlocksOnly || sp.blockRelayOnly,
+		CompactBlocks:     true,
+		AddrV2:            true,
+		WTxIDRelay:        true,
+		V2Transport:       sp.v2Transport,
 		ProtocolVersion:   peer.MaxProtocolVersion,
 	}
 }
@@ -1775,8 +2248,21 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 // instance, associates it with the connection, and starts a goroutine to wait
 // for disconnection.
 func (s *server) inboundPeerConnected(conn net.Conn) {
+	// Refuse connections from banned addresses before doing any work for
+	// them.
+	if ip := tcpAddrIP(conn.RemoteAddr()); ip != nil {
+		if banEnd, banned := s.banList.IsBanned(ip); banned {
+			srvrLog.Debugf("Inbound connection from %s is banned for "+
+				"another %v - disconnecting", conn.RemoteAddr(),
+				time.Until(banEnd))
+			conn.Close()
+			return
+		}
+	}
+
 	sp := newServerPeer(s, false)
 	sp.isWhitelisted = isWhitelisted(conn.RemoteAddr())
+	sp.v2Transport = cfg.V2Transport
 	sp.Peer = peer.NewInboundPeer(newPeerConfig(sp))
 	sp.AssociateConnection(conn)
 	go s.peerDoneHandler(sp)
@@ -1789,6 +2275,8 @@ func (s *server) inboundPeerConnected(conn net.Conn) {
 // manager of the attempt.
 func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	sp := newServerPeer(s, c.Permanent)
+	sp.v2Transport = s.useV2Transport(c)
+	sp.blockRelayOnly = c.BlockRelayOnly
 	p, err := peer.NewOutboundPeer(newPeerConfig(sp), c.Addr.String())
 	if err != nil {
 		srvrLog.Debugf("Cannot create outbound peer %s: %v", c.Addr, err)
@@ -1802,10 +2290,163 @@ func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	s.addrManager.Attempt(sp.NA())
 }
 
+// dial connects to the given address unless it is banned.  It is used by the
+// connection manager for all outbound connections.
+func (s *server) dial(addr net.Addr) (net.Conn, error) {
+	if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+		if _, banned := s.banList.IsHostBanned(host); banned {
+			return nil, fmt.Errorf("address %s is banned", addr)
+		}
+	}
+	return navdDial(addr)
+}
+
+// tcpAddrIP returns the IP address of the given address or nil when it is not
+// a TCP address.
+func tcpAddrIP(addr net.Addr) net.IP {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP
+	}
+	return nil
+}
+
+// loadAnchors reads the addresses of the block-relay-only peers connected at
+// the previous shutdown from the provided file and removes the file, so the
+// same addresses are not reused should the node crash.  Errors are logged and
+// result in no addresses.
+func loadAnchors(filePath string) []*wire.NetAddress {
+	r, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		srvrLog.Errorf("Error opening file %s: %v", filePath, err)
+		return nil
+	}
+	defer func() {
+		r.Close()
+		if err := os.Remove(filePath); err != nil {
+			srvrLog.Errorf("Error removing file %s: %v", filePath, err)
+		}
+	}()
+
+	var msg wire.MsgAddrV2
+	err = msg.BtcDecode(r, wire.ProtocolVersion, wire.BaseEncoding)
+	if err != nil {
+		srvrLog.Errorf("Failed to decode file %s: %v", filePath, err)
+		return nil
+	}
+	srvrLog.Infof("Loaded %d anchor addresses from %s", len(msg.AddrList),
+		filePath)
+	return msg.AddrList
+}
+
+// saveAnchors writes the addresses of the connected block-relay-only peers to
+// the anchors file in the data directory.
+//
+// This function MUST only be called from the peerHandler goroutine.
+func (s *server) saveAnchors(state *peerState) {
+	msg := wire.NewMsgAddrV2()
+	state.forAllOutboundPeers(func(sp *serverPeer) {
+		if sp.blockRelayOnly && sp.Connected() {
+			msg.AddAddress(sp.NA())
+		}
+	})
+	if len(msg.AddrList) == 0 {
+		return
+	}
+
+	filePath := filepath.Join(cfg.DataDir, anchorsFilename)
+	w, err := os.Create(filePath)
+	if err != nil {
+		srvrLog.Errorf("Error opening file %s: %v", filePath, err)
+		return
+	}
+	defer w.Close()
+	err = msg.BtcEncode(w, wire.ProtocolVersion, wire.BaseEncoding)
+	if err != nil {
+		srvrLog.Errorf("Failed to encode file %s: %v", filePath, err)
+		return
+	}
+	srvrLog.Infof("Saved %d anchor addresses to %s", len(msg.AddrList),
+		filePath)
+}
+
+// popAnchor removes and returns the next address of the block-relay-only peers
+// connected at the previous shutdown, or nil when none remain.
+// It is safe for concurrent access.
+func (s *server) popAnchor() *wire.NetAddress {
+	s.anchorsMtx.Lock()
+	defer s.anchorsMtx.Unlock()
+
+	if len(s.anchors) == 0 {
+		return nil
+	}
+	na := s.anchors[0]
+	s.anchors = s.anchors[1:]
+	return na
+}
+
+// useV2Transport returns whether the encrypted v2 transport protocol is
+// attempted for the given outbound connection request.  It is attempted for
+// persistent peers and for peers which advertise support for it, unless a
+// previous v2 transport handshake with the peer failed.
+func (s *server) useV2Transport(c *connmgr.ConnReq) bool {
+	if !cfg.V2Transport {
+		return false
+	}
+
+	s.v1OnlyMtx.Lock()
+	_, v1Only := s.v1OnlyAddrs[c.Addr.String()]
+	s.v1OnlyMtx.Unlock()
+	if v1Only {
+		return false
+	}
+	if c.Permanent {
+		return true
+	}
+
+	tcpAddr, ok := c.Addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	na := wire.NewNetAddress(tcpAddr, 0)
+	return s.addrManager.KnownServices(na)&wire.SFNodeP2PV2 != 0
+}
+
+// addV1OnlyAddr records that outbound connections to the passed address use
+// the v1 transport protocol.  An arbitrary address is forgotten when the
+// maximum number of addresses is reached.
+//
+// It is safe for concurrent access.
+func (s *server) addV1OnlyAddr(addr string) {
+	s.v1OnlyMtx.Lock()
+	defer s.v1OnlyMtx.Unlock()
+
+	if _, ok := s.v1OnlyAddrs[addr]; ok {
+		return
+	}
+	if len(s.v1OnlyAddrs)+1 > maxV1OnlyAddrs {
+		for k := range s.v1OnlyAddrs {
+			delete(s.v1OnlyAddrs, k)
+			break
+		}
+	}
+	s.v1OnlyAddrs[addr] = struct{}{}
+}
+
 // peerDoneHandler handles peer disconnects by notifiying the server that it's
 // done along with other performing other desirable cleanup.
 func (s *server) peerDoneHandler(sp *serverPeer) {
 	sp.WaitForDisconnect()
+
+	// Fall back to the v1 transport protocol for future connections to
+	// outbound peers which refused the v2 transport handshake, since they
+	// don't support it.  This must be done before the server is notified,
+	// which has the connection manager reconnect to them.
+	if sp.v2HandshakeFailed() {
+		s.addV1OnlyAddr(sp.Addr())
+	}
 	s.donePeers <- sp
 
 	// Only tell sync manager we are gone if we ever told it we existed.
@@ -1841,7 +2482,6 @@ func (s *server) peerHandler() {
 		inboundPeers:    make(map[int32]*serverPeer),
 		persistentPeers: make(map[int32]*serverPeer),
 		outboundPeers:   make(map[int32]*serverPeer),
-		banned:          make(map[string]time.Time),
 		outboundGroups:  make(map[string]int),
 	}
 
@@ -1891,6 +2531,10 @@ out:
 			s.handleQuery(state, qmsg)
 
 		case <-s.quit:
+			// Save the addresses of the block-relay-only peers so
+			// they are connected to first at the next startup.
+			s.saveAnchors(state)
+
 			// Disconnect all peers on server shutdown.
 			state.forAllPeers(func(sp *serverPeer) {
 				srvrLog.Tracef("Shutdown peer %s", sp)
@@ -2026,7 +2670,8 @@ out:
 			// yet. We periodically resubmit them until they have.
 			for iv, data := range pendingInvs {
 				ivCopy := iv
-				s.RelayInventory(&ivCopy, data)
+				s.relayInv <- relayMsg{invVect: &ivCopy,
+					data: data, torOnly: cfg.TorBroadcast}
 			}
 
 			// Process at a random time up to 30mins (in seconds)
@@ -2054,6 +2699,51 @@ cleanup:
 	s.wg.Done()
 }
 
+// mempoolExpiryHandler periodically evicts transactions which have been in the
+// memory pool for longer than the configured expiry, along with their
+// descendants, and notifies RPC clients so wallets can decide whether to
+// rebroadcast them.
+func (s *server) mempoolExpiryHandler() {
+	ticker := time.NewTicker(mempoolExpireScanInterval)
+	defer ticker.Stop()
+
+out:
+	for {
+		select {
+		case <-ticker.C:
+			expired := s.txMemPool.ExpireTransactions()
+			if len(expired) == 0 {
+				continue
+			}
+
+			for _, tx := range expired {
+				srvrLog.Debugf("Evicted expired transaction %v "+
+					"from the mempool", tx.Hash())
+			}
+			srvrLog.Infof("Evicted %d expired %s from the mempool",
+				len(expired), pickNoun(uint64(len(expired)),
+					"transaction", "transactions"))
+
+			// Expired transactions no longer need to be
+			// rebroadcast by us and clients are notified so
+			// they can decide whether to rebroadcast them.
+			if s.rpcServer != nil {
+				for _, tx := range expired {
+					iv := wire.NewInvVect(wire.InvTypeTx,
+						tx.Hash())
+					s.RemoveRebroadcastInventory(iv)
+				}
+				s.rpcServer.NotifyExpiredTransactions(expired)
+			}
+
+		case <-s.quit:
+			break out
+		}
+	}
+
+	s.wg.Done()
+}
+
 // Start begins accepting connections from peers.
 func (s *server) Start() {
 	// Already started?
@@ -2076,6 +2766,12 @@ func (s *server) Start() {
 		go s.upnpUpdateThread()
 	}
 
+	// Start the mempool expiry handler when expiry is enabled.
+	if cfg.MempoolExpiry > 0 {
+		s.wg.Add(1)
+		go s.mempoolExpiryHandler()
+	}
+
 	if !cfg.DisableRPC {
 		s.wg.Add(1)
 
@@ -2086,10 +2782,20 @@ func (s *server) Start() {
 		s.rpcServer.Start()
 	}
 
+	// Start the event publisher if it is enabled.
+	if s.eventPub != nil {
+		s.eventPub.Start()
+	}
+
 	// Start the CPU miner if generation is enabled.
 	if cfg.Generate {
 		s.cpuMiner.Start()
 	}
+
+	// Start the Stratum server if it is enabled.
+	if s.stratumServer != nil {
+		s.stratumServer.Start()
+	}
 }
 
 // Stop gracefully shuts down the server by stopping and disconnecting all
@@ -2106,15 +2812,26 @@ func (s *server) Stop() error {
 	// Stop the CPU miner if needed
 	s.cpuMiner.Stop()
 
+	// Shutdown the Stratum server if it is enabled.
+	if s.stratumServer != nil {
+		s.stratumServer.Stop()
+	}
+
 	// Shutdown the RPC server if it's not disabled.
 	if !cfg.DisableRPC {
 		s.rpcServer.Stop()
 	}
 
-	// Save fee estimator state in the database.
+	// Shutdown the event publisher if it is enabled.
+	if s.eventPub != nil {
+		s.eventPub.Stop()
+	}
+
+	// Save fee estimator state and the mempool fee deltas in the database.
 	s.db.Update(func(tx database.Tx) error {
 		metadata := tx.Metadata()
 		metadata.Put(mempool.EstimateFeeDatabaseKey, s.feeEstimator.Save())
+		metadata.Put(mempool.FeeDeltasDatabaseKey, s.txMemPool.SaveFeeDeltas())
 
 		return nil
 	})
@@ -2316,6 +3033,63 @@ func setupRPCListeners() ([]net.Listener, error) {
 	return listeners, nil
 }
 
+// setupEventPubListeners returns a slice of listeners for the event publisher.
+// Addresses prefixed with "unix:" are treated as unix socket paths while all
+// others are treated as TCP interface/port pairs.
+func setupEventPubListeners() ([]net.Listener, error) {
+	var tcpAddrs []string
+	listeners := make([]net.Listener, 0, len(cfg.EventPubListeners))
+	for _, addr := range cfg.EventPubListeners {
+		if !strings.HasPrefix(addr, "unix:") {
+			tcpAddrs = append(tcpAddrs, addr)
+			continue
+		}
+
+		path := strings.TrimPrefix(addr, "unix:")
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			srvrLog.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
+
+	netAddrs, err := parseListeners(tcpAddrs)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range netAddrs {
+		listener, err := net.Listen(addr.Network(), addr.String())
+		if err != nil {
+			srvrLog.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, nil
+}
+
+// setupStratumListeners returns a slice of listeners for the Stratum server.
+func setupStratumListeners() ([]net.Listener, error) {
+	netAddrs, err := parseListeners(cfg.StratumListeners)
+	if err != nil {
+		return nil, err
+	}
+
+	listeners := make([]net.Listener, 0, len(netAddrs))
+	for _, addr := range netAddrs {
+		listener, err := net.Listen(addr.Network(), addr.String())
+		if err != nil {
+			srvrLog.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, nil
+}
+
 // newServer returns a new navd server configured to listen on addr for the
 // navcoin network type specified by chainParams.  Use start to begin accepting
 // connections from peers.
@@ -2327,8 +3101,20 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	if cfg.NoCFilters {
 		services &^= wire.SFNodeCF
 	}
+	if cfg.V2Transport {
+		services |= wire.SFNodeP2PV2
+	}
 
 	amgr := addrmgr.New(cfg.DataDir, navdLookup)
+	if cfg.ASMap != "" {
+		asmap, err := addrmgr.LoadASMap(cfg.ASMap)
+		if err != nil {
+			return nil, err
+		}
+		amgr.SetASMap(asmap)
+		srvrLog.Infof("Using asmap %s (checksum %s)", cfg.ASMap,
+			asmap.Checksum())
+	}
 
 	var listeners []net.Listener
 	var nat NAT
@@ -2343,6 +3129,13 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		}
 	}
 
+	// The secret which keys the network groups of inbound peers when
+	// selecting a peer to evict.
+	evictionSeed, err := wire.RandomUint64()
+	if err != nil {
+		return nil, err
+	}
+
 	s := server{
 		chainParams:          chainParams,
 		addrManager:          amgr,
@@ -2361,6 +3154,13 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		services:             services,
 		sigCache:             txscript.NewSigCache(cfg.SigCacheMaxSize),
 		hashCache:            txscript.NewHashCache(cfg.SigCacheMaxSize),
+		v1OnlyAddrs:          make(map[string]struct{}),
+		evictionSeed:         evictionSeed,
+		banList: connmgr.NewBanList(filepath.Join(cfg.DataDir,
+			banListFilename)),
+	}
+	if err := s.banList.Load(); err != nil {
+		srvrLog.Errorf("Unable to load ban list: %v", err)
 	}
 
 	// Create the transaction and address indexes if needed.
@@ -2408,7 +3208,6 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	}
 
 	// Create a new block chain instance with the appropriate configuration.
-	var err error
 	s.chain, err = blockchain.New(&blockchain.Config{
 		DB:           s.db,
 		Interrupt:    interrupt,
@@ -2463,6 +3262,7 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 			MaxSigOpCostPerTx:    blockchain.MaxBlockSigOpsCost / 4,
 			MinRelayTxFee:        cfg.minRelayTxFee,
 			MaxTxVersion:         2,
+			TxExpiry:             cfg.MempoolExpiry,
 		},
 		ChainParams:    chainParams,
 		FetchUtxoView:  s.chain.FetchUtxoView,
@@ -2477,8 +3277,57 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		AddrIndex:          s.addrIndex,
 		FeeEstimator:       s.feeEstimator,
 	}
+
+	// Publish mempool and block events when event publisher listeners are
+	// configured.
+	if len(cfg.EventPubListeners) > 0 {
+		eventPubListeners, err := setupEventPubListeners()
+		if err != nil {
+			return nil, err
+		}
+		if len(eventPubListeners) == 0 {
+			return nil, errors.New("navd: unable to listen on any " +
+				"event publisher interfaces")
+		}
+		s.eventPub = eventpub.New(&eventpub.Config{
+			Listeners: eventPubListeners,
+		})
+
+		txC.TxAccepted = func(txD *mempool.TxDesc) {
+			s.eventPub.PublishTx(txD.Tx)
+		}
+		txC.TxRemoved = func(tx *navutil.Tx, reason mempool.RemovalReason) {
+			s.eventPub.PublishTxRemoval(tx, reason.String())
+		}
+		s.chain.Subscribe(func(notification *blockchain.Notification) {
+			if notification.Type != blockchain.NTBlockConnected {
+				return
+			}
+			if block, ok := notification.Data.(*navutil.Block); ok {
+				s.eventPub.PublishBlock(block)
+			}
+		})
+	}
 	s.txMemPool = mempool.New(&txC)
 
+	// Restore any fee deltas that were applied to transactions via the
+	// prioritisetransaction RPC during a previous session.
+	db.Update(func(tx database.Tx) error {
+		metadata := tx.Metadata()
+		feeDeltasData := metadata.Get(mempool.FeeDeltasDatabaseKey)
+		if feeDeltasData != nil {
+			metadata.Delete(mempool.FeeDeltasDatabaseKey)
+
+			err := s.txMemPool.RestoreFeeDeltas(feeDeltasData)
+			if err != nil {
+				srvrLog.Errorf("Failed to restore mempool fee "+
+					"deltas: %v", err)
+			}
+		}
+
+		return nil
+	})
+
 	s.syncManager, err = netsync.New(&netsync.Config{
 		PeerNotifier:       &s,
 		Chain:              s.chain,
@@ -2504,10 +3353,12 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		BlockMaxSize:      cfg.BlockMaxSize,
 		BlockPrioritySize: cfg.BlockPrioritySize,
 		TxMinFreeFee:      cfg.minRelayTxFee,
+		TxSelector:        cfg.txSelector,
 	}
 	blockTemplateGenerator := mining.NewBlkTmplGenerator(&policy,
 		s.chainParams, s.txMemPool, s.chain, s.timeSource,
 		s.sigCache, s.hashCache)
+	blockTemplateGenerator.SetCoinbaseSplit(cfg.coinbaseSplit)
 	s.cpuMiner = cpuminer.New(&cpuminer.Config{
 		ChainParams:            chainParams,
 		BlockTemplateGenerator: blockTemplateGenerator,
@@ -2515,8 +3366,33 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		ProcessBlock:           s.syncManager.ProcessBlock,
 		ConnectedCount:         s.ConnectedCount,
 		IsCurrent:              s.syncManager.IsCurrent,
+		SignetKey:              cfg.signetKey,
 	})
 
+	// Serve block templates to Stratum miners when Stratum listeners are
+	// configured.
+	if len(cfg.StratumListeners) > 0 {
+		stratumListeners, err := setupStratumListeners()
+		if err != nil {
+			return nil, err
+		}
+		if len(stratumListeners) == 0 {
+			return nil, errors.New("navd: unable to listen on any " +
+				"stratum interfaces")
+		}
+		s.stratumServer = stratum.New(&stratum.Config{
+			ChainParams:            chainParams,
+			BlockTemplateGenerator: blockTemplateGenerator,
+			MiningAddrs:            cfg.miningAddrs,
+			ProcessBlock:           s.syncManager.ProcessBlock,
+			IsCurrent:              s.syncManager.IsCurrent,
+			Listeners:              stratumListeners,
+			Difficulty:             cfg.StratumDifficulty,
+			MinDifficulty:          cfg.StratumMinDifficulty,
+			ShareInterval:          cfg.StratumShareInterval,
+		})
+	}
+
 	// Only setup a function to return new addresses to connect to when
 	// not running in connect-only mode.  The simulation network is always
 	// in connect-only mode since it is only intended to connect to
@@ -2538,11 +3414,27 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 				// in the same group so that we are not connecting
 				// to the same network segment at the expense of
 				// others.
-				key := addrmgr.GroupKey(addr.NetAddress())
+				key := s.addrManager.GroupKey(addr.NetAddress())
 				if s.OutboundGroupCount(key) != 0 {
 					continue
 				}
 
+				// Skip banned addresses.
+				if _, banned := s.banList.IsBanned(addr.NetAddress().IP); banned {
+					continue
+				}
+
+				// I2P addresses can't be dialed and onion addresses
+				// can only be dialed through tor.
+				na := addr.NetAddress()
+				if addrmgr.IsI2P(na) {
+					continue
+				}
+				if cfg.NoOnion && (addrmgr.IsOnionCatTor(na) ||
+					addrmgr.IsTorV3(na)) {
+					continue
+				}
+
 				// only allow recent nodes (10mins) after we failed 30
 				// times
 				if tries < 30 && time.Since(addr.LastAttempt()) < 10*time.Minute {
@@ -2563,19 +3455,42 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		}
 	}
 
+	// Block-relay-only outbound peers are only connected to when peers
+	// are discovered automatically.  The block-relay-only peers connected
+	// at the previous shutdown are connected to first to make it harder
+	// for an attacker to eclipse the node across restarts.
+	var blockRelayOnlyAddressFunc func() (net.Addr, error)
+	var targetBlockRelayOnly int
+	if newAddressFunc != nil {
+		s.anchors = loadAnchors(filepath.Join(cfg.DataDir,
+			anchorsFilename))
+		blockRelayOnlyAddressFunc = func() (net.Addr, error) {
+			if na := s.popAnchor(); na != nil {
+				return addrStringToNetAddr(addrmgr.NetAddressKey(na))
+			}
+			return newAddressFunc()
+		}
+		targetBlockRelayOnly = defaultBlockRelayOnlyOutbound
+	}
+
 	// Create a connection manager.
 	targetOutbound := defaultTargetOutbound
 	if cfg.MaxPeers < targetOutbound {
 		targetOutbound = cfg.MaxPeers
 	}
+	if cfg.MaxPeers-targetOutbound < targetBlockRelayOnly {
+		targetBlockRelayOnly = cfg.MaxPeers - targetOutbound
+	}
 	cmgr, err := connmgr.New(&connmgr.Config{
-		Listeners:      listeners,
-		OnAccept:       s.inboundPeerConnected,
-		RetryDuration:  connectionRetryInterval,
-		TargetOutbound: uint32(targetOutbound),
-		Dial:           navdDial,
-		OnConnection:   s.outboundPeerConnected,
-		GetNewAddress:  newAddressFunc,
+		Listeners:                listeners,
+		OnAccept:                 s.inboundPeerConnected,
+		RetryDuration:            connectionRetryInterval,
+		TargetOutbound:           uint32(targetOutbound),
+		TargetBlockRelayOnly:     uint32(targetBlockRelayOnly),
+		Dial:                     s.dial,
+		OnConnection:             s.outboundPeerConnected,
+		GetNewAddress:            newAddressFunc,
+		GetBlockRelayOnlyAddress: blockRelayOnlyAddressFunc,
 	})
 	if err != nil {
 		return nil, err
@@ -2749,7 +3664,7 @@ func addrStringToNetAddr(addr string) (net.Addr, error) {
 			return nil, errors.New("tor has been disabled")
 		}
 
-		return &onionAddr{addr: addr}, nil
+		return connmgr.NewOnionAddr(host, port)
 	}
 
 	// Attempt to look up an IP address associated with the parsed host.
@@ -0,0 +1,96 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/navcoin/navd/addrmgr"
+	"github.com/navcoin/navd/chaincfg"
+	"github.com/navcoin/navd/chaincfg/chainhash"
+	"github.com/navcoin/navd/peer"
+	"github.com/navcoin/navd/wire"
+	"github.com/navcoin/navlog"
+)
+
+// newTorTestPeer returns an outbound server peer to the passed address which
+// is connected over an in-memory pipe, along with a function which
+// disconnects it.
+func newTorTestPeer(t *testing.T, amgr *addrmgr.AddrManager, addr string) (*serverPeer, func()) {
+	t.Helper()
+
+	p, err := peer.NewOutboundPeer(&peer.Config{
+		HostToNetAddress: amgr.HostToNetAddress,
+		ChainParams:      &chaincfg.MainNetParams,
+	}, addr)
+	if err != nil {
+		t.Fatalf("NewOutboundPeer(%s): %v", addr, err)
+	}
+	conn, remoteConn := net.Pipe()
+	p.AssociateConnection(conn)
+
+	sp := newServerPeer(nil, false)
+	sp.Peer = p
+	return sp, func() {
+		p.Disconnect()
+		remoteConn.Close()
+	}
+}
+
+// TestTorBroadcastNoTorPeers ensures transactions which are to be broadcast
+// via Tor only are held back while no peers reached over Tor are connected.
+func TestTorBroadcastNoTorPeers(t *testing.T) {
+	oldCfg := cfg
+	cfg = &config{TorBroadcast: true}
+	defer func() { cfg = oldCfg }()
+
+	// The log rotator is not initialized in tests.
+	oldLog := srvrLog
+	srvrLog = navlog.Disabled
+	defer func() { srvrLog = oldLog }()
+
+	dir, err := ioutil.TempDir("", "torbroadcast")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	amgr := addrmgr.New(dir, nil)
+
+	state := &peerState{
+		inboundPeers:    make(map[int32]*serverPeer),
+		outboundPeers:   make(map[int32]*serverPeer),
+		persistentPeers: make(map[int32]*serverPeer),
+		outboundGroups:  make(map[string]int),
+	}
+	if state.hasTorPeer() {
+		t.Fatal("hasTorPeer: got true without any peers")
+	}
+
+	// A transaction to be broadcast via Tor only must not be handed to the
+	// connected clearnet peer.
+	clearnet, teardown := newTorTestPeer(t, amgr, "10.0.0.1:44440")
+	defer teardown()
+	state.outboundPeers[clearnet.ID()] = clearnet
+	if state.hasTorPeer() {
+		t.Fatal("hasTorPeer: got true with only a clearnet peer")
+	}
+	iv := wire.NewInvVect(wire.InvTypeTx, &chainhash.Hash{1})
+	s := &server{}
+	s.handleRelayInvMsg(state, relayMsg{invVect: iv, torOnly: true})
+	if clearnet.HasKnownInventory(iv) {
+		t.Fatal("transaction announced to a clearnet peer")
+	}
+
+	onion, teardown := newTorTestPeer(t, amgr,
+		"2gzyxa5ihm7nsggfxnu52rck2vv4rvmdlkiu3zzui5du4xyclen53wid.onion:44440")
+	defer teardown()
+	state.outboundPeers[onion.ID()] = onion
+	if !state.hasTorPeer() {
+		t.Fatal("hasTorPeer: got false with a peer reached over Tor")
+	}
+}
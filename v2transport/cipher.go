@@ -0,0 +1,120 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package v2transport
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// rekeyInterval is the number of messages encrypted with the same key before
+// the ciphers of a session switch to a new key for forward secrecy.
+const rekeyInterval = 224
+
+// fsChaCha20 is the forward secure stream cipher used to encrypt the lengths
+// of packets.  All lengths encrypted with the same key share a single ChaCha20
+// keystream and the key is replaced with the next 32 bytes of the keystream
+// every rekey interval lengths.
+type fsChaCha20 struct {
+	key         [32]byte
+	stream      *chacha20.Cipher
+	interval    uint32
+	chunkCount  uint32
+	rekeyCount  uint64
+	streamValid bool
+}
+
+// newFSChaCha20 returns a new fsChaCha20 using the provided initial key which
+// is replaced every interval chunks.
+func newFSChaCha20(key []byte, interval uint32) *fsChaCha20 {
+	c := &fsChaCha20{interval: interval}
+	copy(c.key[:], key)
+	return c
+}
+
+// crypt encrypts or decrypts the provided chunk in place.
+func (c *fsChaCha20) crypt(chunk []byte) {
+	if !c.streamValid {
+		var nonce [chacha20.NonceSize]byte
+		binary.LittleEndian.PutUint64(nonce[4:], c.rekeyCount)
+		// The key and nonce sizes are always valid.
+		c.stream, _ = chacha20.NewUnauthenticatedCipher(c.key[:], nonce[:])
+		c.streamValid = true
+	}
+	c.stream.XORKeyStream(chunk, chunk)
+
+	c.chunkCount++
+	if c.chunkCount == c.interval {
+		var newKey [32]byte
+		c.stream.XORKeyStream(newKey[:], newKey[:])
+		c.key = newKey
+		c.chunkCount = 0
+		c.rekeyCount++
+		c.streamValid = false
+	}
+}
+
+// fsChaCha20Poly1305 is the forward secure AEAD used to encrypt the contents
+// of packets.  Every packet is encrypted with a nonce derived from its packet
+// number and the key is replaced every rekey interval packets.
+type fsChaCha20Poly1305 struct {
+	aead        cipher.AEAD
+	interval    uint32
+	packetCount uint32
+	rekeyCount  uint64
+}
+
+// newFSChaCha20Poly1305 returns a new fsChaCha20Poly1305 using the provided
+// initial key which is replaced every interval packets.
+func newFSChaCha20Poly1305(key []byte, interval uint32) *fsChaCha20Poly1305 {
+	// The key size is always valid.
+	aead, _ := chacha20poly1305.New(key)
+	return &fsChaCha20Poly1305{aead: aead, interval: interval}
+}
+
+// nonce returns the nonce for the current packet.
+func (c *fsChaCha20Poly1305) nonce() []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.LittleEndian.PutUint32(nonce, c.packetCount)
+	binary.LittleEndian.PutUint64(nonce[4:], c.rekeyCount)
+	return nonce
+}
+
+// next advances to the next packet and replaces the key when the rekey
+// interval is reached.
+func (c *fsChaCha20Poly1305) next() {
+	c.packetCount++
+	if c.packetCount != c.interval {
+		return
+	}
+
+	// The new key is the encryption of 32 zero bytes using a nonce which
+	// is never used for packets.
+	nonce := c.nonce()
+	binary.LittleEndian.PutUint32(nonce, 0xffffffff)
+	newKey := c.aead.Seal(nil, nonce, make([]byte, 32), nil)
+	c.aead, _ = chacha20poly1305.New(newKey[:32])
+	c.packetCount = 0
+	c.rekeyCount++
+}
+
+// encrypt returns the encryption of the plaintext followed by the
+// authentication tag of the ciphertext and provided additional data.
+func (c *fsChaCha20Poly1305) encrypt(aad, plaintext []byte) []byte {
+	ciphertext := c.aead.Seal(nil, c.nonce(), plaintext, aad)
+	c.next()
+	return ciphertext
+}
+
+// decrypt returns the decryption of the ciphertext after verifying its
+// authentication tag along with the provided additional data.
+func (c *fsChaCha20Poly1305) decrypt(aad, ciphertext []byte) ([]byte, error) {
+	plaintext, err := c.aead.Open(nil, c.nonce(), ciphertext, aad)
+	c.next()
+	return plaintext, err
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package v2transport
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// hexToBytes converts the passed hex string into bytes and will panic if there
+// is an error.  This is only provided for the hard-coded constants so errors in
+// the source code can be detected.  It will only (and must only) be called with
+// hard-coded values.
+func hexToBytes(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic("invalid hex in source file: " + s)
+	}
+	return b
+}
+
+// TestFSChaCha20 ensures the forward secure stream cipher matches the BIP0324
+// test vectors, which check the output after the key has been replaced.
+func TestFSChaCha20(t *testing.T) {
+	tests := []struct {
+		plaintext string
+		key       string
+		interval  uint32
+		want      string // ciphertext after the first rekey
+	}{{
+		plaintext: "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		key:       "0000000000000000000000000000000000000000000000000000000000000000",
+		interval:  256,
+		want:      "a93df4ef03011f3db95f60d996e1785df5de38fc39bfcb663a47bb5561928349",
+	}, {
+		plaintext: "01",
+		key:       "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		interval:  5,
+		want:      "ea",
+	}, {
+		plaintext: "e93fdb5c762804b9a706816aca31e35b11d2aa3080108ef46a5b1f1508819c0a",
+		key:       "8ec4c3ccdaea336bdeb245636970be01266509b33f3d2642504eaf412206207a",
+		interval:  4096,
+		want:      "8bfaa4eacff308fdb4a94a5ff25bd9d0c1f84b77f81239f67ff39d6e1ac280c9",
+	}}
+
+	for i, test := range tests {
+		plaintext := hexToBytes(test.plaintext)
+		enc := newFSChaCha20(hexToBytes(test.key), test.interval)
+		dec := newFSChaCha20(hexToBytes(test.key), test.interval)
+		for j := uint32(0); j <= test.interval; j++ {
+			chunk := append([]byte(nil), plaintext...)
+			enc.crypt(chunk)
+			if j == test.interval {
+				if got := hex.EncodeToString(chunk); got != test.want {
+					t.Fatalf("#%d: got ciphertext %s, want %s", i,
+						got, test.want)
+				}
+			}
+			dec.crypt(chunk)
+			if !bytes.Equal(chunk, plaintext) {
+				t.Fatalf("#%d: chunk %d decrypted to %x, want %x", i,
+					j, chunk, plaintext)
+			}
+		}
+	}
+}
+
+// TestFSChaCha20Poly1305 ensures the forward secure AEAD encrypts packets with
+// the nonce and key schedule of BIP0324, including across rekeys.  The vectors
+// were computed with an independent implementation of the BIP0324 definitions
+// which reproduces the ChaCha20-Poly1305 test vectors of RFC 8439.
+func TestFSChaCha20Poly1305(t *testing.T) {
+	tests := []struct {
+		plaintext string
+		aad       string
+		key       string
+		index     int // number of packets encrypted before
+		want      string
+	}{{
+		plaintext: "",
+		aad:       "",
+		key:       "a819408ce5010ca2e09ef59ac3d89f5ff8595d02b524e61bf8afa894a95d594f",
+		index:     0,
+		want:      "f5556afd0dbf20c3eaeef3d46eab3a65",
+	}, {
+		plaintext: "0a",
+		aad:       "",
+		key:       "8174099687a26621f4e2cdd7cc03b3dacedb3fb962255b1aafd033cabe831530",
+		index:     1,
+		want:      "6562677f2c4c288b5515a669ad7ac14ba4",
+	}, {
+		plaintext: "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f" +
+			"202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f",
+		aad:   "c0ffee",
+		key:   "b10253764c8b233fb37542e23401c7b450e5a6f9751f3b5a014f6f67e8bc999d",
+		index: 223,
+		want: "1989bea950b4c9d33c38590633ca811a33f9beca792b3bb06b29a3c96c524f65" +
+			"4a88b83aff15e5d19802c3b7779cb910a39c30f2ca5aaa22fb7bdbef15ba3c0f" +
+			"20957929dfc2e7967b34515d030b34a4",
+	}, {
+		plaintext: "666f72776172642073656372656379",
+		aad:       "",
+		key:       "f576104eebeab09651d83acffc77c8b8c6eaa4b767aeab24d7da80f83f51d865",
+		index:     224,
+		want:      "e690de9b7e0e4fdcf2e671364c3f07507a8be4ff1a13a11c09409ef1bcac76",
+	}, {
+		plaintext: "6465666768696a6b6c6d6e6f707172737475767778797a7b7c7d7e7f80818283" +
+			"8485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9fa0a1a2a3" +
+			"a4a5a6a7a8a9aaabacadaeafb0b1b2b3b4b5b6b7b8b9babbbcbdbebfc0c1c2c3" +
+			"c4c5c6c7",
+		aad:   "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20",
+		key:   "a4b3504c2769fce9547f6dda310dd8b094d630a044d65f5324d4b37310aab714",
+		index: 1000,
+		want: "3bd6dd54309883c8bb89d88b70255db9f87d633cfcc89b5f189070362ac384c0" +
+			"f6431d18687d2d7a3f124009759899b8af3f173587a51d00c4a0077429cec582" +
+			"a005efda2df3fbe1901ae2f96cbc608aa9b864511cf0caa51de3857c18fbb16e" +
+			"f64c6ec039a985dedb12fc21dba3392b12b7cd4d",
+	}}
+
+	for i, test := range tests {
+		enc := newFSChaCha20Poly1305(hexToBytes(test.key), rekeyInterval)
+		dec := newFSChaCha20Poly1305(hexToBytes(test.key), rekeyInterval)
+		for j := 0; j < test.index; j++ {
+			if _, err := dec.decrypt(nil, enc.encrypt(nil, nil)); err != nil {
+				t.Fatalf("#%d: packet %d: unexpected error: %v", i,
+					j, err)
+			}
+		}
+
+		aad := hexToBytes(test.aad)
+		ciphertext := enc.encrypt(aad, hexToBytes(test.plaintext))
+		if got := hex.EncodeToString(ciphertext); got != test.want {
+			t.Fatalf("#%d: got ciphertext %s, want %s", i, got,
+				test.want)
+		}
+		plaintext, err := dec.decrypt(aad, ciphertext)
+		if err != nil {
+			t.Fatalf("#%d: unexpected error: %v", i, err)
+		}
+		if got := hex.EncodeToString(plaintext); got != test.plaintext {
+			t.Fatalf("#%d: got plaintext %s, want %s", i, got,
+				test.plaintext)
+		}
+	}
+}
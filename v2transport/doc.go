@@ -0,0 +1,28 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package v2transport implements the encrypted v2 transport protocol for navcoin
+peer connections as specified by BIP0324.
+
+Transport Overview
+
+Both sides of a connection exchange ephemeral public keys encoded with
+ElligatorSwift, which makes them indistinguishable from random bytes, followed
+by a random amount of garbage.  The shared secret of the key exchange is used
+to derive the keys of two forward secure ciphers per direction: one which
+encrypts the 3-byte length of each packet and one which encrypts and
+authenticates the contents of each packet with ChaCha20-Poly1305.  Both ciphers
+switch to a new key after every 224 packets.
+
+The contents of the packets carry navcoin messages.  Common messages are
+identified by a single byte short ID instead of the 12-byte command.
+
+Since the side which accepted a connection can't know whether the remote peer
+supports the v2 transport protocol, Respond detects peers starting the
+unencrypted v1 transport protocol and returns a Transport which uses it.  The
+side which opened a connection is expected to reconnect using the v1 transport
+protocol when Initiate fails.
+*/
+package v2transport
@@ -0,0 +1,461 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package v2transport
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"syscall"
+
+	"github.com/navcoin/navd/btcec"
+	"github.com/navcoin/navd/wire"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// ProtocolV1 identifies the unencrypted v1 transport protocol.
+	ProtocolV1 uint32 = 1
+
+	// ProtocolV2 identifies the encrypted v2 transport protocol (BIP0324).
+	ProtocolV2 uint32 = 2
+)
+
+const (
+	// MaxGarbageLen is the maximum number of garbage bytes sent after the
+	// public key during the handshake.
+	MaxGarbageLen = 4095
+
+	// garbageTerminatorLen is the length of the terminator which follows
+	// the garbage.
+	garbageTerminatorLen = 16
+
+	// lengthFieldLen is the length of the encrypted length of a packet.
+	lengthFieldLen = 3
+
+	// headerLen is the length of the header of the packet contents.
+	headerLen = 1
+
+	// ignoreBit is the bit of the packet header which indicates the packet
+	// is a decoy that must be ignored.
+	ignoreBit = 0x80
+
+	// maxContentsLen is the maximum length of the contents of a packet
+	// which carries a message.
+	maxContentsLen = 1 + wire.CommandSize + wire.MaxMessagePayload
+
+	// v1PrefixLen is the number of bytes of the first message of the v1
+	// transport protocol which are compared to detect v1 peers.
+	v1PrefixLen = 16
+
+	// sharedSecretSalt is the prefix of the salt used to derive the keys
+	// of a session.  It is followed by the network magic.
+	sharedSecretSalt = "bitcoin_v2_shared_secret"
+)
+
+var (
+	// ErrGarbageTooLong indicates the remote peer sent more garbage than
+	// allowed without the garbage terminator.
+	ErrGarbageTooLong = errors.New("garbage terminator not found")
+
+	// ErrPacketTooLarge indicates the remote peer sent a packet larger
+	// than any valid message.
+	ErrPacketTooLarge = errors.New("packet too large")
+
+	// ErrDecryptionFailed indicates a packet failed to authenticate.
+	ErrDecryptionFailed = errors.New("packet decryption failed")
+
+	// ErrV1Peer indicates the remote peer closed the connection or started
+	// the v1 transport protocol before sending any of its public key, which
+	// is how peers that only support the v1 transport protocol respond to
+	// the handshake.
+	ErrV1Peer = errors.New("remote peer does not support the v2 " +
+		"transport protocol")
+)
+
+// Transport reads and writes navcoin messages over a connection using either
+// the unencrypted v1 transport protocol or the encrypted v2 transport protocol
+// negotiated by Initiate or Respond.
+//
+// Reading and writing messages may be done concurrently, but neither of them
+// may be done concurrently with itself.
+type Transport struct {
+	rw      io.ReadWriter
+	r       *bufio.Reader
+	navnet  wire.NavCoinNet
+	version uint32
+
+	// The following fields are only used by the v2 transport protocol.
+	sendLength     *fsChaCha20
+	sendPacket     *fsChaCha20Poly1305
+	recvLength     *fsChaCha20
+	recvPacket     *fsChaCha20Poly1305
+	sendTerminator []byte
+	recvTerminator []byte
+	sessionID      [32]byte
+}
+
+// Version returns the transport protocol version used by the transport.
+func (t *Transport) Version() uint32 {
+	return t.version
+}
+
+// SessionID returns the identifier of the session, which is the same for both
+// sides of the connection.  It is only set for the v2 transport protocol.
+func (t *Transport) SessionID() []byte {
+	if t.version != ProtocolV2 {
+		return nil
+	}
+	return t.sessionID[:]
+}
+
+// v1Prefix returns the first bytes of a version message sent using the v1
+// transport protocol on the provided network.
+func v1Prefix(navnet wire.NavCoinNet) []byte {
+	prefix := make([]byte, v1PrefixLen)
+	binary.LittleEndian.PutUint32(prefix, uint32(navnet))
+	copy(prefix[4:], wire.CmdVersion)
+	return prefix
+}
+
+// Initiate performs the handshake of the v2 transport protocol as the side
+// which opened the connection and returns the resulting transport.  Remote
+// peers which only support the v1 transport protocol close the connection
+// during the handshake, in which case ErrV1Peer is returned and callers should
+// reconnect using the v1 transport protocol.
+func Initiate(rw io.ReadWriter, navnet wire.NavCoinNet) (*Transport, error) {
+	t := &Transport{
+		rw:      rw,
+		r:       bufio.NewReader(rw),
+		navnet:  navnet,
+		version: ProtocolV2,
+	}
+
+	privKey, ourPubKey, err := btcec.EllswiftCreate()
+	if err != nil {
+		return nil, err
+	}
+	garbage, err := randomGarbage()
+	if err != nil {
+		return nil, err
+	}
+	sendDone := t.writeAsync(nil, ourPubKey[:], garbage)
+
+	var theirPubKey [btcec.EllswiftEncodingSize]byte
+	if n, err := io.ReadFull(t.r, theirPubKey[:]); err != nil {
+		if n == 0 && isClosedByPeer(err) {
+			return nil, ErrV1Peer
+		}
+		return nil, err
+	}
+	if bytes.Equal(theirPubKey[:v1PrefixLen], v1Prefix(navnet)) {
+		return nil, ErrV1Peer
+	}
+	secret := btcec.EllswiftXDH(privKey, ourPubKey, theirPubKey, true)
+	if err := t.initSession(secret, true); err != nil {
+		return nil, err
+	}
+
+	if err := t.finishHandshake(garbage, sendDone); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Respond performs the handshake of the v2 transport protocol as the side
+// which accepted the connection and returns the resulting transport.  When
+// the remote peer starts the v1 transport protocol instead, a transport using
+// the v1 transport protocol is returned.
+func Respond(rw io.ReadWriter, navnet wire.NavCoinNet) (*Transport, error) {
+	var theirPubKey [btcec.EllswiftEncodingSize]byte
+	if _, err := io.ReadFull(rw, theirPubKey[:v1PrefixLen]); err != nil {
+		return nil, err
+	}
+
+	// Replay the bytes which were already read to v1 peers.
+	prefix := theirPubKey[:v1PrefixLen]
+	if bytes.Equal(prefix, v1Prefix(navnet)) {
+		r := io.MultiReader(bytes.NewReader(prefix), rw)
+		return &Transport{
+			rw:      rw,
+			r:       bufio.NewReader(r),
+			navnet:  navnet,
+			version: ProtocolV1,
+		}, nil
+	}
+
+	t := &Transport{
+		rw:      rw,
+		r:       bufio.NewReader(rw),
+		navnet:  navnet,
+		version: ProtocolV2,
+	}
+	if _, err := io.ReadFull(t.r, theirPubKey[v1PrefixLen:]); err != nil {
+		return nil, err
+	}
+
+	privKey, ourPubKey, err := btcec.EllswiftCreate()
+	if err != nil {
+		return nil, err
+	}
+	garbage, err := randomGarbage()
+	if err != nil {
+		return nil, err
+	}
+	secret := btcec.EllswiftXDH(privKey, ourPubKey, theirPubKey, false)
+	if err := t.initSession(secret, false); err != nil {
+		return nil, err
+	}
+
+	if err := t.finishHandshake(garbage, nil, ourPubKey[:], garbage); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// isClosedByPeer returns whether the provided read error was caused by the
+// remote peer closing the connection.
+func isClosedByPeer(err error) bool {
+	return err == io.EOF || errors.Is(err, syscall.ECONNRESET)
+}
+
+// randomGarbage returns a random amount of random bytes to send after the
+// public key during the handshake.
+func randomGarbage() ([]byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(MaxGarbageLen+1))
+	if err != nil {
+		return nil, err
+	}
+	garbage := make([]byte, n.Int64())
+	if _, err := rand.Read(garbage); err != nil {
+		return nil, err
+	}
+	return garbage, nil
+}
+
+// writeAsync writes the concatenation of the provided byte slices without
+// blocking the caller and returns a channel which receives the result.  This
+// allows both sides of the handshake to read while they are writing.  The
+// write starts once the previous write signalled by prev, if any, succeeded.
+func (t *Transport) writeAsync(prev <-chan error, parts ...[]byte) <-chan error {
+	buf := bytes.Join(parts, nil)
+	done := make(chan error, 1)
+	go func() {
+		if prev != nil {
+			if err := <-prev; err != nil {
+				done <- err
+				return
+			}
+		}
+		_, err := t.rw.Write(buf)
+		done <- err
+	}()
+	return done
+}
+
+// initSession derives the keys of the session from the shared secret and sets
+// up the ciphers of both directions.
+func (t *Transport) initSession(secret [32]byte, initiator bool) error {
+	salt := make([]byte, len(sharedSecretSalt)+4)
+	copy(salt, sharedSecretSalt)
+	binary.LittleEndian.PutUint32(salt[len(sharedSecretSalt):],
+		uint32(t.navnet))
+	prk := hkdf.Extract(sha256.New, secret[:], salt)
+
+	derive := func(info string, size int) ([]byte, error) {
+		key := make([]byte, size)
+		r := hkdf.Expand(sha256.New, prk, []byte(info))
+		if _, err := io.ReadFull(r, key); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	var keys [5][]byte
+	infos := []string{"initiator_L", "initiator_P", "responder_L",
+		"responder_P", "garbage_terminators"}
+	for i, info := range infos {
+		size := chacha20poly1305.KeySize
+		var err error
+		if keys[i], err = derive(info, size); err != nil {
+			return err
+		}
+	}
+	sessionID, err := derive("session_id", len(t.sessionID))
+	if err != nil {
+		return err
+	}
+	copy(t.sessionID[:], sessionID)
+
+	initiatorL, initiatorP := keys[0], keys[1]
+	responderL, responderP := keys[2], keys[3]
+	terminators := keys[4]
+	if initiator {
+		t.sendLength = newFSChaCha20(initiatorL, rekeyInterval)
+		t.sendPacket = newFSChaCha20Poly1305(initiatorP, rekeyInterval)
+		t.recvLength = newFSChaCha20(responderL, rekeyInterval)
+		t.recvPacket = newFSChaCha20Poly1305(responderP, rekeyInterval)
+		t.sendTerminator = terminators[:garbageTerminatorLen]
+		t.recvTerminator = terminators[garbageTerminatorLen:]
+	} else {
+		t.sendLength = newFSChaCha20(responderL, rekeyInterval)
+		t.sendPacket = newFSChaCha20Poly1305(responderP, rekeyInterval)
+		t.recvLength = newFSChaCha20(initiatorL, rekeyInterval)
+		t.recvPacket = newFSChaCha20Poly1305(initiatorP, rekeyInterval)
+		t.sendTerminator = terminators[garbageTerminatorLen:]
+		t.recvTerminator = terminators[:garbageTerminatorLen]
+	}
+	return nil
+}
+
+// finishHandshake sends the provided prefix followed by the garbage
+// terminator and the version packet which authenticates the provided garbage
+// sent by the local side, then receives the same from the remote peer.  The
+// sending starts after the previous write signalled by prev, if any.
+func (t *Transport) finishHandshake(garbage []byte, prev <-chan error,
+	prefix ...[]byte) error {
+
+	version := t.encryptPacket(garbage, nil, false)
+	parts := append(prefix, t.sendTerminator, version)
+	sendDone := t.writeAsync(prev, parts...)
+
+	// Receive the garbage of the remote peer up to its terminator.
+	theirGarbage := make([]byte, 0, MaxGarbageLen+garbageTerminatorLen)
+	for !bytes.HasSuffix(theirGarbage, t.recvTerminator) {
+		if len(theirGarbage) == cap(theirGarbage) {
+			return ErrGarbageTooLong
+		}
+		b, err := t.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		theirGarbage = append(theirGarbage, b)
+	}
+	theirGarbage = theirGarbage[:len(theirGarbage)-garbageTerminatorLen]
+
+	// The first packet authenticates the garbage.  Any decoy packets are
+	// skipped and the contents of the version packet are ignored since no
+	// features are negotiated through it yet.
+	aad := theirGarbage
+	for {
+		_, ignore, _, err := t.readPacket(aad)
+		if err != nil {
+			return err
+		}
+		aad = nil
+		if !ignore {
+			break
+		}
+	}
+
+	return <-sendDone
+}
+
+// encryptPacket returns the encrypted packet which carries the provided
+// contents and additional authenticated data.
+func (t *Transport) encryptPacket(aad, contents []byte, ignore bool) []byte {
+	var length [lengthFieldLen]byte
+	length[0] = byte(len(contents))
+	length[1] = byte(len(contents) >> 8)
+	length[2] = byte(len(contents) >> 16)
+	t.sendLength.crypt(length[:])
+
+	plaintext := make([]byte, headerLen+len(contents))
+	if ignore {
+		plaintext[0] = ignoreBit
+	}
+	copy(plaintext[headerLen:], contents)
+
+	packet := make([]byte, 0, lengthFieldLen+len(plaintext)+
+		chacha20poly1305.Overhead)
+	packet = append(packet, length[:]...)
+	return append(packet, t.sendPacket.encrypt(aad, plaintext)...)
+}
+
+// readPacket reads and decrypts the next packet using the provided additional
+// authenticated data.  It returns the contents of the packet, whether it must
+// be ignored and the number of bytes read.
+func (t *Transport) readPacket(aad []byte) ([]byte, bool, int, error) {
+	var length [lengthFieldLen]byte
+	n, err := io.ReadFull(t.r, length[:])
+	if err != nil {
+		return nil, false, n, err
+	}
+	t.recvLength.crypt(length[:])
+	contentsLen := int(length[0]) | int(length[1])<<8 | int(length[2])<<16
+	if contentsLen > maxContentsLen {
+		return nil, false, n, ErrPacketTooLarge
+	}
+
+	ciphertext := make([]byte, headerLen+contentsLen+
+		chacha20poly1305.Overhead)
+	read, err := io.ReadFull(t.r, ciphertext)
+	n += read
+	if err != nil {
+		return nil, false, n, err
+	}
+	plaintext, err := t.recvPacket.decrypt(aad, ciphertext)
+	if err != nil {
+		return nil, false, n, ErrDecryptionFailed
+	}
+
+	ignore := plaintext[0]&ignoreBit != 0
+	return plaintext[headerLen:], ignore, n, nil
+}
+
+// ReadMessage reads, validates, and parses the next navcoin message using the
+// provided protocol version and message encoding.  It returns the number of
+// bytes read in addition to the parsed message and its raw payload.  Decoy
+// packets of the v2 transport protocol are skipped.
+func (t *Transport) ReadMessage(pver uint32,
+	enc wire.MessageEncoding) (int, wire.Message, []byte, error) {
+
+	if t.version == ProtocolV1 {
+		return wire.ReadMessageWithEncodingN(t.r, pver, t.navnet, enc)
+	}
+
+	totalBytes := 0
+	for {
+		contents, ignore, n, err := t.readPacket(nil)
+		totalBytes += n
+		if err != nil {
+			return totalBytes, nil, nil, err
+		}
+		if ignore {
+			continue
+		}
+
+		msg, payload, err := wire.DecodeV2Message(contents, pver, enc)
+		return totalBytes, msg, payload, err
+	}
+}
+
+// WriteMessage writes the provided navcoin message using the provided protocol
+// version and message encoding.  It returns the number of bytes written.
+func (t *Transport) WriteMessage(msg wire.Message, pver uint32,
+	enc wire.MessageEncoding) (int, error) {
+
+	if t.version == ProtocolV1 {
+		return wire.WriteMessageWithEncodingN(t.rw, msg, pver,
+			t.navnet, enc)
+	}
+
+	contents, err := wire.EncodeV2Message(msg, pver, enc)
+	if err != nil {
+		return 0, err
+	}
+	if len(contents) > maxContentsLen {
+		str := fmt.Sprintf("message contents of %d bytes exceed the "+
+			"max of %d bytes", len(contents), maxContentsLen)
+		return 0, errors.New(str)
+	}
+	return t.rw.Write(t.encryptPacket(nil, contents, false))
+}
@@ -0,0 +1,329 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package v2transport
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/navcoin/navd/wire"
+)
+
+// handshake performs the v2 transport handshake over an in-memory connection
+// and returns the transports of the initiator and responder.
+func handshake(t *testing.T) (*Transport, *Transport, net.Conn, net.Conn) {
+	t.Helper()
+
+	initConn, respConn := net.Pipe()
+	type result struct {
+		t   *Transport
+		err error
+	}
+	respResult := make(chan result, 1)
+	go func() {
+		tr, err := Respond(respConn, wire.MainNet)
+		respResult <- result{tr, err}
+	}()
+
+	initiator, err := Initiate(initConn, wire.MainNet)
+	if err != nil {
+		t.Fatalf("Initiate: unexpected error: %v", err)
+	}
+	var resp result
+	select {
+	case resp = <-respResult:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the responder")
+	}
+	if resp.err != nil {
+		t.Fatalf("Respond: unexpected error: %v", resp.err)
+	}
+	return initiator, resp.t, initConn, respConn
+}
+
+// TestHandshake ensures both sides of a v2 connection complete the handshake
+// with the same session and are able to exchange messages in both directions,
+// including across the rekeying of the ciphers.
+func TestHandshake(t *testing.T) {
+	initiator, responder, initConn, respConn := handshake(t)
+	defer initConn.Close()
+	defer respConn.Close()
+
+	if initiator.Version() != ProtocolV2 || responder.Version() != ProtocolV2 {
+		t.Fatalf("unexpected transport versions %d and %d",
+			initiator.Version(), responder.Version())
+	}
+	if !bytes.Equal(initiator.SessionID(), responder.SessionID()) {
+		t.Fatalf("mismatched session ids %x and %x",
+			initiator.SessionID(), responder.SessionID())
+	}
+
+	// Send enough messages in both directions to rekey the ciphers.
+	pver := wire.ProtocolVersion
+	for _, dir := range []struct {
+		from, to *Transport
+	}{
+		{initiator, responder},
+		{responder, initiator},
+	} {
+		for i := 0; i < rekeyInterval*2+10; i++ {
+			var msg wire.Message = wire.NewMsgPing(uint64(i))
+			if i%3 == 0 {
+				msg = wire.NewMsgVerAck()
+			}
+			errc := make(chan error, 1)
+			go func() {
+				_, err := dir.from.WriteMessage(msg, pver,
+					wire.LatestEncoding)
+				errc <- err
+			}()
+			_, got, _, err := dir.to.ReadMessage(pver,
+				wire.LatestEncoding)
+			if err != nil {
+				t.Fatalf("ReadMessage #%d: unexpected error: %v",
+					i, err)
+			}
+			if err := <-errc; err != nil {
+				t.Fatalf("WriteMessage #%d: unexpected error: %v",
+					i, err)
+			}
+			if !reflect.DeepEqual(got, msg) {
+				t.Fatalf("ReadMessage #%d: got %v want %v", i,
+					got, msg)
+			}
+		}
+	}
+}
+
+// TestDecoyPackets ensures packets with the ignore bit set are skipped.
+func TestDecoyPackets(t *testing.T) {
+	initiator, responder, initConn, respConn := handshake(t)
+	defer initConn.Close()
+	defer respConn.Close()
+
+	go func() {
+		decoy := initiator.encryptPacket(nil, []byte{1, 2, 3}, true)
+		initConn.Write(decoy)
+		initiator.WriteMessage(wire.NewMsgPing(1), wire.ProtocolVersion,
+			wire.LatestEncoding)
+	}()
+	_, msg, _, err := responder.ReadMessage(wire.ProtocolVersion,
+		wire.LatestEncoding)
+	if err != nil {
+		t.Fatalf("ReadMessage: unexpected error: %v", err)
+	}
+	if ping, ok := msg.(*wire.MsgPing); !ok || ping.Nonce != 1 {
+		t.Fatalf("ReadMessage: unexpected message %v", msg)
+	}
+}
+
+// TestTamperedPacket ensures packets which were modified fail to decrypt.
+func TestTamperedPacket(t *testing.T) {
+	initiator, responder, initConn, respConn := handshake(t)
+	defer initConn.Close()
+	defer respConn.Close()
+
+	go func() {
+		packet := initiator.encryptPacket(nil, []byte{0x12, 0, 0, 0,
+			0, 0, 0, 0, 0}, false)
+		packet[len(packet)-1] ^= 0x01
+		initConn.Write(packet)
+	}()
+	_, _, _, err := responder.ReadMessage(wire.ProtocolVersion,
+		wire.LatestEncoding)
+	if err != ErrDecryptionFailed {
+		t.Fatalf("ReadMessage: got error %v, want %v", err,
+			ErrDecryptionFailed)
+	}
+}
+
+// TestRespondV1 ensures the responder falls back to the v1 transport protocol
+// when the remote peer starts it.
+func TestRespondV1(t *testing.T) {
+	initConn, respConn := net.Pipe()
+	defer initConn.Close()
+	defer respConn.Close()
+
+	pver := wire.ProtocolVersion
+	me := wire.NewNetAddressIPPort(net.ParseIP("127.0.0.1"), 8333, 0)
+	msg := wire.NewMsgVersion(me, me, 1, 0)
+	go wire.WriteMessageWithEncodingN(initConn, msg, pver, wire.MainNet,
+		wire.LatestEncoding)
+
+	responder, err := Respond(respConn, wire.MainNet)
+	if err != nil {
+		t.Fatalf("Respond: unexpected error: %v", err)
+	}
+	if responder.Version() != ProtocolV1 {
+		t.Fatalf("Respond: got version %d, want %d",
+			responder.Version(), ProtocolV1)
+	}
+	_, got, _, err := responder.ReadMessage(pver, wire.LatestEncoding)
+	if err != nil {
+		t.Fatalf("ReadMessage: unexpected error: %v", err)
+	}
+	if _, ok := got.(*wire.MsgVersion); !ok {
+		t.Fatalf("ReadMessage: unexpected message %v", got)
+	}
+
+	// Replies are sent using the v1 transport protocol as well.
+	go responder.WriteMessage(wire.NewMsgVerAck(), pver,
+		wire.LatestEncoding)
+	_, reply, _, err := wire.ReadMessageWithEncodingN(initConn, pver,
+		wire.MainNet, wire.LatestEncoding)
+	if err != nil {
+		t.Fatalf("ReadMessageWithEncodingN: unexpected error: %v", err)
+	}
+	if _, ok := reply.(*wire.MsgVerAck); !ok {
+		t.Fatalf("ReadMessageWithEncodingN: unexpected message %v",
+			reply)
+	}
+}
+
+// TestInitiateV1 ensures the handshake reports ErrV1Peer only when the remote
+// peer refuses it the way peers which only support the v1 transport protocol
+// do, and not for other failures such as timeouts.
+func TestInitiateV1(t *testing.T) {
+	tests := []struct {
+		name   string
+		remote func(conn net.Conn)
+		v1Peer bool
+	}{{
+		// A v1 peer reads a message header with an unknown network and
+		// disconnects.
+		name: "closed",
+		remote: func(conn net.Conn) {
+			wire.ReadMessageWithEncodingN(conn, wire.ProtocolVersion,
+				wire.MainNet, wire.LatestEncoding)
+			conn.Close()
+		},
+		v1Peer: true,
+	}, {
+		name: "v1 version message",
+		remote: func(conn net.Conn) {
+			me := wire.NewNetAddressIPPort(net.ParseIP("10.0.0.2"),
+				8333, 0)
+			you := wire.NewNetAddressIPPort(net.ParseIP("10.0.0.1"),
+				8333, 0)
+			msg := wire.NewMsgVersion(me, you, 1, 0)
+			wire.WriteMessage(conn, msg, wire.ProtocolVersion,
+				wire.MainNet)
+		},
+		v1Peer: true,
+	}, {
+		name: "closed during public key",
+		remote: func(conn net.Conn) {
+			conn.Write(make([]byte, 32))
+			conn.Close()
+		},
+		v1Peer: false,
+	}, {
+		name:   "timeout",
+		remote: func(conn net.Conn) {},
+		v1Peer: false,
+	}}
+
+	for _, test := range tests {
+		initConn, respConn := net.Pipe()
+		initConn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		go test.remote(respConn)
+
+		_, err := Initiate(initConn, wire.MainNet)
+		if err == nil {
+			t.Errorf("%s: Initiate: expected error", test.name)
+		} else if v1Peer := err == ErrV1Peer; v1Peer != test.v1Peer {
+			t.Errorf("%s: Initiate: got error %v, want v1 peer %v",
+				test.name, err, test.v1Peer)
+		}
+		initConn.Close()
+		respConn.Close()
+	}
+}
+
+// TestPacketEncoding ensures the keys of a session are derived from the shared
+// secret and network magic and packets are encoded as specified by BIP0324.
+// The vectors were computed with an independent implementation of the BIP0324
+// definitions.
+func TestPacketEncoding(t *testing.T) {
+	var secret [32]byte
+	copy(secret[:], hexToBytes("56b85fece819106242ebb9000d6a173e"+
+		"acba88c1bdb51a9acd6859fcf5d9fef4"))
+	tr := &Transport{navnet: wire.MainNet, version: ProtocolV2}
+	if err := tr.initSession(secret, true); err != nil {
+		t.Fatalf("initSession: unexpected error: %v", err)
+	}
+
+	wantSessionID := "9ec2742efc796363a03e4b75fb3ca9b6d217c907240eb2e4be6b5c91c72065d3"
+	if got := hex.EncodeToString(tr.SessionID()); got != wantSessionID {
+		t.Fatalf("got session id %s, want %s", got, wantSessionID)
+	}
+	wantSendTerminator := "7c375e56efc5b989546c19c2c09d5929"
+	if got := hex.EncodeToString(tr.sendTerminator); got != wantSendTerminator {
+		t.Fatalf("got send garbage terminator %s, want %s", got,
+			wantSendTerminator)
+	}
+	wantRecvTerminator := "bf0deb82a6baf7113d2e79ee6e341d83"
+	if got := hex.EncodeToString(tr.recvTerminator); got != wantRecvTerminator {
+		t.Fatalf("got receive garbage terminator %s, want %s", got,
+			wantRecvTerminator)
+	}
+
+	tests := []struct {
+		index    int // number of packets sent before
+		contents string
+		ignore   bool
+		want     string
+	}{{
+		index: 0,
+		want:  "6180ac245dd0dd71b3dc46a3f000fd26100ca696",
+	}, {
+		index:    1,
+		contents: "0d0700000000000000",
+		want:     "339538987ab51843a1b89e16c110103589c203d4988cde3e885df146a8",
+	}, {
+		index: 223,
+		contents: "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f" +
+			"202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f" +
+			"404142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f" +
+			"606162636465666768696a6b6c6d6e6f707172737475767778797a7b7c7d7e7f" +
+			"808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f" +
+			"a0a1a2a3a4a5a6a7a8a9aaabacadaeafb0b1b2b3b4b5b6b7b8b9babbbcbdbebf" +
+			"c0c1c2c3c4c5c6c7",
+		ignore: true,
+		want: "3dc9ee3d925ea93a5b92cd11921219876e964becb7d45989cacd1aec6ec47e39" +
+			"94ede7a9cea7b6427194e70164e02ae976c8b0f87967f138f096c0d0c8afc47e" +
+			"c9fd88492f968335b38d1480e6fdfe1e493a02200e7b7ff697bc974ae8d38df7" +
+			"5da33849ca85e0123036f21f2addfd5f4c63e5682fc339279b6c4319c3d07d19" +
+			"dd6b7ecef2abf0a928f040ddee81527ca788f376dbfe849b8f860f2eeede2233" +
+			"8b5a0de1600091a5cff1daee076567f8323d26263cecd210f16a619941f2989f" +
+			"c25e7898d6a1a39de42cbc7068dac6ad12aa49e6cd1bababcd9f4947",
+	}, {
+		index:    224,
+		contents: "12",
+		want:     "066eb0b21b37d2f87aa989f964fcda040a0aa1dedb",
+	}, {
+		index:  449,
+		ignore: true,
+		want:   "f8a2b0428cda595e6e0a68427f3952f04585edcc",
+	}}
+
+	sent := 0
+	for _, test := range tests {
+		for ; sent < test.index; sent++ {
+			tr.encryptPacket(nil, nil, false)
+		}
+		packet := tr.encryptPacket(nil, hexToBytes(test.contents),
+			test.ignore)
+		sent++
+		if got := hex.EncodeToString(packet); got != test.want {
+			t.Fatalf("packet %d: got %s, want %s", test.index, got,
+				test.want)
+		}
+	}
+}
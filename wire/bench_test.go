@@ -378,6 +378,28 @@ func BenchmarkWriteBlockHeader(b *testing.B) {
 	}
 }
 
+// BenchmarkBlockHeaderHash performs a benchmark on how long it takes to hash
+// block headers which only differ by their nonce by serializing the whole
+// header for every nonce.
+func BenchmarkBlockHeaderHash(b *testing.B) {
+	header := blockOne.Header
+	for i := 0; i < b.N; i++ {
+		header.Nonce = uint32(i)
+		header.BlockHash()
+	}
+}
+
+// BenchmarkPrehashedBlockHeaderHash performs a benchmark on how long it takes
+// to hash block headers which only differ by their nonce using a prehashed
+// header.
+func BenchmarkPrehashedBlockHeaderHash(b *testing.B) {
+	header := blockOne.Header
+	prehashed := header.Prehash()
+	for i := 0; i < b.N; i++ {
+		prehashed.Hash(uint32(i))
+	}
+}
+
 // BenchmarkDecodeGetHeaders performs a benchmark on how long it takes to
 // decode a getheaders message with the maximum number of block locator hashes.
 func BenchmarkDecodeGetHeaders(b *testing.B) {
@@ -60,6 +60,21 @@ func (h *BlockHeader) BlockHash() chainhash.Hash {
 	}
 }
 
+// Prehash returns a prehashed header for the block header which allows headers
+// only differing by their nonce to be hashed without serializing the whole
+// header again.  The returned prehashed header is not updated when the block
+// header changes, so a new one must be created after modifying any field other
+// than the nonce.
+func (h *BlockHeader) Prehash() *chainhash.PrehashedHeader {
+	// Ignore the error returns since there is no way the encode could fail
+	// except being out of memory which would cause a run-time panic, and
+	// the serialized header is always the expected size.
+	buf := bytes.NewBuffer(make([]byte, 0, MaxBlockHeaderPayload))
+	_ = writeBlockHeader(buf, 0, h)
+	prehashed, _ := chainhash.NewPrehashedHeader(buf.Bytes(), h.Version <= 6)
+	return prehashed
+}
+
 // BtcDecode decodes r using the navcoin protocol encoding into the receiver.
 // This is part of the Message interface implementation.
 // See Deserialize for decoding block headers stored to disk, such as in a
@@ -259,3 +259,22 @@ func TestBlockHeaderSerialize(t *testing.T) {
 		}
 	}
 }
+
+// TestBlockHeaderPrehash ensures prehashed block headers hash to the same value
+// as the block hash for both X13 and double sha256 header versions.
+func TestBlockHeaderPrehash(t *testing.T) {
+	hash := mainNetGenesisHash
+	merkleHash := mainNetGenesisMerkleRoot
+	for _, version := range []int32{1, 6, 7} {
+		bh := NewBlockHeader(version, &hash, &merkleHash, 0x1d00ffff, 0)
+		prehashed := bh.Prehash()
+		for _, nonce := range []uint32{0, 1, 0x9962e301} {
+			bh.Nonce = nonce
+			want := bh.BlockHash()
+			if got := prehashed.Hash(nonce); got != want {
+				t.Errorf("Prehash version %d nonce %d: got %v, "+
+					"want %v", version, nonce, got, want)
+			}
+		}
+	}
+}
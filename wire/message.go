@@ -57,6 +57,13 @@ const (
 	CmdCFilter      = "cfilter"
 	CmdCFHeaders    = "cfheaders"
 	CmdCFTypes      = "cftypes"
+	CmdSendCmpct    = "sendcmpct"
+	CmdCmpctBlock   = "cmpctblock"
+	CmdGetBlockTxn  = "getblocktxn"
+	CmdBlockTxn     = "blocktxn"
+	CmdSendAddrV2   = "sendaddrv2"
+	CmdAddrV2       = "addrv2"
+	CmdWTxIDRelay   = "wtxidrelay"
 )
 
 // MessageEncoding represents the wire message encoding format to be used.
@@ -180,6 +187,27 @@ func makeEmptyMessage(command string) (Message, error) {
 	case CmdCFTypes:
 		msg = &MsgCFTypes{}
 
+	case CmdSendCmpct:
+		msg = &MsgSendCmpct{}
+
+	case CmdCmpctBlock:
+		msg = &MsgCmpctBlock{}
+
+	case CmdGetBlockTxn:
+		msg = &MsgGetBlockTxn{}
+
+	case CmdBlockTxn:
+		msg = &MsgBlockTxn{}
+
+	case CmdSendAddrV2:
+		msg = &MsgSendAddrV2{}
+
+	case CmdAddrV2:
+		msg = &MsgAddrV2{}
+
+	case CmdWTxIDRelay:
+		msg = &MsgWTxIDRelay{}
+
 	default:
 		return nil, fmt.Errorf("unhandled command [%s]", command)
 	}
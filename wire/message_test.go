@@ -76,6 +76,13 @@ func TestMessage(t *testing.T) {
 		[]byte("payload"))
 	msgCFHeaders := NewMsgCFHeaders()
 	msgCFTypes := NewMsgCFTypes([]FilterType{GCSFilterExtended})
+	msgSendCmpct := NewMsgSendCmpct(true, CmpctBlockVersion1)
+	msgCmpctBlock := NewMsgCmpctBlock(&blockOne, CmpctBlockVersion1, 123123)
+	msgGetBlockTxn := NewMsgGetBlockTxn(&chainhash.Hash{}, []uint32{1, 3})
+	msgBlockTxn := NewMsgBlockTxn(&chainhash.Hash{})
+	msgSendAddrV2 := NewMsgSendAddrV2()
+	msgAddrV2 := NewMsgAddrV2()
+	msgWTxIDRelay := NewMsgWTxIDRelay()
 
 	tests := []struct {
 		in     Message    // Value to encode
@@ -111,6 +118,13 @@ func TestMessage(t *testing.T) {
 		{msgCFilter, msgCFilter, pver, MainNet, 65},
 		{msgCFHeaders, msgCFHeaders, pver, MainNet, 58},
 		{msgCFTypes, msgCFTypes, pver, MainNet, 26},
+		{msgSendCmpct, msgSendCmpct, pver, MainNet, 33},
+		{msgCmpctBlock, msgCmpctBlock, pver, MainNet, 254},
+		{msgGetBlockTxn, msgGetBlockTxn, pver, MainNet, 59},
+		{msgBlockTxn, msgBlockTxn, pver, MainNet, 57},
+		{msgSendAddrV2, msgSendAddrV2, pver, MainNet, 24},
+		{msgAddrV2, msgAddrV2, pver, MainNet, 25},
+		{msgWTxIDRelay, msgWTxIDRelay, pver, MainNet, 24},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -0,0 +1,387 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// torV3Addr is a Tor v3 onion address used throughout the addrv2 tests.
+var torV3Addr = []byte{
+	0x53, 0xcd, 0x5d, 0xa3, 0x87, 0x1c, 0x2f, 0x72,
+	0x2b, 0xa1, 0x2e, 0x1a, 0x60, 0x1c, 0x61, 0x9d,
+	0x14, 0x5f, 0x7c, 0x05, 0x46, 0x70, 0x5e, 0x66,
+	0x27, 0x3e, 0x7f, 0x72, 0x16, 0x22, 0x35, 0x4d,
+}
+
+// TestAddrV2 tests the MsgAddrV2 API.
+func TestAddrV2(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "addrv2"
+	msg := NewMsgAddrV2()
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgAddrV2: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	// Num addresses (varInt) + max allowed addresses.
+	wantPayload := uint32(537009)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure NetAddresses are added properly.
+	na, err := NewNetAddressNetwork(NetTorV3, torV3Addr, 8333,
+		SFNodeNetwork)
+	if err != nil {
+		t.Fatalf("NewNetAddressNetwork: %v", err)
+	}
+	err = msg.AddAddress(na)
+	if err != nil {
+		t.Errorf("AddAddress: %v", err)
+	}
+	if msg.AddrList[0] != na {
+		t.Errorf("AddAddress: wrong address added - got %v, want %v",
+			spew.Sprint(msg.AddrList[0]), spew.Sprint(na))
+	}
+
+	// Ensure the address list is cleared properly.
+	msg.ClearAddresses()
+	if len(msg.AddrList) != 0 {
+		t.Errorf("ClearAddresses: address list is not empty - "+
+			"got %v [%v], want %v", len(msg.AddrList),
+			spew.Sprint(msg.AddrList[0]), 0)
+	}
+
+	// Ensure adding more than the max allowed addresses per message returns
+	// error.
+	for i := 0; i < MaxAddrPerMsg+1; i++ {
+		err = msg.AddAddress(na)
+	}
+	if err == nil {
+		t.Errorf("AddAddress: expected error on too many addresses " +
+			"not received")
+	}
+	err = msg.AddAddresses(na)
+	if err == nil {
+		t.Errorf("AddAddresses: expected error on too many addresses " +
+			"not received")
+	}
+}
+
+// TestNetAddressNetwork tests the network identification and conversion of
+// NetAddresses used by addrv2 messages.
+func TestNetAddressNetwork(t *testing.T) {
+	torV2Addr := []byte{0xff, 0xfe, 0xfd, 0xfc, 0xfb, 0xfa, 0xf9, 0xf8,
+		0xf7, 0xf6}
+	tests := []struct {
+		network    NetworkID // Network of the address
+		addr       []byte    // Address on the network
+		ip         net.IP    // Expected IP representation
+		requiresV2 bool      // Whether the address requires addrv2
+	}{
+		{NetIPv4, []byte{127, 0, 0, 1}, net.ParseIP("127.0.0.1"), false},
+		{NetIPv6, net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::1"),
+			false},
+		{NetTorV2, torV2Addr,
+			net.ParseIP("fd87:d87e:eb43:fffe:fdfc:fbfa:f9f8:f7f6"), false},
+		{NetTorV3, torV3Addr, nil, true},
+		{NetI2P, torV3Addr, nil, true},
+		{NetworkID(0xff), []byte{0x01, 0x02}, nil, true},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		na, err := NewNetAddressNetwork(test.network, test.addr, 8333, 0)
+		if err != nil {
+			t.Errorf("NewNetAddressNetwork #%d error %v", i, err)
+			continue
+		}
+		if !na.IP.Equal(test.ip) {
+			t.Errorf("NewNetAddressNetwork #%d wrong ip - got %v, "+
+				"want %v", i, na.IP, test.ip)
+			continue
+		}
+		if na.RequiresAddrV2() != test.requiresV2 {
+			t.Errorf("RequiresAddrV2 #%d got %v, want %v", i,
+				na.RequiresAddrV2(), test.requiresV2)
+			continue
+		}
+		if na.NetworkID() != test.network {
+			t.Errorf("NetworkID #%d got %v, want %v", i,
+				na.NetworkID(), test.network)
+			continue
+		}
+	}
+
+	// Ensure addresses of known networks with the wrong size are rejected.
+	_, err := NewNetAddressNetwork(NetTorV3, torV2Addr, 8333, 0)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("NewNetAddressNetwork: got err <%v>, want "+
+			"MessageError", err)
+	}
+}
+
+// TestAddrV2Wire tests the MsgAddrV2 wire encode and decode for various
+// networks.
+func TestAddrV2Wire(t *testing.T) {
+	// NetAddresses of various networks to use for testing.
+	ts := time.Unix(0x495fab29, 0) // 2009-01-03 12:15:05 -0600 CST
+	na := NewNetAddressTimestamp(ts, SFNodeNetwork,
+		net.ParseIP("127.0.0.1"), 8333)
+	na2, _ := NewNetAddressNetwork(NetTorV3, torV3Addr, 8334, SFNodeNetwork)
+	na2.Timestamp = ts
+	na3, _ := NewNetAddressNetwork(NetworkID(0xff), []byte{0x01, 0x02},
+		8335, 0)
+	na3.Timestamp = ts
+
+	// Empty address message.
+	noAddr := NewMsgAddrV2()
+	noAddrEncoded := []byte{
+		0x00, // Varint for number of addresses
+	}
+
+	// Address message with multiple addresses.
+	multiAddr := NewMsgAddrV2()
+	multiAddr.AddAddresses(na, na2, na3)
+	multiAddrEncoded := []byte{
+		0x03,                   // Varint for number of addresses
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x01,                   // Varint for SFNodeNetwork
+		0x01,                   // NetIPv4
+		0x04,                   // Varint for address length
+		0x7f, 0x00, 0x00, 0x01, // IP 127.0.0.1
+		0x20, 0x8d, // Port 8333 in big-endian
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x01, // Varint for SFNodeNetwork
+		0x04, // NetTorV3
+		0x20, // Varint for address length
+		0x53, 0xcd, 0x5d, 0xa3, 0x87, 0x1c, 0x2f, 0x72,
+		0x2b, 0xa1, 0x2e, 0x1a, 0x60, 0x1c, 0x61, 0x9d,
+		0x14, 0x5f, 0x7c, 0x05, 0x46, 0x70, 0x5e, 0x66,
+		0x27, 0x3e, 0x7f, 0x72, 0x16, 0x22, 0x35, 0x4d, // Tor v3 key
+		0x20, 0x8e, // Port 8334 in big-endian
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x00,       // Varint for no services
+		0xff,       // Unknown network
+		0x02,       // Varint for address length
+		0x01, 0x02, // Address
+		0x20, 0x8f, // Port 8335 in big-endian
+	}
+
+	tests := []struct {
+		in   *MsgAddrV2      // Message to encode
+		out  *MsgAddrV2      // Expected decoded message
+		buf  []byte          // Wire encoding
+		pver uint32          // Protocol version for wire encoding
+		enc  MessageEncoding // Message encoding format
+	}{
+		// Latest protocol version with no addresses.
+		{
+			noAddr,
+			noAddr,
+			noAddrEncoded,
+			ProtocolVersion,
+			BaseEncoding,
+		},
+
+		// Latest protocol version with multiple addresses.
+		{
+			multiAddr,
+			multiAddr,
+			multiAddrEncoded,
+			ProtocolVersion,
+			BaseEncoding,
+		},
+
+		// Protocol version AddrV2Version with multiple addresses.
+		{
+			multiAddr,
+			multiAddr,
+			multiAddrEncoded,
+			AddrV2Version,
+			BaseEncoding,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver, test.enc)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgAddrV2
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver, test.enc)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestAddrV2WireErrors performs negative tests against wire encode and decode
+// of MsgAddrV2 to confirm error paths work correctly.
+func TestAddrV2WireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	wireErr := &MessageError{}
+
+	na := NewNetAddressTimestamp(time.Unix(0x495fab29, 0), SFNodeNetwork,
+		net.ParseIP("127.0.0.1"), 8333)
+
+	// Address message with a single address.
+	baseAddr := NewMsgAddrV2()
+	baseAddr.AddAddress(na)
+	baseAddrEncoded := []byte{
+		0x01,                   // Varint for number of addresses
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x01,                   // Varint for SFNodeNetwork
+		0x01,                   // NetIPv4
+		0x04,                   // Varint for address length
+		0x7f, 0x00, 0x00, 0x01, // IP 127.0.0.1
+		0x20, 0x8d, // Port 8333 in big-endian
+	}
+
+	// Message that forces an error by having more than the max allowed
+	// addresses.
+	maxAddr := NewMsgAddrV2()
+	for i := 0; i < MaxAddrPerMsg; i++ {
+		maxAddr.AddAddress(na)
+	}
+	maxAddr.AddrList = append(maxAddr.AddrList, na)
+	maxAddrEncoded := []byte{
+		0xfd, 0x03, 0xe9, // Varint for number of addresses (1001)
+	}
+
+	// Message that forces an error by having an address of a known network
+	// with the wrong size.
+	badSizeEncoded := []byte{
+		0x01,                   // Varint for number of addresses
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x01,             // Varint for SFNodeNetwork
+		0x01,             // NetIPv4
+		0x03,             // Varint for address length
+		0x7f, 0x00, 0x00, // Truncated IP
+		0x20, 0x8d, // Port 8333 in big-endian
+	}
+
+	// Message that forces an error by having an address which is longer
+	// than allowed.
+	longAddr := NewMsgAddrV2()
+	longAddr.AddAddress(&NetAddress{
+		Network: NetworkID(0xff),
+		Addr:    make([]byte, MaxAddrV2Size+1),
+	})
+	longAddrEncoded := []byte{
+		0x01,                   // Varint for number of addresses
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x00,             // Varint for no services
+		0xff,             // Unknown network
+		0xfd, 0x01, 0x02, // Varint for address length (513)
+	}
+
+	tests := []struct {
+		in       *MsgAddrV2      // Value to encode
+		buf      []byte          // Wire encoding
+		pver     uint32          // Protocol version for wire encoding
+		enc      MessageEncoding // Message encoding format
+		max      int             // Max size of fixed buffer to induce errors
+		writeErr error           // Expected write error
+		readErr  error           // Expected read error
+	}{
+		// Latest protocol version with intentional read/write errors.
+		// Force error in addresses count
+		{baseAddr, baseAddrEncoded, pver, BaseEncoding, 0, io.ErrShortWrite, io.EOF},
+		// Force error in timestamp.
+		{baseAddr, baseAddrEncoded, pver, BaseEncoding, 1, io.ErrShortWrite, io.EOF},
+		// Force error in services.
+		{baseAddr, baseAddrEncoded, pver, BaseEncoding, 5, io.ErrShortWrite, io.EOF},
+		// Force error in network.
+		{baseAddr, baseAddrEncoded, pver, BaseEncoding, 6, io.ErrShortWrite, io.EOF},
+		// Force error in address.
+		{baseAddr, baseAddrEncoded, pver, BaseEncoding, 7, io.ErrShortWrite, io.EOF},
+		// Force error in port.
+		{baseAddr, baseAddrEncoded, pver, BaseEncoding, 12, io.ErrShortWrite, io.EOF},
+		// Force error with greater than max addresses.
+		{maxAddr, maxAddrEncoded, pver, BaseEncoding, 3, wireErr, wireErr},
+		// Force error with address of a known network with the wrong
+		// size.
+		{baseAddr, badSizeEncoded, pver, BaseEncoding, 100, nil, wireErr},
+		// Force error with address longer than allowed.
+		{longAddr, longAddrEncoded, pver, BaseEncoding, 1000, wireErr, wireErr},
+		// Force error due to old protocol version.
+		{baseAddr, baseAddrEncoded, AddrV2Version - 1, BaseEncoding, 100, wireErr, wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver, test.enc)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.writeErr {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg MsgAddrV2
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver, test.enc)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.readErr {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
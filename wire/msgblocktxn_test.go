@@ -0,0 +1,84 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestBlockTxn tests the MsgBlockTxn API and wire encoding.
+func TestBlockTxn(t *testing.T) {
+	pver := ProtocolVersion
+	hash := blockOne.BlockHash()
+	tx := blockOne.Transactions[0]
+
+	// Ensure the command is expected value.
+	wantCmd := "blocktxn"
+	msg := NewMsgBlockTxn(&hash)
+	msg.AddTransaction(tx)
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgBlockTxn: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	wantPayload := uint32(MaxBlockPayload)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure the message encodes to the expected bytes.
+	var buf bytes.Buffer
+	err := msg.BtcEncode(&buf, pver, BaseEncoding)
+	if err != nil {
+		t.Fatalf("BtcEncode error %v", err)
+	}
+	want := bytes.NewBuffer(append(hash.CloneBytes(), 0x01))
+	tx.BtcEncode(want, pver, BaseEncoding)
+	if !bytes.Equal(buf.Bytes(), want.Bytes()) {
+		t.Fatalf("BtcEncode\n got: %s want: %s",
+			spew.Sdump(buf.Bytes()), spew.Sdump(want.Bytes()))
+	}
+
+	// Ensure the message decodes to the same values.
+	var readmsg MsgBlockTxn
+	err = readmsg.BtcDecode(&buf, pver, BaseEncoding)
+	if err != nil {
+		t.Fatalf("BtcDecode error %v", err)
+	}
+	if !reflect.DeepEqual(&readmsg, msg) {
+		t.Errorf("BtcDecode\n got: %s want: %s", spew.Sdump(readmsg),
+			spew.Sdump(msg))
+	}
+
+	// Messages with more transactions than fit in a block are rejected.
+	tooMany := append(hash.CloneBytes(), 0xfe, 0xff, 0xff, 0xff, 0xff)
+	err = readmsg.BtcDecode(bytes.NewReader(tooMany), pver, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcDecode of too many transactions got err <%v>, "+
+			"want MessageError", err)
+	}
+
+	// Older protocol versions should fail encode and decode since the
+	// message didn't exist yet.
+	oldPver := CompactBlocksVersion - 1
+	err = msg.BtcEncode(&buf, oldPver, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcEncode for old protocol version got err <%v>, "+
+			"want MessageError", err)
+	}
+	err = readmsg.BtcDecode(want, oldPver, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcDecode for old protocol version got err <%v>, "+
+			"want MessageError", err)
+	}
+}
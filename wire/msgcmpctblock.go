@@ -0,0 +1,278 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/aead/siphash"
+	"github.com/encrypt-s/navd/chaincfg/chainhash"
+)
+
+const (
+	// ShortIDSize is the number of bytes of a short transaction ID in a
+	// compact block.
+	ShortIDSize = 6
+
+	// shortIDMask is the mask applied to the SipHash of a transaction hash
+	// to obtain its short ID.
+	shortIDMask = 1<<(ShortIDSize*8) - 1
+)
+
+// PrefilledTx houses a transaction which is sent in full within a compact
+// block along with its index in the block.
+type PrefilledTx struct {
+	Index uint32
+	Tx    *MsgTx
+}
+
+// MsgCmpctBlock implements the Message interface and represents a navcoin
+// cmpctblock message.  It is used to relay a block as its header along with
+// the short IDs of its transactions so the receiver is able to reconstruct
+// the block from the transactions it already has (BIP0152).  Transactions the
+// receiver is unlikely to have, such as the coinbase, are sent in full as
+// prefilled transactions.
+//
+// The indexes of the prefilled transactions are absolute and must be in
+// ascending order.  They are differentially encoded on the wire.
+//
+// This message was not added until protocol versions starting with
+// CompactBlocksVersion.
+type MsgCmpctBlock struct {
+	Header        BlockHeader
+	Nonce         uint64
+	ShortIDs      []uint64
+	PrefilledTxns []PrefilledTx
+}
+
+// BlockHash computes the block identifier hash for the compact block.
+func (msg *MsgCmpctBlock) BlockHash() chainhash.Hash {
+	return msg.Header.BlockHash()
+}
+
+// TotalTxns returns the number of transactions in the block described by the
+// compact block.
+func (msg *MsgCmpctBlock) TotalTxns() int {
+	return len(msg.ShortIDs) + len(msg.PrefilledTxns)
+}
+
+// ShortIDKey returns the SipHash key used to calculate the short IDs of the
+// transactions in the compact block.  It is the first 16 bytes of the sha256
+// of the serialized header followed by the nonce.
+func (msg *MsgCmpctBlock) ShortIDKey() [16]byte {
+	// Ignore the error returns since there is no way the encode could fail
+	// except being out of memory which would cause a run-time panic.
+	buf := bytes.NewBuffer(make([]byte, 0, MaxBlockHeaderPayload+8))
+	_ = writeBlockHeader(buf, 0, &msg.Header)
+	_ = writeElement(buf, msg.Nonce)
+
+	var key [16]byte
+	copy(key[:], chainhash.HashB(buf.Bytes()))
+	return key
+}
+
+// ShortTxID returns the short ID of the transaction with the passed hash for
+// the passed SipHash key.  The hash is the transaction hash for compact block
+// version 1 and the witness hash for compact block version 2.
+func ShortTxID(key *[16]byte, hash *chainhash.Hash) uint64 {
+	return siphash.Sum64(hash[:], key) & shortIDMask
+}
+
+// readShortID reads a short transaction ID from r.
+func readShortID(r io.Reader) (uint64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[:ShortIDSize]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buf[:]), nil
+}
+
+// writeShortID writes a short transaction ID to w.
+func writeShortID(w io.Writer, shortID uint64) error {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], shortID)
+	_, err := w.Write(buf[:ShortIDSize])
+	return err
+}
+
+// BtcDecode decodes r using the navcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("cmpctblock message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+
+	err := readBlockHeader(r, pver, &msg.Header)
+	if err != nil {
+		return err
+	}
+	err = readElement(r, &msg.Nonce)
+	if err != nil {
+		return err
+	}
+
+	// Prevent more short IDs than could possibly fit into a block.
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if count > maxTxPerBlock {
+		str := fmt.Sprintf("too many short ids to fit into a block "+
+			"[count %d, max %d]", count, maxTxPerBlock)
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+	msg.ShortIDs = make([]uint64, 0, count)
+	for i := uint64(0); i < count; i++ {
+		shortID, err := readShortID(r)
+		if err != nil {
+			return err
+		}
+		msg.ShortIDs = append(msg.ShortIDs, shortID)
+	}
+
+	// Prevent more transactions in total than could possibly fit into a
+	// block.
+	count, err = ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if count > maxTxPerBlock-uint64(len(msg.ShortIDs)) {
+		str := fmt.Sprintf("too many transactions to fit into a block "+
+			"[count %d, max %d]", count+uint64(len(msg.ShortIDs)),
+			maxTxPerBlock)
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+	msg.PrefilledTxns = make([]PrefilledTx, 0, count)
+	var nextIndex uint64
+	for i := uint64(0); i < count; i++ {
+		// The indexes are encoded as the difference from the index
+		// following the previous prefilled transaction.
+		diff, err := ReadVarInt(r, pver)
+		if err != nil {
+			return err
+		}
+		if diff >= maxTxPerBlock-nextIndex {
+			str := fmt.Sprintf("prefilled transaction index is "+
+				"too high [index %d, max %d]", nextIndex+diff,
+				maxTxPerBlock-1)
+			return messageError("MsgCmpctBlock.BtcDecode", str)
+		}
+		index := nextIndex + diff
+
+		tx := MsgTx{}
+		err = tx.BtcDecode(r, pver, enc)
+		if err != nil {
+			return err
+		}
+		msg.PrefilledTxns = append(msg.PrefilledTxns, PrefilledTx{
+			Index: uint32(index),
+			Tx:    &tx,
+		})
+		nextIndex = index + 1
+	}
+
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the navcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("cmpctblock message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgCmpctBlock.BtcEncode", str)
+	}
+
+	err := writeBlockHeader(w, pver, &msg.Header)
+	if err != nil {
+		return err
+	}
+	err = writeElement(w, msg.Nonce)
+	if err != nil {
+		return err
+	}
+
+	err = WriteVarInt(w, pver, uint64(len(msg.ShortIDs)))
+	if err != nil {
+		return err
+	}
+	for _, shortID := range msg.ShortIDs {
+		err = writeShortID(w, shortID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = WriteVarInt(w, pver, uint64(len(msg.PrefilledTxns)))
+	if err != nil {
+		return err
+	}
+	var nextIndex uint32
+	for i, prefilled := range msg.PrefilledTxns {
+		if i > 0 && prefilled.Index < nextIndex {
+			str := fmt.Sprintf("prefilled transaction indexes are "+
+				"not in ascending order [index %d after %d]",
+				prefilled.Index, nextIndex-1)
+			return messageError("MsgCmpctBlock.BtcEncode", str)
+		}
+		err = WriteVarInt(w, pver, uint64(prefilled.Index-nextIndex))
+		if err != nil {
+			return err
+		}
+		err = prefilled.Tx.BtcEncode(w, pver, enc)
+		if err != nil {
+			return err
+		}
+		nextIndex = prefilled.Index + 1
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgCmpctBlock) Command() string {
+	return CmdCmpctBlock
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) MaxPayloadLength(pver uint32) uint32 {
+	// A compact block is never larger than the full block it describes.
+	return MaxBlockPayload
+}
+
+// NewMsgCmpctBlock returns a new navcoin cmpctblock message that describes the
+// passed block using the given compact block version and nonce.  The coinbase
+// is sent as a prefilled transaction and all other transactions are
+// identified by their short IDs.  See MsgCmpctBlock for details.
+func NewMsgCmpctBlock(block *MsgBlock, version, nonce uint64) *MsgCmpctBlock {
+	msg := &MsgCmpctBlock{
+		Header: block.Header,
+		Nonce:  nonce,
+	}
+	if len(block.Transactions) == 0 {
+		return msg
+	}
+
+	msg.PrefilledTxns = []PrefilledTx{{Index: 0, Tx: block.Transactions[0]}}
+	msg.ShortIDs = make([]uint64, 0, len(block.Transactions)-1)
+	key := msg.ShortIDKey()
+	for _, tx := range block.Transactions[1:] {
+		var hash chainhash.Hash
+		if version == CmpctBlockVersion2 {
+			hash = tx.WitnessHash()
+		} else {
+			hash = tx.TxHash()
+		}
+		msg.ShortIDs = append(msg.ShortIDs, ShortTxID(&key, &hash))
+	}
+	return msg
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// cmpctTestBlock returns a block with a coinbase and two other transactions
+// for use in the compact block tests.
+func cmpctTestBlock() *MsgBlock {
+	block := &MsgBlock{Header: blockOne.Header}
+	for i := uint32(0); i < 3; i++ {
+		tx := blockOne.Transactions[0].Copy()
+		tx.LockTime = i
+		block.AddTransaction(tx)
+	}
+	return block
+}
+
+// TestCmpctBlock tests the MsgCmpctBlock API including the creation of compact
+// blocks and their short transaction IDs.
+func TestCmpctBlock(t *testing.T) {
+	pver := ProtocolVersion
+	block := cmpctTestBlock()
+
+	// Ensure the command is expected value.
+	wantCmd := "cmpctblock"
+	msg := NewMsgCmpctBlock(block, CmpctBlockVersion1, 0x0102030405060708)
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgCmpctBlock: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	wantPayload := uint32(MaxBlockPayload)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure the block is described correctly.
+	if msg.BlockHash() != block.BlockHash() {
+		t.Errorf("BlockHash: wrong hash - got %v, want %v",
+			msg.BlockHash(), block.BlockHash())
+	}
+	if msg.TotalTxns() != len(block.Transactions) {
+		t.Errorf("TotalTxns: wrong count - got %d, want %d",
+			msg.TotalTxns(), len(block.Transactions))
+	}
+	if len(msg.PrefilledTxns) != 1 || msg.PrefilledTxns[0].Index != 0 ||
+		msg.PrefilledTxns[0].Tx != block.Transactions[0] {
+
+		t.Errorf("NewMsgCmpctBlock: coinbase is not prefilled - got %s",
+			spew.Sdump(msg.PrefilledTxns))
+	}
+
+	// Ensure the short IDs are derived from the transaction hashes for
+	// version 1 and the witness hashes for version 2, and that they are
+	// limited to 6 bytes.
+	msgV2 := NewMsgCmpctBlock(block, CmpctBlockVersion2, msg.Nonce)
+	key := msg.ShortIDKey()
+	for i, tx := range block.Transactions[1:] {
+		txHash := tx.TxHash()
+		witnessHash := tx.WitnessHash()
+		if want := ShortTxID(&key, &txHash); msg.ShortIDs[i] != want {
+			t.Errorf("short id #%d: got %x, want %x", i,
+				msg.ShortIDs[i], want)
+		}
+		if want := ShortTxID(&key, &witnessHash); msgV2.ShortIDs[i] != want {
+			t.Errorf("witness short id #%d: got %x, want %x", i,
+				msgV2.ShortIDs[i], want)
+		}
+		if msg.ShortIDs[i] >= 1<<48 {
+			t.Errorf("short id #%d is larger than 6 bytes: %x", i,
+				msg.ShortIDs[i])
+		}
+	}
+
+	// Ensure a different nonce results in different short IDs.
+	other := NewMsgCmpctBlock(block, CmpctBlockVersion1, msg.Nonce+1)
+	if reflect.DeepEqual(other.ShortIDs, msg.ShortIDs) {
+		t.Error("short ids did not change with the nonce")
+	}
+}
+
+// TestCmpctBlockWire tests the MsgCmpctBlock wire encode and decode including
+// the differential encoding of the prefilled transaction indexes.
+func TestCmpctBlockWire(t *testing.T) {
+	pver := ProtocolVersion
+	block := cmpctTestBlock()
+
+	msg := &MsgCmpctBlock{
+		Header:   block.Header,
+		Nonce:    0x0102030405060708,
+		ShortIDs: []uint64{0x0000060504030201},
+		PrefilledTxns: []PrefilledTx{
+			{Index: 0, Tx: block.Transactions[0]},
+			{Index: 2, Tx: block.Transactions[2]},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := msg.BtcEncode(&buf, pver, BaseEncoding)
+	if err != nil {
+		t.Fatalf("BtcEncode error %v", err)
+	}
+
+	// Ensure the short ID and prefilled indexes encode as expected.
+	var want bytes.Buffer
+	writeBlockHeader(&want, pver, &block.Header)
+	want.Write([]byte{0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01})
+	want.Write([]byte{0x01, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	want.Write([]byte{0x02, 0x00})
+	block.Transactions[0].BtcEncode(&want, pver, BaseEncoding)
+	want.Write([]byte{0x01})
+	block.Transactions[2].BtcEncode(&want, pver, BaseEncoding)
+	if !bytes.Equal(buf.Bytes(), want.Bytes()) {
+		t.Fatalf("BtcEncode\n got: %s want: %s",
+			spew.Sdump(buf.Bytes()), spew.Sdump(want.Bytes()))
+	}
+
+	// Ensure the message decodes to the same values.
+	var readmsg MsgCmpctBlock
+	err = readmsg.BtcDecode(bytes.NewReader(want.Bytes()), pver,
+		BaseEncoding)
+	if err != nil {
+		t.Fatalf("BtcDecode error %v", err)
+	}
+	if !reflect.DeepEqual(&readmsg, msg) {
+		t.Errorf("BtcDecode\n got: %s want: %s", spew.Sdump(readmsg),
+			spew.Sdump(msg))
+	}
+}
+
+// TestCmpctBlockWireErrors performs negative tests against wire encode and
+// decode of MsgCmpctBlock to confirm error paths work correctly.
+func TestCmpctBlockWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	block := cmpctTestBlock()
+	msg := NewMsgCmpctBlock(block, CmpctBlockVersion1, 0)
+
+	// Older protocol versions should fail encode and decode since the
+	// message didn't exist yet.
+	var buf bytes.Buffer
+	err := msg.BtcEncode(&buf, CompactBlocksVersion-1, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcEncode for old protocol version got err <%v>, "+
+			"want MessageError", err)
+	}
+	var readmsg MsgCmpctBlock
+	err = readmsg.BtcDecode(&buf, CompactBlocksVersion-1, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcDecode for old protocol version got err <%v>, "+
+			"want MessageError", err)
+	}
+
+	// Prefilled transactions which are not in ascending order can't be
+	// encoded.
+	unordered := *msg
+	unordered.PrefilledTxns = []PrefilledTx{
+		{Index: 2, Tx: block.Transactions[2]},
+		{Index: 1, Tx: block.Transactions[1]},
+	}
+	err = unordered.BtcEncode(&buf, pver, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcEncode of unordered indexes got err <%v>, want "+
+			"MessageError", err)
+	}
+
+	// Messages with more short ids or prefilled indexes than fit in a
+	// block are rejected.
+	var header bytes.Buffer
+	writeBlockHeader(&header, pver, &block.Header)
+	header.Write(make([]byte, 8))
+	tooManyIDs := append([]byte{}, header.Bytes()...)
+	tooManyIDs = append(tooManyIDs, 0xfe, 0xff, 0xff, 0xff, 0xff)
+	tooHighIndex := append([]byte{}, header.Bytes()...)
+	tooHighIndex = append(tooHighIndex, 0x00, 0x01, 0xfe, 0xff, 0xff,
+		0xff, 0xff)
+	for i, encoded := range [][]byte{tooManyIDs, tooHighIndex} {
+		err := readmsg.BtcDecode(bytes.NewReader(encoded), pver,
+			BaseEncoding)
+		if _, ok := err.(*MessageError); !ok {
+			t.Errorf("BtcDecode #%d got err <%v>, want "+
+				"MessageError", i, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/encrypt-s/navd/chaincfg/chainhash"
+)
+
+// MsgGetBlockTxn implements the Message interface and represents a navcoin
+// getblocktxn message.  It is used to request the transactions of a block
+// which could not be found while reconstructing it from a compact block
+// (MsgCmpctBlock).  The transactions are returned via a blocktxn message
+// (MsgBlockTxn).
+//
+// The indexes of the requested transactions are absolute and must be in
+// ascending order.  They are differentially encoded on the wire.
+//
+// This message was not added until protocol versions starting with
+// CompactBlocksVersion.
+type MsgGetBlockTxn struct {
+	BlockHash chainhash.Hash
+	Indexes   []uint32
+}
+
+// BtcDecode decodes r using the navcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGetBlockTxn) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("getblocktxn message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgGetBlockTxn.BtcDecode", str)
+	}
+
+	err := readElement(r, &msg.BlockHash)
+	if err != nil {
+		return err
+	}
+
+	// Prevent more indexes than there could possibly be transactions in a
+	// block.
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if count > maxTxPerBlock {
+		str := fmt.Sprintf("too many transaction indexes for a block "+
+			"[count %d, max %d]", count, maxTxPerBlock)
+		return messageError("MsgGetBlockTxn.BtcDecode", str)
+	}
+
+	msg.Indexes = make([]uint32, 0, count)
+	var nextIndex uint64
+	for i := uint64(0); i < count; i++ {
+		// The indexes are encoded as the difference from the index
+		// following the previous requested transaction.
+		diff, err := ReadVarInt(r, pver)
+		if err != nil {
+			return err
+		}
+		if diff >= maxTxPerBlock-nextIndex {
+			str := fmt.Sprintf("transaction index is too high "+
+				"[index %d, max %d]", nextIndex+diff,
+				maxTxPerBlock-1)
+			return messageError("MsgGetBlockTxn.BtcDecode", str)
+		}
+		index := nextIndex + diff
+		msg.Indexes = append(msg.Indexes, uint32(index))
+		nextIndex = index + 1
+	}
+
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the navcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGetBlockTxn) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("getblocktxn message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgGetBlockTxn.BtcEncode", str)
+	}
+
+	err := writeElement(w, &msg.BlockHash)
+	if err != nil {
+		return err
+	}
+
+	err = WriteVarInt(w, pver, uint64(len(msg.Indexes)))
+	if err != nil {
+		return err
+	}
+	var nextIndex uint32
+	for i, index := range msg.Indexes {
+		if i > 0 && index < nextIndex {
+			str := fmt.Sprintf("transaction indexes are not in "+
+				"ascending order [index %d after %d]", index,
+				nextIndex-1)
+			return messageError("MsgGetBlockTxn.BtcEncode", str)
+		}
+		err = WriteVarInt(w, pver, uint64(index-nextIndex))
+		if err != nil {
+			return err
+		}
+		nextIndex = index + 1
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGetBlockTxn) Command() string {
+	return CmdGetBlockTxn
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGetBlockTxn) MaxPayloadLength(pver uint32) uint32 {
+	// Block hash + num indexes (varInt) + indexes (varInt each).  The
+	// differential encoding keeps each index within a 5 byte varint.
+	return chainhash.HashSize + MaxVarIntPayload + (maxTxPerBlock * 5)
+}
+
+// NewMsgGetBlockTxn returns a new navcoin getblocktxn message that requests
+// the transactions at the passed indexes of the block with the passed hash.
+// See MsgGetBlockTxn for details.
+func NewMsgGetBlockTxn(blockHash *chainhash.Hash, indexes []uint32) *MsgGetBlockTxn {
+	return &MsgGetBlockTxn{
+		BlockHash: *blockHash,
+		Indexes:   indexes,
+	}
+}
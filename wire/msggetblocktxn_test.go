@@ -0,0 +1,87 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestGetBlockTxn tests the MsgGetBlockTxn API and wire encoding including
+// the differential encoding of the transaction indexes.
+func TestGetBlockTxn(t *testing.T) {
+	pver := ProtocolVersion
+	hash := blockOne.BlockHash()
+
+	// Ensure the command is expected value.
+	wantCmd := "getblocktxn"
+	msg := NewMsgGetBlockTxn(&hash, []uint32{1, 2, 5})
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgGetBlockTxn: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure the message encodes to the expected bytes.
+	var buf bytes.Buffer
+	err := msg.BtcEncode(&buf, pver, BaseEncoding)
+	if err != nil {
+		t.Fatalf("BtcEncode error %v", err)
+	}
+	want := append(hash.CloneBytes(), 0x03, 0x01, 0x00, 0x02)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("BtcEncode\n got: %s want: %s",
+			spew.Sdump(buf.Bytes()), spew.Sdump(want))
+	}
+	if uint32(len(want)) > msg.MaxPayloadLength(pver) {
+		t.Errorf("MaxPayloadLength: %d is less than the encoded size %d",
+			msg.MaxPayloadLength(pver), len(want))
+	}
+
+	// Ensure the message decodes to the same values.
+	var readmsg MsgGetBlockTxn
+	err = readmsg.BtcDecode(bytes.NewReader(want), pver, BaseEncoding)
+	if err != nil {
+		t.Fatalf("BtcDecode error %v", err)
+	}
+	if !reflect.DeepEqual(&readmsg, msg) {
+		t.Errorf("BtcDecode\n got: %s want: %s", spew.Sdump(readmsg),
+			spew.Sdump(msg))
+	}
+
+	// Indexes which are not in ascending order can't be encoded.
+	unordered := NewMsgGetBlockTxn(&hash, []uint32{2, 1})
+	err = unordered.BtcEncode(&buf, pver, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcEncode of unordered indexes got err <%v>, want "+
+			"MessageError", err)
+	}
+
+	// Indexes beyond the number of transactions that fit in a block are
+	// rejected.
+	tooHigh := append(hash.CloneBytes(), 0x01, 0xfe, 0xff, 0xff, 0xff,
+		0xff)
+	err = readmsg.BtcDecode(bytes.NewReader(tooHigh), pver, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcDecode of too high index got err <%v>, want "+
+			"MessageError", err)
+	}
+
+	// Older protocol versions should fail encode and decode since the
+	// message didn't exist yet.
+	oldPver := CompactBlocksVersion - 1
+	err = msg.BtcEncode(&buf, oldPver, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcEncode for old protocol version got err <%v>, "+
+			"want MessageError", err)
+	}
+	err = readmsg.BtcDecode(bytes.NewReader(want), oldPver, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcDecode for old protocol version got err <%v>, "+
+			"want MessageError", err)
+	}
+}
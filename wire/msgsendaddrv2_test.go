@@ -0,0 +1,74 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestSendAddrV2 tests the MsgSendAddrV2 API against the latest protocol
+// version.
+func TestSendAddrV2(t *testing.T) {
+	pver := ProtocolVersion
+	enc := BaseEncoding
+
+	// Ensure the command is expected value.
+	wantCmd := "sendaddrv2"
+	msg := NewMsgSendAddrV2()
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgSendAddrV2: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	wantPayload := uint32(0)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure the message encodes to no bytes.
+	var buf bytes.Buffer
+	err := msg.BtcEncode(&buf, pver, enc)
+	if err != nil {
+		t.Fatalf("encode of MsgSendAddrV2 failed %v err <%v>", msg, err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("BtcEncode got: %s want no bytes",
+			spew.Sdump(buf.Bytes()))
+	}
+
+	// Ensure the message decodes.
+	var readmsg MsgSendAddrV2
+	err = readmsg.BtcDecode(&buf, pver, enc)
+	if err != nil {
+		t.Fatalf("decode of MsgSendAddrV2 failed [%v] err <%v>", buf,
+			err)
+	}
+	if !reflect.DeepEqual(&readmsg, msg) {
+		t.Errorf("BtcDecode got: %s want: %s", spew.Sdump(readmsg),
+			spew.Sdump(msg))
+	}
+
+	// Older protocol versions should fail encode and decode since the
+	// message didn't exist yet.
+	oldPver := AddrV2Version - 1
+	err = msg.BtcEncode(&buf, oldPver, enc)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("encode of MsgSendAddrV2 for old protocol version "+
+			"got err <%v>, want MessageError", err)
+	}
+	err = readmsg.BtcDecode(&buf, oldPver, enc)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("decode of MsgSendAddrV2 for old protocol version "+
+			"got err <%v>, want MessageError", err)
+	}
+}
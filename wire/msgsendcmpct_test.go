@@ -0,0 +1,75 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestSendCmpct tests the MsgSendCmpct API against the latest protocol
+// version.
+func TestSendCmpct(t *testing.T) {
+	pver := ProtocolVersion
+	enc := BaseEncoding
+
+	// Ensure the command is expected value.
+	wantCmd := "sendcmpct"
+	msg := NewMsgSendCmpct(true, CmpctBlockVersion2)
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgSendCmpct: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	wantPayload := uint32(9)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure the message encodes to the expected bytes.
+	var buf bytes.Buffer
+	err := msg.BtcEncode(&buf, pver, enc)
+	if err != nil {
+		t.Fatalf("encode of MsgSendCmpct failed %v err <%v>", msg, err)
+	}
+	wantBytes := []byte{0x01, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	if !bytes.Equal(buf.Bytes(), wantBytes) {
+		t.Errorf("BtcEncode got: %s want: %s",
+			spew.Sdump(buf.Bytes()), spew.Sdump(wantBytes))
+	}
+
+	// Ensure the message decodes to the same values.
+	var readmsg MsgSendCmpct
+	err = readmsg.BtcDecode(bytes.NewReader(wantBytes), pver, enc)
+	if err != nil {
+		t.Fatalf("decode of MsgSendCmpct failed [%v] err <%v>", buf,
+			err)
+	}
+	if !reflect.DeepEqual(&readmsg, msg) {
+		t.Errorf("BtcDecode got: %s want: %s", spew.Sdump(readmsg),
+			spew.Sdump(msg))
+	}
+
+	// Older protocol versions should fail encode and decode since the
+	// message didn't exist yet.
+	oldPver := CompactBlocksVersion - 1
+	err = msg.BtcEncode(&buf, oldPver, enc)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("encode of MsgSendCmpct for old protocol version "+
+			"got err <%v>, want MessageError", err)
+	}
+	err = readmsg.BtcDecode(bytes.NewReader(wantBytes), oldPver, enc)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("decode of MsgSendCmpct for old protocol version "+
+			"got err <%v>, want MessageError", err)
+	}
+}
@@ -339,6 +339,7 @@ func (msg *MsgTx) Copy() *MsgTx {
 	// for the transaction inputs and outputs.
 	newTx := MsgTx{
 		Version:  msg.Version,
+		Time:     msg.Time,
 		TxIn:     make([]*TxIn, 0, len(msg.TxIn)),
 		TxOut:    make([]*TxOut, 0, len(msg.TxOut)),
 		LockTime: msg.LockTime,
@@ -404,6 +405,12 @@ func (msg *MsgTx) Copy() *MsgTx {
 		newTx.TxOut = append(newTx.TxOut, &newTxOut)
 	}
 
+	// Deep copy the old strdzeel data.
+	if len(msg.Strdzeel) != 0 {
+		newTx.Strdzeel = make([]byte, len(msg.Strdzeel))
+		copy(newTx.Strdzeel, msg.Strdzeel)
+	}
+
 	return &newTx
 }
 
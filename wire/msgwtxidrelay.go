@@ -0,0 +1,63 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+// MsgWTxIDRelay implements the Message interface and represents a navcoin
+// wtxidrelay message.  It is used to announce support for announcing and
+// requesting transactions by their witness hash (wtxid) using the InvTypeWTx
+// inventory type instead of by their hash (BIP0339).  Transactions are relayed
+// by wtxid once both peers sent it.  It must be sent after the version message
+// and before the verack message.
+//
+// This message has no payload and was not added until protocol versions
+// starting with WTxIDRelayVersion.
+type MsgWTxIDRelay struct{}
+
+// BtcDecode decodes r using the navcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgWTxIDRelay) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	if pver < WTxIDRelayVersion {
+		str := fmt.Sprintf("wtxidrelay message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgWTxIDRelay.BtcDecode", str)
+	}
+
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the navcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgWTxIDRelay) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if pver < WTxIDRelayVersion {
+		str := fmt.Sprintf("wtxidrelay message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgWTxIDRelay.BtcEncode", str)
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgWTxIDRelay) Command() string {
+	return CmdWTxIDRelay
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgWTxIDRelay) MaxPayloadLength(pver uint32) uint32 {
+	return 0
+}
+
+// NewMsgWTxIDRelay returns a new navcoin wtxidrelay message that conforms to
+// the Message interface.  See MsgWTxIDRelay for details.
+func NewMsgWTxIDRelay() *MsgWTxIDRelay {
+	return &MsgWTxIDRelay{}
+}
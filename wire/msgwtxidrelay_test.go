@@ -0,0 +1,74 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestWTxIDRelay tests the MsgWTxIDRelay API against the latest protocol
+// version.
+func TestWTxIDRelay(t *testing.T) {
+	pver := ProtocolVersion
+	enc := BaseEncoding
+
+	// Ensure the command is expected value.
+	wantCmd := "wtxidrelay"
+	msg := NewMsgWTxIDRelay()
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgWTxIDRelay: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	wantPayload := uint32(0)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure the message encodes to no bytes.
+	var buf bytes.Buffer
+	err := msg.BtcEncode(&buf, pver, enc)
+	if err != nil {
+		t.Fatalf("encode of MsgWTxIDRelay failed %v err <%v>", msg, err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("BtcEncode got: %s want no bytes",
+			spew.Sdump(buf.Bytes()))
+	}
+
+	// Ensure the message decodes.
+	var readmsg MsgWTxIDRelay
+	err = readmsg.BtcDecode(&buf, pver, enc)
+	if err != nil {
+		t.Fatalf("decode of MsgWTxIDRelay failed [%v] err <%v>", buf,
+			err)
+	}
+	if !reflect.DeepEqual(&readmsg, msg) {
+		t.Errorf("BtcDecode got: %s want: %s", spew.Sdump(readmsg),
+			spew.Sdump(msg))
+	}
+
+	// Older protocol versions should fail encode and decode since the
+	// message didn't exist yet.
+	oldPver := WTxIDRelayVersion - 1
+	err = msg.BtcEncode(&buf, oldPver, enc)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("encode of MsgWTxIDRelay for old protocol version "+
+			"got err <%v>, want MessageError", err)
+	}
+	err = readmsg.BtcDecode(&buf, oldPver, enc)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("decode of MsgWTxIDRelay for old protocol version "+
+			"got err <%v>, want MessageError", err)
+	}
+}
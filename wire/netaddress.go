@@ -44,6 +44,16 @@ type NetAddress struct {
 	// Port the peer is using.  This is encoded in big endian on the wire
 	// which differs from most everything else.
 	Port uint16
+
+	// Network identifies the network of Addr for addresses which can't be
+	// represented by an IP address, such as Tor v3 and I2P addresses.  It
+	// is zero for IP based addresses.
+	Network NetworkID
+
+	// Addr holds the address of the peer on Network when it can't be
+	// represented by an IP address, in which case IP is nil.  Such
+	// addresses can only be relayed by addrv2 messages (MsgAddrV2).
+	Addr []byte
 }
 
 // HasService returns whether the specified service is supported by the address.
@@ -0,0 +1,234 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+// MaxAddrV2Size is the maximum number of bytes of an address in an addrv2
+// message.
+const MaxAddrV2Size = 512
+
+// NetworkID identifies the network of an address in an addrv2 message
+// (BIP0155).
+type NetworkID uint8
+
+const (
+	// NetIPv4 identifies IPv4 addresses.
+	NetIPv4 NetworkID = 1
+
+	// NetIPv6 identifies IPv6 addresses.
+	NetIPv6 NetworkID = 2
+
+	// NetTorV2 identifies Tor v2 onion addresses.
+	NetTorV2 NetworkID = 3
+
+	// NetTorV3 identifies Tor v3 onion addresses.
+	NetTorV3 NetworkID = 4
+
+	// NetI2P identifies I2P addresses.
+	NetI2P NetworkID = 5
+
+	// NetCJDNS identifies CJDNS addresses.
+	NetCJDNS NetworkID = 6
+)
+
+// Map of network IDs back to their constant names for pretty printing.
+var netIDStrings = map[NetworkID]string{
+	NetIPv4:  "NetIPv4",
+	NetIPv6:  "NetIPv6",
+	NetTorV2: "NetTorV2",
+	NetTorV3: "NetTorV3",
+	NetI2P:   "NetI2P",
+	NetCJDNS: "NetCJDNS",
+}
+
+// String returns the NetworkID in human-readable form.
+func (id NetworkID) String() string {
+	if s, ok := netIDStrings[id]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("Unknown NetworkID (%d)", uint8(id))
+}
+
+// addrV2Sizes houses the address sizes of the known networks.  Addresses of
+// known networks with any other size are invalid.
+var addrV2Sizes = map[NetworkID]int{
+	NetIPv4:  4,
+	NetIPv6:  16,
+	NetTorV2: 10,
+	NetTorV3: 32,
+	NetI2P:   32,
+	NetCJDNS: 16,
+}
+
+// onionCatPrefix is the prefix of the IPv6 range used by OnionCat to represent
+// Tor v2 onion addresses as IP addresses (fd87:d87e:eb43::/48).
+var onionCatPrefix = []byte{0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43}
+
+// maxNetAddressV2Payload returns the max payload size for a navcoin NetAddress
+// encoded in an addrv2 message.
+func maxNetAddressV2Payload() uint32 {
+	// Timestamp 4 bytes + services (varInt) + network id 1 byte + addr
+	// length (varInt) + max addr size + port 2 bytes.
+	return 4 + MaxVarIntPayload + 1 + MaxVarIntPayload + MaxAddrV2Size + 2
+}
+
+// NetworkID returns the network of the address as identified in addrv2
+// messages.  IP based addresses are identified as IPv4, IPv6 or Tor v2 when
+// they are in the range used by OnionCat.
+func (na *NetAddress) NetworkID() NetworkID {
+	switch {
+	case na.Addr != nil:
+		return na.Network
+	case na.IP.To4() != nil:
+		return NetIPv4
+	case len(na.IP) == net.IPv6len && bytes.HasPrefix(na.IP, onionCatPrefix):
+		return NetTorV2
+	default:
+		return NetIPv6
+	}
+}
+
+// RequiresAddrV2 returns whether the address can't be represented by an IP
+// address and therefore can only be relayed by addrv2 messages.
+func (na *NetAddress) RequiresAddrV2() bool {
+	return na.Addr != nil
+}
+
+// NewNetAddressNetwork returns a new NetAddress for the provided address on
+// the given network, port, and supported services with defaults for the
+// remaining fields.  IPv4, IPv6 and Tor v2 addresses are converted to their IP
+// representation while the addresses of other networks are kept as is.  An
+// error is returned when the address size does not match the network.
+func NewNetAddressNetwork(network NetworkID, addr []byte, port uint16,
+	services ServiceFlag) (*NetAddress, error) {
+
+	na := NewNetAddressIPPort(nil, port, services)
+	if err := na.setAddr(network, addr); err != nil {
+		return nil, err
+	}
+	return na, nil
+}
+
+// setAddr sets the address of the NetAddress to the provided address on the
+// given network.  IPv4, IPv6 and Tor v2 addresses are converted to their IP
+// representation while the addresses of other networks are kept as is.
+func (na *NetAddress) setAddr(network NetworkID, addr []byte) error {
+	if size, ok := addrV2Sizes[network]; ok && len(addr) != size {
+		str := fmt.Sprintf("invalid address size for network %v "+
+			"[size %d, want %d]", network, len(addr), size)
+		return messageError("NetAddress.setAddr", str)
+	}
+
+	na.IP = nil
+	na.Network = 0
+	na.Addr = nil
+	switch network {
+	case NetIPv4, NetIPv6:
+		na.IP = net.IP(addr).To16()
+	case NetTorV2:
+		ip := make(net.IP, 0, net.IPv6len)
+		ip = append(ip, onionCatPrefix...)
+		na.IP = append(ip, addr...)
+	default:
+		na.Network = network
+		na.Addr = make([]byte, len(addr))
+		copy(na.Addr, addr)
+	}
+	return nil
+}
+
+// readNetAddressV2 reads an encoded NetAddress from r as it is encoded in
+// addrv2 messages.  Addresses of unknown networks are read as is so that the
+// caller is able to ignore them.
+func readNetAddressV2(r io.Reader, pver uint32, na *NetAddress) error {
+	err := readElement(r, (*uint32Time)(&na.Timestamp))
+	if err != nil {
+		return err
+	}
+
+	services, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+
+	var network NetworkID
+	err = readElement(r, (*uint8)(&network))
+	if err != nil {
+		return err
+	}
+	addr, err := ReadVarBytes(r, pver, MaxAddrV2Size, "NetAddress.Addr")
+	if err != nil {
+		return err
+	}
+
+	// Sigh.  NavCoin protocol mixes little and big endian.
+	port, err := binarySerializer.Uint16(r, bigEndian)
+	if err != nil {
+		return err
+	}
+
+	*na = NetAddress{
+		Timestamp: na.Timestamp,
+		Services:  ServiceFlag(services),
+		Port:      port,
+	}
+	return na.setAddr(network, addr)
+}
+
+// writeNetAddressV2 serializes a NetAddress to w as it is encoded in addrv2
+// messages.
+func writeNetAddressV2(w io.Writer, pver uint32, na *NetAddress) error {
+	// NOTE: The navcoin protocol uses a uint32 for the timestamp so it will
+	// stop working somewhere around 2106.
+	err := writeElement(w, uint32(na.Timestamp.Unix()))
+	if err != nil {
+		return err
+	}
+
+	err = WriteVarInt(w, pver, uint64(na.Services))
+	if err != nil {
+		return err
+	}
+
+	network := na.NetworkID()
+	var addr []byte
+	switch network {
+	case NetIPv4:
+		addr = na.IP.To4()
+	case NetTorV2:
+		addr = na.IP[len(onionCatPrefix):]
+	case NetIPv6:
+		// Ensure to always write 16 bytes even if the ip is nil.
+		addr = make([]byte, net.IPv6len)
+		copy(addr, na.IP.To16())
+	default:
+		addr = na.Addr
+	}
+	if len(addr) > MaxAddrV2Size {
+		str := fmt.Sprintf("address is too long [size %d, max %d]",
+			len(addr), MaxAddrV2Size)
+		return messageError("writeNetAddressV2", str)
+	}
+
+	err = writeElement(w, uint8(network))
+	if err != nil {
+		return err
+	}
+	err = WriteVarBytes(w, pver, addr)
+	if err != nil {
+		return err
+	}
+
+	// Sigh.  NavCoin protocol mixes little and big endian.
+	return binary.Write(w, bigEndian, na.Port)
+}
@@ -13,7 +13,7 @@ import (
 // XXX pedro: we will probably need to bump this.
 const (
 	// ProtocolVersion is the latest protocol version this package supports.
-	ProtocolVersion uint32 = 70020
+	ProtocolVersion uint32 = 70023
 
 	// MultipleAddressVersion is the protocol version which added multiple
 	// addresses per message (pver >= MultipleAddressVersion).
@@ -51,6 +51,21 @@ const (
 	// FeeFilterVersion is the protocol version which added a new
 	// feefilter message.
 	FeeFilterVersion uint32 = 70020
+
+	// CompactBlocksVersion is the protocol version which added the
+	// sendcmpct, cmpctblock, getblocktxn and blocktxn messages used to
+	// relay compact blocks (BIP0152).
+	CompactBlocksVersion uint32 = 70021
+
+	// AddrV2Version is the protocol version which added the sendaddrv2 and
+	// addrv2 messages used to relay addresses of networks which can't be
+	// represented by an IP address (BIP0155).
+	AddrV2Version uint32 = 70022
+
+	// WTxIDRelayVersion is the protocol version which added the wtxidrelay
+	// message and the InvTypeWTx inventory type used to relay transactions
+	// by their witness hash (BIP0339).
+	WTxIDRelayVersion uint32 = 70023
 )
 
 // ServiceFlag identifies services supported by a navcoin peer.
@@ -86,6 +101,10 @@ const (
 	// SFNode2X is a flag used to indicate a peer is running the Segwit2X
 	// software.
 	SFNode2X
+
+	// SFNodeP2PV2 is a flag used to indicate a peer supports the encrypted
+	// v2 transport protocol (BIP0324).
+	SFNodeP2PV2 ServiceFlag = 1 << 11
 )
 
 // Map of service flags back to their constant names for pretty printing.
@@ -98,6 +117,7 @@ var sfStrings = map[ServiceFlag]string{
 	SFNodeBit5:    "SFNodeBit5",
 	SFNodeCF:      "SFNodeCF",
 	SFNode2X:      "SFNode2X",
+	SFNodeP2PV2:   "SFNodeP2PV2",
 }
 
 // orderedSFStrings is an ordered list of service flags from highest to
@@ -111,6 +131,7 @@ var orderedSFStrings = []ServiceFlag{
 	SFNodeBit5,
 	SFNodeCF,
 	SFNode2X,
+	SFNodeP2PV2,
 }
 
 // String returns the ServiceFlag in human-readable form.
@@ -0,0 +1,181 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// v2ShortIDs houses the commands of the messages which are identified by a
+// single byte in the encrypted v2 transport protocol (BIP0324), indexed by
+// their short ID.  Short ID zero indicates the command follows in full.
+var v2ShortIDs = [...]string{
+	1:  CmdAddr,
+	2:  CmdBlock,
+	3:  CmdBlockTxn,
+	4:  CmdCmpctBlock,
+	5:  CmdFeeFilter,
+	6:  CmdFilterAdd,
+	7:  CmdFilterClear,
+	8:  CmdFilterLoad,
+	9:  CmdGetBlocks,
+	10: CmdGetBlockTxn,
+	11: CmdGetData,
+	12: CmdGetHeaders,
+	13: CmdHeaders,
+	14: CmdInv,
+	15: CmdMemPool,
+	16: CmdMerkleBlock,
+	17: CmdNotFound,
+	18: CmdPing,
+	19: CmdPong,
+	20: CmdSendCmpct,
+	21: CmdTx,
+	22: "getcfilters",
+	23: CmdCFilter,
+	24: CmdGetCFHeaders,
+	25: CmdCFHeaders,
+	26: "getcfcheckpt",
+	27: "cfcheckpt",
+	28: CmdAddrV2,
+}
+
+// v2CommandIDs maps the commands which have a short ID to it.
+var v2CommandIDs = func() map[string]byte {
+	ids := make(map[string]byte, len(v2ShortIDs))
+	for id, cmd := range v2ShortIDs {
+		if cmd != "" {
+			ids[cmd] = byte(id)
+		}
+	}
+	return ids
+}()
+
+// EncodeV2Message returns the contents of the packet which carries the provided
+// message in the encrypted v2 transport protocol (BIP0324).  The contents
+// consist of the short ID of the message command or a zero byte followed by
+// the zero padded command, followed by the message payload.
+func EncodeV2Message(msg Message, pver uint32, encoding MessageEncoding) ([]byte, error) {
+	cmd := msg.Command()
+	if len(cmd) > CommandSize {
+		str := fmt.Sprintf("command [%s] is too long [max %v]",
+			cmd, CommandSize)
+		return nil, messageError("EncodeV2Message", str)
+	}
+
+	var bw bytes.Buffer
+	if id, ok := v2CommandIDs[cmd]; ok {
+		bw.WriteByte(id)
+	} else {
+		var command [CommandSize]byte
+		copy(command[:], cmd)
+		bw.WriteByte(0)
+		bw.Write(command[:])
+	}
+	hdrLen := bw.Len()
+
+	err := msg.BtcEncode(&bw, pver, encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	// Enforce maximum overall message payload.
+	lenp := bw.Len() - hdrLen
+	if lenp > MaxMessagePayload {
+		str := fmt.Sprintf("message payload is too large - encoded "+
+			"%d bytes, but maximum message payload is %d bytes",
+			lenp, MaxMessagePayload)
+		return nil, messageError("EncodeV2Message", str)
+	}
+
+	// Enforce maximum message payload based on the message type.
+	mpl := msg.MaxPayloadLength(pver)
+	if uint32(lenp) > mpl {
+		str := fmt.Sprintf("message payload is too large - encoded "+
+			"%d bytes, but maximum message payload size for "+
+			"messages of type [%s] is %d.", lenp, cmd, mpl)
+		return nil, messageError("EncodeV2Message", str)
+	}
+
+	return bw.Bytes(), nil
+}
+
+// DecodeV2Message parses the message carried by the provided packet contents
+// of the encrypted v2 transport protocol (BIP0324).  It returns the parsed
+// Message and the raw payload bytes of the message.
+func DecodeV2Message(contents []byte, pver uint32, enc MessageEncoding) (Message, []byte, error) {
+	if len(contents) == 0 {
+		return nil, nil, messageError("DecodeV2Message", "empty message")
+	}
+
+	// Determine the command from the short ID or the full command.
+	var command string
+	payload := contents[1:]
+	if id := contents[0]; id != 0 {
+		if int(id) >= len(v2ShortIDs) || v2ShortIDs[id] == "" {
+			str := fmt.Sprintf("unknown short message id %d", id)
+			return nil, nil, messageError("DecodeV2Message", str)
+		}
+		command = v2ShortIDs[id]
+	} else {
+		if len(payload) < CommandSize {
+			str := fmt.Sprintf("message contents of %d bytes are "+
+				"too short for a command", len(contents))
+			return nil, nil, messageError("DecodeV2Message", str)
+		}
+
+		// The command must be zero padded without any bytes following
+		// the padding.
+		command = strings.TrimRight(string(payload[:CommandSize]), "\x00")
+		if strings.IndexByte(command, 0) != -1 {
+			str := fmt.Sprintf("invalid command %v",
+				payload[:CommandSize])
+			return nil, nil, messageError("DecodeV2Message", str)
+		}
+		payload = payload[CommandSize:]
+	}
+
+	// Check for malformed commands.
+	if !utf8.ValidString(command) {
+		str := fmt.Sprintf("invalid command %v", []byte(command))
+		return nil, nil, messageError("DecodeV2Message", str)
+	}
+
+	// Enforce maximum message payload.
+	if len(payload) > MaxMessagePayload {
+		str := fmt.Sprintf("message payload is too large - %d bytes, "+
+			"but max message payload is %d bytes.", len(payload),
+			MaxMessagePayload)
+		return nil, nil, messageError("DecodeV2Message", str)
+	}
+
+	// Create struct of appropriate message type based on the command.
+	msg, err := makeEmptyMessage(command)
+	if err != nil {
+		return nil, nil, messageError("DecodeV2Message", err.Error())
+	}
+
+	// Check for maximum length based on the message type.
+	mpl := msg.MaxPayloadLength(pver)
+	if uint32(len(payload)) > mpl {
+		str := fmt.Sprintf("payload exceeds max length - %v bytes, "+
+			"but max payload size for messages of type [%v] is %v.",
+			len(payload), command, mpl)
+		return nil, nil, messageError("DecodeV2Message", str)
+	}
+
+	// Unmarshal message.  NOTE: This must be a *bytes.Buffer since the
+	// MsgVersion BtcDecode function requires it.
+	pr := bytes.NewBuffer(payload)
+	err = msg.BtcDecode(pr, pver, enc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return msg, payload, nil
+}
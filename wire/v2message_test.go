@@ -0,0 +1,101 @@
+// Copyright (c) 2018 The NavCoin developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestV2Message tests the encoding and decoding of messages in the contents of
+// v2 transport packets.
+func TestV2Message(t *testing.T) {
+	pver := ProtocolVersion
+
+	tests := []struct {
+		in  Message // Value to encode
+		buf []byte  // Encoded value
+	}{
+		// Messages with a short ID.
+		{NewMsgPing(0x0102030405060708), []byte{
+			0x12, // Short ID 18
+			0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01,
+		}},
+		{NewMsgMemPool(), []byte{0x0f}},
+		{NewMsgAddrV2(), []byte{0x1c, 0x00}},
+
+		// Messages without a short ID.
+		{NewMsgVerAck(), []byte{
+			0x00,
+			'v', 'e', 'r', 'a', 'c', 'k', 0, 0, 0, 0, 0, 0,
+		}},
+		{NewMsgSendAddrV2(), []byte{
+			0x00,
+			's', 'e', 'n', 'd', 'a', 'd', 'd', 'r', 'v', '2', 0, 0,
+		}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		buf, err := EncodeV2Message(test.in, pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("EncodeV2Message #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf, test.buf) {
+			t.Errorf("EncodeV2Message #%d\n got: %s want: %s", i,
+				spew.Sdump(buf), spew.Sdump(test.buf))
+			continue
+		}
+
+		msg, _, err := DecodeV2Message(test.buf, pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("DecodeV2Message #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(msg, test.in) {
+			t.Errorf("DecodeV2Message #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.in))
+			continue
+		}
+	}
+}
+
+// TestV2MessageErrors performs negative tests against decoding the contents of
+// v2 transport packets to confirm error paths work correctly.
+func TestV2MessageErrors(t *testing.T) {
+	pver := ProtocolVersion
+
+	tests := []struct {
+		buf []byte
+	}{
+		// Empty contents.
+		{[]byte{}},
+		// Unknown short ID.
+		{[]byte{0xff}},
+		// Short ID without a message known to this package.
+		{[]byte{0x1a}},
+		// Truncated command.
+		{[]byte{0x00, 'v', 'e', 'r'}},
+		// Command with data after the padding.
+		{[]byte{0x00, 'v', 'e', 'r', 'a', 'c', 'k', 0, 0, 0, 0, 0, 'x'}},
+		// Unknown command.
+		{[]byte{0x00, 'b', 'o', 'g', 'u', 's', 0, 0, 0, 0, 0, 0, 0}},
+		// Payload exceeding the max payload of the message.
+		{[]byte{0x0f, 0x00}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		_, _, err := DecodeV2Message(test.buf, pver, BaseEncoding)
+		if _, ok := err.(*MessageError); !ok {
+			t.Errorf("DecodeV2Message #%d wrong error got: %v, "+
+				"want: *MessageError", i, err)
+		}
+	}
+}